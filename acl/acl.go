@@ -0,0 +1,250 @@
+// Package acl 对象级别的访问控制列表
+// API 格式为 {"userId":{"read":true,"write":true},"role:admin":{"read":true},"*":{"read":true}}
+// 数据库中以 _rperm 与 _wperm 两个数组保存，如 {"_rperm":["userId","role:admin","*"],"_wperm":["userId"]}
+package acl
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// PublicKey 表示所有人
+const PublicKey = "*"
+
+// rolePrefix 角色的前缀
+const rolePrefix = "role:"
+
+// Permission 单个用户、角色或者所有人的权限
+type Permission struct {
+	Read  bool
+	Write bool
+}
+
+// ACL 访问控制列表，以用户 id 、 role:角色名 或者 * 为索引
+type ACL map[string]Permission
+
+// New 创建空的 ACL ，即只有 Master Key 可以访问
+func New() ACL {
+	return ACL{}
+}
+
+// RoleKey 返回角色在 ACL 中的索引
+func RoleKey(roleName string) string {
+	return rolePrefix + roleName
+}
+
+// SetReadAccess 设置读权限， id 为用户 id 、 role:角色名 或者 *
+func (a ACL) SetReadAccess(id string, allowed bool) {
+	p := a[id]
+	p.Read = allowed
+	a.set(id, p)
+}
+
+// SetWriteAccess 设置写权限， id 为用户 id 、 role:角色名 或者 *
+func (a ACL) SetWriteAccess(id string, allowed bool) {
+	p := a[id]
+	p.Write = allowed
+	a.set(id, p)
+}
+
+// set 没有任何权限时删除该项
+func (a ACL) set(id string, p Permission) {
+	if p.Read == false && p.Write == false {
+		delete(a, id)
+		return
+	}
+	a[id] = p
+}
+
+// GetReadAccess 是否有读权限，只检查 id 本身，不包含 *
+func (a ACL) GetReadAccess(id string) bool {
+	return a[id].Read
+}
+
+// GetWriteAccess 是否有写权限，只检查 id 本身，不包含 *
+func (a ACL) GetWriteAccess(id string) bool {
+	return a[id].Write
+}
+
+// SetUserReadAccess 设置用户的读权限
+func (a ACL) SetUserReadAccess(userID string, allowed bool) {
+	a.SetReadAccess(userID, allowed)
+}
+
+// SetUserWriteAccess 设置用户的写权限
+func (a ACL) SetUserWriteAccess(userID string, allowed bool) {
+	a.SetWriteAccess(userID, allowed)
+}
+
+// SetRoleReadAccess 设置角色的读权限
+func (a ACL) SetRoleReadAccess(roleName string, allowed bool) {
+	a.SetReadAccess(RoleKey(roleName), allowed)
+}
+
+// SetRoleWriteAccess 设置角色的写权限
+func (a ACL) SetRoleWriteAccess(roleName string, allowed bool) {
+	a.SetWriteAccess(RoleKey(roleName), allowed)
+}
+
+// GetRoleReadAccess 角色是否有读权限
+func (a ACL) GetRoleReadAccess(roleName string) bool {
+	return a.GetReadAccess(RoleKey(roleName))
+}
+
+// GetRoleWriteAccess 角色是否有写权限
+func (a ACL) GetRoleWriteAccess(roleName string) bool {
+	return a.GetWriteAccess(RoleKey(roleName))
+}
+
+// SetPublicReadAccess 设置所有人的读权限
+func (a ACL) SetPublicReadAccess(allowed bool) {
+	a.SetReadAccess(PublicKey, allowed)
+}
+
+// SetPublicWriteAccess 设置所有人的写权限
+func (a ACL) SetPublicWriteAccess(allowed bool) {
+	a.SetWriteAccess(PublicKey, allowed)
+}
+
+// GetPublicReadAccess 所有人是否有读权限
+func (a ACL) GetPublicReadAccess() bool {
+	return a.GetReadAccess(PublicKey)
+}
+
+// GetPublicWriteAccess 所有人是否有写权限
+func (a ACL) GetPublicWriteAccess() bool {
+	return a.GetWriteAccess(PublicKey)
+}
+
+// CanRead aclGroup 中的用户或者角色是否有读权限， aclGroup 格式与查询时使用的相同
+func (a ACL) CanRead(aclGroup []string) bool {
+	if a.GetPublicReadAccess() {
+		return true
+	}
+	for _, id := range aclGroup {
+		if a.GetReadAccess(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanWrite aclGroup 中的用户或者角色是否有写权限
+func (a ACL) CanWrite(aclGroup []string) bool {
+	if a.GetPublicWriteAccess() {
+		return true
+	}
+	for _, id := range aclGroup {
+		if a.GetWriteAccess(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// Readers 返回有读权限的用户 id 、角色与 * ，按字母顺序排列
+func (a ACL) Readers() []string {
+	readers := []string{}
+	for id, p := range a {
+		if p.Read {
+			readers = append(readers, id)
+		}
+	}
+	sort.Strings(readers)
+	return readers
+}
+
+// Parse 解析 API 格式的 ACL
+func Parse(v interface{}) (ACL, error) {
+	m := utils.M(v)
+	if m == nil {
+		return nil, errs.E(errs.InvalidACL, "ACL must be an object")
+	}
+	a := New()
+	for id, value := range m {
+		if validKey(id) == false {
+			return nil, errs.E(errs.InvalidACL, "invalid ACL key: "+id)
+		}
+		perm := utils.M(value)
+		if perm == nil {
+			return nil, errs.E(errs.InvalidACL, "permission of "+id+" must be an object")
+		}
+		for op, allowed := range perm {
+			if op != "read" && op != "write" {
+				return nil, errs.E(errs.InvalidACL, "invalid permission type: "+op)
+			}
+			if b, ok := allowed.(bool); ok == false || b == false {
+				return nil, errs.E(errs.InvalidACL, "permission value of "+id+" must be true")
+			}
+		}
+		a.set(id, Permission{Read: perm["read"] != nil, Write: perm["write"] != nil})
+	}
+	return a, nil
+}
+
+// validKey ACL 的索引必须为 * 、 role:角色名 或者用户 id
+func validKey(id string) bool {
+	if id == "" {
+		return false
+	}
+	if strings.HasPrefix(id, rolePrefix) {
+		return len(id) > len(rolePrefix)
+	}
+	return true
+}
+
+// ToMap 转换为 API 格式
+func (a ACL) ToMap() types.M {
+	result := types.M{}
+	for id, p := range a {
+		perm := types.M{}
+		if p.Read {
+			perm["read"] = true
+		}
+		if p.Write {
+			perm["write"] = true
+		}
+		result[id] = perm
+	}
+	return result
+}
+
+// FromPerms 根据数据库中的 _rperm 与 _wperm 生成 ACL
+func FromPerms(rperm, wperm interface{}) ACL {
+	a := New()
+	for _, v := range utils.A(rperm) {
+		if id := utils.S(v); id != "" {
+			a.SetReadAccess(id, true)
+		}
+	}
+	for _, v := range utils.A(wperm) {
+		if id := utils.S(v); id != "" {
+			a.SetWriteAccess(id, true)
+		}
+	}
+	return a
+}
+
+// ToPerms 转换为数据库中的 _rperm 与 _wperm ，按字母顺序排列
+func (a ACL) ToPerms() (rperm, wperm types.S) {
+	ids := []string{}
+	for id := range a {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	rperm = types.S{}
+	wperm = types.S{}
+	for _, id := range ids {
+		if a[id].Read {
+			rperm = append(rperm, id)
+		}
+		if a[id].Write {
+			wperm = append(wperm, id)
+		}
+	}
+	return rperm, wperm
+}
@@ -0,0 +1,144 @@
+package acl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_ACL(t *testing.T) {
+	a := New()
+	a.SetUserReadAccess("1024", true)
+	a.SetUserWriteAccess("1024", true)
+	a.SetRoleReadAccess("admin", true)
+	a.SetPublicReadAccess(true)
+	expect := types.M{
+		"1024":       types.M{"read": true, "write": true},
+		"role:admin": types.M{"read": true},
+		"*":          types.M{"read": true},
+	}
+	if result := a.ToMap(); reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*************************************************/
+	if a.GetRoleReadAccess("admin") == false || a.GetRoleWriteAccess("admin") || a.GetPublicWriteAccess() {
+		t.Error("expect:", "role:admin read only", "result:", a)
+	}
+	if a.CanWrite([]string{"2048", "role:admin"}) || a.CanWrite([]string{"1024"}) == false {
+		t.Error("expect:", "1024 can write", "result:", a)
+	}
+	/*************************************************/
+	a.SetPublicReadAccess(false)
+	a.SetUserReadAccess("1024", false)
+	a.SetUserWriteAccess("1024", false)
+	expect = types.M{
+		"role:admin": types.M{"read": true},
+	}
+	if result := a.ToMap(); reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	if a.CanRead([]string{"1024"}) || a.CanRead([]string{"1024", "role:admin"}) == false {
+		t.Error("expect:", "role:admin can read", "result:", a)
+	}
+}
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       interface{}
+		want    ACL
+		wantErr error
+	}{
+		{
+			name:    "1",
+			v:       "hello",
+			want:    nil,
+			wantErr: errs.E(errs.InvalidACL, "ACL must be an object"),
+		},
+		{
+			name:    "2",
+			v:       map[string]interface{}{"role:": map[string]interface{}{"read": true}},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidACL, "invalid ACL key: role:"),
+		},
+		{
+			name:    "3",
+			v:       map[string]interface{}{"*": map[string]interface{}{"read": false}},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidACL, "permission value of * must be true"),
+		},
+		{
+			name:    "4",
+			v:       map[string]interface{}{"*": map[string]interface{}{"delete": true}},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidACL, "invalid permission type: delete"),
+		},
+		{
+			name: "5",
+			v: map[string]interface{}{
+				"1024": map[string]interface{}{"read": true, "write": true},
+				"*":    map[string]interface{}{"read": true},
+			},
+			want:    ACL{"1024": Permission{Read: true, Write: true}, "*": Permission{Read: true}},
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.v)
+		if reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. Parse() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("%q. Parse() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_Perms(t *testing.T) {
+	a := FromPerms(types.S{"role:admin", "1024", "*"}, types.S{"1024"})
+	expect := ACL{
+		"1024":       Permission{Read: true, Write: true},
+		"role:admin": Permission{Read: true},
+		"*":          Permission{Read: true},
+	}
+	if reflect.DeepEqual(expect, a) == false {
+		t.Error("expect:", expect, "result:", a)
+	}
+	/*************************************************/
+	rperm, wperm := a.ToPerms()
+	if reflect.DeepEqual(types.S{"*", "1024", "role:admin"}, rperm) == false || reflect.DeepEqual(types.S{"1024"}, wperm) == false {
+		t.Error("expect:", "sorted perms", "result:", rperm, wperm)
+	}
+	/*************************************************/
+	readers := a.Readers()
+	if reflect.DeepEqual([]string{"*", "1024", "role:admin"}, readers) == false {
+		t.Error("expect:", "sorted readers", "result:", readers)
+	}
+}
+
+func Test_hasReader(t *testing.T) {
+	p := &principalCache{
+		users: map[string]bool{"1024": true, "2048": false},
+		roles: map[string]bool{"admin": true, "guest": false},
+	}
+	tests := []struct {
+		name string
+		a    ACL
+		want bool
+	}{
+		{name: "1", a: ACL{}, want: false},
+		{name: "2", a: ACL{"1024": Permission{Write: true}}, want: false},
+		{name: "3", a: ACL{"2048": Permission{Read: true}, "role:guest": Permission{Read: true}}, want: false},
+		{name: "4", a: ACL{"2048": Permission{Read: true}, "role:admin": Permission{Read: true}}, want: true},
+		{name: "5", a: ACL{"1024": Permission{Read: true}}, want: true},
+		{name: "6", a: ACL{"*": Permission{Read: true}}, want: true},
+	}
+	for _, tt := range tests {
+		if got := p.hasReader(tt.a); got != tt.want {
+			t.Errorf("%q. hasReader() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
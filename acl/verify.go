@@ -0,0 +1,150 @@
+package acl
+
+import (
+	"strings"
+
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// verifyBatchSize 每次从数据库中读取的对象数量
+const verifyBatchSize = 100
+
+// Report ACL 检查结果
+type Report struct {
+	ClassName string   // 检查的类
+	Scanned   int      // 检查的对象数量
+	Orphaned  []string // 除 Master Key 外无人可读的对象 id
+}
+
+// FindOrphaned 检查类中除 Master Key 外无人可读的对象
+// ACL 中没有任何读权限，或者有读权限的用户与角色均已不存在时，视为无人可读
+// 没有设置 ACL 的对象所有人可读，不在检查范围内
+func FindOrphaned(className string) (*Report, error) {
+	report := &Report{ClassName: className, Orphaned: []string{}}
+	principals := &principalCache{users: map[string]bool{}, roles: map[string]bool{}}
+
+	lastID := ""
+	for {
+		query := types.M{}
+		if lastID != "" {
+			query = types.M{"objectId": types.M{"$gt": lastID}}
+		}
+		results, err := orm.TalismanDBController.Find(className, query, types.M{
+			"limit": verifyBatchSize,
+			"sort":  []string{"objectId"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(results) == 0 {
+			return report, nil
+		}
+
+		acls := map[string]ACL{}
+		for _, v := range results {
+			object := utils.M(v)
+			if object == nil {
+				continue
+			}
+			report.Scanned++
+			if _, ok := object["ACL"]; ok == false {
+				continue
+			}
+			objectID := utils.S(object["objectId"])
+			a, err := Parse(object["ACL"])
+			if err != nil {
+				// 格式错误的 ACL 同样无法被正常读取
+				report.Orphaned = append(report.Orphaned, objectID)
+				continue
+			}
+			acls[objectID] = a
+		}
+
+		err = principals.load(acls)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range results {
+			objectID := utils.S(utils.M(v)["objectId"])
+			if a, ok := acls[objectID]; ok && principals.hasReader(a) == false {
+				report.Orphaned = append(report.Orphaned, objectID)
+			}
+		}
+
+		if len(results) < verifyBatchSize {
+			return report, nil
+		}
+		lastID = utils.S(utils.M(results[len(results)-1])["objectId"])
+		if lastID == "" {
+			return report, nil
+		}
+	}
+}
+
+// principalCache 缓存用户与角色是否存在
+type principalCache struct {
+	users map[string]bool
+	roles map[string]bool
+}
+
+// load 查询 ACL 中尚未缓存的用户与角色是否存在
+func (p *principalCache) load(acls map[string]ACL) error {
+	userIDs := types.S{}
+	roleNames := types.S{}
+	for _, a := range acls {
+		for _, id := range a.Readers() {
+			if id == PublicKey {
+				continue
+			}
+			if strings.HasPrefix(id, rolePrefix) {
+				name := strings.TrimPrefix(id, rolePrefix)
+				if _, ok := p.roles[name]; ok == false {
+					p.roles[name] = false
+					roleNames = append(roleNames, name)
+				}
+			} else if _, ok := p.users[id]; ok == false {
+				p.users[id] = false
+				userIDs = append(userIDs, id)
+			}
+		}
+	}
+
+	if len(userIDs) > 0 {
+		results, err := orm.TalismanDBController.Find("_User", types.M{"objectId": types.M{"$in": userIDs}}, types.M{})
+		if err != nil {
+			return err
+		}
+		for _, v := range results {
+			p.users[utils.S(utils.M(v)["objectId"])] = true
+		}
+	}
+	if len(roleNames) > 0 {
+		results, err := orm.TalismanDBController.Find("_Role", types.M{"name": types.M{"$in": roleNames}}, types.M{})
+		if err != nil {
+			return err
+		}
+		for _, v := range results {
+			p.roles[utils.S(utils.M(v)["name"])] = true
+		}
+	}
+	return nil
+}
+
+// hasReader ACL 中是否存在有效的读权限
+func (p *principalCache) hasReader(a ACL) bool {
+	for _, id := range a.Readers() {
+		if id == PublicKey {
+			return true
+		}
+		if strings.HasPrefix(id, rolePrefix) {
+			if p.roles[strings.TrimPrefix(id, rolePrefix)] {
+				return true
+			}
+		} else if p.users[id] {
+			return true
+		}
+	}
+	return false
+}
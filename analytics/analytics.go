@@ -8,9 +8,19 @@ import (
 var adapter analyticsAdapter
 
 func init() {
-	if config.TConfig.AnalyticsAdapter == "InfluxDB" {
+	Init()
+}
+
+// Init 根据当前配置创建分析模块
+func Init() {
+	switch config.TConfig.AnalyticsAdapter {
+	case "InfluxDB":
 		adapter = newInfluxDBAdapter()
-	} else {
+	case "Database":
+		adapter = newDatabaseAnalyticsAdapter()
+	case "Webhook":
+		adapter = newWebhookAnalyticsAdapter()
+	default:
 		adapter = &nullAnalyticsAdapter{}
 	}
 }
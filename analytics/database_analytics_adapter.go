@@ -0,0 +1,63 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// databaseAnalyticsAdapter 将统计事件保存到数据库中
+type databaseAnalyticsAdapter struct {
+	className string
+}
+
+func newDatabaseAnalyticsAdapter() *databaseAnalyticsAdapter {
+	return &databaseAnalyticsAdapter{
+		className: config.TConfig.AnalyticsClassName,
+	}
+}
+
+func (a *databaseAnalyticsAdapter) appOpened(body types.M) (types.M, error) {
+	err := a.addEvent("AppOpened", body)
+	return types.M{}, err
+}
+
+func (a *databaseAnalyticsAdapter) trackEvent(eventName string, body types.M) (types.M, error) {
+	err := a.addEvent(eventName, body)
+	return types.M{}, err
+}
+
+func (a *databaseAnalyticsAdapter) addEvent(name string, event types.M) error {
+	now := utils.TimetoString(time.Now().UTC())
+	object := types.M{
+		"objectId":  utils.CreateObjectID(),
+		"name":      name,
+		"at":        types.M{"__type": "Date", "iso": eventTime(event)},
+		"createdAt": now,
+		"updatedAt": now,
+		// 仅允许 Master 访问
+		"ACL": types.M{},
+	}
+	if dimensions := utils.M(event["dimensions"]); dimensions != nil {
+		object["dimensions"] = dimensions
+	}
+	if tags := utils.M(event["tags"]); tags != nil {
+		object["tags"] = tags
+	}
+	return orm.TalismanDBController.Create(a.className, object, types.M{})
+}
+
+// eventTime 取出事件中的发生时间，不存在时使用当前时间
+func eventTime(event types.M) string {
+	if atM := utils.M(event["at"]); atM != nil {
+		if iso := utils.S(atM["iso"]); iso != "" {
+			if t, err := utils.StringtoTime(iso); err == nil {
+				return utils.TimetoString(t)
+			}
+		}
+	}
+	return utils.TimetoString(time.Now().UTC())
+}
@@ -0,0 +1,68 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// webhookAnalyticsAdapter 将统计事件以 JSON 格式 POST 到指定地址
+type webhookAnalyticsAdapter struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookAnalyticsAdapter() *webhookAnalyticsAdapter {
+	return &webhookAnalyticsAdapter{
+		url:    config.TConfig.AnalyticsWebhookURL,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (a *webhookAnalyticsAdapter) appOpened(body types.M) (types.M, error) {
+	err := a.addEvent("AppOpened", body)
+	return types.M{}, err
+}
+
+func (a *webhookAnalyticsAdapter) trackEvent(eventName string, body types.M) (types.M, error) {
+	err := a.addEvent(eventName, body)
+	return types.M{}, err
+}
+
+func (a *webhookAnalyticsAdapter) addEvent(name string, event types.M) error {
+	payload := types.M{
+		"name": name,
+		"at":   types.M{"__type": "Date", "iso": eventTime(event)},
+	}
+	if dimensions := utils.M(event["dimensions"]); dimensions != nil {
+		payload["dimensions"] = dimensions
+	}
+	if tags := utils.M(event["tags"]); tags != nil {
+		payload["tags"] = tags
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", a.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := a.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return errs.E(errs.WebhookError, "analytics webhook returned status "+strconv.Itoa(response.StatusCode))
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package analytics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_webhookAnalyticsAdapter(t *testing.T) {
+	var received types.M
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		if received["name"] == "fail" {
+			w.WriteHeader(500)
+		}
+	}))
+	defer server.Close()
+	a := &webhookAnalyticsAdapter{url: server.URL, client: http.DefaultClient}
+	/*************************************************/
+	_, err := a.trackEvent("open", types.M{
+		"dimensions": types.M{"key": "hello"},
+		"at":         types.M{"__type": "Date", "iso": "2006-01-02T15:04:05.000Z"},
+	})
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	expect := types.M{
+		"name":       "open",
+		"dimensions": map[string]interface{}{"key": "hello"},
+		"at":         map[string]interface{}{"__type": "Date", "iso": "2006-01-02T15:04:05.000Z"},
+	}
+	if reflect.DeepEqual(expect, received) == false {
+		t.Error("expect:", expect, "result:", received)
+	}
+	/*************************************************/
+	_, err = a.trackEvent("fail", types.M{})
+	if err == nil {
+		t.Error("expect:", "error", "result:", nil)
+	}
+}
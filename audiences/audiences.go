@@ -0,0 +1,103 @@
+// Package audiences 推送受众，保存在 _Audience 表中
+// 受众由名称与 _Installation 的查询条件组成，查询条件以 JSON 字符串的形式保存
+package audiences
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+const audienceClassName = "_Audience"
+
+// NormalizeQuery 把受众的查询条件转换为 JSON 字符串，query 可以是对象或者 JSON 字符串
+func NormalizeQuery(query interface{}) (string, error) {
+	switch q := query.(type) {
+	case string:
+		var where types.M
+		if err := json.Unmarshal([]byte(q), &where); err != nil || where == nil {
+			return "", errs.E(errs.InvalidJSON, "audience query must be a JSON object")
+		}
+		return q, nil
+	}
+	// types.M 与 map[string]interface{} 是不同的类型，统一通过 utils.M 转换
+	if q := utils.M(query); q != nil {
+		b, err := json.Marshal(q)
+		if err != nil {
+			return "", errs.E(errs.InvalidJSON, "audience query must be a JSON object")
+		}
+		return string(b), nil
+	}
+	return "", errs.E(errs.InvalidJSON, "audience query must be a JSON object")
+}
+
+// ValidateAudience 校验将要写入的受众数据，并把 query 转换为字符串
+// create 为 true 时， name 与 query 为必填项
+func ValidateAudience(data types.M, create bool) error {
+	if data == nil {
+		return nil
+	}
+	if name, ok := data["name"]; ok || create {
+		if s, ok := name.(string); ok == false || s == "" {
+			return errs.E(errs.MissingRequiredFieldError, "audience name is required")
+		}
+	}
+	if query, ok := data["query"]; ok || create {
+		q, err := NormalizeQuery(query)
+		if err != nil {
+			return err
+		}
+		data["query"] = q
+	}
+	return nil
+}
+
+// Query 获取受众的查询条件
+func Query(objectID string) (types.M, error) {
+	results, err := orm.TalismanDBController.Find(audienceClassName, types.M{"objectId": objectID}, types.M{})
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, errs.E(errs.ObjectNotFound, "Audience not found.")
+	}
+	var where types.M
+	if err := json.Unmarshal([]byte(utils.S(utils.M(results[0])["query"])), &where); err != nil {
+		return nil, errs.E(errs.InvalidJSON, "audience query must be a JSON object")
+	}
+	return where, nil
+}
+
+// TrackUsage 向受众发送推送之后，更新最后使用时间与使用次数
+func TrackUsage(objectID string) error {
+	update := types.M{
+		"lastUsed":  types.M{"__type": "Date", "iso": utils.TimetoString(time.Now().UTC())},
+		"timesUsed": types.M{"__op": "Increment", "amount": 1},
+	}
+	_, err := orm.TalismanDBController.Update(audienceClassName, types.M{"objectId": objectID}, update, types.M{}, false)
+	return err
+}
+
+// EstimateSize 估算受众包含的设备数量
+// 查询条件为空时使用数据库的统计信息，否则执行 count
+func EstimateSize(objectID string) (int, error) {
+	where, err := Query(objectID)
+	if err != nil {
+		return 0, err
+	}
+	results, err := orm.TalismanDBController.Find("_Installation", where, types.M{"count": true, "estimate": true})
+	if err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		return 0, nil
+	}
+	if count, ok := results[0].(int); ok {
+		return count, nil
+	}
+	return 0, nil
+}
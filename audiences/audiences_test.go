@@ -0,0 +1,64 @@
+package audiences
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_ValidateAudience(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    types.M
+		create  bool
+		want    types.M
+		wantErr error
+	}{
+		{
+			name:    "1",
+			data:    types.M{"name": "all", "query": types.M{"deviceType": "ios"}},
+			create:  true,
+			want:    types.M{"name": "all", "query": `{"deviceType":"ios"}`},
+			wantErr: nil,
+		},
+		{
+			name:    "2",
+			data:    types.M{"name": "all", "query": `{"deviceType":"ios"}`},
+			create:  true,
+			want:    types.M{"name": "all", "query": `{"deviceType":"ios"}`},
+			wantErr: nil,
+		},
+		{
+			name:    "3",
+			data:    types.M{"query": types.M{}},
+			create:  true,
+			want:    types.M{"query": types.M{}},
+			wantErr: errs.E(errs.MissingRequiredFieldError, "audience name is required"),
+		},
+		{
+			name:    "4",
+			data:    types.M{"name": "all", "query": "[1]"},
+			create:  true,
+			want:    types.M{"name": "all", "query": "[1]"},
+			wantErr: errs.E(errs.InvalidJSON, "audience query must be a JSON object"),
+		},
+		{
+			name:    "5",
+			data:    types.M{"timesUsed": 1},
+			create:  false,
+			want:    types.M{"timesUsed": 1},
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		err := ValidateAudience(tt.data, tt.create)
+		if reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. ValidateAudience() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if reflect.DeepEqual(tt.data, tt.want) == false {
+			t.Errorf("%q. ValidateAudience() = %v, want %v", tt.name, tt.data, tt.want)
+		}
+	}
+}
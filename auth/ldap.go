@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/logger"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+	"gopkg.in/ldap.v2"
+)
+
+// ldapAuth LDAP/Active Directory 用户名密码登录， authData 格式为 {"id":"username","password":"xxx"}
+// 先使用服务账号按 LDAPUserFilter 查找用户，再使用用户的 DN 与密码绑定
+// 校验成功后在 authData 中补充 dn email groups ，用于创建用户与同步角色，保存时只保留 id 与 dn
+type ldapAuth struct{}
+
+func (a ldapAuth) ValidateAuthData(authData types.M, options types.M) error {
+	if config.TConfig.LDAPAuth == false {
+		return errs.E(errs.UnsupportedService, "This authentication method is unsupported.")
+	}
+	username := strings.ToLower(strings.TrimSpace(utils.S(authData["id"])))
+	password := utils.S(authData["password"])
+	// 密码为空时 LDAP 服务器会当作匿名绑定并返回成功，必须拒绝
+	if username == "" || password == "" {
+		return errs.E(errs.ObjectNotFound, "LDAP auth is invalid for this user.")
+	}
+
+	conn, err := dialLDAP()
+	if err != nil {
+		logger.Error("connect to LDAP server failed:", err)
+		return errs.E(errs.ObjectNotFound, "Failed to validate this user with LDAP.")
+	}
+	defer conn.Close()
+
+	entry, err := searchLDAPUser(conn, username)
+	if err != nil {
+		return err
+	}
+	if err := conn.Bind(entry.DN, password); err != nil {
+		return errs.E(errs.ObjectNotFound, "LDAP auth is invalid for this user.")
+	}
+	groups, err := searchLDAPGroups(conn, entry)
+	if err != nil {
+		logger.Error("search LDAP groups failed:", err)
+		return errs.E(errs.ObjectNotFound, "Failed to validate this user with LDAP.")
+	}
+
+	authData["id"] = username
+	authData["dn"] = entry.DN
+	authData["email"] = entry.GetAttributeValue(config.TConfig.LDAPEmailAttribute)
+	authData["groups"] = groups
+	return nil
+}
+
+// StoredAuthData 不保存密码与用户组
+func (a ldapAuth) StoredAuthData(authData types.M) types.M {
+	return types.M{
+		"id": authData["id"],
+		"dn": authData["dn"],
+	}
+}
+
+// dialLDAP 连接 LDAPURL ，使用服务账号绑定
+func dialLDAP() (*ldap.Conn, error) {
+	u, err := url.Parse(config.TConfig.LDAPURL)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Hostname()
+	address := u.Host
+	var conn *ldap.Conn
+	if u.Scheme == "ldaps" {
+		if u.Port() == "" {
+			address = net.JoinHostPort(host, "636")
+		}
+		conn, err = ldap.DialTLS("tcp", address, &tls.Config{ServerName: host})
+	} else {
+		if u.Port() == "" {
+			address = net.JoinHostPort(host, "389")
+		}
+		conn, err = ldap.Dial("tcp", address)
+		if err == nil && config.TConfig.LDAPStartTLS {
+			if err = conn.StartTLS(&tls.Config{ServerName: host}); err != nil {
+				conn.Close()
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if config.TConfig.LDAPBindDN != "" {
+		if err := conn.Bind(config.TConfig.LDAPBindDN, config.TConfig.LDAPBindPassword); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// searchLDAPUser 查找用户名对应的唯一用户
+func searchLDAPUser(conn *ldap.Conn, username string) (*ldap.Entry, error) {
+	request := ldap.NewSearchRequest(
+		config.TConfig.LDAPBaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 10, false,
+		fmt.Sprintf(config.TConfig.LDAPUserFilter, ldap.EscapeFilter(username)),
+		[]string{"dn", config.TConfig.LDAPEmailAttribute, "memberOf"},
+		nil,
+	)
+	result, err := conn.Search(request)
+	if err != nil {
+		logger.Error("search LDAP user failed:", err)
+		return nil, errs.E(errs.ObjectNotFound, "LDAP auth is invalid for this user.")
+	}
+	if len(result.Entries) != 1 {
+		return nil, errs.E(errs.ObjectNotFound, "LDAP auth is invalid for this user.")
+	}
+	return result.Entries[0], nil
+}
+
+// searchLDAPGroups 获取用户所属的用户组名称，未设置 LDAPGroupBaseDN 时使用 memberOf 属性
+func searchLDAPGroups(conn *ldap.Conn, entry *ldap.Entry) ([]string, error) {
+	if config.TConfig.LDAPGroupBaseDN == "" {
+		groups := []string{}
+		for _, dn := range entry.GetAttributeValues("memberOf") {
+			if name := groupNameFromDN(dn); name != "" {
+				groups = append(groups, name)
+			}
+		}
+		return groups, nil
+	}
+	request := ldap.NewSearchRequest(
+		config.TConfig.LDAPGroupBaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 10, false,
+		fmt.Sprintf(config.TConfig.LDAPGroupFilter, ldap.EscapeFilter(entry.DN)),
+		[]string{"cn"},
+		nil,
+	)
+	result, err := conn.Search(request)
+	if err != nil {
+		return nil, err
+	}
+	groups := []string{}
+	for _, e := range result.Entries {
+		if name := e.GetAttributeValue("cn"); name != "" {
+			groups = append(groups, name)
+		}
+	}
+	return groups, nil
+}
+
+// groupNameFromDN 取 DN 中第一个 CN 作为用户组名称，如 cn=admins,ou=groups,dc=example,dc=com 返回 admins
+func groupNameFromDN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 {
+		return ""
+	}
+	for _, attr := range parsed.RDNs[0].Attributes {
+		if strings.EqualFold(attr.Type, "cn") {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// LDAPRoles 返回用户组对应的角色名称，以及 LDAPGroupRoles 中配置的全部角色名称
+// 登录时把用户加入 roles ，并从 managed 中其余的角色里移除，未配置的角色不受影响
+func LDAPRoles(groups []string) (roles, managed []string) {
+	roles = []string{}
+	managed = []string{}
+	for _, r := range config.TConfig.LDAPGroupRoles {
+		p := strings.SplitN(r, ":", 2)
+		if len(p) != 2 {
+			continue
+		}
+		managed = appendUnique(managed, p[1])
+		for _, g := range groups {
+			if strings.EqualFold(g, p[0]) {
+				roles = appendUnique(roles, p[1])
+			}
+		}
+	}
+	return roles, managed
+}
+
+func appendUnique(s []string, v string) []string {
+	for _, e := range s {
+		if e == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_ldapAuth_ValidateAuthData(t *testing.T) {
+	config.TConfig = &config.Config{}
+	a := ldapAuth{}
+	expect := errs.E(errs.UnsupportedService, "This authentication method is unsupported.")
+	if err := a.ValidateAuthData(types.M{"id": "joe", "password": "123"}, nil); reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*************************************************/
+	config.TConfig = &config.Config{LDAPAuth: true, LDAPURL: "ldap://127.0.0.1:1", LDAPBaseDN: "dc=example,dc=com"}
+	expect = errs.E(errs.ObjectNotFound, "LDAP auth is invalid for this user.")
+	if err := a.ValidateAuthData(types.M{"id": "joe", "password": ""}, nil); reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*************************************************/
+	stored := a.StoredAuthData(types.M{"id": "joe", "password": "123", "dn": "uid=joe,dc=example,dc=com", "groups": []string{"admins"}})
+	if reflect.DeepEqual(types.M{"id": "joe", "dn": "uid=joe,dc=example,dc=com"}, stored) == false {
+		t.Error("expect:", "id dn", "result:", stored)
+	}
+}
+
+func Test_groupNameFromDN(t *testing.T) {
+	tests := []struct {
+		dn   string
+		want string
+	}{
+		{dn: "cn=admins,ou=groups,dc=example,dc=com", want: "admins"},
+		{dn: "CN=Domain Users,CN=Users,DC=corp,DC=local", want: "Domain Users"},
+		{dn: "ou=groups,dc=example,dc=com", want: ""},
+		{dn: "invalid", want: ""},
+	}
+	for _, tt := range tests {
+		if got := groupNameFromDN(tt.dn); got != tt.want {
+			t.Errorf("%q. groupNameFromDN() = %v, want %v", tt.dn, got, tt.want)
+		}
+	}
+}
+
+func Test_LDAPRoles(t *testing.T) {
+	config.TConfig = &config.Config{LDAPGroupRoles: []string{"admins:Administrator", "staff:Staff", "ops:Staff"}}
+	roles, managed := LDAPRoles([]string{"Admins", "ops", "other"})
+	if reflect.DeepEqual([]string{"Administrator", "Staff"}, roles) == false {
+		t.Error("expect:", []string{"Administrator", "Staff"}, "result:", roles)
+	}
+	if reflect.DeepEqual([]string{"Administrator", "Staff"}, managed) == false {
+		t.Error("expect:", []string{"Administrator", "Staff"}, "result:", managed)
+	}
+	roles, _ = LDAPRoles(nil)
+	if len(roles) != 0 {
+		t.Error("expect:", 0, "result:", roles)
+	}
+}
@@ -32,6 +32,9 @@ func init() {
 		"douban":         douban{},
 		"yixin":          yixin{},
 		"youdao":         youdao{},
+		"phone":          phone{},
+		"ldap":           ldapAuth{},
+		"sso":            ssoAuth{},
 	}
 	options = map[string]types.M{
 		"facebook": types.M{
@@ -74,3 +77,24 @@ func (a anonymous) ValidateAuthData(authData types.M, option types.M) error {
 type Provider interface {
 	ValidateAuthData(types.M, types.M) error
 }
+
+// OneTimeProvider 使用一次性凭证的登录方式，如手机验证码，每次登录都需要校验，凭证不保存到数据库中
+type OneTimeProvider interface {
+	Provider
+	// StoredAuthData 返回需要保存的登录数据
+	StoredAuthData(types.M) types.M
+}
+
+// IsOneTime 判断登录方式是否使用一次性凭证
+func IsOneTime(provider string) bool {
+	_, ok := providers[provider].(OneTimeProvider)
+	return ok
+}
+
+// StoredAuthData 返回需要保存的登录数据，去掉一次性凭证
+func StoredAuthData(provider string, authData types.M) types.M {
+	if p, ok := providers[provider].(OneTimeProvider); ok {
+		return p.StoredAuthData(authData)
+	}
+	return authData
+}
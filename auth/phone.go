@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/sms"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// phone 手机验证码登录， authData 格式为 {"id":"+8613800138000","code":"123456"}
+// 验证码通过 /phoneVerificationRequest 发送，校验成功后失效
+type phone struct{}
+
+func (a phone) ValidateAuthData(authData types.M, options types.M) error {
+	if config.TConfig.PhoneAuth == false {
+		return errs.E(errs.UnsupportedService, "This authentication method is unsupported.")
+	}
+	return sms.VerifyCode(utils.S(authData["id"]), utils.S(authData["code"]))
+}
+
+// StoredAuthData 只保存格式化之后的手机号
+func (a phone) StoredAuthData(authData types.M) types.M {
+	return types.M{"id": sms.NormalizePhone(utils.S(authData["id"]))}
+}
@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"github.com/okobsamoht/talisman/sso"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// ssoAuth OIDC 单点登录， authData 格式为 {"id":"服务商名称:sub","token":"xxx"}
+// token 由服务端在 /sso/名称/callback 中校验 id_token 之后签发，客户端无法直接使用该方式登录
+type ssoAuth struct{}
+
+func (a ssoAuth) ValidateAuthData(authData types.M, options types.M) error {
+	return sso.VerifyAssertion(utils.S(authData["token"]), utils.S(authData["id"]))
+}
+
+// StoredAuthData 不保存 token
+func (a ssoAuth) StoredAuthData(authData types.M) types.M {
+	return types.M{"id": authData["id"]}
+}
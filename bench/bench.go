@@ -0,0 +1,91 @@
+// Package bench 性能测试工具，包含基准测试使用的数据，以及录制与回放 REST 请求的压测工具
+// 适配器与数据库操作的基准测试分别位于对应包的 bench_test.go 中，使用 go test -bench . 运行
+package bench
+
+import (
+	"strconv"
+
+	"github.com/okobsamoht/talisman/types"
+)
+
+// ClassName 基准测试使用的类名
+const ClassName = "BenchPost"
+
+// Schema 基准测试使用的类定义
+func Schema() types.M {
+	return types.M{
+		"className": ClassName,
+		"fields": types.M{
+			"objectId":  types.M{"type": "String"},
+			"createdAt": types.M{"type": "Date"},
+			"updatedAt": types.M{"type": "Date"},
+			"ACL":       types.M{"type": "ACL"},
+			"title":     types.M{"type": "String"},
+			"score":     types.M{"type": "Number"},
+			"published": types.M{"type": "Boolean"},
+			"tags":      types.M{"type": "Array"},
+			"meta":      types.M{"type": "Object"},
+			"author":    types.M{"type": "Pointer", "targetClass": "_User"},
+			"location":  types.M{"type": "GeoPoint"},
+		},
+	}
+}
+
+// Where 常见的查询条件，包含比较、数组、指针、正则与 $or 条件
+func Where() types.M {
+	return types.M{
+		"score":     types.M{"$gte": 10, "$lt": 100},
+		"published": true,
+		"tags":      types.M{"$all": types.S{"go", "parse"}},
+		"author":    types.M{"__type": "Pointer", "className": "_User", "objectId": "1024"},
+		"title":     types.M{"$regex": "^hello"},
+		"$or": types.S{
+			types.M{"meta.lang": "zh"},
+			types.M{"meta.lang": "en"},
+		},
+	}
+}
+
+// WhereWithACL 在 Where 的基础上添加读权限条件，权限标识数量为 n
+func WhereWithACL(n int) types.M {
+	where := Where()
+	in := types.S{nil}
+	for _, id := range ACL(n) {
+		in = append(in, id)
+	}
+	where["_rperm"] = types.M{"$in": in}
+	return where
+}
+
+// ACL 返回 n 个权限标识，格式与 DBController.Find 的 acl 参数相同，
+// 依次为 * 、用户 id 与 role:角色名 ，用于模拟属于多个角色的用户
+func ACL(n int) []string {
+	acl := []string{}
+	if n > 0 {
+		acl = append(acl, "*")
+	}
+	if n > 1 {
+		acl = append(acl, "1024")
+	}
+	for i := 2; i < n; i++ {
+		acl = append(acl, "role:role"+strconv.Itoa(i))
+	}
+	return acl
+}
+
+// Object 返回第 i 个待创建的对象，读权限包含 ACL 中的第 i 个角色
+func Object(i int) types.M {
+	return types.M{
+		"title":     "hello " + strconv.Itoa(i),
+		"score":     i % 200,
+		"published": i%2 == 0,
+		"tags":      types.S{"go", "parse", "tag" + strconv.Itoa(i%10)},
+		"meta":      types.M{"lang": "zh", "views": i},
+		"author":    types.M{"__type": "Pointer", "className": "_User", "objectId": "1024"},
+		"location":  types.M{"__type": "GeoPoint", "latitude": 30.0, "longitude": 120.0},
+		"ACL": types.M{
+			"role:role" + strconv.Itoa(i%50): types.M{"read": true},
+			"1024":                           types.M{"read": true, "write": true},
+		},
+	}
+}
@@ -0,0 +1,67 @@
+package bench
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/storage/mongo"
+	"github.com/okobsamoht/talisman/test"
+	"github.com/okobsamoht/talisman/types"
+)
+
+// 以下基准测试需要连接 test.MongoDBTestURL 中的数据库
+
+func initEnv() {
+	orm.InitOrm(mongo.NewMongoAdapter("talisman", test.OpenMongoDBForTest()))
+}
+
+// seed 创建 n 个对象
+func seed(b *testing.B, n int) {
+	orm.TalismanDBController.DeleteEverything()
+	for i := 0; i < n; i++ {
+		err := orm.TalismanDBController.Create(ClassName, Object(i), nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkFind(b *testing.B, aclSize int) {
+	initEnv()
+	seed(b, 1000)
+	defer orm.TalismanDBController.DeleteEverything()
+	options := types.M{"acl": ACL(aclSize), "limit": 100}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := orm.TalismanDBController.Find(ClassName, types.M{"score": types.M{"$gte": 10}}, options)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFind_ACL1(b *testing.B)   { benchmarkFind(b, 1) }
+func BenchmarkFind_ACL10(b *testing.B)  { benchmarkFind(b, 10) }
+func BenchmarkFind_ACL100(b *testing.B) { benchmarkFind(b, 100) }
+func BenchmarkFind_ACL500(b *testing.B) { benchmarkFind(b, 500) }
+
+func benchmarkCreate(b *testing.B, batch int) {
+	initEnv()
+	orm.TalismanDBController.DeleteEverything()
+	defer orm.TalismanDBController.DeleteEverything()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < batch; j++ {
+			object := Object(j)
+			object["objectId"] = strconv.Itoa(i) + "_" + strconv.Itoa(j)
+			err := orm.TalismanDBController.Create(ClassName, object, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkCreate_1(b *testing.B)   { benchmarkCreate(b, 1) }
+func BenchmarkCreate_100(b *testing.B) { benchmarkCreate(b, 100) }
@@ -0,0 +1,210 @@
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record 录制的一条请求，保存为一行 JSON
+type Record struct {
+	Method string            `json:"method"`
+	Path   string            `json:"path"`   // 请求路径与查询参数，如 /v1/classes/Post?limit=10
+	Header map[string]string `json:"header"` // 仅保存 X-Parse-* 与 Content-Type
+	Body   string            `json:"body,omitempty"`
+	Offset int64             `json:"offset"` // 相对于第一条请求的时间，单位为毫秒
+}
+
+// recorder 录制经过的请求
+type recorder struct {
+	handler http.Handler
+	mu      sync.Mutex
+	w       io.Writer
+	start   time.Time
+}
+
+// NewRecorder 返回录制请求的 http.Handler ，请求以 JSON 行的格式写入 w ，再交给 h 处理
+// 可以包裹 server.New 返回的 Server 。录制的请求头中包含 Session Token 与 Key ，注意保管录制文件
+func NewRecorder(h http.Handler, w io.Writer) http.Handler {
+	return &recorder{handler: h, w: w}
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	record := &Record{
+		Method: req.Method,
+		Path:   req.URL.RequestURI(),
+		Header: map[string]string{},
+		Body:   string(body),
+	}
+	for k := range req.Header {
+		if strings.HasPrefix(k, "X-Parse-") || k == "Content-Type" {
+			record.Header[k] = req.Header.Get(k)
+		}
+	}
+
+	r.mu.Lock()
+	now := time.Now()
+	if r.start.IsZero() {
+		r.start = now
+	}
+	record.Offset = int64(now.Sub(r.start) / time.Millisecond)
+	if b, err := json.Marshal(record); err == nil {
+		r.w.Write(append(b, '\n'))
+	}
+	r.mu.Unlock()
+
+	r.handler.ServeHTTP(w, req)
+}
+
+// ReadRecords 读取录制的请求，忽略空行
+func ReadRecords(r io.Reader) ([]*Record, error) {
+	records := []*Record{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		record := &Record{}
+		err := json.Unmarshal(line, record)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// Replayer 回放录制的请求
+type Replayer struct {
+	BaseURL     string       // 服务地址，不包含路径，如 http://127.0.0.1:8080
+	Concurrency int          // 并发数，默认为 1
+	Speed       float64      // 回放速度，按录制时的时间间隔乘以 1/Speed 发送，为 0 时不等待，尽快发送
+	Client      *http.Client // 默认为 http.DefaultClient
+}
+
+// Result 回放结果
+type Result struct {
+	Total     int
+	Errors    int // 请求失败或者返回码不是 2xx 的数量
+	Duration  time.Duration
+	latencies []time.Duration
+}
+
+// Run 回放所有请求，返回统计结果
+func (p *Replayer) Run(records []*Record) *Result {
+	concurrency := p.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	result := &Result{Total: len(records)}
+	var mu sync.Mutex
+	queue := make(chan *Record)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for record := range queue {
+				latency, err := p.send(client, record)
+				mu.Lock()
+				if err != nil {
+					result.Errors++
+				} else {
+					result.latencies = append(result.latencies, latency)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	start := time.Now()
+	for _, record := range records {
+		if p.Speed > 0 {
+			at := time.Duration(float64(record.Offset)/p.Speed) * time.Millisecond
+			if wait := at - time.Since(start); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		queue <- record
+	}
+	close(queue)
+	wg.Wait()
+	result.Duration = time.Since(start)
+	sort.Sort(durations(result.latencies))
+	return result
+}
+
+// send 发送一条请求，返回码不是 2xx 时返回错误
+func (p *Replayer) send(client *http.Client, record *Record) (time.Duration, error) {
+	var body io.Reader
+	if record.Body != "" {
+		body = strings.NewReader(record.Body)
+	}
+	req, err := http.NewRequest(record.Method, strings.TrimSuffix(p.BaseURL, "/")+record.Path, body)
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range record.Header {
+		req.Header.Set(k, v)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	latency := time.Since(start)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return latency, fmt.Errorf("%s %s: %s", record.Method, record.Path, resp.Status)
+	}
+	return latency, nil
+}
+
+// Percentile 返回成功请求耗时的百分位数， p 取值 0 到 100
+func (r *Result) Percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(r.latencies)-1) * p / 100)
+	return r.latencies[i]
+}
+
+// String 输出统计结果
+func (r *Result) String() string {
+	rps := 0.0
+	if r.Duration > 0 {
+		rps = float64(r.Total) / r.Duration.Seconds()
+	}
+	return fmt.Sprintf("requests: %d, errors: %d, duration: %s, rps: %.1f, p50: %s, p90: %s, p99: %s, max: %s",
+		r.Total, r.Errors, r.Duration, rps, r.Percentile(50), r.Percentile(90), r.Percentile(99), r.Percentile(100))
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
@@ -0,0 +1,78 @@
+package bench
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_Recorder(t *testing.T) {
+	var buf bytes.Buffer
+	var received string
+	h := NewRecorder(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		received = string(b)
+	}), &buf)
+
+	req := httptest.NewRequest("POST", "/v1/classes/Post?limit=1", strings.NewReader(`{"title":"hello"}`))
+	req.Header.Set("X-Parse-Application-Id", "test")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bench")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if received != `{"title":"hello"}` {
+		t.Error("expect:", `{"title":"hello"}`, "result:", received)
+	}
+	records, err := ReadRecords(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []*Record{
+		{
+			Method: "POST",
+			Path:   "/v1/classes/Post?limit=1",
+			Header: map[string]string{"X-Parse-Application-Id": "test", "Content-Type": "application/json"},
+			Body:   `{"title":"hello"}`,
+		},
+	}
+	if reflect.DeepEqual(expect, records) == false {
+		t.Error("expect:", expect, "result:", records)
+	}
+}
+
+func Test_Replayer(t *testing.T) {
+	var mu sync.Mutex
+	paths := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path]++
+		mu.Unlock()
+		if r.Header.Get("X-Parse-Application-Id") != "test" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	records := []*Record{
+		{Method: "GET", Path: "/v1/classes/Post", Header: map[string]string{"X-Parse-Application-Id": "test"}},
+		{Method: "GET", Path: "/v1/classes/Post", Header: map[string]string{"X-Parse-Application-Id": "test"}, Offset: 1},
+		{Method: "POST", Path: "/v1/classes/Post", Header: map[string]string{"X-Parse-Application-Id": "test"}, Body: `{}`, Offset: 2},
+		{Method: "GET", Path: "/v1/users/me", Offset: 3},
+	}
+	p := &Replayer{BaseURL: server.URL, Concurrency: 2, Speed: 1}
+	result := p.Run(records)
+	if result.Total != 4 || result.Errors != 1 {
+		t.Error("expect:", "4 requests, 1 error", "result:", result)
+	}
+	if paths["/v1/classes/Post"] != 3 || paths["/v1/users/me"] != 1 {
+		t.Error("expect:", "3 /v1/classes/Post, 1 /v1/users/me", "result:", paths)
+	}
+	if result.Percentile(50) <= 0 || result.Percentile(50) > result.Percentile(100) {
+		t.Error("expect:", "valid percentiles", "result:", result)
+	}
+}
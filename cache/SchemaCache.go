@@ -2,6 +2,7 @@ package cache
 
 import (
 	"sync"
+	"time"
 
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
@@ -12,10 +13,41 @@ const schemaCachePrefix = "__SCHEMA"
 const allKeys = "__ALL_KEYS"
 
 // SchemaCache ...
+// mu 保护 classTTLs maxEntries classes ， loadMu 保护 call stale staleAt generation 与 stats
 type SchemaCache struct {
 	ttl    int
 	prefix string
 	mu     sync.Mutex
+
+	classTTLs  map[string]int // 单个类的缓存有效期，覆盖 ttl
+	keysTTL    int            // 记录所有 key 的有效期，取 ttl 与 classTTLs 中的最大值
+	maxEntries int            // 单独缓存的类的最大数量， 0 表示不限制
+	classes    []string       // 单独缓存的类，按写入顺序排列
+
+	staleTTL   time.Duration
+	loadMu     sync.Mutex
+	call       *schemaCall
+	stale      []types.M
+	staleAt    time.Time // stale 的加载时间
+	generation int       // 每次 Clear 时加一，加载期间被清除的结果不再写入缓存
+	stats      SchemaCacheStats
+}
+
+// schemaCall 正在进行的加载，其他请求等待 wg 后共享结果
+type schemaCall struct {
+	wg         sync.WaitGroup
+	val        []types.M
+	err        error
+	generation int
+}
+
+// SchemaCacheStats Schema 缓存的加载统计
+type SchemaCacheStats struct {
+	Reloads      int       // 从数据库加载的次数
+	Coalesced    int       // 等待其他请求加载结果的次数
+	StaleServed  int       // 返回旧数据并在后台重新加载的次数
+	Errors       int       // 加载失败的次数
+	LastReloadAt time.Time // 最后一次加载成功的时间
 }
 
 // NewSchemaCache ...
@@ -29,15 +61,129 @@ func NewSchemaCache(ttl int, singleCache bool) *SchemaCache {
 		prefix = prefix + utils.CreateToken()
 	}
 	return &SchemaCache{
-		ttl:    ttl,
-		prefix: prefix,
+		ttl:       ttl,
+		prefix:    prefix,
+		classTTLs: map[string]int{},
+		keysTTL:   ttl,
 	}
 }
 
+// SetClassTTL 设置单个类的缓存有效期，单位为秒，取值与 NewSchemaCache 的 ttl 相同， -1 表示不缓存该类
+func (s *SchemaCache) SetClassTTL(className string, ttl int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.classTTLs[className] = ttl
+	if ttl > s.keysTTL {
+		s.keysTTL = ttl
+	}
+}
+
+// SetMaxEntries 设置单独缓存的类的最大数量，超过时删除最早写入的类， 0 表示不限制
+func (s *SchemaCache) SetMaxEntries(maxEntries int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxEntries = maxEntries
+}
+
+// classTTL 返回类的缓存有效期
+func (s *SchemaCache) classTTL(className string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ttl, ok := s.classTTLs[className]; ok {
+		return ttl
+	}
+	return s.ttl
+}
+
+// SetStaleTTL 设置缓存失效后继续使用旧数据的时间，单位为秒， 0 表示不使用旧数据
+func (s *SchemaCache) SetStaleTTL(staleTTL int) {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+	s.staleTTL = time.Duration(staleTTL) * time.Second
+}
+
+// Stats 返回加载统计
+func (s *SchemaCache) Stats() SchemaCacheStats {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+	return s.stats
+}
+
+// LoadAllClasses 返回缓存中的所有 Schema ，缓存失效时调用 load 重新加载并写入缓存
+// 同一时间只有一个请求调用 load ，其他请求等待并共享加载结果，避免缓存失效时大量请求同时查询数据库
+// 设置了 staleTTL 时，缓存失效后 staleTTL 时间内直接返回上一次加载的结果，同时在后台重新加载
+func (s *SchemaCache) LoadAllClasses(load func() ([]types.M, error)) ([]types.M, error) {
+	if allClasses := s.GetAllClasses(); len(allClasses) > 0 {
+		return allClasses, nil
+	}
+	if stale := s.getStale(); stale != nil {
+		go s.reload(load)
+		return stale, nil
+	}
+	return s.reload(load)
+}
+
+// getStale 返回仍在 staleTTL 时间内的旧数据，同时计数，不存在时返回 nil
+func (s *SchemaCache) getStale() []types.M {
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+	if s.staleTTL <= 0 || s.ttl < 0 || s.stale == nil {
+		return nil
+	}
+	if time.Since(s.staleAt) >= time.Duration(s.ttl)*time.Second+s.staleTTL {
+		return nil
+	}
+	s.stats.StaleServed++
+	return s.stale
+}
+
+// reload 调用 load 加载 Schema ，已有加载正在进行时等待其结果，清除缓存之前开始的加载不参与合并
+func (s *SchemaCache) reload(load func() ([]types.M, error)) ([]types.M, error) {
+	s.loadMu.Lock()
+	if c := s.call; c != nil && c.generation == s.generation {
+		s.stats.Coalesced++
+		s.loadMu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := &schemaCall{generation: s.generation}
+	c.wg.Add(1)
+	s.call = c
+	s.loadMu.Unlock()
+
+	c.val, c.err = load()
+
+	s.loadMu.Lock()
+	s.stats.Reloads++
+	if c.err != nil {
+		s.stats.Errors++
+	} else {
+		s.stats.LastReloadAt = time.Now()
+		if c.generation == s.generation {
+			s.SetAllClasses(c.val)
+			if s.ttl >= 0 {
+				s.stale = c.val
+				s.staleAt = time.Now()
+			}
+		}
+	}
+	if s.call == c {
+		s.call = nil
+	}
+	s.loadMu.Unlock()
+	c.wg.Done()
+	return c.val, c.err
+}
+
 // Put ...
 func (s *SchemaCache) Put(key string, value interface{}) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.put(key, value, s.ttl)
+}
+
+// put 写入缓存并记录 key ，调用方需要持有 mu
+func (s *SchemaCache) put(key string, value interface{}, ttl int) {
 	var keys map[string]interface{}
 	v := get(s.prefix + allKeys)
 	if v == nil {
@@ -52,8 +198,8 @@ func (s *SchemaCache) Put(key string, value interface{}) {
 	if _, ok := keys[key]; ok == false {
 		keys[key] = true
 	}
-	put(s.prefix+allKeys, keys, int64(s.ttl))
-	put(key, value, int64(s.ttl))
+	put(s.prefix+allKeys, keys, int64(s.keysTTL))
+	put(key, value, int64(ttl))
 }
 
 // GetAllClasses ...
@@ -85,16 +231,33 @@ func (s *SchemaCache) SetAllClasses(schema []types.M) {
 }
 
 // SetOneSchema ...
+// 设置了 maxEntries 时，超过数量后删除最早写入的类
 func (s *SchemaCache) SetOneSchema(className string, schema types.M) {
-	if s.ttl < 0 {
+	ttl := s.classTTL(className)
+	if ttl < 0 {
 		return
 	}
-	s.Put(s.prefix+className, schema)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(s.prefix+className, schema, ttl)
+	for i, name := range s.classes {
+		if name == className {
+			s.classes = append(s.classes[:i], s.classes[i+1:]...)
+			break
+		}
+	}
+	s.classes = append(s.classes, className)
+	if s.maxEntries > 0 && len(s.classes) > s.maxEntries {
+		for _, name := range s.classes[:len(s.classes)-s.maxEntries] {
+			del(s.prefix + name)
+		}
+		s.classes = s.classes[len(s.classes)-s.maxEntries:]
+	}
 }
 
 // GetOneSchema ...
 func (s *SchemaCache) GetOneSchema(className string) types.M {
-	if s.ttl < 0 {
+	if s.classTTL(className) < 0 {
 		return nil
 	}
 	v := get(s.prefix + className)
@@ -130,8 +293,16 @@ func (s *SchemaCache) GetOneSchema(className string) types.M {
 	return nil
 }
 
-// Clear ...
+// Clear 清除缓存与旧数据
 func (s *SchemaCache) Clear() {
+	s.loadMu.Lock()
+	s.stale = nil
+	s.generation++
+	s.loadMu.Unlock()
+	s.mu.Lock()
+	s.classes = nil
+	s.mu.Unlock()
+
 	var keys map[string]interface{}
 	v := get(s.prefix + allKeys)
 	if v == nil {
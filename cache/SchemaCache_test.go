@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_LoadAllClasses(t *testing.T) {
+	s := NewSchemaCache(5, false)
+	schemas := []types.M{types.M{"className": "post"}}
+	var mu sync.Mutex
+	loads := 0
+	release := make(chan struct{})
+	load := func() ([]types.M, error) {
+		mu.Lock()
+		loads++
+		mu.Unlock()
+		<-release
+		return schemas, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([][]types.M, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = s.LoadAllClasses(load)
+		}(i)
+	}
+	// 等待所有请求进入加载或者等待加载
+	for {
+		stats := s.Stats()
+		if stats.Coalesced == 9 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	if loads != 1 {
+		t.Error("expect:", 1, "result:", loads)
+	}
+	for _, r := range results {
+		if reflect.DeepEqual(r, schemas) == false {
+			t.Error("expect:", schemas, "result:", r)
+		}
+	}
+	/*****************************************************************/
+	r, _ := s.LoadAllClasses(load)
+	if loads != 1 || reflect.DeepEqual(r, schemas) == false {
+		t.Error("expect:", 1, schemas, "result:", loads, r)
+	}
+	stats := s.Stats()
+	if stats.Reloads != 1 || stats.Coalesced != 9 || stats.LastReloadAt.IsZero() {
+		t.Error("expect:", "1 reload 9 coalesced", "result:", stats)
+	}
+	s.Clear()
+}
+
+func Test_LoadAllClassesStale(t *testing.T) {
+	s := NewSchemaCache(5, false)
+	s.SetStaleTTL(60)
+	schemas := []types.M{types.M{"className": "post"}}
+	r, err := s.LoadAllClasses(func() ([]types.M, error) { return schemas, nil })
+	if err != nil || reflect.DeepEqual(r, schemas) == false {
+		t.Error("expect:", schemas, "result:", r, err)
+	}
+	/*****************************************************************/
+	// 模拟缓存过期，返回旧数据并在后台重新加载
+	del(s.prefix + mainSchema)
+	done := make(chan struct{})
+	newSchemas := []types.M{types.M{"className": "user"}}
+	r, err = s.LoadAllClasses(func() ([]types.M, error) {
+		defer close(done)
+		return newSchemas, nil
+	})
+	if err != nil || reflect.DeepEqual(r, schemas) == false {
+		t.Error("expect:", schemas, "result:", r, err)
+	}
+	<-done
+	for s.Stats().Reloads != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if r := s.GetAllClasses(); reflect.DeepEqual(r, newSchemas) == false {
+		t.Error("expect:", newSchemas, "result:", r)
+	}
+	if stats := s.Stats(); stats.StaleServed != 1 {
+		t.Error("expect:", 1, "result:", stats.StaleServed)
+	}
+	/*****************************************************************/
+	// 清除缓存后不再使用旧数据
+	s.Clear()
+	expectErr := errors.New("db error")
+	r, err = s.LoadAllClasses(func() ([]types.M, error) { return nil, expectErr })
+	if err != expectErr || r != nil {
+		t.Error("expect:", expectErr, "result:", r, err)
+	}
+	if stats := s.Stats(); stats.Errors != 1 {
+		t.Error("expect:", 1, "result:", stats.Errors)
+	}
+}
+
+func Test_SetOneSchema(t *testing.T) {
+	s := NewSchemaCache(5, false)
+	s.SetMaxEntries(2)
+	s.SetClassTTL("log", -1)
+	s.SetOneSchema("post", types.M{"className": "post"})
+	s.SetOneSchema("user", types.M{"className": "user"})
+	s.SetOneSchema("log", types.M{"className": "log"})
+	if r := s.GetOneSchema("log"); r != nil {
+		t.Error("expect:", nil, "result:", r)
+	}
+	/*****************************************************************/
+	s.SetOneSchema("post", types.M{"className": "post"})
+	s.SetOneSchema("comment", types.M{"className": "comment"})
+	if r := s.GetOneSchema("user"); r != nil {
+		t.Error("expect:", nil, "result:", r)
+	}
+	for _, className := range []string{"post", "comment"} {
+		if r := s.GetOneSchema(className); reflect.DeepEqual(r, types.M{"className": className}) == false {
+			t.Error("expect:", className, "result:", r)
+		}
+	}
+	/*****************************************************************/
+	s.Clear()
+	if r := s.GetOneSchema("post"); r != nil {
+		t.Error("expect:", nil, "result:", r)
+	}
+}
@@ -12,9 +12,23 @@ var Role *SubCache
 // User ...
 var User *SubCache
 
+// Object 按 objectId 缓存的对象
+var Object *SubCache
+
+// LiveQuery 保存从数据库变更生成事件时的读取位置
+var LiveQuery *SubCache
+
+// Phone 保存发送给手机号的登录验证码
+var Phone *SubCache
+
 var adapter Adapter
 
 func init() {
+	Init()
+}
+
+// Init 根据当前配置创建缓存模块，已订阅的通道在新的缓存模块上重新订阅
+func Init() {
 	a := config.TConfig.CacheAdapter
 	if a == "InMemory" {
 		adapter = newInMemoryCacheAdapter(5)
@@ -31,6 +45,16 @@ func init() {
 	User = &SubCache{
 		prefix: "user",
 	}
+	Object = &SubCache{
+		prefix: "object",
+	}
+	LiveQuery = &SubCache{
+		prefix: "livequery",
+	}
+	Phone = &SubCache{
+		prefix: "phone",
+	}
+	resubscribe()
 }
 
 var keySeparatorChar = ":"
@@ -103,4 +127,13 @@ func InitCache() {
 	User = &SubCache{
 		prefix: "user",
 	}
+	Object = &SubCache{
+		prefix: "object",
+	}
+	LiveQuery = &SubCache{
+		prefix: "livequery",
+	}
+	Phone = &SubCache{
+		prefix: "phone",
+	}
 }
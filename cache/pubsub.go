@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"sync"
+
+	"github.com/okobsamoht/talisman/config"
+)
+
+// pubSubAdapter 支持发布订阅的缓存模块，用于在多个实例之间通知缓存失效
+type pubSubAdapter interface {
+	publish(channel, message string)
+	subscribe(channel string, onMessage func(message string))
+}
+
+var (
+	listenersMutex sync.RWMutex
+	listeners      = map[string][]func(message string){}
+)
+
+// Publish 向指定通道发送消息
+// 缓存模块为 Redis 时，消息通过 Redis 发送给所有实例，其他缓存模块只通知当前实例
+func Publish(channel, message string) {
+	if p, ok := adapter.(pubSubAdapter); ok {
+		p.publish(joinKeys(config.TConfig.AppID, "pubsub", channel), message)
+		return
+	}
+	dispatch(channel, message)
+}
+
+// Subscribe 订阅指定通道，当前实例发送的消息也会通知到 handler
+func Subscribe(channel string, handler func(message string)) {
+	listenersMutex.Lock()
+	first := len(listeners[channel]) == 0
+	listeners[channel] = append(listeners[channel], handler)
+	listenersMutex.Unlock()
+
+	if first {
+		if p, ok := adapter.(pubSubAdapter); ok {
+			p.subscribe(joinKeys(config.TConfig.AppID, "pubsub", channel), func(message string) {
+				dispatch(channel, message)
+			})
+		}
+	}
+}
+
+// resubscribe 更换缓存模块后，在新的缓存模块上订阅已有的通道
+func resubscribe() {
+	p, ok := adapter.(pubSubAdapter)
+	if ok == false {
+		return
+	}
+	listenersMutex.RLock()
+	defer listenersMutex.RUnlock()
+	for channel := range listeners {
+		channel := channel
+		p.subscribe(joinKeys(config.TConfig.AppID, "pubsub", channel), func(message string) {
+			dispatch(channel, message)
+		})
+	}
+}
+
+func dispatch(channel, message string) {
+	listenersMutex.RLock()
+	handlers := listeners[channel]
+	listenersMutex.RUnlock()
+	for _, handler := range handlers {
+		handler(message)
+	}
+}
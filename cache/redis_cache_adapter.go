@@ -2,6 +2,7 @@ package cache
 
 import (
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/garyburd/redigo/redis"
@@ -12,6 +13,10 @@ type redisCacheAdapter struct {
 	password string
 	ttl      int
 	p        *redis.Pool
+
+	subMutex sync.Mutex
+	psc      *redis.PubSubConn
+	handlers map[string]func(message string)
 }
 
 const defaultRedisTTL = 30
@@ -97,3 +102,59 @@ func (m *redisCacheAdapter) del(key string) {
 func (m *redisCacheAdapter) clear() {
 	m.do("FLUSHDB")
 }
+
+func (m *redisCacheAdapter) publish(channel, message string) {
+	m.do("PUBLISH", channel, message)
+}
+
+// subscribe 使用单独的连接订阅通道，连接断开后自动重连并重新订阅
+func (m *redisCacheAdapter) subscribe(channel string, onMessage func(message string)) {
+	m.subMutex.Lock()
+	defer m.subMutex.Unlock()
+	if m.handlers == nil {
+		m.handlers = map[string]func(message string){}
+		go m.receive()
+	}
+	m.handlers[channel] = onMessage
+	if m.psc != nil {
+		m.psc.Subscribe(channel)
+	}
+}
+
+func (m *redisCacheAdapter) receive() {
+	for {
+		c, err := m.p.Dial()
+		if err != nil {
+			time.Sleep(time.Second)
+			continue
+		}
+		psc := &redis.PubSubConn{Conn: c}
+		m.subMutex.Lock()
+		m.psc = psc
+		for channel := range m.handlers {
+			psc.Subscribe(channel)
+		}
+		m.subMutex.Unlock()
+
+	receiving:
+		for {
+			switch n := psc.Receive().(type) {
+			case redis.Message:
+				m.subMutex.Lock()
+				handler := m.handlers[n.Channel]
+				m.subMutex.Unlock()
+				if handler != nil {
+					handler(string(n.Data))
+				}
+			case error:
+				break receiving
+			}
+		}
+
+		m.subMutex.Lock()
+		m.psc = nil
+		m.subMutex.Unlock()
+		psc.Close()
+		time.Sleep(time.Second)
+	}
+}
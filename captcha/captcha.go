@@ -0,0 +1,79 @@
+// Package captcha 校验客户端提交的人机验证 token ，用于创建用户与请求重置密码
+// 内置 hCaptcha 与 reCAPTCHA ，其他服务商可以通过 RegisterProvider 注册，之后通过 CaptchaProvider 使用
+package captcha
+
+import (
+	"sync"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/logger"
+)
+
+// Provider 人机验证服务商
+type Provider interface {
+	// Verify 校验客户端提交的 token ， remoteIP 为客户端 IP ，校验未通过时返回 false ，无法完成校验时返回 error
+	Verify(token, remoteIP string) (bool, error)
+}
+
+var (
+	providersMutex sync.RWMutex
+	providers      = map[string]Provider{}
+)
+
+// RegisterProvider 注册自定义的人机验证服务商，与内置服务商同名时替换内置服务商
+func RegisterProvider(name string, p Provider) {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+	providers[name] = p
+}
+
+// getProvider 返回 CaptchaProvider 对应的服务商
+func getProvider(name string) Provider {
+	providersMutex.RLock()
+	p, ok := providers[name]
+	providersMutex.RUnlock()
+	if ok {
+		return p
+	}
+	switch name {
+	case "hCaptcha":
+		return NewSiteVerifyProvider(hCaptchaURL, config.TConfig.CaptchaSecret, 0)
+	case "reCAPTCHA":
+		return NewSiteVerifyProvider(reCAPTCHAURL, config.TConfig.CaptchaSecret, config.TConfig.CaptchaMinScore)
+	}
+	return nil
+}
+
+// Enabled 判断 route 是否需要人机验证， route 可选： signup passwordReset
+func Enabled(route string) bool {
+	if config.TConfig.CaptchaProvider == "" {
+		return false
+	}
+	for _, r := range config.TConfig.CaptchaRoutes {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify 使用 CaptchaProvider 校验 token
+func Verify(token, remoteIP string) error {
+	if token == "" {
+		return errs.E(errs.OperationForbidden, "Captcha token is required.")
+	}
+	p := getProvider(config.TConfig.CaptchaProvider)
+	if p == nil {
+		return errs.E(errs.InternalServerError, "Unsupported captcha provider: "+config.TConfig.CaptchaProvider)
+	}
+	ok, err := p.Verify(token, remoteIP)
+	if err != nil {
+		logger.Error("verify captcha token failed:", err)
+		return errs.E(errs.ConnectionFailed, "Failed to verify captcha token.")
+	}
+	if ok == false {
+		return errs.E(errs.OperationForbidden, "Captcha verification failed.")
+	}
+	return nil
+}
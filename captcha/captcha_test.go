@@ -0,0 +1,107 @@
+package captcha
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+)
+
+func Test_SiteVerifyProvider(t *testing.T) {
+	var received url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		received = r.PostForm
+		switch r.PostForm.Get("response") {
+		case "pass":
+			w.Write([]byte(`{"success":true}`))
+		case "human":
+			w.Write([]byte(`{"success":true,"score":0.9}`))
+		case "bot":
+			w.Write([]byte(`{"success":true,"score":0.1}`))
+		case "error":
+			w.WriteHeader(500)
+		default:
+			w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+		}
+	}))
+	defer server.Close()
+	p := NewSiteVerifyProvider(server.URL, "secret", 0.5)
+	/*************************************************/
+	ok, err := p.Verify("pass", "127.0.0.1")
+	if ok == false || err != nil {
+		t.Error("expect:", true, "result:", ok, err)
+	}
+	expect := url.Values{
+		"secret":   []string{"secret"},
+		"response": []string{"pass"},
+		"remoteip": []string{"127.0.0.1"},
+	}
+	if reflect.DeepEqual(expect, received) == false {
+		t.Error("expect:", expect, "result:", received)
+	}
+	/*************************************************/
+	ok, err = p.Verify("human", "")
+	if ok == false || err != nil {
+		t.Error("expect:", true, "result:", ok, err)
+	}
+	/*************************************************/
+	ok, err = p.Verify("bot", "")
+	if ok || err != nil {
+		t.Error("expect:", false, "result:", ok, err)
+	}
+	/*************************************************/
+	ok, err = p.Verify("wrong", "")
+	if ok || err != nil {
+		t.Error("expect:", false, "result:", ok, err)
+	}
+	/*************************************************/
+	_, err = p.Verify("error", "")
+	if err == nil {
+		t.Error("expect:", "error", "result:", err)
+	}
+}
+
+type testProvider struct{}
+
+func (p testProvider) Verify(token, remoteIP string) (bool, error) {
+	return token == "pass", nil
+}
+
+func Test_Verify(t *testing.T) {
+	defer func(c config.Config) { *config.TConfig = c }(*config.TConfig)
+	RegisterProvider("test", testProvider{})
+	defer delete(providers, "test")
+	config.TConfig.CaptchaProvider = "test"
+	config.TConfig.CaptchaRoutes = []string{"signup"}
+	/*************************************************/
+	if Enabled("signup") == false || Enabled("passwordReset") {
+		t.Error("expect:", "signup enabled", "result:", Enabled("signup"), Enabled("passwordReset"))
+	}
+	/*************************************************/
+	err := Verify("pass", "")
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	/*************************************************/
+	err = Verify("", "")
+	expect := errs.E(errs.OperationForbidden, "Captcha token is required.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*************************************************/
+	err = Verify("wrong", "")
+	expect = errs.E(errs.OperationForbidden, "Captcha verification failed.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*************************************************/
+	config.TConfig.CaptchaProvider = ""
+	if Enabled("signup") {
+		t.Error("expect:", false, "result:", true)
+	}
+}
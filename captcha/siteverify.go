@@ -0,0 +1,65 @@
+package captcha
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	hCaptchaURL  = "https://api.hcaptcha.com/siteverify"
+	reCAPTCHAURL = "https://www.google.com/recaptcha/api/siteverify"
+)
+
+// SiteVerifyProvider 通过 siteverify 接口校验 token ， hCaptcha 与 reCAPTCHA 使用相同的接口格式
+type SiteVerifyProvider struct {
+	url      string
+	secret   string
+	minScore float64
+	client   *http.Client
+}
+
+// NewSiteVerifyProvider minScore 大于 0 时，返回结果中的 score 必须不小于 minScore ，用于 reCAPTCHA v3
+func NewSiteVerifyProvider(verifyURL, secret string, minScore float64) *SiteVerifyProvider {
+	return &SiteVerifyProvider{
+		url:      verifyURL,
+		secret:   secret,
+		minScore: minScore,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Verify 提交 secret response remoteip ，根据返回的 success 与 score 判断是否通过
+func (s *SiteVerifyProvider) Verify(token, remoteIP string) (bool, error) {
+	form := url.Values{}
+	form.Set("secret", s.secret)
+	form.Set("response", token)
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	response, err := s.client.PostForm(s.url, form)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		return false, errors.New(s.url + " returned status " + strconv.Itoa(response.StatusCode))
+	}
+	var result struct {
+		Success bool     `json:"success"`
+		Score   *float64 `json:"score"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return false, err
+	}
+	if result.Success == false {
+		return false, nil
+	}
+	if s.minScore > 0 && result.Score != nil && *result.Score < s.minScore {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,41 @@
+package cloud
+
+import (
+	"sync"
+
+	"github.com/okobsamoht/talisman/types"
+)
+
+// ComputedHandler 计算字段的值，参数为数据库中读取的对象，其中包含注册时声明的依赖字段
+type ComputedHandler func(object types.M) interface{}
+
+// ComputedField 注册的计算函数与其依赖的字段
+type ComputedField struct {
+	Handler   ComputedHandler
+	DependsOn []string
+}
+
+var computedMutex sync.RWMutex
+var computedFields = map[string]*ComputedField{}
+
+// Computed 注册计算函数，在 schema 中定义 {"type":"Computed","function":"name"} 类型的字段后，
+// 读取对象时调用该函数计算字段的值，dependsOn 为计算时需要读取的字段，查询指定了 keys 时会同时读取这些字段
+func Computed(name string, dependsOn []string, handler ComputedHandler) {
+	computedMutex.Lock()
+	defer computedMutex.Unlock()
+	computedFields[name] = &ComputedField{Handler: handler, DependsOn: dependsOn}
+}
+
+// GetComputed 获取计算函数，不存在时返回 nil
+func GetComputed(name string) *ComputedField {
+	computedMutex.RLock()
+	defer computedMutex.RUnlock()
+	return computedFields[name]
+}
+
+// RemoveComputed 删除计算函数
+func RemoveComputed(name string) {
+	computedMutex.Lock()
+	defer computedMutex.Unlock()
+	delete(computedFields, name)
+}
@@ -0,0 +1,91 @@
+package cloud
+
+import (
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+const (
+	// TypeBeforeSaveFile 文件保存前回调
+	TypeBeforeSaveFile = "beforeSaveFile"
+	// TypeAfterSaveFile 文件保存后回调
+	TypeAfterSaveFile = "afterSaveFile"
+	// TypeBeforeDeleteFile 文件删除前回调
+	TypeBeforeDeleteFile = "beforeDeleteFile"
+	// TypeAfterDeleteFile 文件删除后回调
+	TypeAfterDeleteFile = "afterDeleteFile"
+)
+
+// File 触发器中的文件
+// beforeSaveFile 中可以修改 Name Data ContentType Tags ，
+// 设置 URL 表示文件已经由回调保存到其他位置，此时不再保存到文件存储模块
+type File struct {
+	Name        string
+	URL         string
+	Data        []byte
+	ContentType string
+	Tags        []string
+}
+
+// FileTriggerRequest ...
+type FileTriggerRequest struct {
+	TriggerName    string
+	File           *File
+	FileSize       int
+	Master         bool
+	User           types.M
+	InstallationID string
+	RequestID      string
+}
+
+// FileTriggerHandler ...
+type FileTriggerHandler func(FileTriggerRequest, Response)
+
+// FileTriggerResponse ...
+type FileTriggerResponse struct {
+	Request FileTriggerRequest
+	Err     error
+}
+
+// Success ...
+func (f *FileTriggerResponse) Success(response interface{}) {}
+
+// Error ...
+func (f *FileTriggerResponse) Error(code int, message string) {
+	if code == 0 {
+		code = errs.ScriptFailed
+	}
+	f.Err = errs.E(code, message)
+}
+
+var fileTriggers = map[string]FileTriggerHandler{}
+
+// BeforeSaveFile 注册文件保存前回调，可用于检查、重命名或者拒绝上传的文件
+func BeforeSaveFile(handler FileTriggerHandler) {
+	fileTriggers[TypeBeforeSaveFile] = handler
+}
+
+// AfterSaveFile 注册文件保存后回调，可用于同步文件到 CDN
+func AfterSaveFile(handler FileTriggerHandler) {
+	fileTriggers[TypeAfterSaveFile] = handler
+}
+
+// BeforeDeleteFile 注册文件删除前回调，返回错误时不删除文件
+func BeforeDeleteFile(handler FileTriggerHandler) {
+	fileTriggers[TypeBeforeDeleteFile] = handler
+}
+
+// AfterDeleteFile 注册文件删除后回调
+func AfterDeleteFile(handler FileTriggerHandler) {
+	fileTriggers[TypeAfterDeleteFile] = handler
+}
+
+// GetFileTrigger 获取文件回调
+func GetFileTrigger(triggerType string) FileTriggerHandler {
+	return fileTriggers[triggerType]
+}
+
+// RemoveFileTrigger 删除文件回调
+func RemoveFileTrigger(triggerType string) {
+	delete(fileTriggers, triggerType)
+}
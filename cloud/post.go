@@ -2,57 +2,176 @@ package cloud
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/okobsamoht/talisman/config"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
 )
 
-// post 请求网络接口
-// 接口返回格式如下：
-// {
-// 	"success":{},
-// 	"error":{},
-// }
-func post(params types.M, URL string) (r types.M, e types.M) {
-	jsonParams, err := json.Marshal(params)
-	if err != nil {
-		return types.M{}, types.M{"code": -1, "message": "Malformed response"}
-	}
-	request, err := http.NewRequest("POST", URL, bytes.NewBuffer(jsonParams))
+// DeadLetterHandler 云代码请求重试后仍然失败时的处理函数
+type DeadLetterHandler func(URL string, params types.M, err error, attempts int)
+
+var deadLetterHandler DeadLetterHandler
+
+// SetDeadLetterHandler 设置云代码请求最终失败时的处理函数
+func SetDeadLetterHandler(handler DeadLetterHandler) {
+	deadLetterHandler = handler
+}
+
+var (
+	webhookClient     *http.Client
+	webhookClientOnce sync.Once
+)
+
+// getWebhookClient 获取请求云代码使用的 http.Client ，默认校验 HTTPS 证书
+func getWebhookClient() *http.Client {
+	webhookClientOnce.Do(func() {
+		timeout := config.TConfig.WebhookTimeout
+		if timeout <= 0 {
+			timeout = 30
+		}
+		webhookClient = &http.Client{
+			Timeout: time.Duration(timeout) * time.Second,
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{
+					MinVersion:         tls.VersionTLS12,
+					InsecureSkipVerify: config.TConfig.WebhookInsecureSkipVerify,
+				},
+			},
+		}
+	})
+	return webhookClient
+}
+
+// signPayload 计算请求签名，签名内容为 时间戳.请求体
+func signPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// errRetryable 可以重试的请求错误：网络错误、 429 以及 5xx
+type errRetryable struct {
+	err error
+}
+
+func (e *errRetryable) Error() string {
+	return e.err.Error()
+}
+
+// doPost 发送一次请求，返回响应体， requestID 通过 X-Request-Id 请求头发送
+func doPost(URL, requestID string, payload []byte) ([]byte, error) {
+	request, err := http.NewRequest("POST", URL, bytes.NewBuffer(payload))
 	if err != nil {
-		return types.M{}, types.M{"code": -1, "message": "Malformed response"}
+		return nil, err
 	}
 
 	request.Header.Set("Content-Type", "application/json")
+	if requestID != "" {
+		request.Header.Set("X-Request-Id", requestID)
+	}
 	if config.TConfig.WebhookKey != "" {
 		request.Header.Add("X-Parse-Webhook-Key", config.TConfig.WebhookKey)
 	}
+	if config.TConfig.WebhookSigningSecret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		request.Header.Set("X-Parse-Webhook-Timestamp", timestamp)
+		request.Header.Set("X-Parse-Webhook-Signature", "sha256="+signPayload(config.TConfig.WebhookSigningSecret, timestamp, payload))
+	}
 
-	client := http.DefaultClient
-	response, err := client.Do(request)
+	response, err := getWebhookClient().Do(request)
 	if err != nil {
-		return types.M{}, types.M{"code": -1, "message": "Malformed response"}
+		return nil, &errRetryable{err}
 	}
 	defer response.Body.Close()
 
 	body, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return types.M{}, types.M{"code": -1, "message": "Malformed response"}
+		return nil, &errRetryable{err}
+	}
+	if response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500 {
+		return nil, &errRetryable{errors.New("webhook responded with status " + strconv.Itoa(response.StatusCode))}
+	}
+	return body, nil
+}
+
+// postWithRetry 发送请求，遇到可以重试的错误时按指数退避重试
+// 重试后仍然失败时，交给 deadLetterHandler 处理
+func postWithRetry(URL string, params types.M, payload []byte) ([]byte, error) {
+	backoff := time.Duration(config.TConfig.WebhookRetryBackoff) * time.Millisecond
+	attempts := 0
+	for {
+		attempts++
+		body, err := doPost(URL, utils.S(params["requestId"]), payload)
+		if err == nil {
+			return body, nil
+		}
+		if _, ok := err.(*errRetryable); ok == false {
+			return nil, err
+		}
+		if attempts > config.TConfig.WebhookMaxRetries {
+			if deadLetterHandler != nil {
+				deadLetterHandler(URL, params, err, attempts)
+			}
+			return nil, err
+		}
+		time.Sleep(backoff)
+		backoff = backoff * 2
+	}
+}
+
+// post 请求网络接口
+// 接口返回格式如下：
+// {
+// 	"success":{},
+// 	"error":{},
+// }
+func post(params types.M, URL string) (r types.M, e types.M) {
+	result, e := postForResult(params, URL)
+	if e != nil {
+		return types.M{}, e
+	}
+	return utils.M(result), nil
+}
+
+// postForResult 请求网络接口，返回 success 中的原始数据，可以是对象或者数组
+func postForResult(params types.M, URL string) (interface{}, types.M) {
+	jsonParams, err := json.Marshal(params)
+	if err != nil {
+		return nil, types.M{"code": -1, "message": "Malformed response"}
+	}
+	if max := config.TConfig.WebhookMaxPayloadSize; max > 0 && len(jsonParams) > max {
+		return nil, types.M{"code": 0, "message": "Webhook payload is too large: " + strconv.Itoa(len(jsonParams)) + " bytes, limit is " + strconv.Itoa(max) + " bytes"}
+	}
+
+	body, err := postWithRetry(URL, params, jsonParams)
+	if err != nil {
+		return nil, types.M{"code": -1, "message": "Malformed response"}
 	}
 
 	var result types.M
 	err = json.Unmarshal(body, &result)
 	if err != nil {
-		return types.M{}, types.M{"code": -1, "message": "Malformed response"}
+		return nil, types.M{"code": -1, "message": "Malformed response"}
 	}
 
 	if result["error"] != nil {
-		return types.M{}, types.M{"code": 0, "message": utils.S(result["error"])}
+		return nil, types.M{"code": 0, "message": utils.S(result["error"])}
 	}
 
-	return utils.M(result["success"]), nil
+	return result["success"], nil
 }
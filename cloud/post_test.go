@@ -0,0 +1,91 @@
+package cloud
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_post(t *testing.T) {
+	config.TConfig.WebhookSigningSecret = "secret"
+	config.TConfig.WebhookMaxRetries = 2
+	config.TConfig.WebhookRetryBackoff = 0
+	calls := 0
+	var deadLetters int
+	var requestID string
+	SetDeadLetterHandler(func(URL string, params types.M, err error, attempts int) {
+		deadLetters = attempts
+	})
+	defer SetDeadLetterHandler(nil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		requestID = r.Header.Get("X-Request-Id")
+		body, _ := ioutil.ReadAll(r.Body)
+		timestamp := r.Header.Get("X-Parse-Webhook-Timestamp")
+		if r.Header.Get("X-Parse-Webhook-Signature") != "sha256="+signPayload("secret", timestamp, body) {
+			w.WriteHeader(401)
+			return
+		}
+		if r.URL.Path == "/fail" || calls < 2 {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write([]byte(`{"success":{"result":"hello"}}`))
+	}))
+	defer server.Close()
+	/*************************************************/
+	result, err := post(types.M{"params": types.M{}}, server.URL+"/ok")
+	expect := types.M{"result": "hello"}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	if calls != 2 {
+		t.Error("expect:", 2, "result:", calls)
+	}
+	/*************************************************/
+	calls = 0
+	_, err = post(types.M{"params": types.M{}}, server.URL+"/fail")
+	if err == nil {
+		t.Error("expect:", "error", "result:", nil)
+	}
+	if calls != 3 || deadLetters != 3 {
+		t.Error("expect:", 3, "result:", calls, deadLetters)
+	}
+	/*************************************************/
+	calls = 1
+	_, err = post(types.M{"params": types.M{}, "requestId": "req-1"}, server.URL+"/ok")
+	if err != nil || requestID != "req-1" {
+		t.Error("expect:", "req-1", "result:", requestID, err)
+	}
+}
+
+func Test_postForResult(t *testing.T) {
+	config.TConfig.WebhookSigningSecret = ""
+	config.TConfig.WebhookMaxRetries = 0
+	config.TConfig.WebhookMaxPayloadSize = 64
+	defer func() { config.TConfig.WebhookMaxPayloadSize = 0 }()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success":[{"objectId":"1001"}]}`))
+	}))
+	defer server.Close()
+	/*************************************************/
+	result, err := postForResult(types.M{"objects": types.S{}}, server.URL)
+	expect := []interface{}{map[string]interface{}{"objectId": "1001"}}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/*************************************************/
+	objects := types.S{}
+	for i := 0; i < 10; i++ {
+		objects = append(objects, types.M{"objectId": "1001"})
+	}
+	_, err = postForResult(types.M{"objects": objects}, server.URL)
+	if err == nil {
+		t.Error("expect:", "error", "result:", nil)
+	}
+}
@@ -35,6 +35,7 @@ func GetFunctionHandler(url string) FunctionHandler {
 			"master":         request.Master,
 			"user":           request.User,
 			"installationID": request.InstallationID,
+			"requestId":      request.RequestID,
 			"headers":        request.Headers,
 		}
 		result, err := post(params, url)
@@ -54,6 +55,7 @@ func GetValidatorHandler(url string) ValidatorHandler {
 			"master":         request.Master,
 			"user":           request.User,
 			"installationID": request.InstallationID,
+			"requestId":      request.RequestID,
 			"headers":        request.Headers,
 		}
 		result, _ := post(params, url)
@@ -74,6 +76,18 @@ func GetTriggerHandler(url string) TriggerHandler {
 			"master":         request.Master,
 			"user":           request.User,
 			"installationID": request.InstallationID,
+			"requestId":      request.RequestID,
+		}
+		// afterFind 把查询结果发送给云代码，云代码返回过滤或者修改后的结果
+		if request.TriggerName == TypeAfterFind {
+			params["objects"] = request.Objects
+			result, err := postForResult(params, url)
+			if err != nil {
+				response.Error(err["code"].(int), err["message"].(string))
+				return
+			}
+			response.Success(result)
+			return
 		}
 		result, err := post(params, url)
 		if err != nil {
@@ -34,6 +34,7 @@ type TriggerRequest struct {
 	Master         bool
 	User           types.M
 	InstallationID string
+	RequestID      string // 触发当前操作的请求 ID
 }
 
 // FunctionRequest ...
@@ -44,14 +45,16 @@ type FunctionRequest struct {
 	InstallationID string
 	Headers        map[string]string
 	FunctionName   string
+	RequestID      string
 }
 
 // JobRequest ...
 type JobRequest struct {
-	Params  types.M
-	Headers map[string]string
-	JobName string
-	JobID   string
+	Params    types.M
+	Headers   map[string]string
+	JobName   string
+	JobID     string
+	RequestID string
 }
 
 // Response ...
@@ -151,6 +154,7 @@ func UnregisterAll() {
 	functions = map[string]FunctionHandler{}
 	validators = map[string]ValidatorHandler{}
 	jobs = map[string]JobHandler{}
+	fileTriggers = map[string]FileTriggerHandler{}
 }
 
 // GetTrigger 获取回调函数
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// client 使用 Master Key 访问运行中的 talisman 服务
+type client struct {
+	serverURL string
+	appID     string
+	masterKey string
+}
+
+func newClient(serverURL, appID, masterKey string) *client {
+	return &client{
+		serverURL: strings.TrimSuffix(serverURL, "/"),
+		appID:     appID,
+		masterKey: masterKey,
+	}
+}
+
+// request 发送请求， path 相对于 serverURL ，如 /schemas
+// 服务端返回错误时，转换为 errs.TalismanError
+func (c *client) request(method, path string, query url.Values, data interface{}) (types.M, error) {
+	uri := c.serverURL + path
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+	request, err := http.NewRequest(method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-Parse-Application-Id", c.appID)
+	request.Header.Set("X-Parse-Master-Key", c.masterKey)
+	if data != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	b, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	var result types.M
+	if len(b) > 0 {
+		err = json.Unmarshal(b, &result)
+		if err != nil {
+			return nil, errs.E(errs.OtherCause, response.Status+": "+string(b))
+		}
+	}
+	if response.StatusCode >= 400 {
+		if code, ok := result["code"].(float64); ok {
+			return nil, errs.E(int(code), utils.S(result["error"]))
+		}
+		return nil, errs.E(errs.OtherCause, response.Status)
+	}
+	return result, nil
+}
+
+func (c *client) get(path string, query url.Values) (types.M, error) {
+	return c.request("GET", path, query, nil)
+}
+
+func (c *client) post(path string, data interface{}) (types.M, error) {
+	return c.request("POST", path, nil, data)
+}
+
+func (c *client) put(path string, data interface{}) (types.M, error) {
+	return c.request("PUT", path, nil, data)
+}
+
+func (c *client) delete(path string) (types.M, error) {
+	return c.request("DELETE", path, nil, nil)
+}
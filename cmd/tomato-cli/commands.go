@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// indexCreate 为 String 字段创建不区分大小写的索引
+func indexCreate(c *client, className, fieldName string, out io.Writer) error {
+	_, err := c.post("/schemas/"+className+"/indexes", types.M{
+		"fieldName":       fieldName,
+		"caseInsensitive": true,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "created index on %s.%s\n", className, fieldName)
+	return nil
+}
+
+// classPurge 删除类中的所有对象，保留类的 schema
+func classPurge(c *client, className string, out io.Writer) error {
+	_, err := c.delete("/purge/" + className)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "purged class", className)
+	return nil
+}
+
+// userResetPassword 根据用户名查找用户，并设置新的密码
+func userResetPassword(c *client, username, password string, out io.Writer) error {
+	where, err := json.Marshal(types.M{"username": username})
+	if err != nil {
+		return err
+	}
+	result, err := c.get("/users", url.Values{"where": {string(where)}, "limit": {"1"}})
+	if err != nil {
+		return err
+	}
+	users := utils.A(result["results"])
+	if len(users) == 0 {
+		return errs.E(errs.ObjectNotFound, "User "+username+" not found.")
+	}
+	objectID := utils.S(utils.M(users[0])["objectId"])
+	_, err = c.put("/users/"+objectID, types.M{"password": password})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "password updated for", username)
+	return nil
+}
+
+// userConflicts 列出用户名或邮箱在规范化之后相同的用户，开启规范化之前需要先处理这些用户
+// field 为空时检查 username 与 email
+func userConflicts(c *client, field string, out io.Writer) error {
+	query := url.Values{}
+	if field != "" {
+		query.Set("field", field)
+	}
+	result, err := c.get("/userConflicts", query)
+	if err != nil {
+		return err
+	}
+	total := 0
+	for _, f := range []string{"username", "email"} {
+		for _, g := range utils.A(result[f]) {
+			group := utils.M(g)
+			fmt.Fprintf(out, "%s %q:", f, utils.S(group["normalized"]))
+			for _, u := range utils.A(group["users"]) {
+				user := utils.M(u)
+				fmt.Fprintf(out, " %s(%q)", utils.S(user["objectId"]), utils.S(user[f]))
+			}
+			fmt.Fprintln(out)
+			total++
+		}
+	}
+	fmt.Fprintln(out, total, "conflicts found")
+	return nil
+}
+
+// pushTest 向指定的设备发送一条测试推送
+func pushTest(c *client, installationID, alert string, out io.Writer) error {
+	_, err := c.post("/push", types.M{
+		"where": types.M{"objectId": installationID},
+		"data":  types.M{"alert": alert},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "push sent to installation", installationID)
+	return nil
+}
@@ -0,0 +1,105 @@
+// tomato-cli 通过 REST API 使用 Master Key 管理运行中的 talisman 服务
+// 服务地址、 AppID 、 Master Key 可通过参数或者环境变量 TOMATO_SERVER_URL 、 TOMATO_APP_ID 、 TOMATO_MASTER_KEY 设置
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `Usage: tomato-cli [options] <command> [arguments]
+
+Commands:
+  schema list                                 list all classes
+  schema export [file]                        export all schemas, print to stdout when file is omitted
+  schema import <file>                        create missing classes and fields, update class level permissions
+  schema diff <file>                          show differences between file and server
+  schema unused <className>                   list fields never populated in a sample of recent objects
+  schema prune <className> [--confirm]        delete unused fields, only list them without --confirm
+  schema relations <className> [--confirm]    check _Join tables for dangling rows, delete them with --confirm
+  index create <className> <fieldName>        create a case insensitive index on a String field
+  class purge <className>                     delete all objects of a class
+  user reset-password <username> <password>   set a new password for a user
+  user conflicts [username|email]             list users whose username or email differ only by case, unicode form or spaces
+  push test <installationId> [alert]          send a test push to an installation
+
+Options:
+`
+
+func main() {
+	flags := flag.NewFlagSet("tomato-cli", flag.ExitOnError)
+	serverURL := flags.String("server", os.Getenv("TOMATO_SERVER_URL"), "server url, such as http://127.0.0.1:8080/v1")
+	appID := flags.String("appId", os.Getenv("TOMATO_APP_ID"), "application id")
+	masterKey := flags.String("masterKey", os.Getenv("TOMATO_MASTER_KEY"), "master key")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	if *serverURL == "" || *appID == "" || *masterKey == "" {
+		fmt.Fprintln(os.Stderr, "server, appId and masterKey are required")
+		os.Exit(2)
+	}
+
+	c := newClient(*serverURL, *appID, *masterKey)
+	err := run(c, flags.Args(), os.Stdout)
+	if err == errUsage {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+var errUsage = errors.New("invalid command")
+
+// run 执行 args 对应的命令，参数不正确时返回 errUsage
+func run(c *client, args []string, out io.Writer) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	command, params := args[0]+" "+args[1], args[2:]
+	switch {
+	case command == "schema list" && len(params) == 0:
+		return schemaList(c, out)
+	case command == "schema export" && len(params) == 0:
+		return schemaExport(c, "", out)
+	case command == "schema export" && len(params) == 1:
+		return schemaExport(c, params[0], out)
+	case command == "schema import" && len(params) == 1:
+		return schemaImport(c, params[0], out)
+	case command == "schema diff" && len(params) == 1:
+		return schemaDiff(c, params[0], out)
+	case command == "schema unused" && len(params) == 1:
+		return schemaUnused(c, params[0], out)
+	case command == "schema prune" && len(params) == 1:
+		return schemaPrune(c, params[0], false, out)
+	case command == "schema prune" && len(params) == 2 && params[1] == "--confirm":
+		return schemaPrune(c, params[0], true, out)
+	case command == "schema relations" && len(params) == 1:
+		return schemaRelations(c, params[0], false, out)
+	case command == "schema relations" && len(params) == 2 && params[1] == "--confirm":
+		return schemaRelations(c, params[0], true, out)
+	case command == "index create" && len(params) == 2:
+		return indexCreate(c, params[0], params[1], out)
+	case command == "class purge" && len(params) == 1:
+		return classPurge(c, params[0], out)
+	case command == "user reset-password" && len(params) == 2:
+		return userResetPassword(c, params[0], params[1], out)
+	case command == "user conflicts" && len(params) == 0:
+		return userConflicts(c, "", out)
+	case command == "user conflicts" && len(params) == 1 && (params[0] == "username" || params[0] == "email"):
+		return userConflicts(c, params[0], out)
+	case command == "push test" && len(params) == 1:
+		return pushTest(c, params[0], "Test push from tomato-cli", out)
+	case command == "push test" && len(params) == 2:
+		return pushTest(c, params[0], params[1], out)
+	}
+	return errUsage
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"sort"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// schemaList 输出所有类的类名与字段数量
+func schemaList(c *client, out io.Writer) error {
+	schemas, err := fetchSchemas(c)
+	if err != nil {
+		return err
+	}
+	for _, schema := range schemas {
+		fmt.Fprintf(out, "%s\t%d fields\n", utils.S(schema["className"]), len(utils.M(schema["fields"])))
+	}
+	return nil
+}
+
+// schemaExport 导出所有类的 schema ，格式与 GET /schemas 的返回值相同， file 为空时输出到 out
+func schemaExport(c *client, file string, out io.Writer) error {
+	schemas, err := fetchSchemas(c)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(types.M{"results": schemas}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if file == "" {
+		_, err = fmt.Fprintln(out, string(b))
+		return err
+	}
+	return ioutil.WriteFile(file, b, 0644)
+}
+
+// schemaImport 根据文件创建不存在的类，添加缺少的字段，更新类级别权限
+// 不会删除字段，类型不一致的字段跳过并输出提示
+func schemaImport(c *client, file string, out io.Writer) error {
+	local, err := readSchemaFile(file)
+	if err != nil {
+		return err
+	}
+	remote, err := fetchSchemas(c)
+	if err != nil {
+		return err
+	}
+	remoteByName := schemasByClassName(remote)
+
+	for _, schema := range local {
+		className := utils.S(schema["className"])
+		existing := remoteByName[className]
+		if existing == nil {
+			existing, err = c.post("/schemas/"+className, types.M{"className": className})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, "created class", className)
+		}
+
+		fields, conflicts := fieldsToAdd(className, utils.M(schema["fields"]), utils.M(existing["fields"]))
+		for _, conflict := range conflicts {
+			fmt.Fprintln(out, "skipped", conflict)
+		}
+		update := types.M{}
+		if len(fields) > 0 {
+			update["fields"] = fields
+		}
+		clp := utils.M(schema["classLevelPermissions"])
+		if clp != nil && reflect.DeepEqual(clp, utils.M(existing["classLevelPermissions"])) == false {
+			update["classLevelPermissions"] = clp
+		}
+		if len(update) == 0 {
+			continue
+		}
+		_, err = c.put("/schemas/"+className, update)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "updated class %s, %d fields added\n", className, len(fields))
+	}
+	return nil
+}
+
+// schemaDiff 输出文件与服务端 schema 的差异， + 表示仅在文件中存在， - 表示仅在服务端存在， ~ 表示不一致
+func schemaDiff(c *client, file string, out io.Writer) error {
+	local, err := readSchemaFile(file)
+	if err != nil {
+		return err
+	}
+	remote, err := fetchSchemas(c)
+	if err != nil {
+		return err
+	}
+	for _, line := range diffSchemas(local, remote) {
+		fmt.Fprintln(out, line)
+	}
+	return nil
+}
+
+// fetchSchemas 获取服务端所有类的 schema ，按类名排序
+func fetchSchemas(c *client) ([]types.M, error) {
+	result, err := c.get("/schemas", nil)
+	if err != nil {
+		return nil, err
+	}
+	return sortSchemas(utils.A(result["results"])), nil
+}
+
+// readSchemaFile 读取 schemaExport 导出的文件
+func readSchemaFile(file string) ([]types.M, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var data types.M
+	err = json.Unmarshal(b, &data)
+	if err != nil {
+		return nil, err
+	}
+	results := utils.A(data["results"])
+	if results == nil {
+		return nil, errs.E(errs.InvalidJSON, "schema file must contain results")
+	}
+	schemas := sortSchemas(results)
+	for _, schema := range schemas {
+		if utils.S(schema["className"]) == "" {
+			return nil, errs.E(errs.InvalidJSON, "schema file contains a class without className")
+		}
+	}
+	return schemas, nil
+}
+
+func sortSchemas(results types.S) []types.M {
+	byName := map[string]types.M{}
+	names := []string{}
+	for _, r := range results {
+		if schema := utils.M(r); schema != nil {
+			className := utils.S(schema["className"])
+			if byName[className] == nil {
+				names = append(names, className)
+			}
+			byName[className] = schema
+		}
+	}
+	sort.Strings(names)
+	schemas := []types.M{}
+	for _, name := range names {
+		schemas = append(schemas, byName[name])
+	}
+	return schemas
+}
+
+func schemasByClassName(schemas []types.M) map[string]types.M {
+	result := map[string]types.M{}
+	for _, schema := range schemas {
+		result[utils.S(schema["className"])] = schema
+	}
+	return result
+}
+
+// fieldsToAdd 返回 local 中存在而 remote 中不存在的字段，以及类型不一致的字段说明
+func fieldsToAdd(className string, local, remote types.M) (types.M, []string) {
+	fields := types.M{}
+	conflicts := []string{}
+	for _, name := range sortedKeys(local) {
+		localType := utils.M(local[name])
+		remoteType := utils.M(remote[name])
+		if remoteType == nil {
+			fields[name] = local[name]
+			continue
+		}
+		if typeString(localType) != typeString(remoteType) {
+			conflicts = append(conflicts, fmt.Sprintf("%s.%s: %s on server, %s in file", className, name, typeString(remoteType), typeString(localType)))
+		}
+	}
+	return fields, conflicts
+}
+
+// diffSchemas 比较 local 与 remote ，返回按类名与字段名排序的差异
+func diffSchemas(local, remote []types.M) []string {
+	localByName := schemasByClassName(local)
+	remoteByName := schemasByClassName(remote)
+	classNames := map[string]interface{}{}
+	for name := range localByName {
+		classNames[name] = true
+	}
+	for name := range remoteByName {
+		classNames[name] = true
+	}
+
+	lines := []string{}
+	for _, className := range sortedKeys(classNames) {
+		l := localByName[className]
+		r := remoteByName[className]
+		if r == nil {
+			lines = append(lines, "+ class "+className)
+		} else if l == nil {
+			lines = append(lines, "- class "+className)
+		}
+
+		localFields := utils.M(l["fields"])
+		remoteFields := utils.M(r["fields"])
+		fieldNames := map[string]interface{}{}
+		for name := range localFields {
+			fieldNames[name] = true
+		}
+		for name := range remoteFields {
+			fieldNames[name] = true
+		}
+		for _, name := range sortedKeys(fieldNames) {
+			localType := utils.M(localFields[name])
+			remoteType := utils.M(remoteFields[name])
+			switch {
+			case remoteType == nil:
+				lines = append(lines, fmt.Sprintf("+ %s.%s: %s", className, name, typeString(localType)))
+			case localType == nil:
+				lines = append(lines, fmt.Sprintf("- %s.%s: %s", className, name, typeString(remoteType)))
+			case typeString(localType) != typeString(remoteType):
+				lines = append(lines, fmt.Sprintf("~ %s.%s: %s -> %s", className, name, typeString(remoteType), typeString(localType)))
+			}
+		}
+
+		if l != nil && r != nil && reflect.DeepEqual(utils.M(l["classLevelPermissions"]), utils.M(r["classLevelPermissions"])) == false {
+			lines = append(lines, "~ "+className+".classLevelPermissions")
+		}
+	}
+	return lines
+}
+
+// typeString 返回字段类型的描述，如 String 、 Pointer<_User>
+func typeString(fieldType types.M) string {
+	t := utils.S(fieldType["type"])
+	if targetClass := utils.S(fieldType["targetClass"]); targetClass != "" {
+		return t + "<" + targetClass + ">"
+	}
+	return t
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// schemaUnused 输出类中定义但在抽样对象中都没有值的字段
+func schemaUnused(c *client, className string, out io.Writer) error {
+	result, err := c.get("/schemas/"+className+"/unusedFields", nil)
+	if err != nil {
+		return err
+	}
+	printUnusedFields(className, result, "unused", out)
+	return nil
+}
+
+// schemaPrune 删除类中没有使用的字段， confirm 为 false 时只输出将被删除的字段
+func schemaPrune(c *client, className string, confirm bool, out io.Writer) error {
+	if confirm == false {
+		result, err := c.get("/schemas/"+className+"/unusedFields", nil)
+		if err != nil {
+			return err
+		}
+		printUnusedFields(className, result, "would delete", out)
+		if len(utils.A(result["fields"])) > 0 {
+			fmt.Fprintln(out, "run with --confirm to delete these fields")
+		}
+		return nil
+	}
+	result, err := c.request("DELETE", "/schemas/"+className+"/unusedFields", url.Values{"confirm": {"true"}}, nil)
+	if err != nil {
+		return err
+	}
+	printUnusedFields(className, result, "deleted", out)
+	return nil
+}
+
+func printUnusedFields(className string, result types.M, action string, out io.Writer) {
+	fields := utils.A(result["fields"])
+	if len(fields) == 0 {
+		fmt.Fprintf(out, "no unused fields in %s (%v objects sampled)\n", className, result["sampleSize"])
+		return
+	}
+	for _, field := range fields {
+		fmt.Fprintf(out, "%s %s.%s\n", action, className, utils.S(field))
+	}
+}
+
+// schemaRelations 检查类中 Relation 字段的 _Join 表， confirm 为 true 时删除引用了不存在对象的记录
+func schemaRelations(c *client, className string, confirm bool, out io.Writer) error {
+	var result types.M
+	var err error
+	if confirm {
+		result, err = c.request("DELETE", "/schemas/"+className+"/danglingRelations", url.Values{"confirm": {"true"}}, nil)
+	} else {
+		result, err = c.get("/schemas/"+className+"/danglingRelations", nil)
+	}
+	if err != nil {
+		return err
+	}
+	dangling := 0
+	for _, v := range utils.A(result["results"]) {
+		r := utils.M(v)
+		n, _ := r["dangling"].(float64)
+		removed, _ := r["removed"].(float64)
+		fmt.Fprintf(out, "%s.%s\t%v rows checked, %v dangling, %v removed\n", className, utils.S(r["field"]), r["checked"], n, removed)
+		dangling += int(n)
+	}
+	if confirm == false && dangling > 0 {
+		fmt.Fprintln(out, "run with --confirm to delete dangling rows")
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_schemaPrune(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		json.NewEncoder(w).Encode(types.M{"className": "post", "sampleSize": 2, "fields": types.S{"oldTitle"}})
+	}))
+	defer server.Close()
+	c := newClient(server.URL, "appId", "masterKey")
+
+	var out bytes.Buffer
+	err := schemaPrune(c, "post", false, &out)
+	expect := "would delete post.oldTitle\nrun with --confirm to delete these fields\n"
+	if err != nil || out.String() != expect {
+		t.Error("expect:", expect, "result:", out.String(), err)
+	}
+	if len(requests) != 1 || requests[0] != "GET /schemas/post/unusedFields" {
+		t.Error("expect:", "GET /schemas/post/unusedFields", "result:", requests)
+	}
+	/************************************************************/
+	requests = nil
+	out.Reset()
+	err = schemaPrune(c, "post", true, &out)
+	expect = "deleted post.oldTitle\n"
+	if err != nil || out.String() != expect {
+		t.Error("expect:", expect, "result:", out.String(), err)
+	}
+	if len(requests) != 1 || requests[0] != "DELETE /schemas/post/unusedFields?confirm=true" {
+		t.Error("expect:", "DELETE /schemas/post/unusedFields?confirm=true", "result:", requests)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_schemaRelations(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		removed := 0
+		if r.Method == "DELETE" {
+			removed = 2
+		}
+		json.NewEncoder(w).Encode(types.M{"className": "post", "results": types.S{
+			types.M{"field": "likes", "targetClass": "_User", "checked": 10, "dangling": 2, "removed": removed},
+		}})
+	}))
+	defer server.Close()
+	c := newClient(server.URL, "appId", "masterKey")
+
+	var out bytes.Buffer
+	err := schemaRelations(c, "post", false, &out)
+	expect := "post.likes\t10 rows checked, 2 dangling, 0 removed\nrun with --confirm to delete dangling rows\n"
+	if err != nil || out.String() != expect {
+		t.Error("expect:", expect, "result:", out.String(), err)
+	}
+	if len(requests) != 1 || requests[0] != "GET /schemas/post/danglingRelations" {
+		t.Error("expect:", "GET /schemas/post/danglingRelations", "result:", requests)
+	}
+	/************************************************************/
+	requests = nil
+	out.Reset()
+	err = schemaRelations(c, "post", true, &out)
+	expect = "post.likes\t10 rows checked, 2 dangling, 2 removed\n"
+	if err != nil || out.String() != expect {
+		t.Error("expect:", expect, "result:", out.String(), err)
+	}
+	if len(requests) != 1 || requests[0] != "DELETE /schemas/post/danglingRelations?confirm=true" {
+		t.Error("expect:", "DELETE /schemas/post/danglingRelations?confirm=true", "result:", requests)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_diffSchemas(t *testing.T) {
+	type args struct {
+		local  []types.M
+		remote []types.M
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			name: "1",
+			args: args{
+				local:  []types.M{{"className": "post", "fields": types.M{"title": types.M{"type": "String"}}}},
+				remote: []types.M{{"className": "post", "fields": types.M{"title": types.M{"type": "String"}}}},
+			},
+			want: []string{},
+		},
+		{
+			name: "2",
+			args: args{
+				local: []types.M{
+					{"className": "post", "fields": types.M{"title": types.M{"type": "String"}}},
+				},
+				remote: []types.M{
+					{"className": "user", "fields": types.M{"name": types.M{"type": "String"}}},
+				},
+			},
+			want: []string{
+				"+ class post",
+				"+ post.title: String",
+				"- class user",
+				"- user.name: String",
+			},
+		},
+		{
+			name: "3",
+			args: args{
+				local: []types.M{
+					{
+						"className": "post",
+						"fields": types.M{
+							"title":  types.M{"type": "String"},
+							"author": types.M{"type": "Pointer", "targetClass": "_User"},
+							"likes":  types.M{"type": "Number"},
+						},
+						"classLevelPermissions": types.M{"find": types.M{"*": true}},
+					},
+				},
+				remote: []types.M{
+					{
+						"className": "post",
+						"fields": types.M{
+							"title":  types.M{"type": "String"},
+							"author": types.M{"type": "Pointer", "targetClass": "user"},
+							"tags":   types.M{"type": "Array"},
+						},
+						"classLevelPermissions": types.M{"find": types.M{}},
+					},
+				},
+			},
+			want: []string{
+				"~ post.author: Pointer<user> -> Pointer<_User>",
+				"+ post.likes: Number",
+				"- post.tags: Array",
+				"~ post.classLevelPermissions",
+			},
+		},
+	}
+	for _, tt := range tests {
+		if got := diffSchemas(tt.args.local, tt.args.remote); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. diffSchemas() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_fieldsToAdd(t *testing.T) {
+	type args struct {
+		className string
+		local     types.M
+		remote    types.M
+	}
+	tests := []struct {
+		name          string
+		args          args
+		wantFields    types.M
+		wantConflicts []string
+	}{
+		{
+			name: "1",
+			args: args{
+				className: "post",
+				local:     types.M{"objectId": types.M{"type": "String"}, "title": types.M{"type": "String"}},
+				remote:    types.M{"objectId": types.M{"type": "String"}},
+			},
+			wantFields:    types.M{"title": types.M{"type": "String"}},
+			wantConflicts: []string{},
+		},
+		{
+			name: "2",
+			args: args{
+				className: "post",
+				local:     types.M{"likes": types.M{"type": "Number"}},
+				remote:    types.M{"likes": types.M{"type": "String"}},
+			},
+			wantFields:    types.M{},
+			wantConflicts: []string{"post.likes: String on server, Number in file"},
+		},
+	}
+	for _, tt := range tests {
+		fields, conflicts := fieldsToAdd(tt.args.className, tt.args.local, tt.args.remote)
+		if !reflect.DeepEqual(fields, tt.wantFields) {
+			t.Errorf("%q. fieldsToAdd() fields = %v, want %v", tt.name, fields, tt.wantFields)
+		}
+		if !reflect.DeepEqual(conflicts, tt.wantConflicts) {
+			t.Errorf("%q. fieldsToAdd() conflicts = %v, want %v", tt.name, conflicts, tt.wantConflicts)
+		}
+	}
+}
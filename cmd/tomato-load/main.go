@@ -0,0 +1,63 @@
+// tomato-load 回放 bench.NewRecorder 录制的 REST 请求，输出请求数、错误数与耗时分布
+// 服务地址可通过参数或者环境变量 TOMATO_SERVER_URL 设置，只使用其中的协议与主机部分
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/okobsamoht/talisman/bench"
+)
+
+const usage = `Usage: tomato-load [options] <file>
+
+Replay requests recorded by bench.NewRecorder against a running server.
+
+Options:
+`
+
+func main() {
+	flags := flag.NewFlagSet("tomato-load", flag.ExitOnError)
+	serverURL := flags.String("server", os.Getenv("TOMATO_SERVER_URL"), "server url, such as http://127.0.0.1:8080")
+	concurrency := flags.Int("c", 10, "number of concurrent requests")
+	speed := flags.Float64("speed", 0, "replay speed relative to the recording, 0 sends requests as fast as possible")
+	repeat := flags.Int("n", 1, "number of times to replay the file")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	if *serverURL == "" || flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	u, err := url.Parse(*serverURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	records, err := bench.ReadRecords(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	p := &bench.Replayer{
+		BaseURL:     u.Scheme + "://" + u.Host,
+		Concurrency: *concurrency,
+		Speed:       *speed,
+	}
+	for i := 0; i < *repeat; i++ {
+		fmt.Println(p.Run(records))
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP 返回请求的客户端地址，用于 Master Key 来源限制、请求频率限制与审计日志
+// 默认使用直接连接的地址， X-Forwarded-For 可以由客户端任意设置，只有直接连接的地址属于 TrustedProxies 时才读取
+func ClientIP(r *http.Request) string {
+	return clientIP(r.RemoteAddr, r.Header.Get("X-Forwarded-For"), TConfig.TrustedProxies)
+}
+
+// clientIP 从右向左读取 X-Forwarded-For ，跳过可信的代理，返回第一个不可信的地址
+// 全部为可信的代理时返回最左侧的地址
+func clientIP(remoteAddr, forwardedFor string, trustedProxies []string) string {
+	ip := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		ip = host
+	}
+	if isTrustedProxy(ip, trustedProxies) == false || forwardedFor == "" {
+		return ip
+	}
+	hops := strings.Split(forwardedFor, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// 无法解析的地址视为客户端伪造，使用上一个可信代理记录的地址
+			return ip
+		}
+		ip = hop
+		if isTrustedProxy(ip, trustedProxies) == false {
+			return ip
+		}
+	}
+	return ip
+}
+
+// isTrustedProxy 判断 ip 是否属于可信的代理
+func isTrustedProxy(ip string, trustedProxies []string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, s := range trustedProxies {
+		if ipNet, ok := parseIPNet(s); ok && ipNet.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
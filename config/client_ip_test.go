@@ -0,0 +1,114 @@
+package config
+
+import (
+	"net/http"
+	"testing"
+)
+
+func Test_clientIP(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "192.168.1.1"}
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		trusted      []string
+		want         string
+	}{
+		{
+			name:       "1",
+			remoteAddr: "203.0.113.5:52100",
+			trusted:    trusted,
+			want:       "203.0.113.5",
+		},
+		{
+			name:         "2",
+			remoteAddr:   "203.0.113.5:52100",
+			forwardedFor: "127.0.0.1",
+			trusted:      trusted,
+			want:         "203.0.113.5",
+		},
+		{
+			name:         "3",
+			remoteAddr:   "203.0.113.5:52100",
+			forwardedFor: "127.0.0.1",
+			trusted:      nil,
+			want:         "203.0.113.5",
+		},
+		{
+			name:         "4",
+			remoteAddr:   "10.0.0.2:52100",
+			forwardedFor: "198.51.100.7",
+			trusted:      trusted,
+			want:         "198.51.100.7",
+		},
+		{
+			name:         "5",
+			remoteAddr:   "10.0.0.2:52100",
+			forwardedFor: "127.0.0.1, 198.51.100.7",
+			trusted:      trusted,
+			want:         "198.51.100.7",
+		},
+		{
+			name:         "6",
+			remoteAddr:   "10.0.0.2:52100",
+			forwardedFor: "127.0.0.1, 198.51.100.7, 192.168.1.1",
+			trusted:      trusted,
+			want:         "198.51.100.7",
+		},
+		{
+			name:         "7",
+			remoteAddr:   "10.0.0.2:52100",
+			forwardedFor: "10.0.0.3",
+			trusted:      trusted,
+			want:         "10.0.0.3",
+		},
+		{
+			name:         "8",
+			remoteAddr:   "10.0.0.2:52100",
+			forwardedFor: "unknown",
+			trusted:      trusted,
+			want:         "10.0.0.2",
+		},
+		{
+			name:         "9",
+			remoteAddr:   "[2001:db8::1]:52100",
+			forwardedFor: "127.0.0.1",
+			trusted:      trusted,
+			want:         "2001:db8::1",
+		},
+	}
+	for _, tt := range tests {
+		if got := clientIP(tt.remoteAddr, tt.forwardedFor, tt.trusted); got != tt.want {
+			t.Errorf("%q. clientIP() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_MasterKeyIPAllowed_spoofedForwardedFor(t *testing.T) {
+	masterKeyIPs, trustedProxies := TConfig.MasterKeyIPs, TConfig.TrustedProxies
+	defer func() {
+		TConfig.MasterKeyIPs, TConfig.TrustedProxies = masterKeyIPs, trustedProxies
+	}()
+	TConfig.MasterKeyIPs = []string{"127.0.0.1"}
+	TConfig.TrustedProxies = nil
+
+	r, _ := http.NewRequest("GET", "/v1/schemas", nil)
+	r.RemoteAddr = "203.0.113.5:52100"
+	r.Header.Set("X-Forwarded-For", "127.0.0.1")
+	if MasterKeyIPAllowed(ClientIP(r)) {
+		t.Errorf("MasterKeyIPAllowed() = true with spoofed X-Forwarded-For")
+	}
+
+	r.RemoteAddr = "127.0.0.1:52100"
+	r.Header.Del("X-Forwarded-For")
+	if MasterKeyIPAllowed(ClientIP(r)) == false {
+		t.Errorf("MasterKeyIPAllowed() = false for direct peer 127.0.0.1")
+	}
+
+	TConfig.TrustedProxies = []string{"10.0.0.0/8"}
+	r.RemoteAddr = "10.0.0.2:52100"
+	r.Header.Set("X-Forwarded-For", "127.0.0.1, 203.0.113.5")
+	if MasterKeyIPAllowed(ClientIP(r)) {
+		t.Errorf("MasterKeyIPAllowed() = true with spoofed X-Forwarded-For behind trusted proxy")
+	}
+}
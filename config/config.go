@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
+	"strconv"
 	"time"
 
 	"log"
+	"net"
 
 	"regexp"
 
@@ -16,24 +19,98 @@ import (
 type Config struct {
 	AppName                          string   // 应用名称，必填
 	ServerURL                        string   // 服务对外地址，必填
+	LogLevel                         string   // 日志级别，可选： error warn verbose info debug ，默认为 debug ，支持运行时重新加载
 	DatabaseType                     string   // 数据库类型，可选： MongoDB、PostgreSQL
 	DatabaseURI                      string   // 数据库地址
+	PostgresStmtCacheSize            int      // PostgreSQL 预编译语句缓存数量，取值大于等于 0 ，默认为 200 ， 0 表示不缓存
+	PostgresRowLevelSecurity         bool     // 是否使用 PostgreSQL 行级安全策略校验对象的 ACL ，默认为 false 在查询条件中校验，连接使用的角色为超级用户或者具有 BYPASSRLS 属性时不会开启
+	PostgresReadReplicaURIs          string   // PostgreSQL 只读副本地址，多个地址使用 | 隔开，查询对象与计数使用副本，写入与修改表结构使用主库
+	PostgresReplicaMaxLag            int      // 只读副本允许的最大延迟，单位为秒，超过时暂停使用该副本，默认为 0 不检查
+	MongoConnectTimeout              int      // MongoDB 连接超时时间，单位为秒，默认为 10
+	MongoSocketTimeout               int      // MongoDB 单次操作的超时时间，单位为秒，默认为 60
+	MongoPoolLimit                   int      // MongoDB 每个服务器的最大连接数，默认为 0 使用驱动的默认值
+	MongoReadPreference              string   // MongoDB 读取偏好，可选： primary primaryPreferred secondary secondaryPreferred nearest ，默认为 primary ，兼容旧配置中的 monotonic ，等同于 primary
+	MongoReadConcern                 string   // MongoDB 读关注，可选： local available majority linearizable snapshot ，默认为空使用服务器的默认值
+	MongoWriteConcern                string   // MongoDB 写关注，可选：大于等于 0 的整数或者 majority ，默认为空使用驱动的默认值
+	MongoWriteConcernTimeout         int      // MongoDB 等待写关注的超时时间，单位为毫秒，默认为 0 不超时
+	MongoJournal                     bool     // MongoDB 写入时是否等待日志落盘，默认为 false
+	MongoRetryWrites                 bool     // MongoDB 写入遇到网络错误或者主节点切换时是否自动重试一次，需要副本集或者分片集群，默认为 true
+	MongoRetryReads                  bool     // MongoDB 读取遇到网络错误或者主节点切换时是否自动重试一次，默认为 true
+	MaxQueryResultRows               int      // 不使用 Master Key 的查询最多读取的对象数，超出时中止查询并返回错误，默认为 0 不限制
+	MaxQueryResultBytes              int      // 不使用 Master Key 的查询最多读取的数据大小，单位为字节，超出时中止查询并返回错误，默认为 0 不限制
+	SlowQueryThreshold               int      // 查询耗时超过该值时记录到慢查询日志，用于生成索引建议，单位为毫秒，默认为 0 不记录
+	SlowQueryLogSize                 int      // 慢查询日志保留的最近记录数，默认为 1000
+	SlowQueryAutoIndex               bool     // 是否自动创建慢查询的建议索引，同一个建议索引出现 SlowQueryAutoIndexMinCount 次后创建，默认为 false
+	SlowQueryAutoIndexMinCount       int      // 自动创建建议索引需要的慢查询次数，默认为 10
+	MaxObjectNestingDepth            int      // 写入时 Object 与 Array 字段值的最大嵌套层数，字段值本身为第 1 层，默认为 0 不限制
+	MaxObjectKeyLength               int      // 写入时 Object 字段值中键的最大长度，按字符计算，默认为 0 不限制
+	NormalizeObjectKeys              bool     // 写入时是否把 Object 字段值中的键转换为 Unicode NFC 形式，默认为 false 不转换
+	DefaultLocales                   []string // 查询 LocalizedString 字段时，请求的语言都不存在时依次使用的语言，多个使用 | 分隔，如： en|zh ，默认为空
 	AppID                            string   // 必填
 	MasterKey                        string   // 必填
+	AppKeys                          []string // 用于轮换的附加密钥，格式为 类型:标签:密钥[:过期日期] ，多个使用 | 分隔，类型可选： master client javascript dotnet restapi ，过期日期格式为 2006-01-02 ，如： master:2024q1:abc123:2024-04-01|client:ios:def456 ，与 MasterKey 等同时有效，支持运行时重新加载
+	MasterKeyIPs                     []string // 允许使用 Master Key 的 IP 或者 CIDR 网段，多个使用 | 分隔，如： 127.0.0.1|10.0.0.0/8 ，默认为空不限制，其他地址使用 Master Key 的请求被拒绝并记录审计日志
+	TrustedProxies                   []string // 可信的反向代理 IP 或者 CIDR 网段，多个使用 | 分隔，只有直接连接的地址属于其中时才从 X-Forwarded-For 中读取客户端地址，默认为空，始终使用直接连接的地址
 	ClientKey                        string   // 选填
 	JavaScriptKey                    string   // 选填
 	DotNetKey                        string   // 选填
 	RestAPIKey                       string   // 选填
 	AllowClientClassCreation         bool     // 是否允许客户端操作不存在的 class ，默认为 fasle 不允许操作
+	DefaultClassLevelPermissions     string   // 新建类的默认权限，可选： public authenticated masterKey ，默认为 public 所有人可访问，系统类不受影响
 	EnableAnonymousUsers             bool     // 是否支持匿名用户，默认为 true 支持匿名用户
 	VerifyUserEmails                 bool     // 是否需要验证用户的 Email ，默认为 false 不需要验证
 	EmailVerifyTokenValidityDuration int      // 邮箱验证 Token 有效期，单位为秒，取值大于等于 0 ，默认为 0 表示不设置 Token 有效期
-	MailAdapter                      string   // 邮件发送模块，仅在 VerifyUserEmails=true 时需要配置，可选： smtp ，默认为 smtp
+	MailAdapter                      string   // 邮件发送模块，仅在 VerifyUserEmails=true 时需要配置，可选： smtp、SendGrid、Mailgun ，默认为 smtp
 	SMTPServer                       string   // SMTP 邮箱服务器地址，仅在 MailAdapter=smtp 时需要配置
 	MailUsername                     string   // SMTP 用户名，仅在 MailAdapter=smtp 时需要配置
 	MailPassword                     string   // SMTP 密码，仅在 MailAdapter=smtp 时需要配置
+	MailFrom                         string   // 发件人地址，仅在 MailAdapter=SendGrid 或者 Mailgun 时需要配置，如： Talisman <noreply@example.com>
+	MailTemplates                    []string // 邮件模板，格式为 邮件类型:模板 ID ，多个使用 | 分隔，邮件类型可选： verifyEmail passwordReset ，如： verifyEmail:d-123|passwordReset:d-456 ，未配置的类型发送纯文本邮件
+	MailSandbox                      bool     // 是否使用沙盒模式，只校验请求而不实际发送邮件，用于测试，默认为 false
+	MailEventsToken                  string   // 邮件投递事件接口 /mail/events 的 token ，SendGrid 与 Mailgun 的回调地址中需要携带 ?token= ，为空时不接收事件
+	SendGridAPIKey                   string   // SendGrid API Key ，仅在 MailAdapter=SendGrid 时需要配置
+	MailgunDomain                    string   // Mailgun 发信域名，仅在 MailAdapter=Mailgun 时需要配置
+	MailgunAPIKey                    string   // Mailgun API Key ，仅在 MailAdapter=Mailgun 时需要配置
+	MailgunAPIURL                    string   // Mailgun API 地址，欧洲区域为 https://api.eu.mailgun.net ，默认为 https://api.mailgun.net
+	MailgunWebhookSigningKey         string   // Mailgun 回调的签名密钥，设置后校验投递事件的签名，选填
+	PhoneAuth                        bool     // 是否支持手机号验证码登录，开启后通过 /phoneVerificationRequest 发送验证码，使用 authData.phone 登录，默认为 false
+	SMSAdapter                       string   // 短信发送模块，仅在 PhoneAuth=true 时需要配置，可选： Twilio ，默认为 Twilio
+	TwilioAccountSID                 string   // Twilio Account SID ，仅在 SMSAdapter=Twilio 时需要配置
+	TwilioAuthToken                  string   // Twilio Auth Token ，仅在 SMSAdapter=Twilio 时需要配置
+	TwilioFrom                       string   // 发送短信的号码或者 Messaging Service SID ，仅在 SMSAdapter=Twilio 时需要配置
+	PhoneCodeLength                  int      // 验证码位数，取值范围： 4-10 ，默认为 6
+	PhoneCodeValidityDuration        int      // 验证码有效期，单位为秒，取值大于 0 ，默认为 300 秒
+	PhoneCodeMaxAttempts             int      // 同一个验证码允许的校验失败次数，超过后验证码失效，取值大于 0 ，默认为 5 次
+	PhoneCodeResendInterval          int      // 同一手机号重新发送验证码的最小间隔，单位为秒，取值大于等于 0 ，默认为 60 秒
+	LDAPAuth                         bool     // 是否支持 LDAP/Active Directory 用户名密码登录，使用 authData.ldap 登录，默认为 false
+	LDAPURL                          string   // LDAP 服务器地址，如 ldaps://ldap.example.com:636 或者 ldap://ldap.example.com:389 ， LDAPAuth=true 时必填
+	LDAPStartTLS                     bool     // 使用 ldap:// 连接时是否通过 StartTLS 加密，默认为 false
+	LDAPBindDN                       string   // 查找用户时使用的服务账号，为空时匿名查找
+	LDAPBindPassword                 string   // 服务账号的密码
+	LDAPBaseDN                       string   // 查找用户的位置，如 ou=people,dc=example,dc=com ， LDAPAuth=true 时必填
+	LDAPUserFilter                   string   // 查找用户的过滤条件， %s 替换为登录的用户名，默认为 (uid=%s) ， Active Directory 使用 (sAMAccountName=%s)
+	LDAPEmailAttribute               string   // 用户邮箱的属性名，首次登录创建用户时保存到 email 字段，默认为 mail
+	LDAPGroupBaseDN                  string   // 查找用户组的位置，为空时使用用户的 memberOf 属性获取用户组
+	LDAPGroupFilter                  string   // 查找用户组的过滤条件， %s 替换为用户的 DN ，默认为 (member=%s)
+	LDAPGroupRoles                   []string // 用户组与角色的对应关系，格式为 用户组名:角色名 ，多个使用 | 分隔，如： admins:Administrator|staff:Staff ，每次登录时同步角色
+	SSOProviders                     []SSO    // OIDC 单点登录服务商，配置项 SSOProviders 为服务商名称，多个使用 | 分隔，每个服务商的参数在 [sso.名称] 节中配置，见 SSO
 	FileAdapter                      string   // 文件存储模块，可选： Disk、GridFS、Sina、Tencent， 默认为 Disk 本地磁盘存储
 	FileDirectAccess                 bool     // 是否允许直接访问文件地址，默认为 true 允许直接访问而不是通过 talisman 中转
+	FileMetadata                     bool     // 是否在 _File 表中保存文件的类型、大小、上传者与标签，并在查询结果的 File 字段中返回，默认为 false
+	FileCacheControl                 string   // 下载文件时返回的 Cache-Control 头部，如： public, max-age=86400 ，默认为空不返回
+	FileOriginToken                  string   // CDN 回源时携带的 token ，设置后下载文件的请求必须在 FileOriginTokenHeader 头部中携带相同的值，默认为空不校验
+	FileOriginTokenHeader            string   // 携带 CDN 回源 token 的头部，默认为 X-Origin-Token
+	ImageTransform                   bool     // 下载图片时是否允许通过 width height quality format 参数缩放图片与转换格式，默认为 false ，开启时必须设置 ImageSigningKey
+	ImageSigningKey                  string   // 图片处理地址的签名密钥，请求中的 sig 参数必须与签名一致，防止任意参数的请求消耗服务器资源
+	ImageMaxDimension                int      // 图片处理允许的最大宽度与高度，单位为像素，默认为 4096
+	ImageCacheAdapter                string   // 处理之后的图片缓存，可选： Disk、Redis、Null ，默认为 Disk ， Redis 使用 RedisAddress 与 RedisPassword 连接
+	ImageCacheTTL                    int      // 处理之后的图片缓存有效期，单位为秒，取值大于等于 0 ，默认为 86400 ， 0 表示不过期
+	FileMaxSize                      int      // 上传文件的最大长度，单位为字节，默认为 0 不限制
+	FileAllowedTypes                 []string // 允许上传的文件类型，可以是 Content-Type 、以 /* 结尾的类型前缀或者以 . 开头的扩展名，多个使用 | 分隔，如： image/*|application/pdf|.txt ，默认为空不限制
+	FileScanAdapter                  string   // 上传文件的病毒扫描模块，可选： ClamAV、Webhook ，默认为空不扫描
+	FileScanAddress                  string   // 病毒扫描地址， FileScanAdapter=ClamAV 时为 clamd 的 TCP 地址，如 127.0.0.1:3310 ， FileScanAdapter=Webhook 时为接收文件的 URL
+	FileScanTimeout                  int      // 病毒扫描的超时时间，单位为秒，取值大于 0 ，默认为 30
+	FileScanAction                   string   // 发现病毒时的处理方式，可选： Reject 拒绝上传、 Quarantine 拒绝上传并把文件保存到隔离目录，默认为 Reject
 	SinaBucket                       string   // 新浪云存储 Bucket ，仅在 FileAdapter=Sina 时需要配置
 	SinaDomain                       string   // 新浪云存储 Domain ，仅在 FileAdapter=Sina 时需要配置
 	SinaAccessKey                    string   // 新浪云存储 AccessKey ，仅在 FileAdapter=Sina 时需要配置
@@ -47,21 +124,83 @@ type Config struct {
 	PushBatchSize                    int      // 批量推送的大小
 	ScheduledPush                    bool     // 是否有推送调度器
 	LiveQueryClasses                 string   // LiveQuery 支持的 classe ，多个 class 使用 | 隔开，如： classeA|classeB|classeC
-	PublisherType                    string   // 发布者类型，可选：Redis ，默认使用自带的 EventEmitter
+	PublisherType                    string   // 发布者类型，可选：EventEmitter Redis 或者通过 pubsub.RegisterTransport 注册的类型，默认使用自带的 EventEmitter ，多实例部署时需要使用 Redis
 	PublisherURL                     string   // 发布者地址， PublisherType=Redis 时必填
 	PublisherConfig                  string   // 发布者配置信息， PublisherType=Redis 时为 Redis 密码，选填
+	LiveQueryPingInterval            int      // LiveQuery 向客户端发送 ping 帧的间隔，单位为秒，默认为 30 ， 0 表示不发送
+	LiveQueryPingTimeout             int      // LiveQuery 未收到客户端消息时断开连接的时间，单位为秒，默认为 0 不限制
+	LiveQueryMaxConnections          int      // LiveQuery 最大连接数，默认为 0 不限制
+	LiveQueryResumeWindow            int      // LiveQuery 客户端断开之后保留订阅的时间，单位为秒，默认为 30 ， 0 表示不保留
+	LiveQueryEnableSSE               bool     // LiveQuery 是否开启 SSE 接口，用于无法使用 WebSocket 的环境，默认为 false
+	LiveQueryEventSource             string   // LiveQuery 事件来源，可选： Controller Database ，默认为 Controller ， Database 时从数据库变更中生成事件，可收到直接写入数据库的修改
+	LiveQueryClassPrefix             string   // LiveQueryEventSource=Database 时，类名以此开头的类同样生成事件，默认为空，仅处理 LiveQueryClasses 中的类
 	SessionLength                    int      // Session 有效期，单位为秒，取值大于 0 ，默认为 31536000 秒，即 1 年
 	RevokeSessionOnPasswordReset     bool     // 密码重置后是否清除 Session ，默认为 true 清除 Session
+	SessionTokenType                 string   // sessionToken 的类型，可选： Random、JWT ，默认为 Random ，为 JWT 时登录返回签名的 token ，已有的 Random token 仍然可用
+	JWTSigningKeys                   []string // JWT 签名密钥，格式为 kid:密钥 ，多个密钥使用 | 分隔，第一个用于签名，全部用于验证，轮换时把新密钥放在最前面
+	JWTTTL                           int      // JWT 有效期，单位为秒，默认为 0 表示与 SessionLength 相同
+	JWTRevocationCheck               bool     // 验证 JWT 之后是否查询 _Session 确认未被注销，默认为 true ，为 false 时完全无状态，注销后 token 在过期前仍然有效，并且请求中的用户只包含 objectId
+	RefreshTokens                    bool     // 登录时是否同时返回 refreshToken ，开启后 sessionToken 的有效期为 AccessTokenLength ，过期前通过 /refreshSession 换取新的 token ，默认为 false
+	AccessTokenLength                int      // 开启 RefreshTokens 时 sessionToken 的有效期，单位为秒，取值大于 0 ，默认为 3600 秒
+	RefreshTokenLength               int      // refreshToken 的有效期，单位为秒，取值大于 0 ，默认为 2592000 秒，即 30 天
+	SessionActivityInterval          int      // 记录 session 最后使用时间与 IP 的最小间隔，单位为秒，默认为 300 秒，为 0 时不记录
+	MaxSessionsPerUser               int      // 每个用户同时有效的 session 数量上限，默认为 0 不限制
+	SessionLimitPolicy               string   // 超过 MaxSessionsPerUser 时的处理方式，可选： evictOldest 注销最早的 session 、 reject 拒绝登录，默认为 evictOldest
+	ImpersonationSessionLength       int      // 使用 Master Key 以用户身份创建的 session 的最长有效期，单位为秒，取值大于 0 ，默认为 3600 秒
+	UsernameNormalization            []string // 写入与查询用户名时的规范化方式，多个使用 | 分隔，可选： trim 去除首尾空白、 nfc 转换为 Unicode NFC 形式、 lowercase 转换为小写，如： trim|nfc|lowercase ，默认为空不处理
+	EmailNormalization               []string // 写入与查询邮箱时的规范化方式，取值与 UsernameNormalization 相同，默认为空不处理
+	BlockDisposableEmails            bool     // 注册时是否检查一次性邮箱，使用内置的域名列表与 DisposableEmailDomains ，默认为 false 不检查
+	DisposableEmailDomains           []string // 额外的一次性邮箱域名，包括其子域名，多个使用 | 分隔
+	SignupEmailAllowlist             []string // 允许注册的邮箱域名，包括其子域名，多个使用 | 分隔，配置之后其他域名不允许注册，列表中的域名不再做其他检查，默认为空不限制
+	SignupEmailDenylist              []string // 禁止注册的邮箱域名，包括其子域名，多个使用 | 分隔，默认为空
+	SignupValidationPolicy           string   // 注册邮箱不符合规则时的处理方式，可选： reject 拒绝注册、 flag 允许注册并记录在用户的 signupFlags 字段中，默认为 reject
+	CaptchaProvider                  string   // 人机验证服务商，可选： hCaptcha、reCAPTCHA 以及通过 captcha.RegisterProvider 注册的名称，默认为空不校验，客户端通过请求头 X-Parse-Captcha-Token 提交 token
+	CaptchaSecret                    string   // 人机验证服务商的密钥， CaptchaProvider=hCaptcha 或者 reCAPTCHA 时必填
+	CaptchaRoutes                    []string // 需要人机验证的接口，多个使用 | 分隔，可选： signup 创建用户、 passwordReset 请求重置密码，默认为 signup|passwordReset ，使用 Master Key 时不校验
+	CaptchaMinScore                  float64  // reCAPTCHA v3 返回的分数的最低值，取值范围： 0-1 ，默认为 0 不检查分数
+	UniformObjectNotFound            bool     // 客户端按 objectId 读取、修改、删除对象时，对象不存在与无权访问是否返回相同的错误，默认为 false
+	ObjectNotFoundJitter             int      // 开启 UniformObjectNotFound 时返回错误之前随机等待的最长时间，单位为毫秒，取值大于等于 0 ，默认为 100 ， 0 表示不等待
 	PreventLoginWithUnverifiedEmail  bool     // 是否阻止未验证邮箱的用户登录，默认为 false 不阻止
 	CacheAdapter                     string   // 缓存模块，可选： InMemory、Redis、Null， 默认为 InMemory 使用内存做缓存模块
 	RedisAddress                     string   // Redis 地址， CacheAdapter=Redis 时必填
 	RedisPassword                    string   // Redis 密码，选填
 	SchemaCacheTTL                   int      // Schema 缓存有效期，单位为秒。取值： -1 表示永不过期，0 表示使用 CacheAdapter 自身的有效期，或者大于 0 ，默认为 5 秒
+	SchemaCacheStaleTTL              int      // Schema 缓存失效后继续使用旧数据的时间，单位为秒，期间请求直接返回旧数据并在后台重新加载，取值大于等于 0 ，默认为 0 表示不使用旧数据
+	SchemaCacheMaxEntries            int      // 单独缓存的类的最大数量，超过时删除最早写入的类，取值大于等于 0 ，默认为 0 表示不限制
+	SchemaCacheClassTTLs             []string // 单个类的 Schema 缓存有效期，格式为 类名:秒数 ，多个使用 | 分隔，取值与 SchemaCacheTTL 相同，如： Post:60|Log:-1
 	EnableSingleSchemaCache          bool     // 是否允许缓存唯一一份 SchemaCache ，默认为 false 不允许
+	ClassCacheTTL                    int      // 数据库适配器内部 _SCHEMA 缓存的有效期，单位为秒，取值大于等于 0 ，默认为 5 秒， 0 表示不缓存。修改类结构时立即失效
+	UserCacheTTL                     int      // 用户及角色缓存有效期，单位为秒，取值大于等于 0 ，默认为 0 表示使用 CacheAdapter 自身的有效期
+	ObjectCacheTTL                   int      // 对象缓存有效期，单位为秒，取值大于等于 0 ，默认为 0 表示不启用对象缓存。缓存模块与 CacheAdapter 一致
+	SchemaCacheWarmUp                bool     // 是否在启动时预加载所有 Schema ，默认为 false 不预加载
+	SchemaCacheRefreshInterval       int      // 后台刷新 Schema 缓存的间隔，单位为秒，取值大于等于 0 ，默认为 0 表示不在后台刷新
+	TTLSweepInterval                 int      // 后台删除过期对象的间隔，单位为秒，取值大于等于 0 ，默认为 60 ， 0 表示不删除，数据库支持 TTL 索引时不使用
+	TTLFilterExpiredObjects          bool     // 查询时是否排除已过期但尚未被删除的对象，默认为 false 不排除
+	ReloadOnSignal                   bool     // 是否在收到 SIGHUP 时重新加载配置，默认为 false 不处理信号，嵌入运行时由应用自己决定是否调用 config.Reload
 	WebhookKey                       string   // 用于云代码鉴权
+	WebhookSigningSecret             string   // 云代码请求的 HMAC-SHA256 签名密钥，为空时不签名
+	WebhookTimeout                   int      // 云代码请求超时时间，单位为秒，取值大于 0 ，默认为 30 秒
+	WebhookInsecureSkipVerify        bool     // 是否跳过云代码 HTTPS 证书校验，默认为 false 校验证书
+	WebhookMaxRetries                int      // 云代码请求失败后的最大重试次数，取值范围： 0-10 ，默认为 2 次
+	WebhookRetryBackoff              int      // 云代码请求首次重试的等待时间，单位为毫秒，之后每次翻倍，默认为 200 毫秒
+	WebhookDeadLetterClassName       string   // 重试后仍然失败的云代码请求记录到该表中，为空时不记录，默认为 HookDeadLetter
+	WebhookMaxPayloadSize            int      // 云代码请求体的最大长度，单位为字节，超过时不发送请求并返回错误，默认为 1048576 ， 0 表示不限制
 	EnableAccountLockout             bool     // 是否启用账户锁定规则，默认为 false 不启用
 	AccountLockoutThreshold          int      // 锁定账户需要的登录失败次数，取值范围： 1-999 ，默认为 3 次
 	AccountLockoutDuration           int      // 锁定账户时长，单位为分钟，取值范围： 1-99999 ，默认为 10 分钟
+	LoginThrottle                    bool     // 是否按 IP 与用户名限制登录失败次数，默认为 false 不启用
+	LoginThrottleStore               string   // 失败次数的存储，可选： InMemory、Redis ，默认为 InMemory ，多实例部署时需要使用 Redis ，使用 RedisAddress 与 RedisPassword 连接
+	LoginThrottleIPThreshold         int      // 同一 IP 在时间窗口内允许的登录失败次数，默认为 20 次， 0 表示不限制
+	LoginThrottleUsernameThreshold   int      // 同一用户名在时间窗口内允许的登录失败次数，默认为 5 次， 0 表示不限制
+	LoginThrottleWindow              int      // 失败次数的时间窗口，从第一次失败开始计算，单位为秒，默认为 900 秒
+	EnableQuota                      bool     // 是否统计应用与各个类的请求次数与传输字节数，默认为 false 不统计
+	QuotaStore                       string   // 统计数据的存储，可选： InMemory、Redis ，默认为 InMemory ，多实例部署时需要使用 Redis ，使用 RedisAddress 与 RedisPassword 连接
+	DailyRequestQuota                int      // 应用每天允许的请求次数，默认为 0 不限制，按 UTC 时间计算
+	MonthlyRequestQuota              int      // 应用每月允许的请求次数，默认为 0 不限制
+	DailyBytesQuota                  int      // 应用每天允许传输的字节数，包括请求与返回的数据，默认为 0 不限制
+	MonthlyBytesQuota                int      // 应用每月允许传输的字节数，默认为 0 不限制
+	QuotaErrorCode                   int      // 超过配额时返回的错误码，默认为 155 RequestLimitExceeded
+	QuotaErrorMessage                string   // 超过配额时返回的错误信息，默认为 Request quota exceeded.
 	PasswordPolicy                   bool     // 是否启用密码规则，默认为 false 不启用
 	ResetTokenValidityDuration       int      // 密码重置验证 Token 有效期，单位为秒，取值大于等于 0 ，默认为 0 表示不设置 Token 有效期
 	ValidatorPattern                 string   // 校验密码规则的正则表达式
@@ -69,7 +208,12 @@ type Config struct {
 	MaxPasswordAge                   int      // 密码的最长使用时间，单位为天，取值大于等于 0 ，默认为 0 表示不设置最长使用时间
 	MaxPasswordHistory               int      // 最大密码历史个数，修改的密码不能与密码历史重复，取值范围： 0-20 ，默认为 0 表示不设置密码历史
 	UserSensitiveFields              []string // 用户敏感字段，按需删除，多个字段使用 | 删除，如： email|password
-	AnalyticsAdapter                 string   // 分析模块，可选：InfluxDB，默认使用空的分析模块
+	DataExportLimit                  int      // 用户在 DataExportWindow 时间内允许导出个人数据的次数，使用 Master Key 时不限制，默认为 1 ， 0 表示不限制，计数保存在内存中
+	DataExportWindow                 int      // 导出个人数据次数的时间窗口，单位为秒，默认为 86400
+	ErasureReferenceActions          []string // 删除用户数据时对指向该用户的 Pointer 字段的处理方式，格式为 类名.字段名:处理方式 ，多个使用 | 分隔，处理方式可选： unset 删除字段值、 delete 删除对象、 keep 保留，如： Post.author:unset|Like.user:delete ，未配置的字段删除用户时为 unset ，匿名化时为 keep
+	AnalyticsAdapter                 string   // 分析模块，可选：InfluxDB、Database、Webhook，默认使用空的分析模块
+	AnalyticsClassName               string   // 保存统计事件的类名，仅在 AnalyticsAdapter=Database 时使用，默认为 AnalyticsEvent
+	AnalyticsWebhookURL              string   // 接收统计事件的地址，仅在 AnalyticsAdapter=Webhook 时需要配置
 	InfluxDBURL                      string   // InfluxDB 地址，仅在 AnalyticsAdapter=InfluxDB 时需要配置
 	InfluxDBUsername                 string   // InfluxDB 用户名，仅在 AnalyticsAdapter=InfluxDB 时需要配置
 	InfluxDBPassword                 string   // InfluxDB 密码，仅在 AnalyticsAdapter=InfluxDB 时需要配置
@@ -82,9 +226,24 @@ type Config struct {
 	ChoosePassword                   string   // 自定义页面地址，修改密码页面
 	PasswordResetSuccess             string   // 自定义页面地址，密码重置成功页面
 	ParseFrameURL                    string   // 自定义页面地址，用于呈现验证 Email 页面和密码重置页面
+	TemplatesPath                    string   // 自定义页面与邮件模板的位置，为本地目录，或者以 files: 开头表示从文件存储模块读取，如 files:templates- 读取文件名为 templates-verify_email.html 等的文件，默认为空使用内置页面
+	TemplatesCacheTTL                int      // 自定义模板的缓存时间，单位为秒，取值大于等于 0 ，默认为 60 ， 0 表示每次重新读取
 	FCMServerKey                     string   // FCM Server Key
 }
 
+// SSO OIDC 单点登录服务商，在 app.conf 的 [sso.名称] 节中配置
+type SSO struct {
+	Name         string   // 服务商名称，用于接口地址 /sso/名称/login ，只能包含字母、数字、下划线与横线
+	Issuer       string   // OIDC Issuer ，通过 Issuer/.well-known/openid-configuration 获取授权、Token 与公钥地址，必填
+	ClientID     string   // 必填
+	ClientSecret string   // 必填
+	Scopes       []string // 请求的 scope ，多个使用空格分隔，默认为 openid email profile
+	RoleClaim    string   // 包含用户组的声明名称，如 groups ，为空时不同步角色
+	RoleMapping  []string // 声明值与角色的对应关系，格式为 声明值:角色名 ，多个使用 | 分隔，每次登录时同步角色
+	RedirectURLs []string // 允许登录之后跳转的地址前缀，多个使用 | 分隔，为空时只返回 JSON
+	LinkByEmail  bool     // 服务商确认邮箱已验证时，是否关联邮箱相同的已有用户，默认为 false
+}
+
 var (
 	// TConfig ...
 	TConfig *Config
@@ -102,49 +261,224 @@ func init() {
 func parseConfig() {
 	TConfig.AppName = beego.AppConfig.String("appname")
 	TConfig.ServerURL = beego.AppConfig.String("ServerURL")
+	TConfig.LogLevel = beego.AppConfig.DefaultString("LogLevel", "debug")
 	TConfig.DatabaseType = beego.AppConfig.String("DatabaseType")
 	TConfig.DatabaseURI = beego.AppConfig.String("DatabaseURI")
+	TConfig.PostgresStmtCacheSize = beego.AppConfig.DefaultInt("PostgresStmtCacheSize", 200)
+	TConfig.PostgresRowLevelSecurity = beego.AppConfig.DefaultBool("PostgresRowLevelSecurity", false)
+	TConfig.PostgresReadReplicaURIs = beego.AppConfig.String("PostgresReadReplicaURIs")
+	TConfig.PostgresReplicaMaxLag = beego.AppConfig.DefaultInt("PostgresReplicaMaxLag", 0)
+	TConfig.MongoConnectTimeout = beego.AppConfig.DefaultInt("MongoConnectTimeout", 10)
+	TConfig.MongoSocketTimeout = beego.AppConfig.DefaultInt("MongoSocketTimeout", 60)
+	TConfig.MongoPoolLimit = beego.AppConfig.DefaultInt("MongoPoolLimit", 0)
+	TConfig.MongoReadPreference = beego.AppConfig.DefaultString("MongoReadPreference", "primary")
+	TConfig.MongoReadConcern = beego.AppConfig.String("MongoReadConcern")
+	TConfig.MongoWriteConcern = beego.AppConfig.String("MongoWriteConcern")
+	TConfig.MongoWriteConcernTimeout = beego.AppConfig.DefaultInt("MongoWriteConcernTimeout", 0)
+	TConfig.MongoJournal = beego.AppConfig.DefaultBool("MongoJournal", false)
+	TConfig.MongoRetryWrites = beego.AppConfig.DefaultBool("MongoRetryWrites", true)
+	TConfig.MongoRetryReads = beego.AppConfig.DefaultBool("MongoRetryReads", true)
+	TConfig.MaxQueryResultRows = beego.AppConfig.DefaultInt("MaxQueryResultRows", 0)
+	TConfig.MaxQueryResultBytes = beego.AppConfig.DefaultInt("MaxQueryResultBytes", 0)
+	TConfig.SlowQueryThreshold = beego.AppConfig.DefaultInt("SlowQueryThreshold", 0)
+	TConfig.SlowQueryLogSize = beego.AppConfig.DefaultInt("SlowQueryLogSize", 1000)
+	TConfig.SlowQueryAutoIndex = beego.AppConfig.DefaultBool("SlowQueryAutoIndex", false)
+	TConfig.SlowQueryAutoIndexMinCount = beego.AppConfig.DefaultInt("SlowQueryAutoIndexMinCount", 10)
+	TConfig.MaxObjectNestingDepth = beego.AppConfig.DefaultInt("MaxObjectNestingDepth", 0)
+	TConfig.MaxObjectKeyLength = beego.AppConfig.DefaultInt("MaxObjectKeyLength", 0)
+	TConfig.NormalizeObjectKeys = beego.AppConfig.DefaultBool("NormalizeObjectKeys", false)
+	TConfig.DefaultLocales = nil
+	for _, locale := range strings.Split(beego.AppConfig.String("DefaultLocales"), "|") {
+		if locale = strings.TrimSpace(locale); locale != "" {
+			TConfig.DefaultLocales = append(TConfig.DefaultLocales, locale)
+		}
+	}
 	TConfig.AppID = beego.AppConfig.String("AppID")
 	TConfig.MasterKey = beego.AppConfig.String("MasterKey")
+	TConfig.AppKeys = splitAppKeys(beego.AppConfig.String("AppKeys"))
+	TConfig.MasterKeyIPs = nil
+	for _, ip := range strings.Split(beego.AppConfig.String("MasterKeyIPs"), "|") {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			TConfig.MasterKeyIPs = append(TConfig.MasterKeyIPs, ip)
+		}
+	}
+	TConfig.TrustedProxies = splitList(beego.AppConfig.String("TrustedProxies"))
 	TConfig.ClientKey = beego.AppConfig.String("ClientKey")
 	TConfig.JavaScriptKey = beego.AppConfig.String("JavaScriptKey")
 	TConfig.DotNetKey = beego.AppConfig.String("DotNetKey")
 	TConfig.RestAPIKey = beego.AppConfig.String("RestAPIKey")
 	TConfig.AllowClientClassCreation = beego.AppConfig.DefaultBool("AllowClientClassCreation", false)
+	TConfig.DefaultClassLevelPermissions = beego.AppConfig.DefaultString("DefaultClassLevelPermissions", "public")
 	TConfig.EnableAnonymousUsers = beego.AppConfig.DefaultBool("EnableAnonymousUsers", true)
 	TConfig.VerifyUserEmails = beego.AppConfig.DefaultBool("VerifyUserEmails", false)
 	TConfig.FileAdapter = beego.AppConfig.DefaultString("FileAdapter", "Disk")
 	TConfig.PushAdapter = beego.AppConfig.DefaultString("PushAdapter", "talisman")
 	TConfig.MailAdapter = beego.AppConfig.DefaultString("MailAdapter", "smtp")
+	TConfig.MailFrom = beego.AppConfig.String("MailFrom")
+	TConfig.MailTemplates = nil
+	for _, t := range strings.Split(beego.AppConfig.String("MailTemplates"), "|") {
+		if t = strings.TrimSpace(t); t != "" {
+			TConfig.MailTemplates = append(TConfig.MailTemplates, t)
+		}
+	}
+	TConfig.MailSandbox = beego.AppConfig.DefaultBool("MailSandbox", false)
+	TConfig.MailEventsToken = beego.AppConfig.String("MailEventsToken")
+	TConfig.SendGridAPIKey = beego.AppConfig.String("SendGridAPIKey")
+	TConfig.MailgunDomain = beego.AppConfig.String("MailgunDomain")
+	TConfig.MailgunAPIKey = beego.AppConfig.String("MailgunAPIKey")
+	TConfig.MailgunAPIURL = beego.AppConfig.DefaultString("MailgunAPIURL", "https://api.mailgun.net")
+	TConfig.MailgunWebhookSigningKey = beego.AppConfig.String("MailgunWebhookSigningKey")
+	TConfig.PhoneAuth = beego.AppConfig.DefaultBool("PhoneAuth", false)
+	TConfig.SMSAdapter = beego.AppConfig.DefaultString("SMSAdapter", "Twilio")
+	TConfig.TwilioAccountSID = beego.AppConfig.String("TwilioAccountSID")
+	TConfig.TwilioAuthToken = beego.AppConfig.String("TwilioAuthToken")
+	TConfig.TwilioFrom = beego.AppConfig.String("TwilioFrom")
+	TConfig.PhoneCodeLength = beego.AppConfig.DefaultInt("PhoneCodeLength", 6)
+	TConfig.PhoneCodeValidityDuration = beego.AppConfig.DefaultInt("PhoneCodeValidityDuration", 300)
+	TConfig.PhoneCodeMaxAttempts = beego.AppConfig.DefaultInt("PhoneCodeMaxAttempts", 5)
+	TConfig.PhoneCodeResendInterval = beego.AppConfig.DefaultInt("PhoneCodeResendInterval", 60)
+	TConfig.LDAPAuth = beego.AppConfig.DefaultBool("LDAPAuth", false)
+	TConfig.LDAPURL = beego.AppConfig.String("LDAPURL")
+	TConfig.LDAPStartTLS = beego.AppConfig.DefaultBool("LDAPStartTLS", false)
+	TConfig.LDAPBindDN = beego.AppConfig.String("LDAPBindDN")
+	TConfig.LDAPBindPassword = beego.AppConfig.String("LDAPBindPassword")
+	TConfig.LDAPBaseDN = beego.AppConfig.String("LDAPBaseDN")
+	TConfig.LDAPUserFilter = beego.AppConfig.DefaultString("LDAPUserFilter", "(uid=%s)")
+	TConfig.LDAPEmailAttribute = beego.AppConfig.DefaultString("LDAPEmailAttribute", "mail")
+	TConfig.LDAPGroupBaseDN = beego.AppConfig.String("LDAPGroupBaseDN")
+	TConfig.LDAPGroupFilter = beego.AppConfig.DefaultString("LDAPGroupFilter", "(member=%s)")
+	TConfig.LDAPGroupRoles = nil
+	for _, r := range strings.Split(beego.AppConfig.String("LDAPGroupRoles"), "|") {
+		if r = strings.TrimSpace(r); r != "" {
+			TConfig.LDAPGroupRoles = append(TConfig.LDAPGroupRoles, r)
+		}
+	}
+	TConfig.SSOProviders = nil
+	for _, name := range strings.Split(beego.AppConfig.String("SSOProviders"), "|") {
+		if name = strings.TrimSpace(name); name != "" {
+			TConfig.SSOProviders = append(TConfig.SSOProviders, parseSSOProvider(name))
+		}
+	}
 
 	// LiveQueryClasses 支持的类列表，格式： classeA|classeB|classeC
 	TConfig.LiveQueryClasses = beego.AppConfig.String("LiveQueryClasses")
 	TConfig.PublisherType = beego.AppConfig.String("PublisherType")
 	TConfig.PublisherURL = beego.AppConfig.String("PublisherURL")
 	TConfig.PublisherConfig = beego.AppConfig.String("PublisherConfig")
+	TConfig.LiveQueryPingInterval = beego.AppConfig.DefaultInt("LiveQueryPingInterval", 30)
+	TConfig.LiveQueryPingTimeout = beego.AppConfig.DefaultInt("LiveQueryPingTimeout", 0)
+	TConfig.LiveQueryMaxConnections = beego.AppConfig.DefaultInt("LiveQueryMaxConnections", 0)
+	TConfig.LiveQueryResumeWindow = beego.AppConfig.DefaultInt("LiveQueryResumeWindow", 30)
+	TConfig.LiveQueryEnableSSE = beego.AppConfig.DefaultBool("LiveQueryEnableSSE", false)
+	TConfig.LiveQueryEventSource = beego.AppConfig.DefaultString("LiveQueryEventSource", "Controller")
+	TConfig.LiveQueryClassPrefix = beego.AppConfig.String("LiveQueryClassPrefix")
 
 	TConfig.SessionLength = beego.AppConfig.DefaultInt("SessionLength", 31536000)
 	TConfig.RevokeSessionOnPasswordReset = beego.AppConfig.DefaultBool("RevokeSessionOnPasswordReset", true)
+	TConfig.SessionTokenType = beego.AppConfig.DefaultString("SessionTokenType", "Random")
+	if keys := beego.AppConfig.String("JWTSigningKeys"); keys != "" {
+		TConfig.JWTSigningKeys = strings.Split(keys, "|")
+	}
+	TConfig.JWTTTL = beego.AppConfig.DefaultInt("JWTTTL", 0)
+	TConfig.JWTRevocationCheck = beego.AppConfig.DefaultBool("JWTRevocationCheck", true)
+	TConfig.RefreshTokens = beego.AppConfig.DefaultBool("RefreshTokens", false)
+	TConfig.AccessTokenLength = beego.AppConfig.DefaultInt("AccessTokenLength", 3600)
+	TConfig.RefreshTokenLength = beego.AppConfig.DefaultInt("RefreshTokenLength", 2592000)
+	TConfig.SessionActivityInterval = beego.AppConfig.DefaultInt("SessionActivityInterval", 300)
+	TConfig.MaxSessionsPerUser = beego.AppConfig.DefaultInt("MaxSessionsPerUser", 0)
+	TConfig.SessionLimitPolicy = beego.AppConfig.DefaultString("SessionLimitPolicy", "evictOldest")
+	TConfig.ImpersonationSessionLength = beego.AppConfig.DefaultInt("ImpersonationSessionLength", 3600)
+	TConfig.UsernameNormalization = splitList(beego.AppConfig.String("UsernameNormalization"))
+	TConfig.EmailNormalization = splitList(beego.AppConfig.String("EmailNormalization"))
+	TConfig.BlockDisposableEmails = beego.AppConfig.DefaultBool("BlockDisposableEmails", false)
+	TConfig.DisposableEmailDomains = splitList(beego.AppConfig.String("DisposableEmailDomains"))
+	TConfig.SignupEmailAllowlist = splitList(beego.AppConfig.String("SignupEmailAllowlist"))
+	TConfig.SignupEmailDenylist = splitList(beego.AppConfig.String("SignupEmailDenylist"))
+	TConfig.SignupValidationPolicy = beego.AppConfig.DefaultString("SignupValidationPolicy", "reject")
+	TConfig.CaptchaProvider = beego.AppConfig.String("CaptchaProvider")
+	TConfig.CaptchaSecret = beego.AppConfig.String("CaptchaSecret")
+	TConfig.CaptchaRoutes = splitList(beego.AppConfig.DefaultString("CaptchaRoutes", "signup|passwordReset"))
+	TConfig.CaptchaMinScore = beego.AppConfig.DefaultFloat("CaptchaMinScore", 0)
+	TConfig.UniformObjectNotFound = beego.AppConfig.DefaultBool("UniformObjectNotFound", false)
+	TConfig.ObjectNotFoundJitter = beego.AppConfig.DefaultInt("ObjectNotFoundJitter", 100)
 	TConfig.PreventLoginWithUnverifiedEmail = beego.AppConfig.DefaultBool("PreventLoginWithUnverifiedEmail", false)
 	TConfig.EmailVerifyTokenValidityDuration = beego.AppConfig.DefaultInt("EmailVerifyTokenValidityDuration", 0)
 	TConfig.SchemaCacheTTL = beego.AppConfig.DefaultInt("SchemaCacheTTL", 5)
+	TConfig.SchemaCacheStaleTTL = beego.AppConfig.DefaultInt("SchemaCacheStaleTTL", 0)
+	TConfig.SchemaCacheMaxEntries = beego.AppConfig.DefaultInt("SchemaCacheMaxEntries", 0)
+	TConfig.SchemaCacheClassTTLs = nil
+	for _, classTTL := range strings.Split(beego.AppConfig.String("SchemaCacheClassTTLs"), "|") {
+		if classTTL != "" {
+			TConfig.SchemaCacheClassTTLs = append(TConfig.SchemaCacheClassTTLs, classTTL)
+		}
+	}
 
 	TConfig.SMTPServer = beego.AppConfig.String("SMTPServer")
 	TConfig.MailUsername = beego.AppConfig.String("MailUsername")
 	TConfig.MailPassword = beego.AppConfig.String("MailPassword")
 	TConfig.WebhookKey = beego.AppConfig.String("WebhookKey")
+	TConfig.WebhookSigningSecret = beego.AppConfig.String("WebhookSigningSecret")
+	TConfig.WebhookTimeout = beego.AppConfig.DefaultInt("WebhookTimeout", 30)
+	TConfig.WebhookInsecureSkipVerify = beego.AppConfig.DefaultBool("WebhookInsecureSkipVerify", false)
+	TConfig.WebhookMaxRetries = beego.AppConfig.DefaultInt("WebhookMaxRetries", 2)
+	TConfig.WebhookRetryBackoff = beego.AppConfig.DefaultInt("WebhookRetryBackoff", 200)
+	TConfig.WebhookDeadLetterClassName = beego.AppConfig.DefaultString("WebhookDeadLetterClassName", "HookDeadLetter")
+	TConfig.WebhookMaxPayloadSize = beego.AppConfig.DefaultInt("WebhookMaxPayloadSize", 1048576)
 
 	TConfig.EnableAccountLockout = beego.AppConfig.DefaultBool("EnableAccountLockout", false)
 	TConfig.AccountLockoutThreshold = beego.AppConfig.DefaultInt("AccountLockoutThreshold", 3)
 	TConfig.AccountLockoutDuration = beego.AppConfig.DefaultInt("AccountLockoutDuration", 10)
 
+	TConfig.LoginThrottle = beego.AppConfig.DefaultBool("LoginThrottle", false)
+	TConfig.LoginThrottleStore = beego.AppConfig.DefaultString("LoginThrottleStore", "InMemory")
+	TConfig.LoginThrottleIPThreshold = beego.AppConfig.DefaultInt("LoginThrottleIPThreshold", 20)
+	TConfig.LoginThrottleUsernameThreshold = beego.AppConfig.DefaultInt("LoginThrottleUsernameThreshold", 5)
+	TConfig.LoginThrottleWindow = beego.AppConfig.DefaultInt("LoginThrottleWindow", 900)
+
+	TConfig.EnableQuota = beego.AppConfig.DefaultBool("EnableQuota", false)
+	TConfig.QuotaStore = beego.AppConfig.DefaultString("QuotaStore", "InMemory")
+	TConfig.DailyRequestQuota = beego.AppConfig.DefaultInt("DailyRequestQuota", 0)
+	TConfig.MonthlyRequestQuota = beego.AppConfig.DefaultInt("MonthlyRequestQuota", 0)
+	TConfig.DailyBytesQuota = beego.AppConfig.DefaultInt("DailyBytesQuota", 0)
+	TConfig.MonthlyBytesQuota = beego.AppConfig.DefaultInt("MonthlyBytesQuota", 0)
+	TConfig.QuotaErrorCode = beego.AppConfig.DefaultInt("QuotaErrorCode", 155)
+	TConfig.QuotaErrorMessage = beego.AppConfig.DefaultString("QuotaErrorMessage", "Request quota exceeded.")
+
 	TConfig.CacheAdapter = beego.AppConfig.DefaultString("CacheAdapter", "InMemory")
 	TConfig.RedisAddress = beego.AppConfig.String("RedisAddress")
 	TConfig.RedisPassword = beego.AppConfig.String("RedisPassword")
 
 	TConfig.EnableSingleSchemaCache = beego.AppConfig.DefaultBool("EnableSingleSchemaCache", false)
+	TConfig.ClassCacheTTL = beego.AppConfig.DefaultInt("ClassCacheTTL", 5)
+	TConfig.UserCacheTTL = beego.AppConfig.DefaultInt("UserCacheTTL", 0)
+	TConfig.ObjectCacheTTL = beego.AppConfig.DefaultInt("ObjectCacheTTL", 0)
+	TConfig.SchemaCacheWarmUp = beego.AppConfig.DefaultBool("SchemaCacheWarmUp", false)
+	TConfig.SchemaCacheRefreshInterval = beego.AppConfig.DefaultInt("SchemaCacheRefreshInterval", 0)
+	TConfig.TTLSweepInterval = beego.AppConfig.DefaultInt("TTLSweepInterval", 60)
+	TConfig.TTLFilterExpiredObjects = beego.AppConfig.DefaultBool("TTLFilterExpiredObjects", false)
+	TConfig.ReloadOnSignal = beego.AppConfig.DefaultBool("ReloadOnSignal", false)
 
 	TConfig.FileDirectAccess = beego.AppConfig.DefaultBool("FileDirectAccess", true)
+	TConfig.FileMetadata = beego.AppConfig.DefaultBool("FileMetadata", false)
+	TConfig.FileCacheControl = beego.AppConfig.String("FileCacheControl")
+	TConfig.FileOriginToken = beego.AppConfig.String("FileOriginToken")
+	TConfig.FileOriginTokenHeader = beego.AppConfig.DefaultString("FileOriginTokenHeader", "X-Origin-Token")
+	TConfig.ImageTransform = beego.AppConfig.DefaultBool("ImageTransform", false)
+	TConfig.ImageSigningKey = beego.AppConfig.String("ImageSigningKey")
+	TConfig.ImageMaxDimension = beego.AppConfig.DefaultInt("ImageMaxDimension", 4096)
+	TConfig.ImageCacheAdapter = beego.AppConfig.DefaultString("ImageCacheAdapter", "Disk")
+	TConfig.ImageCacheTTL = beego.AppConfig.DefaultInt("ImageCacheTTL", 86400)
+	TConfig.FileMaxSize = beego.AppConfig.DefaultInt("FileMaxSize", 0)
+	TConfig.FileAllowedTypes = nil
+	for _, t := range strings.Split(beego.AppConfig.String("FileAllowedTypes"), "|") {
+		if t = strings.TrimSpace(t); t != "" {
+			TConfig.FileAllowedTypes = append(TConfig.FileAllowedTypes, strings.ToLower(t))
+		}
+	}
+	TConfig.FileScanAdapter = beego.AppConfig.String("FileScanAdapter")
+	TConfig.FileScanAddress = beego.AppConfig.String("FileScanAddress")
+	TConfig.FileScanTimeout = beego.AppConfig.DefaultInt("FileScanTimeout", 30)
+	TConfig.FileScanAction = beego.AppConfig.DefaultString("FileScanAction", "Reject")
 
 	TConfig.SinaBucket = beego.AppConfig.String("SinaBucket")
 	TConfig.SinaDomain = beego.AppConfig.String("SinaDomain")
@@ -167,17 +501,33 @@ func parseConfig() {
 		TConfig.UserSensitiveFields = append(TConfig.UserSensitiveFields, field)
 	}
 
+	TConfig.DataExportLimit = beego.AppConfig.DefaultInt("DataExportLimit", 1)
+	TConfig.DataExportWindow = beego.AppConfig.DefaultInt("DataExportWindow", 86400)
+	TConfig.ErasureReferenceActions = nil
+	for _, action := range strings.Split(beego.AppConfig.String("ErasureReferenceActions"), "|") {
+		if action != "" {
+			TConfig.ErasureReferenceActions = append(TConfig.ErasureReferenceActions, action)
+		}
+	}
+
 	TConfig.AnalyticsAdapter = beego.AppConfig.String("AnalyticsAdapter")
+	TConfig.AnalyticsClassName = beego.AppConfig.DefaultString("AnalyticsClassName", "AnalyticsEvent")
+	TConfig.AnalyticsWebhookURL = beego.AppConfig.String("AnalyticsWebhookURL")
 	TConfig.InfluxDBURL = beego.AppConfig.String("InfluxDBURL")
 	TConfig.InfluxDBUsername = beego.AppConfig.String("InfluxDBUsername")
 	TConfig.InfluxDBPassword = beego.AppConfig.String("InfluxDBPassword")
 	TConfig.InfluxDBDatabaseName = beego.AppConfig.String("InfluxDBDatabaseName")
 
 	TConfig.InvalidLink = beego.AppConfig.String("InvalidLink")
+	TConfig.InvalidVerificationLink = beego.AppConfig.String("InvalidVerificationLink")
+	TConfig.LinkSendSuccess = beego.AppConfig.String("LinkSendSuccess")
+	TConfig.LinkSendFail = beego.AppConfig.String("LinkSendFail")
 	TConfig.VerifyEmailSuccess = beego.AppConfig.String("VerifyEmailSuccess")
 	TConfig.ChoosePassword = beego.AppConfig.String("ChoosePassword")
 	TConfig.PasswordResetSuccess = beego.AppConfig.String("PasswordResetSuccess")
 	TConfig.ParseFrameURL = beego.AppConfig.String("ParseFrameURL")
+	TConfig.TemplatesPath = beego.AppConfig.String("TemplatesPath")
+	TConfig.TemplatesCacheTTL = beego.AppConfig.DefaultInt("TemplatesCacheTTL", 60)
 
 	TConfig.PushChannel = beego.AppConfig.String("PushChannel")
 	TConfig.PushBatchSize = beego.AppConfig.DefaultInt("PushBatchSize", 0)
@@ -186,41 +536,180 @@ func parseConfig() {
 	TConfig.FCMServerKey = beego.AppConfig.String("FCMServerKey")
 }
 
-// Validate 校验用户参数合法性
+// parseSSOProvider 读取 [sso.名称] 节中的服务商参数
+func parseSSOProvider(name string) SSO {
+	section := "sso." + name + "::"
+	p := SSO{
+		Name:         name,
+		Issuer:       strings.TrimRight(beego.AppConfig.String(section+"Issuer"), "/"),
+		ClientID:     beego.AppConfig.String(section + "ClientID"),
+		ClientSecret: beego.AppConfig.String(section + "ClientSecret"),
+		Scopes:       strings.Fields(beego.AppConfig.DefaultString(section+"Scopes", "openid email profile")),
+		RoleClaim:    beego.AppConfig.String(section + "RoleClaim"),
+		LinkByEmail:  beego.AppConfig.DefaultBool(section+"LinkByEmail", false),
+	}
+	for _, r := range strings.Split(beego.AppConfig.String(section+"RoleMapping"), "|") {
+		if r = strings.TrimSpace(r); r != "" {
+			p.RoleMapping = append(p.RoleMapping, r)
+		}
+	}
+	for _, u := range strings.Split(beego.AppConfig.String(section+"RedirectURLs"), "|") {
+		if u = strings.TrimSpace(u); u != "" {
+			p.RedirectURLs = append(p.RedirectURLs, u)
+		}
+	}
+	return p
+}
+
+// Validate 校验用户参数合法性，参数不合法时退出
 func Validate() {
-	validateApplicationConfiguration()
-	validateFileConfiguration()
-	validatePushConfiguration()
-	validateMailConfiguration()
-	validateLiveQueryConfiguration()
-	validateSessionConfiguration()
-	validateAccountLockoutPolicy()
-	validatePasswordPolicy()
-	validateCacheConfiguration()
-	validateAnalyticsConfiguration()
+	if err := Check(); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// Check 校验用户参数合法性，返回第一个不合法的参数对应的错误
+func Check() error {
+	validators := []func() error{
+		validateApplicationConfiguration,
+		validateLogConfiguration,
+		validateDatabaseConfiguration,
+		validateFileConfiguration,
+		validatePushConfiguration,
+		validateMailConfiguration,
+		validatePhoneAuthConfiguration,
+		validateLDAPConfiguration,
+		validateSSOConfiguration,
+		validateLiveQueryConfiguration,
+		validateSessionConfiguration,
+		validateSignupConfiguration,
+		validateAccountLockoutPolicy,
+		validateLoginThrottle,
+		validateQuotaConfiguration,
+		validatePasswordPolicy,
+		validateCacheConfiguration,
+		validateAnalyticsConfiguration,
+		validateWebhookConfiguration,
+		validatePrivacyConfiguration,
+	}
+	for _, validate := range validators {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // validateApplicationConfiguration 校验应用相关参数
-func validateApplicationConfiguration() {
+func validateApplicationConfiguration() error {
 	if TConfig.AppName == "" {
-		log.Fatalln("AppName is required")
+		return errors.New("AppName is required")
 	}
 	if TConfig.ServerURL == "" {
-		log.Fatalln("ServerURL is required")
+		return errors.New("ServerURL is required")
 	}
 	if TConfig.AppID == "" {
-		log.Fatalln("AppID is required")
+		return errors.New("AppID is required")
 	}
 	if TConfig.MasterKey == "" {
-		log.Fatalln("MasterKey is required")
+		return errors.New("MasterKey is required")
+	}
+	if err := validateAppKeys(TConfig.AppKeys); err != nil {
+		return err
+	}
+	for _, ip := range TConfig.MasterKeyIPs {
+		if _, ok := parseIPNet(ip); ok == false {
+			return errors.New("MasterKeyIPs should be IP addresses or CIDR ranges, invalid: " + ip)
+		}
+	}
+	for _, ip := range TConfig.TrustedProxies {
+		if _, ok := parseIPNet(ip); ok == false {
+			return errors.New("TrustedProxies should be IP addresses or CIDR ranges, invalid: " + ip)
+		}
 	}
 	if TConfig.ClientKey == "" && TConfig.JavaScriptKey == "" && TConfig.DotNetKey == "" && TConfig.RestAPIKey == "" {
-		log.Fatalln("ClientKey or JavaScriptKey or DotNetKey or RestAPIKey is required")
+		return errors.New("ClientKey or JavaScriptKey or DotNetKey or RestAPIKey is required")
+	}
+	switch TConfig.DefaultClassLevelPermissions {
+	case "public", "authenticated", "masterKey":
+	default:
+		return errors.New("DefaultClassLevelPermissions must be one of public, authenticated, masterKey")
+	}
+	return nil
+}
+
+// validateLogConfiguration 校验日志相关参数
+func validateLogConfiguration() error {
+	if validLogLevel(TConfig.LogLevel) == false {
+		return errors.New("LogLevel must be one of error, warn, verbose, info, debug")
+	}
+	return nil
+}
+
+// validLogLevel 判断日志级别是否合法
+func validLogLevel(level string) bool {
+	switch level {
+	case "error", "warn", "verbose", "info", "debug":
+		return true
+	}
+	return false
+}
+
+// validateDatabaseConfiguration 校验数据库相关参数
+func validateDatabaseConfiguration() error {
+	if TConfig.PostgresStmtCacheSize < 0 {
+		return errors.New("PostgresStmtCacheSize should be 0 or an integer greater than 0")
+	}
+	if TConfig.PostgresRowLevelSecurity && TConfig.DatabaseType != "PostgreSQL" {
+		return errors.New("PostgresRowLevelSecurity can only be used with PostgreSQL")
+	}
+	if TConfig.PostgresReadReplicaURIs != "" && TConfig.DatabaseType != "PostgreSQL" {
+		return errors.New("PostgresReadReplicaURIs can only be used with PostgreSQL")
 	}
+	if TConfig.PostgresReplicaMaxLag < 0 {
+		return errors.New("PostgresReplicaMaxLag should be 0 or an integer greater than 0")
+	}
+	if TConfig.MongoConnectTimeout <= 0 || TConfig.MongoSocketTimeout <= 0 {
+		return errors.New("MongoConnectTimeout and MongoSocketTimeout must be values greater than 0")
+	}
+	if TConfig.MongoPoolLimit < 0 || TConfig.MongoWriteConcernTimeout < 0 {
+		return errors.New("MongoPoolLimit and MongoWriteConcernTimeout must be positive numbers")
+	}
+	if TConfig.MaxQueryResultRows < 0 || TConfig.MaxQueryResultBytes < 0 {
+		return errors.New("MaxQueryResultRows and MaxQueryResultBytes should be 0 or integers greater than 0")
+	}
+	if TConfig.SlowQueryThreshold < 0 {
+		return errors.New("SlowQueryThreshold should be 0 or an integer greater than 0")
+	}
+	if TConfig.SlowQueryThreshold > 0 && TConfig.SlowQueryLogSize <= 0 {
+		return errors.New("SlowQueryLogSize must be greater than 0")
+	}
+	if TConfig.SlowQueryAutoIndex && (TConfig.SlowQueryThreshold == 0 || TConfig.SlowQueryAutoIndexMinCount <= 0) {
+		return errors.New("SlowQueryAutoIndex requires SlowQueryThreshold and SlowQueryAutoIndexMinCount greater than 0")
+	}
+	if TConfig.MaxObjectNestingDepth < 0 || TConfig.MaxObjectKeyLength < 0 {
+		return errors.New("MaxObjectNestingDepth and MaxObjectKeyLength should be 0 or integers greater than 0")
+	}
+	switch TConfig.MongoReadPreference {
+	case "primary", "primaryPreferred", "secondary", "secondaryPreferred", "nearest", "monotonic":
+	default:
+		return errors.New("Unsupported MongoReadPreference: " + TConfig.MongoReadPreference)
+	}
+	switch TConfig.MongoReadConcern {
+	case "", "local", "available", "majority", "linearizable", "snapshot":
+	default:
+		return errors.New("Unsupported MongoReadConcern: " + TConfig.MongoReadConcern)
+	}
+	if w := TConfig.MongoWriteConcern; w != "" && w != "majority" {
+		if n, err := strconv.Atoi(w); err != nil || n < 0 {
+			return errors.New("MongoWriteConcern must be majority or an integer greater than or equal to 0")
+		}
+	}
+	return nil
 }
 
 // validateFileConfiguration 校验文件存储相关参数
-func validateFileConfiguration() {
+func validateFileConfiguration() error {
 	adapter := TConfig.FileAdapter
 	switch adapter {
 	case "", "Disk":
@@ -228,151 +717,587 @@ func validateFileConfiguration() {
 	// TODO 校验 MongoDB 配置
 	case "Sina":
 		if TConfig.SinaDomain == "" || TConfig.SinaBucket == "" || TConfig.SinaAccessKey == "" || TConfig.SinaSecretKey == "" {
-			log.Fatalln("SinaDomain, SinaBucket, SinaAccessKey, SinaSecretKey is required")
+			return errors.New("SinaDomain, SinaBucket, SinaAccessKey, SinaSecretKey is required")
 		}
 	case "Tencent":
 		if TConfig.TencentAppID == "" || TConfig.TencentBucket == "" || TConfig.TencentSecretID == "" || TConfig.TencentSecretKey == "" {
-			log.Fatalln("TencentAppID, TencentBucket, TencentSecretID, TencentSecretKey is required")
+			return errors.New("TencentAppID, TencentBucket, TencentSecretID, TencentSecretKey is required")
 		}
 	default:
-		log.Fatalln("Unsupported FileAdapter")
+		return errors.New("Unsupported FileAdapter")
 	}
+	if TConfig.ImageTransform {
+		if TConfig.ImageSigningKey == "" {
+			return errors.New("ImageSigningKey is required when ImageTransform is true")
+		}
+		if TConfig.ImageMaxDimension <= 0 {
+			return errors.New("ImageMaxDimension should be an integer greater than 0")
+		}
+		if TConfig.ImageCacheTTL < 0 {
+			return errors.New("ImageCacheTTL should be 0 or an integer greater than 0")
+		}
+		switch TConfig.ImageCacheAdapter {
+		case "", "Disk", "Null":
+		case "Redis":
+			if TConfig.RedisAddress == "" {
+				return errors.New("RedisAddress is required when ImageCacheAdapter is Redis")
+			}
+		default:
+			return errors.New("Unsupported ImageCacheAdapter: " + TConfig.ImageCacheAdapter)
+		}
+	}
+	if TConfig.FileMaxSize < 0 {
+		return errors.New("FileMaxSize should be 0 or an integer greater than 0")
+	}
+	switch TConfig.FileScanAdapter {
+	case "":
+	case "ClamAV", "Webhook":
+		if TConfig.FileScanAddress == "" {
+			return errors.New("FileScanAddress is required when FileScanAdapter is " + TConfig.FileScanAdapter)
+		}
+		if TConfig.FileScanTimeout <= 0 {
+			return errors.New("FileScanTimeout should be an integer greater than 0")
+		}
+		switch TConfig.FileScanAction {
+		case "", "Reject", "Quarantine":
+		default:
+			return errors.New("Unsupported FileScanAction: " + TConfig.FileScanAction)
+		}
+	default:
+		return errors.New("Unsupported FileScanAdapter: " + TConfig.FileScanAdapter)
+	}
+	return nil
 }
 
 // validatePushConfiguration 校验推送相关参数
-func validatePushConfiguration() {
+func validatePushConfiguration() error {
 	// TODO
+	return nil
 }
 
 // validateMailConfiguration 校验发送邮箱相关参数
-func validateMailConfiguration() {
+func validateMailConfiguration() error {
 	if TConfig.VerifyUserEmails == false {
-		return
+		return nil
 	}
 	adapter := TConfig.MailAdapter
 	switch adapter {
 	case "", "smtp":
 		if TConfig.SMTPServer == "" {
-			log.Fatalln("SMTPServer is required")
+			return errors.New("SMTPServer is required")
 		}
 		if TConfig.MailUsername == "" {
-			log.Fatalln("MailUsername is required")
+			return errors.New("MailUsername is required")
 		}
 		if TConfig.MailPassword == "" {
-			log.Fatalln("MailPassword is required")
+			return errors.New("MailPassword is required")
+		}
+	case "SendGrid":
+		if TConfig.SendGridAPIKey == "" || TConfig.MailFrom == "" {
+			return errors.New("SendGridAPIKey, MailFrom is required")
+		}
+	case "Mailgun":
+		if TConfig.MailgunDomain == "" || TConfig.MailgunAPIKey == "" || TConfig.MailFrom == "" {
+			return errors.New("MailgunDomain, MailgunAPIKey, MailFrom is required")
 		}
 	default:
-		log.Fatalln("Unsupported MailAdapter")
+		return errors.New("Unsupported MailAdapter")
 	}
 	if TConfig.EmailVerifyTokenValidityDuration < 0 {
-		log.Fatalln("Email verify token validity duration must be a value greater than 0")
+		return errors.New("Email verify token validity duration must be a value greater than 0")
+	}
+	for _, t := range TConfig.MailTemplates {
+		if p := strings.SplitN(t, ":", 2); len(p) != 2 || p[0] == "" || p[1] == "" {
+			return errors.New("Invalid MailTemplates: " + t)
+		}
+	}
+	return nil
+}
+
+// validatePhoneAuthConfiguration 校验手机号登录相关参数
+func validatePhoneAuthConfiguration() error {
+	if TConfig.PhoneAuth == false {
+		return nil
+	}
+	switch TConfig.SMSAdapter {
+	case "", "Twilio":
+		if TConfig.TwilioAccountSID == "" || TConfig.TwilioAuthToken == "" || TConfig.TwilioFrom == "" {
+			return errors.New("TwilioAccountSID, TwilioAuthToken, TwilioFrom is required")
+		}
+	default:
+		return errors.New("Unsupported SMSAdapter")
+	}
+	if TConfig.CacheAdapter == "Null" {
+		return errors.New("PhoneAuth requires CacheAdapter InMemory or Redis")
+	}
+	if TConfig.PhoneCodeLength < 4 || TConfig.PhoneCodeLength > 10 {
+		return errors.New("PhoneCodeLength should be a value between 4 and 10")
+	}
+	if TConfig.PhoneCodeValidityDuration <= 0 {
+		return errors.New("PhoneCodeValidityDuration should be a value greater than 0")
+	}
+	if TConfig.PhoneCodeMaxAttempts <= 0 {
+		return errors.New("PhoneCodeMaxAttempts should be a value greater than 0")
 	}
+	if TConfig.PhoneCodeResendInterval < 0 {
+		return errors.New("PhoneCodeResendInterval should be a value greater than or equal to 0")
+	}
+	return nil
+}
+
+// validateLDAPConfiguration 校验 LDAP 登录相关参数
+func validateLDAPConfiguration() error {
+	if TConfig.LDAPAuth == false {
+		return nil
+	}
+	if TConfig.LDAPURL == "" || TConfig.LDAPBaseDN == "" {
+		return errors.New("LDAPURL, LDAPBaseDN is required")
+	}
+	if strings.HasPrefix(TConfig.LDAPURL, "ldap://") == false && strings.HasPrefix(TConfig.LDAPURL, "ldaps://") == false {
+		return errors.New("LDAPURL should start with ldap:// or ldaps://")
+	}
+	if strings.Count(TConfig.LDAPUserFilter, "%s") != 1 {
+		return errors.New("LDAPUserFilter should contain exactly one %s")
+	}
+	if TConfig.LDAPGroupBaseDN != "" && strings.Count(TConfig.LDAPGroupFilter, "%s") != 1 {
+		return errors.New("LDAPGroupFilter should contain exactly one %s")
+	}
+	for _, r := range TConfig.LDAPGroupRoles {
+		if p := strings.SplitN(r, ":", 2); len(p) != 2 || p[0] == "" || p[1] == "" {
+			return errors.New("Invalid LDAPGroupRoles: " + r)
+		}
+	}
+	return nil
+}
+
+var ssoNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// validateSSOConfiguration 校验单点登录服务商参数
+func validateSSOConfiguration() error {
+	names := map[string]bool{}
+	for _, p := range TConfig.SSOProviders {
+		if ssoNameRegexp.MatchString(p.Name) == false || names[p.Name] {
+			return errors.New("Invalid SSOProviders: " + p.Name)
+		}
+		names[p.Name] = true
+		if p.Issuer == "" || p.ClientID == "" || p.ClientSecret == "" {
+			return errors.New("sso." + p.Name + ": Issuer, ClientID, ClientSecret is required")
+		}
+		for _, r := range p.RoleMapping {
+			if m := strings.SplitN(r, ":", 2); len(m) != 2 || m[0] == "" || m[1] == "" {
+				return errors.New("sso." + p.Name + ": Invalid RoleMapping: " + r)
+			}
+		}
+	}
+	return nil
 }
 
 // validateLiveQueryConfiguration 校验 LiveQuery 相关参数
-func validateLiveQueryConfiguration() {
+func validateLiveQueryConfiguration() error {
 	t := TConfig.PublisherType
 	switch t {
-	case "": // 默认为 EventEmitter
+	case "", "EventEmitter": // 默认为 EventEmitter ，仅支持单个实例
 	case "Redis":
 		if TConfig.PublisherURL == "" {
-			log.Fatalln("Redis PublisherURL is required")
+			return errors.New("Redis PublisherURL is required")
 		}
 	default:
-		log.Fatalln("Unsupported LiveQuery PublisherType")
+		// 其他类型需要通过 pubsub.RegisterTransport 注册，在创建发布者时校验
+	}
+	if TConfig.LiveQueryPingInterval < 0 || TConfig.LiveQueryPingTimeout < 0 {
+		return errors.New("LiveQuery ping interval and ping timeout must be a value greater than or equal to 0")
+	}
+	if TConfig.LiveQueryMaxConnections < 0 {
+		return errors.New("LiveQuery max connections must be a value greater than or equal to 0")
+	}
+	if TConfig.LiveQueryResumeWindow < 0 {
+		return errors.New("LiveQuery resume window must be a value greater than or equal to 0")
+	}
+	switch TConfig.LiveQueryEventSource {
+	case "", "Controller", "Database":
+	default:
+		return errors.New("LiveQueryEventSource should be Controller or Database")
 	}
+	return nil
 }
 
-// validateSessionConfiguration 校验 Session 有效期
-func validateSessionConfiguration() {
+// validateSessionConfiguration 校验 Session 有效期与 sessionToken 类型
+func validateSessionConfiguration() error {
 	if TConfig.SessionLength <= 0 {
-		log.Fatalln("Session length must be a value greater than 0")
+		return errors.New("Session length must be a value greater than 0")
+	}
+	switch TConfig.SessionTokenType {
+	case "Random":
+	case "JWT":
+		if len(TConfig.JWTSigningKeys) == 0 {
+			return errors.New("JWTSigningKeys is required when SessionTokenType is JWT")
+		}
+		kids := map[string]bool{}
+		for _, key := range TConfig.JWTSigningKeys {
+			p := strings.Index(key, ":")
+			if p < 1 || len(key)-p-1 < 32 {
+				return errors.New("JWTSigningKeys must be kid:secret, and the secret must be at least 32 characters")
+			}
+			if kids[key[:p]] {
+				return errors.New("Duplicate kid in JWTSigningKeys: " + key[:p])
+			}
+			kids[key[:p]] = true
+		}
+		if TConfig.JWTTTL < 0 {
+			return errors.New("JWTTTL must be a positive number")
+		}
+	default:
+		return errors.New("Unsupported SessionTokenType: " + TConfig.SessionTokenType)
+	}
+	if TConfig.RefreshTokens && (TConfig.AccessTokenLength <= 0 || TConfig.RefreshTokenLength <= 0) {
+		return errors.New("AccessTokenLength and RefreshTokenLength must be a value greater than 0")
+	}
+	if TConfig.SessionActivityInterval < 0 {
+		return errors.New("SessionActivityInterval should be 0 or an integer greater than 0")
+	}
+	if TConfig.MaxSessionsPerUser < 0 {
+		return errors.New("MaxSessionsPerUser should be 0 or an integer greater than 0")
+	}
+	switch TConfig.SessionLimitPolicy {
+	case "evictOldest", "reject":
+	default:
+		return errors.New("SessionLimitPolicy must be one of evictOldest, reject")
+	}
+	if TConfig.ImpersonationSessionLength <= 0 {
+		return errors.New("ImpersonationSessionLength must be a value greater than 0")
+	}
+	if TConfig.ObjectNotFoundJitter < 0 {
+		return errors.New("ObjectNotFoundJitter should be 0 or an integer greater than 0")
+	}
+	for _, step := range append(append([]string{}, TConfig.UsernameNormalization...), TConfig.EmailNormalization...) {
+		switch step {
+		case "trim", "nfc", "lowercase":
+		default:
+			return errors.New("Unsupported normalization: " + step + ", must be one of trim, nfc, lowercase")
+		}
+	}
+	return nil
+}
+
+// splitList 解析使用 | 分隔的配置，忽略空白
+func splitList(s string) []string {
+	steps := []string{}
+	for _, step := range strings.Split(s, "|") {
+		if step = strings.TrimSpace(step); step != "" {
+			steps = append(steps, step)
+		}
 	}
+	return steps
+}
+
+// GenerateJWTExpiresAt 获取 JWT 的过期时间
+func GenerateJWTExpiresAt() time.Time {
+	if TConfig.JWTTTL > 0 {
+		return time.Now().UTC().Add(time.Duration(TConfig.JWTTTL) * time.Second)
+	}
+	return GenerateSessionExpiresAt()
+}
+
+// validateSignupConfiguration 校验注册用户相关参数
+func validateSignupConfiguration() error {
+	switch TConfig.SignupValidationPolicy {
+	case "reject", "flag":
+	default:
+		return errors.New("SignupValidationPolicy must be one of reject, flag")
+	}
+	if TConfig.CaptchaProvider == "" {
+		return nil
+	}
+	if (TConfig.CaptchaProvider == "hCaptcha" || TConfig.CaptchaProvider == "reCAPTCHA") && TConfig.CaptchaSecret == "" {
+		return errors.New("CaptchaSecret is required")
+	}
+	for _, route := range TConfig.CaptchaRoutes {
+		if route != "signup" && route != "passwordReset" {
+			return errors.New("Unsupported CaptchaRoutes: " + route + ", must be one of signup, passwordReset")
+		}
+	}
+	if TConfig.CaptchaMinScore < 0 || TConfig.CaptchaMinScore > 1 {
+		return errors.New("CaptchaMinScore must be between 0 and 1")
+	}
+	return nil
 }
 
 // validateAccountLockoutPolicy 校验账户锁定规则
-func validateAccountLockoutPolicy() {
+func validateAccountLockoutPolicy() error {
 	if TConfig.EnableAccountLockout == false {
-		return
+		return nil
 	}
 	if TConfig.AccountLockoutDuration < 1 || TConfig.AccountLockoutDuration > 99999 {
-		log.Fatalln("Account lockout duration should be greater than 0 and less than 100000")
+		return errors.New("Account lockout duration should be greater than 0 and less than 100000")
 	}
 	if TConfig.AccountLockoutThreshold < 1 || TConfig.AccountLockoutThreshold > 999 {
-		log.Fatalln("Account lockout threshold should be an integer greater than 0 and less than 1000")
+		return errors.New("Account lockout threshold should be an integer greater than 0 and less than 1000")
+	}
+	return nil
+}
+
+// validateLoginThrottle 校验登录失败次数限制
+func validateLoginThrottle() error {
+	if TConfig.LoginThrottle == false {
+		return nil
+	}
+	switch TConfig.LoginThrottleStore {
+	case "InMemory":
+	case "Redis":
+		if TConfig.RedisAddress == "" {
+			return errors.New("RedisAddress is required when LoginThrottleStore is Redis")
+		}
+	default:
+		return errors.New("Unsupported LoginThrottleStore: " + TConfig.LoginThrottleStore)
 	}
+	if TConfig.LoginThrottleIPThreshold < 0 || TConfig.LoginThrottleUsernameThreshold < 0 {
+		return errors.New("LoginThrottle thresholds must be positive numbers")
+	}
+	if TConfig.LoginThrottleWindow <= 0 {
+		return errors.New("LoginThrottleWindow must be a value greater than 0")
+	}
+	return nil
+}
+
+// validateQuotaConfiguration 校验请求统计与配额
+func validateQuotaConfiguration() error {
+	if TConfig.EnableQuota == false {
+		return nil
+	}
+	switch TConfig.QuotaStore {
+	case "InMemory":
+	case "Redis":
+		if TConfig.RedisAddress == "" {
+			return errors.New("RedisAddress is required when QuotaStore is Redis")
+		}
+	default:
+		return errors.New("Unsupported QuotaStore: " + TConfig.QuotaStore)
+	}
+	if TConfig.DailyRequestQuota < 0 || TConfig.MonthlyRequestQuota < 0 ||
+		TConfig.DailyBytesQuota < 0 || TConfig.MonthlyBytesQuota < 0 {
+		return errors.New("Quotas must be positive numbers")
+	}
+	if TConfig.QuotaErrorMessage == "" {
+		return errors.New("QuotaErrorMessage is required")
+	}
+	return nil
 }
 
 // validatePasswordPolicy 校验密码规则
-func validatePasswordPolicy() {
+func validatePasswordPolicy() error {
 	if TConfig.PasswordPolicy == false {
-		return
+		return nil
 	}
 	if TConfig.ResetTokenValidityDuration < 0 {
-		log.Fatalln("ResetTokenValidityDuration must be a positive number")
+		return errors.New("ResetTokenValidityDuration must be a positive number")
 	}
 	if TConfig.ValidatorPattern != "" {
 		_, err := regexp.Compile(TConfig.ValidatorPattern)
 		if err != nil {
-			log.Fatalln("ValidatorPattern must be a RegExp")
+			return errors.New("ValidatorPattern must be a RegExp")
 		}
 	}
 	if TConfig.MaxPasswordAge < 0 {
-		log.Fatalln("MaxPasswordAge must be a positive number")
+		return errors.New("MaxPasswordAge must be a positive number")
 	}
 	if TConfig.MaxPasswordHistory < 0 || TConfig.MaxPasswordHistory > 20 {
-		log.Fatalln("MaxPasswordHistory must be an integer ranging 0 - 20")
+		return errors.New("MaxPasswordHistory must be an integer ranging 0 - 20")
 	}
+	return nil
 }
 
 // validateCacheConfiguration 校验缓存相关参数
-func validateCacheConfiguration() {
+func validateCacheConfiguration() error {
 	adapter := TConfig.CacheAdapter
 	switch adapter {
 	case "", "InMemory", "Null":
 	case "Redis":
 		if TConfig.RedisAddress == "" {
-			log.Fatalln("RedisAddress is required")
+			return errors.New("RedisAddress is required")
 		}
 	default:
-		log.Fatalln("Unsupported CacheAdapter")
+		return errors.New("Unsupported CacheAdapter")
 	}
 	if TConfig.SchemaCacheTTL < -1 {
-		log.Fatalln("SchemaCacheTTL should be -1 or 0 or an integer greater than 0")
+		return errors.New("SchemaCacheTTL should be -1 or 0 or an integer greater than 0")
+	}
+	if TConfig.SchemaCacheStaleTTL < 0 {
+		return errors.New("SchemaCacheStaleTTL should be 0 or an integer greater than 0")
+	}
+	if TConfig.SchemaCacheMaxEntries < 0 {
+		return errors.New("SchemaCacheMaxEntries should be 0 or an integer greater than 0")
+	}
+	for _, classTTL := range TConfig.SchemaCacheClassTTLs {
+		if _, _, ok := ParseSchemaCacheClassTTL(classTTL); ok == false {
+			return errors.New("SchemaCacheClassTTLs should be className:seconds and seconds should be -1 or 0 or an integer greater than 0, invalid: " + classTTL)
+		}
+	}
+	if TConfig.ClassCacheTTL < 0 {
+		return errors.New("ClassCacheTTL should be 0 or an integer greater than 0")
+	}
+	if TConfig.UserCacheTTL < 0 {
+		return errors.New("UserCacheTTL should be 0 or an integer greater than 0")
+	}
+	if TConfig.ObjectCacheTTL < 0 {
+		return errors.New("ObjectCacheTTL should be 0 or an integer greater than 0")
 	}
+	if TConfig.SchemaCacheRefreshInterval < 0 {
+		return errors.New("SchemaCacheRefreshInterval should be 0 or an integer greater than 0")
+	}
+	if TConfig.TTLSweepInterval < 0 {
+		return errors.New("TTLSweepInterval should be 0 or an integer greater than 0")
+	}
+	return nil
 }
 
 // validateAnalyticsConfiguration 校验分析模块相关参数
-func validateAnalyticsConfiguration() {
+func validateAnalyticsConfiguration() error {
 	adapter := TConfig.AnalyticsAdapter
 	switch adapter {
 	case "InfluxDB":
 		if TConfig.InfluxDBURL == "" {
-			log.Fatalln("InfluxDBURL is required")
+			return errors.New("InfluxDBURL is required")
 		}
 		if TConfig.InfluxDBUsername == "" {
-			log.Fatalln("InfluxDBUsername is required")
+			return errors.New("InfluxDBUsername is required")
 		}
 		if TConfig.InfluxDBPassword == "" {
-			log.Fatalln("InfluxDBPassword is required")
+			return errors.New("InfluxDBPassword is required")
 		}
 		if TConfig.InfluxDBDatabaseName == "" {
-			log.Fatalln("InfluxDBDatabaseName is required")
+			return errors.New("InfluxDBDatabaseName is required")
+		}
+	case "Database":
+		if TConfig.AnalyticsClassName == "" {
+			return errors.New("AnalyticsClassName is required")
+		}
+	case "Webhook":
+		if TConfig.AnalyticsWebhookURL == "" {
+			return errors.New("AnalyticsWebhookURL is required")
 		}
 	case "":
 		// 默认使用空实现
 	default:
-		log.Fatalln("Unsupported AnalyticsAdapter")
+		return errors.New("Unsupported AnalyticsAdapter")
+	}
+	return nil
+}
+
+// validateWebhookConfiguration 校验云代码请求相关参数
+func validateWebhookConfiguration() error {
+	if TConfig.WebhookTimeout <= 0 {
+		return errors.New("WebhookTimeout should be an integer greater than 0")
+	}
+	if TConfig.WebhookMaxRetries < 0 || TConfig.WebhookMaxRetries > 10 {
+		return errors.New("WebhookMaxRetries should be an integer between 0 and 10")
+	}
+	if TConfig.WebhookRetryBackoff < 0 {
+		return errors.New("WebhookRetryBackoff should be 0 or an integer greater than 0")
+	}
+	if TConfig.WebhookMaxPayloadSize < 0 {
+		return errors.New("WebhookMaxPayloadSize should be 0 or an integer greater than 0")
+	}
+	return nil
+}
+
+// validatePrivacyConfiguration 校验导出与删除用户数据相关参数
+func validatePrivacyConfiguration() error {
+	if TConfig.DataExportLimit < 0 {
+		return errors.New("DataExportLimit should be 0 or an integer greater than 0")
 	}
+	if TConfig.DataExportLimit > 0 && TConfig.DataExportWindow <= 0 {
+		return errors.New("DataExportWindow should be an integer greater than 0")
+	}
+	for _, action := range TConfig.ErasureReferenceActions {
+		_, _, a, ok := ParseErasureReferenceAction(action)
+		if ok == false {
+			return errors.New("ErasureReferenceActions should be in the form className.fieldName:action, invalid: " + action)
+		}
+		switch a {
+		case "unset", "delete", "keep":
+		default:
+			return errors.New("Unsupported ErasureReferenceActions action: " + a + ", should be unset, delete or keep")
+		}
+	}
+	return nil
+}
+
+// ParseErasureReferenceAction 解析 类名.字段名:处理方式 格式的配置项
+func ParseErasureReferenceAction(s string) (className, fieldName, action string, ok bool) {
+	i := strings.LastIndex(s, ":")
+	if i == -1 {
+		return "", "", "", false
+	}
+	action = s[i+1:]
+	j := strings.Index(s[:i], ".")
+	if j <= 0 || j == i-1 {
+		return "", "", "", false
+	}
+	return s[:j], s[j+1 : i], action, true
+}
+
+// ParseSchemaCacheClassTTL 解析 类名:秒数 格式的配置项
+func ParseSchemaCacheClassTTL(s string) (className string, ttl int, ok bool) {
+	i := strings.LastIndex(s, ":")
+	if i <= 0 {
+		return "", 0, false
+	}
+	ttl, err := strconv.Atoi(s[i+1:])
+	if err != nil || ttl < -1 {
+		return "", 0, false
+	}
+	return s[:i], ttl, true
+}
+
+// MasterKeyIPAllowed 判断 ip 是否允许使用 Master Key ，未配置 MasterKeyIPs 时允许所有地址
+func MasterKeyIPAllowed(ip string) bool {
+	if len(TConfig.MasterKeyIPs) == 0 {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, s := range TConfig.MasterKeyIPs {
+		if ipNet, ok := parseIPNet(s); ok && ipNet.Contains(addr) {
+			return true
+		}
+	}
+	return false
 }
 
-// GenerateSessionExpiresAt 获取 Session 过期时间
+// parseIPNet 解析 IP 或者 CIDR 网段，单个 IP 转换为只包含该地址的网段
+func parseIPNet(s string) (*net.IPNet, bool) {
+	if strings.Contains(s, "/") {
+		_, ipNet, err := net.ParseCIDR(s)
+		return ipNet, err == nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, true
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, true
+}
+
+// GenerateSessionExpiresAt 获取 Session 过期时间，开启 RefreshTokens 时使用 AccessTokenLength
 func GenerateSessionExpiresAt() time.Time {
+	length := TConfig.SessionLength
+	if TConfig.RefreshTokens {
+		length = TConfig.AccessTokenLength
+	}
 	expiresAt := time.Now().UTC()
-	expiresAt = expiresAt.Add(time.Duration(TConfig.SessionLength) * time.Second)
+	expiresAt = expiresAt.Add(time.Duration(length) * time.Second)
 	return expiresAt
 }
 
+// GenerateRefreshTokenExpiresAt 获取 refreshToken 过期时间
+func GenerateRefreshTokenExpiresAt() time.Time {
+	return time.Now().UTC().Add(time.Duration(TConfig.RefreshTokenLength) * time.Second)
+}
+
 // GenerateEmailVerifyTokenExpiresAt 获取 Email 验证 Token 过期时间
 func GenerateEmailVerifyTokenExpiresAt() time.Time {
 	if TConfig.VerifyUserEmails == false || TConfig.EmailVerifyTokenValidityDuration <= 0 {
@@ -0,0 +1,26 @@
+package config
+
+import "testing"
+
+func Test_ParseSchemaCacheClassTTL(t *testing.T) {
+	tests := []struct {
+		name          string
+		s             string
+		wantClassName string
+		wantTTL       int
+		wantOk        bool
+	}{
+		{name: "1", s: "Post:60", wantClassName: "Post", wantTTL: 60, wantOk: true},
+		{name: "2", s: "Log:-1", wantClassName: "Log", wantTTL: -1, wantOk: true},
+		{name: "3", s: "Post:-2", wantClassName: "", wantTTL: 0, wantOk: false},
+		{name: "4", s: ":60", wantClassName: "", wantTTL: 0, wantOk: false},
+		{name: "5", s: "Post", wantClassName: "", wantTTL: 0, wantOk: false},
+		{name: "6", s: "Post:abc", wantClassName: "", wantTTL: 0, wantOk: false},
+	}
+	for _, tt := range tests {
+		className, ttl, ok := ParseSchemaCacheClassTTL(tt.s)
+		if className != tt.wantClassName || ttl != tt.wantTTL || ok != tt.wantOk {
+			t.Errorf("%q. ParseSchemaCacheClassTTL() = %v, %v, %v, want %v, %v, %v", tt.name, className, ttl, ok, tt.wantClassName, tt.wantTTL, tt.wantOk)
+		}
+	}
+}
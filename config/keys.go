@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// AppKeys 中的密钥类型
+const (
+	KeyTypeMaster     = "master"
+	KeyTypeClient     = "client"
+	KeyTypeJavaScript = "javascript"
+	KeyTypeDotNet     = "dotnet"
+	KeyTypeRestAPI    = "restapi"
+)
+
+// DefaultKeyLabel MasterKey ClientKey 等主密钥的标签
+const DefaultKeyLabel = "default"
+
+// appKeyDateLayout 密钥过期日期的格式，过期日期当天零点（UTC）起密钥失效
+const appKeyDateLayout = "2006-01-02"
+
+// AppKey 用于轮换的附加密钥
+type AppKey struct {
+	Type      string
+	Label     string
+	Key       string
+	ExpiresAt time.Time // 为零值时不过期
+}
+
+// Expired 判断密钥在 now 时是否已过期
+func (k AppKey) Expired(now time.Time) bool {
+	return k.ExpiresAt.IsZero() == false && now.Before(k.ExpiresAt) == false
+}
+
+// splitAppKeys 拆分使用 | 分隔的 AppKeys 配置，忽略空项
+func splitAppKeys(s string) []string {
+	keys := []string{}
+	for _, key := range strings.Split(s, "|") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// ParseAppKey 解析 类型:标签:密钥[:过期日期] 格式的配置项
+func ParseAppKey(s string) (AppKey, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 && len(parts) != 4 {
+		return AppKey{}, false
+	}
+	key := AppKey{Type: parts[0], Label: parts[1], Key: parts[2]}
+	switch key.Type {
+	case KeyTypeMaster, KeyTypeClient, KeyTypeJavaScript, KeyTypeDotNet, KeyTypeRestAPI:
+	default:
+		return AppKey{}, false
+	}
+	if key.Label == "" || key.Label == DefaultKeyLabel || key.Key == "" {
+		return AppKey{}, false
+	}
+	if len(parts) == 4 {
+		expiresAt, err := time.Parse(appKeyDateLayout, parts[3])
+		if err != nil {
+			return AppKey{}, false
+		}
+		key.ExpiresAt = expiresAt
+	}
+	return key, true
+}
+
+// validateAppKeys 校验 AppKeys 的格式，同一类型下标签与密钥均不能重复
+func validateAppKeys(keys []string) error {
+	labels := map[string]bool{}
+	values := map[string]bool{}
+	for _, s := range keys {
+		key, ok := ParseAppKey(s)
+		if ok == false {
+			return errors.New("AppKeys should be type:label:key[:yyyy-mm-dd], invalid: " + s)
+		}
+		if labels[key.Type+":"+key.Label] {
+			return errors.New("Duplicate AppKeys label: " + key.Type + ":" + key.Label)
+		}
+		if values[key.Type+":"+key.Key] {
+			return errors.New("Duplicate AppKeys key for label: " + key.Type + ":" + key.Label)
+		}
+		labels[key.Type+":"+key.Label] = true
+		values[key.Type+":"+key.Key] = true
+	}
+	return nil
+}
+
+// ListAppKeys 返回当前有效配置中的全部密钥，包括标签为 default 的主密钥与已过期的附加密钥
+func ListAppKeys() []AppKey {
+	c := TConfig
+	keys := []AppKey{}
+	primary := []struct {
+		keyType string
+		key     string
+	}{
+		{KeyTypeMaster, c.MasterKey},
+		{KeyTypeClient, c.ClientKey},
+		{KeyTypeJavaScript, c.JavaScriptKey},
+		{KeyTypeDotNet, c.DotNetKey},
+		{KeyTypeRestAPI, c.RestAPIKey},
+	}
+	for _, p := range primary {
+		if p.key != "" {
+			keys = append(keys, AppKey{Type: p.keyType, Label: DefaultKeyLabel, Key: p.key})
+		}
+	}
+	for _, s := range c.AppKeys {
+		if key, ok := ParseAppKey(s); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// MatchAppKey 查找类型为 keyType 、值为 key 的密钥，包括已过期的密钥，由调用方判断是否过期
+func MatchAppKey(keyType, key string) (AppKey, bool) {
+	if key == "" {
+		return AppKey{}, false
+	}
+	for _, k := range ListAppKeys() {
+		if k.Type == keyType && k.Key == key {
+			return k, true
+		}
+	}
+	return AppKey{}, false
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_ParseAppKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		s      string
+		want   AppKey
+		wantOk bool
+	}{
+		{
+			name:   "1",
+			s:      "master:2024q1:abc123",
+			want:   AppKey{Type: "master", Label: "2024q1", Key: "abc123"},
+			wantOk: true,
+		},
+		{
+			name:   "2",
+			s:      "client:ios:def456:2024-04-01",
+			want:   AppKey{Type: "client", Label: "ios", Key: "def456", ExpiresAt: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+			wantOk: true,
+		},
+		{
+			name:   "3",
+			s:      "admin:ios:def456",
+			want:   AppKey{},
+			wantOk: false,
+		},
+		{
+			name:   "4",
+			s:      "client:default:def456",
+			want:   AppKey{},
+			wantOk: false,
+		},
+		{
+			name:   "5",
+			s:      "client:ios:def456:20240401",
+			want:   AppKey{},
+			wantOk: false,
+		},
+		{
+			name:   "6",
+			s:      "client:ios",
+			want:   AppKey{},
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		got, ok := ParseAppKey(tt.s)
+		if !reflect.DeepEqual(got, tt.want) || ok != tt.wantOk {
+			t.Errorf("%q. ParseAppKey() = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func Test_validateAppKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []string
+		wantErr error
+	}{
+		{
+			name:    "1",
+			keys:    []string{"master:old:abc:2024-04-01", "master:new:def", "client:old:abc"},
+			wantErr: nil,
+		},
+		{
+			name:    "2",
+			keys:    []string{"master:old:abc", "master:old:def"},
+			wantErr: errors.New("Duplicate AppKeys label: master:old"),
+		},
+		{
+			name:    "3",
+			keys:    []string{"master:old:abc", "master:new:abc"},
+			wantErr: errors.New("Duplicate AppKeys key for label: master:new"),
+		},
+		{
+			name:    "4",
+			keys:    []string{"master:old"},
+			wantErr: errors.New("AppKeys should be type:label:key[:yyyy-mm-dd], invalid: master:old"),
+		},
+	}
+	for _, tt := range tests {
+		if err := validateAppKeys(tt.keys); !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%q. validateAppKeys() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_MatchAppKey(t *testing.T) {
+	old := TConfig
+	defer func() { TConfig = old }()
+	TConfig = &Config{
+		MasterKey: "master",
+		ClientKey: "client",
+		AppKeys:   []string{"master:2024q1:abc:2024-04-01", "client:ios:master"},
+	}
+
+	key, ok := MatchAppKey(KeyTypeMaster, "master")
+	if ok == false || key.Label != DefaultKeyLabel {
+		t.Error("expect:", DefaultKeyLabel, "result:", key, ok)
+	}
+	/*****************************************************************/
+	key, ok = MatchAppKey(KeyTypeMaster, "abc")
+	if ok == false || key.Label != "2024q1" || key.Expired(time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC)) {
+		t.Error("expect:", "2024q1", "result:", key, ok)
+	}
+	if key.Expired(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)) == false {
+		t.Error("expect:", true, "result:", false)
+	}
+	/*****************************************************************/
+	key, ok = MatchAppKey(KeyTypeClient, "master")
+	if ok == false || key.Label != "ios" {
+		t.Error("expect:", "ios", "result:", key, ok)
+	}
+	/*****************************************************************/
+	_, ok = MatchAppKey(KeyTypeRestAPI, "")
+	if ok {
+		t.Error("expect:", false, "result:", ok)
+	}
+}
@@ -0,0 +1,13 @@
+package config
+
+import "github.com/okobsamoht/talisman/utils"
+
+// NormalizeUsername 按 UsernameNormalization 规范化用户名，写入、登录与登录频率限制时使用
+func NormalizeUsername(username string) string {
+	return utils.Normalize(username, TConfig.UsernameNormalization)
+}
+
+// NormalizeEmail 按 EmailNormalization 规范化邮箱，写入以及按邮箱查找用户时使用
+func NormalizeEmail(email string) string {
+	return utils.Normalize(email, TConfig.EmailNormalization)
+}
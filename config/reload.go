@@ -0,0 +1,179 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/astaxie/beego"
+	beeconfig "github.com/astaxie/beego/config"
+)
+
+// 支持运行时重新加载的参数如下，其他参数修改后需要重启服务才能生效：
+// LogLevel DefaultClassLevelPermissions
+// LoginThrottleIPThreshold LoginThrottleUsernameThreshold LoginThrottleWindow
+// DailyRequestQuota MonthlyRequestQuota DailyBytesQuota MonthlyBytesQuota QuotaErrorCode QuotaErrorMessage
+// FCMServerKey AppKeys
+
+var (
+	reloadMutex sync.Mutex
+	reloadHooks []func(c *Config)
+)
+
+// OnReload 注册重新加载配置后的回调，各模块在回调中根据新的配置 c 更新内部状态
+func OnReload(fn func(c *Config)) {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+	reloadHooks = append(reloadHooks, fn)
+}
+
+// Reload 重新读取配置文件与环境变量，校验通过后整体替换 TConfig ，然后依次执行 OnReload 注册的回调
+// 配置文件默认为 conf/app.conf ，可通过环境变量 TALISMAN_CONFIG_FILE 指定
+// 环境变量 TALISMAN_ 加大写的参数名优先于配置文件，如 TALISMAN_LOGLEVEL=info
+// 读取或者校验失败时返回错误，当前配置保持不变
+func Reload() error {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	cnf, err := beeconfig.NewConfig("ini", configFile())
+	if err != nil {
+		return err
+	}
+	next := *TConfig
+	source := &reloadSource{config: cnf, runMode: beego.BConfig.RunMode}
+	if err := parseReloadable(&next, source); err != nil {
+		return err
+	}
+	if err := validateReloadable(&next); err != nil {
+		return err
+	}
+
+	// 替换指针而不是修改字段，正在处理的请求继续使用旧的配置
+	TConfig = &next
+	for _, fn := range reloadHooks {
+		fn(TConfig)
+	}
+	return nil
+}
+
+// configFile 返回配置文件路径
+func configFile() string {
+	if file := os.Getenv("TALISMAN_CONFIG_FILE"); file != "" {
+		return file
+	}
+	return filepath.Join(beego.AppPath, "conf", "app.conf")
+}
+
+// parseReloadable 从 source 中读取可重新加载的参数，默认值与 parseConfig 保持一致
+func parseReloadable(c *Config, source *reloadSource) error {
+	c.LogLevel = source.String("LogLevel", "debug")
+	c.DefaultClassLevelPermissions = source.String("DefaultClassLevelPermissions", "public")
+	c.QuotaErrorMessage = source.String("QuotaErrorMessage", "Request quota exceeded.")
+	c.FCMServerKey = source.String("FCMServerKey", "")
+	c.AppKeys = splitAppKeys(source.String("AppKeys", ""))
+
+	ints := []struct {
+		key   string
+		value *int
+		def   int
+	}{
+		{"LoginThrottleIPThreshold", &c.LoginThrottleIPThreshold, 20},
+		{"LoginThrottleUsernameThreshold", &c.LoginThrottleUsernameThreshold, 5},
+		{"LoginThrottleWindow", &c.LoginThrottleWindow, 900},
+		{"DailyRequestQuota", &c.DailyRequestQuota, 0},
+		{"MonthlyRequestQuota", &c.MonthlyRequestQuota, 0},
+		{"DailyBytesQuota", &c.DailyBytesQuota, 0},
+		{"MonthlyBytesQuota", &c.MonthlyBytesQuota, 0},
+		{"QuotaErrorCode", &c.QuotaErrorCode, 155},
+	}
+	for _, i := range ints {
+		v, err := source.Int(i.key, i.def)
+		if err != nil {
+			return err
+		}
+		*i.value = v
+	}
+	return nil
+}
+
+// validateReloadable 校验可重新加载的参数，与启动时的校验规则相同，但是返回错误而不是退出
+func validateReloadable(c *Config) error {
+	if validLogLevel(c.LogLevel) == false {
+		return errors.New("LogLevel must be one of error, warn, verbose, info, debug")
+	}
+	switch c.DefaultClassLevelPermissions {
+	case "public", "authenticated", "masterKey":
+	default:
+		return errors.New("DefaultClassLevelPermissions must be one of public, authenticated, masterKey")
+	}
+	if c.LoginThrottle {
+		if c.LoginThrottleIPThreshold < 0 || c.LoginThrottleUsernameThreshold < 0 {
+			return errors.New("LoginThrottle thresholds must be positive numbers")
+		}
+		if c.LoginThrottleWindow <= 0 {
+			return errors.New("LoginThrottleWindow must be a value greater than 0")
+		}
+	}
+	if c.EnableQuota {
+		if c.DailyRequestQuota < 0 || c.MonthlyRequestQuota < 0 ||
+			c.DailyBytesQuota < 0 || c.MonthlyBytesQuota < 0 {
+			return errors.New("Quotas must be positive numbers")
+		}
+		if c.QuotaErrorMessage == "" {
+			return errors.New("QuotaErrorMessage is required")
+		}
+	}
+	if c.PushAdapter == "FCM" && c.FCMServerKey == "" {
+		return errors.New("FCMServerKey is required when PushAdapter is FCM")
+	}
+	if err := validateAppKeys(c.AppKeys); err != nil {
+		return err
+	}
+	return nil
+}
+
+// reloadSource 重新加载时的参数来源，依次查找环境变量、当前运行模式下的参数、全局参数
+type reloadSource struct {
+	config  beeconfig.Configer
+	runMode string
+}
+
+func (s *reloadSource) lookup(key string) (string, bool) {
+	if v, ok := os.LookupEnv("TALISMAN_" + strings.ToUpper(key)); ok {
+		return v, true
+	}
+	if s.config == nil {
+		return "", false
+	}
+	if v := s.config.String(s.runMode + "::" + key); v != "" {
+		return v, true
+	}
+	if v := s.config.String(key); v != "" {
+		return v, true
+	}
+	return "", false
+}
+
+// String ...
+func (s *reloadSource) String(key, def string) string {
+	if v, ok := s.lookup(key); ok {
+		return v
+	}
+	return def
+}
+
+// Int 参数不是整数时返回错误
+func (s *reloadSource) Int(key string, def int) (int, error) {
+	v, ok := s.lookup(key)
+	if ok == false {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, errors.New(key + " must be an integer")
+	}
+	return i, nil
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"github.com/okobsamoht/talisman/audiences"
+	"github.com/okobsamoht/talisman/types"
+)
+
+// PushAudiencesController 处理 /push_audiences 接口的请求，仅允许 Master 权限访问
+type PushAudiencesController struct {
+	ClassesController
+}
+
+// Prepare ...
+func (p *PushAudiencesController) Prepare() {
+	p.ClassesController.Prepare()
+	if p.Ctx.ResponseWriter.Started == false {
+		p.EnforceMasterKeyAccess()
+	}
+}
+
+// HandleFind 处理查找受众请求
+// @router / [get]
+func (p *PushAudiencesController) HandleFind() {
+	p.ClassName = "_Audience"
+	p.ClassesController.HandleFind()
+}
+
+// HandleGet 处理获取指定受众请求
+// @router /:objectId [get]
+func (p *PushAudiencesController) HandleGet() {
+	p.ClassName = "_Audience"
+	p.ObjectID = p.Ctx.Input.Param(":objectId")
+	p.ClassesController.HandleGet()
+}
+
+// HandleCreate 处理创建受众请求
+// @router / [post]
+func (p *PushAudiencesController) HandleCreate() {
+	p.ClassName = "_Audience"
+	p.ClassesController.HandleCreate()
+}
+
+// HandleUpdate 处理更新指定受众请求
+// @router /:objectId [put]
+func (p *PushAudiencesController) HandleUpdate() {
+	p.ClassName = "_Audience"
+	p.ObjectID = p.Ctx.Input.Param(":objectId")
+	p.ClassesController.HandleUpdate()
+}
+
+// HandleDelete 处理删除指定受众请求
+// @router /:objectId [delete]
+func (p *PushAudiencesController) HandleDelete() {
+	p.ClassName = "_Audience"
+	p.ObjectID = p.Ctx.Input.Param(":objectId")
+	p.ClassesController.HandleDelete()
+}
+
+// HandleSize 估算受众包含的设备数量
+// @router /:objectId/size [get]
+func (p *PushAudiencesController) HandleSize() {
+	count, err := audiences.EstimateSize(p.Ctx.Input.Param(":objectId"))
+	if err != nil {
+		p.HandleError(err, 0)
+		return
+	}
+	p.Data["json"] = types.M{"count": count}
+	p.ServeJSON()
+}
@@ -3,12 +3,16 @@ package controllers
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"strings"
+	"time"
 
 	"github.com/astaxie/beego"
+	"github.com/okobsamoht/talisman/captcha"
 	"github.com/okobsamoht/talisman/client"
 	"github.com/okobsamoht/talisman/config"
 	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/logger"
 	"github.com/okobsamoht/talisman/rest"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
@@ -19,13 +23,15 @@ import (
 // Auth 当前请求的用户权限
 // JSONBody 由 JSON 格式转换来的请求数据
 // RawBody 原始请求数据
+// RequestID 当前请求的 ID ，由 server 中的过滤器生成
 type BaseController struct {
 	beego.Controller
-	Info     *RequestInfo
-	Auth     *rest.Auth
-	Query    map[string]string
-	JSONBody types.M
-	RawBody  []byte
+	Info      *RequestInfo
+	Auth      *rest.Auth
+	Query     map[string]string
+	JSONBody  types.M
+	RawBody   []byte
+	RequestID string
 }
 
 // RequestInfo http 请求的权限信息
@@ -49,6 +55,13 @@ type RequestInfo struct {
 // 4. 校验请求权限
 // 5. 生成用户信息
 func (b *BaseController) Prepare() {
+	b.RequestID = utils.S(b.Ctx.Input.GetData("requestId"))
+	defer func() {
+		if b.Auth != nil {
+			b.Auth.RequestID = b.RequestID
+		}
+	}()
+
 	info := &RequestInfo{}
 	info.AppID = b.Ctx.Input.Header("X-Parse-Application-Id")
 	info.MasterKey = b.Ctx.Input.Header("X-Parse-Master-Key")
@@ -89,8 +102,8 @@ func (b *BaseController) Prepare() {
 			}
 			b.JSONBody = object
 		} else {
-			// 当 AppID 不存在时，尝试转换，转换失败不返回错误
-			if info.AppID == "" {
+			// 当 AppID 不存在，或者为携带 body 的 GET 请求时，尝试转换，转换失败不返回错误
+			if info.AppID == "" || b.Ctx.Input.Method() == "GET" {
 				var object types.M
 				err := json.Unmarshal(b.Ctx.Input.RequestBody, &object)
 				if err == nil {
@@ -166,21 +179,34 @@ func (b *BaseController) Prepare() {
 		b.InvalidRequest()
 		return
 	}
-	if info.MasterKey == config.TConfig.MasterKey {
+	if key, ok := config.MatchAppKey(config.KeyTypeMaster, info.MasterKey); ok {
+		ip := config.ClientIP(b.Ctx.Request)
+		if key.Expired(time.Now()) {
+			logger.Request(b.RequestID).Warn("audit: expired master key rejected", key.Label, "from", ip, b.Ctx.Input.Method(), b.Ctx.Input.URL())
+			b.InvalidRequest()
+			return
+		}
+		// 限制 Master Key 的来源地址，密钥泄露时其他地址无法使用
+		if config.MasterKeyIPAllowed(ip) == false {
+			logger.Request(b.RequestID).Warn("audit: master key rejected from", ip, b.Ctx.Input.Method(), b.Ctx.Input.URL())
+			b.InvalidRequest()
+			return
+		}
+		recordKeyUsage(key, ip)
 		b.Auth = &rest.Auth{InstallationID: info.InstallationID, IsMaster: true}
 		return
 	}
-	var allow = false
-	if (len(info.ClientKey) > 0 && info.ClientKey == config.TConfig.ClientKey) ||
-		(len(info.JavaScriptKey) > 0 && info.JavaScriptKey == config.TConfig.JavaScriptKey) ||
-		(len(info.RestAPIKey) > 0 && info.RestAPIKey == config.TConfig.RestAPIKey) ||
-		(len(info.DotNetKey) > 0 && info.DotNetKey == config.TConfig.DotNetKey) {
-		allow = true
+	key, ok := matchClientKey(info)
+	if ok == false {
+		b.InvalidRequest()
+		return
 	}
-	if allow == false {
+	if key.Expired(time.Now()) {
+		logger.Request(b.RequestID).Warn("audit: expired", key.Type, "key rejected", key.Label, "from", config.ClientIP(b.Ctx.Request))
 		b.InvalidRequest()
 		return
 	}
+	recordKeyUsage(key, config.ClientIP(b.Ctx.Request))
 	// TODO 登录时删除 Token ，如何处理接口地址？
 	url := b.Ctx.Input.URL()
 	if url == "/v1/login" || url == "/v1/login/" {
@@ -204,6 +230,12 @@ func (b *BaseController) Prepare() {
 		return
 	}
 	b.Auth = auth
+
+	deviceName := b.Ctx.Input.Header("X-Parse-Device-Name")
+	if deviceName == "" {
+		deviceName = b.Ctx.Input.UserAgent()
+	}
+	rest.TouchSession(info.SessionToken, config.ClientIP(b.Ctx.Request), deviceName)
 }
 
 func httpAuth(authorization string) map[string]string {
@@ -254,32 +286,31 @@ func decodeBase64(str string) string {
 // HandleError 返回错误信息，不指定 status 参数时，默认为 0
 func (b *BaseController) HandleError(err error, status int) {
 	code := errs.GetErrorCode(err)
+	var result types.M
 	if code != 0 {
-		var httpStatus int
-		switch code {
-		case errs.InternalServerError:
-			httpStatus = 500
-		case errs.ObjectNotFound:
-			httpStatus = 404
-		default:
-			httpStatus = 400
-		}
-
-		b.Ctx.Output.SetStatus(httpStatus)
-		b.Data["json"] = errs.ErrorToMap(err)
-		b.ServeJSON()
-		return
+		status = errs.HTTPStatus(err)
+		result = errs.ErrorToMap(err)
+	} else if status != 0 {
+		result = types.M{"error": err.Error()}
+	} else {
+		status = 500
+		result = errs.ErrorMessageToMap(errs.InternalServerError, "Internal server error: "+err.Error())
 	}
 
-	if status != 0 {
-		b.Ctx.Output.SetStatus(status)
-		b.Data["json"] = types.M{"error": err.Error()}
-		b.ServeJSON()
-		return
+	// 服务端错误记录到日志中，包含原始错误，客户端可以通过返回的 requestId 查找
+	if status >= 500 {
+		args := []interface{}{b.Ctx.Input.Method(), b.Ctx.Input.URL(), err}
+		if cause := errors.Unwrap(err); cause != nil {
+			args = append(args, "cause:", cause)
+		}
+		logger.Request(b.RequestID).Error(args...)
+	}
+	if b.RequestID != "" {
+		result["requestId"] = b.RequestID
 	}
 
-	b.Ctx.Output.SetStatus(500)
-	b.Data["json"] = errs.ErrorMessageToMap(errs.InternalServerError, "Internal server error: "+err.Error())
+	b.Ctx.Output.SetStatus(status)
+	b.Data["json"] = result
 	b.ServeJSON()
 }
 
@@ -290,6 +321,21 @@ func (b *BaseController) InvalidRequest() {
 	b.ServeJSON()
 }
 
+// EnforceCaptcha 校验请求头 X-Parse-Captcha-Token 中的人机验证 token ， route 未开启人机验证或者使用 Master Key 时不校验
+// 返回 false 表示校验未通过，已返回错误信息
+func (b *BaseController) EnforceCaptcha(route string) bool {
+	if b.Auth.IsMaster || captcha.Enabled(route) == false {
+		return true
+	}
+	err := captcha.Verify(b.Ctx.Input.Header("X-Parse-Captcha-Token"), config.ClientIP(b.Ctx.Request))
+	if err != nil {
+		logger.Request(b.RequestID).Warn("captcha verification failed for", route, "from", config.ClientIP(b.Ctx.Request))
+		b.HandleError(err, 0)
+		return false
+	}
+	return true
+}
+
 // EnforceMasterKeyAccess 接口需要 Master 权限
 // 返回 true 表示当前请求是 Master 权限
 func (b *BaseController) EnforceMasterKeyAccess() bool {
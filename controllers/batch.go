@@ -63,6 +63,10 @@ func (b *BatchController) HandleBatch() {
 	if b.Ctx.Input.Header("Authorization") != "" {
 		headers["Authorization"] = b.Ctx.Input.Header("Authorization")
 	}
+	// 子请求沿用批量请求的 ID ，便于在日志中关联
+	if b.RequestID != "" {
+		headers["X-Request-Id"] = b.RequestID
+	}
 
 	b.HandleRequest(requests, headers, b.Ctx.Input.Scheme())
 }
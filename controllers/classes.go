@@ -31,6 +31,9 @@ func (c *ClassesController) HandleCreate() {
 		c.HandleError(errs.E(errs.InvalidJSON, "request body is empty"), 0)
 		return
 	}
+	if c.ClassName == "_User" && c.EnforceCaptcha("signup") == false {
+		return
+	}
 
 	result, err := rest.Create(c.Auth, c.ClassName, c.JSONBody, c.Info.ClientSDK)
 	if err != nil {
@@ -64,6 +67,7 @@ func (c *ClassesController) HandleGet() {
 	allowedGetQueryKeys := map[string]bool{
 		"keys":    true,
 		"include": true,
+		"locale":  true,
 	}
 	for k := range c.Query {
 		if allowedGetQueryKeys[k] == false {
@@ -91,15 +95,21 @@ func (c *ClassesController) HandleGet() {
 		options["include"] = c.JSONBody["include"]
 	}
 
+	if c.Query["locale"] != "" {
+		options["locale"] = c.Query["locale"]
+	} else if c.JSONBody != nil && c.JSONBody["locale"] != nil {
+		options["locale"] = c.JSONBody["locale"]
+	}
+
 	response, err := rest.Get(c.Auth, c.ClassName, c.ObjectID, options, c.Info.ClientSDK)
 	if err != nil {
-		c.HandleError(err, 0)
+		c.HandleError(rest.UniformNotFound(c.Auth, err), 0)
 		return
 	}
 
 	results := utils.A(response["results"])
 	if results == nil || len(results) == 0 {
-		c.HandleError(errs.E(errs.ObjectNotFound, "Object not found."), 0)
+		c.HandleError(rest.UniformNotFound(c.Auth, errs.E(errs.ObjectNotFound, "Object not found.")), 0)
 		return
 	}
 
@@ -133,7 +143,7 @@ func (c *ClassesController) HandleUpdate() {
 
 	result, err := rest.Update(c.Auth, c.ClassName, c.ObjectID, c.JSONBody, c.Info.ClientSDK)
 	if err != nil {
-		c.HandleError(err, 0)
+		c.HandleError(rest.UniformNotFound(c.Auth, err), 0)
 		return
 	}
 
@@ -156,6 +166,9 @@ func (c *ClassesController) HandleFind() {
 		"keys":                    true,
 		"include":                 true,
 		"redirectClassNameForKey": true,
+		"caseInsensitive":         true,
+		"countRelations":          true,
+		"locale":                  true,
 		"where":                   true,
 	}
 	for k := range c.Query {
@@ -229,6 +242,29 @@ func (c *ClassesController) HandleFind() {
 		options["redirectClassNameForKey"] = c.JSONBody["redirectClassNameForKey"]
 	}
 
+	// 需要统计对象数的 Relation 字段，多个使用 , 分隔
+	if c.Query["countRelations"] != "" {
+		options["countRelations"] = c.Query["countRelations"]
+	} else if c.JSONBody != nil && c.JSONBody["countRelations"] != nil {
+		options["countRelations"] = c.JSONBody["countRelations"]
+	}
+
+	// LocalizedString 字段使用的语言，多个候选语言使用 , 分隔
+	if c.Query["locale"] != "" {
+		options["locale"] = c.Query["locale"]
+	} else if c.JSONBody != nil && c.JSONBody["locale"] != nil {
+		options["locale"] = c.JSONBody["locale"]
+	}
+
+	// 为 true 时 String 字段的相等条件不区分大小写
+	if c.Query["caseInsensitive"] != "" {
+		options["caseInsensitive"] = c.Query["caseInsensitive"] == "true"
+	} else if c.JSONBody != nil && c.JSONBody["caseInsensitive"] != nil {
+		if b, ok := c.JSONBody["caseInsensitive"].(bool); ok {
+			options["caseInsensitive"] = b
+		}
+	}
+
 	where := types.M{}
 	if c.Query["where"] != "" {
 		err := json.Unmarshal([]byte(c.Query["where"]), &where)
@@ -271,7 +307,7 @@ func (c *ClassesController) HandleDelete() {
 
 	err := rest.Delete(c.Auth, c.ClassName, c.ObjectID)
 	if err != nil {
-		c.HandleError(err, 0)
+		c.HandleError(rest.UniformNotFound(c.Auth, err), 0)
 		return
 	}
 
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/rest"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// DevicesController 处理 /devices 接口的请求，用户管理自己已登录的设备
+type DevicesController struct {
+	ClassesController
+}
+
+// requireUser 接口需要登录用户
+func (d *DevicesController) requireUser() bool {
+	if d.Auth.User == nil || d.Info.SessionToken == "" {
+		d.HandleError(errs.E(errs.InvalidSessionToken, "Session token required."), 0)
+		return false
+	}
+	return true
+}
+
+// HandleList 列出当前用户已登录的设备
+// @router / [get]
+func (d *DevicesController) HandleList() {
+	if d.requireUser() == false {
+		return
+	}
+	devices, err := rest.ListDevices(utils.S(d.Auth.User["objectId"]), d.Info.SessionToken)
+	if err != nil {
+		d.HandleError(err, 0)
+		return
+	}
+	d.Data["json"] = types.M{"results": devices}
+	d.ServeJSON()
+}
+
+// HandleRevoke 注销指定设备上的登录
+// @router /:objectId [delete]
+func (d *DevicesController) HandleRevoke() {
+	if d.requireUser() == false {
+		return
+	}
+	err := rest.RevokeDevice(utils.S(d.Auth.User["objectId"]), d.Ctx.Input.Param(":objectId"))
+	if err != nil {
+		d.HandleError(err, 0)
+		return
+	}
+	d.Data["json"] = types.M{}
+	d.ServeJSON()
+}
+
+// HandleRevokeAll 注销全部设备上的登录，参数 keepCurrent=true 时保留当前设备
+// @router / [delete]
+func (d *DevicesController) HandleRevokeAll() {
+	if d.requireUser() == false {
+		return
+	}
+	exceptToken := ""
+	if d.Query["keepCurrent"] == "true" {
+		exceptToken = d.Info.SessionToken
+	}
+	count, err := rest.RevokeAllDevices(utils.S(d.Auth.User["objectId"]), exceptToken)
+	if err != nil {
+		d.HandleError(err, 0)
+		return
+	}
+	d.Data["json"] = types.M{"count": count}
+	d.ServeJSON()
+}
+
+// Post ...
+// @router / [post]
+func (d *DevicesController) Post() {
+	d.ClassesController.Post()
+}
+
+// Put ...
+// @router / [put]
+func (d *DevicesController) Put() {
+	d.ClassesController.Put()
+}
@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/export"
+	"github.com/okobsamoht/talisman/logger"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/rest"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// exportPageSize 导出时每次查询的对象数
+const exportPageSize = 1000
+
+// ExportController 处理 /export 接口的请求，按查询条件导出类中的数据
+type ExportController struct {
+	ClassesController
+}
+
+// HandleExport 以 CSV 或者 XLSX 格式导出查询结果，需要 Master 权限
+// 参数： where 查询条件， keys 导出的字段，以逗号分隔， order 排序， limit 最多导出的对象数，
+// format 为 csv （默认）或者 xlsx ，参数可以放在 URL 中，也可以放在 JSON 格式的 body 中
+// 分批查询并逐批写出，已经开始写出之后发生的错误只能记录到日志中，下载的文件不完整
+// @router /:className [get]
+func (e *ExportController) HandleExport() {
+	if e.EnforceMasterKeyAccess() == false {
+		return
+	}
+	className := e.Ctx.Input.Param(":className")
+
+	format := strings.ToLower(e.param("format"))
+	if format == "" {
+		format = "csv"
+	}
+	contentType := export.ContentTypes[format]
+	if contentType == "" {
+		e.HandleError(errs.E(errs.InvalidQuery, "Unsupported export format: "+format), 0)
+		return
+	}
+
+	where := types.M{}
+	if e.Query["where"] != "" {
+		err := json.Unmarshal([]byte(e.Query["where"]), &where)
+		if err != nil {
+			e.HandleError(errs.E(errs.InvalidJSON, "where should be valid json"), 0)
+			return
+		}
+	} else if e.JSONBody != nil && e.JSONBody["where"] != nil {
+		where = utils.M(e.JSONBody["where"])
+	}
+
+	limit := -1
+	if s := e.param("limit"); s != "" {
+		i, err := strconv.Atoi(s)
+		if err != nil || i < 0 {
+			e.HandleError(errs.E(errs.InvalidQuery, "limit should be a non-negative integer"), 0)
+			return
+		}
+		limit = i
+	}
+
+	var keys []string
+	options := types.M{}
+	if s := e.param("keys"); s != "" {
+		keys = strings.Split(s, ",")
+		options["keys"] = s
+	}
+	// 分批查询需要稳定的顺序，未指定时按创建时间排序
+	options["order"] = "createdAt,objectId"
+	if s := e.param("order"); s != "" {
+		options["order"] = s
+	}
+
+	schema, err := orm.TalismanDBController.LoadSchema(nil).GetOneSchema(className, false, nil)
+	if err != nil {
+		e.HandleError(err, 0)
+		return
+	}
+	columns := export.Columns(utils.M(schema["fields"]), keys)
+
+	// 第一批数据在写出之前查询，查询出错时可以正常返回错误
+	results, err := e.findPage(className, where, options, 0, limit)
+	if err != nil {
+		e.HandleError(err, 0)
+		return
+	}
+
+	e.Ctx.Output.Header("Content-Type", contentType)
+	e.Ctx.Output.Header("Content-Disposition", `attachment; filename="`+className+"."+format+`"`)
+	writer := export.NewWriter(format, e.Ctx.ResponseWriter)
+	err = writer.WriteRow(columns)
+	skip := 0
+	for err == nil && len(results) > 0 {
+		for _, result := range results {
+			err = writer.WriteRow(export.Row(utils.M(result), columns))
+			if err != nil {
+				break
+			}
+		}
+		skip += len(results)
+		if err != nil || len(results) < exportPageSize || (limit >= 0 && skip >= limit) {
+			break
+		}
+		results, err = e.findPage(className, where, options, skip, limit)
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		logger.Request(e.RequestID).Error("export", className, "failed after", skip, "objects:", err)
+	}
+}
+
+// findPage 查询从 skip 开始的一批对象， limit 为导出的总数， -1 表示不限制
+func (e *ExportController) findPage(className string, where, options types.M, skip, limit int) (types.S, error) {
+	pageSize := exportPageSize
+	if limit >= 0 && limit-skip < pageSize {
+		pageSize = limit - skip
+	}
+	if pageSize <= 0 {
+		return types.S{}, nil
+	}
+	pageOptions := utils.CopyMapM(options)
+	pageOptions["skip"] = skip
+	pageOptions["limit"] = pageSize
+	response, err := rest.Find(e.Auth, className, utils.CopyMapM(where), pageOptions, e.Info.ClientSDK)
+	if err != nil {
+		return nil, err
+	}
+	return utils.A(response["results"]), nil
+}
+
+// param 从 URL 或者 JSON 格式的 body 中获取字符串参数
+func (e *ExportController) param(key string) string {
+	if e.Query[key] != "" {
+		return e.Query[key]
+	}
+	if e.JSONBody == nil {
+		return ""
+	}
+	switch v := e.JSONBody[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.Itoa(int(v))
+	}
+	return ""
+}
@@ -1,11 +1,18 @@
 package controllers
 
 import (
+	"bytes"
+	"io"
+	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/okobsamoht/talisman/config"
 	"github.com/okobsamoht/talisman/errs"
 	"github.com/okobsamoht/talisman/files"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/rest"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
 )
@@ -17,39 +24,57 @@ type FilesController struct {
 
 // Prepare ...
 func (f *FilesController) Prepare() {
-	if f.Ctx.Input.Method() == "GET" && strings.HasPrefix(f.Ctx.Input.URL(), "/v1/files") {
+	method := f.Ctx.Input.Method()
+	if (method == "GET" || method == "HEAD") && strings.HasPrefix(f.Ctx.Input.URL(), "/v1/files") {
 		return
 	}
 	f.ClassesController.Prepare()
 }
 
-// HandleGet 处理下载文件请求
+// HandleGet 处理下载文件请求，支持 Range 请求与 ETag Last-Modified 条件请求
 // @router /:appId/:filename [get]
 func (f *FilesController) HandleGet() {
+	if files.VerifyOriginToken(f.Ctx.Input.Header(config.TConfig.FileOriginTokenHeader)) == false {
+		f.Ctx.Output.SetStatus(403)
+		f.Ctx.Output.Header("Content-Type", "text/plain")
+		f.Ctx.Output.Body([]byte("Invalid origin token."))
+		return
+	}
 	filename := f.Ctx.Input.Param(":filename")
 	contentType := utils.LookupContentType(filename)
+	if config.TConfig.ImageTransform {
+		options, sig, err := files.ParseImageOptions(f.Input())
+		if err != nil {
+			f.badImageRequest(400, err.Error())
+			return
+		}
+		if options != nil {
+			f.handleImage(filename, *options, sig)
+			return
+		}
+	}
 	if f.isFileStreamable() {
 		s, err := files.GetFileStream(filename)
 		if err != nil {
-			f.Ctx.Output.SetStatus(404)
-			f.Ctx.Output.Header("Content-Type", "text/plain")
-			f.Ctx.Output.Body([]byte("File not found."))
+			f.fileNotFound()
 			return
 		}
-		f.handleFileStream(s, contentType)
+		defer s.Close()
+		f.serveContent(filename, contentType, files.FileStreamETag(s), files.FileModTime(s), s)
 		return
 	}
 	data, err := files.GetFileData(filename)
 	if err != nil {
-		f.Ctx.Output.SetStatus(404)
-		f.Ctx.Output.Header("Content-Type", "text/plain")
-		f.Ctx.Output.Body([]byte("File not found."))
-	} else {
-		f.Ctx.Output.SetStatus(200)
-		f.Ctx.Output.Header("Content-Type", contentType)
-		f.Ctx.Output.Header("Content-Length", strconv.Itoa(len(data)))
-		f.Ctx.Output.Body(data)
+		f.fileNotFound()
+		return
 	}
+	f.serveContent(filename, contentType, files.FileDataETag(data), time.Time{}, bytes.NewReader(data))
+}
+
+// HandleHead 处理获取文件信息的请求，只返回头部
+// @router /:appId/:filename [head]
+func (f *FilesController) HandleHead() {
+	f.HandleGet()
 }
 
 // HandleCreate 处理上传文件请求
@@ -70,15 +95,17 @@ func (f *FilesController) HandleCreate() {
 		return
 	}
 	contentType := f.Ctx.Input.Header("Content-type")
-	result := files.CreateFile(filename, data, contentType)
-	if result != nil && result["url"] != "" {
-		f.Ctx.Output.SetStatus(201)
-		f.Ctx.Output.Header("location", result["url"])
-		f.Data["json"] = result
-		f.ServeJSON()
-	} else {
-		f.HandleError(errs.E(errs.FileSaveError, "Could not store file."), 0)
+	// 文件标签通过查询参数 tags 指定，多个使用 , 分隔
+	tags := orm.ParseFileTags(f.Query["tags"])
+	result, err := rest.SaveFileWithTags(f.Auth, filename, data, contentType, tags)
+	if err != nil {
+		f.HandleError(err, 0)
+		return
 	}
+	f.Ctx.Output.SetStatus(201)
+	f.Ctx.Output.Header("location", result["url"])
+	f.Data["json"] = result
+	f.ServeJSON()
 }
 
 // HandleDelete 处理删除文件请求
@@ -88,9 +115,9 @@ func (f *FilesController) HandleDelete() {
 		return
 	}
 	filename := f.Ctx.Input.Param(":filename")
-	err := files.DeleteFile(filename)
+	err := rest.DeleteFile(f.Auth, filename)
 	if err != nil {
-		f.HandleError(errs.E(errs.FileDeleteError, "Could not delete file."), 0)
+		f.HandleError(err, 0)
 		return
 	}
 	f.Data["json"] = types.M{}
@@ -122,9 +149,6 @@ func (f *FilesController) Delete() {
 }
 
 func (f *FilesController) isFileStreamable() bool {
-	if f.Ctx.Input.Header("Range") == "" {
-		return false
-	}
 	n := files.GetAdapterName()
 	if n == "fileSystemAdapter" || n == "gridStoreAdapter" {
 		return true
@@ -132,86 +156,42 @@ func (f *FilesController) isFileStreamable() bool {
 	return false
 }
 
-func (f *FilesController) handleFileStream(stream files.FileStream, contentType string) {
-	defer stream.Close()
-	var bufferSize = 1024 * 1024
-	var r = f.Ctx.Input.Header("Range")
-	r = strings.Replace(r, "bytes=", "", -1)
-	r = strings.Replace(r, " ", "", -1)
-	var parts = strings.Split(r, "-")
-	if len(parts) != 2 {
-		f.fileNotFound()
-		return
-	}
-	// 修正读取范围
-	var start, end, chunksize int
-	if parts[0] == "" {
-		start = 0
-	} else {
-		start, _ = strconv.Atoi(parts[0])
-	}
-	if parts[1] == "" {
-		end = int(stream.Size()) - 1
-	} else {
-		end, _ = strconv.Atoi(parts[1])
-	}
-	if start > end {
-		f.fileNotFound()
-		return
-	}
-	if start > int(stream.Size())-1 {
-		f.fileNotFound()
-		return
+// serveContent 返回文件内容，由 http.ServeContent 处理 Range If-Range If-None-Match If-Modified-Since 与 HEAD 请求，
+// modTime 为零值时不返回 Last-Modified
+func (f *FilesController) serveContent(filename, contentType, etag string, modTime time.Time, content io.ReadSeeker) {
+	f.Ctx.Output.Header("Content-Type", contentType)
+	f.Ctx.Output.Header("ETag", etag)
+	if cacheControl := config.TConfig.FileCacheControl; cacheControl != "" {
+		f.Ctx.Output.Header("Cache-Control", cacheControl)
 	}
-	chunksize = end - start + 1
+	http.ServeContent(f.Ctx.ResponseWriter, f.Ctx.Request, filename, modTime, content)
+}
 
-	if chunksize > bufferSize {
-		end = start + bufferSize - 1
-		chunksize = bufferSize
+// handleImage 校验签名之后返回处理过的图片，处理结果可以被客户端长期缓存
+func (f *FilesController) handleImage(filename string, options files.ImageOptions, sig string) {
+	err := files.VerifyImageSignature(filename, options, sig, time.Now())
+	if err != nil {
+		f.badImageRequest(403, err.Error())
+		return
 	}
-	// 设置 http 头部
-	f.Ctx.Output.SetStatus(206)
-	f.Ctx.Output.Header("Content-Range", "bytes "+strconv.Itoa(start)+"-"+strconv.Itoa(end)+"/"+strconv.Itoa(int(stream.Size())))
-	f.Ctx.Output.Header("Accept-Ranges", "bytes")
-	f.Ctx.Output.Header("Content-Length", strconv.Itoa(chunksize))
-	f.Ctx.Output.Header("Content-Type", contentType)
-	// 读取数据
-	_, err := stream.Seek(int64(start), 0)
+	data, contentType, err := files.GetTransformedImage(filename, options)
 	if err != nil {
 		f.fileNotFound()
 		return
 	}
-
-	var bufferAvail = 0
-	var size = (end - start) + 1
-	var totalbyteswanted = (end - start) + 1
-	var totalbyteswritten = 0
-
-	data := []byte{}
-	buf := make([]byte, 1024)
-	for {
-		n, _ := stream.Read(buf)
-		if n == 0 {
-			break
-		}
-		bufferAvail += n
-		if bufferAvail < size {
-			data = append(data, buf[:n]...)
-			totalbyteswritten += n
-			size -= n
-			bufferAvail -= n
-		} else {
-			data = append(data, buf[:size]...)
-			totalbyteswritten += n
-			bufferAvail -= size
-		}
-		if totalbyteswritten >= totalbyteswanted {
-			break
-		}
-	}
+	f.Ctx.Output.SetStatus(200)
+	f.Ctx.Output.Header("Content-Type", contentType)
+	f.Ctx.Output.Header("Content-Length", strconv.Itoa(len(data)))
+	f.Ctx.Output.Header("Cache-Control", "public, max-age=31536000")
 	f.Ctx.Output.Body(data)
 }
 
+func (f *FilesController) badImageRequest(status int, message string) {
+	f.Ctx.Output.SetStatus(status)
+	f.Ctx.Output.Header("Content-Type", "text/plain")
+	f.Ctx.Output.Body([]byte(message))
+}
+
 func (f *FilesController) fileNotFound() {
 	f.Ctx.Output.SetStatus(404)
 	f.Ctx.Output.Header("Content-Type", "text/plain")
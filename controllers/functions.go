@@ -49,6 +49,7 @@ func (f *FunctionsController) HandleCloudFunction() {
 		InstallationID: f.Info.InstallationID,
 		FunctionName:   functionName,
 		Headers:        headers,
+		RequestID:      f.RequestID,
 	}
 	if f.Auth != nil {
 		request.Master = f.Auth.IsMaster
@@ -3,7 +3,7 @@ package controllers
 import (
 	"strings"
 
-	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/globalconfig"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
 )
@@ -24,23 +24,11 @@ func (g *GlobalConfigController) Prepare() {
 // HandleGet 获取配置信息
 // @router / [get]
 func (g *GlobalConfigController) HandleGet() {
-	results, _ := orm.TalismanDBController.Find("_GlobalConfig", types.M{"objectId": "1"}, types.M{"limit": 1})
-	if len(results) != 1 {
-		g.Data["json"] = types.M{"params": types.M{}}
-		g.ServeJSON()
-		return
-	}
-	globalConfig := utils.M(results[0])
-	if globalConfig == nil {
-		g.Data["json"] = types.M{"params": types.M{}}
-		g.ServeJSON()
-		return
-	}
-	g.Data["json"] = types.M{"params": globalConfig["params"]}
+	g.Data["json"] = types.M{"params": globalconfig.Params()}
 	g.ServeJSON()
 }
 
-// HandlePut 修改配置信息
+// HandlePut 修改配置信息，仅允许 Master 修改
 // @router / [put]
 func (g *GlobalConfigController) HandlePut() {
 	if g.EnforceMasterKeyAccess() == false {
@@ -52,12 +40,7 @@ func (g *GlobalConfigController) HandlePut() {
 		g.ServeJSON()
 		return
 	}
-	params := utils.M(g.JSONBody["params"])
-	update := types.M{}
-	for k, v := range params {
-		update["params."+k] = v
-	}
-	_, err := orm.TalismanDBController.Update("_GlobalConfig", types.M{"objectId": "1"}, update, types.M{"upsert": true}, false)
+	err := globalconfig.Set(utils.M(g.JSONBody["params"]))
 	if err != nil {
 		g.HandleError(err, 0)
 		return
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/logger"
+	"github.com/okobsamoht/talisman/rest"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// ImpersonateController 处理 /impersonate 接口的请求
+type ImpersonateController struct {
+	ClassesController
+}
+
+// HandleImpersonate 以指定用户的身份创建有时效的 session ，需要 Master 权限
+// 请求数据： {"userId": "xxx", "operator": "alice", "reason": "ticket 123", "ttl": 600} ， operator 为必填的操作人
+// 每次调用都写入审计日志，成功时另外在 _Impersonation 中保存审计记录，包括操作人、使用的密钥、来源 IP 与原因，保存失败时请求失败
+// @router / [post]
+func (i *ImpersonateController) HandleImpersonate() {
+	if i.EnforceMasterKeyAccess() == false {
+		return
+	}
+	if i.JSONBody == nil {
+		i.HandleError(errs.E(errs.InvalidJSON, "request body is empty"), 0)
+		return
+	}
+	userID := utils.S(i.JSONBody["userId"])
+	if userID == "" {
+		i.HandleError(errs.E(errs.MissingObjectID, "userId is required"), 0)
+		return
+	}
+	operator := utils.S(i.JSONBody["operator"])
+	if operator == "" {
+		i.HandleError(errs.E(errs.ValidationError, "operator is required"), 0)
+		return
+	}
+	ttl := 0
+	if v, ok := i.JSONBody["ttl"].(float64); ok {
+		ttl = int(v)
+	}
+	key, _ := config.MatchAppKey(config.KeyTypeMaster, i.Info.MasterKey)
+	ip := config.ClientIP(i.Ctx.Request)
+	reason := utils.S(i.JSONBody["reason"])
+	impersonation := types.M{
+		"operator": operator,
+		"keyLabel": key.Label,
+		"ip":       ip,
+		"reason":   reason,
+	}
+
+	session, err := rest.Impersonate(userID, ttl, impersonation)
+	if err != nil {
+		logger.Request(i.RequestID).Warn("audit: impersonate user", userID, "by", operator, "key", key.Label, "from", ip, "failed:", err)
+		i.HandleError(err, 0)
+		return
+	}
+	logger.Request(i.RequestID).Warn("audit: impersonate user", userID, "by", operator, "key", key.Label, "from", ip,
+		"reason", reason, "session", session["objectId"], "expires", utils.M(session["expiresAt"])["iso"])
+	i.Data["json"] = session
+	i.ServeJSON()
+}
+
+// Get ...
+// @router / [get]
+func (i *ImpersonateController) Get() {
+	i.ClassesController.Get()
+}
+
+// Delete ...
+// @router / [delete]
+func (i *ImpersonateController) Delete() {
+	i.ClassesController.Delete()
+}
+
+// Put ...
+// @router / [put]
+func (i *ImpersonateController) Put() {
+	i.ClassesController.Put()
+}
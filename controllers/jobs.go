@@ -60,9 +60,10 @@ func (j *JobsController) runJob(jobName string) {
 	}
 
 	request := cloud.JobRequest{
-		Params:  params,
-		JobName: jobName,
-		Headers: headers,
+		Params:    params,
+		JobName:   jobName,
+		Headers:   headers,
+		RequestID: j.RequestID,
 	}
 	response := cloud.JobResponse{
 		JobStatus: jobHandler,
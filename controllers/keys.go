@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/logger"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// keyUsage 尚未写入数据库的密钥使用记录
+type keyUsage struct {
+	count      int
+	lastUsedAt time.Time
+	lastIP     string
+}
+
+// keyUsageFlushInterval 使用记录在内存中累积的时间，到期后批量写入 _KeyUsage ，避免每个请求都写数据库
+const keyUsageFlushInterval = 10 * time.Second
+
+var (
+	keyUsageMutex     sync.Mutex
+	keyUsages         = map[string]*keyUsage{}
+	keyUsageFlushedAt = time.Now()
+)
+
+// recordKeyUsage 记录一次密钥的使用，按 类型:标签 统计，定时在后台写入数据库
+func recordKeyUsage(key config.AppKey, ip string) {
+	keyUsageMutex.Lock()
+	defer keyUsageMutex.Unlock()
+	usage := keyUsages[key.Type+":"+key.Label]
+	if usage == nil {
+		usage = &keyUsage{}
+		keyUsages[key.Type+":"+key.Label] = usage
+	}
+	usage.count++
+	usage.lastUsedAt = time.Now()
+	usage.lastIP = ip
+	if time.Since(keyUsageFlushedAt) >= keyUsageFlushInterval {
+		go saveKeyUsages(takeKeyUsages())
+	}
+}
+
+// takeKeyUsages 取出尚未写入的使用记录，调用前需要持有 keyUsageMutex
+func takeKeyUsages() map[string]*keyUsage {
+	usages := keyUsages
+	keyUsages = map[string]*keyUsage{}
+	keyUsageFlushedAt = time.Now()
+	return usages
+}
+
+// saveKeyUsages 把使用记录累加到 _KeyUsage 中，写入失败时放回内存，等待下次写入
+func saveKeyUsages(usages map[string]*keyUsage) {
+	for id, usage := range usages {
+		keyType, label := splitKeyID(id)
+		err := orm.TalismanDBController.SaveKeyUsage(keyType, label, usage.count, usage.lastUsedAt, usage.lastIP)
+		if err != nil {
+			logger.Error("Save key usage failed:", id, err)
+			restoreKeyUsage(id, usage)
+		}
+	}
+}
+
+// restoreKeyUsage 把写入失败的记录合并回内存
+func restoreKeyUsage(id string, usage *keyUsage) {
+	keyUsageMutex.Lock()
+	defer keyUsageMutex.Unlock()
+	current := keyUsages[id]
+	if current == nil {
+		keyUsages[id] = usage
+		return
+	}
+	current.count += usage.count
+	if current.lastUsedAt.Before(usage.lastUsedAt) {
+		current.lastUsedAt = usage.lastUsedAt
+		current.lastIP = usage.lastIP
+	}
+}
+
+// FlushKeyUsages 立即写入内存中的使用记录，停止服务前调用
+func FlushKeyUsages() {
+	keyUsageMutex.Lock()
+	usages := takeKeyUsages()
+	keyUsageMutex.Unlock()
+	saveKeyUsages(usages)
+}
+
+// matchClientKey 依次匹配请求中的 ClientKey JavaScriptKey RestAPIKey DotNetKey
+func matchClientKey(info *RequestInfo) (config.AppKey, bool) {
+	candidates := []struct {
+		keyType string
+		key     string
+	}{
+		{config.KeyTypeClient, info.ClientKey},
+		{config.KeyTypeJavaScript, info.JavaScriptKey},
+		{config.KeyTypeRestAPI, info.RestAPIKey},
+		{config.KeyTypeDotNet, info.DotNetKey},
+	}
+	for _, c := range candidates {
+		if key, ok := config.MatchAppKey(c.keyType, c.key); ok {
+			return key, true
+		}
+	}
+	return config.AppKey{}, false
+}
+
+// KeysController 查看密钥的使用情况，用于确认轮换前的旧密钥是否仍在使用
+type KeysController struct {
+	ClassesController
+}
+
+// HandleGet 返回全部密钥的标签、过期时间与使用记录，不返回密钥的值
+// 已从配置中删除但是有使用记录的密钥 configured 为 false
+// @router / [get]
+func (k *KeysController) HandleGet() {
+	if k.EnforceMasterKeyAccess() == false {
+		return
+	}
+
+	// 先写入内存中的记录，再从数据库中读取所有实例的使用记录
+	FlushKeyUsages()
+	records, err := orm.TalismanDBController.FindKeyUsages()
+	if err != nil {
+		k.HandleError(err, 0)
+		return
+	}
+	usages := map[string]types.M{}
+	for _, r := range records {
+		if record := utils.M(r); record != nil {
+			usages[utils.S(record["objectId"])] = record
+		}
+	}
+
+	now := time.Now()
+	results := types.S{}
+	for _, key := range config.ListAppKeys() {
+		item := types.M{
+			"type":       key.Type,
+			"label":      key.Label,
+			"configured": true,
+			"expired":    key.Expired(now),
+		}
+		if key.ExpiresAt.IsZero() == false {
+			item["expiresAt"] = utils.TimetoString(key.ExpiresAt)
+		}
+		setKeyUsage(item, usages[key.Type+":"+key.Label])
+		delete(usages, key.Type+":"+key.Label)
+		results = append(results, item)
+	}
+	ids := []string{}
+	for id := range usages {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		keyType, label := splitKeyID(id)
+		item := types.M{
+			"type":       keyType,
+			"label":      label,
+			"configured": false,
+		}
+		setKeyUsage(item, usages[id])
+		results = append(results, item)
+	}
+
+	k.Data["json"] = types.M{"results": results}
+	k.ServeJSON()
+}
+
+// setKeyUsage 把 _KeyUsage 中的使用记录写入 item ，没有记录时 count 为 0
+func setKeyUsage(item types.M, usage types.M) {
+	if usage == nil {
+		item["count"] = 0
+		return
+	}
+	item["count"] = usage["count"]
+	if lastUsedAt, err := utils.DateToTime(usage["lastUsedAt"]); err == nil {
+		item["lastUsedAt"] = utils.TimetoString(lastUsedAt)
+	}
+	item["lastIP"] = usage["lastIP"]
+}
+
+// splitKeyID 拆分 类型:标签 格式的记录 ID
+func splitKeyID(id string) (string, string) {
+	parts := strings.SplitN(id, ":", 2)
+	if len(parts) != 2 {
+		return id, ""
+	}
+	return parts[0], parts[1]
+}
+
+// Post ...
+// @router / [post]
+func (k *KeysController) Post() {
+	k.ClassesController.Post()
+}
+
+// Delete ...
+// @router / [delete]
+func (k *KeysController) Delete() {
+	k.ClassesController.Delete()
+}
+
+// Put ...
+// @router / [put]
+func (k *KeysController) Put() {
+	k.ClassesController.Put()
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/okobsamoht/talisman/files"
 	"github.com/okobsamoht/talisman/orm"
 	"github.com/okobsamoht/talisman/rest"
+	"github.com/okobsamoht/talisman/throttle"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
 )
@@ -32,6 +33,7 @@ func (l *LoginController) HandleLogIn() {
 		password = l.Query["password"]
 	}
 
+	username = config.NormalizeUsername(username)
 	if username == "" {
 		l.HandleError(errs.E(errs.UsernameMissing, "username is required."), 0)
 		return
@@ -44,12 +46,13 @@ func (l *LoginController) HandleLogIn() {
 	where := types.M{
 		"username": username,
 	}
-	results, err := orm.TalismanDBController.Find("_User", where, types.M{})
+	results, err := orm.TalismanDBController.Find("_User", where, rest.UsernameLookupOptions(types.M{}))
 	if err != nil {
 		l.HandleError(err, 0)
 		return
 	}
 	if results == nil || len(results) == 0 {
+		l.loginFailed(username)
 		l.HandleError(errs.E(errs.ObjectNotFound, "Invalid username/password."), 0)
 		return
 	}
@@ -76,9 +79,18 @@ func (l *LoginController) HandleLogIn() {
 		return
 	}
 	if correct == false {
+		l.loginFailed(username)
 		l.HandleError(errs.E(errs.ObjectNotFound, "Invalid username/password."), 0)
 		return
 	}
+	if throttle.Login != nil {
+		throttle.Login.Succeed(config.ClientIP(l.Ctx.Request), username)
+	}
+	// 已合并到其他用户的账号不能再登录
+	if user["mergedInto"] != nil {
+		l.HandleError(errs.E(errs.ObjectNotFound, "This account has been merged into another account."), 0)
+		return
+	}
 
 	// 检测密码是否过期
 	if config.TConfig.PasswordPolicy && config.TConfig.MaxPasswordAge > 0 {
@@ -97,7 +109,7 @@ func (l *LoginController) HandleLogIn() {
 		}
 	}
 
-	token := "r:" + utils.CreateToken()
+	token, expiresAt := rest.NewSessionToken(utils.S(user["objectId"]))
 	user["sessionToken"] = token
 	delete(user, "password")
 
@@ -116,7 +128,6 @@ func (l *LoginController) HandleLogIn() {
 	// 展开文件信息
 	files.ExpandFilesInObject(user)
 
-	expiresAt := config.GenerateSessionExpiresAt()
 	usr := types.M{
 		"__type":    "Pointer",
 		"className": "_User",
@@ -150,6 +161,14 @@ func (l *LoginController) HandleLogIn() {
 		l.HandleError(err, 0)
 		return
 	}
+	if config.TConfig.RefreshTokens {
+		refreshToken, err := rest.NewRefreshToken(utils.S(user["objectId"]), token, "")
+		if err != nil {
+			l.HandleError(err, 0)
+			return
+		}
+		user["refreshToken"] = refreshToken
+	}
 
 	l.Data["json"] = user
 	l.ServeJSON()
@@ -173,3 +192,10 @@ func (l *LoginController) Delete() {
 func (l *LoginController) Put() {
 	l.ClassesController.Put()
 }
+
+// loginFailed 记录登录失败次数，超过阈值后由 throttle.Login 的过滤器拒绝请求
+func (l *LoginController) loginFailed(username string) {
+	if throttle.Login != nil {
+		throttle.Login.Fail(config.ClientIP(l.Ctx.Request), username)
+	}
+}
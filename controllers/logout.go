@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"github.com/okobsamoht/talisman/config"
 	"github.com/okobsamoht/talisman/rest"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
@@ -33,6 +34,13 @@ func (l *LogoutController) HandleLogOut() {
 				return
 			}
 		}
+		if config.TConfig.RefreshTokens {
+			err := rest.RevokeRefreshTokens(types.M{"sessionToken": l.Info.SessionToken})
+			if err != nil {
+				l.HandleError(err, 0)
+				return
+			}
+		}
 	}
 	l.Data["json"] = types.M{}
 	l.ServeJSON()
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"crypto/subtle"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/mail"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+)
+
+// MailEventsController 处理 /mail/events 接口的请求，接收 SendGrid 与 Mailgun 推送的邮件投递事件
+// 服务商的回调不携带应用的密钥，通过回调地址中的 token 参数鉴权，如 /v1/mail/events/sendgrid?token=xxx
+type MailEventsController struct {
+	ClassesController
+}
+
+// Prepare 校验 token ，未设置 MailEventsToken 时不接收事件
+func (m *MailEventsController) Prepare() {
+	expect := config.TConfig.MailEventsToken
+	token := m.GetString("token")
+	if expect == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expect)) != 1 {
+		m.HandleError(errs.E(errs.OperationForbidden, "Invalid mail events token."), 0)
+		return
+	}
+}
+
+// HandleSendGrid 保存 SendGrid 推送的事件，一次请求包含多个事件
+// @router /sendgrid [post]
+func (m *MailEventsController) HandleSendGrid() {
+	events, err := mail.ParseSendGridEvents(m.Ctx.Input.RequestBody)
+	if err != nil {
+		m.HandleError(errs.E(errs.InvalidJSON, "Invalid SendGrid events."), 0)
+		return
+	}
+	m.saveEvents(events)
+}
+
+// HandleMailgun 保存 Mailgun 推送的事件，设置了 MailgunWebhookSigningKey 时校验签名
+// @router /mailgun [post]
+func (m *MailEventsController) HandleMailgun() {
+	event, err := mail.ParseMailgunEvent(m.Ctx.Input.RequestBody, config.TConfig.MailgunWebhookSigningKey)
+	if err != nil {
+		m.HandleError(errs.E(errs.InvalidJSON, err.Error()), 0)
+		return
+	}
+	m.saveEvents([]*mail.Event{event})
+}
+
+func (m *MailEventsController) saveEvents(events []*mail.Event) {
+	for _, e := range events {
+		err := orm.TalismanDBController.SaveEmailEvent(orm.NewEmailEvent(e.Provider, e.Email, e.Event, e.MessageID, e.Reason, e.Timestamp))
+		if err != nil {
+			m.HandleError(err, 0)
+			return
+		}
+	}
+	m.Data["json"] = types.M{}
+	m.ServeJSON()
+}
+
+// Get ...
+// @router / [get]
+func (m *MailEventsController) Get() {
+	m.ClassesController.Get()
+}
+
+// Post ...
+// @router / [post]
+func (m *MailEventsController) Post() {
+	m.ClassesController.Post()
+}
+
+// Delete ...
+// @router / [delete]
+func (m *MailEventsController) Delete() {
+	m.ClassesController.Delete()
+}
+
+// Put ...
+// @router / [put]
+func (m *MailEventsController) Put() {
+	m.ClassesController.Put()
+}
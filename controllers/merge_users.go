@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/logger"
+	"github.com/okobsamoht/talisman/usermerge"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// MergeUsersController 处理 /mergeUsers 接口的请求
+type MergeUsersController struct {
+	ClassesController
+}
+
+// HandleMerge 把重复用户合并到主用户中，返回处理报告，需要 Master 权限
+// 请求数据： {"primaryId": "xxx", "duplicateId": "yyy", "dryRun": true}
+// @router / [post]
+func (m *MergeUsersController) HandleMerge() {
+	if m.EnforceMasterKeyAccess() == false {
+		return
+	}
+	if m.JSONBody == nil {
+		m.HandleError(errs.E(errs.InvalidJSON, "request body is empty"), 0)
+		return
+	}
+	primaryID := utils.S(m.JSONBody["primaryId"])
+	duplicateID := utils.S(m.JSONBody["duplicateId"])
+	if primaryID == "" || duplicateID == "" {
+		m.HandleError(errs.E(errs.MissingObjectID, "primaryId and duplicateId are required"), 0)
+		return
+	}
+	dryRun, _ := m.JSONBody["dryRun"].(bool)
+	report, err := usermerge.Merge(primaryID, duplicateID, dryRun)
+	if err != nil {
+		m.HandleError(err, 0)
+		return
+	}
+	if dryRun == false {
+		logger.Request(m.RequestID).Warn("audit: merge user", duplicateID, "into", primaryID, "from", config.ClientIP(m.Ctx.Request), "result:", report["duplicate"])
+	}
+	m.Data["json"] = report
+	m.ServeJSON()
+}
+
+// Get ...
+// @router / [get]
+func (m *MergeUsersController) Get() {
+	m.ClassesController.Get()
+}
+
+// Delete ...
+// @router / [delete]
+func (m *MergeUsersController) Delete() {
+	m.ClassesController.Delete()
+}
+
+// Put ...
+// @router / [put]
+func (m *MergeUsersController) Put() {
+	m.ClassesController.Put()
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/sms"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// PhoneVerificationController 处理 /phoneVerificationRequest 接口的请求
+type PhoneVerificationController struct {
+	ClassesController
+}
+
+// HandlePhoneVerificationRequest 发送手机验证码，之后使用 authData.phone 登录或者注册
+// @router / [post]
+func (p *PhoneVerificationController) HandlePhoneVerificationRequest() {
+	if p.JSONBody == nil || utils.S(p.JSONBody["phone"]) == "" {
+		p.HandleError(errs.E(errs.ValidationError, "you must provide a phone number"), 0)
+		return
+	}
+	err := sms.SendCode(utils.S(p.JSONBody["phone"]))
+	if err != nil {
+		p.HandleError(err, 0)
+		return
+	}
+	p.Data["json"] = types.M{}
+	p.ServeJSON()
+}
+
+// Get ...
+// @router / [get]
+func (p *PhoneVerificationController) Get() {
+	p.ClassesController.Get()
+}
+
+// Delete ...
+// @router / [delete]
+func (p *PhoneVerificationController) Delete() {
+	p.ClassesController.Delete()
+}
+
+// Put ...
+// @router / [put]
+func (p *PhoneVerificationController) Put() {
+	p.ClassesController.Put()
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/logger"
+	"github.com/okobsamoht/talisman/privacy"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// PrivacyController 处理 /privacy 接口的请求
+type PrivacyController struct {
+	ClassesController
+}
+
+// HandleErase 删除或者匿名化用户的个人数据，返回处理报告，需要 Master 权限
+// 请求数据： {"userId": "xxx", "mode": "erase", "dryRun": true} ， mode 为 erase （默认）或者 anonymize
+// @router /erase [post]
+func (p *PrivacyController) HandleErase() {
+	if p.EnforceMasterKeyAccess() == false {
+		return
+	}
+	if p.JSONBody == nil {
+		p.HandleError(errs.E(errs.InvalidJSON, "request body is empty"), 0)
+		return
+	}
+	userID := utils.S(p.JSONBody["userId"])
+	if userID == "" {
+		p.HandleError(errs.E(errs.MissingObjectID, "userId is required"), 0)
+		return
+	}
+	dryRun, _ := p.JSONBody["dryRun"].(bool)
+	report, err := privacy.Erase(userID, utils.S(p.JSONBody["mode"]), dryRun)
+	if err != nil {
+		p.HandleError(err, 0)
+		return
+	}
+	p.Data["json"] = report
+	p.ServeJSON()
+}
+
+// HandleExport 以 JSON 格式导出用户的个人数据
+// 使用 Master Key ，或者使用该用户自己的 Session （开启 VerifyUserEmails 时需要已验证邮箱）
+// 用户自己导出时受 DataExportLimit 限制
+// @router /export/:userId [get]
+func (p *PrivacyController) HandleExport() {
+	userID := p.Ctx.Input.Param(":userId")
+	var acl []string
+	if p.Auth.IsMaster == false {
+		if p.Auth.User == nil || utils.S(p.Auth.User["objectId"]) != userID {
+			p.HandleError(errs.E(errs.OperationForbidden, "Only the user or the master key can export this data."), 0)
+			return
+		}
+		if config.TConfig.VerifyUserEmails && p.Auth.User["emailVerified"] != true {
+			p.HandleError(errs.E(errs.EmailNotFound, "User email is not verified."), 0)
+			return
+		}
+		if err := privacy.CheckExportLimit(userID); err != nil {
+			p.HandleError(err, 0)
+			return
+		}
+		// 用户自己导出时只能导出有读权限的对象
+		acl = append([]string{userID}, p.Auth.GetUserRoles()...)
+	}
+
+	export, err := privacy.NewExport(userID, acl)
+	if err != nil {
+		p.HandleError(err, 0)
+		return
+	}
+	p.Ctx.Output.Header("Content-Type", "application/json; charset=utf-8")
+	p.Ctx.Output.Header("Content-Disposition", `attachment; filename="`+userID+`.json"`)
+	if err := export.Stream(p.Ctx.ResponseWriter); err != nil {
+		logger.Request(p.RequestID).Error("export user data", userID, "failed:", err)
+	}
+}
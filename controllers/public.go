@@ -7,6 +7,7 @@ import (
 	"github.com/okobsamoht/talisman/config"
 	"github.com/okobsamoht/talisman/publichtml"
 	"github.com/okobsamoht/talisman/rest"
+	"github.com/okobsamoht/talisman/types"
 )
 
 // PublicController 处理密码修改与邮箱验证请求
@@ -71,8 +72,7 @@ func (p *PublicController) ChangePassword() {
 	}
 
 	data := strings.Replace(publichtml.ChoosePasswordPage, "PARSE_SERVER_URL", `"`+config.TConfig.ServerURL+`"`, -1)
-	p.Ctx.Output.Header("Content-Type", "text/html")
-	p.Ctx.Output.Body([]byte(data))
+	p.renderPage(publichtml.ChoosePasswordTemplate, data)
 }
 
 // ResetPassword 处理实际的重置密码请求
@@ -142,44 +142,60 @@ func (p *PublicController) RequestResetPassword() {
 // InvalidLink 无效链接页面
 // @router /invalid_link [get]
 func (p *PublicController) InvalidLink() {
-	p.Ctx.Output.Header("Content-Type", "text/html")
-	p.Ctx.Output.Body([]byte(publichtml.InvalidLinkPage))
+	p.renderPage(publichtml.InvalidLinkTemplate, publichtml.InvalidLinkPage)
 }
 
 // InvalidVerificationLink 无效验证链接页面
 // @router /invalid_verification_link [get]
 func (p *PublicController) InvalidVerificationLink() {
-	data := strings.Replace(publichtml.InvalidVerificationLink, "RESEND_VERIFICATION_URL", config.TConfig.ServerURL+"/apps/resend_verification_email", -1)
-	p.Ctx.Output.Header("Content-Type", "text/html")
-	p.Ctx.Output.Body([]byte(data))
+	data := strings.Replace(publichtml.InvalidVerificationLink, "RESEND_VERIFICATION_URL", resendVerificationURL(), -1)
+	p.renderPage(publichtml.InvalidVerificationLinkTemplate, data)
 }
 
 // LinkSendSuccess 发送成功页面
 // @router /link_send_success [get]
 func (p *PublicController) LinkSendSuccess() {
-	p.Ctx.Output.Header("Content-Type", "text/html")
-	p.Ctx.Output.Body([]byte(publichtml.LinkSendSuccess))
+	p.renderPage(publichtml.LinkSendSuccessTemplate, publichtml.LinkSendSuccess)
 }
 
 // LinkSendFail 发送失败页面
 // @router /link_send_fail [get]
 func (p *PublicController) LinkSendFail() {
-	p.Ctx.Output.Header("Content-Type", "text/html")
-	p.Ctx.Output.Body([]byte(publichtml.LinkSendFail))
+	p.renderPage(publichtml.LinkSendFailTemplate, publichtml.LinkSendFail)
 }
 
 // PasswordResetSuccess 密码重置成功页面
 // @router /password_reset_success [get]
 func (p *PublicController) PasswordResetSuccess() {
-	p.Ctx.Output.Header("Content-Type", "text/html")
-	p.Ctx.Output.Body([]byte(publichtml.PasswordResetSuccessPage))
+	p.renderPage(publichtml.PasswordResetSuccessTemplate, publichtml.PasswordResetSuccessPage)
 }
 
 // VerifyEmailSuccess 验证邮箱成功页面
 // @router /verify_email_success [get]
 func (p *PublicController) VerifyEmailSuccess() {
+	p.renderPage(publichtml.VerifyEmailSuccessTemplate, publichtml.VerifyEmailSuccessPage)
+}
+
+// renderPage 返回页面，存在自定义模板时使用模板渲染，否则返回内置页面 builtin
+func (p *PublicController) renderPage(name, builtin string) {
+	data := types.M{
+		"appName":               config.TConfig.AppName,
+		"appId":                 config.TConfig.AppID,
+		"serverURL":             config.TConfig.ServerURL,
+		"username":              p.GetString("username"),
+		"token":                 p.GetString("token"),
+		"error":                 p.GetString("error"),
+		"resendVerificationURL": resendVerificationURL(),
+	}
+	if page, ok := publichtml.Render(name, data); ok {
+		builtin = page
+	}
 	p.Ctx.Output.Header("Content-Type", "text/html")
-	p.Ctx.Output.Body([]byte(publichtml.VerifyEmailSuccessPage))
+	p.Ctx.Output.Body([]byte(builtin))
+}
+
+func resendVerificationURL() string {
+	return config.TConfig.ServerURL + "/apps/resend_verification_email"
 }
 
 func (p *PublicController) invalid() {
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"github.com/okobsamoht/talisman/audiences"
 	"github.com/okobsamoht/talisman/errs"
 	"github.com/okobsamoht/talisman/push"
 	"github.com/okobsamoht/talisman/types"
@@ -22,6 +23,16 @@ func (p *PushController) HandlePost() {
 		p.HandleError(errs.E(errs.InvalidJSON, "request body is empty"), 0)
 		return
 	}
+	// 指定受众且未指定查询条件时，使用受众的查询条件
+	audienceID := utils.S(p.JSONBody["audience_id"])
+	if audienceID != "" && p.JSONBody["where"] == nil && p.JSONBody["channels"] == nil {
+		audienceWhere, err := audiences.Query(audienceID)
+		if err != nil {
+			p.HandleError(err, 0)
+			return
+		}
+		p.JSONBody["where"] = audienceWhere
+	}
 	where, err := getQueryCondition(p.JSONBody)
 	if err != nil {
 		p.HandleError(err, 0)
@@ -35,6 +46,9 @@ func (p *PushController) HandlePost() {
 		p.HandleError(err, 0)
 		return
 	}
+	if audienceID != "" {
+		audiences.TrackUsage(audienceID)
+	}
 	p.Data["json"] = types.M{"result": true}
 	p.ServeJSON()
 }
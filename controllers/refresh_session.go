@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/rest"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// RefreshSessionController 处理 /refreshSession 接口的请求
+type RefreshSessionController struct {
+	ClassesController
+}
+
+// HandleRefreshSession 使用 refreshToken 换取新的 sessionToken 与 refreshToken
+// 请求中不需要携带已过期的 sessionToken
+// @router / [post]
+func (r *RefreshSessionController) HandleRefreshSession() {
+	if config.TConfig.RefreshTokens == false {
+		r.HandleError(errs.E(errs.OperationForbidden, "Refresh tokens are disabled."), 0)
+		return
+	}
+	if r.JSONBody == nil || utils.S(r.JSONBody["refreshToken"]) == "" {
+		r.HandleError(errs.E(errs.InvalidSessionToken, "refreshToken is required."), 0)
+		return
+	}
+	result, err := rest.RefreshSession(utils.S(r.JSONBody["refreshToken"]), r.Info.InstallationID)
+	if err != nil {
+		r.HandleError(err, 0)
+		return
+	}
+	r.Data["json"] = result
+	r.ServeJSON()
+}
+
+// Get ...
+// @router / [get]
+func (r *RefreshSessionController) Get() {
+	r.ClassesController.Get()
+}
+
+// Delete ...
+// @router / [delete]
+func (r *RefreshSessionController) Delete() {
+	r.ClassesController.Delete()
+}
+
+// Put ...
+// @router / [put]
+func (r *RefreshSessionController) Put() {
+	r.ClassesController.Put()
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/rest"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// RevisionsController 处理 /revisions 接口的请求，查看对象的修订记录与恢复到之前的版本
+type RevisionsController struct {
+	ClassesController
+}
+
+// HandleFind 按时间倒序返回对象的修订记录，需要 Master 权限
+// 参数： skip 、 limit ，返回的 values 为被修改字段在修改前的值
+// @router /:className/:objectId [get]
+func (r *RevisionsController) HandleFind() {
+	if r.EnforceMasterKeyAccess() == false {
+		return
+	}
+	options := types.M{"order": "-createdAt"}
+	for _, key := range []string{"skip", "limit"} {
+		if s := r.Query[key]; s != "" {
+			i, err := strconv.Atoi(s)
+			if err != nil || i < 0 {
+				r.HandleError(errs.E(errs.InvalidQuery, key+" should be a non-negative integer"), 0)
+				return
+			}
+			options[key] = i
+		}
+	}
+	where := types.M{
+		"targetClass": r.Ctx.Input.Param(":className"),
+		"targetId":    r.Ctx.Input.Param(":objectId"),
+	}
+	response, err := rest.Find(r.Auth, orm.RevisionClassName, where, options, r.Info.ClientSDK)
+	if err != nil {
+		r.HandleError(err, 0)
+		return
+	}
+	for _, v := range utils.A(response["results"]) {
+		revision := utils.M(v)
+		values := types.M{}
+		if json.Unmarshal([]byte(utils.S(revision["values"])), &values) == nil {
+			revision["values"] = values
+		}
+	}
+	r.Data["json"] = response
+	r.ServeJSON()
+}
+
+// HandleRestore 把对象恢复到修订记录 revisionId 之前的状态，需要 Master 权限
+// 修订记录中的字段恢复为修改前的值，修改前不存在的字段被删除，本次恢复也会生成一条修订记录
+// @router /:className/:objectId/:revisionId/restore [post]
+func (r *RevisionsController) HandleRestore() {
+	if r.EnforceMasterKeyAccess() == false {
+		return
+	}
+	className := r.Ctx.Input.Param(":className")
+	objectID := r.Ctx.Input.Param(":objectId")
+	where := types.M{
+		"objectId":    r.Ctx.Input.Param(":revisionId"),
+		"targetClass": className,
+		"targetId":    objectID,
+	}
+	response, err := rest.Find(r.Auth, orm.RevisionClassName, where, types.M{}, r.Info.ClientSDK)
+	if err != nil {
+		r.HandleError(err, 0)
+		return
+	}
+	if utils.HasResults(response) == false {
+		r.HandleError(errs.E(errs.ObjectNotFound, "Revision not found."), 0)
+		return
+	}
+	update, err := orm.RestoreUpdate(utils.M(utils.A(response["results"])[0]))
+	if err != nil {
+		r.HandleError(err, 0)
+		return
+	}
+	result, err := rest.Update(r.Auth, className, objectID, update, r.Info.ClientSDK)
+	if err != nil {
+		r.HandleError(err, 0)
+		return
+	}
+	r.Data["json"] = result["response"]
+	r.ServeJSON()
+}
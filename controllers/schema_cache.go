@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+)
+
+// SchemaCacheController 处理 /schemaCache 接口的请求
+type SchemaCacheController struct {
+	ClassesController
+}
+
+// HandleDelete 清除所有实例的 Schema 缓存，直接修改数据库中的类结构之后调用，可以发送到任意实例
+// 缓存模块为 Redis 时通知其他实例，其他缓存模块只清除接收请求的实例
+// @router / [delete]
+func (s *SchemaCacheController) HandleDelete() {
+	if s.EnforceMasterKeyAccess() == false {
+		return
+	}
+	orm.TalismanDBController.InvalidateSchemaCache()
+	s.Data["json"] = types.M{}
+	s.ServeJSON()
+}
+
+// Get ...
+// @router / [get]
+func (s *SchemaCacheController) Get() {
+	s.ClassesController.Get()
+}
+
+// Post ...
+// @router / [post]
+func (s *SchemaCacheController) Post() {
+	s.ClassesController.Post()
+}
+
+// Put ...
+// @router / [put]
+func (s *SchemaCacheController) Put() {
+	s.ClassesController.Put()
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// SchemaPermissionsController 处理 /schema_permissions 接口的请求，仅允许 Master 权限访问
+type SchemaPermissionsController struct {
+	ClassesController
+}
+
+// Prepare ...
+func (s *SchemaPermissionsController) Prepare() {
+	s.ClassesController.Prepare()
+	if s.Ctx.ResponseWriter.Started == false {
+		s.EnforceMasterKeyAccess()
+	}
+}
+
+// HandleTighten 批量收紧类级别权限， mode 可选 authenticated masterKey
+// 请求体如： {"mode": "authenticated", "classNames": ["Post", "Comment"]} ， classNames 为空时处理所有非系统类
+// @router /tighten [post]
+func (s *SchemaPermissionsController) HandleTighten() {
+	var data = s.JSONBody
+	if data == nil {
+		s.HandleError(errs.E(errs.InvalidJSON, "request body is empty"), 0)
+		return
+	}
+
+	classNames := []string{}
+	if v, ok := data["classNames"]; ok {
+		names := utils.A(v)
+		if names == nil {
+			s.HandleError(errs.E(errs.InvalidJSON, "classNames must be an array"), 0)
+			return
+		}
+		for _, name := range names {
+			className := utils.S(name)
+			if className == "" {
+				s.HandleError(errs.E(errs.InvalidJSON, "classNames must be an array of string"), 0)
+				return
+			}
+			classNames = append(classNames, className)
+		}
+	}
+
+	schema := orm.TalismanDBController.LoadSchema(types.M{"clearCache": true})
+	results, err := schema.TightenClassLevelPermissions(utils.S(data["mode"]), classNames)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
+
+	s.Data["json"] = types.M{
+		"results": results,
+	}
+	s.ServeJSON()
+}
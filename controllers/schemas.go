@@ -32,6 +32,13 @@ func (s *SchemasController) HandleFind() {
 		s.ServeJSON()
 		return
 	}
+	for i, sch := range schemas {
+		schemas[i], err = withIndexes(sch)
+		if err != nil {
+			s.HandleError(err, 0)
+			return
+		}
+	}
 	s.Data["json"] = types.M{
 		"results": schemas,
 	}
@@ -48,6 +55,11 @@ func (s *SchemasController) HandleGet() {
 		s.HandleError(errs.E(errs.InvalidClassName, "Class "+className+" does not exist."), 0)
 		return
 	}
+	sch, err = withIndexes(sch)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
 	s.Data["json"] = sch
 	s.ServeJSON()
 }
@@ -86,6 +98,18 @@ func (s *SchemasController) HandleCreate() {
 		s.HandleError(err, 0)
 		return
 	}
+	err = orm.TalismanDBController.UpdateIndexes(className, utils.M(data["indexes"]))
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
+	// 通知其他实例类结构已修改
+	orm.TalismanDBController.InvalidateSchemaCache()
+	result, err = withIndexes(result)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
 
 	s.Data["json"] = result
 	s.ServeJSON()
@@ -121,6 +145,18 @@ func (s *SchemasController) HandleUpdate() {
 		s.HandleError(err, 0)
 		return
 	}
+	err = orm.TalismanDBController.UpdateIndexes(className, utils.M(data["indexes"]))
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
+	// 通知其他实例类结构已修改
+	orm.TalismanDBController.InvalidateSchemaCache()
+	result, err = withIndexes(result)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
 
 	s.Data["json"] = result
 	s.ServeJSON()
@@ -140,12 +176,230 @@ func (s *SchemasController) HandleDelete() {
 		s.HandleError(err, 0)
 		return
 	}
+	orm.TalismanDBController.InvalidateSchemaCache()
 
 	s.Data["json"] = types.M{}
 	s.ServeJSON()
 	return
 }
 
+// HandleLock 锁定类的字段，之后只有 Master Key 可以添加字段
+// @router /:className/lock [post]
+func (s *SchemasController) HandleLock() {
+	s.setAllowAddField(false)
+}
+
+// HandleUnlock 解锁类的字段
+// @router /:className/unlock [post]
+func (s *SchemasController) HandleUnlock() {
+	s.setAllowAddField(true)
+}
+
+// HandleMasterOnlyFields 设置只能使用 Master Key 写入的字段，类级别权限的其他项保持不变
+// 请求数据： {"masterOnlyFields": ["isAdmin", "credits"]} ，为空数组时删除该设置
+// @router /:className/masterOnlyFields [put]
+func (s *SchemasController) HandleMasterOnlyFields() {
+	className := s.Ctx.Input.Param(":className")
+	if orm.ClassNameIsValid(className) == false {
+		s.HandleError(errs.E(errs.InvalidClassName, orm.InvalidClassNameMessage(className)), 0)
+		return
+	}
+	if s.JSONBody == nil {
+		s.HandleError(errs.E(errs.InvalidJSON, "request body is empty"), 0)
+		return
+	}
+	fields := []string{}
+	for _, v := range utils.A(s.JSONBody["masterOnlyFields"]) {
+		fieldName, ok := v.(string)
+		if ok == false {
+			s.HandleError(errs.E(errs.InvalidJSON, "masterOnlyFields must be an array of field names"), 0)
+			return
+		}
+		fields = append(fields, fieldName)
+	}
+
+	schema := orm.TalismanDBController.LoadSchema(types.M{"clearCache": true})
+	result, err := schema.SetMasterOnlyFields(className, fields)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
+
+	s.Data["json"] = result
+	s.ServeJSON()
+}
+
+// HandleCreateIndex 为类的字段创建索引，目前仅支持 String 字段上不区分大小写的索引
+// 请求格式： {"fieldName":"username","caseInsensitive":true}
+// @router /:className/indexes [post]
+func (s *SchemasController) HandleCreateIndex() {
+	className := s.Ctx.Input.Param(":className")
+	if s.JSONBody == nil {
+		s.HandleError(errs.E(errs.InvalidJSON, "request body is empty"), 0)
+		return
+	}
+	fieldName := utils.S(s.JSONBody["fieldName"])
+	if fieldName == "" {
+		s.HandleError(errs.E(errs.InvalidJSON, "fieldName is required"), 0)
+		return
+	}
+	if caseInsensitive, ok := s.JSONBody["caseInsensitive"].(bool); ok == false || caseInsensitive == false {
+		s.HandleError(errs.E(errs.InvalidJSON, "Only caseInsensitive indexes are supported"), 0)
+		return
+	}
+
+	schema := orm.TalismanDBController.LoadSchema(types.M{"clearCache": true})
+	sch, err := schema.GetOneSchema(className, false, types.M{"clearCache": true})
+	if err != nil {
+		s.HandleError(errs.E(errs.InvalidClassName, "Class "+className+" does not exist."), 0)
+		return
+	}
+	fieldType := utils.M(utils.M(sch["fields"])[fieldName])
+	if fieldType == nil {
+		s.HandleError(errs.E(errs.InvalidKeyName, "Field "+fieldName+" does not exist."), 0)
+		return
+	}
+	if utils.S(fieldType["type"]) != "String" {
+		s.HandleError(errs.E(errs.IncorrectType, "Case insensitive index requires a String field: "+fieldName), 0)
+		return
+	}
+
+	err = orm.TalismanDBController.EnsureCaseInsensitiveIndex(className, fieldName)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
+	s.Data["json"] = types.M{}
+	s.ServeJSON()
+}
+
+// HandleFindUnusedFields 抽样查找类中定义但没有使用的字段，参数 sampleSize 为抽样的对象数，默认为 1000
+// 返回格式： {"className":"post","sampleSize":1000,"fields":["oldField"]}
+// @router /:className/unusedFields [get]
+func (s *SchemasController) HandleFindUnusedFields() {
+	className := s.Ctx.Input.Param(":className")
+	sampleSize, _ := s.GetInt("sampleSize", orm.DefaultUnusedFieldsSampleSize)
+	fields, sampled, err := orm.TalismanDBController.FindUnusedFields(className, sampleSize)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
+	s.Data["json"] = types.M{
+		"className":  className,
+		"sampleSize": sampled,
+		"fields":     fields,
+	}
+	s.ServeJSON()
+}
+
+// HandleDeleteUnusedFields 查找并删除没有使用的字段，需要参数 confirm=true ，删除后无法恢复
+// 返回格式与 HandleFindUnusedFields 相同， fields 为已删除的字段
+// @router /:className/unusedFields [delete]
+func (s *SchemasController) HandleDeleteUnusedFields() {
+	className := s.Ctx.Input.Param(":className")
+	if confirm, _ := s.GetBool("confirm", false); confirm == false {
+		s.HandleError(errs.E(errs.OperationForbidden, "confirm=true is required to delete unused fields."), 0)
+		return
+	}
+	sampleSize, _ := s.GetInt("sampleSize", orm.DefaultUnusedFieldsSampleSize)
+	fields, sampled, err := orm.TalismanDBController.DeleteUnusedFields(className, sampleSize)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
+	s.Data["json"] = types.M{
+		"className":  className,
+		"sampleSize": sampled,
+		"fields":     fields,
+	}
+	s.ServeJSON()
+}
+
+// HandleFindDanglingRelations 检查类中所有 Relation 字段的 _Join 表，返回引用了不存在对象的记录
+// 返回格式： {"className":"post","results":[{"field":"likes","targetClass":"_User","checked":120,"danglingOwning":[],"danglingRelated":["abc"],"dangling":1,"removed":0}]}
+// @router /:className/danglingRelations [get]
+func (s *SchemasController) HandleFindDanglingRelations() {
+	className := s.Ctx.Input.Param(":className")
+	results, err := orm.TalismanDBController.FindDanglingRelations(className, false)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
+	s.Data["json"] = types.M{
+		"className": className,
+		"results":   results,
+	}
+	s.ServeJSON()
+}
+
+// HandleDeleteDanglingRelations 删除 _Join 表中引用了不存在对象的记录，需要参数 confirm=true
+// 返回格式与 HandleFindDanglingRelations 相同， removed 为删除的记录数
+// @router /:className/danglingRelations [delete]
+func (s *SchemasController) HandleDeleteDanglingRelations() {
+	className := s.Ctx.Input.Param(":className")
+	if confirm, _ := s.GetBool("confirm", false); confirm == false {
+		s.HandleError(errs.E(errs.OperationForbidden, "confirm=true is required to delete dangling relations."), 0)
+		return
+	}
+	results, err := orm.TalismanDBController.FindDanglingRelations(className, true)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
+	s.Data["json"] = types.M{
+		"className": className,
+		"results":   results,
+	}
+	s.ServeJSON()
+}
+
+// HandleIndexSuggestions 根据慢查询日志返回类的建议索引，需要设置 SlowQueryThreshold 记录慢查询
+// 返回格式： {"results":[{"className":"post","fields":["author","-createdAt"],"count":12,"totalTime":3400}]}
+// @router /:className/indexSuggestions [get]
+func (s *SchemasController) HandleIndexSuggestions() {
+	className := s.Ctx.Input.Param(":className")
+	if orm.ClassNameIsValid(className) == false {
+		s.HandleError(errs.E(errs.InvalidClassName, orm.InvalidClassNameMessage(className)), 0)
+		return
+	}
+	s.Data["json"] = types.M{
+		"results": orm.TalismanDBController.SuggestIndexes(className),
+	}
+	s.ServeJSON()
+}
+
+// withIndexes 在类的 schema 中添加 indexes ，适配器不支持索引时不添加
+func withIndexes(schema types.M) (types.M, error) {
+	indexes, err := orm.TalismanDBController.GetIndexes(utils.S(schema["className"]))
+	if err != nil {
+		return nil, err
+	}
+	if indexes == nil {
+		return schema, nil
+	}
+	result := utils.CopyMapM(schema)
+	result["indexes"] = indexes
+	return result, nil
+}
+
+func (s *SchemasController) setAllowAddField(allow bool) {
+	className := s.Ctx.Input.Param(":className")
+	if orm.ClassNameIsValid(className) == false {
+		s.HandleError(errs.E(errs.InvalidClassName, orm.InvalidClassNameMessage(className)), 0)
+		return
+	}
+
+	schema := orm.TalismanDBController.LoadSchema(types.M{"clearCache": true})
+	result, err := schema.SetAllowAddField(className, allow)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
+
+	s.Data["json"] = result
+	s.ServeJSON()
+}
+
 // Delete ...
 // @router / [delete]
 func (s *SchemasController) Delete() {
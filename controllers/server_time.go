@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// ServerTimeController 处理 /serverTime 接口的请求，客户端可以据此校准本地时间
+type ServerTimeController struct {
+	ClassesController
+}
+
+// HandleGet 返回服务器的当前时间，参数 clientTime 为客户端发送请求时的时间，可以是 ISO 格式的字符串或者毫秒时间戳，
+// 指定时同时返回 skew ，为服务器时间减去客户端时间的毫秒数，包含网络延迟
+// 返回格式： {"serverTime":{"__type":"Date","iso":"2016-02-28T13:25:05.123Z"},"unixMillis":1456665905123,"skew":120}
+// @router / [get]
+func (s *ServerTimeController) HandleGet() {
+	now := time.Now().UTC()
+	result := types.M{
+		"serverTime": utils.DateJSON(now),
+		"unixMillis": utils.TimetoUnixmilli(now),
+	}
+	if clientTime := s.GetString("clientTime"); clientTime != "" {
+		client, err := parseClientTime(clientTime)
+		if err != nil {
+			s.HandleError(errs.E(errs.InvalidJSON, "clientTime must be an ISO date or unix milliseconds."), 0)
+			return
+		}
+		result["skew"] = utils.TimetoUnixmilli(now) - utils.TimetoUnixmilli(client)
+	}
+	s.Data["json"] = result
+	s.ServeJSON()
+}
+
+// parseClientTime 解析毫秒时间戳或者 ISO 格式的时间
+func parseClientTime(s string) (time.Time, error) {
+	if m, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return utils.UnixmillitoTime(m), nil
+	}
+	return utils.DateToTime(s)
+}
+
+// Post ...
+// @router / [post]
+func (s *ServerTimeController) Post() {
+	s.ClassesController.Post()
+}
+
+// Delete ...
+// @router / [delete]
+func (s *ServerTimeController) Delete() {
+	s.ClassesController.Delete()
+}
+
+// Put ...
+// @router / [put]
+func (s *ServerTimeController) Put() {
+	s.ClassesController.Put()
+}
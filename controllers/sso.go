@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"net/url"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/rest"
+	"github.com/okobsamoht/talisman/sso"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// ssoStateCookie 保存 state 的 cookie ，回调时与参数中的 state 比较，防止登录 CSRF
+const ssoStateCookie = "talisman_sso_state"
+
+// SSOController 处理 /sso 接口的请求，由浏览器直接访问，不需要应用的密钥
+type SSOController struct {
+	BaseController
+	provider *config.SSO
+}
+
+// Prepare 查找服务商
+func (s *SSOController) Prepare() {
+	s.RequestID = utils.S(s.Ctx.Input.GetData("requestId"))
+	s.provider = sso.Provider(s.Ctx.Input.Param(":provider"))
+	if s.provider == nil {
+		s.HandleError(errs.E(errs.UnsupportedService, "This authentication method is unsupported."), 0)
+		return
+	}
+}
+
+// HandleLogin 跳转到服务商的授权页面， redirect 参数为登录之后跳转的地址，必须在 RedirectURLs 中
+// @router /:provider/login [get]
+func (s *SSOController) HandleLogin() {
+	redirect := s.GetString("redirect")
+	if redirect != "" && sso.AllowedRedirect(s.provider, redirect) == false {
+		s.HandleError(errs.E(errs.OperationForbidden, "Redirect URL is not allowed."), 0)
+		return
+	}
+	state, nonce := sso.NewState(s.provider.Name, redirect)
+	location, err := sso.AuthURL(s.provider, state, nonce)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
+	s.Ctx.SetCookie(ssoStateCookie, state, 600, "/", "", s.Ctx.Input.IsSecure(), true)
+	s.Ctx.Output.SetStatus(302)
+	s.Ctx.Output.Header("location", location)
+}
+
+// HandleCallback 校验服务商返回的授权码并登录，按 RoleMapping 同步角色
+// 有跳转地址时把 sessionToken 放在地址的 # 之后跳转，否则返回用户信息
+// @router /:provider/callback [get]
+func (s *SSOController) HandleCallback() {
+	if e := s.GetString("error"); e != "" {
+		s.HandleError(errs.E(errs.ObjectNotFound, s.provider.Name+" returned error: "+e), 0)
+		return
+	}
+	state := s.GetString("state")
+	if state == "" || state != s.Ctx.GetCookie(ssoStateCookie) {
+		s.HandleError(errs.E(errs.ObjectNotFound, "Invalid or expired SSO state."), 0)
+		return
+	}
+	s.Ctx.SetCookie(ssoStateCookie, "", -1, "/")
+	redirect, nonce, err := sso.ParseState(state, s.provider.Name)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
+
+	identity, err := sso.Authenticate(s.provider, s.GetString("code"), nonce)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
+	user, err := rest.SSOLogin(identity.ID(s.provider.Name), identity.Email, identity.EmailVerified, s.provider.LinkByEmail)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
+	roles, managed := sso.Roles(s.provider, identity.Groups)
+	err = rest.SyncRoles(utils.S(user["objectId"]), roles, managed)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
+
+	if redirect == "" {
+		s.Data["json"] = user
+		s.ServeJSON()
+		return
+	}
+	fragment := url.Values{}
+	fragment.Set("sessionToken", utils.S(user["sessionToken"]))
+	fragment.Set("objectId", utils.S(user["objectId"]))
+	s.Ctx.Output.SetStatus(302)
+	s.Ctx.Output.Header("location", redirect+"#"+fragment.Encode())
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/quota"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// StatsController 处理 /stats 接口的请求，返回请求次数与传输字节数
+type StatsController struct {
+	ClassesController
+}
+
+// HandleGet 返回应用的统计信息
+// @router / [get]
+func (s *StatsController) HandleGet() {
+	s.handleStats("")
+}
+
+// HandleSchemaCache 返回 Schema 缓存的加载统计，用于观察缓存失效后重新加载的频率
+// @router /schemaCache [get]
+func (s *StatsController) HandleSchemaCache() {
+	if s.EnforceMasterKeyAccess() == false {
+		return
+	}
+	stats := orm.TalismanDBController.SchemaCacheStats()
+	result := types.M{
+		"reloads":     stats.Reloads,
+		"coalesced":   stats.Coalesced,
+		"staleServed": stats.StaleServed,
+		"errors":      stats.Errors,
+	}
+	if stats.LastReloadAt.IsZero() == false {
+		result["lastReloadAt"] = utils.TimetoString(stats.LastReloadAt)
+	}
+	s.Data["json"] = result
+	s.ServeJSON()
+}
+
+// HandleGetClass 返回指定类的统计信息
+// @router /:className [get]
+func (s *StatsController) HandleGetClass() {
+	s.handleStats(s.Ctx.Input.Param(":className"))
+}
+
+func (s *StatsController) handleStats(className string) {
+	if s.EnforceMasterKeyAccess() == false {
+		return
+	}
+	if quota.Default == nil {
+		s.HandleError(errs.E(errs.OperationForbidden, "Quota accounting is not enabled."), 0)
+		return
+	}
+	stats, err := quota.Default.Stats(className)
+	if err != nil {
+		s.HandleError(err, 0)
+		return
+	}
+	s.Data["json"] = stats
+	s.ServeJSON()
+}
@@ -1,7 +1,6 @@
 package controllers
 
 import (
-	"github.com/okobsamoht/talisman/config"
 	"github.com/okobsamoht/talisman/errs"
 	"github.com/okobsamoht/talisman/orm"
 	"github.com/okobsamoht/talisman/rest"
@@ -22,9 +21,8 @@ func (u *UpgradeSessionController) HandleUpdateToRevocableSession() {
 		return
 	}
 
-	token := "r:" + utils.CreateToken()
 	userID := utils.S(u.Auth.User["objectId"])
-	expiresAt := config.GenerateSessionExpiresAt()
+	token, expiresAt := rest.NewSessionToken(userID)
 	sessionData := types.M{
 		"sessionToken": token,
 		"user": types.M{
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/rest"
+	"github.com/okobsamoht/talisman/types"
+)
+
+// UserConflictsController 处理 /userConflicts 接口的请求
+type UserConflictsController struct {
+	ClassesController
+}
+
+// HandleFind 查找用户名或邮箱在规范化之后相同的用户，开启 UsernameNormalization 与 EmailNormalization 之前使用，需要 Master 权限
+// 请求参数 field 可选 username email ，为空时检查两个字段
+// 返回数据： {"username":[{"normalized":"joe","users":[{"objectId":"xxx","username":"Joe"},{"objectId":"yyy","username":"joe"}]}],"email":[]}
+// @router / [get]
+func (u *UserConflictsController) HandleFind() {
+	if u.EnforceMasterKeyAccess() == false {
+		return
+	}
+	fields := []string{"username", "email"}
+	switch field := u.GetString("field"); field {
+	case "":
+	case "username", "email":
+		fields = []string{field}
+	default:
+		u.HandleError(errs.E(errs.InvalidKeyName, "field must be one of username, email"), 0)
+		return
+	}
+	response := types.M{}
+	for _, field := range fields {
+		conflicts, err := rest.FindNormalizationConflicts(field)
+		if err != nil {
+			u.HandleError(err, 0)
+			return
+		}
+		response[field] = conflicts
+	}
+	u.Data["json"] = response
+	u.ServeJSON()
+}
+
+// Post ...
+// @router / [post]
+func (u *UserConflictsController) Post() {
+	u.ClassesController.Post()
+}
+
+// Delete ...
+// @router / [delete]
+func (u *UserConflictsController) Delete() {
+	u.ClassesController.Delete()
+}
+
+// Put ...
+// @router / [put]
+func (u *UserConflictsController) Put() {
+	u.ClassesController.Put()
+}
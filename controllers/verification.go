@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"github.com/okobsamoht/talisman/config"
 	"github.com/okobsamoht/talisman/errs"
 	"github.com/okobsamoht/talisman/orm"
 	"github.com/okobsamoht/talisman/rest"
@@ -28,7 +29,8 @@ func (r *VerificationController) HandleVerificationEmailRequest() {
 		return
 	}
 
-	results, err := orm.TalismanDBController.Find("_User", types.M{"email": email}, types.M{})
+	email = config.NormalizeEmail(email)
+	results, err := orm.TalismanDBController.Find("_User", types.M{"email": email}, rest.EmailLookupOptions(types.M{}))
 	if err != nil {
 		r.HandleError(err, 0)
 		return
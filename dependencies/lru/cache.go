@@ -14,6 +14,10 @@ import (
 type Cache struct {
 	maxEntries int
 
+	// OnEvicted optionally specifies a callback function to be
+	// executed when an entry is purged from the cache.
+	OnEvicted func(key, value interface{})
+
 	mu    sync.Mutex
 	ll    *list.List
 	cache map[interface{}]*list.Element
@@ -84,10 +88,41 @@ func (c *Cache) removeOldest() (key, value interface{}) {
 	c.ll.Remove(ele)
 	ent := ele.Value.(*entry)
 	delete(c.cache, ent.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(ent.key, ent.value)
+	}
 	return ent.key, ent.value
 
 }
 
+// Remove removes the provided key from the cache.
+func (c *Cache) Remove(key interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ele, hit := c.cache[key]; hit {
+		c.ll.Remove(ele)
+		ent := ele.Value.(*entry)
+		delete(c.cache, ent.key)
+		if c.OnEvicted != nil {
+			c.OnEvicted(ent.key, ent.value)
+		}
+	}
+}
+
+// Clear purges all stored items from the cache.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.OnEvicted != nil {
+		for _, e := range c.cache {
+			ent := e.Value.(*entry)
+			c.OnEvicted(ent.key, ent.value)
+		}
+	}
+	c.ll = list.New()
+	c.cache = make(map[interface{}]*list.Element)
+}
+
 // Len returns the number of items in the cache.
 func (c *Cache) Len() int {
 	c.mu.Lock()
@@ -57,3 +57,39 @@ func TestRemoveOldest(t *testing.T) {
 		t.Fatalf("oldest = %v, %v; want \"\", nil", k, v)
 	}
 }
+
+func TestEvict(t *testing.T) {
+	evictedKeys := make([]interface{}, 0)
+	onEvictedFun := func(key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+
+	c := New(20)
+	c.OnEvicted = onEvictedFun
+	for i := 0; i < 22; i++ {
+		c.Add(i, i)
+	}
+
+	if len(evictedKeys) != 2 {
+		t.Fatalf("got %d evicted keys; want 2", len(evictedKeys))
+	}
+	if evictedKeys[0] != 0 {
+		t.Fatalf("got %v in first evicted key; want %v", evictedKeys[0], 0)
+	}
+	if evictedKeys[1] != 1 {
+		t.Fatalf("got %v in second evicted key; want %v", evictedKeys[1], 1)
+	}
+
+	c.Remove(2)
+	if len(evictedKeys) != 3 || evictedKeys[2] != 2 {
+		t.Fatalf("got %v evicted keys; want [0 1 2]", evictedKeys)
+	}
+
+	c.Clear()
+	if len(evictedKeys) != 22 {
+		t.Fatalf("got %d evicted keys; want 22", len(evictedKeys))
+	}
+	if c.Len() != 0 {
+		t.Fatalf("got %d entries after clear; want 0", c.Len())
+	}
+}
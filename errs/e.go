@@ -1,21 +1,38 @@
 package errs
 
 import (
+	"errors"
+	"net"
 	"strconv"
 
 	"github.com/okobsamoht/talisman/types"
 )
 
 // TalismanError ...
+// Cause 为引起该错误的原始错误，可通过 errors.Unwrap errors.Is errors.As 访问，不会返回给客户端
 type TalismanError struct {
 	Code    int
 	Message string
+	Cause   error
 }
 
 func (e *TalismanError) Error() string {
 	return `{"code": ` + strconv.Itoa(e.Code) + `,"error": "` + e.Message + `"}`
 }
 
+// Unwrap 返回原始错误
+func (e *TalismanError) Unwrap() error {
+	return e.Cause
+}
+
+// Is 错误码相同即认为匹配，如 errors.Is(err, errs.E(errs.ObjectNotFound, ""))
+func (e *TalismanError) Is(target error) bool {
+	if t, ok := target.(*TalismanError); ok {
+		return t.Code == e.Code
+	}
+	return false
+}
+
 // E 组装 json 格式错误信息：
 // {"code": 105,"error": "invalid field name: bl!ng"}
 func E(code int, msg string) error {
@@ -25,9 +42,57 @@ func E(code int, msg string) error {
 	}
 }
 
+// Wrap 组装错误信息，同时保留原始错误 cause
+func Wrap(code int, msg string, cause error) error {
+	return &TalismanError{
+		Code:    code,
+		Message: msg,
+		Cause:   cause,
+	}
+}
+
+// FromAdapter 把数据库适配器返回的错误转换为 TalismanError
+// 已经包含 TalismanError 的错误原样返回，网络错误转换为 ConnectionFailed ，其他错误转换为 InternalServerError
+func FromAdapter(err error) error {
+	if err == nil {
+		return nil
+	}
+	if as(err) != nil {
+		return err
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return Wrap(ConnectionFailed, "Database connection failed: "+err.Error(), err)
+	}
+	return Wrap(InternalServerError, "Internal server error: "+err.Error(), err)
+}
+
+// HTTPStatus 返回错误对应的 HTTP 状态码
+func HTTPStatus(e error) int {
+	switch GetErrorCode(e) {
+	case 0, OtherCause, InternalServerError:
+		return 500
+	case ServiceUnavailable, ConnectionFailed:
+		return 503
+	case ObjectNotFound:
+		return 404
+	default:
+		return 400
+	}
+}
+
+// as 从错误链中查找 TalismanError
+func as(e error) *TalismanError {
+	var v *TalismanError
+	if errors.As(e, &v) {
+		return v
+	}
+	return nil
+}
+
 // ErrorToMap 把 error 转换为 types.M 格式，准备返回给客户端
 func ErrorToMap(e error) types.M {
-	if v, ok := e.(*TalismanError); ok {
+	if v := as(e); v != nil {
 		return types.M{
 			"code":  v.Code,
 			"error": v.Message,
@@ -46,7 +111,7 @@ func ErrorMessageToMap(code int, msg string) types.M {
 
 // GetErrorCode 获取 error 中的 code
 func GetErrorCode(e error) int {
-	if v, ok := e.(*TalismanError); ok {
+	if v := as(e); v != nil {
 		return v.Code
 	}
 	return 0
@@ -54,7 +119,7 @@ func GetErrorCode(e error) int {
 
 // GetErrorMessage 获取 error 中的 Message
 func GetErrorMessage(e error) string {
-	if v, ok := e.(*TalismanError); ok {
+	if v := as(e); v != nil {
 		return v.Message
 	}
 	return e.Error()
@@ -2,6 +2,8 @@ package errs
 
 import (
 	"errors"
+	"fmt"
+	"net"
 	"reflect"
 	"testing"
 
@@ -77,6 +79,11 @@ func TestGetErrorCode(t *testing.T) {
 			args: args{errors.New("hello")},
 			want: 0,
 		},
+		{
+			name: "GetErrorCode 3",
+			args: args{fmt.Errorf("find: %w", E(20, "hello"))},
+			want: 20,
+		},
 	}
 	for _, tt := range tests {
 		if got := GetErrorCode(tt.args.e); got != tt.want {
@@ -111,3 +118,81 @@ func TestGetErrorMessage(t *testing.T) {
 		}
 	}
 }
+
+func TestWrap(t *testing.T) {
+	cause := errors.New("not found")
+	err := Wrap(ObjectNotFound, "Object not found.", cause)
+	if err.Error() != `{"code": 101,"error": "Object not found."}` {
+		t.Errorf("Wrap() error = %v", err)
+	}
+	if errors.Unwrap(err) != cause {
+		t.Errorf("errors.Unwrap() = %v, want %v", errors.Unwrap(err), cause)
+	}
+	if errors.Is(err, cause) == false {
+		t.Errorf("errors.Is(err, cause) = false, want true")
+	}
+	if errors.Is(err, E(ObjectNotFound, "")) == false {
+		t.Errorf("errors.Is(err, E(ObjectNotFound)) = false, want true")
+	}
+	if errors.Is(err, E(InternalServerError, "")) {
+		t.Errorf("errors.Is(err, E(InternalServerError)) = true, want false")
+	}
+	var e *TalismanError
+	if errors.As(err, &e) == false || e.Code != ObjectNotFound {
+		t.Errorf("errors.As() = %v, want %v", e, err)
+	}
+}
+
+func TestFromAdapter(t *testing.T) {
+	netErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{
+			name: "1",
+			err:  nil,
+			want: nil,
+		},
+		{
+			name: "2",
+			err:  E(DuplicateValue, "A duplicate value for a field with unique values was provided"),
+			want: E(DuplicateValue, "A duplicate value for a field with unique values was provided"),
+		},
+		{
+			name: "3",
+			err:  errors.New("not found"),
+			want: Wrap(InternalServerError, "Internal server error: not found", errors.New("not found")),
+		},
+		{
+			name: "4",
+			err:  netErr,
+			want: Wrap(ConnectionFailed, "Database connection failed: "+netErr.Error(), netErr),
+		},
+	}
+	for _, tt := range tests {
+		if got := FromAdapter(tt.err); reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("%q. FromAdapter() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "1", err: E(InternalServerError, "hello"), want: 500},
+		{name: "2", err: errors.New("hello"), want: 500},
+		{name: "3", err: E(ObjectNotFound, "hello"), want: 404},
+		{name: "4", err: Wrap(ConnectionFailed, "hello", errors.New("EOF")), want: 503},
+		{name: "5", err: E(InvalidQuery, "hello"), want: 400},
+	}
+	for _, tt := range tests {
+		if got := HTTPStatus(tt.err); got != tt.want {
+			t.Errorf("%q. HTTPStatus() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// CSVWriter 以 CSV 格式写出表格，开头写入 UTF-8 BOM ，以便 Excel 正确识别编码
+type CSVWriter struct {
+	w       *csv.Writer
+	started bool
+	out     io.Writer
+}
+
+// NewCSVWriter ...
+func NewCSVWriter(out io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(out), out: out}
+}
+
+// WriteRow 写入一行，数据超过缓冲区大小时输出
+func (c *CSVWriter) WriteRow(row []string) error {
+	if c.started == false {
+		c.started = true
+		if _, err := c.out.Write([]byte("\xEF\xBB\xBF")); err != nil {
+			return err
+		}
+	}
+	return c.w.Write(row)
+}
+
+// Close ...
+func (c *CSVWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
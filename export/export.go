@@ -0,0 +1,127 @@
+// Package export 把查询结果转换为表格，以 CSV 或者 XLSX 格式写出，供不使用 SDK 的分析人员下载数据
+// 每个对象为一行，每个字段为一列，字段值转换为字符串： Pointer 转换为 objectId ， Date 转换为 ISO 格式的时间，
+// File 转换为 url ， GeoPoint 转换为 "纬度,经度" ，其他对象与数组转换为 JSON
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// Writer 逐行写出表格，写完之后需要调用 Close
+type Writer interface {
+	WriteRow(row []string) error
+	Close() error
+}
+
+// Columns 返回导出的列
+// 指定了 keys 时按 keys 的顺序导出，否则导出 fields 中除 ACL 与 Relation 以外的字段，
+// 两种情况下 objectId 均为第一列，未指定 keys 时 createdAt 、 updatedAt 紧随其后，其余字段按名称排序
+func Columns(fields types.M, keys []string) []string {
+	columns := []string{"objectId"}
+	used := map[string]bool{"objectId": true}
+	if len(keys) > 0 {
+		for _, key := range keys {
+			if key == "" || used[key] {
+				continue
+			}
+			columns = append(columns, key)
+			used[key] = true
+		}
+		return columns
+	}
+
+	names := []string{}
+	for fieldName, v := range fields {
+		if used[fieldName] || fieldName == "ACL" || fieldName == "createdAt" || fieldName == "updatedAt" {
+			continue
+		}
+		if utils.S(utils.M(v)["type"]) == "Relation" {
+			continue
+		}
+		names = append(names, fieldName)
+	}
+	sort.Strings(names)
+	columns = append(columns, "createdAt", "updatedAt")
+	return append(columns, names...)
+}
+
+// Row 按 columns 取出对象中的字段值，带点的列名如 a.b 取嵌套对象中的值
+func Row(object types.M, columns []string) []string {
+	row := make([]string, 0, len(columns))
+	for _, column := range columns {
+		var value interface{} = object
+		for _, key := range strings.Split(column, ".") {
+			value = utils.M(value)[key]
+		}
+		row = append(row, FormatValue(value))
+	}
+	return row
+}
+
+// FormatValue 把字段值转换为单元格中的字符串
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	}
+
+	if object := utils.M(value); object != nil {
+		switch utils.S(object["__type"]) {
+		case "Pointer", "Object":
+			return utils.S(object["objectId"])
+		case "Date":
+			return utils.S(object["iso"])
+		case "File":
+			if url := utils.S(object["url"]); url != "" {
+				return url
+			}
+			return utils.S(object["name"])
+		case "GeoPoint":
+			return FormatValue(object["latitude"]) + "," + FormatValue(object["longitude"])
+		case "Bytes":
+			return utils.S(object["base64"])
+		case "Relation":
+			return ""
+		}
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// ContentTypes 支持的导出格式与对应的 Content-Type
+var ContentTypes = map[string]string{
+	"csv":  "text/csv; charset=utf-8",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// NewWriter 按格式创建 Writer ，格式不支持时返回 nil
+func NewWriter(format string, out io.Writer) Writer {
+	switch format {
+	case "csv":
+		return NewCSVWriter(out)
+	case "xlsx":
+		return NewXLSXWriter(out)
+	}
+	return nil
+}
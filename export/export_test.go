@@ -0,0 +1,124 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_Columns(t *testing.T) {
+	fields := types.M{
+		"objectId":  types.M{"type": "String"},
+		"createdAt": types.M{"type": "Date"},
+		"updatedAt": types.M{"type": "Date"},
+		"ACL":       types.M{"type": "ACL"},
+		"score":     types.M{"type": "Number"},
+		"player":    types.M{"type": "Pointer", "targetClass": "_User"},
+		"fans":      types.M{"type": "Relation", "targetClass": "_User"},
+	}
+	type args struct {
+		fields types.M
+		keys   []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			name: "1",
+			args: args{fields: fields},
+			want: []string{"objectId", "createdAt", "updatedAt", "player", "score"},
+		},
+		{
+			name: "2",
+			args: args{fields: fields, keys: []string{"score", "objectId", "player.name", "score"}},
+			want: []string{"objectId", "score", "player.name"},
+		},
+		{
+			name: "3",
+			args: args{fields: nil},
+			want: []string{"objectId", "createdAt", "updatedAt"},
+		},
+	}
+	for _, tt := range tests {
+		if got := Columns(tt.args.fields, tt.args.keys); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. Columns() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_Row(t *testing.T) {
+	object := types.M{
+		"objectId": "1001",
+		"score":    1337.0,
+		"cheat":    false,
+		"player":   types.M{"__type": "Pointer", "className": "_User", "objectId": "u1"},
+		"owner":    types.M{"__type": "Object", "className": "_User", "objectId": "u2", "name": "joe"},
+		"playedAt": types.M{"__type": "Date", "iso": "2016-02-28T13:25:05.123Z"},
+		"avatar":   types.M{"__type": "File", "name": "a.png", "url": "http://127.0.0.1/a.png"},
+		"location": types.M{"__type": "GeoPoint", "latitude": 40.5, "longitude": -30.0},
+		"tags":     types.S{"a", "b"},
+		"detail":   types.M{"level": 2.0},
+	}
+	columns := []string{"objectId", "score", "cheat", "player", "owner", "owner.name", "playedAt", "avatar", "location", "tags", "detail", "detail.level", "missing", "missing.key"}
+	got := Row(object, columns)
+	want := []string{"1001", "1337", "false", "u1", "u2", "joe", "2016-02-28T13:25:05.123Z", "http://127.0.0.1/a.png", "40.5,-30", `["a","b"]`, `{"level":2}`, "2", "", ""}
+	if reflect.DeepEqual(got, want) == false {
+		t.Error("expect:", want, "result:", got)
+	}
+}
+
+func Test_CSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter("csv", &buf)
+	w.WriteRow([]string{"objectId", "name"})
+	w.WriteRow([]string{"1001", "Sean, \"Plott\""})
+	err := w.Close()
+	want := "\xEF\xBB\xBFobjectId,name\n1001,\"Sean, \"\"Plott\"\"\"\n"
+	if err != nil || buf.String() != want {
+		t.Error("expect:", want, "result:", buf.String(), err)
+	}
+}
+
+func Test_XLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter("xlsx", &buf)
+	w.WriteRow([]string{"objectId", "name"})
+	w.WriteRow([]string{"1001", "<Sean & Plott>"})
+	err := w.Close()
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+		return
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+		return
+	}
+	names := []string{}
+	var sheet string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, _ := f.Open()
+			b, _ := ioutil.ReadAll(rc)
+			rc.Close()
+			sheet = string(b)
+		}
+	}
+	wantNames := []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"}
+	if reflect.DeepEqual(names, wantNames) == false {
+		t.Error("expect:", wantNames, "result:", names)
+	}
+	wantRow := `<row><c t="inlineStr"><is><t xml:space="preserve">1001</t></is></c><c t="inlineStr"><is><t xml:space="preserve">&lt;Sean &amp; Plott&gt;</t></is></c></row>`
+	if strings.Contains(sheet, wantRow) == false || strings.HasSuffix(sheet, "</sheetData></worksheet>") == false {
+		t.Error("expect:", wantRow, "result:", sheet)
+	}
+}
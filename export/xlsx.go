@@ -0,0 +1,108 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"unicode/utf8"
+)
+
+// XLSX 文件为包含若干 XML 文件的 zip 包，这里只生成一个工作表，单元格均为内联字符串，
+// 工作表在所有行写入之后才结束，因此可以边查询边输出，不需要把所有数据保存在内存中
+
+// maxCellLength Excel 单元格最多包含的字符数，超过时截断
+const maxCellLength = 32767
+
+var xlsxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// XLSXWriter 以 XLSX 格式写出表格
+type XLSXWriter struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+	err   error
+}
+
+// NewXLSXWriter 写入固定的文件，并开始写入工作表
+func NewXLSXWriter(out io.Writer) *XLSXWriter {
+	x := &XLSXWriter{zip: zip.NewWriter(out)}
+	for _, part := range xlsxParts {
+		w, err := x.zip.Create(part.name)
+		if err == nil {
+			_, err = io.WriteString(w, part.content)
+		}
+		if err != nil {
+			x.err = err
+			return x
+		}
+	}
+	w, err := x.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		x.err = err
+		return x
+	}
+	x.sheet = bufio.NewWriter(w)
+	x.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return x
+}
+
+// WriteRow 写入一行
+func (x *XLSXWriter) WriteRow(row []string) error {
+	if x.err != nil {
+		return x.err
+	}
+	x.sheet.WriteString("<row>")
+	for _, cell := range row {
+		x.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		x.err = xml.EscapeText(x.sheet, []byte(truncateCell(cell)))
+		if x.err != nil {
+			return x.err
+		}
+		x.sheet.WriteString("</t></is></c>")
+	}
+	_, x.err = x.sheet.WriteString("</row>")
+	if x.err == nil {
+		x.err = x.sheet.Flush()
+	}
+	return x.err
+}
+
+// Close 结束工作表并写出 zip 包的目录
+func (x *XLSXWriter) Close() error {
+	if x.err != nil {
+		return x.err
+	}
+	x.sheet.WriteString("</sheetData></worksheet>")
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
+
+func truncateCell(cell string) string {
+	if utf8.RuneCountInString(cell) <= maxCellLength {
+		return cell
+	}
+	return string([]rune(cell)[:maxCellLength])
+}
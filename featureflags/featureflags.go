@@ -0,0 +1,258 @@
+// Package featureflags 服务端功能开关
+// 开关保存在 _GlobalConfig 中 objectId 为 featureFlags 的对象里，不会通过 /config 接口返回给客户端
+// 支持三种类型的开关：
+// boolean 直接开启或者关闭
+// percentage 按用户或者设备 ID 灰度开启
+// targeted 对指定的用户、角色、设备开启
+package featureflags
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/okobsamoht/talisman/cloud"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/rest"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+const featureFlagsClassName = "_GlobalConfig"
+const featureFlagsObjectID = "featureFlags"
+
+// 开关类型
+const (
+	TypeBoolean    = "boolean"
+	TypePercentage = "percentage"
+	TypeTargeted   = "targeted"
+)
+
+// Flag 功能开关
+// Percentage 取值范围 0-100 ，仅在 percentage 类型时使用
+// Users Roles Installations 仅在 targeted 类型时使用， Roles 中为角色名称，不带 role: 前缀
+type Flag struct {
+	Name          string
+	Type          string
+	Enabled       bool
+	Percentage    int
+	Users         []string
+	Roles         []string
+	Installations []string
+}
+
+// Target 开关的判断对象
+type Target struct {
+	UserID         string
+	Roles          []string
+	InstallationID string
+}
+
+var (
+	mu    sync.RWMutex
+	flags map[string]*Flag
+)
+
+// Load 从数据库中加载所有开关
+func Load() error {
+	results, err := orm.TalismanDBController.Find(featureFlagsClassName, types.M{"objectId": featureFlagsObjectID}, types.M{"limit": 1})
+	if err != nil {
+		return err
+	}
+	newFlags := map[string]*Flag{}
+	if len(results) == 1 {
+		if object := utils.M(results[0]); object != nil {
+			for name, v := range utils.M(object["params"]) {
+				if f := flagFromMap(name, utils.M(v)); f != nil {
+					newFlags[name] = f
+				}
+			}
+		}
+	}
+	mu.Lock()
+	flags = newFlags
+	mu.Unlock()
+	return nil
+}
+
+// Get 获取开关，不存在时返回 nil
+func Get(name string) *Flag {
+	mu.RLock()
+	fs := flags
+	mu.RUnlock()
+	if fs == nil {
+		if err := Load(); err != nil {
+			return nil
+		}
+		mu.RLock()
+		fs = flags
+		mu.RUnlock()
+	}
+	if f, ok := fs[name]; ok {
+		c := *f
+		return &c
+	}
+	return nil
+}
+
+// Set 保存开关
+func Set(flag *Flag) error {
+	if flag == nil || flag.Name == "" {
+		return errs.E(errs.InvalidJSON, "flag name is required")
+	}
+	switch flag.Type {
+	case TypeBoolean, TypeTargeted:
+	case TypePercentage:
+		if flag.Percentage < 0 || flag.Percentage > 100 {
+			return errs.E(errs.InvalidJSON, "percentage should be between 0 and 100")
+		}
+	default:
+		return errs.E(errs.InvalidJSON, "unsupported flag type "+flag.Type)
+	}
+	update := types.M{"params." + flag.Name: flagToMap(flag)}
+	_, err := orm.TalismanDBController.Update(featureFlagsClassName, types.M{"objectId": featureFlagsObjectID}, update, types.M{"upsert": true}, false)
+	if err != nil {
+		return err
+	}
+	return Load()
+}
+
+// Delete 删除开关
+func Delete(name string) error {
+	update := types.M{"params." + name: types.M{"__op": "Delete"}}
+	_, err := orm.TalismanDBController.Update(featureFlagsClassName, types.M{"objectId": featureFlagsObjectID}, update, types.M{"upsert": true}, false)
+	if err != nil {
+		return err
+	}
+	return Load()
+}
+
+// IsEnabled 判断开关对 target 是否开启，开关不存在时返回 false
+func IsEnabled(name string, target Target) bool {
+	f := Get(name)
+	if f == nil {
+		return false
+	}
+	return f.enabledFor(target)
+}
+
+// IsEnabledForFunction 在云函数中判断开关是否开启
+func IsEnabledForFunction(name string, request cloud.FunctionRequest) bool {
+	return IsEnabled(name, targetFor(request.User, request.InstallationID))
+}
+
+// IsEnabledForTrigger 在触发器中判断开关是否开启
+func IsEnabledForTrigger(name string, request cloud.TriggerRequest) bool {
+	return IsEnabled(name, targetFor(request.User, request.InstallationID))
+}
+
+// targetFor 组装 Target ，用户角色从 Auth 中获取
+func targetFor(user types.M, installationID string) Target {
+	target := Target{InstallationID: installationID}
+	if user == nil {
+		return target
+	}
+	target.UserID = utils.S(user["objectId"])
+	auth := &rest.Auth{User: user}
+	for _, role := range auth.GetUserRoles() {
+		target.Roles = append(target.Roles, strings.TrimPrefix(role, "role:"))
+	}
+	return target
+}
+
+// enabledFor ...
+func (f *Flag) enabledFor(target Target) bool {
+	if f.Enabled == false {
+		return false
+	}
+	switch f.Type {
+	case TypeBoolean:
+		return true
+	case TypePercentage:
+		id := target.UserID
+		if id == "" {
+			id = target.InstallationID
+		}
+		if id == "" {
+			return false
+		}
+		return bucket(f.Name, id) < f.Percentage
+	case TypeTargeted:
+		if contains(f.Users, target.UserID) || contains(f.Installations, target.InstallationID) {
+			return true
+		}
+		for _, role := range target.Roles {
+			if contains(f.Roles, role) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bucket 计算 id 在开关上的分桶，取值范围 0-99 ，同一个 id 在同一个开关上的结果固定
+func bucket(name, id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + ":" + id))
+	return int(h.Sum32() % 100)
+}
+
+func contains(list []string, s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func flagToMap(f *Flag) types.M {
+	return types.M{
+		"type":          f.Type,
+		"enabled":       f.Enabled,
+		"percentage":    f.Percentage,
+		"users":         f.Users,
+		"roles":         f.Roles,
+		"installations": f.Installations,
+	}
+}
+
+func flagFromMap(name string, m types.M) *Flag {
+	if m == nil {
+		return nil
+	}
+	f := &Flag{
+		Name: name,
+		Type: utils.S(m["type"]),
+	}
+	if v, ok := m["enabled"].(bool); ok {
+		f.Enabled = v
+	}
+	switch v := m["percentage"].(type) {
+	case int:
+		f.Percentage = v
+	case int64:
+		f.Percentage = int(v)
+	case float64:
+		f.Percentage = int(v)
+	}
+	f.Users = toStrings(m["users"])
+	f.Roles = toStrings(m["roles"])
+	f.Installations = toStrings(m["installations"])
+	return f
+}
+
+func toStrings(i interface{}) []string {
+	if v, ok := i.([]string); ok {
+		return v
+	}
+	s := []string{}
+	for _, v := range utils.A(i) {
+		s = append(s, utils.S(v))
+	}
+	return s
+}
@@ -0,0 +1,75 @@
+package featureflags
+
+import (
+	"testing"
+)
+
+func Test_enabledFor(t *testing.T) {
+	type args struct {
+		flag   *Flag
+		target Target
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			name: "1",
+			args: args{flag: &Flag{Name: "a", Type: TypeBoolean, Enabled: true}, target: Target{}},
+			want: true,
+		},
+		{
+			name: "2",
+			args: args{flag: &Flag{Name: "a", Type: TypeBoolean, Enabled: false}, target: Target{UserID: "1001"}},
+			want: false,
+		},
+		{
+			name: "3",
+			args: args{flag: &Flag{Name: "a", Type: TypePercentage, Enabled: true, Percentage: 100}, target: Target{UserID: "1001"}},
+			want: true,
+		},
+		{
+			name: "4",
+			args: args{flag: &Flag{Name: "a", Type: TypePercentage, Enabled: true, Percentage: 0}, target: Target{UserID: "1001"}},
+			want: false,
+		},
+		{
+			name: "5",
+			args: args{flag: &Flag{Name: "a", Type: TypePercentage, Enabled: true, Percentage: 100}, target: Target{}},
+			want: false,
+		},
+		{
+			name: "6",
+			args: args{flag: &Flag{Name: "a", Type: TypeTargeted, Enabled: true, Users: []string{"1001"}}, target: Target{UserID: "1001"}},
+			want: true,
+		},
+		{
+			name: "7",
+			args: args{flag: &Flag{Name: "a", Type: TypeTargeted, Enabled: true, Roles: []string{"admin"}}, target: Target{UserID: "1002", Roles: []string{"user", "admin"}}},
+			want: true,
+		},
+		{
+			name: "8",
+			args: args{flag: &Flag{Name: "a", Type: TypeTargeted, Enabled: true, Installations: []string{"i01"}}, target: Target{UserID: "1002", InstallationID: "i02"}},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.args.flag.enabledFor(tt.args.target); got != tt.want {
+			t.Errorf("%q. enabledFor() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_bucket(t *testing.T) {
+	for _, id := range []string{"1001", "1002", "i01"} {
+		b := bucket("a", id)
+		if b < 0 || b > 99 {
+			t.Errorf("bucket() = %v, want 0-99", b)
+		}
+		if bucket("a", id) != b {
+			t.Errorf("bucket() is not stable for %v", id)
+		}
+	}
+}
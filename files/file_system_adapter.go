@@ -3,6 +3,7 @@ package files
 import (
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/astaxie/beego/utils"
 	"github.com/okobsamoht/talisman/config"
@@ -142,6 +143,14 @@ func (d *diskFileStream) Size() (bytes int64) {
 	return i.Size()
 }
 
+func (d *diskFileStream) ModTime() time.Time {
+	i, err := d.file.Stat()
+	if err != nil {
+		return time.Time{}
+	}
+	return i.ModTime()
+}
+
 func (d *diskFileStream) Close() (err error) {
 	return d.file.Close()
 }
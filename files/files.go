@@ -11,6 +11,11 @@ var adapter filesAdapter
 // 当前支持本地文件存储模块、数据库文件存储
 // 后续可增加第三方网络文件存储模块
 func init() {
+	Init()
+}
+
+// Init 根据当前配置创建文件存储模块
+func Init() {
 	a := config.TConfig.FileAdapter
 	if a == "Disk" {
 		adapter = newFileSystemAdapter(config.TConfig.AppID)
@@ -23,6 +28,8 @@ func init() {
 	} else {
 		adapter = newFileSystemAdapter(config.TConfig.AppID)
 	}
+	imageVariants = newImageCache()
+	scanner = newFileScanner()
 }
 
 // GetFileData 获取文件数据
@@ -53,8 +60,14 @@ func CreateFile(filename string, data []byte, contentType string) map[string]str
 	}
 }
 
-// DeleteFile 删除文件
+// GetFileLocation 获取文件地址
+func GetFileLocation(filename string) string {
+	return adapter.getFileLocation(filename)
+}
+
+// DeleteFile 删除文件，同时删除缓存的图片处理结果
 func DeleteFile(filename string) error {
+	imageVariants.del(filename)
 	return adapter.deleteFile(filename)
 }
 
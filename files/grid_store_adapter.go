@@ -1,26 +1,54 @@
 package files
 
 import (
+	"context"
 	"errors"
+	"io"
+	"io/ioutil"
 	"net/url"
+	"sync"
+	"time"
 
 	"github.com/okobsamoht/talisman/config"
 	"github.com/okobsamoht/talisman/storage"
-	"gopkg.in/mgo.v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type gridStoreAdapter struct {
-	gfs *mgo.GridFS
+	mu     sync.Mutex
+	bucket *gridfs.Bucket
 }
 
+// newGridStoreAdapter 创建时不连接数据库，第一次读写文件时再连接
 func newGridStoreAdapter() *gridStoreAdapter {
-	g := &gridStoreAdapter{}
-	g.gfs = storage.OpenMongoDB().GridFS("fs")
-	return g
+	return &gridStoreAdapter{}
+}
+
+func (g *gridStoreAdapter) gfs() (*gridfs.Bucket, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.bucket == nil {
+		bucket, err := gridfs.NewBucket(storage.OpenMongoDB())
+		if err != nil {
+			return nil, err
+		}
+		g.bucket = bucket
+	}
+	return g.bucket, nil
 }
 
 func (g *gridStoreAdapter) createFile(filename string, data []byte, contentType string) error {
-	file, err := g.gfs.Create(filename)
+	bucket, err := g.gfs()
+	if err != nil {
+		return err
+	}
+	opts := options.GridFSUpload()
+	if contentType != "" {
+		opts.SetMetadata(bson.M{"contentType": contentType})
+	}
+	file, err := bucket.OpenUploadStream(filename, opts)
 	if err != nil {
 		return err
 	}
@@ -34,35 +62,43 @@ func (g *gridStoreAdapter) createFile(filename string, data []byte, contentType
 		return errors.New("createFile failed")
 	}
 
-	if contentType != "" {
-		file.SetContentType(contentType)
-	}
-
 	return nil
 }
 
+// deleteFile 删除所有同名的文件
 func (g *gridStoreAdapter) deleteFile(filename string) error {
-	return g.gfs.Remove(filename)
+	bucket, err := g.gfs()
+	if err != nil {
+		return err
+	}
+	cursor, err := bucket.Find(bson.M{"filename": filename})
+	if err != nil {
+		return err
+	}
+	var files []gridfs.File
+	if err := cursor.All(context.Background(), &files); err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := bucket.Delete(file.ID); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (g *gridStoreAdapter) getFileData(filename string) ([]byte, error) {
-	file, err := g.gfs.Open(filename)
+	bucket, err := g.gfs()
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-
-	data := []byte{}
-	buf := make([]byte, 1024)
-	for {
-		n, _ := file.Read(buf)
-		if n == 0 {
-			break
-		}
-		data = append(data, buf[:n]...)
+	file, err := bucket.OpenDownloadStreamByName(filename)
+	if err != nil {
+		return nil, err
 	}
+	defer file.Close()
 
-	return data, nil
+	return ioutil.ReadAll(file)
 }
 
 func (g *gridStoreAdapter) getFileLocation(filename string) string {
@@ -70,13 +106,80 @@ func (g *gridStoreAdapter) getFileLocation(filename string) string {
 }
 
 func (g *gridStoreAdapter) getFileStream(filename string) (FileStream, error) {
-	file, err := g.gfs.Open(filename)
+	bucket, err := g.gfs()
 	if err != nil {
 		return nil, err
 	}
-	return file, nil
+	stream, err := bucket.OpenDownloadStreamByName(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &gridFileStream{bucket: bucket, file: stream.GetFile(), stream: stream}, nil
 }
 
 func (g *gridStoreAdapter) getAdapterName() string {
 	return "gridStoreAdapter"
 }
+
+// gridFileStream GridFS 文件流，驱动的下载流不支持 Seek
+// Seek 时关闭当前的下载流，下次读取时重新打开文件并跳过 offset 之前的数据
+type gridFileStream struct {
+	bucket *gridfs.Bucket
+	file   *gridfs.File
+	stream *gridfs.DownloadStream
+	offset int64
+}
+
+func (s *gridFileStream) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.file.Length
+	}
+	if offset < 0 {
+		return s.offset, errors.New("seek to a negative offset")
+	}
+	if offset != s.offset && s.stream != nil {
+		s.stream.Close()
+		s.stream = nil
+	}
+	s.offset = offset
+	return offset, nil
+}
+
+func (s *gridFileStream) Read(b []byte) (int, error) {
+	if s.offset >= s.file.Length {
+		return 0, io.EOF
+	}
+	if s.stream == nil {
+		stream, err := s.bucket.OpenDownloadStream(s.file.ID)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := stream.Skip(s.offset); err != nil {
+			stream.Close()
+			return 0, err
+		}
+		s.stream = stream
+	}
+	n, err := s.stream.Read(b)
+	s.offset += int64(n)
+	return n, err
+}
+
+func (s *gridFileStream) Size() int64 {
+	return s.file.Length
+}
+
+// UploadDate 文件的上传时间，用作修改时间
+func (s *gridFileStream) UploadDate() time.Time {
+	return s.file.UploadDate
+}
+
+func (s *gridFileStream) Close() error {
+	if s.stream == nil {
+		return nil
+	}
+	return s.stream.Close()
+}
@@ -0,0 +1,262 @@
+package files
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+)
+
+// 开启 ImageTransform 后，下载图片时可以通过 width height quality format 参数缩放图片或者转换格式，
+// 为了防止任意参数的请求消耗服务器资源，请求必须带有使用 ImageSigningKey 生成的签名 sig ，
+// 签名可以通过 expires 指定过期时间，处理之后的图片保存在 ImageCacheAdapter 指定的缓存中
+// 签名的地址通过 SignedImageURL 生成，如 /files/appId/pic.jpg?width=200&expires=1456665905&sig=xxx
+
+// ImageOptions 图片处理参数，值为 0 或者空时表示不修改
+type ImageOptions struct {
+	Width   int
+	Height  int
+	Quality int    // JPEG 图片的质量，取值范围 1-100
+	Format  string // 输出格式，可选： jpeg png gif ，为空时保持原格式
+	Expires int64  // 签名的过期时间， Unix 时间戳，单位为秒
+}
+
+var imageFormats = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+}
+
+// ParseImageOptions 从下载请求的参数中解析图片处理参数与签名，不包含图片处理参数时返回 nil
+func ParseImageOptions(values url.Values) (*ImageOptions, string, error) {
+	if values.Get("width") == "" && values.Get("height") == "" && values.Get("quality") == "" && values.Get("format") == "" {
+		return nil, "", nil
+	}
+	options := &ImageOptions{}
+	var err error
+	parseInt := func(key string, max int) (int, error) {
+		s := values.Get(key)
+		if s == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > max {
+			return 0, errors.New("invalid image " + key + ": " + s)
+		}
+		return n, nil
+	}
+	maxDimension := config.TConfig.ImageMaxDimension
+	if maxDimension <= 0 {
+		maxDimension = 4096
+	}
+	if options.Width, err = parseInt("width", maxDimension); err != nil {
+		return nil, "", err
+	}
+	if options.Height, err = parseInt("height", maxDimension); err != nil {
+		return nil, "", err
+	}
+	if options.Quality, err = parseInt("quality", 100); err != nil {
+		return nil, "", err
+	}
+	options.Format = strings.ToLower(values.Get("format"))
+	if options.Format == "jpg" {
+		options.Format = "jpeg"
+	}
+	if _, ok := imageFormats[options.Format]; options.Format != "" && ok == false {
+		return nil, "", errors.New("invalid image format: " + values.Get("format"))
+	}
+	if s := values.Get("expires"); s != "" {
+		options.Expires, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, "", errors.New("invalid image expires: " + s)
+		}
+	}
+	return options, values.Get("sig"), nil
+}
+
+// query 按参数名排序的查询字符串，不包含签名，用于生成签名与缓存的 key
+func (o ImageOptions) query() url.Values {
+	values := url.Values{}
+	if o.Width > 0 {
+		values.Set("width", strconv.Itoa(o.Width))
+	}
+	if o.Height > 0 {
+		values.Set("height", strconv.Itoa(o.Height))
+	}
+	if o.Quality > 0 {
+		values.Set("quality", strconv.Itoa(o.Quality))
+	}
+	if o.Format != "" {
+		values.Set("format", o.Format)
+	}
+	if o.Expires > 0 {
+		values.Set("expires", strconv.FormatInt(o.Expires, 10))
+	}
+	return values
+}
+
+// signImage 使用 HMAC-SHA256 对文件名与处理参数签名
+func signImage(filename string, o ImageOptions, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(filename + "?" + o.query().Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyImageSignature 校验图片处理请求的签名与过期时间
+func VerifyImageSignature(filename string, o ImageOptions, sig string, now time.Time) error {
+	key := config.TConfig.ImageSigningKey
+	if key == "" {
+		return errors.New("image transformation is not enabled")
+	}
+	if hmac.Equal([]byte(sig), []byte(signImage(filename, o, key))) == false {
+		return errors.New("invalid image signature")
+	}
+	if o.Expires > 0 && now.Unix() > o.Expires {
+		return errors.New("image signature expired")
+	}
+	return nil
+}
+
+// SignedImageURL 生成带签名的图片处理地址，地址总是指向 talisman ，不受 FileDirectAccess 影响
+func SignedImageURL(filename string, o ImageOptions) string {
+	values := o.query()
+	values.Set("sig", signImage(filename, o, config.TConfig.ImageSigningKey))
+	return config.TConfig.ServerURL + "/files/" + config.TConfig.AppID + "/" + url.QueryEscape(filename) + "?" + values.Encode()
+}
+
+// GetTransformedImage 获取处理之后的图片，优先从缓存中读取，返回图片数据与 Content-Type
+func GetTransformedImage(filename string, o ImageOptions) ([]byte, string, error) {
+	// 过期时间不影响处理结果，不同的签名共用一份缓存
+	o.Expires = 0
+	variant := o.query().Encode()
+	if data, contentType, ok := imageVariants.get(filename, variant); ok {
+		return data, contentType, nil
+	}
+	data, err := adapter.getFileData(filename)
+	if err != nil {
+		return nil, "", err
+	}
+	data, contentType, err := TransformImage(data, o)
+	if err != nil {
+		return nil, "", err
+	}
+	imageVariants.put(filename, variant, data, contentType)
+	return data, contentType, nil
+}
+
+// TransformImage 按参数缩放图片并转换格式，只指定宽度或者高度时按比例缩放，
+// 同时指定时缩放到不超过指定大小的最大尺寸，不会放大图片
+func TransformImage(data []byte, o ImageOptions) ([]byte, string, error) {
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if o.Format != "" {
+		format = o.Format
+	}
+	if _, ok := imageFormats[format]; ok == false {
+		format = "png"
+	}
+
+	bounds := src.Bounds()
+	width, height := fitImageSize(bounds.Dx(), bounds.Dy(), o.Width, o.Height)
+	var dst image.Image = src
+	if width != bounds.Dx() || height != bounds.Dy() {
+		dst = resizeImage(src, width, height)
+	}
+
+	buf := &bytes.Buffer{}
+	switch format {
+	case "jpeg":
+		quality := o.Quality
+		if quality == 0 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(buf, dst, &jpeg.Options{Quality: quality})
+	case "gif":
+		err = gif.Encode(buf, dst, nil)
+	default:
+		err = png.Encode(buf, dst)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), imageFormats[format], nil
+}
+
+// fitImageSize 计算缩放之后的尺寸，保持宽高比，结果不超过原图大小，并且至少为 1
+func fitImageSize(srcWidth, srcHeight, width, height int) (int, int) {
+	if srcWidth <= 0 || srcHeight <= 0 || (width <= 0 && height <= 0) {
+		return srcWidth, srcHeight
+	}
+	if width <= 0 || width > srcWidth {
+		width = srcWidth
+	}
+	if height <= 0 || height > srcHeight {
+		height = srcHeight
+	}
+	// 按缩放比例较小的一边计算
+	if width*srcHeight < height*srcWidth {
+		height = (srcHeight*width + srcWidth/2) / srcWidth
+	} else {
+		width = (srcWidth*height + srcHeight/2) / srcHeight
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	return width, height
+}
+
+// resizeImage 使用区域平均缩小图片，每个目标像素取原图中对应区域内所有像素的平均值
+func resizeImage(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*srcHeight/height
+		y1 := bounds.Min.Y + (y+1)*srcHeight/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*srcWidth/width
+			x1 := bounds.Min.X + (x+1)*srcWidth/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,167 @@
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	beegoutils "github.com/astaxie/beego/utils"
+	"github.com/garyburd/redigo/redis"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// imageVariants 处理之后的图片缓存
+var imageVariants imageCache = nullImageCache{}
+
+// imageCache 规定了图片缓存需要实现的接口，同一个文件的所有处理结果保存在一起， variant 由处理参数生成
+type imageCache interface {
+	get(filename, variant string) (data []byte, contentType string, ok bool)
+	put(filename, variant string, data []byte, contentType string)
+	del(filename string)
+}
+
+// newImageCache 根据当前配置创建图片缓存
+func newImageCache() imageCache {
+	if config.TConfig.ImageTransform == false {
+		return nullImageCache{}
+	}
+	ttl := time.Duration(config.TConfig.ImageCacheTTL) * time.Second
+	switch config.TConfig.ImageCacheAdapter {
+	case "Redis":
+		return newRedisImageCache(config.TConfig.RedisAddress, config.TConfig.RedisPassword, ttl)
+	case "Null":
+		return nullImageCache{}
+	default:
+		return newDiskImageCache(beegoutils.SelfDir()+string(os.PathSeparator)+"files"+string(os.PathSeparator)+"image-cache", ttl)
+	}
+}
+
+// nullImageCache 不缓存
+type nullImageCache struct{}
+
+func (n nullImageCache) get(filename, variant string) ([]byte, string, bool) {
+	return nil, "", false
+}
+
+func (n nullImageCache) put(filename, variant string, data []byte, contentType string) {}
+
+func (n nullImageCache) del(filename string) {}
+
+// diskImageCache 保存在本地磁盘的图片缓存，每个文件的处理结果保存在以文件名 hash 命名的目录中，
+// 文件的修改时间超过 ttl 时视为过期， ttl 为 0 时不过期， Content-Type 保存在同名的 .type 文件中
+type diskImageCache struct {
+	dir string
+	ttl time.Duration
+}
+
+func newDiskImageCache(dir string, ttl time.Duration) *diskImageCache {
+	os.MkdirAll(dir, 0777)
+	return &diskImageCache{dir: dir, ttl: ttl}
+}
+
+func (d *diskImageCache) get(filename, variant string) ([]byte, string, bool) {
+	path := filepath.Join(d.dir, utils.MD5Hash(filename), utils.MD5Hash(variant))
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", false
+	}
+	if d.ttl > 0 && time.Since(info.ModTime()) > d.ttl {
+		os.Remove(path)
+		os.Remove(path + ".type")
+		return nil, "", false
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", false
+	}
+	contentType, err := ioutil.ReadFile(path + ".type")
+	if err != nil {
+		return nil, "", false
+	}
+	return data, string(contentType), true
+}
+
+// put 先写入 Content-Type ，图片写入临时文件之后再重命名，保证读取到的图片是完整的
+func (d *diskImageCache) put(filename, variant string, data []byte, contentType string) {
+	dir := filepath.Join(d.dir, utils.MD5Hash(filename))
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return
+	}
+	path := filepath.Join(dir, utils.MD5Hash(variant))
+	if err := ioutil.WriteFile(path+".type", []byte(contentType), 0666); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0666); err != nil {
+		return
+	}
+	os.Rename(tmp, path)
+}
+
+func (d *diskImageCache) del(filename string) {
+	os.RemoveAll(filepath.Join(d.dir, utils.MD5Hash(filename)))
+}
+
+// redisImageCache 保存在 Redis 中的图片缓存，多实例部署时共享，每个文件的处理结果保存在一个 hash 中
+type redisImageCache struct {
+	p   *redis.Pool
+	ttl time.Duration
+}
+
+func newRedisImageCache(address, password string, ttl time.Duration) *redisImageCache {
+	dialFunc := func() (c redis.Conn, err error) {
+		c, err = redis.Dial("tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		if password != "" {
+			if _, err := c.Do("AUTH", password); err != nil {
+				c.Close()
+				return nil, err
+			}
+		}
+		return
+	}
+	return &redisImageCache{
+		p: &redis.Pool{
+			MaxIdle:     3,
+			IdleTimeout: 180 * time.Second,
+			Dial:        dialFunc,
+		},
+		ttl: ttl,
+	}
+}
+
+func (r *redisImageCache) get(filename, variant string) ([]byte, string, bool) {
+	c := r.p.Get()
+	defer c.Close()
+	values, err := redis.Values(c.Do("HMGET", r.key(filename), variant, variant+":type"))
+	if err != nil || len(values) != 2 || values[0] == nil || values[1] == nil {
+		return nil, "", false
+	}
+	data, _ := values[0].([]byte)
+	contentType, _ := values[1].([]byte)
+	return data, string(contentType), true
+}
+
+// put 每次写入时重新设置过期时间，同一个文件的处理结果一起过期
+func (r *redisImageCache) put(filename, variant string, data []byte, contentType string) {
+	c := r.p.Get()
+	defer c.Close()
+	_, err := c.Do("HMSET", r.key(filename), variant, data, variant+":type", contentType)
+	if err == nil && r.ttl > 0 {
+		c.Do("EXPIRE", r.key(filename), int64(r.ttl/time.Second))
+	}
+}
+
+func (r *redisImageCache) del(filename string) {
+	c := r.p.Get()
+	defer c.Close()
+	c.Do("DEL", r.key(filename))
+}
+
+func (r *redisImageCache) key(filename string) string {
+	return config.TConfig.AppID + ":image:" + utils.MD5Hash(filename)
+}
@@ -0,0 +1,153 @@
+package files
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+)
+
+func Test_ParseImageOptions(t *testing.T) {
+	config.TConfig = &config.Config{ImageMaxDimension: 1000}
+	tests := []struct {
+		name    string
+		query   string
+		want    *ImageOptions
+		wantSig string
+		wantErr bool
+	}{
+		{name: "1", query: "", want: nil},
+		{name: "2", query: "sig=abc", want: nil},
+		{name: "3", query: "width=200&format=JPG&expires=1456665905&sig=abc", want: &ImageOptions{Width: 200, Format: "jpeg", Expires: 1456665905}, wantSig: "abc"},
+		{name: "4", query: "height=100&quality=80", want: &ImageOptions{Height: 100, Quality: 80}},
+		{name: "5", query: "width=2000", wantErr: true},
+		{name: "6", query: "quality=0", wantErr: true},
+		{name: "7", query: "format=webp", wantErr: true},
+		{name: "8", query: "width=abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+		got, sig, err := ParseImageOptions(values)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. ParseImageOptions() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. ParseImageOptions() = %v, want %v", tt.name, got, tt.want)
+		}
+		if sig != tt.wantSig {
+			t.Errorf("%q. ParseImageOptions() sig = %v, want %v", tt.name, sig, tt.wantSig)
+		}
+	}
+}
+
+func Test_VerifyImageSignature(t *testing.T) {
+	config.TConfig = &config.Config{
+		ServerURL:       "http://127.0.0.1",
+		AppID:           "1001",
+		ImageSigningKey: "secret",
+	}
+	options := ImageOptions{Width: 200, Expires: 1456665905}
+	signed, _ := url.Parse(SignedImageURL("pic.jpg", options))
+	if signed.Path != "/files/1001/pic.jpg" {
+		t.Error("expect:", "/files/1001/pic.jpg", "result:", signed.Path)
+	}
+	parsed, sig, err := ParseImageOptions(signed.Query())
+	if err != nil || reflect.DeepEqual(*parsed, options) == false {
+		t.Error("expect:", options, "result:", parsed, err)
+	}
+	/************************************************************/
+	err = VerifyImageSignature("pic.jpg", *parsed, sig, time.Unix(1456665900, 0))
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	/************************************************************/
+	err = VerifyImageSignature("pic.jpg", *parsed, sig, time.Unix(1456665906, 0))
+	if err == nil || err.Error() != "image signature expired" {
+		t.Error("expect:", "image signature expired", "result:", err)
+	}
+	/************************************************************/
+	parsed.Width = 400
+	err = VerifyImageSignature("pic.jpg", *parsed, sig, time.Unix(1456665900, 0))
+	if err == nil || err.Error() != "invalid image signature" {
+		t.Error("expect:", "invalid image signature", "result:", err)
+	}
+	/************************************************************/
+	err = VerifyImageSignature("other.jpg", options, sig, time.Unix(1456665900, 0))
+	if err == nil || err.Error() != "invalid image signature" {
+		t.Error("expect:", "invalid image signature", "result:", err)
+	}
+}
+
+func Test_fitImageSize(t *testing.T) {
+	tests := []struct {
+		name                string
+		srcWidth, srcHeight int
+		width, height       int
+		wantWidth           int
+		wantHeight          int
+	}{
+		{name: "1", srcWidth: 400, srcHeight: 200, width: 0, height: 0, wantWidth: 400, wantHeight: 200},
+		{name: "2", srcWidth: 400, srcHeight: 200, width: 100, height: 0, wantWidth: 100, wantHeight: 50},
+		{name: "3", srcWidth: 400, srcHeight: 200, width: 0, height: 100, wantWidth: 200, wantHeight: 100},
+		{name: "4", srcWidth: 400, srcHeight: 200, width: 100, height: 100, wantWidth: 100, wantHeight: 50},
+		{name: "5", srcWidth: 400, srcHeight: 200, width: 800, height: 0, wantWidth: 400, wantHeight: 200},
+		{name: "6", srcWidth: 1000, srcHeight: 1, width: 10, height: 0, wantWidth: 10, wantHeight: 1},
+	}
+	for _, tt := range tests {
+		width, height := fitImageSize(tt.srcWidth, tt.srcHeight, tt.width, tt.height)
+		if width != tt.wantWidth || height != tt.wantHeight {
+			t.Errorf("%q. fitImageSize() = %v, %v, want %v, %v", tt.name, width, height, tt.wantWidth, tt.wantHeight)
+		}
+	}
+}
+
+func Test_TransformImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			if x < 2 {
+				src.Set(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				src.Set(x, y, color.RGBA{0, 0, 255, 255})
+			}
+		}
+	}
+	buf := &bytes.Buffer{}
+	png.Encode(buf, src)
+
+	data, contentType, err := TransformImage(buf.Bytes(), ImageOptions{Width: 2})
+	if err != nil || contentType != "image/png" {
+		t.Error("expect:", "image/png", "result:", contentType, err)
+	}
+	dst, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+		return
+	}
+	if dst.Bounds().Dx() != 2 || dst.Bounds().Dy() != 1 {
+		t.Error("expect:", "2x1", "result:", dst.Bounds())
+	}
+	if r, _, b, _ := dst.At(0, 0).RGBA(); r != 0xffff || b != 0 {
+		t.Error("expect:", "red", "result:", dst.At(0, 0))
+	}
+	if r, _, b, _ := dst.At(1, 0).RGBA(); r != 0 || b != 0xffff {
+		t.Error("expect:", "blue", "result:", dst.At(1, 0))
+	}
+	/************************************************************/
+	_, contentType, err = TransformImage(buf.Bytes(), ImageOptions{Format: "jpeg", Quality: 80})
+	if err != nil || contentType != "image/jpeg" {
+		t.Error("expect:", "image/jpeg", "result:", contentType, err)
+	}
+	/************************************************************/
+	_, _, err = TransformImage([]byte("hello"), ImageOptions{Width: 2})
+	if err == nil {
+		t.Error("expect:", "error", "result:", nil)
+	}
+}
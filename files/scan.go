@@ -0,0 +1,215 @@
+package files
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	beegoutils "github.com/astaxie/beego/utils"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/logger"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 上传的文件在保存之前集中校验：先检查 FileMaxSize 与 FileAllowedTypes ，
+// 再交给 FileScanAdapter 扫描病毒，发现病毒时拒绝上传， FileScanAction=Quarantine 时同时把文件保存到隔离目录
+
+// scanner 病毒扫描模块，未配置 FileScanAdapter 时为 nil
+var scanner fileScanner
+
+// ScanResult 病毒扫描结果
+type ScanResult struct {
+	Infected  bool   `json:"infected"`
+	Signature string `json:"signature"` // 病毒名称
+}
+
+// fileScanner 规定了病毒扫描模块需要实现的接口
+type fileScanner interface {
+	scan(filename string, data []byte) (*ScanResult, error)
+}
+
+// newFileScanner 根据当前配置创建病毒扫描模块
+func newFileScanner() fileScanner {
+	timeout := time.Duration(config.TConfig.FileScanTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	switch config.TConfig.FileScanAdapter {
+	case "ClamAV":
+		return newClamdScanner(config.TConfig.FileScanAddress, timeout)
+	case "Webhook":
+		return newWebhookScanner(config.TConfig.FileScanAddress, timeout)
+	}
+	return nil
+}
+
+// CheckFileAllowed 校验上传文件的大小与类型，未指定 contentType 时根据文件名推断
+func CheckFileAllowed(filename, contentType string, size int) error {
+	if max := config.TConfig.FileMaxSize; max > 0 && size > max {
+		return errs.E(errs.FileTooLarge, "File size exceeds the limit of "+strconv.Itoa(max)+" bytes.")
+	}
+	allowed := config.TConfig.FileAllowedTypes
+	if len(allowed) == 0 {
+		return nil
+	}
+	if contentType == "" {
+		contentType = utils.LookupContentType(filename)
+	}
+	if i := strings.Index(contentType, ";"); i != -1 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	ext := "." + strings.ToLower(utils.ExtName(filename))
+	for _, t := range allowed {
+		switch {
+		case strings.HasPrefix(t, "."):
+			if ext == t {
+				return nil
+			}
+		case strings.HasSuffix(t, "/*"):
+			if strings.HasPrefix(contentType, t[:len(t)-1]) {
+				return nil
+			}
+		case contentType == t:
+			return nil
+		}
+	}
+	return errs.E(errs.FileSaveError, "File type is not allowed.")
+}
+
+// ScanFile 扫描文件，发现病毒或者扫描失败时返回错误，未配置 FileScanAdapter 或者文件为空时不扫描
+func ScanFile(filename string, data []byte) error {
+	if scanner == nil || len(data) == 0 {
+		return nil
+	}
+	result, err := scanner.scan(filename, data)
+	if err != nil {
+		logger.Error("scan file", filename, "failed:", err)
+		return errs.E(errs.FileSaveError, "Could not scan file.")
+	}
+	if result.Infected == false {
+		return nil
+	}
+	logger.Warn("file", filename, "rejected by virus scan:", result.Signature)
+	if config.TConfig.FileScanAction == "Quarantine" {
+		if err := quarantineFile(filename, data); err != nil {
+			logger.Error("quarantine file", filename, "failed:", err)
+		}
+	}
+	return errs.E(errs.FileSaveError, "File rejected by virus scan.")
+}
+
+// quarantineFile 把文件保存到隔离目录，文件名前加上随机前缀防止覆盖
+func quarantineFile(filename string, data []byte) error {
+	dir := beegoutils.SelfDir() + string(os.PathSeparator) + "files" + string(os.PathSeparator) + "quarantine" + string(os.PathSeparator) + config.TConfig.AppID
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, utils.CreateFileName()+"-"+filepath.Base(filename)), data, 0600)
+}
+
+// clamdScanner 使用 clamd 的 INSTREAM 命令扫描文件
+type clamdScanner struct {
+	address string
+	timeout time.Duration
+}
+
+func newClamdScanner(address string, timeout time.Duration) *clamdScanner {
+	return &clamdScanner{address: address, timeout: timeout}
+}
+
+// clamdChunkSize 每次发送给 clamd 的数据块大小
+const clamdChunkSize = 64 * 1024
+
+func (c *clamdScanner) scan(filename string, data []byte) (*ScanResult, error) {
+	conn, err := net.DialTimeout("tcp", c.address, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeout))
+
+	// 数据以块的形式发送，每块以 4 字节大端序的长度开头，长度为 0 的块表示结束
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	size := make([]byte, 4)
+	for len(data) > 0 {
+		n := len(data)
+		if n > clamdChunkSize {
+			n = clamdChunkSize
+		}
+		binary.BigEndian.PutUint32(size, uint32(n))
+		w.Write(size)
+		w.Write(data[:n])
+		data = data[n:]
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	w.Write(size)
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return nil, err
+	}
+	return parseClamdReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseClamdReply 解析 clamd 的返回结果，格式为 stream: OK 或者 stream: 病毒名称 FOUND
+func parseClamdReply(reply string) (*ScanResult, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return &ScanResult{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &ScanResult{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	}
+	return nil, errors.New("clamd: " + reply)
+}
+
+// webhookScanner 把文件 POST 到指定地址扫描，返回结果为 {"infected":true,"signature":"..."}
+type webhookScanner struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookScanner(url string, timeout time.Duration) *webhookScanner {
+	return &webhookScanner{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *webhookScanner) scan(filename string, data []byte) (*ScanResult, error) {
+	request, err := http.NewRequest("POST", s.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/octet-stream")
+	request.Header.Set("X-Talisman-Filename", filename)
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, errors.New("scan webhook returned status " + strconv.Itoa(response.StatusCode))
+	}
+	result := &ScanResult{}
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
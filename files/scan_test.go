@@ -0,0 +1,175 @@
+package files
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+)
+
+func Test_CheckFileAllowed(t *testing.T) {
+	config.TConfig = &config.Config{
+		FileMaxSize:      100,
+		FileAllowedTypes: []string{"image/*", "application/pdf", ".txt"},
+	}
+	tests := []struct {
+		name        string
+		filename    string
+		contentType string
+		size        int
+		wantErr     bool
+	}{
+		{name: "1", filename: "pic.png", contentType: "image/png", size: 10},
+		{name: "2", filename: "pic", contentType: "image/jpeg; charset=binary", size: 10},
+		{name: "3", filename: "doc.pdf", contentType: "", size: 10},
+		{name: "4", filename: "note.TXT", contentType: "application/octet-stream", size: 10},
+		{name: "5", filename: "pic.png", contentType: "image/png", size: 101, wantErr: true},
+		{name: "6", filename: "run.exe", contentType: "application/octet-stream", size: 10, wantErr: true},
+		{name: "7", filename: "page.html", contentType: "", size: 10, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := CheckFileAllowed(tt.filename, tt.contentType, tt.size)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. CheckFileAllowed() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+	/************************************************************/
+	config.TConfig = &config.Config{}
+	if err := CheckFileAllowed("run.exe", "", 1000); err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+}
+
+func Test_parseClamdReply(t *testing.T) {
+	tests := []struct {
+		reply   string
+		want    *ScanResult
+		wantErr bool
+	}{
+		{reply: "stream: OK", want: &ScanResult{}},
+		{reply: "stream: Eicar-Test-Signature FOUND", want: &ScanResult{Infected: true, Signature: "Eicar-Test-Signature"}},
+		{reply: "INSTREAM size limit exceeded. ERROR", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseClamdReply(tt.reply)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. parseClamdReply() error = %v, wantErr %v", tt.reply, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. parseClamdReply() = %v, want %v", tt.reply, got, tt.want)
+		}
+	}
+}
+
+func Test_clamdScanner(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	// 模拟 clamd ，读取 INSTREAM 数据，内容包含 EICAR 时返回病毒
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			command, _ := r.ReadString(0)
+			if command != "zINSTREAM\x00" {
+				conn.Close()
+				continue
+			}
+			var data []byte
+			size := make([]byte, 4)
+			for {
+				if _, err := io.ReadFull(r, size); err != nil {
+					break
+				}
+				n := binary.BigEndian.Uint32(size)
+				if n == 0 {
+					break
+				}
+				chunk := make([]byte, n)
+				io.ReadFull(r, chunk)
+				data = append(data, chunk...)
+			}
+			if strings.Contains(string(data), "EICAR") {
+				conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+			} else {
+				conn.Write([]byte("stream: OK\x00"))
+			}
+			conn.Close()
+		}
+	}()
+	s := newClamdScanner(l.Addr().String(), 5*time.Second)
+	/************************************************************/
+	result, err := s.scan("hello.txt", []byte(strings.Repeat("hello", clamdChunkSize)))
+	if err != nil || result.Infected {
+		t.Error("expect:", "clean", "result:", result, err)
+	}
+	/************************************************************/
+	result, err = s.scan("eicar.txt", []byte("X5O!P%@AP-EICAR-TEST"))
+	if err != nil || result.Infected == false || result.Signature != "Eicar-Test-Signature" {
+		t.Error("expect:", "Eicar-Test-Signature", "result:", result, err)
+	}
+}
+
+func Test_webhookScanner(t *testing.T) {
+	var filename string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filename = r.Header.Get("X-Talisman-Filename")
+		data, _ := ioutil.ReadAll(r.Body)
+		switch string(data) {
+		case "virus":
+			w.Write([]byte(`{"infected":true,"signature":"Test-Virus"}`))
+		case "fail":
+			w.WriteHeader(500)
+		default:
+			w.Write([]byte(`{"infected":false}`))
+		}
+	}))
+	defer server.Close()
+	s := newWebhookScanner(server.URL, 5*time.Second)
+	/************************************************************/
+	result, err := s.scan("hello.txt", []byte("hello"))
+	if err != nil || result.Infected || filename != "hello.txt" {
+		t.Error("expect:", "clean", "result:", result, err, filename)
+	}
+	/************************************************************/
+	result, err = s.scan("virus.txt", []byte("virus"))
+	if err != nil || reflect.DeepEqual(result, &ScanResult{Infected: true, Signature: "Test-Virus"}) == false {
+		t.Error("expect:", "Test-Virus", "result:", result, err)
+	}
+	/************************************************************/
+	_, err = s.scan("fail.txt", []byte("fail"))
+	if err == nil {
+		t.Error("expect:", "error", "result:", nil)
+	}
+	/************************************************************/
+	scanner = s
+	defer func() { scanner = nil }()
+	config.TConfig = &config.Config{FileScanAction: "Reject"}
+	if err := ScanFile("hello.txt", []byte("hello")); err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	expect := errs.E(errs.FileSaveError, "File rejected by virus scan.")
+	if err := ScanFile("virus.txt", []byte("virus")); reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	expect = errs.E(errs.FileSaveError, "Could not scan file.")
+	if err := ScanFile("fail.txt", []byte("fail")); reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+}
@@ -0,0 +1,51 @@
+package files
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+)
+
+// FileModTime 获取文件流的修改时间，用于 Last-Modified ，文件流不支持时返回零值
+func FileModTime(stream FileStream) time.Time {
+	switch s := stream.(type) {
+	case interface {
+		ModTime() time.Time
+	}:
+		return s.ModTime()
+	case interface {
+		UploadDate() time.Time
+	}:
+		return s.UploadDate()
+	}
+	return time.Time{}
+}
+
+// FileStreamETag 生成文件流的 ETag ，文件流提供 MD5 时使用 MD5 ，否则使用文件大小与修改时间
+func FileStreamETag(stream FileStream) string {
+	if s, ok := stream.(interface {
+		MD5() string
+	}); ok && s.MD5() != "" {
+		return `"` + s.MD5() + `"`
+	}
+	return `"` + strconv.FormatInt(stream.Size(), 16) + "-" + strconv.FormatInt(FileModTime(stream).UnixNano(), 16) + `"`
+}
+
+// FileDataETag 使用文件内容的 MD5 生成 ETag
+func FileDataETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// VerifyOriginToken 校验 CDN 回源请求携带的 token ，未设置 FileOriginToken 时不校验
+func VerifyOriginToken(token string) bool {
+	expect := config.TConfig.FileOriginToken
+	if expect == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expect)) == 1
+}
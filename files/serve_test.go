@@ -0,0 +1,82 @@
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+)
+
+// md5FileStream 模拟 GridFS 的文件流
+type md5FileStream struct {
+	*strings.Reader
+	md5        string
+	uploadDate time.Time
+}
+
+func (m *md5FileStream) Close() error {
+	return nil
+}
+
+func (m *md5FileStream) MD5() string {
+	return m.md5
+}
+
+func (m *md5FileStream) UploadDate() time.Time {
+	return m.uploadDate
+}
+
+func Test_FileStreamETag(t *testing.T) {
+	file, err := ioutil.TempFile("", "talisman")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	file.Write([]byte("hello world!"))
+	modTime := time.Unix(1456665905, 0)
+	os.Chtimes(file.Name(), modTime, modTime)
+
+	var stream FileStream = &diskFileStream{file: file}
+	if FileModTime(stream).Equal(modTime) == false {
+		t.Error("expect:", modTime, "result:", FileModTime(stream))
+	}
+	etag := FileStreamETag(stream)
+	if strings.HasPrefix(etag, `"c-`) == false || strings.HasSuffix(etag, `"`) == false {
+		t.Error("expect:", `"c-..."`, "result:", etag)
+	}
+	/************************************************************/
+	uploadDate := time.Unix(1456665000, 0)
+	stream = &md5FileStream{Reader: strings.NewReader("hello"), md5: "abc", uploadDate: uploadDate}
+	if etag := FileStreamETag(stream); etag != `"abc"` {
+		t.Error("expect:", `"abc"`, "result:", etag)
+	}
+	if FileModTime(stream).Equal(uploadDate) == false {
+		t.Error("expect:", uploadDate, "result:", FileModTime(stream))
+	}
+}
+
+func Test_FileDataETag(t *testing.T) {
+	etag := FileDataETag([]byte("hello world!"))
+	expect := `"fc3ff98e8c6a0d3087d515c0473f8677"`
+	if etag != expect {
+		t.Error("expect:", expect, "result:", etag)
+	}
+}
+
+func Test_VerifyOriginToken(t *testing.T) {
+	config.TConfig = &config.Config{}
+	if VerifyOriginToken("") == false {
+		t.Error("expect:", true, "result:", false)
+	}
+	config.TConfig = &config.Config{FileOriginToken: "secret"}
+	if VerifyOriginToken("secret") == false {
+		t.Error("expect:", true, "result:", false)
+	}
+	if VerifyOriginToken("") || VerifyOriginToken("other") {
+		t.Error("expect:", false, "result:", true)
+	}
+}
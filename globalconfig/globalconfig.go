@@ -0,0 +1,246 @@
+// Package globalconfig 管理保存在 _GlobalConfig 中的应用参数
+// 参数读取后缓存在进程内，修改参数时同步更新缓存，并通知所有监听者
+// 修改参数后通过 cache.Publish 通知其他实例重新加载参数
+package globalconfig
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/okobsamoht/talisman/cache"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+const globalConfigClassName = "_GlobalConfig"
+const globalConfigObjectID = "1"
+
+// globalConfigChannel 通知各个实例参数发生变化的通道
+const globalConfigChannel = "globalconfig"
+
+// instanceID 标识当前实例，用于忽略自己发送的通知
+var instanceID = utils.CreateObjectID()
+
+// Listener 参数发生变化时的回调， value 为新值，删除参数时 value 为 nil
+type Listener func(key string, value interface{})
+
+var (
+	mu        sync.RWMutex
+	params    types.M
+	listeners = map[int]Listener{}
+	nextID    int
+)
+
+func init() {
+	cache.Subscribe(globalConfigChannel, onGlobalConfigMessage)
+}
+
+// onGlobalConfigMessage 处理其他实例发送的参数变化通知，已加载过参数时从数据库中重新加载
+func onGlobalConfigMessage(message string) {
+	if message == instanceID {
+		return
+	}
+	mu.RLock()
+	loaded := params != nil
+	mu.RUnlock()
+	if loaded {
+		Load()
+	}
+}
+
+// Load 从数据库中加载参数，并通知发生变化的参数
+func Load() error {
+	results, err := orm.TalismanDBController.Find(globalConfigClassName, types.M{"objectId": globalConfigObjectID}, types.M{"limit": 1})
+	if err != nil {
+		return err
+	}
+	newParams := types.M{}
+	if len(results) == 1 {
+		if globalConfig := utils.M(results[0]); globalConfig != nil {
+			if p := utils.M(globalConfig["params"]); p != nil {
+				newParams = p
+			}
+		}
+	}
+
+	mu.Lock()
+	oldParams := params
+	params = newParams
+	mu.Unlock()
+
+	if oldParams != nil {
+		changes := types.M{}
+		for k, v := range newParams {
+			if old, ok := oldParams[k]; ok == false || reflect.DeepEqual(old, v) == false {
+				changes[k] = v
+			}
+		}
+		for k := range oldParams {
+			if _, ok := newParams[k]; ok == false {
+				changes[k] = nil
+			}
+		}
+		notify(changes)
+	}
+	return nil
+}
+
+// Params 返回所有参数，首次调用时从数据库中加载
+func Params() types.M {
+	mu.RLock()
+	p := params
+	mu.RUnlock()
+	if p == nil {
+		if err := Load(); err != nil {
+			return types.M{}
+		}
+		mu.RLock()
+		p = params
+		mu.RUnlock()
+	}
+	return utils.CopyMap(p)
+}
+
+// Get 获取参数，参数不存在时返回 nil
+func Get(key string) interface{} {
+	return Params()[key]
+}
+
+// GetString 获取字符串类型的参数，参数不存在或者类型不符时返回 defaultValue
+func GetString(key, defaultValue string) string {
+	if v, ok := Get(key).(string); ok {
+		return v
+	}
+	return defaultValue
+}
+
+// GetBool 获取布尔类型的参数
+func GetBool(key string, defaultValue bool) bool {
+	if v, ok := Get(key).(bool); ok {
+		return v
+	}
+	return defaultValue
+}
+
+// GetInt 获取整数类型的参数，支持数字与数字字符串
+func GetInt(key string, defaultValue int) int {
+	switch v := Get(key).(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case string:
+		if i, err := strconv.Atoi(v); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+// GetFloat 获取浮点类型的参数
+func GetFloat(key string, defaultValue float64) float64 {
+	switch v := Get(key).(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// GetM 获取对象类型的参数
+func GetM(key string) types.M {
+	return utils.M(Get(key))
+}
+
+// GetA 获取数组类型的参数
+func GetA(key string) types.S {
+	return utils.A(Get(key))
+}
+
+// Set 修改参数，需要由调用方校验 masterKey
+// 参数值为 {"__op":"Delete"} 时删除该参数
+func Set(newParams types.M) error {
+	if len(newParams) == 0 {
+		return nil
+	}
+	// 确保已加载过参数，用于更新缓存
+	Params()
+	update := types.M{}
+	for k, v := range newParams {
+		update["params."+k] = v
+	}
+	_, err := orm.TalismanDBController.Update(globalConfigClassName, types.M{"objectId": globalConfigObjectID}, update, types.M{"upsert": true}, false)
+	if err != nil {
+		return err
+	}
+
+	changes := types.M{}
+	mu.Lock()
+	if params == nil {
+		params = types.M{}
+	}
+	for k, v := range newParams {
+		if op := utils.M(v); op != nil && utils.S(op["__op"]) == "Delete" {
+			delete(params, k)
+			changes[k] = nil
+		} else {
+			params[k] = v
+			changes[k] = v
+		}
+	}
+	mu.Unlock()
+
+	notify(changes)
+	cache.Publish(globalConfigChannel, instanceID)
+	return nil
+}
+
+// Watch 注册参数变化的监听者，返回用于取消监听的函数
+func Watch(listener Listener) func() {
+	mu.Lock()
+	id := nextID
+	nextID++
+	listeners[id] = listener
+	mu.Unlock()
+	return func() {
+		mu.Lock()
+		delete(listeners, id)
+		mu.Unlock()
+	}
+}
+
+// Clear 清空进程内缓存，下次读取时从数据库加载
+func Clear() {
+	mu.Lock()
+	params = nil
+	mu.Unlock()
+}
+
+// notify 通知所有监听者，回调在锁外执行
+func notify(changes types.M) {
+	if len(changes) == 0 {
+		return
+	}
+	mu.RLock()
+	ls := []Listener{}
+	for _, l := range listeners {
+		ls = append(ls, l)
+	}
+	mu.RUnlock()
+	for k, v := range changes {
+		for _, l := range ls {
+			l(k, v)
+		}
+	}
+}
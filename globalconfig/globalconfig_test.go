@@ -0,0 +1,77 @@
+package globalconfig
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/storage/mongo"
+	"github.com/okobsamoht/talisman/test"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_Set(t *testing.T) {
+	initEnv()
+	var err error
+	var result types.M
+	var expect types.M
+	var changes types.M
+	/*************************************************/
+	changes = types.M{}
+	cancel := Watch(func(key string, value interface{}) {
+		changes[key] = value
+	})
+	err = Set(types.M{"key": "hello", "count": 10})
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	result = Params()
+	expect = types.M{"key": "hello", "count": 10}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	if reflect.DeepEqual(expect, changes) == false {
+		t.Error("expect:", expect, "result:", changes)
+	}
+	if GetString("key", "") != "hello" || GetInt("count", 0) != 10 {
+		t.Error("expect:", expect, "result:", Params())
+	}
+	/*************************************************/
+	changes = types.M{}
+	err = Set(types.M{"key": types.M{"__op": "Delete"}})
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	expect = types.M{"key": nil}
+	if reflect.DeepEqual(expect, changes) == false {
+		t.Error("expect:", expect, "result:", changes)
+	}
+	Clear()
+	if GetString("key", "default") != "default" || GetInt("count", 0) != 10 {
+		t.Error("expect:", types.M{"count": 10}, "result:", Params())
+	}
+	/*************************************************/
+	// 其他实例修改参数后发送通知
+	changes = types.M{}
+	orm.TalismanDBController.Update(globalConfigClassName, types.M{"objectId": globalConfigObjectID}, types.M{"params.key": "world"}, types.M{}, false)
+	onGlobalConfigMessage(instanceID)
+	if GetString("key", "default") != "default" {
+		t.Error("expect:", "default", "result:", Params())
+	}
+	onGlobalConfigMessage("other")
+	expect = types.M{"key": "world"}
+	if reflect.DeepEqual(expect, changes) == false {
+		t.Error("expect:", expect, "result:", changes)
+	}
+	if GetString("key", "default") != "world" {
+		t.Error("expect:", "world", "result:", Params())
+	}
+	cancel()
+	orm.TalismanDBController.DeleteEverything()
+	Clear()
+}
+
+func initEnv() {
+	orm.InitOrm(mongo.NewMongoAdapter("talisman", test.OpenMongoDBForTest()))
+	Clear()
+}
@@ -1,7 +1,15 @@
 package hooks
 
 import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/okobsamoht/talisman/cache"
 	"github.com/okobsamoht/talisman/cloud"
+	"github.com/okobsamoht/talisman/config"
 	"github.com/okobsamoht/talisman/errs"
 	"github.com/okobsamoht/talisman/orm"
 	"github.com/okobsamoht/talisman/types"
@@ -10,22 +18,82 @@ import (
 
 const defaultHooksCollectionName = "_Hooks"
 
+// hooksChannel 通知其他实例 hook 发生变化的通道
+const hooksChannel = "hooks"
+
+// validTriggerNames 允许通过 hook 注册的触发器类型
+var validTriggerNames = []string{
+	cloud.TypeBeforeSave,
+	cloud.TypeAfterSave,
+	cloud.TypeBeforeDelete,
+	cloud.TypeAfterDelete,
+	cloud.TypeBeforeFind,
+	cloud.TypeAfterFind,
+}
+
+// 内存中缓存的 hook ，加载之前为 nil ，此时从数据库中查询
+var (
+	hooksMutex    sync.RWMutex
+	functionHooks map[string]types.M // functionName -> hook
+	triggerHooks  map[string]types.M // className:triggerName -> hook
+)
+
 func init() {
-	Load()
+	cloud.SetDeadLetterHandler(recordDeadLetter)
+	cache.Subscribe(hooksChannel, onHookMessage)
 }
 
-// Load ...
+// Load 从数据库中加载所有 hook ，注册到云代码中并缓存在内存里
+// 已经不存在的 hook 会从云代码中移除
 func Load() {
-	hooks, _ := getHooks(types.M{}, types.M{})
+	hooks, err := getHooks(types.M{}, types.M{})
+	if err != nil {
+		return
+	}
+	newFunctions := map[string]types.M{}
+	newTriggers := map[string]types.M{}
 	for _, v := range hooks {
-		if hook := utils.M(v); hook != nil {
-			addHookToTriggers(hook)
+		hook := utils.M(v)
+		if hook == nil {
+			continue
+		}
+		addHookToTriggers(hook)
+		if hook["className"] != nil {
+			newTriggers[triggerKey(utils.S(hook["className"]), utils.S(hook["triggerName"]))] = hook
+		} else if hook["functionName"] != nil {
+			newFunctions[utils.S(hook["functionName"])] = hook
+		}
+	}
+
+	hooksMutex.Lock()
+	oldFunctions, oldTriggers := functionHooks, triggerHooks
+	functionHooks, triggerHooks = newFunctions, newTriggers
+	hooksMutex.Unlock()
+
+	for name := range oldFunctions {
+		if _, ok := newFunctions[name]; ok == false {
+			cloud.RemoveFunction(name)
+		}
+	}
+	for key, hook := range oldTriggers {
+		if _, ok := newTriggers[key]; ok == false {
+			cloud.RemoveTrigger(utils.S(hook["triggerName"]), utils.S(hook["className"]))
 		}
 	}
 }
 
 // GetFunction ...
 func GetFunction(functionName string) (types.M, error) {
+	hooksMutex.RLock()
+	if functionHooks != nil {
+		defer hooksMutex.RUnlock()
+		if hook, ok := functionHooks[functionName]; ok {
+			return utils.CopyMapM(hook), nil
+		}
+		return nil, nil
+	}
+	hooksMutex.RUnlock()
+
 	results, err := getHooks(types.M{"functionName": functionName}, types.M{"limit": 1})
 	if err != nil {
 		return nil, err
@@ -38,6 +106,13 @@ func GetFunction(functionName string) (types.M, error) {
 
 // GetFunctions ...
 func GetFunctions() (types.S, error) {
+	hooksMutex.RLock()
+	if functionHooks != nil {
+		defer hooksMutex.RUnlock()
+		return sortedHooks(functionHooks), nil
+	}
+	hooksMutex.RUnlock()
+
 	results, err := getHooks(types.M{"functionName": types.M{"$exists": true}}, types.M{})
 	if err != nil {
 		return nil, err
@@ -47,6 +122,16 @@ func GetFunctions() (types.S, error) {
 
 // GetTrigger ...
 func GetTrigger(className, triggerName string) (types.M, error) {
+	hooksMutex.RLock()
+	if triggerHooks != nil {
+		defer hooksMutex.RUnlock()
+		if hook, ok := triggerHooks[triggerKey(className, triggerName)]; ok {
+			return utils.CopyMapM(hook), nil
+		}
+		return nil, nil
+	}
+	hooksMutex.RUnlock()
+
 	results, err := getHooks(types.M{"className": className, "triggerName": triggerName}, types.M{"limit": 1})
 	if err != nil {
 		return nil, err
@@ -59,6 +144,13 @@ func GetTrigger(className, triggerName string) (types.M, error) {
 
 // GetTriggers ...
 func GetTriggers() (types.S, error) {
+	hooksMutex.RLock()
+	if triggerHooks != nil {
+		defer hooksMutex.RUnlock()
+		return sortedHooks(triggerHooks), nil
+	}
+	hooksMutex.RUnlock()
+
 	results, err := getHooks(types.M{"className": types.M{"$exists": true}, "triggerName": types.M{"$exists": true}}, types.M{})
 	if err != nil {
 		return nil, err
@@ -68,14 +160,22 @@ func GetTriggers() (types.S, error) {
 
 // DeleteFunction ...
 func DeleteFunction(functionName string) error {
-	cloud.RemoveFunction(functionName)
-	return removeHooks(types.M{"functionName": functionName})
+	err := removeHooks(types.M{"functionName": functionName})
+	if err != nil {
+		return err
+	}
+	notify("delete", types.M{"functionName": functionName})
+	return nil
 }
 
 // DeleteTrigger ...
 func DeleteTrigger(className, triggerName string) error {
-	cloud.RemoveTrigger(triggerName, className)
-	return removeHooks(types.M{"className": className, "triggerName": triggerName})
+	err := removeHooks(types.M{"className": className, "triggerName": triggerName})
+	if err != nil {
+		return err
+	}
+	notify("delete", types.M{"className": className, "triggerName": triggerName})
+	return nil
 }
 
 func getHooks(query, options types.M) (types.S, error) {
@@ -116,13 +216,18 @@ func saveHook(hook types.M) (types.M, error) {
 func addHookToTriggers(hook types.M) {
 	if hook["className"] != nil {
 		cloud.AddTrigger(utils.S(hook["triggerName"]), utils.S(hook["className"]), cloud.GetTriggerHandler(utils.S(hook["url"])))
+		return
 	}
 	cloud.AddFunction(utils.S(hook["functionName"]), cloud.GetFunctionHandler(utils.S(hook["url"])), nil)
 }
 
 func addHook(hook types.M) (types.M, error) {
-	addHookToTriggers(hook)
-	return saveHook(hook)
+	result, err := saveHook(hook)
+	if err != nil {
+		return nil, err
+	}
+	notify("save", hook)
+	return result, nil
 }
 
 func createOrUpdateHook(aHook types.M) (types.M, error) {
@@ -142,6 +247,11 @@ func createOrUpdateHook(aHook types.M) (types.M, error) {
 		return nil, errs.E(errs.WebhookError, "invalid hook declaration")
 	}
 
+	err := validateHook(hook)
+	if err != nil {
+		return nil, err
+	}
+
 	return addHook(hook)
 }
 
@@ -180,3 +290,132 @@ func UpdateHook(aHook types.M) (types.M, error) {
 	}
 	return nil, errs.E(errs.WebhookError, "invalid hook declaration")
 }
+
+// validateHook 校验 hook 的地址、类名以及触发器类型
+func validateHook(hook types.M) error {
+	u, err := url.Parse(utils.S(hook["url"]))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errs.E(errs.WebhookError, "invalid hook url: "+utils.S(hook["url"]))
+	}
+	if hook["functionName"] != nil {
+		if utils.S(hook["functionName"]) == "" {
+			return errs.E(errs.WebhookError, "invalid function name")
+		}
+		return nil
+	}
+	className := utils.S(hook["className"])
+	if orm.ClassNameIsValid(className) == false {
+		return errs.E(errs.WebhookError, "invalid class name: "+className)
+	}
+	triggerName := utils.S(hook["triggerName"])
+	for _, name := range validTriggerNames {
+		if name == triggerName {
+			return nil
+		}
+	}
+	return errs.E(errs.WebhookError, "invalid trigger name: "+triggerName)
+}
+
+// notify 在当前实例中应用 hook 的变化，并通知其他实例
+func notify(action string, hook types.M) {
+	applyHook(action, hook)
+	message, err := json.Marshal(types.M{"action": action, "hook": hook})
+	if err != nil {
+		return
+	}
+	cache.Publish(hooksChannel, string(message))
+}
+
+// onHookMessage 处理其他实例发送的 hook 变化通知，当前实例发送的通知会重复应用一次，不影响结果
+func onHookMessage(message string) {
+	var m types.M
+	if err := json.Unmarshal([]byte(message), &m); err != nil {
+		return
+	}
+	if hook := utils.M(m["hook"]); hook != nil {
+		applyHook(utils.S(m["action"]), hook)
+	}
+}
+
+// applyHook 更新云代码注册的 hook 以及内存中的缓存
+func applyHook(action string, hook types.M) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	if functionHooks == nil {
+		functionHooks = map[string]types.M{}
+	}
+	if triggerHooks == nil {
+		triggerHooks = map[string]types.M{}
+	}
+
+	if hook["className"] != nil {
+		className := utils.S(hook["className"])
+		triggerName := utils.S(hook["triggerName"])
+		switch action {
+		case "save":
+			addHookToTriggers(hook)
+			triggerHooks[triggerKey(className, triggerName)] = hook
+		case "delete":
+			cloud.RemoveTrigger(triggerName, className)
+			delete(triggerHooks, triggerKey(className, triggerName))
+		}
+		return
+	}
+
+	functionName := utils.S(hook["functionName"])
+	if functionName == "" {
+		return
+	}
+	switch action {
+	case "save":
+		addHookToTriggers(hook)
+		functionHooks[functionName] = hook
+	case "delete":
+		cloud.RemoveFunction(functionName)
+		delete(functionHooks, functionName)
+	}
+}
+
+func triggerKey(className, triggerName string) string {
+	return className + ":" + triggerName
+}
+
+// sortedHooks 按 key 排序返回 hook 列表，需要在持有锁时调用
+func sortedHooks(hooks map[string]types.M) types.S {
+	keys := make([]string, 0, len(hooks))
+	for k := range hooks {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	results := types.S{}
+	for _, k := range keys {
+		results = append(results, utils.CopyMapM(hooks[k]))
+	}
+	return results
+}
+
+// recordDeadLetter 记录重试后仍然失败的云代码请求，仅保存触发器名称、类名与错误信息，不保存请求数据
+func recordDeadLetter(URL string, params types.M, err error, attempts int) {
+	className := config.TConfig.WebhookDeadLetterClassName
+	if className == "" {
+		return
+	}
+	now := utils.TimetoString(time.Now().UTC())
+	object := types.M{
+		"objectId":  utils.CreateObjectID(),
+		"url":       URL,
+		"error":     err.Error(),
+		"attempts":  attempts,
+		"createdAt": now,
+		"updatedAt": now,
+		// 仅允许 Master 访问
+		"ACL": types.M{},
+	}
+	if triggerName := utils.S(params["triggerName"]); triggerName != "" {
+		object["triggerName"] = triggerName
+		if o := utils.M(params["object"]); o != nil {
+			object["className"] = utils.S(o["className"])
+		}
+	}
+	orm.TalismanDBController.Create(className, object, types.M{})
+}
@@ -0,0 +1,73 @@
+package hooks
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_validateHook(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    types.M
+		wantErr error
+	}{
+		{
+			name:    "1",
+			hook:    types.M{"functionName": "hello", "url": "https://example.com/hello"},
+			wantErr: nil,
+		},
+		{
+			name:    "2",
+			hook:    types.M{"functionName": "hello", "url": "example.com/hello"},
+			wantErr: errs.E(errs.WebhookError, "invalid hook url: example.com/hello"),
+		},
+		{
+			name:    "3",
+			hook:    types.M{"className": "Post", "triggerName": "beforeSave", "url": "http://example.com/post"},
+			wantErr: nil,
+		},
+		{
+			name:    "4",
+			hook:    types.M{"className": "Post", "triggerName": "beforeUpdate", "url": "http://example.com/post"},
+			wantErr: errs.E(errs.WebhookError, "invalid trigger name: beforeUpdate"),
+		},
+		{
+			name:    "5",
+			hook:    types.M{"className": "_Post", "triggerName": "beforeSave", "url": "http://example.com/post"},
+			wantErr: errs.E(errs.WebhookError, "invalid class name: _Post"),
+		},
+	}
+	for _, tt := range tests {
+		if err := validateHook(tt.hook); reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. validateHook() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_onHookMessage(t *testing.T) {
+	functionHooks = nil
+	triggerHooks = nil
+	/*************************************************/
+	onHookMessage(`{"action":"save","hook":{"functionName":"hello","url":"http://example.com/hello"}}`)
+	onHookMessage(`{"action":"save","hook":{"className":"Post","triggerName":"afterSave","url":"http://example.com/post"}}`)
+	result, _ := GetFunctions()
+	expect := types.S{types.M{"functionName": "hello", "url": "http://example.com/hello"}}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	trigger, _ := GetTrigger("Post", "afterSave")
+	expectTrigger := types.M{"className": "Post", "triggerName": "afterSave", "url": "http://example.com/post"}
+	if reflect.DeepEqual(expectTrigger, trigger) == false {
+		t.Error("expect:", expectTrigger, "result:", trigger)
+	}
+	/*************************************************/
+	onHookMessage(`{"action":"delete","hook":{"functionName":"hello"}}`)
+	result, _ = GetFunctions()
+	expect = types.S{}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
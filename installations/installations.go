@@ -0,0 +1,120 @@
+// Package installations 处理 _Installation 表的数据约束与角标操作
+package installations
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+const installationClassName = "_Installation"
+
+// channelNameRegex 频道名称以字母开头，只能包含字母、数字、下划线、横线，空字符串表示广播频道
+var channelNameRegex = regexp.MustCompile(`^$|^[a-zA-Z][\w-]*$`)
+
+// ValidateChannels 校验 channels 字段，支持数组以及 Add AddUnique Remove 操作
+func ValidateChannels(channels interface{}) error {
+	if channels == nil {
+		return nil
+	}
+	var names types.S
+	if op := utils.M(channels); op != nil {
+		switch utils.S(op["__op"]) {
+		case "Add", "AddUnique", "Remove":
+			names = utils.A(op["objects"])
+			if names == nil {
+				return errs.E(errs.InvalidJSON, "objects to add must be an array")
+			}
+		case "Delete":
+			return nil
+		default:
+			return errs.E(errs.IncorrectType, "channels must be an array")
+		}
+	} else if names = utils.A(channels); names == nil {
+		return errs.E(errs.IncorrectType, "channels must be an array")
+	}
+	for _, v := range names {
+		name, ok := v.(string)
+		if ok == false {
+			return errs.E(errs.IncorrectType, "channel name must be a string")
+		}
+		if channelNameRegex.MatchString(name) == false {
+			return errs.E(errs.InvalidChannelName, "Invalid channel name: "+name+". Channel names must start with a letter and contain only letters, numbers, underscores and dashes.")
+		}
+	}
+	return nil
+}
+
+// ValidateBadge 校验 badge 字段，支持大于等于 0 的整数以及 Increment 操作
+func ValidateBadge(badge interface{}) error {
+	if badge == nil {
+		return nil
+	}
+	if op := utils.M(badge); op != nil {
+		switch utils.S(op["__op"]) {
+		case "Increment":
+			if _, ok := toInt(op["amount"]); ok == false {
+				return errs.E(errs.IncorrectType, "badge increment amount must be a number")
+			}
+			return nil
+		case "Delete":
+			return nil
+		}
+		return errs.E(errs.IncorrectType, "badge must be a number")
+	}
+	n, ok := toInt(badge)
+	if ok == false {
+		return errs.E(errs.IncorrectType, "badge must be a number")
+	}
+	if n < 0 {
+		return errs.E(errs.IncorrectType, "badge must not be negative")
+	}
+	return nil
+}
+
+// IncrementBadge 增加指定设备的角标
+func IncrementBadge(installationID string, amount int) error {
+	return updateBadge(installationID, types.M{"__op": "Increment", "amount": amount})
+}
+
+// ResetBadge 将指定设备的角标清零
+func ResetBadge(installationID string) error {
+	return updateBadge(installationID, 0)
+}
+
+// SetBadge 设置指定设备的角标
+func SetBadge(installationID string, badge int) error {
+	if badge < 0 {
+		return errs.E(errs.IncorrectType, "badge must not be negative")
+	}
+	return updateBadge(installationID, badge)
+}
+
+func updateBadge(installationID string, badge interface{}) error {
+	if installationID == "" {
+		return errs.E(errs.MissingRequiredFieldError, "installationId is required")
+	}
+	query := types.M{"installationId": strings.ToLower(installationID)}
+	update := types.M{"badge": badge}
+	_, err := orm.TalismanDBController.Update(installationClassName, query, update, types.M{"many": true}, false)
+	return err
+}
+
+func toInt(i interface{}) (int, bool) {
+	switch v := i.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		if v != float64(int(v)) {
+			return 0, false
+		}
+		return int(v), true
+	}
+	return 0, false
+}
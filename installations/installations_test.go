@@ -0,0 +1,58 @@
+package installations
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_ValidateChannels(t *testing.T) {
+	tests := []struct {
+		name     string
+		channels interface{}
+		wantErr  error
+	}{
+		{name: "1", channels: nil, wantErr: nil},
+		{name: "2", channels: types.S{"", "news", "sport_1", "a-b"}, wantErr: nil},
+		{name: "3", channels: "news", wantErr: errs.E(errs.IncorrectType, "channels must be an array")},
+		{name: "4", channels: types.S{1}, wantErr: errs.E(errs.IncorrectType, "channel name must be a string")},
+		{
+			name:     "5",
+			channels: types.S{"1news"},
+			wantErr:  errs.E(errs.InvalidChannelName, "Invalid channel name: 1news. Channel names must start with a letter and contain only letters, numbers, underscores and dashes."),
+		},
+		{name: "6", channels: types.M{"__op": "AddUnique", "objects": types.S{"news"}}, wantErr: nil},
+		{name: "7", channels: types.M{"__op": "Remove", "objects": "news"}, wantErr: errs.E(errs.InvalidJSON, "objects to add must be an array")},
+		{name: "8", channels: types.M{"__op": "Delete"}, wantErr: nil},
+	}
+	for _, tt := range tests {
+		if err := ValidateChannels(tt.channels); reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. ValidateChannels() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_ValidateBadge(t *testing.T) {
+	tests := []struct {
+		name    string
+		badge   interface{}
+		wantErr error
+	}{
+		{name: "1", badge: nil, wantErr: nil},
+		{name: "2", badge: 10, wantErr: nil},
+		{name: "3", badge: 10.0, wantErr: nil},
+		{name: "4", badge: 1.5, wantErr: errs.E(errs.IncorrectType, "badge must be a number")},
+		{name: "5", badge: -1, wantErr: errs.E(errs.IncorrectType, "badge must not be negative")},
+		{name: "6", badge: "1", wantErr: errs.E(errs.IncorrectType, "badge must be a number")},
+		{name: "7", badge: types.M{"__op": "Increment", "amount": 1}, wantErr: nil},
+		{name: "8", badge: types.M{"__op": "Increment", "amount": "1"}, wantErr: errs.E(errs.IncorrectType, "badge increment amount must be a number")},
+		{name: "9", badge: types.M{"__op": "Add", "objects": types.S{1}}, wantErr: errs.E(errs.IncorrectType, "badge must be a number")},
+	}
+	for _, tt := range tests {
+		if err := ValidateBadge(tt.badge); reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. ValidateBadge() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Package jwt 使用 HS256 签名的 JSON Web Token ，用于无状态的 sessionToken
+// header 中的 kid 指定签名密钥，轮换密钥时旧密钥签发的 token 在过期前仍然有效
+package jwt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+const algorithm = "HS256"
+
+var encoding = base64.RawURLEncoding
+
+// Sign 使用 key 签名 claims ，生成 token
+func Sign(claims types.M, kid string, key []byte) string {
+	header, _ := json.Marshal(types.M{"alg": algorithm, "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signingInput := encoding.EncodeToString(header) + "." + encoding.EncodeToString(payload)
+	return signingInput + "." + encoding.EncodeToString(signature(signingInput, key))
+}
+
+// Parse 校验 token 的签名与过期时间，返回 claims
+// keys 以 kid 为索引，包含所有可用于验证的密钥
+func Parse(token string, keys map[string][]byte, now time.Time) (types.M, error) {
+	invalid := errs.E(errs.InvalidSessionToken, "invalid session token")
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalid
+	}
+
+	var header types.M
+	if decode(parts[0], &header) != nil {
+		return nil, invalid
+	}
+	if utils.S(header["alg"]) != algorithm {
+		return nil, invalid
+	}
+	key, ok := keys[utils.S(header["kid"])]
+	if ok == false {
+		return nil, invalid
+	}
+	sig, err := encoding.DecodeString(parts[2])
+	if err != nil || hmac.Equal(sig, signature(parts[0]+"."+parts[1], key)) == false {
+		return nil, invalid
+	}
+
+	var claims types.M
+	if decode(parts[1], &claims) != nil {
+		return nil, invalid
+	}
+	exp, ok := claims["exp"].(float64)
+	if ok == false || int64(exp) <= now.Unix() {
+		return nil, errs.E(errs.InvalidSessionToken, "Session token is expired.")
+	}
+	return claims, nil
+}
+
+// IsToken 判断字符串是否为 JWT 格式，不校验签名
+func IsToken(token string) bool {
+	return strings.Count(token, ".") == 2 && strings.HasPrefix(token, "r:") == false
+}
+
+func signature(signingInput string, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signingInput))
+	return mac.Sum(nil)
+}
+
+func decode(segment string, v interface{}) error {
+	b, err := encoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,88 @@
+package jwt
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_SignAndParse(t *testing.T) {
+	var token string
+	var claims types.M
+	var err error
+	var expect types.M
+	var expectErr error
+	now := time.Unix(1500000000, 0)
+	keys := map[string][]byte{
+		"k1": []byte("0123456789abcdef0123456789abcdef"),
+		"k2": []byte("fedcba9876543210fedcba9876543210"),
+	}
+	/*****************************************************/
+	token = Sign(types.M{"sub": "1001", "exp": now.Unix() + 60}, "k1", keys["k1"])
+	claims, err = Parse(token, keys, now)
+	expect = types.M{"sub": "1001", "exp": float64(now.Unix() + 60)}
+	if err != nil || reflect.DeepEqual(expect, claims) == false {
+		t.Error("expect:", expect, "result:", claims, err)
+	}
+	/*****************************************************/
+	token = Sign(types.M{"sub": "1001", "exp": now.Unix() + 60}, "k2", keys["k2"])
+	claims, err = Parse(token, keys, now)
+	if err != nil || reflect.DeepEqual(expect, claims) == false {
+		t.Error("expect:", expect, "result:", claims, err)
+	}
+	/*****************************************************/
+	token = Sign(types.M{"sub": "1001", "exp": now.Unix() + 60}, "k3", keys["k1"])
+	claims, err = Parse(token, keys, now)
+	expectErr = errs.E(errs.InvalidSessionToken, "invalid session token")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", claims, err)
+	}
+	/*****************************************************/
+	token = Sign(types.M{"sub": "1001", "exp": now.Unix() + 60}, "k1", keys["k2"])
+	claims, err = Parse(token, keys, now)
+	expectErr = errs.E(errs.InvalidSessionToken, "invalid session token")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", claims, err)
+	}
+	/*****************************************************/
+	token = Sign(types.M{"sub": "1001", "exp": now.Unix()}, "k1", keys["k1"])
+	claims, err = Parse(token, keys, now)
+	expectErr = errs.E(errs.InvalidSessionToken, "Session token is expired.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", claims, err)
+	}
+	/*****************************************************/
+	token = Sign(types.M{"sub": "1001"}, "k1", keys["k1"])
+	claims, err = Parse(token, keys, now)
+	expectErr = errs.E(errs.InvalidSessionToken, "Session token is expired.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", claims, err)
+	}
+	/*****************************************************/
+	token = "r:0123456789ABCDEF"
+	claims, err = Parse(token, keys, now)
+	expectErr = errs.E(errs.InvalidSessionToken, "invalid session token")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", claims, err)
+	}
+}
+
+func Test_IsToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{name: "1", token: "aaa.bbb.ccc", want: true},
+		{name: "2", token: "r:0123456789ABCDEF", want: false},
+		{name: "3", token: "0123456789ABCDEF", want: false},
+	}
+	for _, tt := range tests {
+		if got := IsToken(tt.token); got != tt.want {
+			t.Errorf("%q. IsToken() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
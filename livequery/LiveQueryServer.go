@@ -1,9 +1,12 @@
 package livequery
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"strconv"
 	"sync"
+	"time"
 
 	"strings"
 
@@ -15,6 +18,7 @@ import (
 
 /*
 Parse LiveQuery Protocol Specification
+消息格式同样适用于 SSE 接口，见 server/sse.go
 https://github.com/ParsePlatform/parse-server/wiki/Parse-LiveQuery-Protocol-Specification
 
 Connect message:
@@ -30,7 +34,48 @@ request
 }
 response
 {
-	"op": "connected"
+	"op": "connected",
+	"clientId": 1,
+	"sessionId": "" // 开启订阅恢复时返回，用于重连之后恢复订阅
+}
+
+Resubscribe message:
+客户端断开重连之后发送，字段与 connect 相同。
+如果 sessionId 对应的会话仍在保留期内，则恢复原有订阅，并按顺序补发断开期间的消息；
+否则创建新的会话，并根据 subscriptions 重新订阅，此时 resumed 为 false ，客户端需要自行刷新数据
+request
+{
+	"op": "resubscribe",
+	"sessionId": "", // Optional
+	"subscriptions": [ // Optional
+		{
+			"requestId": 1,
+			"query": {
+				"className": "Player",
+				"where": {"name": "test"}
+			},
+			"sessionToken": "" // Optional
+		}
+	],
+	"masterKey": "" // Optional
+}
+response
+{
+	"op": "resubscribed",
+	"clientId": 1,
+	"sessionId": "",
+	"resumed": true
+}
+
+Ping message:
+客户端可定时发送，用于保持连接
+request
+{
+	"op": "ping"
+}
+response
+{
+	"op": "pong"
 }
 
 Subscribe message:
@@ -172,6 +217,8 @@ type liveQueryServer struct {
 	clients           map[int]*server.Client                     // 当前已连接的客户端，以 clientID 为索引 TODO 增加并发锁
 	subscriptions     map[string]map[string]*server.Subscription // 当前所有的订阅对象 className -> (queryHash -> subscription) TODO 增加并发锁
 	keyPairs          map[string]string                          // 用于客户端鉴权的键值对，如 secretKey:abcd
+	sessions          map[string]*server.Client                  // 可恢复的客户端，以 sessionId 为索引
+	resumeWindow      time.Duration                              // 客户端断开之后保留订阅的时间， 0 表示不保留
 	subscriber        pubsub.Subscriber                          // 订阅者
 	sessionTokenCache *server.SessionTokenCache                  // 缓存 sessionToken 对应的用户 id
 }
@@ -188,6 +235,11 @@ type liveQueryServer struct {
 // masterKey talisman 对应的 masterKey
 // subType 订阅服务类型，支持 EventEmitter Redis
 // subURL 订阅服务地址，如果是 EventEmitter 可不填写
+// pingInterval 向客户端发送 ping 帧的间隔，单位为秒，默认为 30 ， 0 表示不发送
+// pingTimeout 未收到客户端消息时断开连接的时间，单位为秒，默认为 0 不限制
+// maxConnections 最大连接数，默认为 0 不限制
+// resumeWindow 客户端断开之后保留订阅的时间，单位为秒，默认为 30 ， 0 表示不保留
+// enableSSE 是否开启 SSE 接口，地址为 pattern/events ，默认为 false
 func Run(args map[string]string) {
 	s = &liveQueryServer{}
 	s.initServer(args)
@@ -202,6 +254,7 @@ func (l *liveQueryServer) initServer(args map[string]string) {
 	l.clientID = 1
 	l.clients = map[int]*server.Client{}
 	l.subscriptions = map[string]map[string]*server.Subscription{}
+	l.sessions = map[string]*server.Client{}
 
 	// 设置日志级别
 	if level, ok := args["logLevel"]; ok {
@@ -223,6 +276,16 @@ func (l *liveQueryServer) initServer(args map[string]string) {
 	}
 	utils.TLog.Verbose("Support key pairs", l.keyPairs)
 
+	// 设置连接保持、连接数限制与 SSE 接口
+	l.resumeWindow = time.Duration(intArg(args, "resumeWindow", 30)) * time.Second
+	server.SetWebSocketOptions(server.WebSocketOptions{
+		PingInterval:   time.Duration(intArg(args, "pingInterval", 30)) * time.Second,
+		PingTimeout:    time.Duration(intArg(args, "pingTimeout", 0)) * time.Second,
+		MaxConnections: intArg(args, "maxConnections", 0),
+		EnableSSE:      args["enableSSE"] == "true",
+		ResumeWindow:   l.resumeWindow,
+	})
+
 	// 初始化 talisman 服务参数，用于获取用户信息
 	server.TalismanInfo["serverURL"] = args["serverURL"]
 	server.TalismanInfo["appId"] = args["appId"]
@@ -314,6 +377,10 @@ func (l *liveQueryServer) OnMessage(ws *server.WebSocket, msg interface{}) {
 		l.handleUpdateSubscription(ws, request)
 	case "unsubscribe":
 		l.handleUnsubscribe(ws, request, true)
+	case "resubscribe":
+		l.handleResubscribe(ws, request)
+	case "ping":
+		server.PushPong(ws)
 	default:
 		server.PushError(ws, 3, "Get unknown operation", true)
 		utils.TLog.Error("Get unknown operation", op)
@@ -333,7 +400,51 @@ func (l *liveQueryServer) OnDisconnect(ws *server.WebSocket) {
 	}
 
 	client := l.clients[clientID]
+	// 客户端已经恢复到新的连接上，旧连接断开时不做处理
+	if client.IsAttachedTo(ws) == false {
+		return
+	}
+	// 保留有订阅的客户端，等待客户端恢复，超时之后再删除
+	if l.resumeWindow > 0 && client.SessionID != "" && len(client.SubscriptionInfos) > 0 {
+		detachedAt := client.Detach()
+		time.AfterFunc(l.resumeWindow, func() {
+			l.expireClient(clientID, detachedAt)
+		})
+		utils.TLog.Verbose("Client", clientID, "detached, waiting for resubscribe")
+		return
+	}
+	l.removeClient(clientID)
+
+	utils.TLog.Verbose("Current clients", len(l.clients))
+	utils.TLog.Verbose("Current subscriptions", len(l.subscriptions))
+}
+
+// expireClient 客户端断开超过保留时间之后，删除客户端与订阅
+func (l *liveQueryServer) expireClient(clientID int, detachedAt time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	client := l.clients[clientID]
+	if client == nil {
+		return
+	}
+	// 客户端已经恢复，或者恢复之后再次断开
+	if at, detached := client.DetachedAt(); detached == false || at.Equal(detachedAt) == false {
+		return
+	}
+	utils.TLog.Log("Client", clientID, "resume window expired")
+	l.removeClient(clientID)
+}
+
+// removeClient 删除客户端及其所有订阅，调用方需持有锁
+func (l *liveQueryServer) removeClient(clientID int) {
+	client := l.clients[clientID]
+	if client == nil {
+		return
+	}
 	delete(l.clients, clientID)
+	if client.SessionID != "" {
+		delete(l.sessions, client.SessionID)
+	}
 
 	for requestID, subscriptionInfo := range client.SubscriptionInfos {
 		subscription := subscriptionInfo.Subscription
@@ -348,9 +459,6 @@ func (l *liveQueryServer) OnDisconnect(ws *server.WebSocket) {
 			delete(l.subscriptions, subscription.ClassName)
 		}
 	}
-
-	utils.TLog.Verbose("Current clients", len(l.clients))
-	utils.TLog.Verbose("Current subscriptions", len(l.subscriptions))
 }
 
 // inflateParseObject 展开对象
@@ -496,15 +604,67 @@ func (l *liveQueryServer) handleConnect(ws *server.WebSocket, request t.M) {
 		return
 	}
 
-	// 创建新的 client 并更新 l.clientID
+	l.mutex.Lock()
+	client := l.newClient(ws)
+	l.mutex.Unlock()
+	client.PushConnect(0, nil, nil)
+}
+
+// newClient 创建新的 client 并更新 l.clientID ，调用方需持有锁
+func (l *liveQueryServer) newClient(ws *server.WebSocket) *server.Client {
 	client := server.NewClient(l.clientID, ws)
 	ws.ClientID = l.clientID
 	l.clientID++
-	l.mutex.Lock()
 	l.clients[ws.ClientID] = client
-	l.mutex.Unlock()
+	if l.resumeWindow > 0 {
+		client.SessionID = newSessionID()
+		l.sessions[client.SessionID] = client
+	}
 	utils.TLog.Log("Create new client:", ws.ClientID)
-	client.PushConnect(0, nil, nil)
+	return client
+}
+
+// handleResubscribe 处理客户端 Resubscribe 操作
+// 优先恢复 sessionId 对应的客户端，无法恢复时创建新的客户端并重新订阅
+func (l *liveQueryServer) handleResubscribe(ws *server.WebSocket, request t.M) {
+	if l.validateKeys(request, l.keyPairs) == false {
+		server.PushError(ws, 4, "Key in request is not valid", true)
+		utils.TLog.Error("Key in request is not valid")
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if ws.ClientID != 0 {
+		server.PushError(ws, 1, "Client is already connected, resubscribe must be the first message", true)
+		utils.TLog.Error("Client", ws.ClientID, "is already connected")
+		return
+	}
+
+	if sessionID, ok := request["sessionId"].(string); ok && sessionID != "" {
+		if client := l.sessions[sessionID]; client != nil {
+			if old, ok := client.Attach(ws); ok {
+				// 客户端在服务端发现断开之前重连，关闭旧连接
+				if old != nil && old != ws {
+					old.Close()
+				}
+				utils.TLog.Log("Client", ws.ClientID, "resumed")
+				return
+			}
+			// 断开期间缓存的消息过多，无法恢复
+			l.removeClient(client.ID())
+		}
+	}
+
+	client := l.newClient(ws)
+	client.PushResubscribed(false)
+	if subscriptions, ok := request["subscriptions"].([]interface{}); ok {
+		for _, v := range subscriptions {
+			// 格式已经过校验
+			subscription := v.(map[string]interface{})
+			l.subscribe(client, subscription)
+		}
+	}
 }
 
 // handleSubscribe 处理客户端 Subscribe 操作
@@ -524,6 +684,11 @@ func (l *liveQueryServer) handleSubscribe(ws *server.WebSocket, request t.M) {
 		return
 	}
 
+	l.subscribe(client, request)
+}
+
+// subscribe 为客户端添加订阅，调用方需持有锁
+func (l *liveQueryServer) subscribe(client *server.Client, request t.M) {
 	query := request["query"].(map[string]interface{})
 	// 计算 query 的 hash ，参与计算的字段包括： className 与 where
 	subscriptionHash := utils.QueryHash(query)
@@ -562,11 +727,11 @@ func (l *liveQueryServer) handleSubscribe(ws *server.WebSocket, request t.M) {
 	// 根据 requestID ，把订阅信息对象设置到 client 中
 	client.AddSubscriptionInfo(requestID, subscriptionInfo)
 	// 更新订阅对象，添加使用该对象的 ClientID 与 requestID
-	subscription.AddClientSubscription(ws.ClientID, requestID)
+	subscription.AddClientSubscription(client.ID(), requestID)
 	// 订阅成功
 	client.PushSubscribe(requestID, nil, nil)
 
-	utils.TLog.Verbose("Create client", ws.ClientID, "new subscription:", requestID)
+	utils.TLog.Verbose("Create client", client.ID(), "new subscription:", requestID)
 	utils.TLog.Verbose("Current client number:", len(l.clients))
 }
 
@@ -705,6 +870,63 @@ func (l *liveQueryServer) validateKeys(request t.M, validKeyPairs map[string]str
 	return isValid
 }
 
+// Metrics LiveQuery 服务运行指标
+type Metrics struct {
+	Connections         int // 当前的 WebSocket 连接数
+	Clients             int // 客户端数，包含等待恢复的客户端
+	DetachedClients     int // 断开连接等待恢复的客户端数
+	Subscriptions       int // 订阅对象数，className 与查询条件相同的订阅共用一个订阅对象
+	ClientSubscriptions int // 客户端发起的订阅总数
+}
+
+// GetMetrics 获取 LiveQuery 服务运行指标，服务未启动时返回空指标
+func GetMetrics() Metrics {
+	if s == nil {
+		return Metrics{}
+	}
+	return s.metrics()
+}
+
+func (l *liveQueryServer) metrics() Metrics {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	m := Metrics{
+		Connections: server.ConnectionCount(),
+		Clients:     len(l.clients),
+	}
+	for _, client := range l.clients {
+		if _, detached := client.DetachedAt(); detached {
+			m.DetachedClients++
+		}
+		m.ClientSubscriptions += len(client.SubscriptionInfos)
+	}
+	for _, classSubscriptions := range l.subscriptions {
+		m.Subscriptions += len(classSubscriptions)
+	}
+	return m
+}
+
+// intArg 从启动参数中读取整数，未设置或者格式错误时返回默认值
+func intArg(args map[string]string, key string, defaultValue int) int {
+	v, ok := args[key]
+	if ok == false || v == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		utils.TLog.Error("Invalid", key, v)
+		return defaultValue
+	}
+	return i
+}
+
+// newSessionID 生成用于恢复订阅的随机 sessionId
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 func getPublicReadAccess(acl t.M) bool {
 	return getReadAccess(acl, "*")
 }
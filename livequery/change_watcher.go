@@ -0,0 +1,72 @@
+package livequery
+
+import (
+	"time"
+
+	"github.com/okobsamoht/talisman/cache"
+	"github.com/okobsamoht/talisman/livequery/t"
+	"github.com/okobsamoht/talisman/logger"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/storage"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// changeTokenKey 最后处理的变更的 Token 在缓存中的 key
+const changeTokenKey = "changeToken"
+
+// maxWatchBackoff 读取变更出错时重新连接的最大间隔
+const maxWatchBackoff = 30 * time.Second
+
+var watchStop chan struct{}
+
+// WatchDatabase 从数据库的对象变更中生成 LiveQuery 事件，用于 LiveQueryEventSource=Database
+// 最后处理的变更保存在缓存中，重启之后从该位置继续读取，读取出错时等待一段时间后重新连接
+func WatchDatabase(watcher storage.ChangeWatcher) {
+	StopWatchDatabase()
+	stop := make(chan struct{})
+	watchStop = stop
+	go func() {
+		backoff := time.Second
+		for {
+			token := utils.S(cache.LiveQuery.Get(changeTokenKey))
+			err := watcher.WatchChanges(TLiveQuery.classPrefix, token, onDatabaseChange, stop)
+			if err == nil {
+				return
+			}
+			logger.Error("Watch database changes failed:", err)
+			select {
+			case <-stop:
+				return
+			case <-time.After(backoff):
+			}
+			if backoff < maxWatchBackoff {
+				backoff *= 2
+			}
+		}
+	}()
+}
+
+// StopWatchDatabase 停止读取数据库的对象变更
+func StopWatchDatabase() {
+	if watchStop != nil {
+		close(watchStop)
+		watchStop = nil
+	}
+}
+
+// onDatabaseChange 发布对象变更，数据库中没有变更前的对象，更新时以当前对象作为原对象
+func onDatabaseChange(change *storage.Change) {
+	l := TLiveQuery
+	if l != nil && l.HasLiveQuery(change.ClassName) {
+		object := t.M(orm.SanitizeObject(change.ClassName, change.Object, false, ""))
+		object["className"] = change.ClassName
+		if change.Deleted {
+			l.liveQueryPublisher.OnCloudCodeAfterDelete(l.makePublisherRequest(object, nil))
+		} else if change.Created {
+			l.liveQueryPublisher.OnCloudCodeAfterSave(l.makePublisherRequest(object, nil))
+		} else {
+			l.liveQueryPublisher.OnCloudCodeAfterSave(l.makePublisherRequest(object, utils.CopyMap(object)))
+		}
+	}
+	cache.LiveQuery.Put(changeTokenKey, change.Token, -1)
+}
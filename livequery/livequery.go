@@ -11,6 +11,8 @@ import (
 // LiveQuery 接收指定类的对象保存与对象删除的通知，发送对象数据到发布者，由发布者通知订阅者，订阅者实时接收数据
 type LiveQuery struct {
 	classNames         map[string]bool
+	classPrefix        string
+	fromDatabase       bool
 	liveQueryPublisher *pubsub.CloudCodePublisher
 }
 
@@ -18,6 +20,11 @@ type LiveQuery struct {
 var TLiveQuery *LiveQuery
 
 func init() {
+	Init()
+}
+
+// Init 根据当前配置创建 LiveQuery 事件发布模块
+func Init() {
 	classNames := strings.Split(config.TConfig.LiveQueryClasses, "|")
 	pubType := config.TConfig.PublisherType
 	pubURL := config.TConfig.PublisherURL
@@ -37,31 +44,36 @@ func NewLiveQuery(classNames []string, pubType, pubURL, pubConfig string) *LiveQ
 			liveQuery.classNames[n] = true
 		}
 	}
-	liveQuery.liveQueryPublisher = pubsub.NewCloudCodePublisher(pubType, pubURL, pubConfig)
+	liveQuery.classPrefix = config.TConfig.LiveQueryClassPrefix
+	liveQuery.fromDatabase = config.TConfig.LiveQueryEventSource == "Database"
+	liveQuery.liveQueryPublisher = pubsub.NewCloudCodePublisher(config.TConfig.AppID, pubType, pubURL, pubConfig)
 
 	return liveQuery
 }
 
-// OnAfterSave 保存对象之后调用
+// OnAfterSave 保存对象之后调用，事件来源为数据库时忽略
 func (l *LiveQuery) OnAfterSave(className string, currentObject, originalObject map[string]interface{}) {
-	if l.HasLiveQuery(className) == false {
+	if l.fromDatabase || l.HasLiveQuery(className) == false {
 		return
 	}
 	req := l.makePublisherRequest(currentObject, originalObject)
 	l.liveQueryPublisher.OnCloudCodeAfterSave(req)
 }
 
-// OnAfterDelete 删除对象之后调用
+// OnAfterDelete 删除对象之后调用，事件来源为数据库时忽略
 func (l *LiveQuery) OnAfterDelete(className string, currentObject, originalObject map[string]interface{}) {
-	if l.HasLiveQuery(className) == false {
+	if l.fromDatabase || l.HasLiveQuery(className) == false {
 		return
 	}
 	req := l.makePublisherRequest(currentObject, originalObject)
 	l.liveQueryPublisher.OnCloudCodeAfterDelete(req)
 }
 
-// HasLiveQuery 是否有对应的 className
+// HasLiveQuery 是否有对应的 className ，事件来源为数据库时，类名以 LiveQueryClassPrefix 开头的类同样支持
 func (l *LiveQuery) HasLiveQuery(className string) bool {
+	if l.fromDatabase && l.classPrefix != "" && strings.HasPrefix(className, l.classPrefix) {
+		return true
+	}
 	return l.classNames[className]
 }
 
@@ -9,24 +9,36 @@ import (
 
 // CloudCodePublisher 云代码发布者，当前支持发布 afterSave 与 afterDelete 通知
 type CloudCodePublisher struct {
+	appID     string
 	publisher Publisher
 }
 
-// NewCloudCodePublisher 创建云代码发布者，其中的 Publisher 当前仅支持实验性质的 EventEmitter
-func NewCloudCodePublisher(pubType, pubURL, pubConfig string) *CloudCodePublisher {
+// NewCloudCodePublisher 创建云代码发布者
+// appID 作为通道名称的前缀，需要与 LiveQuery 服务的 appId 一致，
+// 这样在未运行 LiveQuery 服务的实例上产生的事件，也能通过 Redis 等传输方式发送到其他实例
+func NewCloudCodePublisher(appID, pubType, pubURL, pubConfig string) *CloudCodePublisher {
 	return &CloudCodePublisher{
+		appID:     appID,
 		publisher: CreatePublisher(pubType, pubURL, pubConfig),
 	}
 }
 
+// channelPrefix 通道名称前缀，未指定 appID 时使用 LiveQuery 服务的 appId
+func (c *CloudCodePublisher) channelPrefix() string {
+	if c.appID != "" {
+		return c.appID
+	}
+	return server.TalismanInfo["appId"]
+}
+
 // OnCloudCodeAfterSave 对象保存时调用，request 中包含修改前与修改后的数据
 func (c *CloudCodePublisher) OnCloudCodeAfterSave(request t.M) {
-	c.onCloudCodeMessage(server.TalismanInfo["appId"]+"afterSave", request)
+	c.onCloudCodeMessage(c.channelPrefix()+"afterSave", request)
 }
 
 // OnCloudCodeAfterDelete 对象删除时调用，request 中包含要删除的数据
 func (c *CloudCodePublisher) OnCloudCodeAfterDelete(request t.M) {
-	c.onCloudCodeMessage(server.TalismanInfo["appId"]+"afterDelete", request)
+	c.onCloudCodeMessage(c.channelPrefix()+"afterDelete", request)
 }
 
 // onCloudCodeMessage 向发送者发送通知消息
@@ -1,18 +1,66 @@
 package pubsub
 
-// CreatePublisher 创建发布者，当前支持 EventEmitter 、 Redis
+import "sync"
+
+// PublisherFactory 创建发布者， url 与 config 来自 PublisherURL 与 PublisherConfig
+type PublisherFactory func(pubURL, pubConfig string) Publisher
+
+// SubscriberFactory 创建订阅者
+type SubscriberFactory func(subURL, subConfig string) Subscriber
+
+type transport struct {
+	publisher  PublisherFactory
+	subscriber SubscriberFactory
+}
+
+var (
+	transportsMutex sync.RWMutex
+	transports      = map[string]transport{}
+)
+
+// RegisterTransport 注册自定义的消息传输方式，注册之后可以通过 PublisherType 使用
+// 多个 talisman 实例之间需要使用同一个传输方式，才能把一个实例上产生的事件发送到其他实例的订阅者
+func RegisterTransport(name string, publisher PublisherFactory, subscriber SubscriberFactory) {
+	transportsMutex.Lock()
+	defer transportsMutex.Unlock()
+	transports[name] = transport{
+		publisher:  publisher,
+		subscriber: subscriber,
+	}
+}
+
+func getTransport(name string) (transport, bool) {
+	transportsMutex.RLock()
+	defer transportsMutex.RUnlock()
+	t, ok := transports[name]
+	return t, ok
+}
+
+// CreatePublisher 创建发布者，当前支持 EventEmitter 、 Redis 以及通过 RegisterTransport 注册的类型
 func CreatePublisher(pubType, pubURL, pubConfig string) Publisher {
+	if t, ok := getTransport(pubType); ok {
+		return t.publisher(pubURL, pubConfig)
+	}
 	if useRedis(pubType) {
 		return createRedisPublisher(pubURL, pubConfig)
 	}
+	if useEventEmitter(pubType) == false {
+		panic("unsupported PublisherType: " + pubType)
+	}
 	return createEventEmitterPublisher()
 }
 
-// CreateSubscriber 创建订阅者，当前支持 EventEmitter 、 Redis
+// CreateSubscriber 创建订阅者，当前支持 EventEmitter 、 Redis 以及通过 RegisterTransport 注册的类型
 func CreateSubscriber(subType, subURL, subConfig string) Subscriber {
+	if t, ok := getTransport(subType); ok {
+		return t.subscriber(subURL, subConfig)
+	}
 	if useRedis(subType) {
 		return createRedisSubscriber(subURL, subConfig)
 	}
+	if useEventEmitter(subType) == false {
+		panic("unsupported PublisherType: " + subType)
+	}
 	return createEventEmitterSubscriber()
 }
 
@@ -24,6 +72,11 @@ func useRedis(pubType string) bool {
 	return false
 }
 
+// useEventEmitter 判断类型是否为 EventEmitter ，未设置时默认使用 EventEmitter
+func useEventEmitter(pubType string) bool {
+	return pubType == "" || pubType == "EventEmitter"
+}
+
 // HandlerType ...
 type HandlerType func(args ...string)
 
@@ -0,0 +1,38 @@
+package pubsub
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_RegisterTransport(t *testing.T) {
+	RegisterTransport("Test",
+		func(pubURL, pubConfig string) Publisher {
+			return createEventEmitterPublisher()
+		},
+		func(subURL, subConfig string) Subscriber {
+			return createEventEmitterSubscriber()
+		},
+	)
+	sub := CreateSubscriber("Test", "", "")
+	pub := CreatePublisher("Test", "", "")
+	var mutex sync.Mutex
+	var result []string
+	sub.Subscribe("transport")
+	sub.On("message", func(args ...string) {
+		mutex.Lock()
+		result = args
+		mutex.Unlock()
+	})
+	pub.Publish("transport", "hello")
+	time.Sleep(100 * time.Millisecond)
+	sub.Unsubscribe("transport")
+	expect := []string{"transport", "hello"}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
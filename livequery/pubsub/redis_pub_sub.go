@@ -1,6 +1,7 @@
 package pubsub
 
 import (
+	"sync"
 	"time"
 
 	"github.com/garyburd/redigo/redis"
@@ -48,35 +49,94 @@ func (r *redisPublisher) do(commandName string, args ...interface{}) (reply inte
 }
 
 type redisSubscriber struct {
-	psc       redis.PubSubConn
+	address   string
+	password  string
+	mutex     sync.Mutex
+	psc       *redis.PubSubConn
+	channels  map[string]bool
 	listeners []HandlerType
 }
 
 func (r *redisSubscriber) Subscribe(channel string) {
-	r.psc.Subscribe(channel)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.channels[channel] = true
+	if r.psc != nil {
+		r.psc.Subscribe(channel)
+	}
 }
 
 func (r *redisSubscriber) Unsubscribe(channel string) {
-	r.psc.Unsubscribe(channel)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.channels, channel)
+	if r.psc != nil {
+		r.psc.Unsubscribe(channel)
+	}
 }
 
 func (r *redisSubscriber) On(channel string, listener HandlerType) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 	r.listeners = append(r.listeners, listener)
 }
 
-func (r *redisSubscriber) receive() {
+// receive 接收订阅的消息，连接断开后重新连接并恢复所有订阅
+func (r *redisSubscriber) receive(psc *redis.PubSubConn) {
 	go func() {
 		for {
-			switch n := r.psc.Receive().(type) {
-			case redis.Message:
-				for _, listener := range r.listeners {
-					go listener(n.Channel, string(n.Data))
+		receiving:
+			for {
+				switch n := psc.Receive().(type) {
+				case redis.Message:
+					r.mutex.Lock()
+					listeners := r.listeners
+					r.mutex.Unlock()
+					for _, listener := range listeners {
+						go listener(n.Channel, string(n.Data))
+					}
+				case error:
+					break receiving
+				}
+			}
+			psc.Close()
+			r.mutex.Lock()
+			r.psc = nil
+			r.mutex.Unlock()
+
+			for {
+				time.Sleep(time.Second)
+				c, err := dialRedis(r.address, r.password)
+				if err != nil {
+					continue
+				}
+				psc = &redis.PubSubConn{Conn: c}
+				r.mutex.Lock()
+				r.psc = psc
+				for channel := range r.channels {
+					psc.Subscribe(channel)
 				}
+				r.mutex.Unlock()
+				break
 			}
 		}
 	}()
 }
 
+func dialRedis(address, password string) (redis.Conn, error) {
+	c, err := redis.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if password != "" {
+		if _, err := c.Do("AUTH", password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
 func createRedisPublisher(address, password string) *redisPublisher {
 	m := &redisPublisher{
 		address:  address,
@@ -93,20 +153,18 @@ func createRedisPublisher(address, password string) *redisPublisher {
 }
 
 func createRedisSubscriber(address, password string) *redisSubscriber {
-	c, err := redis.Dial("tcp", address)
+	c, err := dialRedis(address, password)
 	if err != nil {
 		panic(err)
 	}
-	if password != "" {
-		if _, err := c.Do("AUTH", password); err != nil {
-			c.Close()
-			panic(err)
-		}
-	}
+	psc := &redis.PubSubConn{Conn: c}
 	r := &redisSubscriber{
-		psc:       redis.PubSubConn{Conn: c},
+		address:   address,
+		password:  password,
+		psc:       psc,
+		channels:  map[string]bool{},
 		listeners: []HandlerType{},
 	}
-	r.receive()
+	r.receive(psc)
 	return r
 }
@@ -22,6 +22,10 @@ func Validate(data t.M, op string) error {
 		return validateUnsubscribe(data)
 	case "update":
 		return validateUpdate(data)
+	case "resubscribe":
+		return validateResubscribe(data)
+	case "ping":
+		return nil
 	default:
 		return errors.New("invalid op")
 	}
@@ -31,8 +35,10 @@ func Validate(data t.M, op string) error {
 func validateGeneral(data t.M) error {
 	if v, ok := data["op"]; ok {
 		if op, ok := v.(string); ok {
-			if op != "connect" && op != "subscribe" && op != "unsubscribe" && op != "update" {
-				return errors.New("op is not in [connect, subscribe, unsubscribe, update]")
+			switch op {
+			case "connect", "subscribe", "unsubscribe", "update", "resubscribe", "ping":
+			default:
+				return errors.New("op is not in [connect, subscribe, unsubscribe, update, resubscribe, ping]")
 			}
 			return nil
 		}
@@ -142,6 +148,40 @@ func validateUpdate(data t.M) error {
 	return validateSubscribe(data)
 }
 
+// validateResubscribe 校验 resubscribe 请求格式
+// 除 connect 的字段外，可以包含上次连接返回的 sessionId ，以及需要恢复的订阅列表
+func validateResubscribe(data t.M) error {
+	err := validateConnect(data)
+	if err != nil {
+		return err
+	}
+
+	if v, ok := data["sessionId"]; ok {
+		if _, ok := v.(string); ok == false {
+			return errors.New("sessionId is not string")
+		}
+	}
+
+	if v, ok := data["subscriptions"]; ok {
+		subscriptions, ok := v.([]interface{})
+		if ok == false {
+			return errors.New("subscriptions is not array")
+		}
+		for _, s := range subscriptions {
+			subscription, ok := s.(map[string]interface{})
+			if ok == false {
+				return errors.New("subscription is not object")
+			}
+			err := validateSubscribe(subscription)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 // validateQuery 校验 query 字段
 func validateQuery(data t.M) error {
 	// 必须包含 className 字段
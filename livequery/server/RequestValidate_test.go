@@ -30,7 +30,7 @@ func Test_validateGeneral(t *testing.T) {
 		{
 			name:    "3",
 			args:    args{data: tp.M{"op": "hello"}},
-			wantErr: errors.New("op is not in [connect, subscribe, unsubscribe, update]"),
+			wantErr: errors.New("op is not in [connect, subscribe, unsubscribe, update, resubscribe, ping]"),
 		},
 		{
 			name:    "4",
@@ -52,6 +52,16 @@ func Test_validateGeneral(t *testing.T) {
 			args:    args{data: tp.M{"op": "update"}},
 			wantErr: nil,
 		},
+		{
+			name:    "8",
+			args:    args{data: tp.M{"op": "resubscribe"}},
+			wantErr: nil,
+		},
+		{
+			name:    "9",
+			args:    args{data: tp.M{"op": "ping"}},
+			wantErr: nil,
+		},
 	}
 	for _, tt := range tests {
 		if err := validateGeneral(tt.args.data); reflect.DeepEqual(err, tt.wantErr) == false {
@@ -259,6 +269,72 @@ func Test_validateUnsubscribe(t *testing.T) {
 	}
 }
 
+func Test_validateResubscribe(t *testing.T) {
+	type args struct {
+		data tp.M
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr error
+	}{
+		{
+			name:    "1",
+			args:    args{data: tp.M{}},
+			wantErr: nil,
+		},
+		{
+			name:    "2",
+			args:    args{data: tp.M{"masterKey": 1024}},
+			wantErr: errors.New("masterKey is not string"),
+		},
+		{
+			name:    "3",
+			args:    args{data: tp.M{"sessionId": 1024}},
+			wantErr: errors.New("sessionId is not string"),
+		},
+		{
+			name:    "4",
+			args:    args{data: tp.M{"subscriptions": "hello"}},
+			wantErr: errors.New("subscriptions is not array"),
+		},
+		{
+			name:    "5",
+			args:    args{data: tp.M{"subscriptions": []interface{}{"hello"}}},
+			wantErr: errors.New("subscription is not object"),
+		},
+		{
+			name: "6",
+			args: args{data: tp.M{"subscriptions": []interface{}{
+				map[string]interface{}{"requestId": 1024.0},
+			}}},
+			wantErr: errors.New("need query"),
+		},
+		{
+			name: "7",
+			args: args{data: tp.M{
+				"sessionId": "abc",
+				"subscriptions": []interface{}{
+					map[string]interface{}{
+						"requestId": 1024.0,
+						"query": map[string]interface{}{
+							"className": "post",
+							"where":     map[string]interface{}{},
+						},
+						"sessionToken": "1024",
+					},
+				},
+			}},
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		if err := validateResubscribe(tt.args.data); reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. validateResubscribe() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
 func Test_validateQuery(t *testing.T) {
 	type args struct {
 		data tp.M
@@ -3,6 +3,9 @@ package server
 import (
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/astaxie/beego"
 	"golang.org/x/net/websocket"
@@ -17,6 +20,33 @@ type WebSocketHandler interface {
 
 var handler WebSocketHandler
 
+// WebSocketOptions WebSocket 连接参数
+type WebSocketOptions struct {
+	PingInterval   time.Duration // 向客户端发送 ping 帧的间隔，用于保持连接， 0 表示不发送
+	PingTimeout    time.Duration // 在该时间内未收到客户端任何消息则断开连接，客户端可发送 ping 操作保持连接， 0 表示不限制
+	MaxConnections int           // 最大连接数，包含 SSE 连接， 0 表示不限制
+	EnableSSE      bool          // 是否开启 SSE 接口，用于无法使用 WebSocket 的环境
+	ResumeWindow   time.Duration // SSE 连接断开之后保留会话的时间， 0 表示不保留
+}
+
+var options WebSocketOptions
+
+// connections 当前的连接数
+var connections int64
+
+// writeTimeout 发送 ping 帧的超时时间，超时则认为连接已失效
+const writeTimeout = 10 * time.Second
+
+// SetWebSocketOptions 设置 WebSocket 连接参数，需要在 RunWebSocketServer 之前调用
+func SetWebSocketOptions(opts WebSocketOptions) {
+	options = opts
+}
+
+// ConnectionCount 返回当前的连接数
+func ConnectionCount() int {
+	return int(atomic.LoadInt64(&connections))
+}
+
 // RunWebSocketServer ...
 func RunWebSocketServer(pattern, addr string, h WebSocketHandler) {
 	handler = h
@@ -25,6 +55,7 @@ func RunWebSocketServer(pattern, addr string, h WebSocketHandler) {
 			s := websocket.Server{Handler: websocket.Handler(httpHandler)}
 			s.ServeHTTP(w, req)
 		})
+	sseHandlerFunc := http.HandlerFunc(sseHandler)
 	// 如果未设置监听地址，则与 beego 共用
 	if addr == "" {
 		// http://127.0.0.1:8080/v1 ==>> pattern = /v1
@@ -39,10 +70,19 @@ func RunWebSocketServer(pattern, addr string, h WebSocketHandler) {
 			panic("RunWebSocketServer: invalid serverURL: " + serverURL)
 		}
 		pattern = serverURL[i:]
+		if options.EnableSSE {
+			ssePath = strings.TrimSuffix(pattern, "/") + "/events"
+			beego.Handler(ssePath, sseHandlerFunc, true)
+		}
 		beego.Handler(pattern, handlerFunc)
 		return
 	}
 	// 如果设置了地址，则开启新服务去处理 WebSocket
+	if options.EnableSSE {
+		ssePath = strings.TrimSuffix(pattern, "/") + "/events"
+		http.Handle(ssePath, sseHandlerFunc)
+		http.Handle(ssePath+"/", sseHandlerFunc)
+	}
 	http.Handle(pattern, handlerFunc)
 	err := http.ListenAndServe(addr, nil)
 	if err != nil {
@@ -55,9 +95,26 @@ func httpHandler(ws *websocket.Conn) {
 		ws:       ws,
 		ClientID: 0,
 	}
+	n := atomic.AddInt64(&connections, 1)
+	defer atomic.AddInt64(&connections, -1)
+	// 超过最大连接数时，返回错误并断开，客户端可稍后重连
+	if options.MaxConnections > 0 && n > int64(options.MaxConnections) {
+		socket.send(errorMessage(5, "Too many connections", true))
+		socket.Close()
+		return
+	}
+
 	handler.OnConnect(socket)
+	if options.PingInterval > 0 {
+		stop := make(chan struct{})
+		defer close(stop)
+		go socket.keepAlive(options.PingInterval, stop)
+	}
 	var v string
 	for {
+		if options.PingTimeout > 0 {
+			ws.SetReadDeadline(time.Now().Add(options.PingTimeout))
+		}
 		err := socket.receive(&v)
 		if err != nil {
 			handler.OnDisconnect(socket)
@@ -67,9 +124,11 @@ func httpHandler(ws *websocket.Conn) {
 	}
 }
 
-// WebSocket ...
+// WebSocket 客户端连接， ws 与 sse 二者之一不为空
 type WebSocket struct {
 	ws       *websocket.Conn
+	sse      *sseSession
+	mutex    sync.Mutex // 保证同一时间只有一个协程写入
 	ClientID int
 }
 
@@ -78,5 +137,50 @@ func (w *WebSocket) receive(v interface{}) error {
 }
 
 func (w *WebSocket) send(v interface{}) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.sse != nil {
+		msg, _ := v.(string)
+		return w.sse.send(msg)
+	}
 	return websocket.Message.Send(w.ws, v)
 }
+
+// ping 发送 ping 帧，客户端会自动回复 pong 帧
+func (w *WebSocket) ping() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+	defer w.ws.SetWriteDeadline(time.Time{})
+	w.ws.PayloadType = websocket.PingFrame
+	defer func() { w.ws.PayloadType = websocket.TextFrame }()
+	_, err := w.ws.Write([]byte{})
+	return err
+}
+
+// keepAlive 定时发送 ping 帧，发送失败时关闭连接，由接收循环处理断开
+func (w *WebSocket) keepAlive(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := w.ping(); err != nil {
+				w.Close()
+				return
+			}
+		}
+	}
+}
+
+// Close 关闭连接
+func (w *WebSocket) Close() error {
+	if w.sse != nil {
+		// close 中会调用 OnDisconnect ，调用方可能持有锁，所以异步关闭
+		go w.sse.close()
+		return nil
+	}
+	return w.ws.Close()
+}
@@ -2,6 +2,8 @@ package server
 
 import (
 	"encoding/json"
+	"sync"
+	"time"
 
 	"reflect"
 
@@ -10,12 +12,22 @@ import (
 
 var dafaultFields = []string{"className", "objectId", "updatedAt", "createdAt", "ACL"}
 
+// maxPendingEvents 客户端断开期间最多缓存的消息数，超过后不再支持恢复
+const maxPendingEvents = 1000
+
 // Client 客户端信息
-// ws 当前对象的 WebSocket 连接
+// ws 当前对象的 WebSocket 连接，客户端断开等待恢复时为 nil
+// SessionID 用于客户端重连之后恢复订阅
 // SubscriptionInfos 当前客户端发起的所有请求对应的订阅信息
 type Client struct {
 	id                int
+	mutex             sync.Mutex
 	ws                *WebSocket
+	pending           []string   // 断开期间缓存的消息
+	overflowed        bool       // 缓存的消息超过上限
+	flushing          *WebSocket // 正在发送缓存消息的连接
+	detachedAt        time.Time  // 断开的时间
+	SessionID         string
 	SubscriptionInfos map[int]*SubscriptionInfo
 	PushConnect       func(int, t.M, t.M)
 	PushSubscribe     func(int, t.M, t.M)
@@ -45,23 +57,140 @@ func NewClient(id int, ws *WebSocket) *Client {
 	return c
 }
 
+// ID 返回客户端 id
+func (c *Client) ID() int {
+	return c.id
+}
+
 func pushResponse(ws *WebSocket, msg string) {
 	go ws.send(msg)
 }
 
+// push 向客户端发送消息，客户端断开期间缓存消息，等待恢复后发送
+func (c *Client) push(msg string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.ws != nil && c.flushing == nil {
+		pushResponse(c.ws, msg)
+		return
+	}
+	if c.overflowed {
+		return
+	}
+	if c.ws == nil && len(c.pending) >= maxPendingEvents {
+		c.overflowed = true
+		c.pending = nil
+		return
+	}
+	c.pending = append(c.pending, msg)
+}
+
+// flush 按顺序发送缓存的消息，发送期间产生的新消息继续追加到缓存中
+// 客户端绑定到其他连接之后，由新连接的 flush 接替
+func (c *Client) flush(ws *WebSocket) {
+	for {
+		c.mutex.Lock()
+		if c.flushing != ws {
+			c.mutex.Unlock()
+			return
+		}
+		pending := c.pending
+		c.pending = nil
+		if len(pending) == 0 {
+			c.flushing = nil
+			c.mutex.Unlock()
+			return
+		}
+		c.mutex.Unlock()
+		for i, msg := range pending {
+			if err := ws.send(msg); err != nil {
+				// 发送失败时放回缓存，等待客户端恢复后重新发送
+				c.mutex.Lock()
+				c.pending = append(pending[i:], c.pending...)
+				c.mutex.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// Detach 客户端连接断开，之后的消息将被缓存
+func (c *Client) Detach() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.ws = nil
+	c.flushing = nil
+	c.detachedAt = time.Now()
+	return c.detachedAt
+}
+
+// DetachedAt 返回客户端断开的时间，如果客户端处于连接状态，返回 false
+func (c *Client) DetachedAt() (time.Time, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.detachedAt, c.ws == nil
+}
+
+// IsAttachedTo 客户端当前是否使用该连接
+func (c *Client) IsAttachedTo(ws *WebSocket) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.ws == ws
+}
+
+// Attach 把客户端绑定到新的连接上，先发送恢复成功的消息，再按顺序发送断开期间缓存的消息，返回之前的连接
+// 如果缓存的消息超过上限，则无法保证消息完整，返回 false
+func (c *Client) Attach(ws *WebSocket) (*WebSocket, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.overflowed {
+		return nil, false
+	}
+	old := c.ws
+	c.ws = ws
+	ws.ClientID = c.id
+	c.pending = append([]string{c.resubscribedMessage(true)}, c.pending...)
+	c.flushing = ws
+	go c.flush(ws)
+	return old, true
+}
+
+// PushResubscribed 发送恢复订阅的结果， resumed 表示是否从断开的会话中恢复
+func (c *Client) PushResubscribed(resumed bool) {
+	c.push(c.resubscribedMessage(resumed))
+}
+
+func (c *Client) resubscribedMessage(resumed bool) string {
+	response := t.M{
+		"op":        "resubscribed",
+		"clientId":  c.id,
+		"sessionId": c.SessionID,
+		"resumed":   resumed,
+	}
+	r, _ := json.Marshal(response)
+	return string(r)
+}
+
+// PushPong 回复客户端的 ping 操作
+func PushPong(ws *WebSocket) {
+	pushResponse(ws, `{"op":"pong"}`)
+}
+
 // PushError 发送错误信息
 func PushError(ws *WebSocket, code int, errMsg string, reconnect bool) {
+	pushResponse(ws, errorMessage(code, errMsg, reconnect))
+}
+
+// errorMessage 组装错误信息
+func errorMessage(code int, errMsg string, reconnect bool) string {
 	errResp := t.M{
 		"op":        "error",
 		"error":     errMsg,
 		"code":      code,
 		"reconnect": reconnect,
 	}
-	data, err := json.Marshal(errResp)
-	if err != nil {
-		return
-	}
-	pushResponse(ws, string(data))
+	data, _ := json.Marshal(errResp)
+	return string(data)
 }
 
 // AddSubscriptionInfo 添加 requestID 对应的订阅信息
@@ -86,6 +215,9 @@ func (c *Client) pushEvent(eventType string) func(int, t.M, t.M) {
 			"op":       eventType,
 			"clientId": c.id,
 		}
+		if eventType == "connected" && c.SessionID != "" {
+			response["sessionId"] = c.SessionID
+		}
 		if subscriptionId != 0 {
 			response["requestId"] = subscriptionId
 		}
@@ -104,7 +236,7 @@ func (c *Client) pushEvent(eventType string) func(int, t.M, t.M) {
 		if err != nil {
 			return
 		}
-		pushResponse(c.ws, string(r))
+		c.push(string(r))
 	}
 }
 
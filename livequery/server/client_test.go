@@ -245,3 +245,26 @@ func Test_transformUpdateOperators(t *testing.T) {
 		}
 	}
 }
+
+func Test_Client_push(t *testing.T) {
+	c := NewClient(1, nil)
+	c.Detach()
+	c.PushCreate(1, tp.M{"objectId": "1"}, nil)
+	c.PushDelete(1, tp.M{"objectId": "1"}, nil)
+	if len(c.pending) != 2 {
+		t.Errorf("pending = %v, want 2 messages", c.pending)
+	}
+	if at, detached := c.DetachedAt(); detached == false || at.IsZero() {
+		t.Errorf("DetachedAt() = %v, %v, want detached", at, detached)
+	}
+
+	for i := 0; i < maxPendingEvents; i++ {
+		c.push("{}")
+	}
+	if c.overflowed == false || len(c.pending) != 0 {
+		t.Errorf("overflowed = %v, pending = %v, want overflowed", c.overflowed, len(c.pending))
+	}
+	if _, ok := c.Attach(&WebSocket{}); ok {
+		t.Errorf("Attach() = %v, want false", ok)
+	}
+}
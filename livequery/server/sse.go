@@ -0,0 +1,349 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+SSE 接口，用于无法使用 WebSocket 的环境，消息格式与 WebSocket 相同
+
+建立连接：
+GET /livequery/events?applicationId=xxx&clientKey=xxx
+连接时的键值对通过 URL 参数传递，服务端据此发送 connect 请求。
+第一条消息返回 streamId ，之后的消息 id 格式为 streamId:序号
+data: {"op":"stream","streamId":"xxx"}
+
+发送请求：
+POST /livequery/events/{streamId}
+请求体与 WebSocket 的请求相同，如 subscribe unsubscribe update ，结果通过 SSE 连接返回
+
+断线重连：
+浏览器重连时会自动带上 Last-Event-ID 请求头，也可以通过 lastEventId 参数指定。
+如果会话仍在保留期内，且缓存中包含之后的全部消息，则补发这些消息并保留原有订阅；
+否则创建新的会话，客户端收到新的 streamId 之后需要重新订阅
+*/
+
+// maxSSEHistory 每个 SSE 会话缓存的已发送消息数，用于断线重连之后补发
+const maxSSEHistory = 1000
+
+// maxSSERequestSize POST 请求体的最大长度
+const maxSSERequestSize = 1 << 20
+
+// connectKeys 建立 SSE 连接时，从 URL 参数中读取的键值对
+var connectKeys = []string{"applicationId", "restAPIKey", "javascriptKey", "clientKey", "windowsKey", "masterKey", "sessionToken"}
+
+// ssePath SSE 接口的路径
+var ssePath string
+
+var (
+	sseMutex    sync.Mutex
+	sseSessions = map[string]*sseSession{}
+)
+
+// sseEvent 已发送的消息
+type sseEvent struct {
+	seq  int
+	data string
+}
+
+// sseSession SSE 会话，同一个会话在重连之后继续使用同一个 WebSocket 对象，
+// 因此 liveQueryServer 中的客户端与订阅不受重连影响
+type sseSession struct {
+	id         string
+	mutex      sync.Mutex
+	socket     *WebSocket
+	w          io.Writer
+	flusher    http.Flusher
+	done       chan struct{} // 当前连接被替换或者会话关闭时关闭
+	seq        int
+	history    []sseEvent
+	detachedAt time.Time
+	closed     bool
+}
+
+func newSSESession() *sseSession {
+	b := make([]byte, 16)
+	rand.Read(b)
+	s := &sseSession{id: hex.EncodeToString(b)}
+	s.socket = &WebSocket{sse: s}
+	sseMutex.Lock()
+	sseSessions[s.id] = s
+	sseMutex.Unlock()
+	return s
+}
+
+func getSSESession(id string) *sseSession {
+	sseMutex.Lock()
+	defer sseMutex.Unlock()
+	return sseSessions[id]
+}
+
+// resumeSSESession 根据 Last-Event-ID 查找可以恢复的会话，返回会话与最后收到的消息序号
+// 会话中缺少之后的消息时，关闭该会话并返回 nil
+func resumeSSESession(lastEventID string) (*sseSession, int) {
+	i := strings.LastIndex(lastEventID, ":")
+	if i < 0 {
+		return nil, -1
+	}
+	seq, err := strconv.Atoi(lastEventID[i+1:])
+	if err != nil || seq < 0 {
+		return nil, -1
+	}
+	s := getSSESession(lastEventID[:i])
+	if s == nil {
+		return nil, -1
+	}
+	s.mutex.Lock()
+	oldest := s.seq - len(s.history) + 1
+	ok := seq <= s.seq && seq+1 >= oldest
+	s.mutex.Unlock()
+	if ok == false {
+		s.close()
+		return nil, -1
+	}
+	return s, seq
+}
+
+// send 发送消息，同时记录到缓存中，连接断开期间只记录
+func (s *sseSession) send(msg string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return io.ErrClosedPipe
+	}
+	s.seq++
+	s.history = append(s.history, sseEvent{seq: s.seq, data: msg})
+	if len(s.history) > maxSSEHistory {
+		s.history = s.history[len(s.history)-maxSSEHistory:]
+	}
+	if s.w == nil {
+		return nil
+	}
+	return s.write(s.seq, msg)
+}
+
+// write 写入一条消息，调用方需持有锁
+func (s *sseSession) write(seq int, msg string) error {
+	_, err := io.WriteString(s.w, "id: "+s.id+":"+strconv.Itoa(seq)+"\ndata: "+msg+"\n\n")
+	if err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// ping 发送注释行，防止代理因连接空闲而断开
+func (s *sseSession) ping(done chan struct{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.done != done {
+		return nil
+	}
+	_, err := io.WriteString(s.w, ": ping\n\n")
+	if err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// attach 把会话绑定到新的连接上，并补发序号大于 lastSeq 的消息
+// lastSeq 小于 0 表示新会话，此时发送 streamId
+func (s *sseSession) attach(w io.Writer, flusher http.Flusher, lastSeq int) chan struct{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.done != nil {
+		close(s.done)
+	}
+	s.w = w
+	s.flusher = flusher
+	s.done = make(chan struct{})
+	if lastSeq < 0 {
+		data, _ := json.Marshal(map[string]string{"op": "stream", "streamId": s.id})
+		s.write(0, string(data))
+		return s.done
+	}
+	for _, e := range s.history {
+		if e.seq <= lastSeq {
+			continue
+		}
+		if s.write(e.seq, e.data) != nil {
+			break
+		}
+	}
+	return s.done
+}
+
+// detach 连接断开，在保留期内等待客户端重连
+func (s *sseSession) detach(done chan struct{}) {
+	s.mutex.Lock()
+	if s.done != done {
+		s.mutex.Unlock()
+		return
+	}
+	s.w = nil
+	s.flusher = nil
+	s.done = nil
+	s.detachedAt = time.Now()
+	detachedAt := s.detachedAt
+	s.mutex.Unlock()
+
+	if options.ResumeWindow <= 0 {
+		s.close()
+		return
+	}
+	time.AfterFunc(options.ResumeWindow, func() {
+		s.mutex.Lock()
+		expired := s.w == nil && s.detachedAt.Equal(detachedAt)
+		s.mutex.Unlock()
+		if expired {
+			s.close()
+		}
+	})
+}
+
+// close 关闭会话，通知 handler 客户端已断开
+func (s *sseSession) close() {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return
+	}
+	s.closed = true
+	if s.done != nil {
+		close(s.done)
+		s.done = nil
+	}
+	s.w = nil
+	s.flusher = nil
+	s.mutex.Unlock()
+
+	sseMutex.Lock()
+	delete(sseSessions, s.id)
+	sseMutex.Unlock()
+	handler.OnDisconnect(s.socket)
+}
+
+// sseHandler 处理 SSE 接口的请求
+func sseHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	streamID := strings.Trim(strings.TrimPrefix(req.URL.Path, ssePath), "/")
+	switch req.Method {
+	case "OPTIONS":
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Last-Event-ID")
+		w.WriteHeader(http.StatusNoContent)
+	case "GET":
+		if streamID != "" {
+			http.NotFound(w, req)
+			return
+		}
+		serveEvents(w, req)
+	case "POST":
+		postMessage(w, req, streamID)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// serveEvents 建立或者恢复 SSE 连接，直到客户端断开
+func serveEvents(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if ok == false {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	n := atomic.AddInt64(&connections, 1)
+	defer atomic.AddInt64(&connections, -1)
+	if options.MaxConnections > 0 && n > int64(options.MaxConnections) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, errorMessage(5, "Too many connections", true))
+		return
+	}
+
+	lastEventID := req.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = req.URL.Query().Get("lastEventId")
+	}
+	session, lastSeq := resumeSSESession(lastEventID)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var done chan struct{}
+	if session != nil {
+		done = session.attach(w, flusher, lastSeq)
+	} else {
+		session = newSSESession()
+		done = session.attach(w, flusher, -1)
+		handler.OnConnect(session.socket)
+		handler.OnMessage(session.socket, connectRequest(req))
+	}
+
+	var tick <-chan time.Time
+	if options.PingInterval > 0 {
+		ticker := time.NewTicker(options.PingInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-req.Context().Done():
+			session.detach(done)
+			return
+		case <-done:
+			// 连接被新的连接替换，或者会话已关闭
+			return
+		case <-tick:
+			if session.ping(done) != nil {
+				session.detach(done)
+				return
+			}
+		}
+	}
+}
+
+// postMessage 接收客户端请求，交给 handler 处理，结果通过 SSE 连接返回
+func postMessage(w http.ResponseWriter, req *http.Request, streamID string) {
+	session := getSSESession(streamID)
+	if session == nil {
+		http.NotFound(w, req)
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxSSERequestSize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	handler.OnMessage(session.socket, string(body))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// connectRequest 根据 URL 参数组装 connect 请求
+func connectRequest(req *http.Request) string {
+	request := map[string]string{"op": "connect"}
+	query := req.URL.Query()
+	for _, key := range connectKeys {
+		if v := query.Get(key); v != "" {
+			request[key] = v
+		}
+	}
+	data, _ := json.Marshal(request)
+	return string(data)
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type recordHandler struct {
+	messages    []interface{}
+	disconnects int
+}
+
+func (h *recordHandler) OnConnect(ws *WebSocket) {}
+
+func (h *recordHandler) OnMessage(ws *WebSocket, msg interface{}) {
+	h.messages = append(h.messages, msg)
+}
+
+func (h *recordHandler) OnDisconnect(ws *WebSocket) {
+	h.disconnects++
+}
+
+func Test_sseSession(t *testing.T) {
+	h := &recordHandler{}
+	handler = h
+
+	s := newSSESession()
+	w := httptest.NewRecorder()
+	s.attach(w, w, -1)
+	s.socket.send(`{"op":"connected"}`)
+	s.socket.send(`{"op":"subscribed"}`)
+	expect := "id: " + s.id + ":0\ndata: {\"op\":\"stream\",\"streamId\":\"" + s.id + "\"}\n\n" +
+		"id: " + s.id + ":1\ndata: {\"op\":\"connected\"}\n\n" +
+		"id: " + s.id + ":2\ndata: {\"op\":\"subscribed\"}\n\n"
+	if w.Body.String() != expect {
+		t.Error("expect:", expect, "result:", w.Body.String())
+	}
+	/*************************************************/
+	resumed, seq := resumeSSESession(s.id + ":1")
+	if resumed != s || seq != 1 {
+		t.Error("expect:", s.id, 1, "result:", resumed, seq)
+	}
+	w = httptest.NewRecorder()
+	s.attach(w, w, seq)
+	expect = "id: " + s.id + ":2\ndata: {\"op\":\"subscribed\"}\n\n"
+	if w.Body.String() != expect {
+		t.Error("expect:", expect, "result:", w.Body.String())
+	}
+	/*************************************************/
+	resumed, _ = resumeSSESession(s.id + ":1024")
+	if resumed != nil {
+		t.Error("expect:", nil, "result:", resumed)
+	}
+	if h.disconnects != 1 || getSSESession(s.id) != nil {
+		t.Error("expect:", 1, "result:", h.disconnects)
+	}
+	/*************************************************/
+	s = newSSESession()
+	s.attach(httptest.NewRecorder(), httptest.NewRecorder(), -1)
+	for i := 0; i < maxSSEHistory+1; i++ {
+		s.socket.send("{}")
+	}
+	resumed, _ = resumeSSESession(s.id + ":0")
+	if resumed != nil {
+		t.Error("expect:", nil, "result:", resumed)
+	}
+	if h.disconnects != 2 {
+		t.Error("expect:", 2, "result:", h.disconnects)
+	}
+}
+
+func Test_connectRequest(t *testing.T) {
+	req := httptest.NewRequest("GET", "/livequery/events?clientKey=test&other=1", nil)
+	result := connectRequest(req)
+	expect := `{"clientKey":"test","op":"connect"}`
+	if result != expect {
+		t.Error("expect:", expect, "result:", result)
+	}
+	if strings.Contains(result, "other") {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
@@ -12,9 +12,9 @@ type beegoLogger struct {
 	beelogger *logs.BeeLogger
 }
 
-func newBeegoLogger() *beegoLogger {
+func newBeegoLogger(level string) *beegoLogger {
 	l := logs.NewLogger(1000)
-	l.SetLevel(logs.LevelDebug)
+	l.SetLevel(beegoLevel(level))
 	l.SetLogger("file", `{"filename":"project.log"}`)
 	l.DelLogger("console")
 	l.Async()
@@ -46,6 +46,26 @@ func (l *beegoLogger) log(level string, args ...interface{}) {
 	}
 }
 
+func (l *beegoLogger) setLevel(level string) {
+	l.beelogger.SetLevel(beegoLevel(level))
+}
+
+// beegoLevel 转换为 beego 的日志级别，与 log 中的对应关系保持一致
+func beegoLevel(level string) int {
+	switch level {
+	case "error":
+		return logs.LevelError
+	case "warn":
+		return logs.LevelWarning
+	case "verbose":
+		return logs.LevelNotice
+	case "info":
+		return logs.LevelInformational
+	default:
+		return logs.LevelDebug
+	}
+}
+
 func generateFmtStr(n int) string {
 	return strings.Repeat("%v ", n)
 }
@@ -1,6 +1,9 @@
 package logger
 
-import "github.com/okobsamoht/talisman/types"
+import (
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/types"
+)
 
 const logStringTruncateLength = 1000
 const truncationMarker = "... (truncated)"
@@ -8,7 +11,15 @@ const truncationMarker = "... (truncated)"
 var adapter loggerAdapter
 
 func init() {
-	adapter = newBeegoLogger()
+	adapter = newBeegoLogger(config.TConfig.LogLevel)
+	config.OnReload(func(c *config.Config) {
+		SetLevel(c.LogLevel)
+	})
+}
+
+// SetLevel 设置日志级别，低于该级别的日志不再输出
+func SetLevel(level string) {
+	adapter.setLevel(level)
 }
 
 // Log ...
@@ -67,4 +78,5 @@ func GetLogs(options map[string]string) (types.M, error) {
 type loggerAdapter interface {
 	log(level string, args ...interface{})
 	query(options types.M) (types.M, error)
+	setLevel(level string)
 }
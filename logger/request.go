@@ -0,0 +1,47 @@
+package logger
+
+// RequestLogger 带有请求 ID 的日志记录器，每行日志以 [requestId] 开头
+// 客户端在错误响应中拿到请求 ID 后，可以据此查找对应的日志
+type RequestLogger struct {
+	prefix string
+}
+
+// Request 返回请求 ID 对应的日志记录器， requestID 为空时与包级函数相同
+func Request(requestID string) *RequestLogger {
+	if requestID == "" {
+		return &RequestLogger{}
+	}
+	return &RequestLogger{prefix: "[" + requestID + "]"}
+}
+
+func (l *RequestLogger) args(args []interface{}) []interface{} {
+	if l.prefix == "" {
+		return args
+	}
+	return append([]interface{}{l.prefix}, args...)
+}
+
+// Info ...
+func (l *RequestLogger) Info(args ...interface{}) {
+	Log("info", l.args(args)...)
+}
+
+// Error ...
+func (l *RequestLogger) Error(args ...interface{}) {
+	Log("error", l.args(args)...)
+}
+
+// Warn ...
+func (l *RequestLogger) Warn(args ...interface{}) {
+	Log("warn", l.args(args)...)
+}
+
+// Verbose ...
+func (l *RequestLogger) Verbose(args ...interface{}) {
+	Log("verbose", l.args(args)...)
+}
+
+// Debug ...
+func (l *RequestLogger) Debug(args ...interface{}) {
+	Log("debug", l.args(args)...)
+}
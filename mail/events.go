@@ -0,0 +1,137 @@
+package mail
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// SendGrid 与 Mailgun 通过回调推送邮件的投递事件，解析之后保存到 _EmailEvent 表中，
+// 可以据此找出退信或者投诉的地址，停止向这些地址发送邮件
+
+// Event 邮件投递事件
+type Event struct {
+	Provider  string
+	Email     string
+	Event     string // 统一之后的事件类型，见 eventNames
+	MessageID string
+	Reason    string // 退信或者失败的原因
+	Timestamp time.Time
+}
+
+// eventNames 把服务商的事件类型统一为 processed delivered deferred bounced dropped opened clicked complained unsubscribed
+var eventNames = map[string]string{
+	"processed":         "processed",
+	"accepted":          "processed",
+	"delivered":         "delivered",
+	"deferred":          "deferred",
+	"bounce":            "bounced",
+	"dropped":           "dropped",
+	"open":              "opened",
+	"opened":            "opened",
+	"click":             "clicked",
+	"clicked":           "clicked",
+	"spamreport":        "complained",
+	"complained":        "complained",
+	"unsubscribe":       "unsubscribed",
+	"group_unsubscribe": "unsubscribed",
+	"unsubscribed":      "unsubscribed",
+}
+
+func eventName(name string) string {
+	if n, ok := eventNames[name]; ok {
+		return n
+	}
+	return name
+}
+
+// ParseSendGridEvents 解析 SendGrid Event Webhook 的请求体，格式为事件数组
+func ParseSendGridEvents(body []byte) ([]*Event, error) {
+	var raw []types.M
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+	events := []*Event{}
+	for _, r := range raw {
+		event := &Event{
+			Provider:  "SendGrid",
+			Email:     utils.S(r["email"]),
+			Event:     eventName(utils.S(r["event"])),
+			MessageID: utils.S(r["sg_message_id"]),
+			Reason:    utils.S(r["reason"]),
+		}
+		if event.Email == "" || event.Event == "" {
+			continue
+		}
+		if ts, ok := r["timestamp"].(float64); ok {
+			event.Timestamp = time.Unix(int64(ts), 0).UTC()
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// ParseMailgunEvent 解析 Mailgun Webhook 的请求体， signingKey 不为空时校验签名，
+// failed 事件按照 severity 区分为 bounced 与 deferred
+func ParseMailgunEvent(body []byte, signingKey string) (*Event, error) {
+	var raw types.M
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+	if signingKey != "" {
+		signature := utils.M(raw["signature"])
+		if signature == nil || VerifyMailgunSignature(signingKey, utils.S(signature["timestamp"]), utils.S(signature["token"]), utils.S(signature["signature"])) == false {
+			return nil, errors.New("invalid mailgun signature")
+		}
+	}
+	data := utils.M(raw["event-data"])
+	if data == nil {
+		return nil, errors.New("event-data is required")
+	}
+	event := &Event{
+		Provider: "Mailgun",
+		Email:    utils.S(data["recipient"]),
+		Event:    eventName(utils.S(data["event"])),
+		Reason:   utils.S(data["reason"]),
+	}
+	if event.Event == "failed" {
+		if utils.S(data["severity"]) == "temporary" {
+			event.Event = "deferred"
+		} else {
+			event.Event = "bounced"
+		}
+	}
+	if status := utils.M(data["delivery-status"]); status != nil {
+		if description := utils.S(status["description"]); description != "" {
+			event.Reason = description
+		} else if message := utils.S(status["message"]); message != "" {
+			event.Reason = message
+		}
+	}
+	if message := utils.M(data["message"]); message != nil {
+		if headers := utils.M(message["headers"]); headers != nil {
+			event.MessageID = utils.S(headers["message-id"])
+		}
+	}
+	if ts, ok := data["timestamp"].(float64); ok {
+		event.Timestamp = time.Unix(int64(ts), int64((ts-float64(int64(ts)))*1e9)).UTC()
+	}
+	if event.Email == "" || event.Event == "" {
+		return nil, errors.New("recipient and event are required")
+	}
+	return event, nil
+}
+
+// VerifyMailgunSignature 校验 Mailgun 回调的签名，签名为 HMAC-SHA256(signingKey, timestamp+token)
+func VerifyMailgunSignature(signingKey, timestamp, token, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(timestamp + token))
+	return hmac.Equal([]byte(strings.ToLower(signature)), []byte(hex.EncodeToString(mac.Sum(nil))))
+}
@@ -0,0 +1,82 @@
+package mail
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_ParseSendGridEvents(t *testing.T) {
+	body := `[
+		{"email":"a@g.com","event":"delivered","timestamp":1456665905,"sg_message_id":"m1"},
+		{"email":"b@g.com","event":"bounce","timestamp":1456665906,"sg_message_id":"m2","reason":"550 5.1.1 unknown user"},
+		{"email":"c@g.com","event":"spamreport","timestamp":1456665907},
+		{"event":"delivered"}
+	]`
+	events, err := ParseSendGridEvents([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []*Event{
+		{Provider: "SendGrid", Email: "a@g.com", Event: "delivered", MessageID: "m1", Timestamp: time.Unix(1456665905, 0).UTC()},
+		{Provider: "SendGrid", Email: "b@g.com", Event: "bounced", MessageID: "m2", Reason: "550 5.1.1 unknown user", Timestamp: time.Unix(1456665906, 0).UTC()},
+		{Provider: "SendGrid", Email: "c@g.com", Event: "complained", Timestamp: time.Unix(1456665907, 0).UTC()},
+	}
+	if reflect.DeepEqual(expect, events) == false {
+		t.Error("expect:", expect, "result:", events)
+	}
+	/*************************************************/
+	_, err = ParseSendGridEvents([]byte(`{}`))
+	if err == nil {
+		t.Error("expect:", "error", "result:", nil)
+	}
+}
+
+func Test_ParseMailgunEvent(t *testing.T) {
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write([]byte("1456665905" + "abc"))
+	signature := hex.EncodeToString(mac.Sum(nil))
+	body := `{
+		"signature":{"timestamp":"1456665905","token":"abc","signature":"` + signature + `"},
+		"event-data":{
+			"event":"failed","severity":"permanent","recipient":"a@g.com","timestamp":1456665905.5,
+			"delivery-status":{"message":"","description":"No such mailbox"},
+			"message":{"headers":{"message-id":"m1@g.com"}}
+		}
+	}`
+	event, err := ParseMailgunEvent([]byte(body), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := &Event{
+		Provider:  "Mailgun",
+		Email:     "a@g.com",
+		Event:     "bounced",
+		MessageID: "m1@g.com",
+		Reason:    "No such mailbox",
+		Timestamp: time.Unix(1456665905, 5e8).UTC(),
+	}
+	if reflect.DeepEqual(expect, event) == false {
+		t.Error("expect:", expect, "result:", event)
+	}
+	/*************************************************/
+	_, err = ParseMailgunEvent([]byte(body), "other")
+	if err == nil || err.Error() != "invalid mailgun signature" {
+		t.Error("expect:", "invalid mailgun signature", "result:", err)
+	}
+	/*************************************************/
+	body = `{"event-data":{"event":"failed","severity":"temporary","recipient":"a@g.com"}}`
+	event, err = ParseMailgunEvent([]byte(body), "")
+	if err != nil || event.Event != "deferred" {
+		t.Error("expect:", "deferred", "result:", event, err)
+	}
+	/*************************************************/
+	body = `{"event-data":{"event":"opened"}}`
+	_, err = ParseMailgunEvent([]byte(body), "")
+	if err == nil {
+		t.Error("expect:", "error", "result:", nil)
+	}
+}
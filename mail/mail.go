@@ -1,6 +1,11 @@
 package mail
 
-import "github.com/okobsamoht/talisman/types"
+import (
+	"strings"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/types"
+)
 
 // Adapter ...
 type Adapter interface {
@@ -8,5 +13,23 @@ type Adapter interface {
 	// to 接收方地址
 	// text 邮件内容
 	// subject 邮件主题
+	// 以及可选的 html ，为 HTML 格式的邮件内容
+	// 另外两个可选参数，支持模板的发送模块使用：
+	// templateName 邮件类型，如 verifyEmail passwordReset ，通过 MailTemplates 查找对应的模板 ID
+	// templateData 模板变量
 	SendMail(types.M) error
 }
+
+// templateID 查找邮件类型对应的模板 ID ，未配置时返回空
+func templateID(name string) string {
+	if name == "" {
+		return ""
+	}
+	for _, t := range config.TConfig.MailTemplates {
+		p := strings.SplitN(t, ":", 2)
+		if len(p) == 2 && p[0] == name {
+			return p[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package mail
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// MailgunMailAdapter 通过 Mailgun API 发送邮件
+type MailgunMailAdapter struct {
+	url     string
+	apiKey  string
+	from    string
+	sandbox bool
+	client  *http.Client
+}
+
+// NewMailgunAdapter ...
+func NewMailgunAdapter() *MailgunMailAdapter {
+	return &MailgunMailAdapter{
+		url:     strings.TrimRight(config.TConfig.MailgunAPIURL, "/") + "/v3/" + config.TConfig.MailgunDomain + "/messages",
+		apiKey:  config.TConfig.MailgunAPIKey,
+		from:    config.TConfig.MailFrom,
+		sandbox: config.TConfig.MailSandbox,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SendMail 配置了模板时使用模板发送， templateData 通过 h:X-Mailgun-Variables 传递，沙盒模式使用 o:testmode
+func (m *MailgunMailAdapter) SendMail(object types.M) error {
+	form := url.Values{}
+	form.Set("from", m.from)
+	form.Set("to", utils.S(object["to"]))
+	form.Set("subject", utils.S(object["subject"]))
+	if id := templateID(utils.S(object["templateName"])); id != "" {
+		form.Set("template", id)
+		if data := utils.M(object["templateData"]); data != nil {
+			b, err := json.Marshal(data)
+			if err != nil {
+				return err
+			}
+			form.Set("h:X-Mailgun-Variables", string(b))
+		}
+	} else {
+		form.Set("text", utils.S(object["text"]))
+		if html := utils.S(object["html"]); html != "" {
+			form.Set("html", html)
+		}
+	}
+	if m.sandbox {
+		form.Set("o:testmode", "yes")
+	}
+
+	request, err := http.NewRequest("POST", m.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth("api", m.apiKey)
+	response, err := m.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return errors.New("mailgun returned status " + strconv.Itoa(response.StatusCode))
+	}
+	return nil
+}
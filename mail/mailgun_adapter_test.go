@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_MailgunMailAdapter(t *testing.T) {
+	var received url.Values
+	var username, password string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ = r.BasicAuth()
+		r.ParseForm()
+		received = r.PostForm
+		if received.Get("to") == "fail@g.com" {
+			w.WriteHeader(400)
+		}
+	}))
+	defer server.Close()
+	config.TConfig = &config.Config{MailTemplates: []string{"passwordReset:reset"}}
+	m := &MailgunMailAdapter{url: server.URL, apiKey: "key", from: "noreply@g.com", client: http.DefaultClient}
+	/*************************************************/
+	err := m.SendMail(types.M{"to": "123@g.com", "subject": "hello", "text": "hello world"})
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	expect := url.Values{
+		"from":    []string{"noreply@g.com"},
+		"to":      []string{"123@g.com"},
+		"subject": []string{"hello"},
+		"text":    []string{"hello world"},
+	}
+	if reflect.DeepEqual(expect, received) == false {
+		t.Error("expect:", expect, "result:", received)
+	}
+	if username != "api" || password != "key" {
+		t.Error("expect:", "api:key", "result:", username+":"+password)
+	}
+	/*************************************************/
+	m.sandbox = true
+	err = m.SendMail(types.M{
+		"to":           "123@g.com",
+		"subject":      "hello",
+		"text":         "hello world",
+		"templateName": "passwordReset",
+		"templateData": types.M{"link": "http://www.g.com"},
+	})
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	expect = url.Values{
+		"from":                  []string{"noreply@g.com"},
+		"to":                    []string{"123@g.com"},
+		"subject":               []string{"hello"},
+		"template":              []string{"reset"},
+		"h:X-Mailgun-Variables": []string{`{"link":"http://www.g.com"}`},
+		"o:testmode":            []string{"yes"},
+	}
+	if reflect.DeepEqual(expect, received) == false {
+		t.Error("expect:", expect, "result:", received)
+	}
+	/*************************************************/
+	err = m.SendMail(types.M{"to": "fail@g.com"})
+	if err == nil {
+		t.Error("expect:", "error", "result:", nil)
+	}
+}
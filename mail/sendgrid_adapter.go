@@ -0,0 +1,94 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// SendGridMailAdapter 通过 SendGrid v3 API 发送邮件
+type SendGridMailAdapter struct {
+	url     string
+	apiKey  string
+	from    string
+	sandbox bool
+	client  *http.Client
+}
+
+// NewSendGridAdapter ...
+func NewSendGridAdapter() *SendGridMailAdapter {
+	return &SendGridMailAdapter{
+		url:     "https://api.sendgrid.com/v3/mail/send",
+		apiKey:  config.TConfig.SendGridAPIKey,
+		from:    config.TConfig.MailFrom,
+		sandbox: config.TConfig.MailSandbox,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SendMail 配置了模板时使用动态模板发送， templateData 作为 dynamic_template_data ，主题由模板决定
+func (s *SendGridMailAdapter) SendMail(object types.M) error {
+	personalization := types.M{
+		"to": []types.M{{"email": utils.S(object["to"])}},
+	}
+	payload := types.M{
+		"personalizations": []types.M{personalization},
+		"from":             sendGridAddress(s.from),
+	}
+	if id := templateID(utils.S(object["templateName"])); id != "" {
+		payload["template_id"] = id
+		if data := utils.M(object["templateData"]); data != nil {
+			personalization["dynamic_template_data"] = data
+		}
+	} else {
+		payload["subject"] = utils.S(object["subject"])
+		content := []types.M{{"type": "text/plain", "value": utils.S(object["text"])}}
+		if html := utils.S(object["html"]); html != "" {
+			content = append(content, types.M{"type": "text/html", "value": html})
+		}
+		payload["content"] = content
+	}
+	if s.sandbox {
+		payload["mail_settings"] = types.M{"sandbox_mode": types.M{"enable": true}}
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", s.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+s.apiKey)
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return errors.New("sendgrid returned status " + strconv.Itoa(response.StatusCode))
+	}
+	return nil
+}
+
+// sendGridAddress 把 Name <email> 格式的地址转换为 SendGrid 的格式
+func sendGridAddress(s string) types.M {
+	address, err := mail.ParseAddress(s)
+	if err != nil {
+		return types.M{"email": s}
+	}
+	if address.Name == "" {
+		return types.M{"email": address.Address}
+	}
+	return types.M{"email": address.Address, "name": address.Name}
+}
@@ -0,0 +1,77 @@
+package mail
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_SendGridMailAdapter(t *testing.T) {
+	var received types.M
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(401)
+			return
+		}
+		authorization = r.Header.Get("Authorization")
+		received = types.M{}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(202)
+	}))
+	defer server.Close()
+	config.TConfig = &config.Config{MailTemplates: []string{"verifyEmail:d-123"}}
+	s := &SendGridMailAdapter{url: server.URL, apiKey: "key", from: "Talisman <noreply@g.com>", client: http.DefaultClient}
+	/*************************************************/
+	err := s.SendMail(types.M{"to": "123@g.com", "subject": "hello", "text": "hello world"})
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	expect := types.M{
+		"personalizations": []interface{}{map[string]interface{}{"to": []interface{}{map[string]interface{}{"email": "123@g.com"}}}},
+		"from":             map[string]interface{}{"email": "noreply@g.com", "name": "Talisman"},
+		"subject":          "hello",
+		"content":          []interface{}{map[string]interface{}{"type": "text/plain", "value": "hello world"}},
+	}
+	if reflect.DeepEqual(expect, received) == false {
+		t.Error("expect:", expect, "result:", received)
+	}
+	if authorization != "Bearer key" {
+		t.Error("expect:", "Bearer key", "result:", authorization)
+	}
+	/*************************************************/
+	s.sandbox = true
+	err = s.SendMail(types.M{
+		"to":           "123@g.com",
+		"subject":      "hello",
+		"text":         "hello world",
+		"templateName": "verifyEmail",
+		"templateData": types.M{"link": "http://www.g.com"},
+	})
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	expect = types.M{
+		"personalizations": []interface{}{map[string]interface{}{
+			"to":                    []interface{}{map[string]interface{}{"email": "123@g.com"}},
+			"dynamic_template_data": map[string]interface{}{"link": "http://www.g.com"},
+		}},
+		"from":          map[string]interface{}{"email": "noreply@g.com", "name": "Talisman"},
+		"template_id":   "d-123",
+		"mail_settings": map[string]interface{}{"sandbox_mode": map[string]interface{}{"enable": true}},
+	}
+	if reflect.DeepEqual(expect, received) == false {
+		t.Error("expect:", expect, "result:", received)
+	}
+	/*************************************************/
+	s.url = server.URL + "/fail"
+	err = s.SendMail(types.M{"to": "123@g.com"})
+	if err == nil {
+		t.Error("expect:", "error", "result:", nil)
+	}
+}
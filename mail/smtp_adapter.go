@@ -38,13 +38,34 @@ func (s *SMTPMailAdapter) SendMail(object types.M) error {
 
 	auth := smtp.PlainAuth("", s.username, s.password, s.server)
 	to := []string{receiver}
-	msg := []byte("To: " + receiver + "\r\n" +
-		"Subject: " + subject + "\r\n" +
-		"\r\n" +
-		text + "\r\n")
+	msg := smtpMessage(receiver, subject, text, utils.S(object["html"]))
 	err := smtp.SendMail(s.server+":25", auth, s.username, to, msg)
 	if err != nil {
 		// 打印错误
 	}
 	return nil
 }
+
+// smtpMessage 生成邮件内容，包含 html 时使用 multipart/alternative 同时发送纯文本与 HTML
+func smtpMessage(to, subject, text, html string) []byte {
+	header := "To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n"
+	if html == "" {
+		return []byte(header + "\r\n" + text + "\r\n")
+	}
+	boundary := utils.CreateToken()
+	body := header +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/alternative; boundary=" + boundary + "\r\n" +
+		"\r\n" +
+		"--" + boundary + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		text + "\r\n" +
+		"--" + boundary + "\r\n" +
+		"Content-Type: text/html; charset=UTF-8\r\n" +
+		"\r\n" +
+		html + "\r\n" +
+		"--" + boundary + "--\r\n"
+	return []byte(body)
+}
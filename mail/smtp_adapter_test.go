@@ -1,6 +1,7 @@
 package mail
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/okobsamoht/talisman/config"
@@ -22,3 +23,18 @@ func Test_smtp(t *testing.T) {
 	}
 	s.SendMail(object)
 }
+
+func Test_smtpMessage(t *testing.T) {
+	msg := string(smtpMessage("user@163.com", "hello", "text", ""))
+	expect := "To: user@163.com\r\nSubject: hello\r\n\r\ntext\r\n"
+	if msg != expect {
+		t.Error("expect:", expect, "result:", msg)
+	}
+	/*************************************************/
+	msg = string(smtpMessage("user@163.com", "hello", "text", "<p>html</p>"))
+	if strings.Contains(msg, "Content-Type: multipart/alternative; boundary=") == false ||
+		strings.Contains(msg, "Content-Type: text/plain; charset=UTF-8\r\n\r\ntext\r\n") == false ||
+		strings.Contains(msg, "Content-Type: text/html; charset=UTF-8\r\n\r\n<p>html</p>\r\n") == false {
+		t.Error("expect:", "multipart message", "result:", msg)
+	}
+}
@@ -0,0 +1,63 @@
+package orm
+
+import (
+	"sort"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/storage"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 字段类型为 AutoIncrement 时，创建对象时从数据库的序列中原子地取得下一个值，可用于订单号等便于阅读的编号，
+// 字段定义的格式为 {"type":"AutoIncrement"} ，值为从 1 开始的整数，可以像 Number 字段一样查询与排序
+// MongoDB 的序列保存在 _Sequence 表中， PostgreSQL 使用 SEQUENCE ，创建失败的对象会使编号出现空缺
+// 只有使用 Master Key 时才能指定或者修改字段的值
+
+// autoIncrementFieldsOf 返回类中的自增字段，按字段名排序
+func autoIncrementFieldsOf(fields types.M) []string {
+	result := []string{}
+	for fieldName, v := range fields {
+		if utils.S(utils.M(v)["type"]) == "AutoIncrement" {
+			result = append(result, fieldName)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// validateAutoIncrementWrite 不使用 Master Key 时不能写入自增字段
+func validateAutoIncrementWrite(fields, object types.M, isMaster bool) error {
+	if isMaster {
+		return nil
+	}
+	for _, fieldName := range autoIncrementFieldsOf(fields) {
+		if _, ok := object[fieldName]; ok {
+			return errs.E(errs.OperationForbidden, fieldName+" is an AutoIncrement field and can not be set.")
+		}
+	}
+	return nil
+}
+
+// assignAutoIncrementFields 为对象中没有值的自增字段分配下一个序列值，直接修改 object
+func assignAutoIncrementFields(className string, fields, object types.M) error {
+	autoIncrementFields := autoIncrementFieldsOf(fields)
+	if len(autoIncrementFields) == 0 {
+		return nil
+	}
+	sequencer, ok := Adapter.(storage.Sequencer)
+	if ok == false {
+		return errs.E(errs.OperationForbidden, "AutoIncrement is not supported by the database adapter.")
+	}
+	for _, fieldName := range autoIncrementFields {
+		if object[fieldName] != nil {
+			continue
+		}
+		value, err := sequencer.NextSequenceValue(className, fieldName)
+		if err != nil {
+			return errs.FromAdapter(err)
+		}
+		object[fieldName] = value
+	}
+	return nil
+}
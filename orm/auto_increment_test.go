@@ -0,0 +1,80 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_autoIncrementFieldsOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields types.M
+		want   []string
+	}{
+		{
+			name:   "1",
+			fields: nil,
+			want:   []string{},
+		},
+		{
+			name: "2",
+			fields: types.M{
+				"title":     types.M{"type": "String"},
+				"orderNo":   types.M{"type": "AutoIncrement"},
+				"invoiceNo": types.M{"type": "AutoIncrement"},
+			},
+			want: []string{"invoiceNo", "orderNo"},
+		},
+	}
+	for _, tt := range tests {
+		if got := autoIncrementFieldsOf(tt.fields); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. autoIncrementFieldsOf() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_validateAutoIncrementWrite(t *testing.T) {
+	fields := types.M{
+		"title":   types.M{"type": "String"},
+		"orderNo": types.M{"type": "AutoIncrement"},
+	}
+	tests := []struct {
+		name     string
+		object   types.M
+		isMaster bool
+		wantErr  error
+	}{
+		{
+			name:     "1",
+			object:   types.M{"title": "hello"},
+			isMaster: false,
+			wantErr:  nil,
+		},
+		{
+			name:     "2",
+			object:   types.M{"title": "hello", "orderNo": 10},
+			isMaster: false,
+			wantErr:  errs.E(errs.OperationForbidden, "orderNo is an AutoIncrement field and can not be set."),
+		},
+		{
+			name:     "3",
+			object:   types.M{"orderNo": types.M{"__op": "Increment", "amount": 1}},
+			isMaster: false,
+			wantErr:  errs.E(errs.OperationForbidden, "orderNo is an AutoIncrement field and can not be set."),
+		},
+		{
+			name:     "4",
+			object:   types.M{"orderNo": 10},
+			isMaster: true,
+			wantErr:  nil,
+		},
+	}
+	for _, tt := range tests {
+		if err := validateAutoIncrementWrite(fields, tt.object, tt.isMaster); !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%q. validateAutoIncrementWrite() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package orm
+
+import (
+	"strings"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 类级别权限模式
+const (
+	CLPModePublic        = "public"        // 所有人可访问
+	CLPModeAuthenticated = "authenticated" // 仅登录用户可访问
+	CLPModeMasterKey     = "masterKey"     // 仅 Master Key 可访问
+)
+
+// clpOperations 类级别权限中的操作，不包含 readUserFields 与 writeUserFields
+var clpOperations = []string{"find", "count", "get", "create", "update", "delete", "addField"}
+
+// CLPModeIsValid 校验类级别权限模式
+func CLPModeIsValid(mode string) bool {
+	return mode == CLPModePublic || mode == CLPModeAuthenticated || mode == CLPModeMasterKey
+}
+
+// defaultCLPForNewClass 根据 DefaultClassLevelPermissions 返回新建类的权限
+// 系统类与 public 模式返回 nil ，即使用数据库的默认权限
+func defaultCLPForNewClass(className string) types.M {
+	mode := config.TConfig.DefaultClassLevelPermissions
+	if mode == "" || mode == CLPModePublic || strings.HasPrefix(className, "_") {
+		return nil
+	}
+	return tightenCLP(types.M{}, mode)
+}
+
+// tightenCLP 按照指定模式收紧类级别权限，返回新的权限
+// authenticated 模式下，公开的操作改为仅登录用户可访问
+// masterKey 模式下，去掉公开与登录用户的权限，只保留对指定用户与角色的授权
+// 未设置的操作视为公开， readUserFields 与 writeUserFields 保持不变
+func tightenCLP(perms types.M, mode string) types.M {
+	result := types.M{}
+	for k, v := range perms {
+		result[k] = v
+	}
+	for _, operation := range clpOperations {
+		perm := utils.M(result[operation])
+		if perm == nil {
+			perm = types.M{"*": true}
+		}
+		result[operation] = tightenPermission(perm, mode)
+	}
+	return result
+}
+
+// tightenPermission 收紧单个操作的权限
+func tightenPermission(perm types.M, mode string) types.M {
+	_, public := perm["*"]
+	_, authenticated := perm["requiresAuthentication"]
+	switch mode {
+	case CLPModeAuthenticated:
+		if public {
+			return types.M{"requiresAuthentication": true}
+		}
+	case CLPModeMasterKey:
+		if public || authenticated {
+			result := types.M{}
+			for k, v := range perm {
+				if k != "*" && k != "requiresAuthentication" {
+					result[k] = v
+				}
+			}
+			return result
+		}
+	}
+	return perm
+}
+
+// TightenClassLevelPermissions 批量收紧类级别权限，返回修改后的类定义
+// classNames 为空时处理所有非系统类，系统类的权限不允许修改
+func (s *Schema) TightenClassLevelPermissions(mode string, classNames []string) ([]types.M, error) {
+	if mode != CLPModeAuthenticated && mode != CLPModeMasterKey {
+		return nil, errs.E(errs.InvalidJSON, "mode must be one of authenticated, masterKey")
+	}
+	for _, className := range classNames {
+		if strings.HasPrefix(className, "_") {
+			return nil, errs.E(errs.InvalidClassName, "Class level permissions of system class "+className+" can not be tightened.")
+		}
+	}
+
+	var schemas []types.M
+	if len(classNames) == 0 {
+		allSchemas, err := s.GetAllClasses(types.M{"clearCache": true})
+		if err != nil {
+			return nil, err
+		}
+		for _, schema := range allSchemas {
+			if strings.HasPrefix(utils.S(schema["className"]), "_") == false {
+				schemas = append(schemas, schema)
+			}
+		}
+	} else {
+		for _, className := range classNames {
+			schema, err := s.GetOneSchema(className, false, types.M{"clearCache": true})
+			if err != nil {
+				return nil, err
+			}
+			if len(schema) == 0 {
+				return nil, errs.E(errs.InvalidClassName, "Class "+className+" does not exist.")
+			}
+			schemas = append(schemas, schema)
+		}
+	}
+
+	results := []types.M{}
+	for _, schema := range schemas {
+		className := utils.S(schema["className"])
+		perms := tightenCLP(utils.M(schema["classLevelPermissions"]), mode)
+		err := s.setPermissions(className, perms, utils.M(schema["fields"]))
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, types.M{
+			"className":             className,
+			"classLevelPermissions": perms,
+		})
+	}
+	return results, nil
+}
@@ -0,0 +1,154 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_tightenCLP(t *testing.T) {
+	type args struct {
+		perms types.M
+		mode  string
+	}
+	tests := []struct {
+		name string
+		args args
+		want types.M
+	}{
+		{
+			name: "1",
+			args: args{perms: nil, mode: CLPModeAuthenticated},
+			want: types.M{
+				"find":     types.M{"requiresAuthentication": true},
+				"count":    types.M{"requiresAuthentication": true},
+				"get":      types.M{"requiresAuthentication": true},
+				"create":   types.M{"requiresAuthentication": true},
+				"update":   types.M{"requiresAuthentication": true},
+				"delete":   types.M{"requiresAuthentication": true},
+				"addField": types.M{"requiresAuthentication": true},
+			},
+		},
+		{
+			name: "2",
+			args: args{
+				perms: types.M{
+					"find":           types.M{"*": true, "role:admin": true},
+					"get":            types.M{"role:admin": true},
+					"create":         types.M{"requiresAuthentication": true},
+					"readUserFields": types.S{"owner"},
+				},
+				mode: CLPModeAuthenticated,
+			},
+			want: types.M{
+				"find":           types.M{"requiresAuthentication": true},
+				"count":          types.M{"requiresAuthentication": true},
+				"get":            types.M{"role:admin": true},
+				"create":         types.M{"requiresAuthentication": true},
+				"update":         types.M{"requiresAuthentication": true},
+				"delete":         types.M{"requiresAuthentication": true},
+				"addField":       types.M{"requiresAuthentication": true},
+				"readUserFields": types.S{"owner"},
+			},
+		},
+		{
+			name: "3",
+			args: args{
+				perms: types.M{
+					"find":   types.M{"*": true, "role:admin": true},
+					"get":    types.M{"role:admin": true},
+					"create": types.M{"requiresAuthentication": true},
+				},
+				mode: CLPModeMasterKey,
+			},
+			want: types.M{
+				"find":     types.M{"role:admin": true},
+				"count":    types.M{},
+				"get":      types.M{"role:admin": true},
+				"create":   types.M{},
+				"update":   types.M{},
+				"delete":   types.M{},
+				"addField": types.M{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		if got := tightenCLP(tt.args.perms, tt.args.mode); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. tightenCLP() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_defaultCLPForNewClass(t *testing.T) {
+	mode := config.TConfig.DefaultClassLevelPermissions
+	defer func() { config.TConfig.DefaultClassLevelPermissions = mode }()
+	/*************************************************/
+	config.TConfig.DefaultClassLevelPermissions = CLPModePublic
+	result := defaultCLPForNewClass("post")
+	if result != nil {
+		t.Error("expect:", nil, "result:", result)
+	}
+	/*************************************************/
+	config.TConfig.DefaultClassLevelPermissions = CLPModeMasterKey
+	result = defaultCLPForNewClass("_User")
+	if result != nil {
+		t.Error("expect:", nil, "result:", result)
+	}
+	/*************************************************/
+	result = defaultCLPForNewClass("post")
+	expect := tightenCLP(types.M{}, CLPModeMasterKey)
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
+
+func Test_TightenClassLevelPermissions(t *testing.T) {
+	adapter := getAdapter()
+	schema := getSchema()
+	var results []types.M
+	var err error
+	var expect []types.M
+	/*************************************************/
+	_, err = schema.TightenClassLevelPermissions(CLPModePublic, nil)
+	if err == nil {
+		t.Error("expect:", "error", "result:", err)
+	}
+	/*************************************************/
+	_, err = schema.TightenClassLevelPermissions(CLPModeMasterKey, []string{"_User"})
+	expectErr := errs.E(errs.InvalidClassName, "Class level permissions of system class _User can not be tightened.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/*************************************************/
+	adapter.CreateClass("post", types.M{
+		"fields": types.M{
+			"key": types.M{"type": "String"},
+		},
+		"classLevelPermissions": types.M{
+			"get": types.M{"role:admin": true},
+		},
+	})
+	schema.reloadData(types.M{"clearCache": true})
+	results, err = schema.TightenClassLevelPermissions(CLPModeAuthenticated, nil)
+	expect = []types.M{
+		types.M{
+			"className": "post",
+			"classLevelPermissions": types.M{
+				"find":     types.M{"requiresAuthentication": true},
+				"count":    types.M{"requiresAuthentication": true},
+				"get":      types.M{"role:admin": true},
+				"create":   types.M{"requiresAuthentication": true},
+				"update":   types.M{"requiresAuthentication": true},
+				"delete":   types.M{"requiresAuthentication": true},
+				"addField": types.M{"requiresAuthentication": true},
+			},
+		},
+	}
+	if err != nil || reflect.DeepEqual(expect, results) == false {
+		t.Error("expect:", expect, "result:", results, err)
+	}
+	adapter.DeleteAllClasses()
+}
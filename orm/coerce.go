@@ -0,0 +1,83 @@
+package orm
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 类级别权限中的 coerceTypes 为 true 时，写入对象前把可以转换的值转换为字段声明的类型，
+// 如 Number 字段的 "5" 转换为 5 ， Date 字段的 ISO 时间字符串转换为 Date 对象，
+// 无法转换的值保持不变，仍按原来的规则返回 IncorrectType
+
+// validateCoerceTypes coerceTypes 必须为 bool 值
+func validateCoerceTypes(perm interface{}) error {
+	if _, ok := perm.(bool); ok == false {
+		return errs.E(errs.InvalidJSON, "coerceTypes must be a boolean value for class level permissions")
+	}
+	return nil
+}
+
+// coerceTypesEnabled 类是否开启了写入时的类型转换
+func (s *Schema) coerceTypesEnabled(className string) bool {
+	s.permsMutex.Lock()
+	defer s.permsMutex.Unlock()
+	if s.perms == nil {
+		return false
+	}
+	classPerms := utils.M(s.perms[className])
+	if classPerms == nil {
+		return false
+	}
+	coerce, _ := classPerms["coerceTypes"].(bool)
+	return coerce
+}
+
+// coerceObject 按字段声明的类型转换 object 中的值，直接修改 object
+func (s *Schema) coerceObject(className string, object types.M) {
+	if len(object) == 0 || s.coerceTypesEnabled(className) == false {
+		return
+	}
+	s.reloadData(nil)
+	for fieldName, v := range object {
+		if _, ok := v.(string); ok == false {
+			continue
+		}
+		expectedType := s.getExpectedType(className, fieldName)
+		if expectedType == nil {
+			continue
+		}
+		if coerced, ok := coerceValue(v.(string), utils.S(expectedType["type"])); ok {
+			object[fieldName] = coerced
+		}
+	}
+}
+
+// coerceValue 把字符串转换为指定类型，无法转换时返回 false
+func coerceValue(value, fieldType string) (interface{}, bool) {
+	switch fieldType {
+	case "Number":
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, false
+		}
+		return n, true
+	case "Date":
+		t, err := utils.StringtoTime(value)
+		if err != nil {
+			t, err = time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return nil, false
+			}
+		}
+		return types.M{
+			"__type": "Date",
+			"iso":    utils.TimetoString(t),
+		}, true
+	}
+	return nil, false
+}
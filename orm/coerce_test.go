@@ -0,0 +1,95 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_validateCoerceTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		perm    interface{}
+		wantErr error
+	}{
+		{name: "1", perm: true, wantErr: nil},
+		{name: "2", perm: false, wantErr: nil},
+		{name: "3", perm: "true", wantErr: errs.E(errs.InvalidJSON, "coerceTypes must be a boolean value for class level permissions")},
+	}
+	for _, tt := range tests {
+		if err := validateCoerceTypes(tt.perm); !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%q. validateCoerceTypes() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_coerceValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		fieldType string
+		want      interface{}
+		wantOK    bool
+	}{
+		{name: "1", value: "5", fieldType: "Number", want: 5.0, wantOK: true},
+		{name: "2", value: " -1.5 ", fieldType: "Number", want: -1.5, wantOK: true},
+		{name: "3", value: "abc", fieldType: "Number", want: nil, wantOK: false},
+		{name: "4", value: "2006-01-02T15:04:05.000Z", fieldType: "Date", want: types.M{"__type": "Date", "iso": "2006-01-02T15:04:05.000Z"}, wantOK: true},
+		{name: "5", value: "2006-01-02T23:04:05+08:00", fieldType: "Date", want: types.M{"__type": "Date", "iso": "2006-01-02T15:04:05.000Z"}, wantOK: true},
+		{name: "6", value: "2006-01-02", fieldType: "Date", want: nil, wantOK: false},
+		{name: "7", value: "5", fieldType: "String", want: nil, wantOK: false},
+		{name: "8", value: "true", fieldType: "Boolean", want: nil, wantOK: false},
+	}
+	for _, tt := range tests {
+		got, ok := coerceValue(tt.value, tt.fieldType)
+		if ok != tt.wantOK {
+			t.Errorf("%q. coerceValue() ok = %v, want %v", tt.name, ok, tt.wantOK)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. coerceValue() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_coerceObject(t *testing.T) {
+	s := &Schema{
+		data: types.M{
+			"post": types.M{
+				"count":    types.M{"type": "Number"},
+				"postedAt": types.M{"type": "Date"},
+				"title":    types.M{"type": "String"},
+			},
+		},
+		perms: types.M{
+			"post": types.M{"coerceTypes": true},
+		},
+		reloadDataPromise: []types.M{},
+	}
+	object := types.M{
+		"count":    "5",
+		"postedAt": "2006-01-02T15:04:05.000Z",
+		"title":    "5",
+		"other":    "5",
+	}
+	s.coerceObject("post", object)
+	expect := types.M{
+		"count":    5.0,
+		"postedAt": types.M{"__type": "Date", "iso": "2006-01-02T15:04:05.000Z"},
+		"title":    "5",
+		"other":    "5",
+	}
+	if reflect.DeepEqual(expect, object) == false {
+		t.Error("expect:", expect, "result:", object)
+	}
+	/************************************************************/
+	s.perms = types.M{"post": types.M{"coerceTypes": false}}
+	object = types.M{"count": "5"}
+	s.coerceObject("post", object)
+	expect = types.M{"count": "5"}
+	if reflect.DeepEqual(expect, object) == false {
+		t.Error("expect:", expect, "result:", object)
+	}
+}
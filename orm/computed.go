@@ -0,0 +1,143 @@
+package orm
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/okobsamoht/talisman/cloud"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 字段类型为 Computed 时，字段值不保存到数据库，而是在读取对象时调用云代码中注册的计算函数生成，
+// 字段定义的格式为 {"type":"Computed","function":"fullName"} ，计算函数通过 cloud.Computed 注册
+// 写入对象时忽略计算字段，查询条件与排序中不能使用计算字段
+
+// validateComputedField Computed 类型的字段必须指定计算函数的名称
+func validateComputedField(t types.M) error {
+	function, ok := t["function"].(string)
+	if ok == false || function == "" {
+		return errs.E(errs.MissingRequiredFieldError, "type Computed needs a function name")
+	}
+	return nil
+}
+
+// computedFieldsOf 返回类中的计算字段与对应的计算函数名称
+func computedFieldsOf(fields types.M) map[string]string {
+	computed := map[string]string{}
+	for fieldName, v := range fields {
+		field := utils.M(v)
+		if utils.S(field["type"]) == "Computed" {
+			computed[fieldName] = utils.S(field["function"])
+		}
+	}
+	return computed
+}
+
+// removeComputedFields 删除写入数据中的计算字段，直接修改 object
+func (s *Schema) removeComputedFields(className string, object types.M) {
+	for fieldName := range object {
+		expectedType := s.getExpectedType(className, fieldName)
+		if expectedType != nil && utils.S(expectedType["type"]) == "Computed" {
+			delete(object, fieldName)
+		}
+	}
+}
+
+// validateComputedQuery 查询条件中不能使用计算字段
+func validateComputedQuery(query types.M, computed map[string]string) error {
+	if len(computed) == 0 {
+		return nil
+	}
+	for key, v := range query {
+		if key == "$or" || key == "$and" || key == "$nor" {
+			for _, subQuery := range utils.A(v) {
+				err := validateComputedQuery(utils.M(subQuery), computed)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if _, ok := computed[strings.Split(key, ".")[0]]; ok {
+			return errs.E(errs.InvalidQuery, "Cannot query on Computed field: "+key)
+		}
+	}
+	return nil
+}
+
+// validateComputedSort 排序中不能使用计算字段
+func validateComputedSort(keys []string, computed map[string]string) error {
+	for _, key := range keys {
+		key = strings.Split(strings.TrimPrefix(key, "-"), ".")[0]
+		if _, ok := computed[key]; ok {
+			return errs.E(errs.InvalidKeyName, "Cannot sort by Computed field: "+key)
+		}
+	}
+	return nil
+}
+
+// computedProjection 处理查询选项中的 keys ，返回需要计算的字段，以及为了计算额外读取、返回前需要删除的字段
+// keys 中的计算字段替换为计算函数依赖的字段，未指定 keys 时计算全部字段
+func computedProjection(options types.M, computed map[string]string) (fields []string, extra []string) {
+	if len(computed) == 0 {
+		return nil, nil
+	}
+	keys, ok := options["keys"].([]string)
+	if ok == false {
+		for fieldName := range computed {
+			fields = append(fields, fieldName)
+		}
+		sort.Strings(fields)
+		return fields, nil
+	}
+
+	selected := map[string]bool{}
+	newKeys := []string{}
+	for _, key := range keys {
+		if _, ok := computed[key]; ok {
+			fields = append(fields, key)
+			continue
+		}
+		selected[key] = true
+		newKeys = append(newKeys, key)
+	}
+	for _, fieldName := range fields {
+		handler := cloud.GetComputed(computed[fieldName])
+		if handler == nil {
+			continue
+		}
+		for _, key := range handler.DependsOn {
+			if selected[key] {
+				continue
+			}
+			selected[key] = true
+			newKeys = append(newKeys, key)
+			extra = append(extra, key)
+		}
+	}
+	// keys 中只有计算字段且计算函数没有依赖时，只读取 objectId
+	if len(newKeys) == 0 {
+		newKeys = append(newKeys, "objectId")
+	}
+	options["keys"] = newKeys
+	return fields, extra
+}
+
+// applyComputedFields 调用计算函数生成字段值，函数未注册时不返回该字段，然后删除额外读取的字段
+func applyComputedFields(object types.M, computed map[string]string, fields, extra []string) {
+	if object == nil {
+		return
+	}
+	for _, fieldName := range fields {
+		handler := cloud.GetComputed(computed[fieldName])
+		if handler == nil || handler.Handler == nil {
+			continue
+		}
+		object[fieldName] = handler.Handler(object)
+	}
+	for _, key := range extra {
+		delete(object, key)
+	}
+}
@@ -0,0 +1,120 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/cloud"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+func Test_validateComputedField(t *testing.T) {
+	tests := []struct {
+		name    string
+		t       types.M
+		wantErr error
+	}{
+		{
+			name:    "1",
+			t:       types.M{"type": "Computed", "function": "fullName"},
+			wantErr: nil,
+		},
+		{
+			name:    "2",
+			t:       types.M{"type": "Computed"},
+			wantErr: errs.E(errs.MissingRequiredFieldError, "type Computed needs a function name"),
+		},
+		{
+			name:    "3",
+			t:       types.M{"type": "Computed", "function": 1},
+			wantErr: errs.E(errs.MissingRequiredFieldError, "type Computed needs a function name"),
+		},
+	}
+	for _, tt := range tests {
+		if err := validateComputedField(tt.t); !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%q. validateComputedField() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_validateComputedQuery(t *testing.T) {
+	computed := map[string]string{"fullName": "fullName"}
+	tests := []struct {
+		name    string
+		query   types.M
+		wantErr error
+	}{
+		{
+			name:    "1",
+			query:   types.M{"firstName": "joe"},
+			wantErr: nil,
+		},
+		{
+			name:    "2",
+			query:   types.M{"fullName": "joe"},
+			wantErr: errs.E(errs.InvalidQuery, "Cannot query on Computed field: fullName"),
+		},
+		{
+			name:    "3",
+			query:   types.M{"$or": types.S{types.M{"firstName": "joe"}, types.M{"fullName.a": "joe"}}},
+			wantErr: errs.E(errs.InvalidQuery, "Cannot query on Computed field: fullName.a"),
+		},
+	}
+	for _, tt := range tests {
+		if err := validateComputedQuery(tt.query, computed); !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%q. validateComputedQuery() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_validateComputedSort(t *testing.T) {
+	computed := map[string]string{"fullName": "fullName"}
+	err := validateComputedSort([]string{"firstName", "objectId"}, computed)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	/*****************************************************************/
+	err = validateComputedSort([]string{"-fullName", "objectId"}, computed)
+	expect := errs.E(errs.InvalidKeyName, "Cannot sort by Computed field: fullName")
+	if reflect.DeepEqual(err, expect) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+}
+
+func Test_computedProjection(t *testing.T) {
+	cloud.Computed("fullName", []string{"firstName", "lastName"}, func(object types.M) interface{} {
+		return utils.S(object["firstName"]) + " " + utils.S(object["lastName"])
+	})
+	defer cloud.RemoveComputed("fullName")
+	computed := map[string]string{"fullName": "fullName", "initials": "initials"}
+
+	options := types.M{}
+	fields, extra := computedProjection(options, computed)
+	if reflect.DeepEqual(fields, []string{"fullName", "initials"}) == false || extra != nil || options["keys"] != nil {
+		t.Error("expect:", []string{"fullName", "initials"}, "result:", fields, extra, options)
+	}
+	/*****************************************************************/
+	options = types.M{"keys": []string{"lastName", "fullName"}}
+	fields, extra = computedProjection(options, computed)
+	if reflect.DeepEqual(fields, []string{"fullName"}) == false || reflect.DeepEqual(extra, []string{"firstName"}) == false {
+		t.Error("expect:", []string{"fullName"}, []string{"firstName"}, "result:", fields, extra)
+	}
+	if reflect.DeepEqual(options["keys"], []string{"lastName", "firstName"}) == false {
+		t.Error("expect:", []string{"lastName", "firstName"}, "result:", options["keys"])
+	}
+	/*****************************************************************/
+	options = types.M{"keys": []string{"initials"}}
+	fields, extra = computedProjection(options, computed)
+	if reflect.DeepEqual(fields, []string{"initials"}) == false || extra != nil || reflect.DeepEqual(options["keys"], []string{"objectId"}) == false {
+		t.Error("expect:", []string{"initials"}, "result:", fields, extra, options)
+	}
+	/*****************************************************************/
+	object := types.M{"objectId": "1001", "firstName": "Sean", "lastName": "Plott"}
+	applyComputedFields(object, computed, []string{"fullName", "initials"}, []string{"firstName"})
+	expect := types.M{"objectId": "1001", "lastName": "Plott", "fullName": "Sean Plott"}
+	if reflect.DeepEqual(object, expect) == false {
+		t.Error("expect:", expect, "result:", object)
+	}
+}
@@ -2,13 +2,18 @@
 package orm
 
 import (
+	"math/rand"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/okobsamoht/talisman/cache"
 	"github.com/okobsamoht/talisman/config"
 	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/logger"
 	"github.com/okobsamoht/talisman/storage"
 	"github.com/okobsamoht/talisman/storage/mongo"
 	"github.com/okobsamoht/talisman/storage/postgres"
@@ -24,21 +29,66 @@ var Adapter storage.Adapter
 
 var schemaCache *cache.SchemaCache
 var schemaPromise *Schema
+var schemaRefreshStop chan struct{}
 
-// init 初始化 Mongo 适配器
+// schemaChannel 通知各个实例 Schema 缓存失效的通道
+const schemaChannel = "schema"
+
+// init 订阅 Schema 缓存失效通知，数据库在 Init 或 InitOrm 中连接，导入包时不连接数据库
 func init() {
+	cache.Subscribe(schemaChannel, func(message string) {
+		invalidateLocalSchemaCache()
+	})
+}
+
+// newSchemaCache 根据当前配置创建 Schema 缓存
+func newSchemaCache() *cache.SchemaCache {
+	c := cache.NewSchemaCache(config.TConfig.SchemaCacheTTL, config.TConfig.EnableSingleSchemaCache)
+	c.SetStaleTTL(config.TConfig.SchemaCacheStaleTTL)
+	c.SetMaxEntries(config.TConfig.SchemaCacheMaxEntries)
+	for _, classTTL := range config.TConfig.SchemaCacheClassTTLs {
+		if className, ttl, ok := config.ParseSchemaCacheClassTTL(classTTL); ok {
+			c.SetClassTTL(className, ttl)
+		}
+	}
+	return c
+}
+
+// Init 根据当前配置连接数据库，并创建数据库操作对象，已有的连接会被关闭
+// 嵌入模式下修改配置后需要重新调用
+func Init() {
+	if Adapter != nil {
+		Adapter.HandleShutdown()
+	}
 	if config.TConfig.DatabaseType == "MongoDB" {
-		Adapter = mongo.NewMongoAdapter("talisman", storage.OpenMongoDB())
+		Adapter = newMongoAdapter()
 	} else if config.TConfig.DatabaseType == "PostgreSQL" {
-		Adapter = postgres.NewPostgresAdapter("talisman", storage.OpenPostgreSQL())
+		adapter := postgres.NewPostgresAdapter("talisman", storage.OpenPostgreSQL())
+		adapter.SetStmtCacheSize(config.TConfig.PostgresStmtCacheSize)
+		if err := adapter.SetRowLevelSecurity(config.TConfig.PostgresRowLevelSecurity); err != nil {
+			logger.Error("PostgresRowLevelSecurity is disabled:", err)
+		}
+		adapter.SetReadReplicas(storage.OpenPostgreSQLReplicas(), time.Duration(config.TConfig.PostgresReplicaMaxLag)*time.Second)
+		Adapter = adapter
 	} else {
 		// 默认连接 MongoDB
-		Adapter = mongo.NewMongoAdapter("talisman", storage.OpenMongoDB())
+		Adapter = newMongoAdapter()
+	}
+	if c, ok := Adapter.(storage.ClassCacher); ok {
+		c.SetClassCacheTTL(time.Duration(config.TConfig.ClassCacheTTL) * time.Second)
 	}
-	schemaCache = cache.NewSchemaCache(config.TConfig.SchemaCacheTTL, config.TConfig.EnableSingleSchemaCache)
+	schemaCache = newSchemaCache()
+	schemaPromise = nil
 	TalismanDBController = &DBController{}
 }
 
+// newMongoAdapter 连接 MongoDB ，单次操作的超时时间为 MongoSocketTimeout
+func newMongoAdapter() storage.Adapter {
+	adapter := mongo.NewMongoAdapter("talisman", storage.OpenMongoDB())
+	adapter.SetOperationTimeout(time.Duration(config.TConfig.MongoSocketTimeout) * time.Second)
+	return adapter
+}
+
 // DBController 数据库操作类
 type DBController struct {
 }
@@ -55,7 +105,12 @@ func (d *DBController) PurgeCollection(className string) error {
 	if err != nil {
 		return err
 	}
-	return Adapter.DeleteObjectsByQuery(className, sch, types.M{})
+	err = Adapter.DeleteObjectsByQuery(className, sch, types.M{})
+	if err != nil {
+		return errs.FromAdapter(err)
+	}
+	clearObjectCache()
+	return nil
 }
 
 // Find 从指定表中查询数据，查询到的数据放入 list 中
@@ -109,30 +164,19 @@ func (d *DBController) Find(className string, query, options types.M) (types.S,
 		parseFormatSchema["fields"] = types.M{}
 	}
 
+	computed := computedFieldsOf(utils.M(parseFormatSchema["fields"]))
 	if keys, ok := options["sort"].([]string); ok {
-		for i, key := range keys {
-			// sort 中的 key ，如果是要按倒序排列，则会加前缀 "-" ，所以要对其进行处理
-			var prefix string
-			if strings.HasPrefix(key, "-") {
-				prefix = "-"
-				key = key[1:]
-			}
-
-			if key == "_created_at" {
-				key = "createdAt"
-			} else if key == "_updated_at" {
-				key = "updatedAt"
-			}
-
-			if match, _ := regexp.MatchString(`^authData\.([a-zA-Z0-9_]+)\.id$`, key); match {
-				return nil, errs.E(errs.InvalidKeyName, "Cannot sort by "+key)
-			}
-
-			if fieldNameIsValid(key) == false {
-				return nil, errs.E(errs.InvalidKeyName, "Invalid field name: "+key)
-			}
-
-			keys[i] = prefix + key
+		var fields types.M
+		if classExists {
+			fields = utils.M(parseFormatSchema["fields"])
+		}
+		keys, err = normalizeSort(keys, fields)
+		if err != nil {
+			return nil, err
+		}
+		err = validateComputedSort(keys, computed)
+		if err != nil {
+			return nil, err
 		}
 		options["sort"] = keys
 	}
@@ -169,20 +213,43 @@ func (d *DBController) Find(className string, query, options types.M) (types.S,
 		query = addReadACL(query, aclGroup)
 	}
 
+	// 排除已过期但尚未被删除的对象
+	if config.TConfig.TTLFilterExpiredObjects {
+		if fieldName := schema.ttlField(className); fieldName != "" {
+			query = addTTLFilter(query, fieldName, time.Now())
+		}
+	}
+
 	err = validateQuery(query)
 	if err != nil {
 		return nil, err
 	}
+	err = validateComputedQuery(query, computed)
+	if err != nil {
+		return nil, err
+	}
 
 	// 获取 count
 	if options["count"] != nil {
 		if classExists == false {
 			return types.S{0}, nil
 		}
+		// 没有查询条件时，可以使用数据库的统计信息估算数量
+		if estimate, ok := options["estimate"].(bool); ok && estimate && len(query) == 0 {
+			if counter, ok := Adapter.(storage.EstimatedCounter); ok {
+				count, err := counter.EstimatedCount(className)
+				if err != nil {
+					return nil, err
+				}
+				return types.S{count}, nil
+			}
+		}
+		start := time.Now()
 		count, err := Adapter.Count(className, parseFormatSchema, query)
 		if err != nil {
-			return nil, err
+			return nil, errs.FromAdapter(err)
 		}
+		recordSlowQuery(className, parseFormatSchema, query, nil, start)
 		return types.S{count}, nil
 	}
 
@@ -190,20 +257,105 @@ func (d *DBController) Find(className string, query, options types.M) (types.S,
 		return types.S{}, nil
 	}
 
+	// 限制客户端查询的结果大小，避免读取包含大量 Object 或 Array 数据的类时占用过多内存
+	if isMaster == false {
+		if config.TConfig.MaxQueryResultRows > 0 {
+			options["maxRows"] = config.TConfig.MaxQueryResultRows
+		}
+		if config.TConfig.MaxQueryResultBytes > 0 {
+			options["maxBytes"] = config.TConfig.MaxQueryResultBytes
+		}
+	}
+
+	// 计算字段不在数据库中，替换为计算函数依赖的字段
+	computedKeys, extraKeys := computedProjection(options, computed)
+
 	// 执行查询操作
-	objects, err := Adapter.Find(className, parseFormatSchema, query, options)
+	start := time.Now()
+	objects, err := findWithObjectCache(className, parseFormatSchema, query, options)
 	if err != nil {
 		return nil, err
 	}
+	sortKeys, _ := options["sort"].([]string)
+	recordSlowQuery(className, parseFormatSchema, query, sortKeys, start)
+	// 按请求的语言解析 LocalizedString 字段
+	var localizedFields, locales []string
+	if locale, ok := options["locale"].(string); ok && locale != "" {
+		localizedFields = localizedStringFieldsOf(utils.M(parseFormatSchema["fields"]))
+		locales = localeFallbacks(locale, config.TConfig.DefaultLocales)
+	}
 	results := types.S{}
 	for _, object := range objects {
 		object = untransformObjectACL(object)
+		applyComputedFields(object, computed, computedKeys, extraKeys)
+		resolveLocalizedStrings(object, localizedFields, locales)
 		result := filterSensitiveData(isMaster, aclGroup, className, object)
 		results = append(results, result)
 	}
 	return results, nil
 }
 
+// normalizeSort 校验并整理排序字段，倒序的字段带有前缀 "-"
+// 字段必须存在于 fields 中， fields 为 nil 时不校验字段是否存在
+// 可以使用 a.b 形式的路径对 Object 字段的子字段排序
+// 重复的字段以第一次出现时的顺序为准，最后追加 objectId ，保证排序值相同的对象顺序稳定
+func normalizeSort(keys []string, fields types.M) ([]string, error) {
+	result := []string{}
+	seen := map[string]bool{}
+	for _, key := range keys {
+		// sort 中的 key ，如果是要按倒序排列，则会加前缀 "-" ，所以要对其进行处理
+		var prefix string
+		if strings.HasPrefix(key, "-") {
+			prefix = "-"
+			key = key[1:]
+		}
+		if key == "" {
+			continue
+		}
+
+		if key == "_created_at" {
+			key = "createdAt"
+		} else if key == "_updated_at" {
+			key = "updatedAt"
+		}
+
+		if match, _ := regexp.MatchString(`^authData\.([a-zA-Z0-9_]+)\.id$`, key); match {
+			return nil, errs.E(errs.InvalidKeyName, "Cannot sort by "+key)
+		}
+
+		path := strings.Split(key, ".")
+		for _, name := range path {
+			if fieldNameIsValid(name) == false {
+				return nil, errs.E(errs.InvalidKeyName, "Invalid field name: "+key)
+			}
+		}
+
+		if fields != nil {
+			tp := utils.M(fields[path[0]])
+			if tp == nil {
+				return nil, errs.E(errs.InvalidKeyName, "Cannot sort by unknown field: "+key)
+			}
+			fieldType := utils.S(tp["type"])
+			if fieldType == "Relation" || fieldType == "GeoPoint" {
+				return nil, errs.E(errs.InvalidKeyName, "Cannot sort by "+fieldType+" field: "+key)
+			}
+			if len(path) > 1 && fieldType != "Object" && fieldType != "LocalizedString" {
+				return nil, errs.E(errs.InvalidKeyName, "Cannot sort by "+key+", "+path[0]+" is not an Object field")
+			}
+		}
+
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, prefix+key)
+	}
+	if len(result) > 0 && seen["objectId"] == false {
+		result = append(result, "objectId")
+	}
+	return result, nil
+}
+
 // Destroy 从指定表中删除数据
 func (d *DBController) Destroy(className string, query types.M, options types.M) error {
 	if query == nil {
@@ -212,6 +364,7 @@ func (d *DBController) Destroy(className string, query types.M, options types.M)
 	if options == nil {
 		options = types.M{}
 	}
+	objectID := utils.S(query["objectId"])
 	isMaster := false
 	aclGroup := []string{}
 	if acl, ok := options["acl"]; ok {
@@ -260,12 +413,85 @@ func (d *DBController) Destroy(className string, query types.M, options types.M)
 		if className == "_Session" && errs.GetErrorCode(err) == errs.ObjectNotFound {
 			return nil
 		}
-		return err
+		return errs.FromAdapter(err)
+	}
+	if objectID != "" {
+		delObjectCache(className, objectID)
+	} else {
+		clearClassObjectCache(className)
 	}
 
 	return nil
 }
 
+// FindOneAndDelete 原子地删除符合条件的一个对象并返回被删除的对象，用于从队列中取出任务等场景
+// 并发调用时每个对象只会被一个调用取得，没有符合条件的对象时返回空对象
+// options 中的参数包括：acl、sort
+func (d *DBController) FindOneAndDelete(className string, query, options types.M) (types.M, error) {
+	deleter, ok := Adapter.(storage.FindOneAndDeleter)
+	if ok == false {
+		return nil, errs.E(errs.OperationForbidden, "FindOneAndDelete is not supported by the database adapter.")
+	}
+	if query == nil {
+		query = types.M{}
+	}
+	if options == nil {
+		options = types.M{}
+	}
+	isMaster := false
+	aclGroup := []string{}
+	if acl, ok := options["acl"]; ok {
+		if v, ok := acl.([]string); ok {
+			aclGroup = v
+		}
+	} else {
+		isMaster = true
+	}
+
+	schema := d.LoadSchema(nil)
+	if isMaster == false {
+		err := schema.validatePermission(className, aclGroup, "delete")
+		if err != nil {
+			return nil, err
+		}
+		query = d.addPointerPermissions(schema, className, "delete", query, aclGroup)
+		if query == nil {
+			return types.M{}, nil
+		}
+		query = addWriteACL(query, aclGroup)
+	}
+
+	err := validateQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	parseFormatSchema, err := schema.GetOneSchema(className, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(parseFormatSchema) == 0 {
+		parseFormatSchema["fields"] = types.M{}
+	}
+
+	deleteOptions := types.M{}
+	if sort, ok := options["sort"].([]string); ok {
+		deleteOptions["sort"] = sort
+	}
+	result, err := deleter.FindOneAndDelete(className, parseFormatSchema, query, deleteOptions)
+	if err != nil {
+		return nil, errs.FromAdapter(err)
+	}
+	if len(result) == 0 {
+		return types.M{}, nil
+	}
+	delObjectCache(className, utils.S(result["objectId"]))
+
+	result = untransformObjectACL(result)
+	result = filterSensitiveData(isMaster, aclGroup, className, result)
+	return result, nil
+}
+
 var specialKeysForUpdate = map[string]bool{
 	"_hashed_password":               true,
 	"_perishable_token":              true,
@@ -352,22 +578,36 @@ func (d *DBController) Update(className string, query, update, options types.M,
 		sch["fields"] = types.M{}
 	}
 
-	for fieldName, v := range update {
-		if match, _ := regexp.MatchString(`^authData\.([a-zA-Z0-9_]+)\.id$`, fieldName); match {
-			return nil, errs.E(errs.InvalidKeyName, "Invalid field name for update: "+fieldName)
-		}
-		fieldName = strings.Split(fieldName, ".")[0]
-		if fieldNameIsValid(fieldName) == false && specialKeysForUpdate[fieldName] == false {
-			return nil, errs.E(errs.InvalidKeyName, "Invalid field name for update: "+fieldName)
-		}
+	err = validateUpdateKeys(update)
+	if err != nil {
+		return nil, err
+	}
+	err = validateAutoIncrementWrite(utils.M(sch["fields"]), update, isMaster)
+	if err != nil {
+		return nil, err
+	}
+	err = validateMasterOnlyWrite(schema.masterOnlyFields(className), update, isMaster)
+	if err != nil {
+		return nil, err
+	}
+	err = validateLocalizedStringWrite(utils.M(sch["fields"]), update)
+	if err != nil {
+		return nil, err
+	}
+	query, err = addUpdateMatchingQuery(query, update)
+	if err != nil {
+		return nil, err
+	}
 
-		if updateOperation := utils.M(v); updateOperation != nil {
-			for innerKey := range updateOperation {
-				if strings.Index(innerKey, "$") > -1 || strings.Index(innerKey, ".") > -1 {
-					return nil, errs.E(errs.InvalidNestedKey, "Nested keys should not contain the '$' or '.' characters")
-				}
-			}
-		}
+	// 先写入 Relation 操作，对象更新失败时撤销，计数字段与对象一起更新
+	objectID := utils.S(originalQuery["objectId"])
+	relations, err := d.applyRelationUpdates(className, objectID, relationUpdates)
+	if err != nil {
+		return nil, err
+	}
+	stripRelationUpdates(update)
+	if many == false {
+		mergeRelationCounters(update, relations.deltas)
 	}
 
 	update = transformObjectACL(update)
@@ -376,45 +616,284 @@ func (d *DBController) Update(className string, query, update, options types.M,
 	if many {
 		err := Adapter.UpdateObjectsByQuery(className, sch, query, update)
 		if err != nil {
-			return nil, err
+			d.rollbackRelationUpdates(relations)
+			return nil, errs.FromAdapter(err)
 		}
 		result = types.M{}
+		clearClassObjectCache(className)
+		// 批量更新时只修改 objectId 对应对象的计数字段
+		err = d.updateRelationCounters(className, objectID, relations.deltas)
+		if err != nil {
+			return nil, err
+		}
 	} else if upsert {
 		err := Adapter.UpsertOneObject(className, sch, query, update)
 		if err != nil {
-			return nil, err
+			d.rollbackRelationUpdates(relations)
+			return nil, errs.FromAdapter(err)
 		}
 		result = types.M{}
+		// 无法得知插入或者更新的是哪个对象，使该类已缓存的对象全部失效
+		clearClassObjectCache(className)
 	} else {
 		var err error
 		result, err = Adapter.FindOneAndUpdate(className, sch, query, update)
 		if err != nil {
-			return nil, err
+			d.rollbackRelationUpdates(relations)
+			return nil, errs.FromAdapter(err)
+		}
+		// 按 username 等其他字段更新时查询条件中没有 objectId ，使用更新后的对象的 objectId
+		if id := utils.S(result["objectId"]); id != "" {
+			putObjectCache(className, id, result)
+		} else if objectID != "" {
+			delObjectCache(className, objectID)
+		} else {
+			clearClassObjectCache(className)
 		}
 	}
 
 	// 不处理 many 、 upsert 时的操作结果，仅处理 FindOneAndUpdate 的结果
 	if many == false && upsert == false && len(result) == 0 {
+		d.rollbackRelationUpdates(relations)
 		return nil, errs.E(errs.ObjectNotFound, "Object not found.")
 	}
 
-	err = d.handleRelationUpdates(className, utils.S(originalQuery["objectId"]), update, relationUpdates)
-	if err != nil {
-		return nil, err
-	}
-
 	if skipSanitization {
 		return result, nil
 	}
 
 	// 返回经过修改的字段
-	response := sanitizeDatabaseResult(originalUpdate, result)
+	computedFields, _ := options["computedFields"].([]string)
+	response := sanitizeDatabaseResult(originalUpdate, result, computedFields)
 
 	return response, nil
 }
 
-// sanitizeDatabaseResult 处理数据库返回结果
-func sanitizeDatabaseResult(originalObject, result types.M) types.M {
+// validateUpdateKeys 校验更新数据中的字段名
+func validateUpdateKeys(update types.M) error {
+	for fieldName, v := range update {
+		if match, _ := regexp.MatchString(`^authData\.([a-zA-Z0-9_]+)\.id$`, fieldName); match {
+			return errs.E(errs.InvalidKeyName, "Invalid field name for update: "+fieldName)
+		}
+		fieldName = strings.Split(fieldName, ".")[0]
+		if fieldNameIsValid(fieldName) == false && specialKeysForUpdate[fieldName] == false {
+			return errs.E(errs.InvalidKeyName, "Invalid field name for update: "+fieldName)
+		}
+
+		if updateOperation := utils.M(v); updateOperation != nil {
+			for innerKey := range updateOperation {
+				if strings.Index(innerKey, "$") > -1 || strings.Index(innerKey, ".") > -1 {
+					return errs.E(errs.InvalidNestedKey, "Nested keys should not contain the '$' or '.' characters")
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// addUpdateMatchingQuery 校验 UpdateMatching 操作，并把 match 条件以 $elemMatch 的形式添加到查询条件中，
+// 只更新数组中存在符合条件元素的对象，一次更新中只能包含一个 UpdateMatching 操作
+func addUpdateMatchingQuery(query, update types.M) (types.M, error) {
+	var matchQuery types.M
+	for key, v := range update {
+		op := utils.M(v)
+		if op == nil || utils.S(op["__op"]) != "UpdateMatching" {
+			continue
+		}
+		if matchQuery != nil {
+			return nil, errs.E(errs.InvalidJSON, "only one UpdateMatching operator is allowed in an update")
+		}
+		if strings.Index(key, ".") > -1 {
+			return nil, errs.E(errs.InvalidKeyName, "UpdateMatching can not be applied to nested field: "+key)
+		}
+		match := utils.M(op["match"])
+		if len(match) == 0 {
+			return nil, errs.E(errs.InvalidJSON, "match must be an object")
+		}
+		set := utils.M(op["set"])
+		if len(set) == 0 {
+			return nil, errs.E(errs.InvalidJSON, "fields to set must be an object")
+		}
+		for k := range set {
+			if k == "" || strings.Index(k, "$") > -1 || strings.Index(k, ".") > -1 {
+				return nil, errs.E(errs.InvalidNestedKey, "Nested keys should not contain the '$' or '.' characters")
+			}
+		}
+		matchQuery = types.M{key: types.M{"$elemMatch": match}}
+	}
+	if matchQuery == nil {
+		return query, nil
+	}
+	return types.M{"$and": types.S{query, matchQuery}}, nil
+}
+
+// Upsert 更新符合 where 的一个对象，不存在时创建新对象，返回更新或者创建后的对象，以及是否创建了新对象
+// 新对象包含 where 中的相等条件与 data 中的字段，原子操作按照创建对象时的规则处理， where 中指定了 objectId 时使用该 objectId
+// options 中的参数包括：acl ，非 Master 权限时需要同时具有 update 与 create 权限，
+// 符合条件的对象存在但当前用户不可修改时返回 ObjectNotFound ，不会创建新对象
+func (d *DBController) Upsert(className string, where, data, options types.M) (types.M, bool, error) {
+	if len(where) == 0 {
+		return nil, false, errs.E(errs.InvalidQuery, "Upsert requires a query.")
+	}
+	upserter, ok := Adapter.(storage.Upserter)
+	if ok == false {
+		return nil, false, errs.E(errs.OperationForbidden, "Upsert is not supported by the database adapter.")
+	}
+	if options == nil {
+		options = types.M{}
+	}
+	if data == nil {
+		data = types.M{}
+	}
+
+	isMaster := false
+	aclGroup := []string{}
+	if acl, ok := options["acl"]; ok {
+		if v, ok := acl.([]string); ok {
+			aclGroup = v
+		}
+	} else {
+		isMaster = true
+	}
+
+	err := d.validateClassName(className)
+	if err != nil {
+		return nil, false, err
+	}
+	schema := d.LoadSchema(nil)
+	if isMaster == false {
+		for _, operation := range []string{"update", "create"} {
+			err := schema.validatePermission(className, aclGroup, operation)
+			if err != nil {
+				return nil, false, err
+			}
+		}
+	}
+	err = d.ValidateObject(className, data, where, options)
+	if err != nil {
+		return nil, false, err
+	}
+	err = schema.EnforceClassExists(className)
+	if err != nil {
+		return nil, false, err
+	}
+	schema.reloadData(nil)
+	sch, err := schema.GetOneSchema(className, true, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	adapterSchema := convertSchemaToAdapterSchema(sch)
+
+	update := utils.CopyMap(data)
+	delete(update, "objectId")
+	delete(update, "createdAt")
+	// 新建对象的 objectId 在写入之后才能确定， Relation 操作在对象写入之后处理
+	relationUpdates := d.collectRelationUpdates(className, "", update)
+	stripRelationUpdates(update)
+	err = validateUpdateKeys(update)
+	if err != nil {
+		return nil, false, err
+	}
+	err = validateAutoIncrementWrite(utils.M(sch["fields"]), update, isMaster)
+	if err != nil {
+		return nil, false, err
+	}
+	err = validateLocalizedStringWrite(utils.M(sch["fields"]), update)
+	if err != nil {
+		return nil, false, err
+	}
+	now := utils.TimetoString(time.Now().UTC())
+	update["updatedAt"] = now
+
+	// 组装新对象： where 中的相等条件加上 data 中的字段
+	insert := types.M{}
+	for key, value := range where {
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+		isConstraint := false
+		for k := range utils.M(value) {
+			if strings.HasPrefix(k, "$") {
+				isConstraint = true
+				break
+			}
+		}
+		if isConstraint == false {
+			insert[key] = utils.DeepCopy(value)
+		}
+	}
+	for key, value := range update {
+		insert[key] = utils.DeepCopy(value)
+	}
+	err = flattenUpdateOperatorsForCreate(insert)
+	if err != nil {
+		return nil, false, err
+	}
+	// 对象已存在时不会写入 insert ，分配的序列值不会被使用
+	err = assignAutoIncrementFields(className, utils.M(sch["fields"]), insert)
+	if err != nil {
+		return nil, false, err
+	}
+	if objectID, ok := where["objectId"].(string); ok && objectID != "" {
+		insert["objectId"] = objectID
+	} else {
+		insert["objectId"] = utils.CreateObjectID()
+	}
+	insert["createdAt"] = types.M{"__type": "Date", "iso": now}
+	insert["updatedAt"] = types.M{"__type": "Date", "iso": now}
+
+	insert = transformObjectACL(insert)
+	transformAuthData(className, insert, sch)
+	update = transformObjectACL(update)
+	transformAuthData(className, update, sch)
+
+	query := utils.CopyMap(where)
+	if isMaster == false {
+		query = d.addPointerPermissions(schema, className, "update", query, aclGroup)
+		if query == nil {
+			return nil, false, errs.E(errs.ObjectNotFound, "Object not found.")
+		}
+		// 对象已经存在但不可修改时，不能创建新对象
+		count, err := Adapter.Count(className, adapterSchema, where)
+		if err != nil {
+			return nil, false, errs.FromAdapter(err)
+		}
+		query = addWriteACL(query, aclGroup)
+		if count > 0 {
+			writable, err := Adapter.Count(className, adapterSchema, query)
+			if err != nil {
+				return nil, false, errs.FromAdapter(err)
+			}
+			if writable == 0 {
+				return nil, false, errs.E(errs.ObjectNotFound, "Object not found.")
+			}
+		}
+	}
+	err = validateQuery(query)
+	if err != nil {
+		return nil, false, err
+	}
+
+	result, created, err := upserter.FindOneAndUpsert(className, adapterSchema, query, update, insert)
+	if err != nil {
+		return nil, false, errs.FromAdapter(err)
+	}
+	objectID := utils.S(result["objectId"])
+	delObjectCache(className, objectID)
+
+	err = d.handleRelationUpdates(className, objectID, types.M{}, relationUpdates)
+	if err != nil {
+		return nil, false, err
+	}
+
+	result = untransformObjectACL(result)
+	result = filterSensitiveData(isMaster, aclGroup, className, result)
+	return result, created, nil
+}
+
+// sanitizeDatabaseResult 处理数据库返回结果，只返回客户端无法自行得知的字段：
+// 原子操作的结果、服务端设置的 updatedAt ，以及 computedFields 中由服务端修改过的字段，如 beforeSave 中修改的字段
+func sanitizeDatabaseResult(originalObject, result types.M, computedFields []string) types.M {
 	response := types.M{}
 	if originalObject == nil || result == nil {
 		return response
@@ -424,19 +903,55 @@ func sanitizeDatabaseResult(originalObject, result types.M) types.M {
 	for key, value := range originalObject {
 		if keyUpdate := utils.M(value); keyUpdate != nil {
 			if op := utils.S(keyUpdate["__op"]); op != "" {
-				if op == "Add" || op == "AddUnique" || op == "Remove" || op == "Increment" {
-					// 只把操作的字段放入返回结果中
-					if v, ok := result[key]; ok {
-						response[key] = v
-					}
+				if op == "Add" || op == "AddUnique" || op == "Remove" || op == "Increment" || op == "UpdateMatching" {
+					// 只把操作的字段放入返回结果中，操作可能作用于 a.b 这样的子字段
+					expandResultOnKeyPath(response, key, result)
 				}
 			}
 		}
 	}
 
+	if _, ok := originalObject["updatedAt"]; ok {
+		if v, ok := result["updatedAt"]; ok {
+			response["updatedAt"] = v
+		}
+	}
+	for _, key := range computedFields {
+		if _, ok := response[key]; ok {
+			continue
+		}
+		if v, ok := result[key]; ok {
+			response[key] = v
+		}
+	}
+
 	return response
 }
 
+// expandResultOnKeyPath 把 result 中 key 对应的值放入 response ， key 为 a.b 时放入 response["a"]["b"]
+// result 中不存在该值时不做处理
+func expandResultOnKeyPath(response types.M, key string, result types.M) {
+	if strings.Index(key, ".") < 0 {
+		if v, ok := result[key]; ok {
+			response[key] = v
+		}
+		return
+	}
+	path := strings.SplitN(key, ".", 2)
+	sub := utils.M(result[path[0]])
+	if sub == nil {
+		return
+	}
+	next := types.M(utils.M(response[path[0]]))
+	if next == nil {
+		next = types.M{}
+	}
+	expandResultOnKeyPath(next, path[1], sub)
+	if len(next) > 0 {
+		response[path[0]] = next
+	}
+}
+
 // Create 创建对象
 func (d *DBController) Create(className string, object, options types.M) error {
 	if options == nil {
@@ -487,29 +1002,56 @@ func (d *DBController) Create(className string, object, options types.M) error {
 			return err
 		}
 	}
-
-	err = schema.EnforceClassExists(className)
+
+	err = schema.EnforceClassExists(className)
+	if err != nil {
+		return err
+	}
+
+	schema.reloadData(nil)
+
+	sch, err := schema.GetOneSchema(className, true, nil)
+	if err != nil {
+		return err
+	}
+
+	transformAuthData(className, object, sch)
+
+	err = validateAutoIncrementWrite(utils.M(sch["fields"]), object, isMaster)
+	if err != nil {
+		return err
+	}
+	// 类的默认值写入的字段不需要 Master 权限
+	err = validateMasterOnlyWrite(schema.masterOnlyFields(className), withoutDefaultFields(object, options), isMaster)
+	if err != nil {
+		return err
+	}
+	err = validateLocalizedStringWrite(utils.M(sch["fields"]), object)
 	if err != nil {
 		return err
 	}
-
-	schema.reloadData(nil)
-
-	sch, err := schema.GetOneSchema(className, true, nil)
+	err = assignAutoIncrementFields(className, utils.M(sch["fields"]), object)
 	if err != nil {
 		return err
 	}
 
-	transformAuthData(className, object, sch)
+	// 先写入 Relation 操作，对象创建失败时撤销，计数字段与对象一起写入
+	relations, err := d.applyRelationUpdates(className, utils.S(object["objectId"]), relationUpdates)
+	if err != nil {
+		return err
+	}
+	stripRelationUpdates(object)
+	mergeRelationCounters(object, relations.deltas)
 	flattenUpdateOperatorsForCreate(object)
 
 	// 无需调用 sanitizeDatabaseResult
 	err = Adapter.CreateObject(className, convertSchemaToAdapterSchema(sch), object)
 	if err != nil {
-		return err
+		d.rollbackRelationUpdates(relations)
+		return errs.FromAdapter(err)
 	}
 
-	return d.handleRelationUpdates(className, "", object, relationUpdates)
+	return nil
 }
 
 // validateClassName 校验表名是否合法
@@ -553,12 +1095,9 @@ func (d *DBController) collectRelationUpdates(className, objectID string, update
 		p := utils.S(opMap["__op"])
 		if p == "AddRelation" {
 			ops = append(ops, types.M{"key": key, "op": op})
-			delete(update, key)
 		} else if p == "RemoveRelation" {
 			ops = append(ops, types.M{"key": key, "op": op})
-			delete(update, key)
 		} else if p == "Batch" {
-			delete(update, key)
 			// 批处理 Relation 对象
 			if ops := utils.A(opMap["ops"]); ops != nil {
 				for _, x := range ops {
@@ -575,7 +1114,8 @@ func (d *DBController) collectRelationUpdates(className, objectID string, update
 	return ops
 }
 
-// handleRelationUpdates 处理 Relation 相关操作
+// handleRelationUpdates 写入 Relation 操作并更新计数字段，全部成功后从 update 中删除 Relation 操作
+// 用于对象已经写入数据库的情况，失败时撤销已写入的 _Join 记录
 func (d *DBController) handleRelationUpdates(className, objectID string, update types.M, ops []types.M) error {
 	if update == nil {
 		return nil
@@ -584,46 +1124,142 @@ func (d *DBController) handleRelationUpdates(className, objectID string, update
 		objectID = utils.S(update["objectId"])
 	}
 
+	relations, err := d.applyRelationUpdates(className, objectID, ops)
+	if err != nil {
+		return err
+	}
+	err = d.updateRelationCounters(className, objectID, relations.deltas)
+	if err != nil {
+		d.rollbackRelationUpdates(relations)
+		return err
+	}
+	stripRelationUpdates(update)
+	return nil
+}
+
+// stripRelationUpdates 从 update 中删除 Relation 操作，需要在 Relation 操作写入 _Join 表之后调用
+func stripRelationUpdates(update types.M) {
+	for key, v := range update {
+		if op := utils.M(v); op != nil {
+			switch utils.S(op["__op"]) {
+			case "AddRelation", "RemoveRelation", "Batch":
+				delete(update, key)
+			}
+		}
+	}
+}
+
+// relationMutation 一条 _Join 表记录的写入操作， add 为 false 时表示删除
+type relationMutation struct {
+	key       string
+	relatedID string
+	add       bool
+}
+
+// appliedRelations 已写入 _Join 表的记录，用于失败时撤销，以及更新计数字段
+type appliedRelations struct {
+	className string
+	objectID  string
+	applied   []relationMutation
+	deltas    map[string]int
+}
+
+// stageRelationUpdates 把 Relation 操作展开为 _Join 表记录的写入操作，
+// 跳过添加已存在的关系与删除不存在的关系，保证每条写入操作都可以准确撤销
+func (d *DBController) stageRelationUpdates(className, objectID string, ops []types.M) ([]relationMutation, error) {
+	mutations := []relationMutation{}
+	exists := map[string]bool{}
 	for _, subOp := range ops {
 		key := utils.S(subOp["key"])
-		op := subOp["op"]
-		if op == nil || utils.M(op) == nil || utils.M(op)["__op"] == nil {
+		opMap := utils.M(subOp["op"])
+		if opMap == nil {
 			continue
 		}
-		opMap := utils.M(op)
 		p := utils.S(opMap["__op"])
-		if p == "AddRelation" {
-			// 添加 Relation 对象
-			if objects := utils.A(opMap["objects"]); objects != nil {
-				for _, object := range objects {
-					if obj := utils.M(object); obj != nil {
-						if relationID := utils.S(obj["objectId"]); relationID != "" {
-							err := d.addRelation(key, className, objectID, relationID)
-							if err != nil {
-								return err
-							}
-						}
-					}
-				}
+		if p != "AddRelation" && p != "RemoveRelation" {
+			continue
+		}
+		add := p == "AddRelation"
+		for _, object := range utils.A(opMap["objects"]) {
+			relatedID := utils.S(utils.M(object)["objectId"])
+			if relatedID == "" {
+				continue
 			}
-		} else if p == "RemoveRelation" {
-			// 删除 Relation 对象
-			if objects := utils.A(opMap["objects"]); objects != nil {
-				for _, object := range objects {
-					if obj := utils.M(object); obj != nil {
-						if relationID := utils.S(obj["objectId"]); relationID != "" {
-							err := d.removeRelation(key, className, objectID, relationID)
-							if err != nil {
-								return err
-							}
-						}
-					}
+			id := key + ":" + relatedID
+			existed, ok := exists[id]
+			if ok == false {
+				var err error
+				existed, err = d.relationExists(key, className, objectID, relatedID)
+				if err != nil {
+					return nil, err
 				}
 			}
+			exists[id] = add
+			if existed != add {
+				mutations = append(mutations, relationMutation{key: key, relatedID: relatedID, add: add})
+			}
 		}
 	}
+	return mutations, nil
+}
 
-	return nil
+// applyRelationUpdates 写入 Relation 操作，任意一条记录写入失败时撤销已写入的记录，不会只写入一部分
+// 撤销同样失败时可能残留 _Join 记录，可通过 FindDanglingRelations 检查
+func (d *DBController) applyRelationUpdates(className, objectID string, ops []types.M) (*appliedRelations, error) {
+	relations := &appliedRelations{
+		className: className,
+		objectID:  objectID,
+		applied:   []relationMutation{},
+		deltas:    map[string]int{},
+	}
+	if len(ops) == 0 {
+		return relations, nil
+	}
+	mutations, err := d.stageRelationUpdates(className, objectID, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	// 设置了计数字段的 Relation ，统计实际新增与删除的关系数
+	counters := d.LoadSchema(nil).relationCounters(className)
+	for _, m := range mutations {
+		err := d.applyRelationMutation(className, objectID, m)
+		if err != nil {
+			d.rollbackRelationUpdates(relations)
+			return nil, err
+		}
+		relations.applied = append(relations.applied, m)
+		if counterField := utils.S(counters[m.key]); counterField != "" {
+			if m.add {
+				relations.deltas[counterField]++
+			} else {
+				relations.deltas[counterField]--
+			}
+		}
+	}
+	return relations, nil
+}
+
+// applyRelationMutation 写入一条 _Join 表记录
+func (d *DBController) applyRelationMutation(className, objectID string, m relationMutation) error {
+	if m.add {
+		return d.addRelation(m.key, className, objectID, m.relatedID)
+	}
+	return d.removeRelation(m.key, className, objectID, m.relatedID)
+}
+
+// rollbackRelationUpdates 按相反的顺序撤销已写入的 _Join 记录，尽量撤销所有记录
+func (d *DBController) rollbackRelationUpdates(relations *appliedRelations) {
+	if relations == nil {
+		return
+	}
+	for i := len(relations.applied) - 1; i >= 0; i-- {
+		m := relations.applied[i]
+		m.add = !m.add
+		d.applyRelationMutation(relations.className, relations.objectID, m)
+	}
+	relations.applied = []relationMutation{}
+	relations.deltas = map[string]int{}
 }
 
 var relationSchema = types.M{
@@ -640,7 +1276,7 @@ func (d *DBController) addRelation(key, fromClassName, fromID, toID string) erro
 		"owningId":  fromID,
 	}
 	className := "_Join:" + key + ":" + fromClassName
-	return Adapter.UpsertOneObject(className, relationSchema, doc, doc)
+	return errs.FromAdapter(Adapter.UpsertOneObject(className, relationSchema, doc, doc))
 }
 
 // removeRelation 把对象 id 从 _Join 表中删除，表名为 _Join:key:fromClassName
@@ -655,11 +1291,73 @@ func (d *DBController) removeRelation(key, fromClassName, fromID, toID string) e
 		if errs.GetErrorCode(err) == errs.ObjectNotFound {
 			return nil
 		}
-		return err
+		return errs.FromAdapter(err)
 	}
 	return nil
 }
 
+// CountRelated 返回对象 owningID 的 Relation 字段 key 中的对象数，直接统计 _Join 表，不查询目标对象，不校验目标对象的 ACL
+func (d *DBController) CountRelated(className, key, owningID string) (int, error) {
+	t := d.LoadSchema(nil).getExpectedType(className, key)
+	if t == nil || utils.S(t["type"]) != "Relation" {
+		return 0, errs.E(errs.InvalidKeyName, key+" is not a Relation field of "+className)
+	}
+	count, err := Adapter.Count(joinTableName(className, key), relationSchema, types.M{"owningId": owningID})
+	if err != nil {
+		return 0, errs.FromAdapter(err)
+	}
+	return count, nil
+}
+
+// RemoveRelatedObject 从类中所有对象的 Relation 字段 key 中移除 relatedID ，返回受影响的对象数， dryRun 为 true 时只返回数量
+func (d *DBController) RemoveRelatedObject(className, key, relatedID string, dryRun bool) (int, error) {
+	owning := d.owningIds(className, key, types.S{relatedID})
+	if len(owning) == 0 || dryRun {
+		return len(owning), nil
+	}
+	err := Adapter.DeleteObjectsByQuery(joinTableName(className, key), relationSchema, types.M{"relatedId": relatedID})
+	if err != nil && errs.GetErrorCode(err) != errs.ObjectNotFound {
+		return 0, errs.FromAdapter(err)
+	}
+	return len(owning), nil
+}
+
+// ReplaceRelatedObject 把类中所有对象的 Relation 字段 key 中的 fromID 替换为 toID ，返回受影响的对象数， dryRun 为 true 时只返回数量
+func (d *DBController) ReplaceRelatedObject(className, key, fromID, toID string, dryRun bool) (int, error) {
+	owning := d.owningIds(className, key, types.S{fromID})
+	if len(owning) == 0 || dryRun {
+		return len(owning), nil
+	}
+	for _, owningID := range owning {
+		if err := d.addRelation(key, className, utils.S(owningID), toID); err != nil {
+			return 0, err
+		}
+	}
+	err := Adapter.DeleteObjectsByQuery(joinTableName(className, key), relationSchema, types.M{"relatedId": fromID})
+	if err != nil && errs.GetErrorCode(err) != errs.ObjectNotFound {
+		return 0, errs.FromAdapter(err)
+	}
+	return len(owning), nil
+}
+
+// MoveRelatedObjects 把对象 fromID 的 Relation 字段 key 中的对象移动到对象 toID 的同一字段中，返回移动的对象数， dryRun 为 true 时只返回数量
+func (d *DBController) MoveRelatedObjects(className, key, fromID, toID string, dryRun bool) (int, error) {
+	related := d.relatedIds(className, key, fromID)
+	if len(related) == 0 || dryRun {
+		return len(related), nil
+	}
+	for _, relatedID := range related {
+		if err := d.addRelation(key, className, toID, utils.S(relatedID)); err != nil {
+			return 0, err
+		}
+	}
+	err := Adapter.DeleteObjectsByQuery(joinTableName(className, key), relationSchema, types.M{"owningId": fromID})
+	if err != nil && errs.GetErrorCode(err) != errs.ObjectNotFound {
+		return 0, errs.FromAdapter(err)
+	}
+	return len(related), nil
+}
+
 // ValidateObject 校验对象是否合法
 func (d *DBController) ValidateObject(className string, object, query, options types.M) error {
 	schema := d.LoadSchema(nil)
@@ -682,6 +1380,10 @@ func (d *DBController) ValidateObject(className string, object, query, options t
 		if err != nil {
 			return err
 		}
+		err = validateMasterOnlyWrite(schema.masterOnlyFields(className), withoutDefaultFields(object, options), isMaster)
+		if err != nil {
+			return err
+		}
 	}
 
 	err := schema.validateObject(className, object, query)
@@ -707,6 +1409,73 @@ func (d *DBController) LoadSchema(options types.M) *Schema {
 	return schemaPromise
 }
 
+// WarmUpSchemaCache 预加载所有表的 Schema 到缓存中，避免启动后的首个请求等待 GetAllClasses
+func (d *DBController) WarmUpSchemaCache() error {
+	schemas, err := Adapter.GetAllClasses()
+	if err != nil {
+		return errs.FromAdapter(err)
+	}
+	allSchemas := []types.M{}
+	for _, v := range schemas {
+		allSchemas = append(allSchemas, injectDefaultSchema(v))
+	}
+	schemaCache.Clear()
+	schemaCache.SetAllClasses(allSchemas)
+	schemaPromise = Load(Adapter, schemaCache, nil)
+	return nil
+}
+
+// StartSchemaCacheRefresh 在后台定时刷新 Schema 缓存
+// 每次刷新的间隔增加 0-10% 的随机抖动，避免多个实例同时查询数据库
+func (d *DBController) StartSchemaCacheRefresh(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	d.StopSchemaCacheRefresh()
+	stop := make(chan struct{})
+	schemaRefreshStop = stop
+	go func() {
+		for {
+			jitter := time.Duration(rand.Int63n(int64(interval)/10 + 1))
+			select {
+			case <-stop:
+				return
+			case <-time.After(interval + jitter):
+				d.WarmUpSchemaCache()
+			}
+		}
+	}()
+}
+
+// SchemaCacheStats 返回 Schema 缓存的加载统计
+func (d *DBController) SchemaCacheStats() cache.SchemaCacheStats {
+	return schemaCache.Stats()
+}
+
+// InvalidateSchemaCache 清除当前实例的 Schema 缓存，并通知其他实例清除
+// 缓存模块为 Redis 时通过 Redis 发送通知，其他缓存模块只清除当前实例，用于直接修改数据库中的类结构之后
+func (d *DBController) InvalidateSchemaCache() {
+	invalidateLocalSchemaCache()
+	cache.Publish(schemaChannel, "invalidate")
+}
+
+// invalidateLocalSchemaCache 清除当前实例的 Schema 缓存与适配器内部的类缓存，下次使用时重新加载
+func invalidateLocalSchemaCache() {
+	schemaCache.Clear()
+	schemaPromise = nil
+	if c, ok := Adapter.(storage.ClassCacher); ok {
+		c.InvalidateClassCache()
+	}
+}
+
+// StopSchemaCacheRefresh 停止后台刷新 Schema 缓存
+func (d *DBController) StopSchemaCacheRefresh() {
+	if schemaRefreshStop != nil {
+		close(schemaRefreshStop)
+		schemaRefreshStop = nil
+	}
+}
+
 // DeleteEverything 删除所有表数据，仅用于测试
 func (d *DBController) DeleteEverything() {
 	schemaCache.Clear()
@@ -753,6 +1522,9 @@ func (d *DBController) canAddField(schema *Schema, className string, object type
 	}
 
 	if len(newKeys) > 0 {
+		if schema.addFieldAllowed(className) == false {
+			return errs.E(errs.OperationForbidden, "Class "+className+" is locked, new fields can not be added.")
+		}
 		return schema.validatePermission(className, acl, "addField")
 	}
 
@@ -783,7 +1555,32 @@ func (d *DBController) canAddField(schema *Schema, className string, object type
 //     }
 // }
 // 已知父对象，查找子对象
+// 查询条件中所有的 $relatedTo 先统一并发查询 Join 表，再替换回查询条件中
 func (d *DBController) reduceRelationKeys(className string, query types.M) types.M {
+	fetcher := newJoinFetcher()
+	d.collectRelationKeys(query, fetcher)
+	fetcher.fetch()
+	return d.applyRelationKeys(className, query, fetcher)
+}
+
+// collectRelationKeys 收集查询条件中 $relatedTo 需要的 Join 表查询
+func (d *DBController) collectRelationKeys(query types.M, fetcher *joinFetcher) {
+	if query == nil {
+		return
+	}
+	if query["$or"] != nil {
+		for _, v := range utils.A(query["$or"]) {
+			d.collectRelationKeys(utils.M(v), fetcher)
+		}
+		return
+	}
+	if q, ok := relatedToJoinQuery(query["$relatedTo"]); ok {
+		fetcher.add(q)
+	}
+}
+
+// applyRelationKeys 使用 Join 表的查询结果替换 $relatedTo
+func (d *DBController) applyRelationKeys(className string, query types.M, fetcher *joinFetcher) types.M {
 	if query == nil {
 		return query
 	}
@@ -793,7 +1590,7 @@ func (d *DBController) reduceRelationKeys(className string, query types.M) types
 		subQuerys = utils.A(query["$or"])
 		for i, v := range subQuerys {
 			aQuery := utils.M(v)
-			subQuerys[i] = d.reduceRelationKeys(className, aQuery)
+			subQuerys[i] = d.applyRelationKeys(className, aQuery, fetcher)
 		}
 		query["$or"] = subQuerys
 		return query
@@ -801,38 +1598,47 @@ func (d *DBController) reduceRelationKeys(className string, query types.M) types
 
 	if r, ok := query["$relatedTo"]; ok {
 		delete(query, "$relatedTo")
-		relatedTo := utils.M(r)
-		if relatedTo == nil {
-			return query
-		}
-		key := utils.S(relatedTo["key"])
-		object := utils.M(relatedTo["object"])
-		if key == "" || object == nil {
+		q, ok := relatedToJoinQuery(r)
+		if ok == false {
 			return query
 		}
-		objClassName := utils.S(object["className"])
-		objID := utils.S(object["objectId"])
-		ids := d.relatedIds(objClassName, key, objID)
+		ids := fetcher.get(q)
 		query = d.addInObjectIdsIds(ids, query)
-		query = d.reduceRelationKeys(className, query)
+		query = d.applyRelationKeys(className, query, fetcher)
 	}
 
 	return query
 }
 
-// relatedIds 从 Join 表中查询 ids ，表名：_Join:key:className
-func (d *DBController) relatedIds(className, key, owningID string) types.S {
-	ids := types.S{}
-	results, err := Adapter.Find(joinTableName(className, key), relationSchema, types.M{"owningId": owningID}, types.M{})
-	if err != nil {
-		return ids
+// relatedToJoinQuery 将 $relatedTo 转换为 Join 表查询
+func relatedToJoinQuery(r interface{}) (joinQuery, bool) {
+	relatedTo := utils.M(r)
+	if relatedTo == nil {
+		return joinQuery{}, false
+	}
+	key := utils.S(relatedTo["key"])
+	object := utils.M(relatedTo["object"])
+	if key == "" || object == nil {
+		return joinQuery{}, false
 	}
+	q := joinQuery{
+		className: utils.S(object["className"]),
+		key:       key,
+		field:     "owningId",
+		ids:       types.S{utils.S(object["objectId"])},
+	}
+	return q, true
+}
 
-	for _, result := range results {
-		id := result["relatedId"]
-		ids = append(ids, id)
+// relatedIds 从 Join 表中查询 ids ，表名：_Join:key:className
+func (d *DBController) relatedIds(className, key, owningID string) types.S {
+	q := joinQuery{
+		className: className,
+		key:       key,
+		field:     "owningId",
+		ids:       types.S{owningID},
 	}
-	return ids
+	return q.find()
 }
 
 // joinTableName 组装用于 relation 的 Join 表
@@ -1003,7 +1809,40 @@ func (d *DBController) addNotInObjectIdsIds(ids types.S, query types.M) types.M
 // 例如 classA 中的 字段 key 为 relation<classB> 类型，查找 key 中包含指定 classB 对象的 classA
 // query = {"key":{"$in":[]}}
 // 已知子对象，查找父对象
+// 查询条件中所有的限制条件先统一并发查询 Join 表，再替换回查询条件中
 func (d *DBController) reduceInRelation(className string, query types.M, schema *Schema) types.M {
+	fetcher := newJoinFetcher()
+	d.collectInRelation(className, query, schema, fetcher)
+	fetcher.fetch()
+	return d.applyInRelation(className, query, schema, fetcher)
+}
+
+// collectInRelation 收集查询条件中作用于 relation 类型字段上的 Join 表查询
+func (d *DBController) collectInRelation(className string, query types.M, schema *Schema, fetcher *joinFetcher) {
+	if query == nil {
+		return
+	}
+	if query["$or"] != nil {
+		for _, v := range utils.A(query["$or"]) {
+			d.collectInRelation(className, utils.M(v), schema, fetcher)
+		}
+		return
+	}
+
+	for key, v := range query {
+		op := relationOperator(className, key, v, schema)
+		if op == nil {
+			continue
+		}
+		relatedIds, _ := relationConstraints(op)
+		for _, relatedID := range relatedIds {
+			fetcher.add(owningJoinQuery(className, key, relatedID))
+		}
+	}
+}
+
+// applyInRelation 使用 Join 表的查询结果替换 relation 类型字段上的限制条件
+func (d *DBController) applyInRelation(className string, query types.M, schema *Schema, fetcher *joinFetcher) types.M {
 	if query == nil {
 		return query
 	}
@@ -1013,118 +1852,236 @@ func (d *DBController) reduceInRelation(className string, query types.M, schema
 		ors = utils.A(query["$or"])
 		for i, v := range ors {
 			aQuery := utils.M(v)
-			ors[i] = d.reduceInRelation(className, aQuery, schema)
+			ors[i] = d.applyInRelation(className, aQuery, schema, fetcher)
 		}
 		query["$or"] = ors
 		return query
 	}
 
 	for key, v := range query {
-		op := utils.M(v)
-		if op != nil && (op["$in"] != nil || op["$ne"] != nil || op["$nin"] != nil || op["$eq"] != nil || utils.S(op["__type"]) == "Pointer") {
-			if schema == nil {
-				continue
-			}
-			// 只处理 relation 类型
-			t := schema.getExpectedType(className, key)
-			if t == nil || utils.S(t["type"]) != "Relation" {
-				continue
+		op := relationOperator(className, key, v, schema)
+		if op == nil {
+			continue
+		}
+		relatedIds, isNegation := relationConstraints(op)
+
+		delete(query, key)
+
+		// 应用所有限制条件
+		for i, relatedID := range relatedIds {
+			// 此处 relatedID 含有至少一个元素
+			// 从 Join 表中查找的 ids，替换查询条件
+			ids := fetcher.get(owningJoinQuery(className, key, relatedID))
+			if isNegation[i] {
+				query = d.addNotInObjectIdsIds(ids, query)
+			} else {
+				query = d.addInObjectIdsIds(ids, query)
 			}
+		}
+	}
 
-			// 取出所有限制条件
-			relatedIds := []types.S{}
-			isNegation := []bool{}
-			for constraintKey, value := range op {
-				if constraintKey == "objectId" {
-					if utils.S(value) != "" {
-						ids := types.S{value}
-						relatedIds = append(relatedIds, ids)
-						isNegation = append(isNegation, false)
-					}
-				} else if constraintKey == "$in" {
-					in := utils.A(value)
-					ids := types.S{}
-					for _, v := range in {
-						if r := utils.M(v); r != nil {
-							if utils.S(r["objectId"]) != "" {
-								ids = append(ids, r["objectId"])
-							}
-						}
-					}
-					// 只计算有效的 objectId
-					if len(ids) > 0 {
-						relatedIds = append(relatedIds, ids)
-						isNegation = append(isNegation, false)
-					}
-				} else if constraintKey == "$nin" {
-					nin := utils.A(value)
-					ids := types.S{}
-					for _, v := range nin {
-						if r := utils.M(v); r != nil {
-							if utils.S(r["objectId"]) != "" {
-								ids = append(ids, r["objectId"])
-							}
-						}
-					}
-					// 只计算有效的 objectId
-					if len(ids) > 0 {
-						relatedIds = append(relatedIds, ids)
-						isNegation = append(isNegation, true)
-					}
-				} else if constraintKey == "$ne" {
-					if ne := utils.M(value); ne != nil {
-						if utils.S(ne["objectId"]) != "" {
-							ids := types.S{ne["objectId"]}
-							relatedIds = append(relatedIds, ids)
-							isNegation = append(isNegation, true)
-						}
+	return query
+}
+
+// relationOperator 当 key 为 relation 类型字段，并且 v 为 $in $ne $nin $eq 或者 Pointer 时，返回 v
+func relationOperator(className, key string, v interface{}, schema *Schema) types.M {
+	op := utils.M(v)
+	if op == nil || schema == nil {
+		return nil
+	}
+	if op["$in"] == nil && op["$ne"] == nil && op["$nin"] == nil && op["$eq"] == nil && utils.S(op["__type"]) != "Pointer" {
+		return nil
+	}
+	// 只处理 relation 类型
+	t := schema.getExpectedType(className, key)
+	if t == nil || utils.S(t["type"]) != "Relation" {
+		return nil
+	}
+	return op
+}
+
+// relationConstraints 取出所有限制条件， isNegation 表示对应的限制条件是否为 $ne $nin
+func relationConstraints(op types.M) (relatedIds []types.S, isNegation []bool) {
+	relatedIds = []types.S{}
+	isNegation = []bool{}
+	for constraintKey, value := range op {
+		if constraintKey == "objectId" {
+			if utils.S(value) != "" {
+				ids := types.S{value}
+				relatedIds = append(relatedIds, ids)
+				isNegation = append(isNegation, false)
+			}
+		} else if constraintKey == "$in" {
+			in := utils.A(value)
+			ids := types.S{}
+			for _, v := range in {
+				if r := utils.M(v); r != nil {
+					if utils.S(r["objectId"]) != "" {
+						ids = append(ids, r["objectId"])
 					}
-				} else if constraintKey == "$eq" {
-					if eq := utils.M(value); eq != nil {
-						if utils.S(eq["objectId"]) != "" {
-							ids := types.S{eq["objectId"]}
-							relatedIds = append(relatedIds, ids)
-							isNegation = append(isNegation, false)
-						}
+				}
+			}
+			// 只计算有效的 objectId
+			if len(ids) > 0 {
+				relatedIds = append(relatedIds, ids)
+				isNegation = append(isNegation, false)
+			}
+		} else if constraintKey == "$nin" {
+			nin := utils.A(value)
+			ids := types.S{}
+			for _, v := range nin {
+				if r := utils.M(v); r != nil {
+					if utils.S(r["objectId"]) != "" {
+						ids = append(ids, r["objectId"])
 					}
 				}
 			}
-
-			delete(query, key)
-
-			// 应用所有限制条件
-			for i, relatedID := range relatedIds {
-				// 此处 relatedID 含有至少一个元素
-				// 从 Join 表中查找的 ids，替换查询条件
-				ids := d.owningIds(className, key, relatedID)
-				if isNegation[i] {
-					query = d.addNotInObjectIdsIds(ids, query)
-				} else {
-					query = d.addInObjectIdsIds(ids, query)
+			// 只计算有效的 objectId
+			if len(ids) > 0 {
+				relatedIds = append(relatedIds, ids)
+				isNegation = append(isNegation, true)
+			}
+		} else if constraintKey == "$ne" {
+			if ne := utils.M(value); ne != nil {
+				if utils.S(ne["objectId"]) != "" {
+					ids := types.S{ne["objectId"]}
+					relatedIds = append(relatedIds, ids)
+					isNegation = append(isNegation, true)
+				}
+			}
+		} else if constraintKey == "$eq" {
+			if eq := utils.M(value); eq != nil {
+				if utils.S(eq["objectId"]) != "" {
+					ids := types.S{eq["objectId"]}
+					relatedIds = append(relatedIds, ids)
+					isNegation = append(isNegation, false)
 				}
 			}
 		}
 	}
+	return
+}
 
-	return query
+// owningJoinQuery 查询 relatedIds 对应父对象的 Join 表查询
+func owningJoinQuery(className, key string, relatedIds types.S) joinQuery {
+	return joinQuery{
+		className: className,
+		key:       key,
+		field:     "relatedId",
+		ids:       relatedIds,
+	}
 }
 
 // owningIds 从 Join 表中查询 relatedIds 对应的父对象
 func (d *DBController) owningIds(className, key string, relatedIds types.S) types.S {
+	return owningJoinQuery(className, key, relatedIds).find()
+}
+
+// joinQueryConcurrency 同时查询 Join 表的最大数量
+const joinQueryConcurrency = 8
+
+// joinQuery Join 表查询，field 为 owningId 时查询 relatedId ，为 relatedId 时查询 owningId
+type joinQuery struct {
+	className string
+	key       string
+	field     string
+	ids       types.S
+}
+
+// id 同一张 Join 表上条件相同的查询具有相同的 id
+func (q joinQuery) id() string {
+	ids := []string{}
+	for _, v := range q.ids {
+		ids = append(ids, utils.S(v))
+	}
+	sort.Strings(ids)
+	return joinTableName(q.className, q.key) + ":" + q.field + ":" + strings.Join(ids, ",")
+}
+
+// find 执行查询，出错时返回空列表
+func (q joinQuery) find() types.S {
 	ids := types.S{}
-	query := types.M{
-		"relatedId": types.M{
-			"$in": relatedIds,
-		},
+	resultField := "owningId"
+	if q.field == "owningId" {
+		resultField = "relatedId"
 	}
-	results, err := Adapter.Find(joinTableName(className, key), relationSchema, query, types.M{})
+	var query types.M
+	if len(q.ids) == 1 {
+		query = types.M{q.field: q.ids[0]}
+	} else {
+		query = types.M{q.field: types.M{"$in": q.ids}}
+	}
+	// 只读取结果字段，配合 EnsureJoinIndexes 创建的索引可以只读取索引
+	results, err := Adapter.Find(joinTableName(q.className, q.key), relationSchema, query, types.M{"keys": []string{resultField}})
 	if err != nil {
 		return ids
 	}
 
 	for _, result := range results {
-		ids = append(ids, result["owningId"])
+		ids = append(ids, result[resultField])
+	}
+	return ids
+}
+
+// joinFetcher 合并同一请求中重复的 Join 表查询，并发执行并保存结果
+type joinFetcher struct {
+	pending map[string]joinQuery
+	results map[string]types.S
+}
+
+func newJoinFetcher() *joinFetcher {
+	return &joinFetcher{
+		pending: map[string]joinQuery{},
+		results: map[string]types.S{},
+	}
+}
+
+// add 添加待执行的查询
+func (f *joinFetcher) add(q joinQuery) {
+	id := q.id()
+	if _, ok := f.results[id]; ok {
+		return
+	}
+	f.pending[id] = q
+}
+
+// fetch 并发执行所有待执行的查询，并发数量不超过 joinQueryConcurrency
+func (f *joinFetcher) fetch() {
+	if len(f.pending) == 1 {
+		for id, q := range f.pending {
+			f.results[id] = q.find()
+		}
+		f.pending = map[string]joinQuery{}
+		return
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, joinQueryConcurrency)
+	for id, q := range f.pending {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(id string, q joinQuery) {
+			defer wg.Done()
+			ids := q.find()
+			mu.Lock()
+			f.results[id] = ids
+			mu.Unlock()
+			<-sem
+		}(id, q)
+	}
+	wg.Wait()
+	f.pending = map[string]joinQuery{}
+}
+
+// get 获取查询结果，未执行过的查询立即执行
+func (f *joinFetcher) get(q joinQuery) types.S {
+	id := q.id()
+	if ids, ok := f.results[id]; ok {
+		return ids
 	}
+	ids := q.find()
+	f.results[id] = ids
 	return ids
 }
 
@@ -1148,14 +2105,7 @@ func filterSensitiveData(isMaster bool, aclGroup []string, className string, obj
 		return object
 	}
 
-	delete(object, "_email_verify_token")
-	delete(object, "_perishable_token")
-	delete(object, "_perishable_token_expires_at")
-	delete(object, "_tombstone")
-	delete(object, "_email_verify_token_expires_at")
-	delete(object, "_failed_login_count")
-	delete(object, "_account_lockout_expires_at")
-	delete(object, "_password_changed_at")
+	stripInternalFields(object)
 
 	// 当前用户返回所有信息
 	if aclGroup == nil {
@@ -1186,7 +2136,7 @@ func (d *DBController) DeleteSchema(className string) error {
 	if exist {
 		count, err := Adapter.Count(className, types.M{"fields": types.M{}}, types.M{})
 		if err != nil {
-			return err
+			return errs.FromAdapter(err)
 		}
 		if count > 0 {
 			return errs.E(errs.ClassNotEmpty, "Class "+className+" is not empty, contains "+strconv.Itoa(count)+" objects, cannot drop schema.")
@@ -1195,7 +2145,7 @@ func (d *DBController) DeleteSchema(className string) error {
 
 	result, err := Adapter.DeleteClass(className)
 	if err != nil {
-		return err
+		return errs.FromAdapter(err)
 	}
 	if result != nil {
 		if fields := utils.M(schema["fields"]); fields != nil {
@@ -1204,13 +2154,14 @@ func (d *DBController) DeleteSchema(className string) error {
 					if utils.S(fieldType["type"]) == "Relation" {
 						_, err = Adapter.DeleteClass(joinTableName(className, fieldName))
 						if err != nil {
-							return err
+							return errs.FromAdapter(err)
 						}
 					}
 				}
 			}
 		}
 	}
+	clearObjectCache()
 	d.LoadSchema(types.M{"clearCache": true})
 	return nil
 }
@@ -1226,9 +2177,9 @@ func (d *DBController) addPointerPermissions(schema *Schema, className string, o
 	}
 
 	perms := schema.perms[className]
-	// 根据当前操作确定是读还是写
+	// 根据当前操作确定是读还是写， count 与 find 一样属于读操作
 	var field string
-	if operation == "get" || operation == "find" {
+	if operation == "get" || operation == "find" || operation == "count" {
 		field = "readUserFields"
 	} else {
 		field = "writeUserFields"
@@ -1246,45 +2197,63 @@ func (d *DBController) addPointerPermissions(schema *Schema, className string, o
 	}
 
 	// 查找保存 有读或写权限 用户 的字段，然后使用 and 与原查询请求进行拼装
-	if perms != nil {
-		if permsMap := utils.M(perms); permsMap != nil {
-			if permFields := utils.A(permsMap[field]); permFields != nil {
-				if permFields != nil && len(permFields) > 0 {
-					// 用户 ID 有多个时，表示没有正确处理 ACL
-					if len(userACL) != 1 {
-						return nil
-					}
-					userID := userACL[0]
-					userPointer := types.M{
-						"__type":    "Pointer",
-						"className": "_User",
-						"objectId":  userID,
-					}
+	permsMap := utils.M(perms)
+	permFields := types.S{}
+	if permsMap != nil {
+		permFields = append(permFields, utils.A(permsMap[field])...)
+		// 操作权限中的 owner 相当于只对当前操作设置了 ownerField 字段的指针权限
+		if ownerField := ownerFieldFor(permsMap, operation); ownerField != "" {
+			permFields = append(permFields, ownerField)
+		}
+	}
+	if len(permFields) > 0 {
+		// 用户 ID 有多个时，表示没有正确处理 ACL
+		if len(userACL) != 1 {
+			return nil
+		}
+		userID := userACL[0]
+		userPointer := types.M{
+			"__type":    "Pointer",
+			"className": "_User",
+			"objectId":  userID,
+		}
 
-					// 使用 and 拼装请求
-					ors := []types.M{}
-					for _, key := range permFields {
-						q := types.M{
-							utils.S(key): userPointer,
-						}
-						and := types.M{
-							"$and": types.S{q, query},
-						}
-						ors = append(ors, and)
-					}
-					// 有多个权限字段时，使用 or 再次拼装
-					if len(ors) > 1 {
-						return types.M{"$or": ors}
-					}
-					return ors[0]
-				}
+		// 使用 and 拼装请求
+		ors := []types.M{}
+		seen := map[string]bool{}
+		for _, key := range permFields {
+			if seen[utils.S(key)] {
+				continue
 			}
+			seen[utils.S(key)] = true
+			q := d.pointerPermissionQuery(schema, className, utils.S(key), userPointer)
+			and := types.M{
+				"$and": types.S{q, query},
+			}
+			ors = append(ors, and)
 		}
-
+		// 有多个权限字段时，使用 or 再次拼装
+		if len(ors) > 1 {
+			return types.M{"$or": ors}
+		}
+		return ors[0]
 	}
 	return query
 }
 
+// pointerPermissionQuery 生成指针权限字段 key 包含当前用户的查询条件
+// Pointer 字段等于当前用户， Array 字段中包含当前用户， Relation 字段中包含当前用户时转换为对象 ID 的查询
+func (d *DBController) pointerPermissionQuery(schema *Schema, className, key string, userPointer types.M) types.M {
+	switch utils.S(schema.getExpectedType(className, key)["type"]) {
+	case "Array":
+		return types.M{key: types.M{"$all": types.S{userPointer}}}
+	case "Relation":
+		ids := d.owningIds(className, key, types.S{userPointer["objectId"]})
+		return types.M{"objectId": types.M{"$in": ids}}
+	}
+	return types.M{key: userPointer}
+}
+
 // PerformInitialization 初始化数据库索引
 func (d *DBController) PerformInitialization() {
 	requiredUserFields := types.M{}
@@ -1313,7 +2282,38 @@ func (d *DBController) PerformInitialization() {
 	Adapter.EnsureUniqueness("_User", requiredUserFields, []string{"username"})
 	Adapter.EnsureUniqueness("_User", requiredUserFields, []string{"email"})
 	Adapter.EnsureUniqueness("_Role", requiredRoleFields, []string{"name"})
+	// 用户名与邮箱常用于不区分大小写的查询
+	d.EnsureCaseInsensitiveIndex("_User", "username")
+	d.EnsureCaseInsensitiveIndex("_User", "email")
 	Adapter.PerformInitialization(types.M{"VolatileClassesSchemas": volatileClassesSchemas()})
+	d.ensureAllJoinIndexes()
+}
+
+// ensureAllJoinIndexes 为已有的 Relation 字段创建 _Join 表的索引，适配器不支持时不做处理
+func (d *DBController) ensureAllJoinIndexes() {
+	indexer, ok := Adapter.(storage.JoinIndexer)
+	if ok == false {
+		return
+	}
+	schemas, err := d.LoadSchema(nil).GetAllClasses(nil)
+	if err != nil {
+		return
+	}
+	for _, schema := range schemas {
+		for fieldName, v := range utils.M(schema["fields"]) {
+			if utils.S(utils.M(v)["type"]) == "Relation" {
+				indexer.EnsureJoinIndexes(utils.S(schema["className"]), fieldName)
+			}
+		}
+	}
+}
+
+// EnsureCaseInsensitiveIndex 为 String 字段创建索引，用于查询选项 caseInsensitive ，适配器不支持时不做处理
+func (d *DBController) EnsureCaseInsensitiveIndex(className, fieldName string) error {
+	if indexer, ok := Adapter.(storage.CaseInsensitiveIndexer); ok {
+		return indexer.EnsureCaseInsensitiveIndex(className, fieldName)
+	}
+	return nil
 }
 
 func addWriteACL(query types.M, acl []string) types.M {
@@ -1688,6 +2688,9 @@ func flattenUpdateOperatorsForCreate(object types.M) error {
 			case "Delete":
 				delete(object, key)
 
+			case "UpdateMatching":
+				return errs.E(errs.InvalidJSON, "The UpdateMatching operator can not be used when creating an object.")
+
 			default:
 				return errs.E(errs.CommandUnavailable, "The "+utils.S(value["__op"])+" operator is not supported yet.")
 			}
@@ -1699,6 +2702,6 @@ func flattenUpdateOperatorsForCreate(object types.M) error {
 // InitOrm 初始化 orm ，仅用于测试
 func InitOrm(a storage.Adapter) {
 	Adapter = a
-	schemaCache = cache.NewSchemaCache(5, false)
+	schemaCache = newSchemaCache()
 	TalismanDBController = &DBController{}
 }
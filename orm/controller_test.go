@@ -1137,6 +1137,67 @@ func Test_Find(t *testing.T) {
 	/*************************************************/
 	initEnv()
 	className = "user"
+	object = types.M{
+		"fields": types.M{
+			"key": types.M{"type": "String"},
+		},
+		"classLevelPermissions": types.M{
+			"find":  types.M{"role:2048": true},
+			"count": types.M{"*": true},
+		},
+	}
+	Adapter.CreateClass(className, object)
+	className = "user"
+	object = types.M{
+		"objectId": "01",
+		"key":      "hello",
+	}
+	Adapter.CreateObject(className, types.M{}, object)
+	object = types.M{
+		"objectId": "02",
+		"key":      "hello",
+	}
+	Adapter.CreateObject(className, types.M{}, object)
+	className = "user"
+	query = types.M{}
+	options = types.M{"acl": []string{"role:1024", "123456789012345678901234"}, "count": true}
+	results, err = TalismanDBController.Find(className, query, options)
+	expects = types.S{2}
+	if err != nil || reflect.DeepEqual(expects, results) == false {
+		t.Error("expect:", expects, "result:", results, err)
+	}
+	TalismanDBController.DeleteEverything()
+	/*************************************************/
+	initEnv()
+	className = "user"
+	object = types.M{
+		"fields": types.M{
+			"key": types.M{"type": "String"},
+		},
+		"classLevelPermissions": types.M{
+			"find":  types.M{"*": true},
+			"count": types.M{"role:2048": true},
+		},
+	}
+	Adapter.CreateClass(className, object)
+	className = "user"
+	object = types.M{
+		"objectId": "01",
+		"key":      "hello",
+	}
+	Adapter.CreateObject(className, types.M{}, object)
+	className = "user"
+	query = types.M{}
+	options = types.M{"acl": []string{"role:1024", "123456789012345678901234"}, "count": true}
+	results, err = TalismanDBController.Find(className, query, options)
+	expectErr = errs.E(errs.OperationForbidden, "Permission denied for action count on class user.")
+	if err == nil || reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", results, err)
+	}
+	TalismanDBController.DeleteEverything()
+	/*************************************************/
+	initEnv()
+	className = "user"
 	object = types.M{
 		"fields": types.M{
 			"key": types.M{"type": "String"},
@@ -2474,6 +2535,95 @@ func Test_Update(t *testing.T) {
 	TalismanDBController.DeleteEverything()
 }
 
+func Test_Upsert(t *testing.T) {
+	initEnv()
+	var className string
+	var where types.M
+	var data types.M
+	var options types.M
+	var result types.M
+	var created bool
+	var err error
+	var expectErr error
+	/*************************************************/
+	className = "post"
+	where = nil
+	data = types.M{"title": "hello"}
+	options = nil
+	_, _, err = TalismanDBController.Upsert(className, where, data, options)
+	expectErr = errs.E(errs.InvalidQuery, "Upsert requires a query.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/*************************************************/
+	className = "post"
+	where = types.M{"slug": "hello"}
+	data = types.M{"title": "hello", "views": types.M{"__op": "Increment", "amount": 1}}
+	options = nil
+	result, created, err = TalismanDBController.Upsert(className, where, data, options)
+	if err != nil || created == false {
+		t.Error("expect:", true, "result:", created, err)
+	}
+	if result["slug"] != "hello" || result["title"] != "hello" || result["views"] != 1 ||
+		utils.S(result["objectId"]) == "" || utils.S(result["createdAt"]) == "" {
+		t.Error("expect:", "created object", "result:", result)
+	}
+	objectID := utils.S(result["objectId"])
+	/*************************************************/
+	result, created, err = TalismanDBController.Upsert(className, where, data, options)
+	if err != nil || created {
+		t.Error("expect:", false, "result:", created, err)
+	}
+	if result["objectId"] != objectID || result["views"] != 2 {
+		t.Error("expect:", objectID, 2, "result:", result)
+	}
+	/*************************************************/
+	TalismanDBController.Update(className, types.M{"objectId": objectID}, types.M{"ACL": types.M{"123": types.M{"write": true}}}, nil, false)
+	options = types.M{"acl": []string{"456"}}
+	_, _, err = TalismanDBController.Upsert(className, where, data, options)
+	expectErr = errs.E(errs.ObjectNotFound, "Object not found.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	TalismanDBController.DeleteEverything()
+}
+
+func Test_FindOneAndDelete(t *testing.T) {
+	initEnv()
+	var className string
+	var query types.M
+	var options types.M
+	var result types.M
+	var expect types.M
+	var err error
+	/*************************************************/
+	className = "job"
+	Adapter.CreateObject(className, types.M{}, types.M{"objectId": "1001", "state": "ready", "priority": 1})
+	Adapter.CreateObject(className, types.M{}, types.M{"objectId": "1002", "state": "ready", "priority": 2})
+	query = types.M{"state": "ready"}
+	options = types.M{"sort": []string{"priority"}}
+	result, err = TalismanDBController.FindOneAndDelete(className, query, options)
+	expect = types.M{"objectId": "1001", "state": "ready", "priority": 1}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/*************************************************/
+	TalismanDBController.Update(className, types.M{"objectId": "1002"}, types.M{"ACL": types.M{"123": types.M{"write": true}}}, nil, false)
+	options = types.M{"acl": []string{"456"}}
+	result, err = TalismanDBController.FindOneAndDelete(className, query, options)
+	expect = types.M{}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/*************************************************/
+	options = types.M{"acl": []string{"123"}, "sort": []string{"priority"}}
+	result, err = TalismanDBController.FindOneAndDelete(className, query, options)
+	if err != nil || result["objectId"] != "1002" {
+		t.Error("expect:", "1002", "result:", result, err)
+	}
+	TalismanDBController.DeleteEverything()
+}
+
 func Test_Create(t *testing.T) {
 	initEnv()
 	var className string
@@ -2765,6 +2915,27 @@ func Test_validateClassName(t *testing.T) {
 	}
 }
 
+func Test_stripRelationUpdates(t *testing.T) {
+	var update types.M
+	var expect types.M
+	/*************************************************/
+	update = types.M{
+		"key":   "hello",
+		"count": types.M{"__op": "Increment", "amount": 1},
+		"post":  types.M{"__op": "AddRelation", "objects": types.S{}},
+		"user":  types.M{"__op": "RemoveRelation", "objects": types.S{}},
+		"team":  types.M{"__op": "Batch", "ops": types.S{}},
+	}
+	stripRelationUpdates(update)
+	expect = types.M{
+		"key":   "hello",
+		"count": types.M{"__op": "Increment", "amount": 1},
+	}
+	if reflect.DeepEqual(expect, update) == false {
+		t.Error("expect:", expect, "result:", update)
+	}
+}
+
 func Test_handleRelationUpdates(t *testing.T) {
 	initEnv()
 	var className string
@@ -3216,6 +3387,34 @@ func Test_LoadSchema(t *testing.T) {
 	Adapter.DeleteAllClasses()
 }
 
+func Test_WarmUpSchemaCache(t *testing.T) {
+	initEnv()
+	var object types.M
+	var className string
+	var err error
+	var result types.M
+	/*************************************************/
+	object = types.M{
+		"fields": types.M{
+			"key": types.M{"type": "String"},
+		},
+	}
+	className = "user"
+	Adapter.CreateClass(className, object)
+	err = TalismanDBController.WarmUpSchemaCache()
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	result = schemaCache.GetOneSchema(className)
+	if result == nil || utils.S(result["className"]) != className {
+		t.Error("expect:", className, "result:", result)
+	}
+	if schemaPromise == nil || schemaPromise.data[className] == nil {
+		t.Error("expect:", className, "result:", schemaPromise)
+	}
+	TalismanDBController.DeleteEverything()
+}
+
 func Test_DeleteEverything(t *testing.T) {
 	// 测试用例与 Adapter.DeleteAllClasses 类似
 }
@@ -3441,6 +3640,55 @@ func Test_canAddField(t *testing.T) {
 		t.Error("expect:", expect, "result:", err)
 	}
 	Adapter.DeleteAllClasses()
+	/*************************************************/
+	object = types.M{
+		"fields": types.M{
+			"key": types.M{"type": "String"},
+		},
+		"classLevelPermissions": types.M{
+			"addField":      types.M{"*": true},
+			"allowAddField": false,
+		},
+	}
+	Adapter.CreateClass("user", object)
+	schema = getSchema()
+	schema.reloadData(nil)
+	className = "user"
+	object = types.M{
+		"key":  "hello",
+		"key1": "hello",
+	}
+	acl = nil
+	err = TalismanDBController.canAddField(schema, className, object, acl)
+	expect = errs.E(errs.OperationForbidden, "Class user is locked, new fields can not be added.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	Adapter.DeleteAllClasses()
+	/*************************************************/
+	object = types.M{
+		"fields": types.M{
+			"key": types.M{"type": "String"},
+		},
+		"classLevelPermissions": types.M{
+			"addField":      types.M{"*": true},
+			"allowAddField": false,
+		},
+	}
+	Adapter.CreateClass("user", object)
+	schema = getSchema()
+	schema.reloadData(nil)
+	className = "user"
+	object = types.M{
+		"key": "hello",
+	}
+	acl = nil
+	err = TalismanDBController.canAddField(schema, className, object, acl)
+	expect = nil
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	Adapter.DeleteAllClasses()
 }
 
 func Test_reduceRelationKeys(t *testing.T) {
@@ -3635,6 +3883,38 @@ func Test_relatedIds(t *testing.T) {
 	Adapter.DeleteAllClasses()
 }
 
+func Test_CountRelated(t *testing.T) {
+	initEnv()
+	/*************************************************/
+	_, err := TalismanDBController.CountRelated("user", "name", "1001")
+	expectErr := errs.E(errs.InvalidKeyName, "name is not a Relation field of user")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/*************************************************/
+	Adapter.CreateClass("user", types.M{
+		"fields": types.M{
+			"name": types.M{"type": "Relation", "targetClass": "post"},
+		},
+	})
+	for _, object := range []types.M{
+		{"_id": "1", "relatedId": "01", "owningId": "1001"},
+		{"_id": "2", "relatedId": "02", "owningId": "1002"},
+		{"_id": "3", "relatedId": "03", "owningId": "1001"},
+	} {
+		Adapter.CreateObject("_Join:name:user", relationSchema, object)
+	}
+	count, err := TalismanDBController.CountRelated("user", "name", "1001")
+	if err != nil || count != 2 {
+		t.Error("expect:", 2, "result:", count, err)
+	}
+	count, err = TalismanDBController.CountRelated("user", "name", "1003")
+	if err != nil || count != 0 {
+		t.Error("expect:", 0, "result:", count, err)
+	}
+	TalismanDBController.DeleteEverything()
+}
+
 func Test_addInObjectIdsIds(t *testing.T) {
 	initEnv()
 	var ids types.S
@@ -4501,6 +4781,22 @@ func Test_owningIds(t *testing.T) {
 	Adapter.DeleteAllClasses()
 }
 
+func Test_joinFetcher(t *testing.T) {
+	var fetcher *joinFetcher
+	var expect int
+	/*************************************************/
+	fetcher = newJoinFetcher()
+	fetcher.add(owningJoinQuery("user", "name", types.S{"01", "02"}))
+	fetcher.add(owningJoinQuery("user", "name", types.S{"02", "01"}))
+	fetcher.add(owningJoinQuery("user", "name", types.S{"01"}))
+	fetcher.add(owningJoinQuery("user", "age", types.S{"01"}))
+	fetcher.add(joinQuery{className: "user", key: "name", field: "owningId", ids: types.S{"01"}})
+	expect = 4
+	if len(fetcher.pending) != expect {
+		t.Error("expect:", expect, "result:", len(fetcher.pending))
+	}
+}
+
 func Test_DeleteSchema(t *testing.T) {
 	initEnv()
 	var object types.M
@@ -4731,6 +5027,47 @@ func Test_addPointerPermissions(t *testing.T) {
 	Adapter.DeleteAllClasses()
 	/*************************************************/
 	className = "user"
+	object = types.M{
+		"className": className,
+		"fields": types.M{
+			"key": types.M{"type": "String"},
+		},
+		"classLevelPermissions": types.M{
+			"count":           types.M{"role:1024": true},
+			"readUserFields":  types.S{"key2"},
+			"writeUserFields": types.S{"key3"},
+		},
+	}
+	Adapter.CreateClass(className, object)
+	schema = getSchema()
+	schema.reloadData(nil)
+	className = "user"
+	operation = "count"
+	query = types.M{
+		"key": "hello",
+	}
+	aclGroup = []string{"123456789012345678901234"}
+	result = TalismanDBController.addPointerPermissions(schema, className, operation, query, aclGroup)
+	expect = types.M{
+		"$and": types.S{
+			types.M{
+				"key2": types.M{
+					"__type":    "Pointer",
+					"className": "_User",
+					"objectId":  "123456789012345678901234",
+				},
+			},
+			types.M{
+				"key": "hello",
+			},
+		},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	Adapter.DeleteAllClasses()
+	/*************************************************/
+	className = "user"
 	object = types.M{
 		"className": className,
 		"fields": types.M{
@@ -4862,7 +5199,7 @@ func Test_sanitizeDatabaseResult(t *testing.T) {
 	/*************************************************/
 	originalObject = nil
 	object = nil
-	result = sanitizeDatabaseResult(originalObject, object)
+	result = sanitizeDatabaseResult(originalObject, object, nil)
 	expect = types.M{}
 	if reflect.DeepEqual(expect, result) == false {
 		t.Error("expect:", expect, "result:", result)
@@ -4879,7 +5216,7 @@ func Test_sanitizeDatabaseResult(t *testing.T) {
 		"key":  types.S{"hello", "world"},
 		"key2": "hello",
 	}
-	result = sanitizeDatabaseResult(originalObject, object)
+	result = sanitizeDatabaseResult(originalObject, object, nil)
 	expect = types.M{
 		"key": types.S{"hello", "world"},
 	}
@@ -4898,7 +5235,7 @@ func Test_sanitizeDatabaseResult(t *testing.T) {
 		"key":  types.S{"hello", "world"},
 		"key2": "hello",
 	}
-	result = sanitizeDatabaseResult(originalObject, object)
+	result = sanitizeDatabaseResult(originalObject, object, nil)
 	expect = types.M{
 		"key": types.S{"hello", "world"},
 	}
@@ -4917,7 +5254,7 @@ func Test_sanitizeDatabaseResult(t *testing.T) {
 		"key":  types.S{"value"},
 		"key2": "hello",
 	}
-	result = sanitizeDatabaseResult(originalObject, object)
+	result = sanitizeDatabaseResult(originalObject, object, nil)
 	expect = types.M{
 		"key": types.S{"value"},
 	}
@@ -4936,7 +5273,7 @@ func Test_sanitizeDatabaseResult(t *testing.T) {
 		"key":  20,
 		"key2": "hello",
 	}
-	result = sanitizeDatabaseResult(originalObject, object)
+	result = sanitizeDatabaseResult(originalObject, object, nil)
 	expect = types.M{
 		"key": 20,
 	}
@@ -4954,11 +5291,50 @@ func Test_sanitizeDatabaseResult(t *testing.T) {
 	object = types.M{
 		"key2": "hello",
 	}
-	result = sanitizeDatabaseResult(originalObject, object)
+	result = sanitizeDatabaseResult(originalObject, object, nil)
 	expect = types.M{}
 	if reflect.DeepEqual(expect, result) == false {
 		t.Error("expect:", expect, "result:", result)
 	}
+	/*************************************************/
+	originalObject = types.M{
+		"stats.count": types.M{
+			"__op":   "Increment",
+			"amount": 1,
+		},
+		"updatedAt": "2006-01-02T15:04:05.000Z",
+	}
+	object = types.M{
+		"stats":     types.M{"count": 3, "total": 10},
+		"updatedAt": "2006-01-02T15:04:05.000Z",
+	}
+	result = sanitizeDatabaseResult(originalObject, object, nil)
+	expect = types.M{
+		"stats":     types.M{"count": 3},
+		"updatedAt": "2006-01-02T15:04:05.000Z",
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*************************************************/
+	originalObject = types.M{
+		"title":      "Hello",
+		"slug":       "hello",
+		"deviceType": "ios",
+	}
+	object = types.M{
+		"title":      "Hello",
+		"slug":       "hello",
+		"deviceType": "ios",
+		"other":      "value",
+	}
+	result = sanitizeDatabaseResult(originalObject, object, []string{"slug", "missing"})
+	expect = types.M{
+		"slug": "hello",
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
 }
 
 func Test_joinTableName(t *testing.T) {
@@ -5405,6 +5781,86 @@ func Test_validateQuery(t *testing.T) {
 	}
 }
 
+func Test_normalizeSort(t *testing.T) {
+	var keys []string
+	var fields types.M
+	var result []string
+	var err error
+	var expect []string
+	var expectErr error
+	fields = types.M{
+		"objectId":  types.M{"type": "String"},
+		"createdAt": types.M{"type": "Date"},
+		"key":       types.M{"type": "Number"},
+		"info":      types.M{"type": "Object"},
+		"location":  types.M{"type": "GeoPoint"},
+		"friends":   types.M{"type": "Relation", "targetClass": "_User"},
+	}
+	/*************************************************/
+	keys = []string{}
+	result, err = normalizeSort(keys, fields)
+	expect = []string{}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/*************************************************/
+	keys = []string{"-key", "_created_at"}
+	result, err = normalizeSort(keys, fields)
+	expect = []string{"-key", "createdAt", "objectId"}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/*************************************************/
+	keys = []string{"key", "-key", "-objectId"}
+	result, err = normalizeSort(keys, fields)
+	expect = []string{"key", "-objectId"}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/*************************************************/
+	keys = []string{"info.age", "-info.name"}
+	result, err = normalizeSort(keys, fields)
+	expect = []string{"info.age", "-info.name", "objectId"}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/*************************************************/
+	keys = []string{"key.age"}
+	result, err = normalizeSort(keys, fields)
+	expectErr = errs.E(errs.InvalidKeyName, "Cannot sort by key.age, key is not an Object field")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+	/*************************************************/
+	keys = []string{"other"}
+	result, err = normalizeSort(keys, fields)
+	expectErr = errs.E(errs.InvalidKeyName, "Cannot sort by unknown field: other")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+	/*************************************************/
+	keys = []string{"other"}
+	result, err = normalizeSort(keys, nil)
+	expect = []string{"other", "objectId"}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/*************************************************/
+	keys = []string{"friends"}
+	result, err = normalizeSort(keys, fields)
+	expectErr = errs.E(errs.InvalidKeyName, "Cannot sort by Relation field: friends")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+	/*************************************************/
+	keys = []string{"info.@age"}
+	result, err = normalizeSort(keys, fields)
+	expectErr = errs.E(errs.InvalidKeyName, "Invalid field name: info.@age")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", result, err)
+	}
+}
+
 func Test_transformObjectACL(t *testing.T) {
 	var object types.M
 	var result types.M
@@ -5878,6 +6334,89 @@ func Test_flattenUpdateOperatorsForCreate(t *testing.T) {
 	}
 }
 
+func Test_addUpdateMatchingQuery(t *testing.T) {
+	type args struct {
+		query  types.M
+		update types.M
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    types.M
+		wantErr error
+	}{
+		{
+			name: "1",
+			args: args{
+				query:  types.M{"objectId": "1001"},
+				update: types.M{"title": "hello"},
+			},
+			want:    types.M{"objectId": "1001"},
+			wantErr: nil,
+		},
+		{
+			name: "2",
+			args: args{
+				query: types.M{"objectId": "1001"},
+				update: types.M{
+					"items": types.M{"__op": "UpdateMatching", "match": types.M{"id": "a"}, "set": types.M{"done": true}},
+				},
+			},
+			want: types.M{
+				"$and": types.S{
+					types.M{"objectId": "1001"},
+					types.M{"items": types.M{"$elemMatch": types.M{"id": "a"}}},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "3",
+			args: args{
+				query: types.M{"objectId": "1001"},
+				update: types.M{
+					"items": types.M{"__op": "UpdateMatching", "match": types.M{"id": "a"}, "set": types.M{"done": true}},
+					"tags":  types.M{"__op": "UpdateMatching", "match": types.M{"id": "b"}, "set": types.M{"done": true}},
+				},
+			},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidJSON, "only one UpdateMatching operator is allowed in an update"),
+		},
+		{
+			name: "4",
+			args: args{
+				query: types.M{"objectId": "1001"},
+				update: types.M{
+					"items": types.M{"__op": "UpdateMatching", "set": types.M{"done": true}},
+				},
+			},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidJSON, "match must be an object"),
+		},
+		{
+			name: "5",
+			args: args{
+				query: types.M{"objectId": "1001"},
+				update: types.M{
+					"items": types.M{"__op": "UpdateMatching", "match": types.M{"id": "a"}, "set": types.M{"a.b": true}},
+				},
+			},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidNestedKey, "Nested keys should not contain the '$' or '.' characters"),
+		},
+	}
+	for _, tt := range tests {
+		got, err := addUpdateMatchingQuery(tt.args.query, tt.args.update)
+		if reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. addUpdateMatchingQuery() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("%q. addUpdateMatchingQuery() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
 func initEnv() {
 	Adapter = getAdapter()
 	schemaCache = cache.NewSchemaCache(5, false)
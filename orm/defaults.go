@@ -0,0 +1,143 @@
+package orm
+
+import (
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 类级别权限中的 defaults 设置创建对象时的默认值，在 beforeSave 与数据校验之前应用，请求中已有的字段不会被覆盖
+// 格式如下：
+//
+//	"defaults": {
+//		"ACL": {"owner": {"read": true, "write": true}, "role:Admin": {"read": true}},
+//		"ownerField": "author",
+//		"values": {"status": "draft", "score": 0}
+//	}
+//
+// ACL 为默认 ACL 的模板，其中 owner 替换为创建对象的用户，没有用户时忽略 owner ；
+// ownerField 为指向 _User 的 Pointer 字段，设置为创建对象的用户； values 为其他字段的默认值
+
+// defaultsOwner 默认 ACL 模板中表示创建者的 key
+const defaultsOwner = "owner"
+
+// validateCLPDefaults 校验 defaults 的格式
+func validateCLPDefaults(perm interface{}, fields types.M) error {
+	defaults := utils.M(perm)
+	if defaults == nil {
+		return errs.E(errs.InvalidJSON, "defaults must be an object for class level permissions")
+	}
+	for key, value := range defaults {
+		switch key {
+		case "ACL":
+			acl := utils.M(value)
+			if acl == nil {
+				return errs.E(errs.InvalidJSON, "defaults ACL must be an object")
+			}
+			for entry, v := range acl {
+				if entry != defaultsOwner {
+					if err := verifyPermissionKey(entry); err != nil || entry == "requiresAuthentication" {
+						return errs.E(errs.InvalidJSON, entry+" is not a valid key for defaults ACL")
+					}
+				}
+				permission := utils.M(v)
+				if permission == nil {
+					return errs.E(errs.InvalidJSON, "defaults ACL "+entry+" must be an object")
+				}
+				for op, allowed := range permission {
+					if _, ok := allowed.(bool); (op != "read" && op != "write") || ok == false {
+						return errs.E(errs.InvalidJSON, "defaults ACL "+entry+" only supports boolean read and write")
+					}
+				}
+			}
+		case "ownerField":
+			fieldName := utils.S(value)
+			if fields != nil {
+				if t := utils.M(fields[fieldName]); t != nil && utils.S(t["type"]) == "Pointer" && utils.S(t["targetClass"]) == "_User" {
+					continue
+				}
+			}
+			return errs.E(errs.InvalidJSON, fieldName+" is not a valid column for defaults ownerField")
+		case "values":
+			values := utils.M(value)
+			if values == nil {
+				return errs.E(errs.InvalidJSON, "defaults values must be an object")
+			}
+			for fieldName, v := range values {
+				if fieldName == "ACL" || fieldNameIsValid(fieldName) == false || DefaultColumns["_Default"][fieldName] != nil {
+					return errs.E(errs.InvalidKeyName, fieldName+" is not a valid field for defaults values")
+				}
+				if utils.M(v) != nil && utils.M(v)["__op"] != nil {
+					return errs.E(errs.InvalidJSON, "defaults values can not contain operations: "+fieldName)
+				}
+			}
+		default:
+			return errs.E(errs.InvalidJSON, key+" is not a valid option for defaults")
+		}
+	}
+	return nil
+}
+
+// CreationDefaults 返回类中创建对象时的默认值设置，未设置时返回 nil
+// 未设置 defaults.ownerField 时使用类级别权限中的 ownerField
+func (s *Schema) CreationDefaults(className string) types.M {
+	s.permsMutex.Lock()
+	defer s.permsMutex.Unlock()
+	if s.perms == nil {
+		return nil
+	}
+	classPerms := utils.M(s.perms[className])
+	if classPerms == nil {
+		return nil
+	}
+	defaults := utils.M(classPerms["defaults"])
+	// 声明了所有者字段时，创建对象的用户默认为所有者
+	if ownerField := utils.S(classPerms["ownerField"]); ownerField != "" && utils.S(defaults["ownerField"]) == "" {
+		defaults = utils.CopyMap(defaults)
+		if defaults == nil {
+			defaults = types.M{}
+		}
+		defaults["ownerField"] = ownerField
+	}
+	return defaults
+}
+
+// ApplyCreationDefaults 把默认值写入 object 中已有值以外的字段，直接修改 object ， userID 为创建对象的用户，可以为空
+// 返回写入了默认值的字段
+func ApplyCreationDefaults(defaults, object types.M, userID string) []string {
+	fields := []string{}
+	if defaults == nil || object == nil {
+		return fields
+	}
+	for fieldName, value := range utils.M(defaults["values"]) {
+		if _, ok := object[fieldName]; ok == false {
+			object[fieldName] = utils.DeepCopy(value)
+			fields = append(fields, fieldName)
+		}
+	}
+	if fieldName := utils.S(defaults["ownerField"]); fieldName != "" && userID != "" {
+		if _, ok := object[fieldName]; ok == false {
+			object[fieldName] = types.M{"__type": "Pointer", "className": "_User", "objectId": userID}
+			fields = append(fields, fieldName)
+		}
+	}
+	if template := utils.M(defaults["ACL"]); template != nil {
+		if _, ok := object["ACL"]; ok == false {
+			acl := types.M{}
+			for entry, v := range template {
+				if entry == defaultsOwner {
+					if userID == "" {
+						continue
+					}
+					entry = userID
+				}
+				acl[entry] = utils.CopyMap(utils.M(v))
+			}
+			if len(acl) > 0 {
+				object["ACL"] = acl
+				fields = append(fields, "ACL")
+			}
+		}
+	}
+	return fields
+}
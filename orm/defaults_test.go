@@ -0,0 +1,116 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_validateCLPDefaults(t *testing.T) {
+	fields := types.M{
+		"author": types.M{"type": "Pointer", "targetClass": "_User"},
+		"post":   types.M{"type": "Pointer", "targetClass": "Post"},
+	}
+	tests := []struct {
+		name    string
+		perm    interface{}
+		wantErr error
+	}{
+		{
+			name: "1",
+			perm: types.M{
+				"ACL":        types.M{"owner": types.M{"read": true, "write": true}, "role:Admin": types.M{"read": true}, "*": types.M{"read": false}},
+				"ownerField": "author",
+				"values":     types.M{"status": "draft", "score": 0},
+			},
+			wantErr: nil,
+		},
+		{
+			name:    "2",
+			perm:    "author",
+			wantErr: errs.E(errs.InvalidJSON, "defaults must be an object for class level permissions"),
+		},
+		{
+			name:    "3",
+			perm:    types.M{"ACL": types.M{"role:Admin": types.M{"delete": true}}},
+			wantErr: errs.E(errs.InvalidJSON, "defaults ACL role:Admin only supports boolean read and write"),
+		},
+		{
+			name:    "4",
+			perm:    types.M{"ACL": types.M{"abc": types.M{"read": true}}},
+			wantErr: errs.E(errs.InvalidJSON, "abc is not a valid key for defaults ACL"),
+		},
+		{
+			name:    "5",
+			perm:    types.M{"ownerField": "post"},
+			wantErr: errs.E(errs.InvalidJSON, "post is not a valid column for defaults ownerField"),
+		},
+		{
+			name:    "6",
+			perm:    types.M{"values": types.M{"objectId": "1001"}},
+			wantErr: errs.E(errs.InvalidKeyName, "objectId is not a valid field for defaults values"),
+		},
+		{
+			name:    "7",
+			perm:    types.M{"values": types.M{"score": types.M{"__op": "Increment", "amount": 1}}},
+			wantErr: errs.E(errs.InvalidJSON, "defaults values can not contain operations: score"),
+		},
+		{
+			name:    "8",
+			perm:    types.M{"owner": "author"},
+			wantErr: errs.E(errs.InvalidJSON, "owner is not a valid option for defaults"),
+		},
+	}
+	for _, tt := range tests {
+		if err := validateCLPDefaults(tt.perm, fields); !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%q. validateCLPDefaults() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_ApplyCreationDefaults(t *testing.T) {
+	defaults := types.M{
+		"ACL":        types.M{"owner": types.M{"read": true, "write": true}, "role:Admin": types.M{"read": true}},
+		"ownerField": "author",
+		"values":     types.M{"status": "draft", "tags": types.S{"new"}},
+	}
+	object := types.M{"title": "hello", "status": "published"}
+	fields := ApplyCreationDefaults(defaults, object, "u1")
+	expect := types.M{
+		"title":  "hello",
+		"status": "published",
+		"tags":   types.S{"new"},
+		"author": types.M{"__type": "Pointer", "className": "_User", "objectId": "u1"},
+		"ACL": types.M{
+			"u1":         map[string]interface{}{"read": true, "write": true},
+			"role:Admin": map[string]interface{}{"read": true},
+		},
+	}
+	if reflect.DeepEqual(object, expect) == false {
+		t.Error("expect:", expect, "result:", object)
+	}
+	expectFields := []string{"tags", "author", "ACL"}
+	if reflect.DeepEqual(fields, expectFields) == false {
+		t.Error("expect:", expectFields, "result:", fields)
+	}
+	/*****************************************************************/
+	object = types.M{"ACL": types.M{"*": types.M{"read": true}}}
+	ApplyCreationDefaults(defaults, object, "")
+	expect = types.M{
+		"status": "draft",
+		"tags":   types.S{"new"},
+		"ACL":    types.M{"*": types.M{"read": true}},
+	}
+	if reflect.DeepEqual(object, expect) == false {
+		t.Error("expect:", expect, "result:", object)
+	}
+	/*****************************************************************/
+	object = types.M{}
+	ApplyCreationDefaults(types.M{"ACL": types.M{"owner": types.M{"read": true}}}, object, "")
+	expect = types.M{}
+	if reflect.DeepEqual(object, expect) == false {
+		t.Error("expect:", expect, "result:", object)
+	}
+}
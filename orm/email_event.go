@@ -0,0 +1,39 @@
+package orm
+
+import (
+	"time"
+
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 使用 SendGrid 或者 Mailgun 发送邮件时，服务商通过 /mail/events 回调推送投递事件，
+// 每个事件在 _EmailEvent 表中保存一条记录，可以使用 Master Key 通过 /classes/_EmailEvent 查询，
+// 例如查询 event 为 bounced 或者 complained 的地址，用于处理退信
+
+// EmailEventClassName 保存邮件投递事件的表
+const EmailEventClassName = "_EmailEvent"
+
+// NewEmailEvent 生成邮件投递事件， timestamp 为零值时使用当前时间
+func NewEmailEvent(provider, email, event, messageID, reason string, timestamp time.Time) types.M {
+	now := time.Now().UTC()
+	if timestamp.IsZero() {
+		timestamp = now
+	}
+	return types.M{
+		"objectId":  utils.CreateObjectID(),
+		"provider":  provider,
+		"email":     email,
+		"event":     event,
+		"messageId": messageID,
+		"reason":    reason,
+		"timestamp": utils.DateJSON(timestamp),
+		"createdAt": utils.TimetoString(now),
+		"updatedAt": utils.TimetoString(now),
+	}
+}
+
+// SaveEmailEvent 保存邮件投递事件
+func (d *DBController) SaveEmailEvent(event types.M) error {
+	return d.Create(EmailEventClassName, event, types.M{})
+}
@@ -0,0 +1,103 @@
+package orm
+
+import (
+	"strings"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/storage"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 字段类型为 Enum 时，字段值为 String ，只能是字段定义中 values 列出的值，如 {"type":"Enum","values":["draft","published"]}
+// 修改类时可以重新提交已有 Enum 字段的定义以增加取值，不能删除已有的取值，修改定义不需要迁移数据
+// 数据库中按 String 保存，可以像 String 字段一样查询与排序
+
+// isEnumField 字段类型是否为 Enum
+func isEnumField(t types.M) bool {
+	return t != nil && utils.S(t["type"]) == "Enum"
+}
+
+// enumValuesOf 返回 Enum 字段允许的取值
+func enumValuesOf(t types.M) []string {
+	values := []string{}
+	for _, v := range utils.A(t["values"]) {
+		if s, ok := v.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// validateEnumField values 必须为不重复的非空字符串组成的数组
+func validateEnumField(t types.M) error {
+	values := utils.A(t["values"])
+	if len(values) == 0 {
+		return errs.E(errs.MissingRequiredFieldError, "type Enum needs a non-empty values array")
+	}
+	seen := map[string]bool{}
+	for _, v := range values {
+		s, ok := v.(string)
+		if ok == false || s == "" {
+			return errs.E(errs.InvalidJSON, "values of type Enum must be non-empty strings")
+		}
+		if seen[s] {
+			return errs.E(errs.InvalidJSON, "duplicate value in type Enum: "+s)
+		}
+		seen[s] = true
+	}
+	return nil
+}
+
+// validateEnumExtension 修改 Enum 字段的定义时，新的取值必须包含全部已有的取值
+func validateEnumExtension(fieldName string, existing, submitted types.M) error {
+	err := validateEnumField(submitted)
+	if err != nil {
+		return err
+	}
+	allowed := map[string]bool{}
+	for _, v := range enumValuesOf(submitted) {
+		allowed[v] = true
+	}
+	removed := []string{}
+	for _, v := range enumValuesOf(existing) {
+		if allowed[v] == false {
+			removed = append(removed, v)
+		}
+	}
+	if len(removed) > 0 {
+		return errs.E(errs.IncorrectType, "Enum field "+fieldName+" can not remove values: "+strings.Join(removed, ", "))
+	}
+	return nil
+}
+
+// validateEnumValue 写入 Enum 字段的值必须在允许的取值中
+func validateEnumValue(fieldName string, t types.M, value interface{}) error {
+	if isEnumField(t) == false {
+		return nil
+	}
+	s, ok := value.(string)
+	if ok == false {
+		return nil
+	}
+	for _, v := range enumValuesOf(t) {
+		if v == s {
+			return nil
+		}
+	}
+	return errs.E(errs.IncorrectType, s+" is not a valid value for Enum field "+fieldName+", expected one of: "+strings.Join(enumValuesOf(t), ", "))
+}
+
+// updateFieldType 修改已有字段的定义，不修改数据，适配器不支持时返回错误
+func (s *Schema) updateFieldType(className, fieldName string, fieldType types.M) error {
+	updater, ok := s.dbAdapter.(storage.FieldTypeUpdater)
+	if ok == false {
+		return errs.E(errs.OperationForbidden, "Updating field definitions is not supported by the database adapter.")
+	}
+	err := updater.UpdateFieldType(className, fieldName, fieldType)
+	if err != nil {
+		return errs.FromAdapter(err)
+	}
+	s.cache.Clear()
+	return nil
+}
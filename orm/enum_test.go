@@ -0,0 +1,134 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_validateEnumField(t *testing.T) {
+	tests := []struct {
+		name    string
+		t       types.M
+		wantErr error
+	}{
+		{
+			name:    "1",
+			t:       types.M{"type": "Enum", "values": types.S{"draft", "published"}},
+			wantErr: nil,
+		},
+		{
+			name:    "2",
+			t:       types.M{"type": "Enum"},
+			wantErr: errs.E(errs.MissingRequiredFieldError, "type Enum needs a non-empty values array"),
+		},
+		{
+			name:    "3",
+			t:       types.M{"type": "Enum", "values": types.S{}},
+			wantErr: errs.E(errs.MissingRequiredFieldError, "type Enum needs a non-empty values array"),
+		},
+		{
+			name:    "4",
+			t:       types.M{"type": "Enum", "values": types.S{"draft", 1}},
+			wantErr: errs.E(errs.InvalidJSON, "values of type Enum must be non-empty strings"),
+		},
+		{
+			name:    "5",
+			t:       types.M{"type": "Enum", "values": types.S{"draft", ""}},
+			wantErr: errs.E(errs.InvalidJSON, "values of type Enum must be non-empty strings"),
+		},
+		{
+			name:    "6",
+			t:       types.M{"type": "Enum", "values": types.S{"draft", "draft"}},
+			wantErr: errs.E(errs.InvalidJSON, "duplicate value in type Enum: draft"),
+		},
+	}
+	for _, tt := range tests {
+		if err := validateEnumField(tt.t); reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. validateEnumField() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_validateEnumExtension(t *testing.T) {
+	existing := types.M{"type": "Enum", "values": types.S{"draft", "published"}}
+	tests := []struct {
+		name      string
+		submitted types.M
+		wantErr   error
+	}{
+		{
+			name:      "1",
+			submitted: types.M{"type": "Enum", "values": types.S{"draft", "published", "archived"}},
+			wantErr:   nil,
+		},
+		{
+			name:      "2",
+			submitted: types.M{"type": "Enum", "values": types.S{"published", "draft"}},
+			wantErr:   nil,
+		},
+		{
+			name:      "3",
+			submitted: types.M{"type": "Enum", "values": types.S{"draft", "archived"}},
+			wantErr:   errs.E(errs.IncorrectType, "Enum field status can not remove values: published"),
+		},
+		{
+			name:      "4",
+			submitted: types.M{"type": "Enum", "values": types.S{}},
+			wantErr:   errs.E(errs.MissingRequiredFieldError, "type Enum needs a non-empty values array"),
+		},
+	}
+	for _, tt := range tests {
+		if err := validateEnumExtension("status", existing, tt.submitted); reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. validateEnumExtension() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_validateEnumValue(t *testing.T) {
+	enum := types.M{"type": "Enum", "values": types.S{"draft", "published"}}
+	tests := []struct {
+		name    string
+		t       types.M
+		value   interface{}
+		wantErr error
+	}{
+		{
+			name:    "1",
+			t:       enum,
+			value:   "draft",
+			wantErr: nil,
+		},
+		{
+			name:    "2",
+			t:       enum,
+			value:   "deleted",
+			wantErr: errs.E(errs.IncorrectType, "deleted is not a valid value for Enum field status, expected one of: draft, published"),
+		},
+		{
+			name:    "3",
+			t:       enum,
+			value:   types.M{"__op": "Delete"},
+			wantErr: nil,
+		},
+		{
+			name:    "4",
+			t:       types.M{"type": "String"},
+			value:   "deleted",
+			wantErr: nil,
+		},
+		{
+			name:    "5",
+			t:       nil,
+			value:   "deleted",
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		if err := validateEnumValue("status", tt.t, tt.value); reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. validateEnumValue() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
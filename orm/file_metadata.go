@@ -0,0 +1,151 @@
+package orm
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 开启 FileMetadata 后，上传文件时在 _File 表中保存文件的元数据，包括文件名、地址、类型、大小、上传者与标签，
+// 可以使用 Master Key 通过 /classes/_File 查询，查询对象时 File 字段的值中同时返回 contentType 、 size 与 tags ，
+// 客户端不需要再请求文件获取这些信息，删除文件时同时删除元数据
+
+// FileClassName 保存文件元数据的表
+const FileClassName = "_File"
+
+// fileMetadataKeys 查询对象时添加到 File 字段中的元数据
+var fileMetadataKeys = []string{"contentType", "size", "tags"}
+
+// ParseFileTags 解析 , 分隔的文件标签，去掉空白与重复的标签
+func ParseFileTags(s string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// NewFileMetadata 生成文件的元数据， uploader 为上传者的 objectId ，使用 Master Key 上传时为空
+func NewFileMetadata(name, url, contentType string, size int, uploader string, tags []string) types.M {
+	tagList := types.S{}
+	for _, tag := range tags {
+		tagList = append(tagList, tag)
+	}
+	now := utils.TimetoString(time.Now().UTC())
+	metadata := types.M{
+		"objectId":    utils.CreateObjectID(),
+		"name":        name,
+		"url":         url,
+		"contentType": contentType,
+		"size":        size,
+		"tags":        tagList,
+		"createdAt":   now,
+		"updatedAt":   now,
+	}
+	if uploader != "" {
+		metadata["uploader"] = types.M{
+			"__type":    "Pointer",
+			"className": "_User",
+			"objectId":  uploader,
+		}
+	}
+	return metadata
+}
+
+// SaveFileMetadata 保存文件的元数据
+func (d *DBController) SaveFileMetadata(metadata types.M) error {
+	return d.Create(FileClassName, metadata, types.M{})
+}
+
+// DeleteFileMetadata 删除文件的元数据，元数据不存在时不返回错误
+func (d *DBController) DeleteFileMetadata(name string) error {
+	err := d.Destroy(FileClassName, types.M{"name": name}, types.M{})
+	if err != nil && errs.GetErrorCode(err) != errs.ObjectNotFound {
+		return err
+	}
+	return nil
+}
+
+// FindFileMetadata 查询文件的元数据，返回文件名到元数据的映射
+func (d *DBController) FindFileMetadata(names []string) (map[string]types.M, error) {
+	result := map[string]types.M{}
+	if len(names) == 0 || d.LoadSchema(nil).HasClass(FileClassName) == false {
+		return result, nil
+	}
+	in := types.S{}
+	for _, name := range names {
+		in = append(in, name)
+	}
+	keys := append([]string{"name"}, fileMetadataKeys...)
+	objects, err := d.Find(FileClassName, types.M{"name": types.M{"$in": in}}, types.M{"keys": keys})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range objects {
+		if object := utils.M(v); object != nil {
+			result[utils.S(object["name"])] = object
+		}
+	}
+	return result, nil
+}
+
+// AddFileMetadata 把元数据添加到对象的 File 字段中， objects 为查询结果
+func AddFileMetadata(objects types.S, metadata map[string]types.M) {
+	for _, v := range objects {
+		object := utils.M(v)
+		if object == nil {
+			continue
+		}
+		for _, value := range object {
+			file := utils.M(value)
+			if file == nil || utils.S(file["__type"]) != "File" {
+				continue
+			}
+			m, ok := metadata[utils.S(file["name"])]
+			if ok == false {
+				continue
+			}
+			for _, key := range fileMetadataKeys {
+				if v, ok := m[key]; ok {
+					file[key] = v
+				}
+			}
+		}
+	}
+}
+
+// FileNamesInObjects 返回查询结果中所有 File 字段的文件名，去掉重复的文件名并排序
+func FileNamesInObjects(objects types.S) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, v := range objects {
+		object := utils.M(v)
+		if object == nil {
+			continue
+		}
+		for _, value := range object {
+			file := utils.M(value)
+			if file == nil || utils.S(file["__type"]) != "File" {
+				continue
+			}
+			name := utils.S(file["name"])
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,89 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_ParseFileTags(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []string
+	}{
+		{name: "1", s: "", want: []string{}},
+		{name: "2", s: "avatar", want: []string{"avatar"}},
+		{name: "3", s: " avatar, ,user,avatar ", want: []string{"avatar", "user"}},
+	}
+	for _, tt := range tests {
+		if got := ParseFileTags(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. ParseFileTags() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_NewFileMetadata(t *testing.T) {
+	metadata := NewFileMetadata("abc-hello.png", "http://127.0.0.1/files/abc-hello.png", "image/png", 1024, "1001", []string{"avatar"})
+	for _, key := range []string{"objectId", "createdAt", "updatedAt"} {
+		if metadata[key] == nil {
+			t.Error("expect:", key, "result:", metadata)
+		}
+		delete(metadata, key)
+	}
+	expect := types.M{
+		"name":        "abc-hello.png",
+		"url":         "http://127.0.0.1/files/abc-hello.png",
+		"contentType": "image/png",
+		"size":        1024,
+		"tags":        types.S{"avatar"},
+		"uploader":    types.M{"__type": "Pointer", "className": "_User", "objectId": "1001"},
+	}
+	if reflect.DeepEqual(expect, metadata) == false {
+		t.Error("expect:", expect, "result:", metadata)
+	}
+	/************************************************************/
+	metadata = NewFileMetadata("abc-hello.png", "http://127.0.0.1/files/abc-hello.png", "image/png", 1024, "", nil)
+	if _, ok := metadata["uploader"]; ok {
+		t.Error("expect:", "no uploader", "result:", metadata)
+	}
+}
+
+func Test_AddFileMetadata(t *testing.T) {
+	objects := types.S{
+		types.M{
+			"objectId": "1001",
+			"avatar":   types.M{"__type": "File", "name": "a.png", "url": "http://127.0.0.1/files/a.png"},
+			"cover":    types.M{"__type": "File", "name": "b.png", "url": "http://127.0.0.1/files/b.png"},
+		},
+		types.M{
+			"objectId": "1002",
+			"avatar":   types.M{"__type": "File", "name": "a.png", "url": "http://127.0.0.1/files/a.png"},
+			"title":    "hello",
+		},
+	}
+	names := FileNamesInObjects(objects)
+	if reflect.DeepEqual([]string{"a.png", "b.png"}, names) == false {
+		t.Error("expect:", []string{"a.png", "b.png"}, "result:", names)
+	}
+	metadata := map[string]types.M{
+		"a.png": types.M{"name": "a.png", "contentType": "image/png", "size": 1024.0, "tags": types.S{"avatar"}},
+	}
+	AddFileMetadata(objects, metadata)
+	expect := types.S{
+		types.M{
+			"objectId": "1001",
+			"avatar":   types.M{"__type": "File", "name": "a.png", "url": "http://127.0.0.1/files/a.png", "contentType": "image/png", "size": 1024.0, "tags": types.S{"avatar"}},
+			"cover":    types.M{"__type": "File", "name": "b.png", "url": "http://127.0.0.1/files/b.png"},
+		},
+		types.M{
+			"objectId": "1002",
+			"avatar":   types.M{"__type": "File", "name": "a.png", "url": "http://127.0.0.1/files/a.png", "contentType": "image/png", "size": 1024.0, "tags": types.S{"avatar"}},
+			"title":    "hello",
+		},
+	}
+	if reflect.DeepEqual(expect, objects) == false {
+		t.Error("expect:", expect, "result:", objects)
+	}
+}
@@ -0,0 +1,43 @@
+package orm
+
+import (
+	"time"
+
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 使用 Master Key 模拟用户登录时，每次成功创建 session 都在 _Impersonation 表中保存一条审计记录，
+// 包括被模拟的用户、 session 、操作人、 Master Key 标签、来源 IP 、原因与时间，
+// 记录只能使用 Master Key 查询，用户可以读取的 _Session 中只保存 impersonated 标记
+
+// ImpersonationClassName 保存模拟登录审计记录的表
+const ImpersonationClassName = "_Impersonation"
+
+// NewImpersonation 生成模拟登录的审计记录， info 中为 operator keyLabel ip reason
+func NewImpersonation(userID, sessionID string, info types.M, expiresAt time.Time) types.M {
+	now := time.Now().UTC()
+	record := types.M{
+		"objectId": utils.CreateObjectID(),
+		"user": types.M{
+			"__type":    "Pointer",
+			"className": "_User",
+			"objectId":  userID,
+		},
+		"session":   sessionID,
+		"expiresAt": utils.DateJSON(expiresAt),
+		"createdAt": utils.TimetoString(now),
+		"updatedAt": utils.TimetoString(now),
+		// 仅允许 Master 访问
+		"ACL": types.M{},
+	}
+	for _, key := range []string{"operator", "keyLabel", "ip", "reason"} {
+		record[key] = utils.S(info[key])
+	}
+	return record
+}
+
+// SaveImpersonation 保存模拟登录的审计记录
+func (d *DBController) SaveImpersonation(record types.M) error {
+	return d.Create(ImpersonationClassName, record, types.M{})
+}
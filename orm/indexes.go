@@ -0,0 +1,131 @@
+package orm
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/storage"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 类的索引格式与 Parse Dashboard 相同： {"索引名": {"字段名": 1}} ，倒序为 -1
+// 创建或者更新类时通过 indexes 添加索引，删除索引时为 {"索引名": {"__op": "Delete"}}
+
+// GetIndexes 返回类的索引，适配器不支持时返回 nil
+func (d *DBController) GetIndexes(className string) (types.M, error) {
+	manager, ok := Adapter.(storage.IndexManager)
+	if ok == false {
+		return nil, nil
+	}
+	schema := d.LoadSchema(nil)
+	sch, err := schema.GetOneSchema(className, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := manager.GetIndexes(className, sch)
+	if err != nil {
+		return nil, errs.FromAdapter(err)
+	}
+	return indexes, nil
+}
+
+// UpdateIndexes 按 submitted 添加与删除类的索引
+// JSON 对象中字段的顺序无法保留，包含多个字段的索引按字段名的顺序创建
+func (d *DBController) UpdateIndexes(className string, submitted types.M) error {
+	if len(submitted) == 0 {
+		return nil
+	}
+	manager, ok := Adapter.(storage.IndexManager)
+	if ok == false {
+		return errs.E(errs.CommandUnavailable, "Indexes are not supported by the database adapter.")
+	}
+	schema := d.LoadSchema(nil)
+	sch, err := schema.GetOneSchema(className, false, types.M{"clearCache": true})
+	if err != nil {
+		return err
+	}
+	if len(sch) == 0 {
+		return errs.E(errs.InvalidClassName, "Class "+className+" does not exist.")
+	}
+	existing, err := manager.GetIndexes(className, sch)
+	if err != nil {
+		return errs.FromAdapter(err)
+	}
+	creates, drops, err := validateIndexes(submitted, existing, utils.M(sch["fields"]))
+	if err != nil {
+		return err
+	}
+
+	for _, name := range drops {
+		err = manager.DropIndex(className, name)
+		if err != nil {
+			return errs.FromAdapter(err)
+		}
+	}
+	for _, name := range sortedIndexNames(creates) {
+		err = manager.CreateIndex(className, sch, name, creates[name])
+		if err != nil {
+			return errs.FromAdapter(err)
+		}
+	}
+	return nil
+}
+
+// validateIndexes 校验需要添加与删除的索引，返回需要创建的索引字段与需要删除的索引名
+func validateIndexes(submitted, existing, fields types.M) (map[string][]string, []string, error) {
+	creates := map[string][]string{}
+	drops := []string{}
+	for name, v := range submitted {
+		index := utils.M(v)
+		if index == nil {
+			return nil, nil, errs.E(errs.InvalidJSON, "Index "+name+" must be an object.")
+		}
+		if utils.S(index["__op"]) == "Delete" {
+			if existing[name] == nil {
+				return nil, nil, errs.E(errs.InvalidQuery, "Index "+name+" does not exist, cannot delete.")
+			}
+			if name == "_id_" {
+				return nil, nil, errs.E(errs.InvalidQuery, "Index _id_ cannot be deleted.")
+			}
+			drops = append(drops, name)
+			continue
+		}
+		if existing[name] != nil {
+			return nil, nil, errs.E(errs.InvalidQuery, "Index "+name+" exists, cannot update.")
+		}
+		if len(index) == 0 {
+			return nil, nil, errs.E(errs.InvalidJSON, "Index "+name+" must contain at least one field.")
+		}
+		fieldNames := []string{}
+		for fieldName, direction := range index {
+			if fields[fieldName] == nil {
+				return nil, nil, errs.E(errs.InvalidQuery, "Field "+fieldName+" does not exist, cannot add index.")
+			}
+			switch direction {
+			case 1, float64(1):
+				fieldNames = append(fieldNames, fieldName)
+			case -1, float64(-1):
+				fieldNames = append(fieldNames, "-"+fieldName)
+			default:
+				return nil, nil, errs.E(errs.InvalidJSON, "Index "+name+" has an invalid value for field "+fieldName+", must be 1 or -1.")
+			}
+		}
+		sort.Slice(fieldNames, func(i, j int) bool {
+			return strings.TrimPrefix(fieldNames[i], "-") < strings.TrimPrefix(fieldNames[j], "-")
+		})
+		creates[name] = fieldNames
+	}
+	sort.Strings(drops)
+	return creates, drops, nil
+}
+
+func sortedIndexNames(m map[string][]string) []string {
+	names := []string{}
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
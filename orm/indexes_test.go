@@ -0,0 +1,86 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_validateIndexes(t *testing.T) {
+	fields := types.M{
+		"objectId":  types.M{"type": "String"},
+		"createdAt": types.M{"type": "Date"},
+		"title":     types.M{"type": "String"},
+		"score":     types.M{"type": "Number"},
+	}
+	existing := types.M{
+		"_id_":    types.M{"objectId": 1},
+		"title_1": types.M{"title": 1},
+	}
+	var submitted types.M
+	var creates map[string][]string
+	var drops []string
+	var err error
+	var expectCreates map[string][]string
+	var expectDrops []string
+	var expectErr error
+	/************************************************************/
+	submitted = types.M{
+		"score_title": types.M{"title": 1.0, "score": -1.0},
+		"created":     types.M{"createdAt": 1},
+		"title_1":     types.M{"__op": "Delete"},
+	}
+	creates, drops, err = validateIndexes(submitted, existing, fields)
+	expectCreates = map[string][]string{
+		"score_title": []string{"-score", "title"},
+		"created":     []string{"createdAt"},
+	}
+	expectDrops = []string{"title_1"}
+	if err != nil || reflect.DeepEqual(expectCreates, creates) == false || reflect.DeepEqual(expectDrops, drops) == false {
+		t.Error("expect:", expectCreates, expectDrops, "result:", creates, drops, err)
+	}
+	/************************************************************/
+	submitted = types.M{"missing": types.M{"__op": "Delete"}}
+	_, _, err = validateIndexes(submitted, existing, fields)
+	expectErr = errs.E(errs.InvalidQuery, "Index missing does not exist, cannot delete.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/************************************************************/
+	submitted = types.M{"_id_": types.M{"__op": "Delete"}}
+	_, _, err = validateIndexes(submitted, existing, fields)
+	expectErr = errs.E(errs.InvalidQuery, "Index _id_ cannot be deleted.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/************************************************************/
+	submitted = types.M{"title_1": types.M{"title": -1}}
+	_, _, err = validateIndexes(submitted, existing, fields)
+	expectErr = errs.E(errs.InvalidQuery, "Index title_1 exists, cannot update.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/************************************************************/
+	submitted = types.M{"name_1": types.M{"name": 1}}
+	_, _, err = validateIndexes(submitted, existing, fields)
+	expectErr = errs.E(errs.InvalidQuery, "Field name does not exist, cannot add index.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/************************************************************/
+	submitted = types.M{"score_1": types.M{"score": 2}}
+	_, _, err = validateIndexes(submitted, existing, fields)
+	expectErr = errs.E(errs.InvalidJSON, "Index score_1 has an invalid value for field score, must be 1 or -1.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/************************************************************/
+	submitted = types.M{"empty": types.M{}}
+	_, _, err = validateIndexes(submitted, existing, fields)
+	expectErr = errs.E(errs.InvalidJSON, "Index empty must contain at least one field.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+}
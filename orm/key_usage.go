@@ -0,0 +1,35 @@
+package orm
+
+import (
+	"time"
+
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 每个密钥在 _KeyUsage 表中保存一条记录， objectId 为 类型:标签 ，记录使用次数、最后使用时间与来源地址，
+// 多个实例共享同一份记录，服务重启后不丢失，只能使用 Master Key 通过 /keys 或者 /classes/_KeyUsage 查询
+
+// KeyUsageClassName 保存密钥使用记录的表
+const KeyUsageClassName = "_KeyUsage"
+
+// SaveKeyUsage 累加密钥的使用次数，并更新最后使用时间与来源地址，记录不存在时创建
+func (d *DBController) SaveKeyUsage(keyType, label string, count int, lastUsedAt time.Time, lastIP string) error {
+	where := types.M{"objectId": keyType + ":" + label}
+	data := types.M{
+		"keyType":    keyType,
+		"label":      label,
+		"count":      types.M{"__op": "Increment", "amount": count},
+		"lastUsedAt": utils.DateJSON(lastUsedAt),
+		"lastIP":     lastIP,
+		// 仅允许 Master 访问
+		"ACL": types.M{},
+	}
+	_, _, err := d.Upsert(KeyUsageClassName, where, data, types.M{})
+	return err
+}
+
+// FindKeyUsages 查询全部密钥的使用记录
+func (d *DBController) FindKeyUsages() (types.S, error) {
+	return d.Find(KeyUsageClassName, types.M{}, types.M{})
+}
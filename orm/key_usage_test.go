@@ -0,0 +1,33 @@
+package orm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/okobsamoht/talisman/utils"
+)
+
+func Test_SaveKeyUsage(t *testing.T) {
+	initEnv()
+	var err error
+	/*************************************************/
+	lastUsedAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	err = TalismanDBController.SaveKeyUsage("client", "ios", 3, lastUsedAt, "10.0.0.1")
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	err = TalismanDBController.SaveKeyUsage("client", "ios", 2, lastUsedAt.Add(time.Hour), "10.0.0.2")
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	results, err := TalismanDBController.FindKeyUsages()
+	if err != nil || len(results) != 1 {
+		t.Error("expect:", 1, "result:", results, err)
+	} else {
+		record := utils.M(results[0])
+		if record["objectId"] != "client:ios" || record["count"] != 5 || record["lastIP"] != "10.0.0.2" {
+			t.Error("expect:", "client:ios", 5, "10.0.0.2", "result:", record)
+		}
+	}
+	TalismanDBController.DeleteEverything()
+}
@@ -0,0 +1,164 @@
+package orm
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 字段类型为 LocalizedString 时，值为语言到字符串的对象，如 {"en":"Hello","zh-CN":"你好"} ，
+// 查询时通过 locale 指定语言，结果中的字段替换为对应语言的字符串，不指定时返回完整的对象
+// locale 可以包含多个使用 , 分隔的候选语言，每个语言依次去掉最后一段继续尝试，如 zh-Hant-TW 依次尝试 zh-Hant 、 zh ，
+// 都不存在时使用 DefaultLocales ，仍然不存在时结果中不包含该字段
+// 查询条件与排序可以使用 title.en 形式的子字段，修改单个语言时同样使用 title.en 作为字段名
+
+var localeRegex = regexp.MustCompile(`^[a-zA-Z]{2,3}([-_][a-zA-Z0-9]{1,8})*$`)
+
+// localeIsValid 校验语言标记，如 en 、 zh-CN 、 zh-Hant-TW
+func localeIsValid(locale string) bool {
+	return localeRegex.MatchString(locale)
+}
+
+// localizedStringFieldsOf 返回类中的 LocalizedString 字段，按字段名排序
+func localizedStringFieldsOf(fields types.M) []string {
+	result := []string{}
+	for fieldName, v := range fields {
+		if utils.S(utils.M(v)["type"]) == "LocalizedString" {
+			result = append(result, fieldName)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// validateLocalizedStringWrite 校验写入 LocalizedString 字段的值，整个字段的值必须为语言到字符串的对象，
+// 使用 title.en 修改单个语言时值必须为字符串或者 Delete 操作
+func validateLocalizedStringWrite(fields, object types.M) error {
+	for key, value := range object {
+		fieldName := key
+		locale := ""
+		if i := strings.Index(key, "."); i > 0 {
+			fieldName = key[:i]
+			locale = key[i+1:]
+		}
+		if utils.S(utils.M(fields[fieldName])["type"]) != "LocalizedString" {
+			continue
+		}
+		if locale != "" {
+			if localeIsValid(locale) == false {
+				return errs.E(errs.InvalidKeyName, "Invalid locale for LocalizedString field: "+key)
+			}
+			if _, ok := value.(string); ok {
+				continue
+			}
+			if op := utils.M(value); op != nil && utils.S(op["__op"]) == "Delete" {
+				continue
+			}
+			return errs.E(errs.IncorrectType, key+" must be a string")
+		}
+		if value == nil {
+			continue
+		}
+		values := utils.M(value)
+		if values == nil {
+			return errs.E(errs.IncorrectType, fieldName+" must be an object of locale and string")
+		}
+		if utils.S(values["__op"]) == "Delete" {
+			continue
+		}
+		for l, v := range values {
+			if localeIsValid(l) == false {
+				return errs.E(errs.InvalidKeyName, "Invalid locale for LocalizedString field: "+fieldName+"."+l)
+			}
+			if _, ok := v.(string); ok == false {
+				return errs.E(errs.IncorrectType, fieldName+"."+l+" must be a string")
+			}
+		}
+	}
+	return nil
+}
+
+// localeFallbacks 展开候选语言， locales 为 , 分隔的语言，每个语言之后依次加入去掉最后一段的语言，最后追加 defaults
+// 重复的语言只保留第一次出现的位置
+func localeFallbacks(locales string, defaults []string) []string {
+	result := []string{}
+	seen := map[string]bool{}
+	add := func(locale string) {
+		key := normalizeLocale(locale)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		result = append(result, locale)
+	}
+	for _, locale := range strings.Split(locales, ",") {
+		locale = strings.TrimSpace(locale)
+		for locale != "" {
+			add(locale)
+			i := strings.LastIndexAny(locale, "-_")
+			if i < 0 {
+				break
+			}
+			locale = locale[:i]
+		}
+	}
+	for _, locale := range defaults {
+		if locale = strings.TrimSpace(locale); locale != "" {
+			add(locale)
+		}
+	}
+	return result
+}
+
+// normalizeLocale 比较语言时不区分大小写，并且 _ 与 - 等同
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.Replace(locale, "_", "-", -1))
+}
+
+// lookupLocale 从 values 中取出 locale 对应的字符串，优先使用完全相同的语言
+func lookupLocale(values types.M, locale string) (string, bool) {
+	if s, ok := values[locale].(string); ok {
+		return s, true
+	}
+	key := normalizeLocale(locale)
+	// 按语言排序，保证多个语言只有大小写不同时结果稳定
+	locales := make([]string, 0, len(values))
+	for l := range values {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	for _, l := range locales {
+		if normalizeLocale(l) != key {
+			continue
+		}
+		if s, ok := values[l].(string); ok {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// resolveLocalizedStrings 把对象中的 LocalizedString 字段替换为候选语言中第一个存在的字符串，都不存在时删除该字段
+func resolveLocalizedStrings(object types.M, fieldNames []string, locales []string) {
+	for _, fieldName := range fieldNames {
+		v, ok := object[fieldName]
+		if ok == false {
+			continue
+		}
+		values := utils.M(v)
+		if values == nil {
+			continue
+		}
+		delete(object, fieldName)
+		for _, locale := range locales {
+			if s, ok := lookupLocale(values, locale); ok {
+				object[fieldName] = s
+				break
+			}
+		}
+	}
+}
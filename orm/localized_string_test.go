@@ -0,0 +1,145 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_validateLocalizedStringWrite(t *testing.T) {
+	fields := types.M{
+		"title":   types.M{"type": "LocalizedString"},
+		"profile": types.M{"type": "Object"},
+	}
+	tests := []struct {
+		name    string
+		object  types.M
+		wantErr error
+	}{
+		{
+			name:    "1",
+			object:  types.M{"title": types.M{"en": "Hello", "zh-CN": "你好"}, "profile": types.M{"a": 1}},
+			wantErr: nil,
+		},
+		{
+			name:    "2",
+			object:  types.M{"title.zh_TW": "妳好", "profile.a": 1},
+			wantErr: nil,
+		},
+		{
+			name:    "3",
+			object:  types.M{"title": types.M{"__op": "Delete"}, "title.en": types.M{"__op": "Delete"}},
+			wantErr: nil,
+		},
+		{
+			name:    "4",
+			object:  types.M{"title": "Hello"},
+			wantErr: errs.E(errs.IncorrectType, "title must be an object of locale and string"),
+		},
+		{
+			name:    "5",
+			object:  types.M{"title": types.M{"english": "Hello"}},
+			wantErr: errs.E(errs.InvalidKeyName, "Invalid locale for LocalizedString field: title.english"),
+		},
+		{
+			name:    "6",
+			object:  types.M{"title": types.M{"en": 1}},
+			wantErr: errs.E(errs.IncorrectType, "title.en must be a string"),
+		},
+		{
+			name:    "7",
+			object:  types.M{"title.en": types.M{"a": "b"}},
+			wantErr: errs.E(errs.IncorrectType, "title.en must be a string"),
+		},
+		{
+			name:    "8",
+			object:  types.M{"title.e": "Hello"},
+			wantErr: errs.E(errs.InvalidKeyName, "Invalid locale for LocalizedString field: title.e"),
+		},
+	}
+	for _, tt := range tests {
+		if err := validateLocalizedStringWrite(fields, tt.object); !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%q. validateLocalizedStringWrite() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_localeFallbacks(t *testing.T) {
+	tests := []struct {
+		name     string
+		locales  string
+		defaults []string
+		want     []string
+	}{
+		{
+			name:     "1",
+			locales:  "en",
+			defaults: nil,
+			want:     []string{"en"},
+		},
+		{
+			name:     "2",
+			locales:  "zh-Hant-TW",
+			defaults: []string{"en"},
+			want:     []string{"zh-Hant-TW", "zh-Hant", "zh", "en"},
+		},
+		{
+			name:     "3",
+			locales:  "pt_BR, zh-CN,zh ,",
+			defaults: []string{"zh", "EN"},
+			want:     []string{"pt_BR", "pt", "zh-CN", "zh", "EN"},
+		},
+	}
+	for _, tt := range tests {
+		if got := localeFallbacks(tt.locales, tt.defaults); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. localeFallbacks() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_resolveLocalizedStrings(t *testing.T) {
+	tests := []struct {
+		name       string
+		object     types.M
+		fieldNames []string
+		locales    []string
+		want       types.M
+	}{
+		{
+			name:       "1",
+			object:     types.M{"title": types.M{"en": "Hello", "zh-CN": "你好"}, "name": "a"},
+			fieldNames: []string{"title"},
+			locales:    []string{"zh-CN", "zh", "en"},
+			want:       types.M{"title": "你好", "name": "a"},
+		},
+		{
+			name:       "2",
+			object:     types.M{"title": types.M{"en": "Hello", "zh_cn": "你好"}},
+			fieldNames: []string{"title"},
+			locales:    []string{"zh-CN", "zh", "en"},
+			want:       types.M{"title": "你好"},
+		},
+		{
+			name:       "3",
+			object:     types.M{"title": types.M{"en": "Hello"}, "subtitle": types.M{"fr": "Salut"}},
+			fieldNames: []string{"subtitle", "title"},
+			locales:    []string{"de", "en"},
+			want:       types.M{"title": "Hello"},
+		},
+		{
+			name:       "4",
+			object:     types.M{"name": "a"},
+			fieldNames: []string{"title"},
+			locales:    []string{"en"},
+			want:       types.M{"name": "a"},
+		},
+	}
+	for _, tt := range tests {
+		resolveLocalizedStrings(tt.object, tt.fieldNames, tt.locales)
+		if !reflect.DeepEqual(tt.object, tt.want) {
+			t.Errorf("%q. resolveLocalizedStrings() = %v, want %v", tt.name, tt.object, tt.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package orm
+
+import (
+	"strings"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 类级别权限中的 masterOnlyFields 为只能使用 Master Key 写入的字段，如 {"masterOnlyFields": ["isAdmin", "credits"]} ，
+// 与 create update 等操作权限无关，不使用 Master Key 创建或者修改对象时包含这些字段则拒绝写入，客户端仍然可以读取这些字段
+// 字段不需要已经存在于类中，云代码的 beforeSave 中修改这些字段时同样需要使用 Master Key 写入
+// 与 allowAddField 一样通过单独的接口 PUT /schemas/:className/masterOnlyFields 设置，只修改该项，类的其他权限保持不变，
+// 修改类级别权限时未提交 masterOnlyFields 则保留原有的设置，避免读取再写回类级别权限时丢失
+
+// masterOnlyFieldsOf 从类级别权限中读取只能使用 Master Key 写入的字段
+func masterOnlyFieldsOf(perms types.M) []string {
+	if perms == nil {
+		return nil
+	}
+	fields := []string{}
+	for _, v := range utils.A(perms["masterOnlyFields"]) {
+		if fieldName, ok := v.(string); ok {
+			fields = append(fields, fieldName)
+		}
+	}
+	return fields
+}
+
+// validateMasterOnlyFields 必须为字段名数组，不能包含 objectId createdAt updatedAt
+func validateMasterOnlyFields(perm interface{}) error {
+	fields := utils.A(perm)
+	if fields == nil {
+		return errs.E(errs.InvalidJSON, "masterOnlyFields must be an array of field names for class level permissions")
+	}
+	for _, v := range fields {
+		fieldName, ok := v.(string)
+		if ok == false || fieldNameIsValid(fieldName) == false {
+			return errs.E(errs.InvalidJSON, utils.S(v)+" is not a valid column for class level permissions masterOnlyFields")
+		}
+		if fieldName != "ACL" && DefaultColumns["_Default"][fieldName] != nil {
+			return errs.E(errs.InvalidJSON, fieldName+" is not a valid column for class level permissions masterOnlyFields")
+		}
+	}
+	return nil
+}
+
+// masterOnlyFields 返回类中只能使用 Master Key 写入的字段
+func (s *Schema) masterOnlyFields(className string) []string {
+	s.permsMutex.Lock()
+	defer s.permsMutex.Unlock()
+	if s.perms == nil {
+		return nil
+	}
+	return masterOnlyFieldsOf(utils.M(s.perms[className]))
+}
+
+// validateMasterOnlyWrite 不使用 Master Key 时不能写入 fields 中的字段，包括 a.b 形式修改子字段
+func validateMasterOnlyWrite(fields []string, object types.M, isMaster bool) error {
+	if isMaster || len(fields) == 0 {
+		return nil
+	}
+	for key := range object {
+		for _, fieldName := range fields {
+			if key == fieldName || strings.HasPrefix(key, fieldName+".") {
+				return errs.E(errs.OperationForbidden, fieldName+" can only be set with the master key.")
+			}
+		}
+	}
+	return nil
+}
+
+// withoutDefaultFields 去掉 options 中 defaultFields 列出的字段，这些字段由类的默认值写入，不是客户端提交的数据
+func withoutDefaultFields(object, options types.M) types.M {
+	defaultFields, _ := options["defaultFields"].([]string)
+	if len(defaultFields) == 0 {
+		return object
+	}
+	result := types.M{}
+	for k, v := range object {
+		result[k] = v
+	}
+	for _, fieldName := range defaultFields {
+		delete(result, fieldName)
+	}
+	return result
+}
+
+// SetMasterOnlyFields 设置类中只能使用 Master Key 写入的字段， fields 为空时删除该设置，类级别权限的其他项保持不变
+func (s *Schema) SetMasterOnlyFields(className string, fields []string) (types.M, error) {
+	schema, err := s.GetOneSchema(className, false, types.M{"clearCache": true})
+	if err != nil {
+		return nil, err
+	}
+	if len(schema) == 0 {
+		return nil, errs.E(errs.InvalidClassName, "Class "+className+" does not exist.")
+	}
+	perms := utils.CopyMap(utils.M(schema["classLevelPermissions"]))
+	if perms == nil {
+		perms = types.M{}
+	}
+	if len(fields) == 0 {
+		delete(perms, "masterOnlyFields")
+	} else {
+		perms["masterOnlyFields"] = toSliceS(fields)
+	}
+	err = s.setPermissions(className, perms, utils.M(schema["fields"]))
+	if err != nil {
+		return nil, err
+	}
+	return types.M{
+		"className":             className,
+		"fields":                schema["fields"],
+		"classLevelPermissions": perms,
+	}, nil
+}
+
+// keepMasterOnlyFields 提交的类级别权限中没有 masterOnlyFields 时，保留类原有的设置
+func (s *Schema) keepMasterOnlyFields(className string, perms types.M) types.M {
+	if perms == nil {
+		return nil
+	}
+	if _, ok := perms["masterOnlyFields"]; ok {
+		return perms
+	}
+	fields := s.masterOnlyFields(className)
+	if len(fields) == 0 {
+		return perms
+	}
+	result := utils.CopyMap(perms)
+	result["masterOnlyFields"] = toSliceS(fields)
+	return result
+}
+
+// toSliceS ...
+func toSliceS(fields []string) types.S {
+	result := types.S{}
+	for _, fieldName := range fields {
+		result = append(result, fieldName)
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+func Test_validateMasterOnlyFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		perm    interface{}
+		wantErr error
+	}{
+		{name: "1", perm: types.S{"isAdmin", "credits"}, wantErr: nil},
+		{name: "2", perm: []interface{}{"ACL"}, wantErr: nil},
+		{name: "3", perm: "isAdmin", wantErr: errs.E(errs.InvalidJSON, "masterOnlyFields must be an array of field names for class level permissions")},
+		{name: "4", perm: types.S{"objectId"}, wantErr: errs.E(errs.InvalidJSON, "objectId is not a valid column for class level permissions masterOnlyFields")},
+		{name: "5", perm: types.S{"1abc"}, wantErr: errs.E(errs.InvalidJSON, "1abc is not a valid column for class level permissions masterOnlyFields")},
+	}
+	for _, tt := range tests {
+		if err := validateMasterOnlyFields(tt.perm); reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. validateMasterOnlyFields() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_validateMasterOnlyWrite(t *testing.T) {
+	fields := []string{"isAdmin", "credits"}
+	tests := []struct {
+		name     string
+		object   types.M
+		isMaster bool
+		wantErr  error
+	}{
+		{name: "1", object: types.M{"title": "hello"}, wantErr: nil},
+		{name: "2", object: types.M{"isAdmin": true}, wantErr: errs.E(errs.OperationForbidden, "isAdmin can only be set with the master key.")},
+		{name: "3", object: types.M{"credits": types.M{"__op": "Increment", "amount": 10}}, wantErr: errs.E(errs.OperationForbidden, "credits can only be set with the master key.")},
+		{name: "4", object: types.M{"credits.bonus": 10}, wantErr: errs.E(errs.OperationForbidden, "credits can only be set with the master key.")},
+		{name: "5", object: types.M{"creditsNote": "x"}, wantErr: nil},
+		{name: "6", object: types.M{"isAdmin": true}, isMaster: true, wantErr: nil},
+	}
+	for _, tt := range tests {
+		if err := validateMasterOnlyWrite(fields, tt.object, tt.isMaster); reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. validateMasterOnlyWrite() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_withoutDefaultFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		object  types.M
+		options types.M
+		want    types.M
+	}{
+		{name: "1", object: types.M{"credits": 0}, options: types.M{}, want: types.M{"credits": 0}},
+		{name: "2", object: types.M{"title": "hello", "credits": 0}, options: types.M{"defaultFields": []string{"credits"}}, want: types.M{"title": "hello"}},
+		{name: "3", object: types.M{"credits": 0}, options: types.M{"defaultFields": []string{"status"}}, want: types.M{"credits": 0}},
+	}
+	for _, tt := range tests {
+		if got := withoutDefaultFields(tt.object, tt.options); reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("%q. withoutDefaultFields() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_SetMasterOnlyFields(t *testing.T) {
+	adapter := getAdapter()
+	schama := getSchema()
+	var className string
+	var perms types.M
+	var result types.M
+	var err error
+	var expect interface{}
+	clp := func() types.M {
+		p := utils.CopyMap(utils.M(schama.perms[className]))
+		delete(p, "masterOnlyFields")
+		return p
+	}
+	/************************************************************/
+	className = "post"
+	adapter.CreateClass(className, types.M{"fields": types.M{"credits": types.M{"type": "Number"}}})
+	perms = types.M{
+		"get":      types.M{"*": true},
+		"find":     types.M{"role:admin": true},
+		"create":   types.M{"*": true},
+		"update":   types.M{"requiresAuthentication": true},
+		"delete":   types.M{},
+		"addField": types.M{},
+	}
+	err = schama.setPermissions(className, perms, nil)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	_, err = schama.SetMasterOnlyFields(className, []string{"credits", "isAdmin"})
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	expect = []string{"credits", "isAdmin"}
+	if reflect.DeepEqual(expect, schama.masterOnlyFields(className)) == false {
+		t.Error("expect:", expect, "result:", schama.masterOnlyFields(className))
+	}
+	if reflect.DeepEqual(map[string]interface{}(perms), clp()) == false {
+		t.Error("expect:", perms, "result:", clp())
+	}
+	/************************************************************/
+	// 读取再写回类级别权限，权限与 masterOnlyFields 均保持不变
+	result, err = schama.GetOneSchema(className, false, types.M{"clearCache": true})
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	_, err = schama.UpdateClass(className, types.M{}, utils.M(utils.DeepCopy(result["classLevelPermissions"])))
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	if reflect.DeepEqual(expect, schama.masterOnlyFields(className)) == false {
+		t.Error("expect:", expect, "result:", schama.masterOnlyFields(className))
+	}
+	if reflect.DeepEqual(map[string]interface{}(perms), clp()) == false {
+		t.Error("expect:", perms, "result:", clp())
+	}
+	/************************************************************/
+	// 提交的类级别权限中没有 masterOnlyFields 时保留原有设置
+	_, err = schama.UpdateClass(className, types.M{}, utils.CopyMap(perms))
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	if reflect.DeepEqual(expect, schama.masterOnlyFields(className)) == false {
+		t.Error("expect:", expect, "result:", schama.masterOnlyFields(className))
+	}
+	/************************************************************/
+	_, err = schama.SetMasterOnlyFields(className, nil)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	expect = []string{}
+	if reflect.DeepEqual(expect, schama.masterOnlyFields(className)) == false {
+		t.Error("expect:", expect, "result:", schama.masterOnlyFields(className))
+	}
+	if reflect.DeepEqual(map[string]interface{}(perms), clp()) == false {
+		t.Error("expect:", perms, "result:", clp())
+	}
+	adapter.DeleteAllClasses()
+}
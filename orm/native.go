@@ -0,0 +1,107 @@
+package orm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 作为库使用时，对象中可能包含 Go 的原生类型，如 time.Time 、 []string 、 map[string]string 、 json.Number ，
+// 校验之前先转换为对应的 Parse 类型，转换后的对象与从 REST 接口解析的对象格式相同
+
+// normalizeObject 把 object 中的原生类型转换为 Parse 类型，直接修改 object
+func normalizeObject(object map[string]interface{}) {
+	for key, value := range object {
+		if v, ok := toParseValue(value); ok {
+			object[key] = v
+		}
+	}
+}
+
+// toParseValue 把原生类型的值转换为 Parse 类型，包括 slice 与 map 中的元素，不需要转换时返回 false
+func toParseValue(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case nil, bool, string, float64, int:
+		return nil, false
+	case time.Time:
+		return types.M{"__type": "Date", "iso": utils.TimetoString(v)}, true
+	case *time.Time:
+		if v == nil {
+			return nil, false
+		}
+		return types.M{"__type": "Date", "iso": utils.TimetoString(*v)}, true
+	case []byte:
+		if v == nil {
+			return nil, false
+		}
+		return types.M{"__type": "Bytes", "base64": base64.StdEncoding.EncodeToString(v)}, true
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return nil, false
+		}
+		return f, true
+	case types.M:
+		normalizeObject(v)
+		return nil, false
+	case map[string]interface{}:
+		normalizeObject(v)
+		return nil, false
+	case types.S:
+		normalizeSlice(v)
+		return nil, false
+	case []interface{}:
+		normalizeSlice(v)
+		return nil, false
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32:
+		return rv.Float(), true
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil, false
+		}
+		result := types.S{}
+		for i := 0; i < rv.Len(); i++ {
+			item := rv.Index(i).Interface()
+			if v, ok := toParseValue(item); ok {
+				item = v
+			}
+			result = append(result, item)
+		}
+		return result, true
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String || rv.IsNil() {
+			return nil, false
+		}
+		result := types.M{}
+		for _, k := range rv.MapKeys() {
+			item := rv.MapIndex(k).Interface()
+			if v, ok := toParseValue(item); ok {
+				item = v
+			}
+			result[k.String()] = item
+		}
+		return result, true
+	}
+	return nil, false
+}
+
+// normalizeSlice 把 s 中的原生类型转换为 Parse 类型，直接修改 s
+func normalizeSlice(s []interface{}) {
+	for i, value := range s {
+		if v, ok := toParseValue(value); ok {
+			s[i] = v
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package orm
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_toParseValue(t *testing.T) {
+	now := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		value  interface{}
+		want   interface{}
+		wantOK bool
+	}{
+		{name: "1", value: "hello", want: nil, wantOK: false},
+		{name: "2", value: 10, want: nil, wantOK: false},
+		{name: "3", value: now, want: types.M{"__type": "Date", "iso": "2006-01-02T15:04:05.000Z"}, wantOK: true},
+		{name: "4", value: &now, want: types.M{"__type": "Date", "iso": "2006-01-02T15:04:05.000Z"}, wantOK: true},
+		{name: "5", value: json.Number("10.5"), want: 10.5, wantOK: true},
+		{name: "6", value: int64(10), want: 10.0, wantOK: true},
+		{name: "7", value: float32(0.5), want: 0.5, wantOK: true},
+		{name: "8", value: []string{"a", "b"}, want: types.S{"a", "b"}, wantOK: true},
+		{name: "9", value: map[string]string{"a": "b"}, want: types.M{"a": "b"}, wantOK: true},
+		{name: "10", value: []byte("hi"), want: types.M{"__type": "Bytes", "base64": "aGk="}, wantOK: true},
+		{name: "11", value: []map[string]time.Time{{"t": now}}, want: types.S{types.M{"t": types.M{"__type": "Date", "iso": "2006-01-02T15:04:05.000Z"}}}, wantOK: true},
+		{name: "12", value: map[int]string{1: "a"}, want: nil, wantOK: false},
+		{name: "13", value: struct{}{}, want: nil, wantOK: false},
+	}
+	for _, tt := range tests {
+		got, ok := toParseValue(tt.value)
+		if ok != tt.wantOK {
+			t.Errorf("%q. toParseValue() ok = %v, want %v", tt.name, ok, tt.wantOK)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. toParseValue() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_normalizeObject(t *testing.T) {
+	now := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	object := types.M{
+		"key":  "hello",
+		"date": now,
+		"tags": []string{"a"},
+		"sub":  types.M{"n": json.Number("1")},
+		"list": types.S{now},
+	}
+	normalizeObject(object)
+	expect := types.M{
+		"key":  "hello",
+		"date": types.M{"__type": "Date", "iso": "2006-01-02T15:04:05.000Z"},
+		"tags": types.S{"a"},
+		"sub":  types.M{"n": 1.0},
+		"list": types.S{types.M{"__type": "Date", "iso": "2006-01-02T15:04:05.000Z"}},
+	}
+	if reflect.DeepEqual(expect, object) == false {
+		t.Error("expect:", expect, "result:", object)
+	}
+}
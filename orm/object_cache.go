@@ -0,0 +1,143 @@
+package orm
+
+import (
+	"github.com/okobsamoht/talisman/cache"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 对象缓存，位于 Adapter 之前，缓存按 objectId 查询到的单个对象
+// 缓存的是 Adapter 返回的原始数据，包含 _rperm ，命中缓存时在内存中校验读权限
+// 通过 objectId 更新、删除对象时同步更新缓存，批量更新、删除对象时更换类的缓存版本，该类已缓存的对象全部失效
+
+// objectCacheEnabled 是否启用对象缓存
+func objectCacheEnabled() bool {
+	return config.TConfig.ObjectCacheTTL > 0
+}
+
+// objectCacheKey 缓存的键中包含类的缓存版本，更换版本后旧的对象不会再被读取
+func objectCacheKey(className, objectID string) string {
+	return className + ":" + objectCacheGeneration(className) + ":" + objectID
+}
+
+// objectCacheGeneration 返回类的缓存版本，不存在时创建新的版本
+// 版本的有效期与对象相同，并且先于对象写入，版本过期后该类已缓存的对象同样失效
+func objectCacheGeneration(className string) string {
+	generation := utils.S(cache.Object.Get(className))
+	if generation == "" {
+		generation = utils.CreateToken()
+		cache.Object.Put(className, generation, int64(config.TConfig.ObjectCacheTTL))
+	}
+	return generation
+}
+
+// findWithObjectCache 按 objectId 查询单个对象时优先从缓存中获取，其他查询直接交给 Adapter
+func findWithObjectCache(className string, schema, query, options types.M) ([]types.M, error) {
+	objectID, ok := objectCacheQuery(query, options)
+	if ok == false {
+		objects, err := Adapter.Find(className, schema, query, options)
+		return objects, errs.FromAdapter(err)
+	}
+
+	if object := utils.M(cache.Object.Get(objectCacheKey(className, objectID))); object != nil {
+		if matchesReadACL(object, query["_rperm"]) == false {
+			return []types.M{}, nil
+		}
+		return []types.M{utils.CopyMap(object)}, nil
+	}
+
+	objects, err := Adapter.Find(className, schema, query, options)
+	if err != nil {
+		return nil, errs.FromAdapter(err)
+	}
+	if len(objects) == 1 {
+		cache.Object.Put(objectCacheKey(className, objectID), utils.CopyMap(objects[0]), int64(config.TConfig.ObjectCacheTTL))
+	}
+	return objects, nil
+}
+
+// objectCacheQuery 判断查询是否可以使用对象缓存，仅支持 objectId 与 _rperm 组成的查询，并且不能指定返回字段
+func objectCacheQuery(query, options types.M) (string, bool) {
+	if objectCacheEnabled() == false {
+		return "", false
+	}
+	objectID, ok := query["objectId"].(string)
+	if ok == false || objectID == "" {
+		return "", false
+	}
+	for key := range query {
+		if key != "objectId" && key != "_rperm" {
+			return "", false
+		}
+	}
+	if _, ok := options["keys"]; ok {
+		return "", false
+	}
+	if skip, ok := options["skip"].(int); ok && skip > 0 {
+		return "", false
+	}
+	if limit, ok := options["limit"].(int); ok && limit == 0 {
+		return "", false
+	}
+	return objectID, true
+}
+
+// matchesReadACL 校验缓存对象的 _rperm 是否满足查询条件 {"$in":[nil,"*","userid","role:xxx"]}
+// 对象中不存在 _rperm 时，表示所有人可读
+func matchesReadACL(object types.M, rperm interface{}) bool {
+	if rperm == nil {
+		return true
+	}
+	in := utils.A(utils.M(rperm)["$in"])
+	perms := utils.A(object["_rperm"])
+	if perms == nil {
+		return true
+	}
+	for _, p := range perms {
+		for _, a := range in {
+			if a != nil && utils.S(a) == utils.S(p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// putObjectCache 更新对象之后，写入最新的对象
+func putObjectCache(className, objectID string, object types.M) {
+	if objectCacheEnabled() == false || objectID == "" {
+		return
+	}
+	if len(object) == 0 {
+		cache.Object.Del(objectCacheKey(className, objectID))
+		return
+	}
+	cache.Object.Put(objectCacheKey(className, objectID), utils.CopyMap(object), int64(config.TConfig.ObjectCacheTTL))
+}
+
+// delObjectCache 删除对象之后，从缓存中删除
+func delObjectCache(className, objectID string) {
+	if objectCacheEnabled() == false || objectID == "" {
+		return
+	}
+	cache.Object.Del(objectCacheKey(className, objectID))
+}
+
+// clearClassObjectCache 批量更新、删除对象之后，更换类的缓存版本，使该类已缓存的对象全部失效
+// 无法得知受影响的 objectId ，并且多个实例共享缓存，因此不逐个删除
+func clearClassObjectCache(className string) {
+	if objectCacheEnabled() == false {
+		return
+	}
+	cache.Object.Put(className, utils.CreateToken(), int64(config.TConfig.ObjectCacheTTL))
+}
+
+// clearObjectCache 清空整个类时，清空缓存
+func clearObjectCache() {
+	if objectCacheEnabled() == false {
+		return
+	}
+	cache.Object.Clear()
+}
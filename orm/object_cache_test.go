@@ -0,0 +1,163 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/cache"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+func Test_objectCacheQuery(t *testing.T) {
+	ttl := config.TConfig.ObjectCacheTTL
+	config.TConfig.ObjectCacheTTL = 5
+	defer func() { config.TConfig.ObjectCacheTTL = ttl }()
+	type args struct {
+		query   types.M
+		options types.M
+	}
+	tests := []struct {
+		name   string
+		args   args
+		want   string
+		wantOk bool
+	}{
+		{
+			name:   "1",
+			args:   args{query: types.M{"objectId": "1001"}, options: types.M{}},
+			want:   "1001",
+			wantOk: true,
+		},
+		{
+			name:   "2",
+			args:   args{query: types.M{"objectId": "1001", "_rperm": types.M{"$in": types.S{nil, "*"}}}, options: types.M{"limit": 1}},
+			want:   "1001",
+			wantOk: true,
+		},
+		{
+			name:   "3",
+			args:   args{query: types.M{"objectId": "1001", "key": "hello"}, options: types.M{}},
+			want:   "",
+			wantOk: false,
+		},
+		{
+			name:   "4",
+			args:   args{query: types.M{"objectId": "1001"}, options: types.M{"keys": "key"}},
+			want:   "",
+			wantOk: false,
+		},
+		{
+			name:   "5",
+			args:   args{query: types.M{"objectId": types.M{"$in": types.S{"1001"}}}, options: types.M{}},
+			want:   "",
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		got, gotOk := objectCacheQuery(tt.args.query, tt.args.options)
+		if got != tt.want || gotOk != tt.wantOk {
+			t.Errorf("%q. objectCacheQuery() = %v, %v, want %v, %v", tt.name, got, gotOk, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func Test_matchesReadACL(t *testing.T) {
+	type args struct {
+		object types.M
+		rperm  interface{}
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			name: "1",
+			args: args{object: types.M{"_rperm": types.S{"1001"}}, rperm: nil},
+			want: true,
+		},
+		{
+			name: "2",
+			args: args{object: types.M{}, rperm: types.M{"$in": types.S{nil, "*"}}},
+			want: true,
+		},
+		{
+			name: "3",
+			args: args{object: types.M{"_rperm": types.S{"1001"}}, rperm: types.M{"$in": types.S{nil, "*"}}},
+			want: false,
+		},
+		{
+			name: "4",
+			args: args{object: types.M{"_rperm": []interface{}{"role:admin"}}, rperm: types.M{"$in": types.S{nil, "*", "1001", "role:admin"}}},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		if got := matchesReadACL(tt.args.object, tt.args.rperm); reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("%q. matchesReadACL() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_clearClassObjectCache(t *testing.T) {
+	ttl := config.TConfig.ObjectCacheTTL
+	config.TConfig.ObjectCacheTTL = 5
+	defer func() { config.TConfig.ObjectCacheTTL = ttl }()
+	cache.InitCache()
+	var result interface{}
+	/********************************************************/
+	putObjectCache("Post", "1001", types.M{"objectId": "1001", "_rperm": types.S{"*"}})
+	putObjectCache("Comment", "1001", types.M{"objectId": "1001"})
+	clearClassObjectCache("Post")
+	result = cache.Object.Get(objectCacheKey("Post", "1001"))
+	if result != nil {
+		t.Error("expect:", nil, "result:", result)
+	}
+	result = cache.Object.Get(objectCacheKey("Comment", "1001"))
+	if utils.S(utils.M(result)["objectId"]) != "1001" {
+		t.Error("expect:", "1001", "result:", result)
+	}
+	/********************************************************/
+	putObjectCache("Post", "1001", types.M{"objectId": "1001", "_rperm": types.S{"role:admin"}})
+	result = cache.Object.Get(objectCacheKey("Post", "1001"))
+	if matchesReadACL(utils.M(result), types.M{"$in": types.S{nil, "*"}}) {
+		t.Error("expect:", false, "result:", result)
+	}
+}
+
+// 按 objectId 以外的字段更新对象之后，按 objectId 读取时不应返回缓存中的旧对象
+func Test_Update_objectCache(t *testing.T) {
+	initEnv()
+	ttl := config.TConfig.ObjectCacheTTL
+	config.TConfig.ObjectCacheTTL = 5
+	defer func() { config.TConfig.ObjectCacheTTL = ttl }()
+	cache.InitCache()
+	var results types.S
+	var err error
+	/********************************************************/
+	TalismanDBController.Create("user", types.M{"objectId": "01", "username": "joe", "emailVerified": false}, nil)
+	TalismanDBController.Find("user", types.M{"objectId": "01"}, types.M{})
+	_, err = TalismanDBController.Update("user", types.M{"username": "joe"}, types.M{"emailVerified": true}, types.M{}, false)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	results, err = TalismanDBController.Find("user", types.M{"objectId": "01"}, types.M{})
+	if err != nil || len(results) != 1 || utils.M(results[0])["emailVerified"] != true {
+		t.Error("expect:", true, "result:", results, err)
+	}
+	TalismanDBController.DeleteEverything()
+	/********************************************************/
+	TalismanDBController.Create("user", types.M{"objectId": "01", "username": "joe", "emailVerified": false}, nil)
+	TalismanDBController.Find("user", types.M{"objectId": "01"}, types.M{})
+	_, err = TalismanDBController.Update("user", types.M{"username": "joe"}, types.M{"emailVerified": true}, types.M{"upsert": true}, false)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	results, err = TalismanDBController.Find("user", types.M{"objectId": "01"}, types.M{})
+	if err != nil || len(results) != 1 || utils.M(results[0])["emailVerified"] != true {
+		t.Error("expect:", true, "result:", results, err)
+	}
+	TalismanDBController.DeleteEverything()
+}
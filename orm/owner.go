@@ -0,0 +1,80 @@
+package orm
+
+import (
+	"strings"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 类级别权限中的 ownerField 声明类的所有者字段，必须为指向 _User 的 Pointer 字段，
+// 操作权限中可以使用 owner 表示只允许所有者访问，如 {"ownerField": "author", "update": {"owner": true, "role:Admin": true}} ，
+// 也可以简写为 {"ownerField": "author", "update": "owner"} ， owner 只能用于 get find count update delete ，
+// 效果与只对该操作设置 readUserFields 或者 writeUserFields 相同，创建对象时未指定所有者则设置为当前用户
+
+// clpOwner 操作权限中表示所有者的 key
+const clpOwner = "owner"
+
+// ownerOperations 可以使用 owner 权限的操作
+var ownerOperations = map[string]bool{"get": true, "find": true, "count": true, "update": true, "delete": true}
+
+// validateOwnerField ownerField 必须为指向 _User 的 Pointer 字段
+func validateOwnerField(perm interface{}, fields types.M) error {
+	fieldName := utils.S(perm)
+	if fields != nil {
+		if t := utils.M(fields[fieldName]); t != nil && utils.S(t["type"]) == "Pointer" && utils.S(t["targetClass"]) == "_User" {
+			return nil
+		}
+	}
+	return errs.E(errs.InvalidJSON, fieldName+" is not a valid column for class level permissions ownerField")
+}
+
+// validateOwnerPermission 校验操作权限中的 owner
+func validateOwnerPermission(perms types.M, operation string, value interface{}) error {
+	if ownerOperations[operation] == false {
+		return errs.E(errs.InvalidJSON, "owner is not a valid permission for class level permissions "+operation)
+	}
+	if utils.S(perms["ownerField"]) == "" {
+		return errs.E(errs.InvalidJSON, "ownerField is required for class level permissions "+operation+":owner")
+	}
+	if v, ok := value.(bool); ok == false || v == false {
+		return errs.E(errs.InvalidJSON, "this perm is not a valid value for class level permissions "+operation+":owner:perm")
+	}
+	return nil
+}
+
+// expandOwnerShorthand 把操作权限的简写 "owner" 展开为 {"owner": true} ，直接修改 perms
+func expandOwnerShorthand(perms types.M) {
+	for _, operation := range clpOperations {
+		if s, ok := perms[operation].(string); ok && s == clpOwner {
+			perms[operation] = types.M{clpOwner: true}
+		}
+	}
+}
+
+// ownerFieldFor 操作权限中包含 owner 时返回所有者字段，否则返回空
+func ownerFieldFor(classPerms types.M, operation string) string {
+	if classPerms == nil {
+		return ""
+	}
+	if perms := utils.M(classPerms[operation]); perms == nil || perms[clpOwner] == nil {
+		return ""
+	}
+	return utils.S(classPerms["ownerField"])
+}
+
+// userIDFromACL 从 aclGroup 中取出用户 ID ，不存在或者有多个时返回空
+func userIDFromACL(aclGroup []string) string {
+	userID := ""
+	for _, acl := range aclGroup {
+		if strings.HasPrefix(acl, "role:") || acl == "*" {
+			continue
+		}
+		if userID != "" {
+			return ""
+		}
+		userID = acl
+	}
+	return userID
+}
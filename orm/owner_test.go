@@ -0,0 +1,106 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_validateCLP_owner(t *testing.T) {
+	fields := types.M{
+		"author": types.M{"type": "Pointer", "targetClass": "_User"},
+		"title":  types.M{"type": "String"},
+	}
+	tests := []struct {
+		name    string
+		perms   types.M
+		wantErr error
+	}{
+		{
+			name:    "1",
+			perms:   types.M{"ownerField": "author", "update": types.M{"owner": true, "role:Admin": true}, "get": types.M{"owner": true}},
+			wantErr: nil,
+		},
+		{
+			name:    "2",
+			perms:   types.M{"ownerField": "title"},
+			wantErr: errs.E(errs.InvalidJSON, "title is not a valid column for class level permissions ownerField"),
+		},
+		{
+			name:    "3",
+			perms:   types.M{"update": types.M{"owner": true}},
+			wantErr: errs.E(errs.InvalidJSON, "ownerField is required for class level permissions update:owner"),
+		},
+		{
+			name:    "4",
+			perms:   types.M{"ownerField": "author", "create": types.M{"owner": true}},
+			wantErr: errs.E(errs.InvalidJSON, "owner is not a valid permission for class level permissions create"),
+		},
+		{
+			name:    "5",
+			perms:   types.M{"ownerField": "author", "delete": types.M{"owner": "yes"}},
+			wantErr: errs.E(errs.InvalidJSON, "this perm is not a valid value for class level permissions delete:owner:perm"),
+		},
+	}
+	for _, tt := range tests {
+		if err := validateCLP(tt.perms, fields); !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%q. validateCLP() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_expandOwnerShorthand(t *testing.T) {
+	perms := types.M{
+		"ownerField": "author",
+		"update":     "owner",
+		"delete":     "owner",
+		"find":       types.M{"*": true},
+	}
+	expandOwnerShorthand(perms)
+	expect := types.M{
+		"ownerField": "author",
+		"update":     types.M{"owner": true},
+		"delete":     types.M{"owner": true},
+		"find":       types.M{"*": true},
+	}
+	if reflect.DeepEqual(perms, expect) == false {
+		t.Error("expect:", expect, "result:", perms)
+	}
+}
+
+func Test_ownerFieldFor(t *testing.T) {
+	classPerms := types.M{
+		"ownerField": "author",
+		"update":     types.M{"owner": true},
+		"find":       types.M{"*": true},
+	}
+	if got := ownerFieldFor(classPerms, "update"); got != "author" {
+		t.Error("expect:", "author", "result:", got)
+	}
+	if got := ownerFieldFor(classPerms, "find"); got != "" {
+		t.Error("expect:", "", "result:", got)
+	}
+	if got := ownerFieldFor(nil, "update"); got != "" {
+		t.Error("expect:", "", "result:", got)
+	}
+}
+
+func Test_userIDFromACL(t *testing.T) {
+	tests := []struct {
+		name     string
+		aclGroup []string
+		want     string
+	}{
+		{name: "1", aclGroup: nil, want: ""},
+		{name: "2", aclGroup: []string{"*", "role:Admin"}, want: ""},
+		{name: "3", aclGroup: []string{"*", "u1", "role:Admin"}, want: "u1"},
+		{name: "4", aclGroup: []string{"u1", "u2"}, want: ""},
+	}
+	for _, tt := range tests {
+		if got := userIDFromACL(tt.aclGroup); got != tt.want {
+			t.Errorf("%q. userIDFromACL() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package orm
+
+import (
+	"time"
+
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 开启 RefreshTokens 后，登录时除了 sessionToken 还会返回 refreshToken ，
+// 每个 refreshToken 在 _RefreshToken 表中保存一条记录，只保存 token 的哈希值，
+// 同一次登录之后通过刷新得到的 token 属于同一个 family ，用于发现重复使用时注销整个令牌族
+
+// RefreshTokenClassName 保存 refreshToken 的表
+const RefreshTokenClassName = "_RefreshToken"
+
+// NewRefreshToken 生成 refreshToken 记录， sessionToken 为同时签发的 sessionToken
+func NewRefreshToken(tokenHash, family, userID, sessionToken string, expiresAt time.Time) types.M {
+	now := time.Now().UTC()
+	return types.M{
+		"objectId":  utils.CreateObjectID(),
+		"tokenHash": tokenHash,
+		"family":    family,
+		"user": types.M{
+			"__type":    "Pointer",
+			"className": "_User",
+			"objectId":  userID,
+		},
+		"sessionToken": sessionToken,
+		"used":         false,
+		"expiresAt":    utils.DateJSON(expiresAt),
+		"createdAt":    utils.TimetoString(now),
+		"updatedAt":    utils.TimetoString(now),
+	}
+}
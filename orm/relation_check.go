@@ -0,0 +1,175 @@
+package orm
+
+import (
+	"sort"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// DanglingRelationsBatchSize 检查 _Join 表时每次读取的记录数
+const DanglingRelationsBatchSize = 1000
+
+// FindDanglingRelations 检查类中所有 Relation 字段的 _Join 表，统计 owningId 或 relatedId 指向不存在对象的记录，
+// 这些记录通常由 handleRelationUpdates 中途失败或者直接删除数据库中的对象产生， remove 为 true 时删除这些记录
+// 返回格式： [{"field":"likes","targetClass":"_User","checked":120,"danglingOwning":["abc"],"danglingRelated":["def"],"dangling":3,"removed":3}]
+// danglingOwning 与 danglingRelated 为不存在的对象 id ， dangling 为引用了这些 id 的记录数
+func (d *DBController) FindDanglingRelations(className string, remove bool) (types.S, error) {
+	if ClassNameIsValid(className) == false {
+		return nil, errs.E(errs.InvalidClassName, InvalidClassNameMessage(className))
+	}
+	schema := d.LoadSchema(types.M{"clearCache": true})
+	sch, err := schema.GetOneSchema(className, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(sch) == 0 {
+		return nil, errs.E(errs.InvalidClassName, "Class "+className+" does not exist.")
+	}
+
+	fields := utils.M(sch["fields"])
+	fieldNames := []string{}
+	for fieldName, v := range fields {
+		if utils.S(utils.M(v)["type"]) == "Relation" {
+			fieldNames = append(fieldNames, fieldName)
+		}
+	}
+	sort.Strings(fieldNames)
+
+	results := types.S{}
+	for _, fieldName := range fieldNames {
+		targetClass := utils.S(utils.M(fields[fieldName])["targetClass"])
+		result, err := d.checkJoinTable(schema, className, fieldName, targetClass, remove)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// checkJoinTable 分批读取 _Join 表，查找两端对象不存在的记录
+func (d *DBController) checkJoinTable(schema *Schema, className, key, targetClass string, remove bool) (types.M, error) {
+	joinClassName := joinTableName(className, key)
+	owning := map[string]bool{}
+	related := map[string]bool{}
+	danglingRows := 0
+	checked := 0
+	for {
+		rows, err := Adapter.Find(joinClassName, relationSchema, types.M{}, types.M{
+			"skip":  checked,
+			"limit": DanglingRelationsBatchSize,
+			"sort":  []string{"owningId", "relatedId"},
+		})
+		if err != nil {
+			return nil, errs.FromAdapter(err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+		checked += len(rows)
+
+		err = d.markExistingIDs(schema, className, rows, "owningId", owning)
+		if err != nil {
+			return nil, err
+		}
+		err = d.markExistingIDs(schema, targetClass, rows, "relatedId", related)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if owning[utils.S(row["owningId"])] == false || related[utils.S(row["relatedId"])] == false {
+				danglingRows++
+			}
+		}
+		if len(rows) < DanglingRelationsBatchSize {
+			break
+		}
+	}
+
+	danglingOwning := missingIDs(owning)
+	danglingRelated := missingIDs(related)
+	removed := 0
+	if remove && danglingRows > 0 {
+		err := removeJoinRows(joinClassName, "owningId", danglingOwning)
+		if err != nil {
+			return nil, err
+		}
+		err = removeJoinRows(joinClassName, "relatedId", danglingRelated)
+		if err != nil {
+			return nil, err
+		}
+		removed = danglingRows
+	}
+
+	return types.M{
+		"field":           key,
+		"targetClass":     targetClass,
+		"checked":         checked,
+		"danglingOwning":  danglingOwning,
+		"danglingRelated": danglingRelated,
+		"dangling":        danglingRows,
+		"removed":         removed,
+	}, nil
+}
+
+// markExistingIDs 查询 rows 中字段 field 对应的对象是否存在于 className 中，结果写入 known ，已查询过的 id 不再查询
+// 类不存在时所有对象都视为不存在
+func (d *DBController) markExistingIDs(schema *Schema, className string, rows []types.M, field string, known map[string]bool) error {
+	ids := types.S{}
+	for _, row := range rows {
+		id := utils.S(row[field])
+		if _, ok := known[id]; ok {
+			continue
+		}
+		known[id] = false
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 || schema.HasClass(className) == false {
+		return nil
+	}
+	sch, err := schema.GetOneSchema(className, false, nil)
+	if err != nil {
+		return err
+	}
+	objects, err := Adapter.Find(className, sch, types.M{"objectId": types.M{"$in": ids}}, types.M{"keys": []string{"objectId"}})
+	if err != nil {
+		return errs.FromAdapter(err)
+	}
+	for _, object := range objects {
+		known[utils.S(object["objectId"])] = true
+	}
+	return nil
+}
+
+// missingIDs 返回 known 中不存在的对象 id ，按 id 排序
+func missingIDs(known map[string]bool) []string {
+	ids := []string{}
+	for id, exists := range known {
+		if exists == false {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// removeJoinRows 分批删除 _Join 表中字段 field 为 ids 的记录
+func removeJoinRows(joinClassName, field string, ids []string) error {
+	for start := 0; start < len(ids); start += DanglingRelationsBatchSize {
+		end := start + DanglingRelationsBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		in := types.S{}
+		for _, id := range ids[start:end] {
+			in = append(in, id)
+		}
+		err := Adapter.DeleteObjectsByQuery(joinClassName, relationSchema, types.M{field: types.M{"$in": in}})
+		if err != nil && errs.GetErrorCode(err) != errs.ObjectNotFound {
+			return errs.FromAdapter(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_missingIDs(t *testing.T) {
+	tests := []struct {
+		name  string
+		known map[string]bool
+		want  []string
+	}{
+		{
+			name:  "1",
+			known: map[string]bool{},
+			want:  []string{},
+		},
+		{
+			name:  "2",
+			known: map[string]bool{"abc": true, "def": true},
+			want:  []string{},
+		},
+		{
+			name:  "3",
+			known: map[string]bool{"xyz": false, "abc": true, "def": false},
+			want:  []string{"def", "xyz"},
+		},
+	}
+	for _, tt := range tests {
+		if got := missingIDs(tt.known); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. missingIDs() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
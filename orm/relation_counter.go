@@ -0,0 +1,111 @@
+package orm
+
+import (
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 类级别权限中的 relationCounters 为 Relation 字段指定计数字段，如 {"relationCounters": {"likes": "likesCount"}} ，
+// 通过 AddRelation 与 RemoveRelation 修改 Relation 字段时，同时对所属对象的计数字段做原子增减，
+// 计数字段必须为 Number 类型，只统计实际新增或删除的关系，重复添加与删除不存在的关系不影响计数
+
+// relationCountersOf 从类级别权限中读取 Relation 计数字段
+func relationCountersOf(perms types.M) types.M {
+	if perms == nil {
+		return nil
+	}
+	return utils.M(perms["relationCounters"])
+}
+
+// validateRelationCounters key 必须为 Relation 字段， value 必须为 Number 字段
+func validateRelationCounters(perm interface{}, fields types.M) error {
+	counters := utils.M(perm)
+	if counters == nil {
+		return errs.E(errs.InvalidJSON, "relationCounters must be an object for class level permissions")
+	}
+	for relationField, v := range counters {
+		if t := utils.M(fields[relationField]); t == nil || utils.S(t["type"]) != "Relation" {
+			return errs.E(errs.InvalidJSON, relationField+" is not a valid column for relationCounters, it must be a Relation field")
+		}
+		counterField, ok := v.(string)
+		if ok == false || counterField == "" {
+			return errs.E(errs.InvalidJSON, "relationCounters."+relationField+" must be a field name")
+		}
+		if t := utils.M(fields[counterField]); t == nil || utils.S(t["type"]) != "Number" {
+			return errs.E(errs.InvalidJSON, counterField+" is not a valid column for relationCounters, it must be a Number field")
+		}
+	}
+	return nil
+}
+
+// relationCounters 返回类中 Relation 字段对应的计数字段，未设置时返回 nil
+func (s *Schema) relationCounters(className string) types.M {
+	s.permsMutex.Lock()
+	defer s.permsMutex.Unlock()
+	if s.perms == nil {
+		return nil
+	}
+	return utils.CopyMap(relationCountersOf(utils.M(s.perms[className])))
+}
+
+// relationExists 判断 _Join 表中是否已存在 fromID 到 toID 的关系
+func (d *DBController) relationExists(key, fromClassName, fromID, toID string) (bool, error) {
+	doc := types.M{
+		"relatedId": toID,
+		"owningId":  fromID,
+	}
+	count, err := Adapter.Count(joinTableName(fromClassName, key), relationSchema, doc)
+	if err != nil {
+		return false, errs.FromAdapter(err)
+	}
+	return count > 0, nil
+}
+
+// updateRelationCounters 按 deltas 原子增减对象的计数字段， deltas 的 key 为计数字段
+func (d *DBController) updateRelationCounters(className, objectID string, deltas map[string]int) error {
+	update := types.M{}
+	for counterField, delta := range deltas {
+		if delta != 0 {
+			update[counterField] = types.M{"__op": "Increment", "amount": delta}
+		}
+	}
+	if len(update) == 0 || objectID == "" {
+		return nil
+	}
+	schema, err := d.LoadSchema(nil).GetOneSchema(className, false, nil)
+	if err != nil {
+		return err
+	}
+	err = Adapter.UpdateObjectsByQuery(className, schema, types.M{"objectId": objectID}, update)
+	if err != nil {
+		return errs.FromAdapter(err)
+	}
+	delObjectCache(className, objectID)
+	return nil
+}
+
+// mergeRelationCounters 把计数字段的增量合并到 update 中，与对象一起写入数据库
+// update 中已经包含计数字段的 Increment 操作时累加增量，包含其他值时以 update 为准
+func mergeRelationCounters(update types.M, deltas map[string]int) {
+	for counterField, delta := range deltas {
+		if delta == 0 {
+			continue
+		}
+		v, ok := update[counterField]
+		if ok == false {
+			update[counterField] = types.M{"__op": "Increment", "amount": delta}
+			continue
+		}
+		op := utils.M(v)
+		if op == nil || utils.S(op["__op"]) != "Increment" {
+			continue
+		}
+		switch amount := op["amount"].(type) {
+		case float64:
+			update[counterField] = types.M{"__op": "Increment", "amount": amount + float64(delta)}
+		case int:
+			update[counterField] = types.M{"__op": "Increment", "amount": amount + delta}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_validateRelationCounters(t *testing.T) {
+	fields := types.M{
+		"likes":      types.M{"type": "Relation", "targetClass": "_User"},
+		"likesCount": types.M{"type": "Number"},
+		"author":     types.M{"type": "Pointer", "targetClass": "_User"},
+		"title":      types.M{"type": "String"},
+	}
+	tests := []struct {
+		name    string
+		perm    interface{}
+		wantErr error
+	}{
+		{
+			name:    "1",
+			perm:    types.M{"likes": "likesCount"},
+			wantErr: nil,
+		},
+		{
+			name:    "2",
+			perm:    "likesCount",
+			wantErr: errs.E(errs.InvalidJSON, "relationCounters must be an object for class level permissions"),
+		},
+		{
+			name:    "3",
+			perm:    types.M{"author": "likesCount"},
+			wantErr: errs.E(errs.InvalidJSON, "author is not a valid column for relationCounters, it must be a Relation field"),
+		},
+		{
+			name:    "4",
+			perm:    types.M{"likes": 1},
+			wantErr: errs.E(errs.InvalidJSON, "relationCounters.likes must be a field name"),
+		},
+		{
+			name:    "5",
+			perm:    types.M{"likes": "title"},
+			wantErr: errs.E(errs.InvalidJSON, "title is not a valid column for relationCounters, it must be a Number field"),
+		},
+		{
+			name:    "6",
+			perm:    types.M{"likes": "viewsCount"},
+			wantErr: errs.E(errs.InvalidJSON, "viewsCount is not a valid column for relationCounters, it must be a Number field"),
+		},
+	}
+	for _, tt := range tests {
+		if err := validateRelationCounters(tt.perm, fields); !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%q. validateRelationCounters() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_mergeRelationCounters(t *testing.T) {
+	tests := []struct {
+		name   string
+		update types.M
+		deltas map[string]int
+		want   types.M
+	}{
+		{
+			name:   "1",
+			update: types.M{"title": "hello"},
+			deltas: map[string]int{},
+			want:   types.M{"title": "hello"},
+		},
+		{
+			name:   "2",
+			update: types.M{"title": "hello"},
+			deltas: map[string]int{"likesCount": 2, "viewsCount": 0},
+			want:   types.M{"title": "hello", "likesCount": types.M{"__op": "Increment", "amount": 2}},
+		},
+		{
+			name:   "3",
+			update: types.M{"likesCount": types.M{"__op": "Increment", "amount": 1.0}},
+			deltas: map[string]int{"likesCount": -1},
+			want:   types.M{"likesCount": types.M{"__op": "Increment", "amount": 0.0}},
+		},
+		{
+			name:   "4",
+			update: types.M{"likesCount": 10.0},
+			deltas: map[string]int{"likesCount": 1},
+			want:   types.M{"likesCount": 10.0},
+		},
+	}
+	for _, tt := range tests {
+		mergeRelationCounters(tt.update, tt.deltas)
+		if !reflect.DeepEqual(tt.update, tt.want) {
+			t.Errorf("%q. mergeRelationCounters() = %v, want %v", tt.name, tt.update, tt.want)
+		}
+	}
+}
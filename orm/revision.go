@@ -0,0 +1,189 @@
+package orm
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 类级别权限中设置了 revisions 时，每次更新对象都会在 _Revision 表中保存一条修订记录，
+// 记录被修改字段更新前的值、修改人与修改时间，可用于查看对象的修改历史与恢复到之前的版本
+// revisions 的格式为 {"maxCount": 20, "maxAge": 30} ， maxCount 为每个对象最多保留的记录数，
+// maxAge 为记录保留的天数，均为可选项， {} 表示保留全部记录
+
+// RevisionClassName 保存修订记录的表
+const RevisionClassName = "_Revision"
+
+// RevisionPolicy 类的修订记录保留策略，值为 0 时表示不限制
+type RevisionPolicy struct {
+	MaxCount int
+	MaxAge   int
+}
+
+// validateRevisions revisions 必须为对象， maxCount 与 maxAge 必须为正整数
+func validateRevisions(perm interface{}) error {
+	p := utils.M(perm)
+	if p == nil {
+		return errs.E(errs.InvalidJSON, "revisions must be an object for class level permissions")
+	}
+	for key, value := range p {
+		if key != "maxCount" && key != "maxAge" {
+			return errs.E(errs.InvalidJSON, key+" is not a valid option for revisions")
+		}
+		if n, ok := value.(float64); ok == false || n < 1 || n != float64(int(n)) {
+			return errs.E(errs.InvalidJSON, "revisions "+key+" must be a positive integer")
+		}
+	}
+	return nil
+}
+
+// revisionPolicyOf 从类级别权限中读取修订记录保留策略
+func revisionPolicyOf(perms types.M) (RevisionPolicy, bool) {
+	if perms == nil {
+		return RevisionPolicy{}, false
+	}
+	p := utils.M(perms["revisions"])
+	if p == nil {
+		return RevisionPolicy{}, false
+	}
+	policy := RevisionPolicy{}
+	if n, ok := p["maxCount"].(float64); ok {
+		policy.MaxCount = int(n)
+	}
+	if n, ok := p["maxAge"].(float64); ok {
+		policy.MaxAge = int(n)
+	}
+	return policy, true
+}
+
+// RevisionPolicy 返回类的修订记录保留策略，未开启时 ok 为 false
+func (s *Schema) RevisionPolicy(className string) (policy RevisionPolicy, ok bool) {
+	s.permsMutex.Lock()
+	defer s.permsMutex.Unlock()
+	if s.perms == nil {
+		return RevisionPolicy{}, false
+	}
+	return revisionPolicyOf(utils.M(s.perms[className]))
+}
+
+// revisionFields 返回 update 中需要记录的字段，按名称排序
+// 内部字段、密码、第三方登录信息与 Relation 操作不记录
+func revisionFields(update types.M) []string {
+	fields := []string{}
+	for key, value := range update {
+		if strings.HasPrefix(key, "_") {
+			continue
+		}
+		switch key {
+		case "objectId", "createdAt", "updatedAt", "password", "authData", "sessionToken":
+			continue
+		}
+		switch utils.S(utils.M(value)["__op"]) {
+		case "AddRelation", "RemoveRelation", "Batch":
+			continue
+		}
+		fields = append(fields, key)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// NewRevision 生成修订记录， original 为更新前的对象， update 为更新数据
+// 字段的旧值以 JSON 字符串的形式保存在 values 中，更新前不存在的字段不出现在 values 中
+// 没有需要记录的字段时返回 nil
+func NewRevision(className string, original, update types.M, editor string) types.M {
+	fields := revisionFields(update)
+	if len(fields) == 0 {
+		return nil
+	}
+	values := types.M{}
+	names := types.S{}
+	for _, fieldName := range fields {
+		names = append(names, fieldName)
+		if v, ok := original[fieldName]; ok && v != nil {
+			values[fieldName] = v
+		}
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return nil
+	}
+	return types.M{
+		"objectId":    utils.CreateObjectID(),
+		"targetClass": className,
+		"targetId":    utils.S(original["objectId"]),
+		"fields":      names,
+		"values":      string(b),
+		"editor":      editor,
+		"createdAt":   utils.TimetoString(time.Now().UTC()),
+	}
+}
+
+// RestoreUpdate 根据修订记录生成恢复对象的更新数据，更新前不存在的字段删除
+func RestoreUpdate(revision types.M) (types.M, error) {
+	values := types.M{}
+	if s := utils.S(revision["values"]); s != "" {
+		err := json.Unmarshal([]byte(s), &values)
+		if err != nil {
+			return nil, errs.E(errs.InvalidJSON, "revision values is not valid json")
+		}
+	}
+	update := types.M{}
+	for _, v := range utils.A(revision["fields"]) {
+		fieldName := utils.S(v)
+		if fieldName == "" {
+			continue
+		}
+		if value, ok := values[fieldName]; ok && value != nil {
+			update[fieldName] = value
+		} else {
+			update[fieldName] = types.M{"__op": "Delete"}
+		}
+	}
+	return update, nil
+}
+
+// SaveRevision 保存修订记录，并按类的保留策略删除对象的过期记录
+func (d *DBController) SaveRevision(revision types.M, policy RevisionPolicy) error {
+	err := d.Create(RevisionClassName, revision, types.M{})
+	if err != nil {
+		return err
+	}
+	return d.pruneRevisions(utils.S(revision["targetClass"]), utils.S(revision["targetId"]), policy)
+}
+
+// pruneRevisions 删除对象超过 MaxCount 条的旧记录，以及创建时间超过 MaxAge 天的记录
+func (d *DBController) pruneRevisions(className, objectID string, policy RevisionPolicy) error {
+	if policy.MaxCount <= 0 && policy.MaxAge <= 0 {
+		return nil
+	}
+	query := types.M{"targetClass": className, "targetId": objectID}
+	revisions, err := d.Find(RevisionClassName, query, types.M{"sort": []string{"-createdAt"}, "keys": []string{"createdAt"}})
+	if err != nil {
+		return err
+	}
+	expiredAt := time.Now().UTC().Add(-time.Duration(policy.MaxAge) * 24 * time.Hour)
+	ids := types.S{}
+	for i, r := range revisions {
+		revision := utils.M(r)
+		if policy.MaxCount > 0 && i >= policy.MaxCount {
+			ids = append(ids, revision["objectId"])
+			continue
+		}
+		if policy.MaxAge > 0 {
+			createdAt, err := utils.StringtoTime(utils.S(revision["createdAt"]))
+			if err == nil && createdAt.Before(expiredAt) {
+				ids = append(ids, revision["objectId"])
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return d.Destroy(RevisionClassName, types.M{"objectId": types.M{"$in": ids}}, types.M{})
+}
@@ -0,0 +1,143 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_validateRevisions(t *testing.T) {
+	tests := []struct {
+		name    string
+		perm    interface{}
+		wantErr error
+	}{
+		{
+			name:    "1",
+			perm:    map[string]interface{}{},
+			wantErr: nil,
+		},
+		{
+			name:    "2",
+			perm:    map[string]interface{}{"maxCount": 20.0, "maxAge": 30.0},
+			wantErr: nil,
+		},
+		{
+			name:    "3",
+			perm:    true,
+			wantErr: errs.E(errs.InvalidJSON, "revisions must be an object for class level permissions"),
+		},
+		{
+			name:    "4",
+			perm:    map[string]interface{}{"maxSize": 20.0},
+			wantErr: errs.E(errs.InvalidJSON, "maxSize is not a valid option for revisions"),
+		},
+		{
+			name:    "5",
+			perm:    map[string]interface{}{"maxCount": 0.0},
+			wantErr: errs.E(errs.InvalidJSON, "revisions maxCount must be a positive integer"),
+		},
+		{
+			name:    "6",
+			perm:    map[string]interface{}{"maxAge": 1.5},
+			wantErr: errs.E(errs.InvalidJSON, "revisions maxAge must be a positive integer"),
+		},
+	}
+	for _, tt := range tests {
+		if err := validateRevisions(tt.perm); !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%q. validateRevisions() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_revisionPolicyOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		perms  types.M
+		want   RevisionPolicy
+		wantOk bool
+	}{
+		{
+			name:   "1",
+			perms:  nil,
+			want:   RevisionPolicy{},
+			wantOk: false,
+		},
+		{
+			name:   "2",
+			perms:  types.M{"revisions": types.M{}},
+			want:   RevisionPolicy{},
+			wantOk: true,
+		},
+		{
+			name:   "3",
+			perms:  types.M{"revisions": types.M{"maxCount": 20.0, "maxAge": 30.0}},
+			want:   RevisionPolicy{MaxCount: 20, MaxAge: 30},
+			wantOk: true,
+		},
+	}
+	for _, tt := range tests {
+		got, ok := revisionPolicyOf(tt.perms)
+		if !reflect.DeepEqual(got, tt.want) || ok != tt.wantOk {
+			t.Errorf("%q. revisionPolicyOf() = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func Test_NewRevision(t *testing.T) {
+	original := types.M{
+		"objectId":         "1001",
+		"title":            "hello",
+		"author":           types.M{"__type": "Pointer", "className": "_User", "objectId": "u1"},
+		"_hashed_password": "xxx",
+	}
+	update := types.M{
+		"title":            "world",
+		"score":            types.M{"__op": "Increment", "amount": 1},
+		"fans":             types.M{"__op": "AddRelation", "objects": types.S{}},
+		"updatedAt":        "2016-02-28T13:25:05.123Z",
+		"_hashed_password": "yyy",
+		"author":           types.M{"__op": "Delete"},
+	}
+	revision := NewRevision("Post", original, update, "master")
+	if revision["targetClass"] != "Post" || revision["targetId"] != "1001" || revision["editor"] != "master" {
+		t.Error("expect:", "Post 1001 master", "result:", revision)
+	}
+	wantFields := types.S{"author", "score", "title"}
+	if reflect.DeepEqual(revision["fields"], wantFields) == false {
+		t.Error("expect:", wantFields, "result:", revision["fields"])
+	}
+	wantValues := `{"author":{"__type":"Pointer","className":"_User","objectId":"u1"},"title":"hello"}`
+	if revision["values"] != wantValues {
+		t.Error("expect:", wantValues, "result:", revision["values"])
+	}
+	/*****************************************************************/
+	revision = NewRevision("Post", original, types.M{"_hashed_password": "yyy"}, "")
+	if revision != nil {
+		t.Error("expect:", nil, "result:", revision)
+	}
+}
+
+func Test_RestoreUpdate(t *testing.T) {
+	revision := types.M{
+		"fields": types.S{"author", "score", "title"},
+		"values": `{"author":{"__type":"Pointer","className":"_User","objectId":"u1"},"title":"hello"}`,
+	}
+	got, err := RestoreUpdate(revision)
+	want := types.M{
+		"author": map[string]interface{}{"__type": "Pointer", "className": "_User", "objectId": "u1"},
+		"score":  types.M{"__op": "Delete"},
+		"title":  "hello",
+	}
+	if err != nil || reflect.DeepEqual(got, want) == false {
+		t.Error("expect:", want, "result:", got, err)
+	}
+	/*****************************************************************/
+	_, err = RestoreUpdate(types.M{"fields": types.S{"title"}, "values": "{"})
+	expectErr := errs.E(errs.InvalidJSON, "revision values is not valid json")
+	if reflect.DeepEqual(err, expectErr) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+}
@@ -0,0 +1,44 @@
+package orm
+
+import (
+	"strings"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/types"
+)
+
+// SanitizeObject 删除返回给客户端的对象中不应公开的字段，所有读取路径在返回结果前都应调用
+// 以 _ 开头的字段为服务端内部字段，如 _hashed_password 、 _email_verify_token ，任何情况下都不返回
+// 对于 _User 类，还会删除 password 与 sessionToken ，
+// 非 Master Key 且不是用户本人时，删除 authData 与配置中的 UserSensitiveFields
+// userID 为当前用户 id ，没有用户时为空
+func SanitizeObject(className string, object types.M, isMaster bool, userID string) types.M {
+	if object == nil {
+		return object
+	}
+	stripInternalFields(object)
+	if className != "_User" {
+		return object
+	}
+
+	delete(object, "password")
+	delete(object, "sessionToken")
+
+	if isMaster || (userID != "" && userID == object["objectId"]) {
+		return object
+	}
+	delete(object, "authData")
+	for _, field := range config.TConfig.UserSensitiveFields {
+		delete(object, field)
+	}
+	return object
+}
+
+// stripInternalFields 删除以 _ 开头的内部字段
+func stripInternalFields(object types.M) {
+	for k := range object {
+		if strings.HasPrefix(k, "_") {
+			delete(object, k)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_SanitizeObject(t *testing.T) {
+	config.TConfig.UserSensitiveFields = []string{"email"}
+	type args struct {
+		className string
+		object    types.M
+		isMaster  bool
+		userID    string
+	}
+	tests := []struct {
+		name string
+		args args
+		want types.M
+	}{
+		{
+			name: "1",
+			args: args{className: "_User", object: nil},
+			want: nil,
+		},
+		{
+			name: "2",
+			args: args{
+				className: "post",
+				object:    types.M{"objectId": "1001", "title": "hello", "_rperm": types.S{"*"}},
+			},
+			want: types.M{"objectId": "1001", "title": "hello"},
+		},
+		{
+			name: "3",
+			args: args{
+				className: "_User",
+				object: types.M{
+					"objectId":            "1001",
+					"username":            "joe",
+					"email":               "joe@example.com",
+					"password":            "hash",
+					"sessionToken":        "r:abc",
+					"authData":            types.M{"facebook": types.M{"id": "1"}},
+					"_hashed_password":    "hash",
+					"_email_verify_token": "token",
+				},
+				isMaster: false,
+				userID:   "1002",
+			},
+			want: types.M{"objectId": "1001", "username": "joe"},
+		},
+		{
+			name: "4",
+			args: args{
+				className: "_User",
+				object: types.M{
+					"objectId":          "1001",
+					"username":          "joe",
+					"email":             "joe@example.com",
+					"password":          "hash",
+					"authData":          types.M{"facebook": types.M{"id": "1"}},
+					"_perishable_token": "token",
+				},
+				isMaster: false,
+				userID:   "1001",
+			},
+			want: types.M{
+				"objectId": "1001",
+				"username": "joe",
+				"email":    "joe@example.com",
+				"authData": types.M{"facebook": types.M{"id": "1"}},
+			},
+		},
+		{
+			name: "5",
+			args: args{
+				className: "_User",
+				object: types.M{
+					"objectId":            "1001",
+					"email":               "joe@example.com",
+					"password":            "hash",
+					"_failed_login_count": 3,
+				},
+				isMaster: true,
+			},
+			want: types.M{"objectId": "1001", "email": "joe@example.com"},
+		},
+	}
+	for _, tt := range tests {
+		if got := SanitizeObject(tt.args.className, tt.args.object, tt.args.isMaster, tt.args.userID); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. SanitizeObject() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
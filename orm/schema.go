@@ -13,10 +13,10 @@ import (
 )
 
 // clpValidKeys 类级别的权限 列表
-var clpValidKeys = []string{"find", "count", "get", "create", "update", "delete", "addField", "readUserFields", "writeUserFields"}
+var clpValidKeys = []string{"find", "count", "get", "create", "update", "delete", "addField", "readUserFields", "writeUserFields", "allowAddField", "ttlField", "coerceTypes", "revisions", "defaults", "ownerField", "relationCounters", "masterOnlyFields"}
 
 // SystemClasses 系统表
-var SystemClasses = []string{"_User", "_Installation", "_Role", "_Session", "_Product", "_PushStatus", "_JobStatus"}
+var SystemClasses = []string{"_User", "_Installation", "_Role", "_Session", "_Product", "_PushStatus", "_JobStatus", "_Audience", "_Revision", "_File", "_EmailEvent", "_RefreshToken", "_KeyUsage", "_Impersonation"}
 
 var volatileClasses = []string{"_JobStatus", "_PushStatus", "_Hooks", "_GlobalConfig"}
 
@@ -62,6 +62,10 @@ var DefaultColumns = map[string]types.M{
 		"sessionToken":   types.M{"type": "String"},
 		"expiresAt":      types.M{"type": "Date"},
 		"createdWith":    types.M{"type": "Object"},
+		"deviceName":     types.M{"type": "String"},
+		"ipAddress":      types.M{"type": "String"},
+		"lastUsedAt":     types.M{"type": "Date"},
+		"impersonated":   types.M{"type": "Boolean"},
 	},
 	"_Product": types.M{
 		"productIdentifier": types.M{"type": "String"},
@@ -106,6 +110,60 @@ var DefaultColumns = map[string]types.M{
 		"objectId": types.M{"type": "String"},
 		"params":   types.M{"type": "Object"},
 	},
+	"_Audience": types.M{
+		"objectId":  types.M{"type": "String"},
+		"name":      types.M{"type": "String"},
+		"query":     types.M{"type": "String"}, // the stringified JSON query
+		"lastUsed":  types.M{"type": "Date"},
+		"timesUsed": types.M{"type": "Number"},
+	},
+	"_Revision": types.M{
+		"targetClass": types.M{"type": "String"},
+		"targetId":    types.M{"type": "String"},
+		"fields":      types.M{"type": "Array"},
+		"values":      types.M{"type": "String"}, // the stringified JSON of old values
+		"editor":      types.M{"type": "String"},
+	},
+	"_File": types.M{
+		"name":        types.M{"type": "String"},
+		"url":         types.M{"type": "String"},
+		"contentType": types.M{"type": "String"},
+		"size":        types.M{"type": "Number"},
+		"uploader":    types.M{"type": "Pointer", "targetClass": "_User"},
+		"tags":        types.M{"type": "Array"},
+	},
+	"_EmailEvent": types.M{
+		"provider":  types.M{"type": "String"},
+		"email":     types.M{"type": "String"},
+		"event":     types.M{"type": "String"},
+		"messageId": types.M{"type": "String"},
+		"reason":    types.M{"type": "String"},
+		"timestamp": types.M{"type": "Date"},
+	},
+	"_RefreshToken": types.M{
+		"tokenHash":    types.M{"type": "String"},
+		"family":       types.M{"type": "String"},
+		"user":         types.M{"type": "Pointer", "targetClass": "_User"},
+		"sessionToken": types.M{"type": "String"},
+		"used":         types.M{"type": "Boolean"},
+		"expiresAt":    types.M{"type": "Date"},
+	},
+	"_KeyUsage": types.M{
+		"keyType":    types.M{"type": "String"},
+		"label":      types.M{"type": "String"},
+		"count":      types.M{"type": "Number"},
+		"lastUsedAt": types.M{"type": "Date"},
+		"lastIP":     types.M{"type": "String"},
+	},
+	"_Impersonation": types.M{
+		"user":      types.M{"type": "Pointer", "targetClass": "_User"},
+		"session":   types.M{"type": "String"},
+		"operator":  types.M{"type": "String"},
+		"keyLabel":  types.M{"type": "String"},
+		"ip":        types.M{"type": "String"},
+		"reason":    types.M{"type": "String"},
+		"expiresAt": types.M{"type": "Date"},
+	},
 }
 
 // requiredColumns 类必须要有的字段
@@ -126,7 +184,13 @@ type Schema struct {
 }
 
 // AddClassIfNotExists 添加类定义，包含默认的字段
+// 未指定 classLevelPermissions 时，使用 DefaultClassLevelPermissions 对应的权限
 func (s *Schema) AddClassIfNotExists(className string, fields types.M, classLevelPermissions types.M) (types.M, error) {
+	if classLevelPermissions == nil {
+		classLevelPermissions = defaultCLPForNewClass(className)
+	} else {
+		expandOwnerShorthand(classLevelPermissions)
+	}
 	err := s.validateNewClass(className, fields, classLevelPermissions)
 	if err != nil {
 		return nil, err
@@ -142,10 +206,18 @@ func (s *Schema) AddClassIfNotExists(className string, fields types.M, classLeve
 		if errs.GetErrorCode(err) == errs.DuplicateValue {
 			return nil, errs.E(errs.InvalidClassName, "Class "+className+" already exists.")
 		}
-		return nil, err
+		return nil, errs.FromAdapter(err)
 	}
 	result = convertAdapterSchemaToParseSchema(result)
+	for fieldName, fieldType := range fields {
+		s.ensureGeoIndex(className, fieldName, utils.M(fieldType))
+		s.ensureJoinIndexes(className, fieldName, utils.M(fieldType))
+	}
 	s.cache.Clear()
+	err = s.syncTTLIndex(className, "", ttlFieldOf(classLevelPermissions))
+	if err != nil {
+		return nil, err
+	}
 
 	return result, nil
 }
@@ -170,6 +242,24 @@ func (s *Schema) UpdateClass(className string, submittedFields types.M, classLev
 	if submittedFields == nil {
 		submittedFields = types.M{}
 	}
+	// 已存在的 Enum 字段可以重新提交定义以增加取值，与其他字段分开处理
+	enumUpdates := types.M{}
+	for name, v := range submittedFields {
+		field := utils.M(v)
+		if isEnumField(field) && isEnumField(utils.M(existingFields[name])) {
+			err := validateEnumExtension(name, utils.M(existingFields[name]), field)
+			if err != nil {
+				return nil, err
+			}
+			enumUpdates[name] = field
+		}
+	}
+	if len(enumUpdates) > 0 {
+		submittedFields = utils.CopyMap(submittedFields)
+		for name := range enumUpdates {
+			delete(submittedFields, name)
+		}
+	}
 	for name, v := range submittedFields {
 		field := utils.M(v)
 		if field == nil {
@@ -224,6 +314,12 @@ func (s *Schema) UpdateClass(className string, submittedFields types.M, classLev
 			return nil, err
 		}
 	}
+	for fieldName, v := range enumUpdates {
+		err = s.updateFieldType(className, fieldName, utils.M(v))
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// 重新加载修改过的数据
 	s.reloadData(types.M{"clearCache": true})
@@ -237,8 +333,8 @@ func (s *Schema) UpdateClass(className string, submittedFields types.M, classLev
 		}
 	}
 
-	// 设置 CLP
-	err = s.setPermissions(className, classLevelPermissions, newSchema)
+	// 设置 CLP ，未提交的 masterOnlyFields 保持不变
+	err = s.setPermissions(className, s.keepMasterOnlyFields(className, classLevelPermissions), newSchema)
 	if err != nil {
 		return nil, err
 	}
@@ -290,17 +386,18 @@ func (s *Schema) deleteFields(fieldNames []string, className string) error {
 
 	err = s.dbAdapter.DeleteFields(className, schema, fieldNames)
 	if err != nil {
-		return err
+		return errs.FromAdapter(err)
 	}
 
 	// 根据字段属性进行相应 对象数据 删除操作
 	for _, fieldName := range fieldNames {
 		if fieldType := utils.M(fields[fieldName]); fieldType != nil {
+			s.dropGeoIndex(className, fieldName, fieldType)
 			if utils.S(fieldType["type"]) == "Relation" {
 				// 删除 _Join table 数据
 				_, err = s.dbAdapter.DeleteClass("_Join:" + fieldName + ":" + className)
 				if err != nil {
-					return err
+					return errs.FromAdapter(err)
 				}
 			}
 		}
@@ -321,6 +418,9 @@ func (s *Schema) validateObject(className string, object, query types.M) error {
 	if object == nil {
 		object = types.M{}
 	}
+	normalizeObject(object)
+	s.coerceObject(className, object)
+	s.removeComputedFields(className, object)
 
 	for fieldName, v := range object {
 		if v == nil {
@@ -349,6 +449,10 @@ func (s *Schema) validateObject(className string, object, query types.M) error {
 		if err != nil {
 			return err
 		}
+		err = validateEnumValue(fieldName, s.getExpectedType(className, fieldName), v)
+		if err != nil {
+			return err
+		}
 	}
 
 	err = thenValidateRequiredColumns(s, className, object, query)
@@ -396,6 +500,48 @@ func (s *Schema) testBaseCLP(className string, aclGroup []string, operation stri
 	return false
 }
 
+// addFieldAllowed 类的字段是否允许修改，类级别权限中的 allowAddField 为 false 时不允许
+func (s *Schema) addFieldAllowed(className string) bool {
+	s.permsMutex.Lock()
+	defer s.permsMutex.Unlock()
+	if s.perms == nil {
+		return true
+	}
+	classPerms := utils.M(s.perms[className])
+	if classPerms == nil {
+		return true
+	}
+	if allow, ok := classPerms["allowAddField"].(bool); ok {
+		return allow
+	}
+	return true
+}
+
+// SetAllowAddField 锁定或者解锁类的字段，锁定之后只有 Master Key 可以添加字段
+func (s *Schema) SetAllowAddField(className string, allow bool) (types.M, error) {
+	schema, err := s.GetOneSchema(className, false, types.M{"clearCache": true})
+	if err != nil {
+		return nil, err
+	}
+	if len(schema) == 0 {
+		return nil, errs.E(errs.InvalidClassName, "Class "+className+" does not exist.")
+	}
+	perms := utils.CopyMap(utils.M(schema["classLevelPermissions"]))
+	if perms == nil {
+		perms = types.M{}
+	}
+	perms["allowAddField"] = allow
+	err = s.setPermissions(className, perms, utils.M(schema["fields"]))
+	if err != nil {
+		return nil, err
+	}
+	return types.M{
+		"className":             className,
+		"fields":                schema["fields"],
+		"classLevelPermissions": perms,
+	}, nil
+}
+
 // validatePermission 校验对指定类的操作权限
 func (s *Schema) validatePermission(className string, aclGroup []string, operation string) error {
 	if s.testBaseCLP(className, aclGroup, operation) {
@@ -419,6 +565,14 @@ func (s *Schema) validatePermission(className string, aclGroup []string, operati
 		return nil
 	}
 
+	// 只允许所有者访问时，需要是登录用户，查询时再限制 ownerField 为当前用户
+	if ownerFieldFor(classPerms, operation) != "" {
+		if userIDFromACL(aclGroup) == "" {
+			return errs.E(errs.ObjectNotFound, "Permission denied, user needs to be authenticated.")
+		}
+		return nil
+	}
+
 	var permissionField string
 	if operation == "get" || operation == "find" || operation == "count" {
 		permissionField = "readUserFields"
@@ -592,25 +746,68 @@ func (s *Schema) enforceFieldExists(className, fieldName string, fieldtype types
 	if dbTypeMatchesObjectType(s.getExpectedType(className, fieldName), fieldtype) == false {
 		return errs.E(errs.InvalidJSON, "Could not add field "+fieldName)
 	}
+	if err == nil {
+		s.ensureGeoIndex(className, fieldName, fieldtype)
+		s.ensureJoinIndexes(className, fieldName, fieldtype)
+	}
 	s.cache.Clear()
 	return nil
 }
 
+// ensureGeoIndex 为 GeoPoint 与 Polygon 字段创建地理位置索引，适配器不支持时不做处理
+// 索引创建失败不影响字段的添加， MongoDB 在地理位置查询缺少索引时还会再次尝试创建
+func (s *Schema) ensureGeoIndex(className, fieldName string, fieldType types.M) {
+	if isGeoFieldType(fieldType) == false {
+		return
+	}
+	if indexer, ok := s.dbAdapter.(storage.GeoIndexer); ok {
+		indexer.EnsureGeoIndex(className, fieldName)
+	}
+}
+
+// ensureJoinIndexes 为 Relation 字段的 _Join 表创建索引，适配器不支持时不做处理
+func (s *Schema) ensureJoinIndexes(className, fieldName string, fieldType types.M) {
+	if utils.S(fieldType["type"]) != "Relation" {
+		return
+	}
+	if indexer, ok := s.dbAdapter.(storage.JoinIndexer); ok {
+		indexer.EnsureJoinIndexes(className, fieldName)
+	}
+}
+
+// dropGeoIndex 删除 GeoPoint 与 Polygon 字段上的地理位置索引
+func (s *Schema) dropGeoIndex(className, fieldName string, fieldType types.M) {
+	if isGeoFieldType(fieldType) == false {
+		return
+	}
+	if indexer, ok := s.dbAdapter.(storage.GeoIndexer); ok {
+		indexer.DropGeoIndex(className, fieldName)
+	}
+}
+
+// isGeoFieldType 是否为需要地理位置索引的字段类型
+func isGeoFieldType(fieldType types.M) bool {
+	t := utils.S(fieldType["type"])
+	return t == "GeoPoint" || t == "Polygon"
+}
+
 // setPermissions 给指定类设置权限
 func (s *Schema) setPermissions(className string, perms types.M, newSchema types.M) error {
 	if perms == nil {
 		return nil
 	}
+	expandOwnerShorthand(perms)
 	err := validateCLP(perms, newSchema)
 	if err != nil {
 		return err
 	}
+	oldTTLField := s.ttlField(className)
 	err = s.dbAdapter.SetClassLevelPermissions(className, perms)
 	if err != nil {
-		return err
+		return errs.FromAdapter(err)
 	}
 	s.reloadData(types.M{"clearCache": true})
-	return nil
+	return s.syncTTLIndex(className, oldTTLField, ttlFieldOf(perms))
 }
 
 // HasClass Schema 中是否存在类定义
@@ -708,20 +905,20 @@ func (s *Schema) GetAllClasses(options types.M) ([]types.M, error) {
 	if clearCache {
 		s.cache.Clear()
 	}
-	allClasses := s.cache.GetAllClasses()
-	if allClasses != nil && len(allClasses) > 0 && clearCache == false {
-		return allClasses, nil
-	}
+	// 缓存失效时由 SchemaCache 合并同时发生的加载请求
+	return s.cache.LoadAllClasses(s.loadAllClasses)
+}
 
+// loadAllClasses 从数据库中加载所有 Schema
+func (s *Schema) loadAllClasses() ([]types.M, error) {
 	allSchemas, err := s.dbAdapter.GetAllClasses()
 	if err != nil {
-		return nil, err
+		return nil, errs.FromAdapter(err)
 	}
 	schemas := []types.M{}
 	for _, v := range allSchemas {
 		schemas = append(schemas, injectDefaultSchema(v))
 	}
-	s.cache.SetAllClasses(schemas)
 	return schemas, nil
 }
 
@@ -764,7 +961,7 @@ func (s *Schema) GetOneSchema(className string, allowVolatileClasses bool, optio
 
 	schema, err := s.dbAdapter.GetClass(className)
 	if err != nil {
-		return nil, err
+		return nil, errs.FromAdapter(err)
 	}
 	if schema == nil || len(schema) == 0 {
 		return types.M{}, nil
@@ -791,6 +988,10 @@ func getType(obj interface{}) (types.M, error) {
 	case map[string]interface{}, []interface{}, types.M, types.S:
 		return getObjectType(obj)
 	default:
+		// 原生类型转换为 Parse 类型后再获取格式
+		if v, ok := toParseValue(obj); ok {
+			return getType(v)
+		}
 		return nil, errs.E(errs.IncorrectType, "bad obj. can not get type")
 	}
 }
@@ -830,13 +1031,17 @@ func getObjectType(obj interface{}) (types.M, error) {
 				if object["latitude"] != nil && object["longitude"] != nil {
 					return types.M{"type": "GeoPoint"}, nil
 				}
+			case "Polygon":
+				if object["coordinates"] != nil {
+					return types.M{"type": "Polygon"}, nil
+				}
 			case "Bytes":
 				if object["base64"] != nil {
 					return types.M{"type": "Bytes"}, nil
 				}
 			}
-			// 当 __type 的值不在以上 6 种类型之中时，为无效类型
-			// 当 __type 的值在以上 6 种类型之中，但是不符合详细规则时，为无效的类型
+			// 当 __type 的值不在以上 7 种类型之中时，为无效类型
+			// 当 __type 的值在以上 7 种类型之中，但是不符合详细规则时，为无效的类型
 			return nil, errs.E(errs.IncorrectType, "This is not a valid "+t)
 		}
 		if object["$ne"] != nil {
@@ -849,7 +1054,7 @@ func getObjectType(obj interface{}) (types.M, error) {
 				return types.M{"type": "Number"}, nil
 			case "Delete":
 				return nil, nil
-			case "Add", "AddUnique", "Remove":
+			case "Add", "AddUnique", "Remove", "UpdateMatching":
 				return types.M{"type": "Array"}, nil
 			case "AddRelation", "RemoveRelation":
 				if objects := utils.A(object["objects"]); objects != nil && len(objects) > 0 {
@@ -926,14 +1131,18 @@ func fieldNameIsValidForClass(fieldName string, className string) bool {
 }
 
 var validNonRelationOrPointerTypes = map[string]bool{
-	"Number":   true,
-	"String":   true,
-	"Boolean":  true,
-	"Date":     true,
-	"Object":   true,
-	"Array":    true,
-	"GeoPoint": true,
-	"File":     true,
+	"Number":          true,
+	"AutoIncrement":   true,
+	"String":          true,
+	"LocalizedString": true,
+	"Enum":            true,
+	"Boolean":         true,
+	"Date":            true,
+	"Object":          true,
+	"Array":           true,
+	"GeoPoint":        true,
+	"Polygon":         true,
+	"File":            true,
 }
 
 // fieldTypeIsInvalid 检测字段类型是否合法
@@ -964,6 +1173,14 @@ func fieldTypeIsInvalid(t types.M) error {
 		return nil
 	}
 
+	if fieldType == "Computed" {
+		return validateComputedField(t)
+	}
+
+	if fieldType == "Enum" {
+		return validateEnumField(t)
+	}
+
 	if validNonRelationOrPointerTypes[fieldType] == false {
 		return errs.E(errs.IncorrectType, "invalid field type: "+fieldType)
 	}
@@ -1001,14 +1218,85 @@ func validateCLP(perms types.M, fields types.M) error {
 			return errs.E(errs.InvalidJSON, operation+" is not a valid operation for class level permissions")
 		}
 
+		// allowAddField 为 false 时锁定类的字段
+		if operation == "allowAddField" {
+			if _, ok := perm.(bool); ok == false {
+				return errs.E(errs.InvalidJSON, "allowAddField must be a boolean value for class level permissions")
+			}
+			continue
+		}
+
+		// coerceTypes 为 true 时写入前转换值的类型
+		if operation == "coerceTypes" {
+			err := validateCoerceTypes(perm)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// ownerField 为类的所有者字段
+		if operation == "ownerField" {
+			err := validateOwnerField(perm, fields)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// defaults 为创建对象时的默认值
+		if operation == "defaults" {
+			err := validateCLPDefaults(perm, fields)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// revisions 为对象修订记录的保留策略
+		if operation == "revisions" {
+			err := validateRevisions(perm)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// relationCounters 为 Relation 字段的计数字段
+		if operation == "relationCounters" {
+			err := validateRelationCounters(perm, fields)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// masterOnlyFields 为只能使用 Master Key 写入的字段
+		if operation == "masterOnlyFields" {
+			err := validateMasterOnlyFields(perm)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// ttlField 为对象的过期时间字段
+		if operation == "ttlField" {
+			err := validateTTLField(perm, fields)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
 		if operation == "readUserFields" || operation == "writeUserFields" {
 			if p := utils.A(perm); p != nil {
 				for _, v := range p {
 					key := utils.S(v)
-					// 字段类型必须为指向 _User 的指针类型
+					// 字段类型必须为指向 _User 的 Pointer 、 Relation ，或者保存用户 Pointer 的 Array
 					if fields != nil && fields[key] != nil {
 						if t := utils.M(fields[key]); t != nil {
-							if utils.S(t["type"]) == "Pointer" && utils.S(t["targetClass"]) == "_User" {
+							if pointerPermissionFieldIsValid(t) {
 								continue
 							}
 						}
@@ -1022,6 +1310,13 @@ func validateCLP(perms types.M, fields types.M) error {
 
 		if p := utils.M(perm); p != nil {
 			for key, value := range p {
+				if key == clpOwner {
+					err := validateOwnerPermission(perms, operation, value)
+					if err != nil {
+						return err
+					}
+					continue
+				}
 				err := verifyPermissionKey(key)
 				if err != nil {
 					return err
@@ -1052,6 +1347,17 @@ var requireAuthenticationRegex = `^requiresAuthentication$`
 
 var permissionKeyRegex = []string{userIDRegex, roleRegex, publicRegex, requireAuthenticationRegex}
 
+// pointerPermissionFieldIsValid 字段是否可以用于 readUserFields 与 writeUserFields
+func pointerPermissionFieldIsValid(t types.M) bool {
+	switch utils.S(t["type"]) {
+	case "Pointer", "Relation":
+		return utils.S(t["targetClass"]) == "_User"
+	case "Array":
+		return true
+	}
+	return false
+}
+
 // verifyPermissionKey 校验 CLP 中各种操作包含的角色名是否合法
 // 可以是24位的用户 ID，可以是角色名 role:abc ,可以是公共权限 *
 func verifyPermissionKey(key string) error {
@@ -1279,6 +1585,18 @@ func dbTypeMatchesObjectType(dbType, objectType types.M) bool {
 	if dbType == nil && objectType != nil {
 		return false
 	}
+	// AutoIncrement 字段的值为 Number
+	if utils.S(dbType["type"]) == "AutoIncrement" && utils.S(objectType["type"]) == "Number" {
+		return true
+	}
+	// LocalizedString 字段的值为 Object
+	if utils.S(dbType["type"]) == "LocalizedString" && utils.S(objectType["type"]) == "Object" {
+		return true
+	}
+	// Enum 字段的值为 String
+	if utils.S(dbType["type"]) == "Enum" && utils.S(objectType["type"]) == "String" {
+		return true
+	}
 	if utils.S(dbType["type"]) != utils.S(objectType["type"]) {
 		return false
 	}
@@ -1,6 +1,7 @@
 package orm
 
 import (
+	"encoding/json"
 	"errors"
 	"reflect"
 	"testing"
@@ -1202,7 +1203,7 @@ func Test_setPermissions(t *testing.T) {
 	}
 	newSchema = nil
 	err = schama.setPermissions(className, perms, newSchema)
-	expect = errors.New("not found")
+	expect = errs.Wrap(errs.InvalidClassName, "Class class does not exist.", errors.New("not found"))
 	if reflect.DeepEqual(expect, err) == false {
 		t.Error("expect:", expect, "result:", err)
 	}
@@ -1848,6 +1849,34 @@ func Test_getType(t *testing.T) {
 	/************************************************************/
 	object = time.Now()
 	result, err = getType(object)
+	expect = types.M{"type": "Date"}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/************************************************************/
+	object = []string{"a", "b"}
+	result, err = getType(object)
+	expect = types.M{"type": "Array"}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/************************************************************/
+	object = map[string]string{"a": "b"}
+	result, err = getType(object)
+	expect = types.M{"type": "Object"}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/************************************************************/
+	object = json.Number("10.24")
+	result, err = getType(object)
+	expect = types.M{"type": "Number"}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	/************************************************************/
+	object = struct{}{}
+	result, err = getType(object)
 	expect = errs.E(errs.IncorrectType, "bad obj. can not get type")
 	if err == nil || reflect.DeepEqual(expect, err) == false {
 		t.Error("expect:", expect, "result:", result, err)
@@ -2652,6 +2681,40 @@ func Test_validateCLP(t *testing.T) {
 		t.Error("expect:", expect, "result:", err)
 	}
 	/************************************************************/
+	perms = types.M{
+		"readUserFields":  types.S{"members", "editors"},
+		"writeUserFields": types.S{"editors"},
+	}
+	fields = types.M{
+		"members": types.M{
+			"type":        "Relation",
+			"targetClass": "_User",
+		},
+		"editors": types.M{
+			"type": "Array",
+		},
+	}
+	err = validateCLP(perms, fields)
+	expect = nil
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
+	perms = types.M{
+		"readUserFields": types.S{"tags"},
+	}
+	fields = types.M{
+		"tags": types.M{
+			"type":        "Relation",
+			"targetClass": "Tag",
+		},
+	}
+	err = validateCLP(perms, fields)
+	expect = errs.E(errs.InvalidJSON, "tags is not a valid column for class level pointer permissions readUserFields")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
 	perms = types.M{
 		"get": types.M{"abc": true},
 	}
@@ -2681,6 +2744,70 @@ func Test_validateCLP(t *testing.T) {
 	if reflect.DeepEqual(expect, err) == false {
 		t.Error("expect:", expect, "result:", err)
 	}
+	/************************************************************/
+	perms = types.M{
+		"allowAddField": false,
+	}
+	fields = nil
+	err = validateCLP(perms, fields)
+	expect = nil
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
+	perms = types.M{
+		"allowAddField": "false",
+	}
+	fields = nil
+	err = validateCLP(perms, fields)
+	expect = errs.E(errs.InvalidJSON, "allowAddField must be a boolean value for class level permissions")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
+	perms = types.M{
+		"coerceTypes": true,
+	}
+	fields = nil
+	err = validateCLP(perms, fields)
+	expect = nil
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
+	perms = types.M{
+		"coerceTypes": 1,
+	}
+	fields = nil
+	err = validateCLP(perms, fields)
+	expect = errs.E(errs.InvalidJSON, "coerceTypes must be a boolean value for class level permissions")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
+	perms = types.M{
+		"ttlField": "expiresAt",
+	}
+	fields = types.M{
+		"expiresAt": types.M{"type": "Date"},
+	}
+	err = validateCLP(perms, fields)
+	expect = nil
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/************************************************************/
+	perms = types.M{
+		"ttlField": "expiresAt",
+	}
+	fields = types.M{
+		"expiresAt": types.M{"type": "String"},
+	}
+	err = validateCLP(perms, fields)
+	expect = errs.E(errs.InvalidJSON, "expiresAt is not a valid column for ttlField, it must be a Date field")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
 }
 
 func Test_verifyPermissionKey(t *testing.T) {
@@ -3220,6 +3347,30 @@ func Test_dbTypeMatchesObjectType(t *testing.T) {
 	if ok != expect {
 		t.Error("expect:", expect, "result:", ok)
 	}
+	/************************************************************/
+	dbType = types.M{"type": "AutoIncrement"}
+	objectType = types.M{"type": "Number"}
+	ok = dbTypeMatchesObjectType(dbType, objectType)
+	expect = true
+	if ok != expect {
+		t.Error("expect:", expect, "result:", ok)
+	}
+	/************************************************************/
+	dbType = types.M{"type": "AutoIncrement"}
+	objectType = types.M{"type": "String"}
+	ok = dbTypeMatchesObjectType(dbType, objectType)
+	expect = false
+	if ok != expect {
+		t.Error("expect:", expect, "result:", ok)
+	}
+	/************************************************************/
+	dbType = types.M{"type": "LocalizedString"}
+	objectType = types.M{"type": "Object"}
+	ok = dbTypeMatchesObjectType(dbType, objectType)
+	expect = true
+	if ok != expect {
+		t.Error("expect:", expect, "result:", ok)
+	}
 }
 
 func Test_Load(t *testing.T) {
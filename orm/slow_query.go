@@ -0,0 +1,259 @@
+package orm
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/storage"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 查询耗时超过 SlowQueryThreshold 时记录到内存中的慢查询日志，只保留最近的 SlowQueryLogSize 条
+// 记录时根据查询条件与排序字段生成候选索引，按照 相等条件字段、排序字段、范围条件字段 的顺序组成
+// 开启 SlowQueryAutoIndex 时，同一个候选索引出现 SlowQueryAutoIndexMinCount 次后由适配器在后台创建
+
+// slowQuery 一条慢查询记录
+type slowQuery struct {
+	className string
+	index     []string // 候选索引，无法生成时为空
+	duration  time.Duration
+}
+
+// slowQueryLog 慢查询日志，超过容量时丢弃最早的记录
+type slowQueryLog struct {
+	mutex   sync.Mutex
+	entries []slowQuery
+	created map[string]bool // 已经自动创建的索引
+}
+
+var slowQueries = &slowQueryLog{created: map[string]bool{}}
+
+// record 添加一条记录，返回日志中与该记录候选索引相同的记录数
+func (l *slowQueryLog) record(q slowQuery, size int) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries = append(l.entries, q)
+	if len(l.entries) > size {
+		l.entries = l.entries[len(l.entries)-size:]
+	}
+	if len(q.index) == 0 {
+		return 0
+	}
+	key := indexKey(q.className, q.index)
+	count := 0
+	for _, e := range l.entries {
+		if e.className == q.className && indexKey(e.className, e.index) == key {
+			count++
+		}
+	}
+	return count
+}
+
+// markCreated 标记索引已经自动创建，已经标记过时返回 false
+func (l *slowQueryLog) markCreated(className string, index []string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	key := indexKey(className, index)
+	if l.created[key] {
+		return false
+	}
+	l.created[key] = true
+	return true
+}
+
+// list 返回指定类的记录， className 为空时返回所有记录
+func (l *slowQueryLog) list(className string) []slowQuery {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	result := []slowQuery{}
+	for _, e := range l.entries {
+		if className == "" || e.className == className {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// reset 清空日志，仅用于测试
+func (l *slowQueryLog) reset() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries = nil
+	l.created = map[string]bool{}
+}
+
+func indexKey(className string, index []string) string {
+	return className + ":" + strings.Join(index, ",")
+}
+
+// recordSlowQuery 查询耗时超过阈值时记录到慢查询日志，需要时在后台创建候选索引
+func recordSlowQuery(className string, schema, query types.M, keys []string, start time.Time) {
+	c := config.TConfig
+	if c.SlowQueryThreshold <= 0 {
+		return
+	}
+	duration := time.Since(start)
+	if duration < time.Duration(c.SlowQueryThreshold)*time.Millisecond {
+		return
+	}
+	fields := utils.M(schema["fields"])
+	index := indexCandidate(query, keys, fields)
+	count := slowQueries.record(slowQuery{className: className, index: index, duration: duration}, c.SlowQueryLogSize)
+	if c.SlowQueryAutoIndex == false || len(index) == 0 || count < c.SlowQueryAutoIndexMinCount {
+		return
+	}
+	indexer, ok := Adapter.(storage.Indexer)
+	if ok == false || slowQueries.markCreated(className, index) == false {
+		return
+	}
+	go indexer.EnsureIndex(className, schema, index)
+}
+
+// SuggestIndexes 根据慢查询日志返回类的建议索引， className 为空时返回所有类的建议索引
+// 返回格式： [{"className":"post","fields":["author","-createdAt"],"count":12,"totalTime":3400}] ， totalTime 单位为毫秒
+// 按出现次数从多到少排列，次数相同时按总耗时从多到少排列
+func (d *DBController) SuggestIndexes(className string) []types.M {
+	type suggestion struct {
+		className string
+		index     []string
+		count     int
+		totalTime time.Duration
+	}
+	suggestions := map[string]*suggestion{}
+	for _, q := range slowQueries.list(className) {
+		if len(q.index) == 0 {
+			continue
+		}
+		key := indexKey(q.className, q.index)
+		s := suggestions[key]
+		if s == nil {
+			s = &suggestion{className: q.className, index: q.index}
+			suggestions[key] = s
+		}
+		s.count++
+		s.totalTime += q.duration
+	}
+
+	list := []*suggestion{}
+	for _, s := range suggestions {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].count != list[j].count {
+			return list[i].count > list[j].count
+		}
+		if list[i].totalTime != list[j].totalTime {
+			return list[i].totalTime > list[j].totalTime
+		}
+		return indexKey(list[i].className, list[i].index) < indexKey(list[j].className, list[j].index)
+	})
+
+	result := []types.M{}
+	for _, s := range list {
+		result = append(result, types.M{
+			"className": s.className,
+			"fields":    s.index,
+			"count":     s.count,
+			"totalTime": int64(s.totalTime / time.Millisecond),
+		})
+	}
+	return result
+}
+
+// indexCandidate 根据查询条件与排序字段生成候选索引，倒序的排序字段带有前缀 "-"
+// 只使用 fields 中可以建立普通索引的顶层字段， objectId 已有唯一索引， $or 中的条件无法使用同一个索引，都不参与计算
+func indexCandidate(query types.M, keys []string, fields types.M) []string {
+	equality := map[string]bool{}
+	ranges := map[string]bool{}
+	collectIndexFields(query, fields, equality, ranges)
+
+	index := []string{}
+	used := map[string]bool{}
+	for _, fieldName := range sortedKeys(equality) {
+		index = append(index, fieldName)
+		used[fieldName] = true
+	}
+	for _, key := range keys {
+		fieldName := strings.TrimPrefix(key, "-")
+		if used[fieldName] || indexableField(fieldName, fields) == false {
+			continue
+		}
+		index = append(index, key)
+		used[fieldName] = true
+	}
+	for _, fieldName := range sortedKeys(ranges) {
+		if used[fieldName] {
+			continue
+		}
+		index = append(index, fieldName)
+		used[fieldName] = true
+	}
+	if len(index) == 0 {
+		return nil
+	}
+	return index
+}
+
+// collectIndexFields 收集查询条件中使用相等条件与范围条件的字段
+func collectIndexFields(query types.M, fields types.M, equality, ranges map[string]bool) {
+	for key, value := range query {
+		if key == "$and" {
+			for _, q := range utils.A(value) {
+				collectIndexFields(utils.M(q), fields, equality, ranges)
+			}
+			continue
+		}
+		if indexableField(key, fields) == false {
+			continue
+		}
+		constraint := utils.M(value)
+		if constraint == nil || isConstraint(constraint) == false {
+			equality[key] = true
+			continue
+		}
+		if constraint["$eq"] != nil || constraint["$in"] != nil {
+			equality[key] = true
+		} else if constraint["$gt"] != nil || constraint["$gte"] != nil || constraint["$lt"] != nil || constraint["$lte"] != nil {
+			ranges[key] = true
+		}
+	}
+}
+
+// isConstraint 是否为包含 $ 操作符的查询条件
+func isConstraint(value types.M) bool {
+	for k := range value {
+		if strings.HasPrefix(k, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// indexableField 字段是否可以建立普通索引
+func indexableField(fieldName string, fields types.M) bool {
+	if fieldName == "objectId" || strings.HasPrefix(fieldName, "$") || strings.HasPrefix(fieldName, "_") || strings.Contains(fieldName, ".") {
+		return false
+	}
+	fieldType := utils.M(fields[fieldName])
+	if fieldType == nil {
+		return false
+	}
+	switch utils.S(fieldType["type"]) {
+	case "String", "Enum", "Number", "AutoIncrement", "Boolean", "Date", "Pointer":
+		return true
+	}
+	return false
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
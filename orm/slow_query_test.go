@@ -0,0 +1,106 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_indexCandidate(t *testing.T) {
+	fields := types.M{
+		"objectId":  types.M{"type": "String"},
+		"createdAt": types.M{"type": "Date"},
+		"title":     types.M{"type": "String"},
+		"score":     types.M{"type": "Number"},
+		"author":    types.M{"type": "Pointer", "targetClass": "_User"},
+		"tags":      types.M{"type": "Array"},
+		"meta":      types.M{"type": "Object"},
+	}
+	tests := []struct {
+		name  string
+		query types.M
+		keys  []string
+		want  []string
+	}{
+		{
+			name:  "1",
+			query: types.M{},
+			keys:  []string{"objectId"},
+			want:  nil,
+		},
+		{
+			name:  "2",
+			query: types.M{"title": "hello", "author": types.M{"__type": "Pointer", "className": "_User", "objectId": "1"}},
+			keys:  nil,
+			want:  []string{"author", "title"},
+		},
+		{
+			name:  "3",
+			query: types.M{"score": types.M{"$gt": 10}, "title": types.M{"$in": types.S{"a", "b"}}},
+			keys:  []string{"-createdAt", "objectId"},
+			want:  []string{"title", "-createdAt", "score"},
+		},
+		{
+			name:  "4",
+			query: types.M{"$and": types.S{types.M{"title": "a"}, types.M{"score": types.M{"$lte": 1}}}, "$or": types.S{types.M{"author": "x"}}},
+			keys:  []string{"score"},
+			want:  []string{"title", "score"},
+		},
+		{
+			name:  "5",
+			query: types.M{"tags": "a", "meta.a": 1, "_rperm": types.M{"$in": types.S{"*"}}, "title": types.M{"$regex": "^a"}, "unknown": 1},
+			keys:  nil,
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		if got := indexCandidate(tt.query, tt.keys, fields); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. indexCandidate() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_SuggestIndexes(t *testing.T) {
+	defer slowQueries.reset()
+	slowQueries.reset()
+	slowQueries.record(slowQuery{className: "post", index: []string{"title"}, duration: 100 * time.Millisecond}, 10)
+	slowQueries.record(slowQuery{className: "post", index: []string{"author", "-createdAt"}, duration: 300 * time.Millisecond}, 10)
+	slowQueries.record(slowQuery{className: "post", index: nil, duration: 500 * time.Millisecond}, 10)
+	slowQueries.record(slowQuery{className: "user", index: []string{"name"}, duration: 200 * time.Millisecond}, 10)
+	count := slowQueries.record(slowQuery{className: "post", index: []string{"title"}, duration: 100 * time.Millisecond}, 10)
+	if count != 2 {
+		t.Error("expect:", 2, "result:", count)
+	}
+	d := &DBController{}
+	result := d.SuggestIndexes("post")
+	expect := []types.M{
+		{"className": "post", "fields": []string{"title"}, "count": 2, "totalTime": int64(200)},
+		{"className": "post", "fields": []string{"author", "-createdAt"}, "count": 1, "totalTime": int64(300)},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/************************************************************/
+	result = d.SuggestIndexes("")
+	if len(result) != 3 {
+		t.Error("expect:", 3, "result:", len(result))
+	}
+	/************************************************************/
+	slowQueries.reset()
+	for i := 0; i < 5; i++ {
+		slowQueries.record(slowQuery{className: "post", index: []string{"title"}, duration: time.Millisecond}, 3)
+	}
+	result = d.SuggestIndexes("post")
+	expect = []types.M{
+		{"className": "post", "fields": []string{"title"}, "count": 3, "totalTime": int64(3)},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/************************************************************/
+	if slowQueries.markCreated("post", []string{"title"}) == false || slowQueries.markCreated("post", []string{"title"}) == true {
+		t.Error("expect:", "index marked once")
+	}
+}
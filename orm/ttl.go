@@ -0,0 +1,152 @@
+package orm
+
+import (
+	"time"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/storage"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// 类级别权限中的 ttlField 指定一个 Date 类型的字段，字段值为对象的过期时间
+// 支持 TTL 索引的数据库（MongoDB）由数据库删除过期对象，其他数据库由后台任务定时删除
+// 字段为空的对象不会过期
+
+var ttlSweepStop chan struct{}
+
+// ttlFieldOf 从类级别权限中读取过期时间字段
+func ttlFieldOf(perms types.M) string {
+	if perms == nil {
+		return ""
+	}
+	return utils.S(perms["ttlField"])
+}
+
+// validateTTLField 过期时间字段必须为 Date 类型，且不能是 createdAt 与 updatedAt
+func validateTTLField(perm interface{}, fields types.M) error {
+	fieldName, ok := perm.(string)
+	if ok == false || fieldName == "" {
+		return errs.E(errs.InvalidJSON, "ttlField must be a field name for class level permissions")
+	}
+	if fieldName == "createdAt" || fieldName == "updatedAt" {
+		return errs.E(errs.InvalidJSON, fieldName+" is not a valid column for ttlField")
+	}
+	if fields != nil {
+		if t := utils.M(fields[fieldName]); t != nil && utils.S(t["type"]) == "Date" {
+			return nil
+		}
+	}
+	return errs.E(errs.InvalidJSON, fieldName+" is not a valid column for ttlField, it must be a Date field")
+}
+
+// ttlField 返回类的过期时间字段，未设置时返回空
+func (s *Schema) ttlField(className string) string {
+	s.permsMutex.Lock()
+	defer s.permsMutex.Unlock()
+	if s.perms == nil {
+		return ""
+	}
+	return ttlFieldOf(utils.M(s.perms[className]))
+}
+
+// syncTTLIndex 过期时间字段变化时，更新数据库中的 TTL 索引，适配器不支持时不做处理
+func (s *Schema) syncTTLIndex(className, oldField, newField string) error {
+	if oldField == newField {
+		return nil
+	}
+	indexer, ok := s.dbAdapter.(storage.TTLIndexer)
+	if ok == false {
+		return nil
+	}
+	if oldField != "" {
+		err := indexer.DropTTLIndex(className, oldField)
+		if err != nil {
+			return err
+		}
+	}
+	if newField != "" {
+		return indexer.EnsureTTLIndex(className, newField)
+	}
+	return nil
+}
+
+// addTTLFilter 在查询条件中排除已过期但尚未被删除的对象
+// 条件加入到 $and 中，不影响查询中已有的字段
+func addTTLFilter(query types.M, fieldName string, now time.Time) types.M {
+	newQuery := utils.CopyMap(query)
+	if newQuery == nil {
+		newQuery = types.M{}
+	}
+	notExpired := types.M{
+		"$or": types.S{
+			types.M{fieldName: types.M{"$exists": false}},
+			types.M{fieldName: types.M{"$gt": types.M{"__type": "Date", "iso": utils.TimetoString(now)}}},
+		},
+	}
+	and := types.S{}
+	if v := utils.A(newQuery["$and"]); v != nil {
+		and = append(and, v...)
+	}
+	newQuery["$and"] = append(and, notExpired)
+	return newQuery
+}
+
+// SweepExpiredObjects 删除所有类中已过期的对象，支持 TTL 索引的数据库无需调用
+func (d *DBController) SweepExpiredObjects() error {
+	schema := d.LoadSchema(types.M{"clearCache": true})
+	classes := map[string]string{}
+	schema.permsMutex.Lock()
+	for className, perms := range schema.perms {
+		if fieldName := ttlFieldOf(utils.M(perms)); fieldName != "" {
+			classes[className] = fieldName
+		}
+	}
+	schema.permsMutex.Unlock()
+
+	now := types.M{"__type": "Date", "iso": utils.TimetoString(time.Now())}
+	for className, fieldName := range classes {
+		parseFormatSchema, err := schema.GetOneSchema(className, false, nil)
+		if err != nil {
+			return err
+		}
+		err = Adapter.DeleteObjectsByQuery(className, parseFormatSchema, types.M{fieldName: types.M{"$lt": now}})
+		if err == nil {
+			clearClassObjectCache(className)
+		} else if errs.GetErrorCode(err) != errs.ObjectNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartTTLSweeper 在后台定时删除过期对象，数据库支持 TTL 索引时不启动
+func (d *DBController) StartTTLSweeper(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	if _, ok := Adapter.(storage.TTLIndexer); ok {
+		return
+	}
+	d.StopTTLSweeper()
+	stop := make(chan struct{})
+	ttlSweepStop = stop
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(interval):
+				d.SweepExpiredObjects()
+			}
+		}
+	}()
+}
+
+// StopTTLSweeper 停止后台删除过期对象
+func (d *DBController) StopTTLSweeper() {
+	if ttlSweepStop != nil {
+		close(ttlSweepStop)
+		ttlSweepStop = nil
+	}
+}
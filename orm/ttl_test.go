@@ -0,0 +1,99 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_validateTTLField(t *testing.T) {
+	fields := types.M{
+		"expiresAt": types.M{"type": "Date"},
+		"updatedAt": types.M{"type": "Date"},
+		"title":     types.M{"type": "String"},
+	}
+	tests := []struct {
+		name    string
+		perm    interface{}
+		wantErr error
+	}{
+		{
+			name:    "1",
+			perm:    "expiresAt",
+			wantErr: nil,
+		},
+		{
+			name:    "2",
+			perm:    "",
+			wantErr: errs.E(errs.InvalidJSON, "ttlField must be a field name for class level permissions"),
+		},
+		{
+			name:    "3",
+			perm:    true,
+			wantErr: errs.E(errs.InvalidJSON, "ttlField must be a field name for class level permissions"),
+		},
+		{
+			name:    "4",
+			perm:    "updatedAt",
+			wantErr: errs.E(errs.InvalidJSON, "updatedAt is not a valid column for ttlField"),
+		},
+		{
+			name:    "5",
+			perm:    "title",
+			wantErr: errs.E(errs.InvalidJSON, "title is not a valid column for ttlField, it must be a Date field"),
+		},
+		{
+			name:    "6",
+			perm:    "deletedAt",
+			wantErr: errs.E(errs.InvalidJSON, "deletedAt is not a valid column for ttlField, it must be a Date field"),
+		},
+	}
+	for _, tt := range tests {
+		if err := validateTTLField(tt.perm, fields); !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%q. validateTTLField() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_addTTLFilter(t *testing.T) {
+	now := time.Date(2016, 10, 1, 8, 0, 0, 0, time.UTC)
+	notExpired := types.M{
+		"$or": types.S{
+			types.M{"expiresAt": types.M{"$exists": false}},
+			types.M{"expiresAt": types.M{"$gt": types.M{"__type": "Date", "iso": "2016-10-01T08:00:00.000Z"}}},
+		},
+	}
+	tests := []struct {
+		name  string
+		query types.M
+		want  types.M
+	}{
+		{
+			name:  "1",
+			query: nil,
+			want:  types.M{"$and": types.S{notExpired}},
+		},
+		{
+			name:  "2",
+			query: types.M{"title": "hello", "_rperm": types.M{"$in": types.S{nil, "*"}}},
+			want: types.M{
+				"title":  "hello",
+				"_rperm": types.M{"$in": types.S{nil, "*"}},
+				"$and":   types.S{notExpired},
+			},
+		},
+		{
+			name:  "3",
+			query: types.M{"$and": types.S{types.M{"title": "hello"}}},
+			want:  types.M{"$and": types.S{types.M{"title": "hello"}, notExpired}},
+		},
+	}
+	for _, tt := range tests {
+		if got := addTTLFilter(tt.query, "expiresAt", now); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. addTTLFilter() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package orm
+
+import (
+	"sort"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// DefaultUnusedFieldsSampleSize 查找未使用字段时默认抽样的对象数
+const DefaultUnusedFieldsSampleSize = 1000
+
+// FindUnusedFields 抽样读取类中最近更新的 sampleSize 个对象，返回 schema 中定义但在所有样本中都没有值的字段，以及样本数量
+// 默认字段与 Relation 字段（数据保存在 _Join 表中）不参与检查，类中没有对象时无法判断，返回空列表
+func (d *DBController) FindUnusedFields(className string, sampleSize int) ([]string, int, error) {
+	if ClassNameIsValid(className) == false {
+		return nil, 0, errs.E(errs.InvalidClassName, InvalidClassNameMessage(className))
+	}
+	if sampleSize <= 0 {
+		sampleSize = DefaultUnusedFieldsSampleSize
+	}
+	schema := d.LoadSchema(nil)
+	sch, err := schema.GetOneSchema(className, false, types.M{"clearCache": true})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(sch) == 0 {
+		return nil, 0, errs.E(errs.InvalidClassName, "Class "+className+" does not exist.")
+	}
+
+	objects, err := d.Find(className, types.M{}, types.M{"limit": sampleSize, "sort": []string{"-updatedAt"}})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(objects) == 0 {
+		return []string{}, 0, nil
+	}
+	return unusedFields(className, utils.M(sch["fields"]), objects), len(objects), nil
+}
+
+// DeleteUnusedFields 查找并删除未使用的字段，返回删除的字段以及样本数量
+func (d *DBController) DeleteUnusedFields(className string, sampleSize int) ([]string, int, error) {
+	fields, sampled, err := d.FindUnusedFields(className, sampleSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(fields) == 0 {
+		return fields, sampled, nil
+	}
+	schema := d.LoadSchema(nil)
+	err = schema.deleteFields(fields, className)
+	if err != nil {
+		return nil, 0, err
+	}
+	schema.reloadData(types.M{"clearCache": true})
+	return fields, sampled, nil
+}
+
+// unusedFields 返回 fields 中可以删除、且在 objects 中都没有值的字段，按字段名排序
+func unusedFields(className string, fields types.M, objects types.S) []string {
+	used := map[string]bool{}
+	for _, o := range objects {
+		for key, value := range utils.M(o) {
+			if value != nil {
+				used[key] = true
+			}
+		}
+	}
+	result := []string{}
+	for fieldName, v := range fields {
+		if used[fieldName] || fieldNameIsValidForClass(fieldName, className) == false {
+			continue
+		}
+		if utils.S(utils.M(v)["type"]) == "Relation" {
+			continue
+		}
+		result = append(result, fieldName)
+	}
+	sort.Strings(result)
+	return result
+}
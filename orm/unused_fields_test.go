@@ -0,0 +1,42 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_unusedFields(t *testing.T) {
+	fields := types.M{
+		"objectId":  types.M{"type": "String"},
+		"createdAt": types.M{"type": "Date"},
+		"updatedAt": types.M{"type": "Date"},
+		"ACL":       types.M{"type": "ACL"},
+		"title":     types.M{"type": "String"},
+		"oldTitle":  types.M{"type": "String"},
+		"author":    types.M{"type": "Pointer", "targetClass": "_User"},
+		"likes":     types.M{"type": "Relation", "targetClass": "_User"},
+		"score":     types.M{"type": "Number"},
+	}
+	objects := types.S{
+		types.M{"objectId": "1", "title": "hello", "score": nil},
+		types.M{"objectId": "2", "author": types.M{"__type": "Pointer", "className": "_User", "objectId": "u1"}},
+	}
+	result := unusedFields("post", fields, objects)
+	expect := []string{"oldTitle", "score"}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/************************************************************/
+	fields = types.M{
+		"username": types.M{"type": "String"},
+		"nickname": types.M{"type": "String"},
+	}
+	objects = types.S{types.M{"objectId": "1"}}
+	result = unusedFields("_User", fields, objects)
+	expect = []string{"nickname"}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
@@ -0,0 +1,184 @@
+package privacy
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/throttle"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// exportPageSize 导出时每次查询的对象数
+const exportPageSize = 1000
+
+// exportClasses 参与导出的系统类，其他以 _ 开头的类保存的是服务端数据，不导出
+var exportClasses = map[string]bool{
+	"_User":         true,
+	"_Role":         true,
+	"_Session":      true,
+	"_Installation": true,
+}
+
+// exportStore 用户导出次数的计数
+var exportStore throttle.Store = throttle.NewMemoryStore()
+
+// CheckExportLimit 记录一次用户的导出请求，超过 DataExportLimit 时返回 RequestLimitExceeded 错误
+func CheckExportLimit(userID string) error {
+	c := config.TConfig
+	if c.DataExportLimit <= 0 {
+		return nil
+	}
+	count, err := exportStore.Incr("export:"+userID, time.Duration(c.DataExportWindow)*time.Second)
+	if err != nil {
+		return nil
+	}
+	if count > c.DataExportLimit {
+		return errs.E(errs.RequestLimitExceeded, "Too many data export requests, please try again later.")
+	}
+	return nil
+}
+
+// Export 用户个人数据的导出任务，包括用户对象，以及 ACL 中包含该用户读权限或者 Pointer 字段指向该用户的对象
+// acl 为 nil 时使用 Master 权限查询，否则只导出 acl 可以读取的对象，跳过类级别权限不允许查询的类
+type Export struct {
+	userID  string
+	acl     []string
+	classes []types.M
+}
+
+// NewExport 校验用户是否存在并加载所有类，出错时尚未写出任何数据
+// 用户自己导出时 acl 为用户 id 与所属角色，使用 Master Key 时为 nil
+func NewExport(userID string, acl []string) (*Export, error) {
+	d := orm.TalismanDBController
+	users, err := d.Find("_User", types.M{"objectId": userID}, types.M{})
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, errs.E(errs.ObjectNotFound, "User not found.")
+	}
+	classes, err := d.LoadSchema(nil).GetAllClasses(types.M{"clearCache": true})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		return utils.S(classes[i]["className"]) < utils.S(classes[j]["className"])
+	})
+	return &Export{userID: userID, acl: acl, classes: classes}, nil
+}
+
+// Stream 分批查询并以 JSON 格式写出数据，没有数据的类不写出：
+// {"userId":"xxx","exportedAt":"2016-02-28T13:25:05.123Z","classes":{"Post":[{...},{...}],"_User":[{...}]}}
+// 对象中不包含密码、 sessionToken 与内部字段， authData 只保留第三方平台的名称
+func (e *Export) Stream(out io.Writer) error {
+	w := bufio.NewWriter(out)
+	header, _ := json.Marshal(types.M{"userId": e.userID, "exportedAt": utils.TimetoString(time.Now())})
+	w.Write(header[:len(header)-1])
+	w.WriteString(`,"classes":{`)
+
+	d := orm.TalismanDBController
+	pointer := types.M{"__type": "Pointer", "className": "_User", "objectId": e.userID}
+	firstClass := true
+	for _, class := range e.classes {
+		className := utils.S(class["className"])
+		if strings.HasPrefix(className, "_") && exportClasses[className] == false {
+			continue
+		}
+		query := exportQuery(className, utils.M(class["fields"]), e.userID, pointer)
+		firstObject := true
+		for skip := 0; ; skip += exportPageSize {
+			options := types.M{"skip": skip, "limit": exportPageSize, "sort": []string{"objectId"}}
+			if e.acl != nil {
+				options["acl"] = e.acl
+			}
+			objects, err := d.Find(className, query, options)
+			if err != nil {
+				// 类级别权限不允许用户查询该类
+				if e.acl != nil && skip == 0 {
+					if code := errs.GetErrorCode(err); code == errs.OperationForbidden || code == errs.ObjectNotFound {
+						break
+					}
+				}
+				return err
+			}
+			for _, o := range objects {
+				b, err := json.Marshal(sanitizeExportObject(utils.M(o)))
+				if err != nil {
+					return err
+				}
+				if firstObject {
+					if firstClass == false {
+						w.WriteString(",")
+					}
+					name, _ := json.Marshal(className)
+					w.Write(name)
+					w.WriteString(":[")
+					firstClass = false
+					firstObject = false
+				} else {
+					w.WriteString(",")
+				}
+				w.Write(b)
+			}
+			// 每批数据写出后发送给客户端，客户端断开时停止查询
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if len(objects) < exportPageSize {
+				break
+			}
+		}
+		if firstObject == false {
+			w.WriteString("]")
+		}
+	}
+	w.WriteString("}}")
+	return w.Flush()
+}
+
+// exportQuery 生成查询用户数据的条件， _User 只导出用户自己
+func exportQuery(className string, fields types.M, userID string, pointer types.M) types.M {
+	if className == "_User" {
+		return types.M{"objectId": userID}
+	}
+	conditions := types.S{types.M{"_rperm": types.M{"$in": types.S{userID}}}}
+	for _, fieldName := range sortedFieldNames(fields) {
+		field := utils.M(fields[fieldName])
+		if utils.S(field["type"]) == "Pointer" && utils.S(field["targetClass"]) == "_User" {
+			conditions = append(conditions, types.M{fieldName: pointer})
+		}
+	}
+	if len(conditions) == 1 {
+		return utils.M(conditions[0])
+	}
+	return types.M{"$or": conditions}
+}
+
+// sanitizeExportObject 删除密码、 sessionToken 与内部字段， authData 替换为第三方平台名称的列表
+func sanitizeExportObject(object types.M) types.M {
+	result := types.M{}
+	for key, value := range object {
+		if strings.HasPrefix(key, "_") || key == "password" || key == "sessionToken" {
+			continue
+		}
+		if key == "authData" {
+			providers := []string{}
+			for provider := range utils.M(value) {
+				providers = append(providers, provider)
+			}
+			sort.Strings(providers)
+			result[key] = providers
+			continue
+		}
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,379 @@
+// Package privacy 删除或者匿名化指定用户的个人数据，用于处理 GDPR 等法规要求的删除请求
+// 处理范围包括：用户的 _User 对象及其中的文件、 _Session 、 _Installation 、
+// 所有类中指向该用户的 Pointer 字段（按 ErasureReferenceActions 处理）以及包含该用户的 Relation 字段
+// 用户对象最后处理，之前的步骤出错时保留用户对象，修复问题后可以再次执行
+package privacy
+
+import (
+	"sort"
+
+	"github.com/okobsamoht/talisman/cache"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/files"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+const (
+	// ModeErase 删除用户对象
+	ModeErase = "erase"
+	// ModeAnonymize 保留用户对象，清除其他字段，用户名替换为随机值
+	ModeAnonymize = "anonymize"
+)
+
+// 对指向用户的 Pointer 字段的处理方式
+const (
+	actionUnset  = "unset"
+	actionDelete = "delete"
+	actionKeep   = "keep"
+)
+
+// Erase 按 mode 删除或者匿名化用户 userID 的数据，返回处理报告， dryRun 为 true 时只统计不修改
+// 报告格式：
+//
+//	{
+//		"userId": "xxx",
+//		"mode": "erase",
+//		"dryRun": false,
+//		"user": "deleted", // 匿名化时为 anonymized ，之前的步骤出错时为 kept
+//		"sessions": 2,
+//		"installations": 1,
+//		"files": ["xxx-avatar.png"],
+//		"references": [{"className": "Post", "fieldName": "author", "action": "unset", "count": 3}],
+//		"relations": [{"className": "_Role", "fieldName": "users", "count": 1}],
+//		"errors": []
+//	}
+//
+// 用户不存在时返回错误，其他步骤出错时记录到 errors 中并继续处理
+func Erase(userID, mode string, dryRun bool) (types.M, error) {
+	if mode == "" {
+		mode = ModeErase
+	}
+	if mode != ModeErase && mode != ModeAnonymize {
+		return nil, errs.E(errs.InvalidJSON, "mode should be erase or anonymize")
+	}
+	d := orm.TalismanDBController
+	users, err := d.Find("_User", types.M{"objectId": userID}, types.M{})
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, errs.E(errs.ObjectNotFound, "User not found.")
+	}
+	classes, err := d.LoadSchema(nil).GetAllClasses(types.M{"clearCache": true})
+	if err != nil {
+		return nil, err
+	}
+
+	e := &eraser{
+		userID:  userID,
+		pointer: types.M{"__type": "Pointer", "className": "_User", "objectId": userID},
+		mode:    mode,
+		dryRun:  dryRun,
+		report: types.M{
+			"userId":        userID,
+			"mode":          mode,
+			"dryRun":        dryRun,
+			"sessions":      0,
+			"installations": 0,
+			"files":         []string{},
+			"references":    types.S{},
+			"relations":     types.S{},
+			"errors":        types.S{},
+		},
+	}
+	e.eraseReferences(classes)
+	installationIDs := e.eraseSessions()
+	e.eraseInstallations(classes, installationIDs)
+	e.eraseUser(utils.M(users[0]))
+	return e.report, nil
+}
+
+type eraser struct {
+	userID  string
+	pointer types.M
+	mode    string
+	dryRun  bool
+	files   []string // 需要删除的文件
+	report  types.M
+}
+
+func (e *eraser) addError(err error) {
+	e.report["errors"] = append(utils.A(e.report["errors"]), err.Error())
+}
+
+func (e *eraser) addFiles(object types.M) {
+	for _, name := range fileNames(object) {
+		if containsString(e.files, name) == false {
+			e.files = append(e.files, name)
+		}
+	}
+}
+
+// eraseReferences 处理除 _Session 与 _Installation 以外的类中指向用户的 Pointer 字段，以及包含用户的 Relation 字段
+// 匿名化时用户对象仍然存在， Relation 字段保持不变
+func (e *eraser) eraseReferences(classes []types.M) {
+	actions := referenceActions(config.TConfig.ErasureReferenceActions)
+	for _, class := range classes {
+		className := utils.S(class["className"])
+		if className == "_Session" || className == "_Installation" {
+			continue
+		}
+		fields := utils.M(class["fields"])
+		for _, fieldName := range sortedFieldNames(fields) {
+			field := utils.M(fields[fieldName])
+			if utils.S(field["targetClass"]) != "_User" {
+				continue
+			}
+			switch utils.S(field["type"]) {
+			case "Pointer":
+				action := actions[className+"."+fieldName]
+				if action == "" {
+					action = defaultAction(e.mode)
+				}
+				e.erasePointer(className, fieldName, action)
+			case "Relation":
+				if e.mode == ModeAnonymize {
+					continue
+				}
+				count, err := orm.TalismanDBController.RemoveRelatedObject(className, fieldName, e.userID, e.dryRun)
+				if err != nil {
+					e.addError(err)
+					continue
+				}
+				e.report["relations"] = append(utils.A(e.report["relations"]), types.M{
+					"className": className,
+					"fieldName": fieldName,
+					"count":     count,
+				})
+			}
+		}
+	}
+}
+
+// erasePointer 按 action 处理 className 中 fieldName 指向用户的对象，删除对象时同时删除其中的文件
+func (e *eraser) erasePointer(className, fieldName, action string) {
+	d := orm.TalismanDBController
+	query := types.M{fieldName: e.pointer}
+	objects, err := d.Find(className, query, types.M{})
+	if err != nil {
+		e.addError(err)
+		return
+	}
+	if len(objects) > 0 && e.dryRun == false {
+		switch action {
+		case actionUnset:
+			_, err = d.Update(className, query, types.M{fieldName: types.M{"__op": "Delete"}}, types.M{"many": true}, false)
+		case actionDelete:
+			err = d.Destroy(className, query, types.M{})
+		}
+		if err != nil {
+			e.addError(err)
+			return
+		}
+	}
+	if action == actionDelete {
+		for _, o := range objects {
+			e.addFiles(utils.M(o))
+		}
+	}
+	e.report["references"] = append(utils.A(e.report["references"]), types.M{
+		"className": className,
+		"fieldName": fieldName,
+		"action":    action,
+		"count":     len(objects),
+	})
+}
+
+// eraseSessions 删除用户的所有 Session ，返回 Session 中的 installationId
+func (e *eraser) eraseSessions() []string {
+	d := orm.TalismanDBController
+	query := types.M{"user": e.pointer}
+	sessions, err := d.Find("_Session", query, types.M{})
+	if err != nil {
+		e.addError(err)
+		return nil
+	}
+	installationIDs := []string{}
+	for _, s := range sessions {
+		if id := utils.S(utils.M(s)["installationId"]); id != "" && containsString(installationIDs, id) == false {
+			installationIDs = append(installationIDs, id)
+		}
+	}
+	if len(sessions) > 0 && e.dryRun == false {
+		if err := d.Destroy("_Session", query, types.M{}); err != nil {
+			e.addError(err)
+			return installationIDs
+		}
+		for _, s := range sessions {
+			cache.User.Del(utils.S(utils.M(s)["sessionToken"]))
+		}
+	}
+	e.report["sessions"] = len(sessions)
+	return installationIDs
+}
+
+// eraseInstallations 删除用户登录过的设备，以及 _Installation 中 Pointer 字段指向用户的设备
+func (e *eraser) eraseInstallations(classes []types.M, installationIDs []string) {
+	conditions := types.S{}
+	if len(installationIDs) > 0 {
+		ids := types.S{}
+		for _, id := range installationIDs {
+			ids = append(ids, id)
+		}
+		conditions = append(conditions, types.M{"installationId": types.M{"$in": ids}})
+	}
+	for _, class := range classes {
+		if utils.S(class["className"]) != "_Installation" {
+			continue
+		}
+		fields := utils.M(class["fields"])
+		for _, fieldName := range sortedFieldNames(fields) {
+			field := utils.M(fields[fieldName])
+			if utils.S(field["type"]) == "Pointer" && utils.S(field["targetClass"]) == "_User" {
+				conditions = append(conditions, types.M{fieldName: e.pointer})
+			}
+		}
+	}
+	if len(conditions) == 0 {
+		return
+	}
+	query := utils.M(conditions[0])
+	if len(conditions) > 1 {
+		query = types.M{"$or": conditions}
+	}
+
+	d := orm.TalismanDBController
+	installations, err := d.Find("_Installation", query, types.M{})
+	if err != nil {
+		e.addError(err)
+		return
+	}
+	if len(installations) > 0 && e.dryRun == false {
+		if err := d.Destroy("_Installation", query, types.M{}); err != nil {
+			e.addError(err)
+			return
+		}
+	}
+	e.report["installations"] = len(installations)
+}
+
+// eraseUser 删除或者匿名化用户对象，并删除其中的文件，之前的步骤出错时不处理
+func (e *eraser) eraseUser(user types.M) {
+	if len(utils.A(e.report["errors"])) > 0 {
+		e.report["user"] = "kept"
+		return
+	}
+	d := orm.TalismanDBController
+	e.addFiles(user)
+	if e.dryRun == false {
+		var err error
+		if e.mode == ModeAnonymize {
+			_, err = d.Update("_User", types.M{"objectId": e.userID}, anonymizeUpdate(user), types.M{}, false)
+		} else {
+			err = d.Destroy("_User", types.M{"objectId": e.userID}, types.M{})
+		}
+		if err != nil {
+			e.addError(err)
+			e.report["user"] = "kept"
+			return
+		}
+	}
+	if e.mode == ModeAnonymize {
+		e.report["user"] = "anonymized"
+	} else {
+		e.report["user"] = "deleted"
+	}
+
+	deleted := []string{}
+	for _, name := range e.files {
+		if e.dryRun == false {
+			if err := files.DeleteFile(name); err != nil {
+				e.addError(err)
+				continue
+			}
+		}
+		deleted = append(deleted, name)
+	}
+	e.report["files"] = deleted
+}
+
+// anonymizeUpdate 生成匿名化用户的更新数据：用户名替换为随机值，删除密码、第三方登录信息与其他字段， ACL 保持不变
+func anonymizeUpdate(user types.M) types.M {
+	update := types.M{"username": "anonymous-" + utils.CreateObjectID()}
+	for key, value := range user {
+		switch key {
+		case "objectId", "createdAt", "updatedAt", "username", "ACL":
+			continue
+		case "password":
+			update["_hashed_password"] = types.M{"__op": "Delete"}
+		case "authData":
+			authData := types.M{}
+			for provider := range utils.M(value) {
+				authData[provider] = nil
+			}
+			if len(authData) > 0 {
+				update["authData"] = authData
+			}
+		default:
+			if utils.S(utils.M(value)["__type"]) == "Relation" {
+				continue
+			}
+			update[key] = types.M{"__op": "Delete"}
+		}
+	}
+	return update
+}
+
+// fileNames 返回对象顶层字段中的文件名，按名称排序
+func fileNames(object types.M) []string {
+	names := []string{}
+	for _, value := range object {
+		file := utils.M(value)
+		if utils.S(file["__type"]) == "File" && utils.S(file["name"]) != "" {
+			names = append(names, utils.S(file["name"]))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// referenceActions 解析 ErasureReferenceActions ，返回 类名.字段名 对应的处理方式
+func referenceActions(list []string) map[string]string {
+	actions := map[string]string{}
+	for _, s := range list {
+		className, fieldName, action, ok := config.ParseErasureReferenceAction(s)
+		if ok {
+			actions[className+"."+fieldName] = action
+		}
+	}
+	return actions
+}
+
+// defaultAction 未配置的 Pointer 字段的处理方式，删除用户时删除字段值，匿名化时保留
+func defaultAction(mode string) string {
+	if mode == ModeAnonymize {
+		return actionKeep
+	}
+	return actionUnset
+}
+
+func sortedFieldNames(fields types.M) []string {
+	names := []string{}
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
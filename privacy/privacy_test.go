@@ -0,0 +1,226 @@
+package privacy
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/storage/mongo"
+	"github.com/okobsamoht/talisman/test"
+	"github.com/okobsamoht/talisman/throttle"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_anonymizeUpdate(t *testing.T) {
+	user := types.M{
+		"objectId":  "u1",
+		"createdAt": "2016-02-28T13:25:05.123Z",
+		"updatedAt": "2016-02-28T13:25:05.123Z",
+		"username":  "joe",
+		"password":  "$2a$10$xxx",
+		"email":     "joe@example.com",
+		"ACL":       types.M{"u1": types.M{"read": true, "write": true}},
+		"authData": types.M{
+			"facebook": types.M{"id": "1001"},
+			"twitter":  types.M{"id": "2002"},
+		},
+		"avatar":              types.M{"__type": "File", "name": "a.png"},
+		"friends":             types.M{"__type": "Relation", "className": "_User"},
+		"_perishable_token":   "token",
+		"_password_history":   types.S{"$2a$10$yyy"},
+		"_failed_login_count": 2.0,
+	}
+	result := anonymizeUpdate(user)
+	username, _ := result["username"].(string)
+	if strings.HasPrefix(username, "anonymous-") == false || len(username) <= len("anonymous-") {
+		t.Error("expect:", "anonymous-xxx", "result:", username)
+	}
+	delete(result, "username")
+	deleteOp := types.M{"__op": "Delete"}
+	expect := types.M{
+		"_hashed_password":    deleteOp,
+		"email":               deleteOp,
+		"authData":            types.M{"facebook": nil, "twitter": nil},
+		"avatar":              deleteOp,
+		"_perishable_token":   deleteOp,
+		"_password_history":   deleteOp,
+		"_failed_login_count": deleteOp,
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
+
+func Test_fileNames(t *testing.T) {
+	object := types.M{
+		"objectId": "o1",
+		"photo":    types.M{"__type": "File", "name": "b.png", "url": "http://127.0.0.1/b.png"},
+		"avatar":   types.M{"__type": "File", "name": "a.png"},
+		"author":   types.M{"__type": "Pointer", "className": "_User", "objectId": "u1"},
+		"title":    "hello",
+	}
+	result := fileNames(object)
+	expect := []string{"a.png", "b.png"}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
+
+func Test_referenceActions(t *testing.T) {
+	tests := []struct {
+		name string
+		list []string
+		want map[string]string
+	}{
+		{name: "1", list: nil, want: map[string]string{}},
+		{
+			name: "2",
+			list: []string{"Post.author:unset", "Like.user:delete", "_Role.owner:keep"},
+			want: map[string]string{"Post.author": "unset", "Like.user": "delete", "_Role.owner": "keep"},
+		},
+		{name: "3", list: []string{"Post:unset", ".author:unset", "Post.author"}, want: map[string]string{}},
+	}
+	for _, tt := range tests {
+		if got := referenceActions(tt.list); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. referenceActions() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_defaultAction(t *testing.T) {
+	if defaultAction(ModeErase) != actionUnset {
+		t.Error("expect:", actionUnset, "result:", defaultAction(ModeErase))
+	}
+	if defaultAction(ModeAnonymize) != actionKeep {
+		t.Error("expect:", actionKeep, "result:", defaultAction(ModeAnonymize))
+	}
+}
+
+func Test_exportQuery(t *testing.T) {
+	pointer := types.M{"__type": "Pointer", "className": "_User", "objectId": "u1"}
+	fields := types.M{
+		"title":  types.M{"type": "String"},
+		"owner":  types.M{"type": "Pointer", "targetClass": "_User"},
+		"author": types.M{"type": "Pointer", "targetClass": "_User"},
+		"post":   types.M{"type": "Pointer", "targetClass": "Post"},
+	}
+	tests := []struct {
+		name      string
+		className string
+		fields    types.M
+		want      types.M
+	}{
+		{name: "1", className: "_User", fields: fields, want: types.M{"objectId": "u1"}},
+		{
+			name:      "2",
+			className: "Note",
+			fields:    types.M{"title": types.M{"type": "String"}},
+			want:      types.M{"_rperm": types.M{"$in": types.S{"u1"}}},
+		},
+		{
+			name:      "3",
+			className: "Comment",
+			fields:    fields,
+			want: types.M{"$or": types.S{
+				types.M{"_rperm": types.M{"$in": types.S{"u1"}}},
+				types.M{"author": pointer},
+				types.M{"owner": pointer},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		if got := exportQuery(tt.className, tt.fields, "u1", pointer); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. exportQuery() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_sanitizeExportObject(t *testing.T) {
+	object := types.M{
+		"objectId":          "u1",
+		"username":          "joe",
+		"password":          "$2a$10$xxx",
+		"sessionToken":      "r:xxx",
+		"_perishable_token": "token",
+		"authData": types.M{
+			"twitter":  types.M{"id": "2002", "auth_token": "xxx"},
+			"facebook": types.M{"id": "1001", "access_token": "xxx"},
+		},
+	}
+	result := sanitizeExportObject(object)
+	expect := types.M{
+		"objectId": "u1",
+		"username": "joe",
+		"authData": []string{"facebook", "twitter"},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
+
+func Test_CheckExportLimit(t *testing.T) {
+	limit, window := config.TConfig.DataExportLimit, config.TConfig.DataExportWindow
+	defer func() {
+		config.TConfig.DataExportLimit, config.TConfig.DataExportWindow = limit, window
+		exportStore = throttle.NewMemoryStore()
+	}()
+	exportStore = throttle.NewMemoryStore()
+	config.TConfig.DataExportLimit = 2
+	config.TConfig.DataExportWindow = 60
+	expectErr := errs.E(errs.RequestLimitExceeded, "Too many data export requests, please try again later.")
+	for i, expect := range []error{nil, nil, expectErr} {
+		if err := CheckExportLimit("u1"); reflect.DeepEqual(expect, err) == false {
+			t.Error(i, "expect:", expect, "result:", err)
+		}
+	}
+	if err := CheckExportLimit("u2"); err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	/************************************************************/
+	config.TConfig.DataExportLimit = 0
+	if err := CheckExportLimit("u1"); err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+}
+
+func Test_Export(t *testing.T) {
+	var export *Export
+	var err error
+	var out bytes.Buffer
+	pointer := types.M{"__type": "Pointer", "className": "_User", "objectId": "u1"}
+	/************************************************************/
+	initEnv()
+	orm.TalismanDBController.Create("_User", types.M{"objectId": "u1", "username": "joe"}, types.M{})
+	orm.TalismanDBController.Create("Note", types.M{"objectId": "n1", "owner": pointer, "ACL": types.M{"*": types.M{"read": true}}}, types.M{})
+	orm.TalismanDBController.Create("Note", types.M{"objectId": "n2", "owner": pointer, "ACL": types.M{"u2": types.M{"read": true}}}, types.M{})
+	// Master Key 导出所有指向该用户的对象
+	export, err = NewExport("u1", nil)
+	if err != nil {
+		t.Fatal("expect:", nil, "result:", err)
+	}
+	out.Reset()
+	export.Stream(&out)
+	if strings.Contains(out.String(), `"n1"`) == false || strings.Contains(out.String(), `"n2"`) == false {
+		t.Error("expect:", "n1 and n2", "result:", out.String())
+	}
+	/************************************************************/
+	// 用户自己导出时不包含没有读权限的对象
+	export, err = NewExport("u1", []string{"u1"})
+	if err != nil {
+		t.Fatal("expect:", nil, "result:", err)
+	}
+	out.Reset()
+	export.Stream(&out)
+	if strings.Contains(out.String(), `"n1"`) == false || strings.Contains(out.String(), `"n2"`) {
+		t.Error("expect:", "n1 only", "result:", out.String())
+	}
+	orm.TalismanDBController.DeleteEverything()
+}
+
+func initEnv() {
+	orm.InitOrm(mongo.NewMongoAdapter("talisman", test.OpenMongoDBForTest()))
+}
@@ -0,0 +1,135 @@
+package publichtml
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/files"
+	"github.com/okobsamoht/talisman/logger"
+	"github.com/okobsamoht/talisman/types"
+)
+
+// 设置 TemplatesPath 后，可以使用自定义模板替换内置的页面与邮件内容，模板不存在时使用内置的页面与邮件，
+// 模板使用 Go 模板语法，如 {{.appName}} ， .html 模板使用 html/template 解析，变量会被转义
+
+// 页面模板，可用的变量为 appName appId serverURL username token error resendVerificationURL
+const (
+	InvalidLinkTemplate             = "invalid_link.html"
+	InvalidVerificationLinkTemplate = "invalid_verification_link.html"
+	LinkSendSuccessTemplate         = "link_send_success.html"
+	LinkSendFailTemplate            = "link_send_fail.html"
+	VerifyEmailSuccessTemplate      = "verify_email_success.html"
+	ChoosePasswordTemplate          = "choose_password.html"
+	PasswordResetSuccessTemplate    = "password_reset_success.html"
+)
+
+// 邮件模板，可用的变量为 appName link username email
+const (
+	VerifyEmailSubjectTemplate   = "verify_email_subject.txt"
+	VerifyEmailTextTemplate      = "verify_email.txt"
+	VerifyEmailHTMLTemplate      = "verify_email.html"
+	PasswordResetSubjectTemplate = "password_reset_subject.txt"
+	PasswordResetTextTemplate    = "password_reset.txt"
+	PasswordResetHTMLTemplate    = "password_reset.html"
+)
+
+// 短信模板，可用的变量为 appName code
+const (
+	PhoneCodeTemplate = "phone_code.txt"
+)
+
+// cachedTemplate 缓存的模板，模板不存在时 tmpl 为 nil ，同样缓存以避免重复读取
+type cachedTemplate struct {
+	tmpl     func(data interface{}) (string, error)
+	loadedAt time.Time
+}
+
+var (
+	templateCache = map[string]*cachedTemplate{}
+	templateMutex sync.Mutex
+	// readTemplate 读取模板内容，测试时可以替换
+	readTemplate = readTemplateFromPath
+)
+
+// readTemplateFromPath 从 TemplatesPath 指定的位置读取模板
+func readTemplateFromPath(name string) ([]byte, error) {
+	path := config.TConfig.TemplatesPath
+	if strings.HasPrefix(path, "files:") {
+		return files.GetFileData(strings.TrimPrefix(path, "files:") + name)
+	}
+	return ioutil.ReadFile(filepath.Join(path, name))
+}
+
+// Render 使用自定义模板渲染内容，未设置 TemplatesPath 、模板不存在或者渲染失败时 ok 为 false
+func Render(name string, data types.M) (result string, ok bool) {
+	if config.TConfig.TemplatesPath == "" {
+		return "", false
+	}
+	tmpl := loadTemplate(name)
+	if tmpl == nil {
+		return "", false
+	}
+	result, err := tmpl(data)
+	if err != nil {
+		logger.Error("render template", name, "failed:", err)
+		return "", false
+	}
+	return result, true
+}
+
+// loadTemplate 获取解析之后的模板，缓存时间为 TemplatesCacheTTL
+func loadTemplate(name string) func(data interface{}) (string, error) {
+	templateMutex.Lock()
+	defer templateMutex.Unlock()
+	ttl := time.Duration(config.TConfig.TemplatesCacheTTL) * time.Second
+	if cached, ok := templateCache[name]; ok && ttl > 0 && time.Since(cached.loadedAt) < ttl {
+		return cached.tmpl
+	}
+	var tmpl func(data interface{}) (string, error)
+	if text, err := readTemplate(name); err == nil {
+		tmpl, err = parseTemplate(name, string(text))
+		if err != nil {
+			logger.Error("parse template", name, "failed:", err)
+		}
+	}
+	templateCache[name] = &cachedTemplate{tmpl: tmpl, loadedAt: time.Now()}
+	return tmpl
+}
+
+// parseTemplate .html 模板使用 html/template 解析，其他模板使用 text/template 解析
+func parseTemplate(name, text string) (func(data interface{}) (string, error), error) {
+	if strings.HasSuffix(name, ".html") {
+		t, err := htmltemplate.New(name).Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		return func(data interface{}) (string, error) {
+			buf := &bytes.Buffer{}
+			err := t.Execute(buf, data)
+			return buf.String(), err
+		}, nil
+	}
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return func(data interface{}) (string, error) {
+		buf := &bytes.Buffer{}
+		err := t.Execute(buf, data)
+		return strings.TrimSpace(buf.String()), err
+	}, nil
+}
+
+// ClearTemplateCache 清除模板缓存，修改模板之后立即生效
+func ClearTemplateCache() {
+	templateMutex.Lock()
+	defer templateMutex.Unlock()
+	templateCache = map[string]*cachedTemplate{}
+}
@@ -0,0 +1,62 @@
+package publichtml
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_Render(t *testing.T) {
+	dir, err := ioutil.TempDir("", "talisman")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, InvalidLinkTemplate), []byte(`<h1>{{.appName}}</h1><p>{{.username}}</p>`), 0666)
+	ioutil.WriteFile(filepath.Join(dir, VerifyEmailSubjectTemplate), []byte("  Verify {{.email}} for {{.appName}}\n"), 0666)
+	ioutil.WriteFile(filepath.Join(dir, LinkSendFailTemplate), []byte(`{{.appName`), 0666)
+	data := types.M{"appName": "talisman", "username": "<joe>", "email": "joe@g.com"}
+	/*************************************************/
+	config.TConfig = &config.Config{}
+	ClearTemplateCache()
+	if _, ok := Render(InvalidLinkTemplate, data); ok {
+		t.Error("expect:", false, "result:", ok)
+	}
+	/*************************************************/
+	config.TConfig = &config.Config{TemplatesPath: dir, TemplatesCacheTTL: 60}
+	result, ok := Render(InvalidLinkTemplate, data)
+	expect := `<h1>talisman</h1><p>&lt;joe&gt;</p>`
+	if ok == false || result != expect {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*************************************************/
+	result, ok = Render(VerifyEmailSubjectTemplate, data)
+	expect = "Verify joe@g.com for talisman"
+	if ok == false || result != expect {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*************************************************/
+	if _, ok = Render(LinkSendSuccessTemplate, data); ok {
+		t.Error("expect:", false, "result:", ok)
+	}
+	if _, ok = Render(LinkSendFailTemplate, data); ok {
+		t.Error("expect:", false, "result:", ok)
+	}
+	/*************************************************/
+	ioutil.WriteFile(filepath.Join(dir, InvalidLinkTemplate), []byte(`<h2>{{.appName}}</h2>`), 0666)
+	result, _ = Render(InvalidLinkTemplate, data)
+	expect = `<h1>talisman</h1><p>&lt;joe&gt;</p>`
+	if result != expect {
+		t.Error("expect:", expect, "result:", result)
+	}
+	ClearTemplateCache()
+	result, _ = Render(InvalidLinkTemplate, data)
+	expect = `<h2>talisman</h2>`
+	if result != expect {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
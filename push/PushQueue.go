@@ -81,6 +81,9 @@ func (q *pushQueue) enqueue(body, where types.M, auth *rest.Auth, status *pushSt
 			"query":      query,
 			"pushStatus": types.M{"objectId": status.objectID},
 		}
+		if auth != nil && auth.RequestID != "" {
+			pushWorkItem["requestId"] = auth.RequestID
+		}
 		b, err := json.Marshal(pushWorkItem)
 		if err != nil {
 			return err
@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/okobsamoht/talisman/livequery/pubsub"
+	"github.com/okobsamoht/talisman/logger"
 	"github.com/okobsamoht/talisman/rest"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
@@ -64,7 +65,10 @@ func newPushWorker(adapter pushAdapter, channel string) *pushWorker {
 		if err != nil {
 			return
 		}
-		worker.run(workItem)
+		err = worker.run(workItem)
+		if err != nil {
+			logger.Request(utils.S(workItem["requestId"])).Error("Push failed:", err)
+		}
 	})
 
 	return worker
@@ -80,6 +84,7 @@ func (p *pushWorker) run(workItem types.M) error {
 	status := utils.M(workItem["pushStatus"])
 
 	auth := rest.Master()
+	auth.RequestID = utils.S(workItem["requestId"])
 	where := utils.M(query["where"])
 	delete(query, "where")
 
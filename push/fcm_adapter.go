@@ -1,6 +1,8 @@
 package push
 
 import (
+	"sync"
+
 	"github.com/okobsamoht/talisman/config"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
@@ -14,6 +16,7 @@ import (
 
 type fcmPushAdapter struct {
 	validPushTypes []string
+	mu             sync.RWMutex
 	serverKey      string
 }
 
@@ -98,12 +101,25 @@ func (f *fcmPushAdapter) send(body types.M, installations types.S, pushStatus st
 	return results
 }
 
+// setServerKey 更新 FCM Server Key ，用于重新加载配置，之后的推送使用新的 Key
+func (f *fcmPushAdapter) setServerKey(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.serverKey = key
+}
+
+func (f *fcmPushAdapter) client() *fcm.FcmClient {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return fcm.NewFcmClient(f.serverKey)
+}
+
 func (f *fcmPushAdapter) getValidPushTypes() []string {
 	return f.validPushTypes
 }
 
 func (f *fcmPushAdapter) sendToiOSDevices(tokens []string, body types.M) (*fcm.FcmResponseStatus, error) {
-	c := fcm.NewFcmClient(f.serverKey)
+	c := f.client()
 	c.SetPriority(fcm.Priority_HIGH)
 
 	if t, ok := body["expiration_time"].(int64); ok {
@@ -146,7 +162,7 @@ func (f *fcmPushAdapter) sendToiOSDevices(tokens []string, body types.M) (*fcm.F
 }
 
 func (f *fcmPushAdapter) sendToAndroidDevices(tokens []string, body types.M) (*fcm.FcmResponseStatus, error) {
-	c := fcm.NewFcmClient(f.serverKey)
+	c := f.client()
 	c.SetPriority(fcm.Priority_HIGH)
 
 	if t, ok := body["expiration_time"].(int64); ok {
@@ -21,6 +21,19 @@ var worker *pushWorker
 // 当前仅有模拟的推送模块，
 // 后续添加 APNS、GCM、以及其他第三方推送模块
 func init() {
+	Init()
+	config.OnReload(func(c *config.Config) {
+		if f, ok := adapter.(*fcmPushAdapter); ok {
+			f.setServerKey(c.FCMServerKey)
+		}
+	})
+}
+
+// Init 根据当前配置创建推送模块，已有的推送任务处理器会取消订阅
+func Init() {
+	if worker != nil {
+		worker.unsubscribe()
+	}
 	a := config.TConfig.PushAdapter
 	if a == "talisman" {
 		adapter = newTalismanPush()
@@ -42,6 +55,45 @@ func SendPush(body types.M, where types.M, auth *rest.Auth, onPushStatusSaved fu
 
 	// validatePushType(where, adapter.getValidPushTypes())
 
+	err := normalizeTimes(body)
+	if err != nil {
+		return err
+	}
+
+	badgeUpdate, err := newBadgeUpdate(body, where)
+	if err != nil {
+		return err
+	}
+
+	status := newPushStatus("")
+
+	err = status.setInitial(body, where, nil)
+	if err != nil {
+		return err
+	}
+
+	onPushStatusSaved(status.objectID)
+	err = badgeUpdate()
+	if err != nil {
+		status.fail(err)
+		return err
+	}
+
+	if _, ok := body["push_time"]; ok && config.TConfig.ScheduledPush {
+
+	} else {
+		err = queue.enqueue(body, where, auth, status)
+	}
+
+	if err != nil {
+		status.fail(err)
+	}
+
+	return err
+}
+
+// normalizeTimes 转换推送消息中的过期时间与推送时间
+func normalizeTimes(body types.M) error {
 	if body["expiration_time"] != nil {
 		var err error
 		body["expiration_time"], err = getExpirationTime(body)
@@ -59,7 +111,11 @@ func SendPush(body types.M, where types.M, auth *rest.Auth, onPushStatusSaved fu
 			body["push_time"] = pushTime
 		}
 	}
+	return nil
+}
 
+// newBadgeUpdate 根据推送消息中的 badge 生成更新 iOS 设备角标的函数
+func newBadgeUpdate(body, where types.M) (func() error, error) {
 	badgeUpdate := func() error { return nil }
 
 	data := utils.M(body["data"])
@@ -77,7 +133,7 @@ func SendPush(body types.M, where types.M, auth *rest.Auth, onPushStatusSaved fu
 		} else if v, ok := badge.(int); ok {
 			restUpdate["badge"] = v
 		} else {
-			return errors.New("Invalid value for badge, expected number or 'Increment'")
+			return nil, errors.New("Invalid value for badge, expected number or 'Increment'")
 		}
 		updateWhere := utils.CopyMapM(where)
 
@@ -100,32 +156,7 @@ func SendPush(body types.M, where types.M, auth *rest.Auth, onPushStatusSaved fu
 			return err
 		}
 	}
-
-	status := newPushStatus("")
-
-	err := status.setInitial(body, where, nil)
-	if err != nil {
-		return err
-	}
-
-	onPushStatusSaved(status.objectID)
-	err = badgeUpdate()
-	if err != nil {
-		status.fail(err)
-		return err
-	}
-
-	if _, ok := body["push_time"]; ok && config.TConfig.ScheduledPush {
-
-	} else {
-		err = queue.enqueue(body, where, auth, status)
-	}
-
-	if err != nil {
-		status.fail(err)
-	}
-
-	return err
+	return badgeUpdate, nil
 }
 
 // getExpirationTime 把过期时间转换为以毫秒为单位的 Unix 时间
@@ -0,0 +1,173 @@
+package push
+
+import (
+	"errors"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/rest"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// Target 推送目标
+// Channels 为频道列表， Where 为 _Installation 的查询条件，同时指定时取交集
+type Target struct {
+	Channels []string
+	Where    types.M
+}
+
+// where 把推送目标转换为 _Installation 的查询条件
+func (t Target) where() (types.M, error) {
+	if len(t.Channels) == 0 && len(t.Where) == 0 {
+		return nil, errs.E(errs.PushMisconfigured, `Sending a push requires either "channels" or a "where" query.`)
+	}
+	where := utils.CopyMapM(t.Where)
+	if len(t.Channels) > 0 {
+		channels := types.S{}
+		for _, c := range t.Channels {
+			channels = append(channels, c)
+		}
+		channelsWhere := types.M{"channels": types.M{"$in": channels}}
+		if _, ok := where["channels"]; ok {
+			where = types.M{"$and": types.S{where, channelsWhere}}
+		} else {
+			where["channels"] = channelsWhere["channels"]
+		}
+	}
+	if _, ok := where["deviceToken"]; !ok {
+		where["deviceToken"] = types.M{"$exists": true}
+	}
+	return where, nil
+}
+
+// SendToTarget 向频道或者查询条件匹配的设备发送推送
+// 设备分批从数据库中按 objectId 顺序读取，同一个 deviceToken 只推送一次，
+// 去重后的查询条件与设备数量记录在 _PushStatus 中，返回推送状态的 objectId
+func SendToTarget(target Target, body types.M) (string, error) {
+	if adapter == nil {
+		return "", errs.E(errs.PushMisconfigured, "Missing push configuration")
+	}
+
+	where, err := target.where()
+	if err != nil {
+		return "", err
+	}
+
+	err = normalizeTimes(body)
+	if err != nil {
+		return "", err
+	}
+
+	badgeUpdate, err := newBadgeUpdate(body, where)
+	if err != nil {
+		return "", err
+	}
+
+	status := newPushStatus("")
+	err = status.setInitial(body, where, nil)
+	if err != nil {
+		return "", err
+	}
+
+	err = badgeUpdate()
+	if err != nil {
+		status.fail(err)
+		return status.objectID, err
+	}
+
+	// 角标更新之后再读取设备，保证推送内容中的角标与数据库一致
+	installations := types.S{}
+	seen := map[string]bool{}
+	err = eachInstallation(where, queue.batchSize, func(results types.S) error {
+		installations = append(installations, dedupDeviceTokens(results, seen)...)
+		return nil
+	})
+	if err == nil && len(installations) == 0 {
+		err = errors.New("PushController: no results in query")
+	}
+	if err != nil {
+		status.fail(err)
+		return status.objectID, err
+	}
+
+	status.setRunning(len(installations))
+
+	pushStatus := types.M{"objectId": status.objectID}
+	for i := 0; i < len(installations); i += queue.batchSize {
+		end := i + queue.batchSize
+		if end > len(installations) {
+			end = len(installations)
+		}
+		err = worker.sendToAdapter(body, installations[i:end], pushStatus)
+		if err != nil {
+			status.fail(err)
+			return status.objectID, err
+		}
+	}
+
+	return status.objectID, nil
+}
+
+// eachInstallation 按 objectId 顺序分批读取符合条件的设备，避免使用 skip 在数据量大时变慢
+func eachInstallation(where types.M, batchSize int, fn func(types.S) error) error {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	lastID := ""
+	for {
+		query := where
+		if lastID != "" {
+			query = types.M{
+				"$and": types.S{
+					where,
+					types.M{"objectId": types.M{"$gt": lastID}},
+				},
+			}
+		}
+		options := types.M{
+			"limit": batchSize,
+			"order": "objectId",
+		}
+		response, err := rest.Find(rest.Master(), "_Installation", query, options, nil)
+		if err != nil {
+			return err
+		}
+		if utils.HasResults(response) == false {
+			return nil
+		}
+		results := utils.A(response["results"])
+		err = fn(results)
+		if err != nil {
+			return err
+		}
+		if len(results) < batchSize {
+			return nil
+		}
+		lastID = utils.S(utils.M(results[len(results)-1])["objectId"])
+		if lastID == "" {
+			return nil
+		}
+	}
+}
+
+// dedupDeviceTokens 过滤掉已经出现过的 deviceToken ， seen 在多个批次之间共享
+func dedupDeviceTokens(installations types.S, seen map[string]bool) types.S {
+	results := types.S{}
+	for _, v := range installations {
+		installation := utils.M(v)
+		if installation == nil {
+			continue
+		}
+		token := utils.S(installation["deviceToken"])
+		if token == "" {
+			continue
+		}
+		key := utils.S(installation["deviceType"]) + ":" + token
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		results = append(results, installation)
+	}
+	return results
+}
@@ -0,0 +1,241 @@
+// Package quota 统计应用与各个类的请求次数与传输字节数，并按天、按月限制应用的请求
+// 统计周期以 UTC 时间计算，当天与当月的计数在周期结束后保留一段时间，之后自动清除
+package quota
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/context"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+// Default 应用使用的统计模块，未开启 EnableQuota 时为 nil
+var Default *Accountant
+
+func init() {
+	Init()
+	config.OnReload(func(c *config.Config) {
+		if Default != nil {
+			Default.SetLimits(limitsFromConfig(c))
+		}
+	})
+}
+
+// Init 根据当前配置创建 Default
+func Init() {
+	Default = nil
+	if config.TConfig.EnableQuota == false {
+		return
+	}
+	var store Store
+	if config.TConfig.QuotaStore == "Redis" {
+		store = NewRedisStore(config.TConfig.RedisAddress, config.TConfig.RedisPassword)
+	} else {
+		store = NewMemoryStore()
+	}
+	Default = NewAccountant(store, limitsFromConfig(config.TConfig))
+}
+
+func limitsFromConfig(c *config.Config) Limits {
+	return Limits{
+		DailyRequests:   int64(c.DailyRequestQuota),
+		MonthlyRequests: int64(c.MonthlyRequestQuota),
+		DailyBytes:      int64(c.DailyBytesQuota),
+		MonthlyBytes:    int64(c.MonthlyBytesQuota),
+	}
+}
+
+// Limits 应用的请求配额， 0 表示不限制
+type Limits struct {
+	DailyRequests   int64
+	MonthlyRequests int64
+	DailyBytes      int64
+	MonthlyBytes    int64
+}
+
+// appScope 应用统计的范围，类的统计范围为 class:类名
+const appScope = "app"
+
+const (
+	dailyTTL   = 48 * time.Hour
+	monthlyTTL = 62 * 24 * time.Hour
+)
+
+// Accountant 统计请求并检查配额
+type Accountant struct {
+	mu     sync.RWMutex
+	store  Store
+	limits Limits
+	now    func() time.Time
+}
+
+// NewAccountant ...
+func NewAccountant(store Store, limits Limits) *Accountant {
+	return &Accountant{
+		store:  store,
+		limits: limits,
+		now:    time.Now,
+	}
+}
+
+// Record 记录一次请求及其传输的字节数，同时计入应用与 className 对应的类， className 为空时只计入应用
+func (a *Accountant) Record(className string, bytes int64) {
+	day, month := a.periods()
+	scopes := []string{appScope}
+	if className != "" {
+		scopes = append(scopes, classScope(className))
+	}
+	for _, scope := range scopes {
+		a.store.IncrBy(key(scope, day, "requests"), 1, dailyTTL)
+		a.store.IncrBy(key(scope, month, "requests"), 1, monthlyTTL)
+		if bytes > 0 {
+			a.store.IncrBy(key(scope, day, "bytes"), bytes, dailyTTL)
+			a.store.IncrBy(key(scope, month, "bytes"), bytes, monthlyTTL)
+		}
+	}
+}
+
+// SetLimits 修改配额，已有的统计保持不变，用于重新加载配置
+func (a *Accountant) SetLimits(limits Limits) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.limits = limits
+}
+
+// Check 检查应用当天与当月的请求是否已超过配额，超过时返回配置中的错误
+// 存储不可用时不做限制
+func (a *Accountant) Check() error {
+	day, month := a.periods()
+	scope := appScope
+	a.mu.RLock()
+	limits := a.limits
+	a.mu.RUnlock()
+	checks := []struct {
+		key   string
+		limit int64
+	}{
+		{key(scope, day, "requests"), limits.DailyRequests},
+		{key(scope, month, "requests"), limits.MonthlyRequests},
+		{key(scope, day, "bytes"), limits.DailyBytes},
+		{key(scope, month, "bytes"), limits.MonthlyBytes},
+	}
+	for _, c := range checks {
+		if c.limit <= 0 {
+			continue
+		}
+		value, err := a.store.Get(c.key)
+		if err == nil && value >= c.limit {
+			return errs.E(config.TConfig.QuotaErrorCode, config.TConfig.QuotaErrorMessage)
+		}
+	}
+	return nil
+}
+
+// Stats 返回当天与当月的统计信息， className 为空时返回应用的统计信息，格式如下
+// {"daily":{"period":"2017-06-01","requests":100,"bytes":102400},"monthly":{"period":"2017-06","requests":1000,"bytes":1024000}}
+func (a *Accountant) Stats(className string) (types.M, error) {
+	day, month := a.periods()
+	scope := appScope
+	if className != "" {
+		scope = classScope(className)
+	}
+	result := types.M{}
+	for name, period := range map[string]string{"daily": day, "monthly": month} {
+		requests, err := a.store.Get(key(scope, period, "requests"))
+		if err != nil {
+			return nil, err
+		}
+		bytes, err := a.store.Get(key(scope, period, "bytes"))
+		if err != nil {
+			return nil, err
+		}
+		result[name] = types.M{
+			"period":   period,
+			"requests": requests,
+			"bytes":    bytes,
+		}
+	}
+	return result, nil
+}
+
+// Filters 返回检查配额与统计请求的过滤器，如：
+// beego.InsertFilter("/v1/*", beego.BeforeRouter, before)
+// beego.InsertFilter("/v1/*", beego.FinishRouter, after, false)
+// 使用 Master Key 的请求不受配额限制，但仍然计入统计
+func (a *Accountant) Filters() (before, after func(ctx *context.Context)) {
+	before = func(ctx *context.Context) {
+		writer := &countingWriter{ResponseWriter: ctx.ResponseWriter.ResponseWriter}
+		ctx.ResponseWriter.ResponseWriter = writer
+		if key, ok := config.MatchAppKey(config.KeyTypeMaster, ctx.Input.Header("X-Parse-Master-Key")); ok &&
+			key.Expired(time.Now()) == false && config.MasterKeyIPAllowed(config.ClientIP(ctx.Request)) {
+			return
+		}
+		if err := a.Check(); err != nil {
+			ctx.Input.SetData("quotaExceeded", true)
+			ctx.Output.SetStatus(429)
+			ctx.Output.JSON(errs.ErrorToMap(err), false, false)
+		}
+	}
+	after = func(ctx *context.Context) {
+		if exceeded, ok := ctx.Input.GetData("quotaExceeded").(bool); ok && exceeded {
+			return
+		}
+		bytes := int64(len(ctx.Input.RequestBody))
+		if writer, ok := ctx.ResponseWriter.ResponseWriter.(*countingWriter); ok {
+			bytes += writer.written
+		}
+		a.Record(requestClassName(ctx), bytes)
+	}
+	return before, after
+}
+
+// countingWriter 统计返回数据的字节数
+type countingWriter struct {
+	http.ResponseWriter
+	written int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// classPaths 操作系统类的接口
+var classPaths = map[string]string{
+	"users":         "_User",
+	"login":         "_User",
+	"sessions":      "_Session",
+	"roles":         "_Role",
+	"installations": "_Installation",
+}
+
+// requestClassName 获取请求操作的类，不是操作类的请求返回空
+func requestClassName(ctx *context.Context) string {
+	if className := ctx.Input.Param(":className"); className != "" {
+		return className
+	}
+	parts := strings.Split(strings.Trim(ctx.Input.URL(), "/"), "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return classPaths[parts[1]]
+}
+
+func (a *Accountant) periods() (string, string) {
+	now := a.now().UTC()
+	return now.Format("2006-01-02"), now.Format("2006-01")
+}
+
+func classScope(className string) string {
+	return "class:" + className
+}
+
+func key(scope, period, metric string) string {
+	return strings.Join([]string{config.TConfig.AppID, "quota", scope, period, metric}, ":")
+}
@@ -0,0 +1,124 @@
+package quota
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/context"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_Accountant(t *testing.T) {
+	var a *Accountant
+	var stats types.M
+	var err error
+	var expect types.M
+	var expectErr error
+	config.TConfig.QuotaErrorCode = errs.RequestLimitExceeded
+	config.TConfig.QuotaErrorMessage = "Request quota exceeded."
+	now := func() time.Time { return time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC) }
+	/*****************************************************/
+	a = NewAccountant(NewMemoryStore(), Limits{})
+	a.now = now
+	a.Record("post", 100)
+	a.Record("post", 50)
+	a.Record("", 10)
+	stats, err = a.Stats("")
+	expect = types.M{
+		"daily":   types.M{"period": "2017-06-01", "requests": int64(3), "bytes": int64(160)},
+		"monthly": types.M{"period": "2017-06", "requests": int64(3), "bytes": int64(160)},
+	}
+	if err != nil || reflect.DeepEqual(expect, stats) == false {
+		t.Error("expect:", expect, "result:", stats, err)
+	}
+	stats, err = a.Stats("post")
+	expect = types.M{
+		"daily":   types.M{"period": "2017-06-01", "requests": int64(2), "bytes": int64(150)},
+		"monthly": types.M{"period": "2017-06", "requests": int64(2), "bytes": int64(150)},
+	}
+	if err != nil || reflect.DeepEqual(expect, stats) == false {
+		t.Error("expect:", expect, "result:", stats, err)
+	}
+	/*****************************************************/
+	a = NewAccountant(NewMemoryStore(), Limits{DailyRequests: 2})
+	a.now = now
+	a.Record("post", 0)
+	err = a.Check()
+	expectErr = nil
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	a.Record("post", 0)
+	err = a.Check()
+	expectErr = errs.E(errs.RequestLimitExceeded, "Request quota exceeded.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/*****************************************************/
+	a = NewAccountant(NewMemoryStore(), Limits{MonthlyBytes: 100})
+	a.now = now
+	a.Record("post", 60)
+	a.now = func() time.Time { return time.Date(2017, 6, 2, 12, 0, 0, 0, time.UTC) }
+	a.Record("post", 60)
+	err = a.Check()
+	expectErr = errs.E(errs.RequestLimitExceeded, "Request quota exceeded.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	a.now = func() time.Time { return time.Date(2017, 7, 1, 12, 0, 0, 0, time.UTC) }
+	err = a.Check()
+	expectErr = nil
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/*****************************************************/
+	a = NewAccountant(NewMemoryStore(), Limits{DailyRequests: 1})
+	a.now = now
+	a.Record("post", 0)
+	a.SetLimits(Limits{DailyRequests: 2})
+	err = a.Check()
+	expectErr = nil
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+}
+
+func Test_Accountant_Filters(t *testing.T) {
+	masterKey, masterKeyIPs := config.TConfig.MasterKey, config.TConfig.MasterKeyIPs
+	defer func() {
+		config.TConfig.MasterKey, config.TConfig.MasterKeyIPs = masterKey, masterKeyIPs
+	}()
+	config.TConfig.MasterKey = "master"
+	config.TConfig.MasterKeyIPs = []string{"127.0.0.1"}
+	config.TConfig.QuotaErrorCode = errs.RequestLimitExceeded
+	config.TConfig.QuotaErrorMessage = "Request quota exceeded."
+	a := NewAccountant(NewMemoryStore(), Limits{DailyRequests: 1})
+	a.Record("post", 0)
+	before, _ := a.Filters()
+
+	request := func(remoteAddr, forwardedFor string) int {
+		r := httptest.NewRequest("GET", "/v1/classes/post", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("X-Parse-Master-Key", "master")
+		if forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		ctx := context.NewContext()
+		ctx.Reset(w, r)
+		before(ctx)
+		return w.Code
+	}
+	/*****************************************************/
+	if code := request("127.0.0.1:52100", ""); code != 200 {
+		t.Error("expect:", 200, "result:", code)
+	}
+	/*****************************************************/
+	if code := request("203.0.113.5:52100", "127.0.0.1"); code != 429 {
+		t.Error("expect:", 429, "result:", code)
+	}
+}
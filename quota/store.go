@@ -0,0 +1,123 @@
+package quota
+
+import (
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Store 保存计数，计数在第一次增加后的 ttl 时间内有效
+type Store interface {
+	IncrBy(key string, n int64, ttl time.Duration) (int64, error)
+	Get(key string) (int64, error)
+}
+
+// MemoryStore 保存在内存中的计数，只适用于单实例部署，重启后清零
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]*counter
+	lastPrune time.Time
+}
+
+type counter struct {
+	value  int64
+	expire time.Time
+}
+
+// NewMemoryStore ...
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters:  map[string]*counter{},
+		lastPrune: time.Now(),
+	}
+}
+
+// IncrBy ...
+func (m *MemoryStore) IncrBy(key string, n int64, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	// 每小时清理一次过期的计数
+	if now.Sub(m.lastPrune) > time.Hour {
+		for k, c := range m.counters {
+			if c.expire.Before(now) {
+				delete(m.counters, k)
+			}
+		}
+		m.lastPrune = now
+	}
+
+	c, ok := m.counters[key]
+	if ok == false || c.expire.Before(now) {
+		c = &counter{expire: now.Add(ttl)}
+		m.counters[key] = c
+	}
+	c.value += n
+	return c.value, nil
+}
+
+// Get ...
+func (m *MemoryStore) Get(key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.counters[key]
+	if ok == false || c.expire.Before(time.Now()) {
+		return 0, nil
+	}
+	return c.value, nil
+}
+
+// RedisStore 保存在 Redis 中的计数，多实例部署时共享
+type RedisStore struct {
+	p *redis.Pool
+}
+
+// NewRedisStore ...
+func NewRedisStore(address, password string) *RedisStore {
+	dialFunc := func() (c redis.Conn, err error) {
+		c, err = redis.Dial("tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		if password != "" {
+			if _, err := c.Do("AUTH", password); err != nil {
+				c.Close()
+				return nil, err
+			}
+		}
+		return
+	}
+	return &RedisStore{
+		p: &redis.Pool{
+			MaxIdle:     3,
+			IdleTimeout: 180 * time.Second,
+			Dial:        dialFunc,
+		},
+	}
+}
+
+// IncrBy 第一次增加时设置过期时间
+func (r *RedisStore) IncrBy(key string, n int64, ttl time.Duration) (int64, error) {
+	c := r.p.Get()
+	defer c.Close()
+	value, err := redis.Int64(c.Do("INCRBY", key, n))
+	if err != nil {
+		return 0, err
+	}
+	if value == n {
+		_, err = c.Do("EXPIRE", key, int64(ttl/time.Second))
+	}
+	return value, err
+}
+
+// Get ...
+func (r *RedisStore) Get(key string) (int64, error) {
+	c := r.p.Get()
+	defer c.Close()
+	value, err := redis.Int64(c.Do("GET", key))
+	if err == redis.ErrNil {
+		return 0, nil
+	}
+	return value, err
+}
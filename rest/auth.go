@@ -3,8 +3,9 @@ package rest
 import (
 	"time"
 
-	"github.com/okobsamoht/talisman/cache"
+	"github.com/okobsamoht/talisman/config"
 	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/jwt"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
 )
@@ -17,6 +18,7 @@ type Auth struct {
 	UserRoles      []string
 	FetchedRoles   bool
 	RolePromise    []string
+	RequestID      string // 当前请求的 ID ，用于关联日志、云代码与推送任务
 }
 
 // Master 生成 Master 级别用户
@@ -31,9 +33,15 @@ func Nobody() *Auth {
 
 // GetAuthForSessionToken 返回 sessionToken 对应的用户权限信息
 func GetAuthForSessionToken(sessionToken string, installationID string) (*Auth, error) {
+	// JWT 先校验签名与过期时间，无效的 token 不需要查询数据库
+	if config.TConfig.SessionTokenType == "JWT" && jwt.IsToken(sessionToken) {
+		auth, err := authForJWT(sessionToken, installationID)
+		if err != nil || auth != nil {
+			return auth, err
+		}
+	}
 	// 从缓存获取用户信息
-	cachedUser := cache.User.Get(sessionToken)
-	if u := utils.M(cachedUser); u != nil {
+	if u := tUserCache.getUser(sessionToken); u != nil {
 		return &Auth{
 			IsMaster:       false,
 			InstallationID: installationID,
@@ -90,7 +98,7 @@ func GetAuthForSessionToken(sessionToken string, installationID string) (*Auth,
 	user["className"] = "_User"
 	user["sessionToken"] = sessionToken
 	// 写入缓存
-	cache.User.Put(sessionToken, user, 0)
+	tUserCache.putUser(sessionToken, user, expiresAt)
 
 	return &Auth{
 		IsMaster:       false,
@@ -159,11 +167,10 @@ func (a *Auth) GetUserRoles() []string {
 
 // loadRoles 从数据库加载用户角色列表
 func (a *Auth) loadRoles() []string {
-	cachedRoles := cache.Role.Get(utils.S(a.User["objectId"]))
-	if cachedRoles != nil {
+	if cachedRoles, ok := tUserCache.getRoles(utils.S(a.User["objectId"])); ok {
 		a.FetchedRoles = true
-		a.UserRoles = cachedRoles.([]string)
-		return cachedRoles.([]string)
+		a.UserRoles = cachedRoles
+		return cachedRoles
 	}
 
 	users := types.M{
@@ -181,7 +188,7 @@ func (a *Auth) loadRoles() []string {
 		a.UserRoles = []string{}
 		a.FetchedRoles = true
 		a.RolePromise = nil
-		cache.Role.Put(utils.S(a.User["objectId"]), a.UserRoles, 0)
+		tUserCache.putRoles(utils.S(a.User["objectId"]), a.UserRoles)
 		return a.UserRoles
 	}
 
@@ -190,7 +197,7 @@ func (a *Auth) loadRoles() []string {
 		a.UserRoles = []string{}
 		a.FetchedRoles = true
 		a.RolePromise = nil
-		cache.Role.Put(utils.S(a.User["objectId"]), a.UserRoles, 0)
+		tUserCache.putRoles(utils.S(a.User["objectId"]), a.UserRoles)
 		return a.UserRoles
 	}
 
@@ -216,7 +223,7 @@ func (a *Auth) loadRoles() []string {
 	a.FetchedRoles = true
 	a.RolePromise = nil
 
-	cache.Role.Put(utils.S(a.User["objectId"]), a.UserRoles, 0)
+	tUserCache.putRoles(utils.S(a.User["objectId"]), a.UserRoles)
 	return a.UserRoles
 }
 
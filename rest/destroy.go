@@ -1,7 +1,6 @@
 package rest
 
 import (
-	"github.com/okobsamoht/talisman/cache"
 	"github.com/okobsamoht/talisman/cloud"
 	"github.com/okobsamoht/talisman/livequery"
 	"github.com/okobsamoht/talisman/orm"
@@ -58,14 +57,16 @@ func (d *Destroy) Execute() error {
 	return nil
 }
 
-// handleSession 处理 _Session 表的删除操作
+// handleSession 处理 _Session 表的删除操作，删除 _User 时清除该用户的全部缓存
 func (d *Destroy) handleSession() error {
-	if d.className != "_Session" {
+	if d.className == "_User" {
+		tUserCache.delUser(utils.S(d.query["objectId"]))
 		return nil
 	}
-	if sessionToken := utils.S(d.originalData["sessionToken"]); sessionToken != "" {
-		cache.User.Del(sessionToken)
+	if d.className != "_Session" {
+		return nil
 	}
+	tUserCache.delSession(utils.S(d.originalData["sessionToken"]))
 
 	return nil
 }
@@ -76,7 +77,7 @@ func (d *Destroy) runBeforeTrigger() error {
 		return nil
 	}
 	if livequery.TLiveQuery != nil {
-		livequery.TLiveQuery.OnAfterDelete(d.className, d.originalData, nil)
+		livequery.TLiveQuery.OnAfterDelete(d.className, sanitizeEvent(d.className, d.originalData), nil)
 	}
 
 	d.originalData["className"] = d.className
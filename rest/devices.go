@@ -0,0 +1,233 @@
+package rest
+
+import (
+	"sort"
+	"time"
+
+	"github.com/okobsamoht/talisman/cache"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/logger"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/throttle"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// sessionActivityPrefix 记录最近一次写入最后使用时间的 sessionToken ，间隔内不重复写入
+const sessionActivityPrefix = "activity:"
+
+// installationDeviceKeys 设备列表中返回的 _Installation 字段
+var installationDeviceKeys = []string{"deviceType", "appName", "appVersion", "appIdentifier", "localeIdentifier", "timeZone"}
+
+// TouchSession 记录 session 的最后使用时间、IP 与设备名称，每个 session 在 SessionActivityInterval 内最多写入一次
+// deviceName 取自请求头 X-Parse-Device-Name ，未设置时为 User-Agent
+func TouchSession(sessionToken, ip, deviceName string) {
+	interval := config.TConfig.SessionActivityInterval
+	if sessionToken == "" || interval <= 0 {
+		return
+	}
+	if cache.User.Get(sessionActivityPrefix+sessionToken) != nil {
+		return
+	}
+	cache.User.Put(sessionActivityPrefix+sessionToken, true, int64(interval))
+
+	update := types.M{
+		"lastUsedAt": utils.DateJSON(time.Now().UTC()),
+		"ipAddress":  ip,
+	}
+	if deviceName != "" {
+		update["deviceName"] = deviceName
+	}
+	_, err := orm.TalismanDBController.Update("_Session", types.M{"sessionToken": sessionToken}, update, types.M{}, true)
+	if err != nil && errs.GetErrorCode(err) != errs.ObjectNotFound {
+		logger.Error("update session activity failed:", err)
+	}
+}
+
+// ListDevices 返回用户未过期的 session ，包括设备名称、最后使用时间、 IP 以及对应的 _Installation 信息
+// 结果中不包含 sessionToken ，当前请求使用的 session 中 current 为 true ，按最后使用时间倒序排列
+func ListDevices(userID, currentToken string) (types.S, error) {
+	sessions, err := orm.TalismanDBController.Find("_Session", userSessionsQuery(userID), types.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	installationIDs := types.S{}
+	for _, s := range sessions {
+		if id := utils.S(utils.M(s)["installationId"]); id != "" {
+			installationIDs = append(installationIDs, id)
+		}
+	}
+	installations := map[string]types.M{}
+	if len(installationIDs) > 0 {
+		results, err := orm.TalismanDBController.Find("_Installation", types.M{"installationId": types.M{"$in": installationIDs}}, types.M{})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			installation := utils.M(r)
+			installations[utils.S(installation["installationId"])] = installation
+		}
+	}
+
+	now := time.Now()
+	devices := types.S{}
+	for _, s := range sessions {
+		session := utils.M(s)
+		if expiresAt, err := utils.DateToTime(session["expiresAt"]); err == nil && expiresAt.Before(now) {
+			continue
+		}
+		device := types.M{
+			"objectId": session["objectId"],
+			"current":  currentToken != "" && session["sessionToken"] == currentToken,
+		}
+		for _, k := range []string{"deviceName", "ipAddress", "lastUsedAt", "expiresAt", "createdAt", "createdWith", "installationId", "impersonated"} {
+			if v, ok := session[k]; ok {
+				device[k] = v
+			}
+		}
+		if installation, ok := installations[utils.S(session["installationId"])]; ok {
+			info := types.M{}
+			for _, k := range installationDeviceKeys {
+				if v, ok := installation[k]; ok {
+					info[k] = v
+				}
+			}
+			device["installation"] = info
+		}
+		devices = append(devices, device)
+	}
+
+	sort.SliceStable(devices, func(i, j int) bool {
+		return lastActivity(utils.M(devices[i])).After(lastActivity(utils.M(devices[j])))
+	})
+	return devices, nil
+}
+
+// RevokeDevice 注销用户的指定 session ，不属于该用户的 session 视为不存在
+func RevokeDevice(userID, objectID string) error {
+	where := userSessionsQuery(userID)
+	where["objectId"] = objectID
+	sessions, err := orm.TalismanDBController.Find("_Session", where, types.M{})
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		return errs.E(errs.ObjectNotFound, "Object not found.")
+	}
+	return revokeSessions([]string{utils.S(utils.M(sessions[0])["sessionToken"])})
+}
+
+// RevokeAllDevices 注销用户的全部 session ，exceptToken 不为空时保留该 session ，返回注销的数量
+func RevokeAllDevices(userID, exceptToken string) (int, error) {
+	sessions, err := orm.TalismanDBController.Find("_Session", userSessionsQuery(userID), types.M{})
+	if err != nil {
+		return 0, err
+	}
+	tokens := []string{}
+	for _, s := range sessions {
+		token := utils.S(utils.M(s)["sessionToken"])
+		if token != "" && token != exceptToken {
+			tokens = append(tokens, token)
+		}
+	}
+	return len(tokens), revokeSessions(tokens)
+}
+
+// revokeSessions 删除 session 以及对应的 refreshToken 令牌族
+func revokeSessions(sessionTokens []string) error {
+	if len(sessionTokens) == 0 {
+		return nil
+	}
+	err := destroySessions(sessionTokens)
+	if err != nil {
+		return err
+	}
+	if config.TConfig.RefreshTokens {
+		tokens := types.S{}
+		for _, token := range sessionTokens {
+			tokens = append(tokens, token)
+		}
+		return RevokeRefreshTokens(types.M{"sessionToken": types.M{"$in": tokens}})
+	}
+	return nil
+}
+
+// sessionLocks 用户创建 session 时使用的锁，使用 Redis 缓存时多个实例共享
+var sessionLocks throttle.Store
+
+// sessionLockTTL 锁的有效期，持有锁的实例异常退出时自动释放
+const sessionLockTTL = 10 * time.Second
+
+// lockUserSessions 获取用户的 session 锁，第一个计数的请求获得锁，其余请求等待
+func lockUserSessions(userID string) error {
+	deadline := time.Now().Add(sessionLockTTL)
+	for {
+		n, err := sessionLocks.Incr("sessions:"+userID, sessionLockTTL)
+		if err != nil {
+			return err
+		}
+		if n == 1 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errs.E(errs.InternalServerError, "Timed out waiting for other logins of this user.")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func unlockUserSessions(userID string) {
+	if err := sessionLocks.Del("sessions:" + userID); err != nil {
+		logger.Error("release session lock failed:", err)
+	}
+}
+
+// activeSessions 返回用户自己登录的未过期的 session ，不包括模拟登录的 session ，按创建时间与 objectId 排序
+func activeSessions(userID string) ([]types.M, error) {
+	results, err := orm.TalismanDBController.Find("_Session", userSessionsQuery(userID), types.M{})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	sessions := []types.M{}
+	for _, r := range results {
+		session := utils.M(r)
+		if isImpersonationSession(session) {
+			continue
+		}
+		if expiresAt, err := utils.DateToTime(session["expiresAt"]); err == nil && expiresAt.Before(now) {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		ti, _ := utils.DateToTime(sessions[i]["createdAt"])
+		tj, _ := utils.DateToTime(sessions[j]["createdAt"])
+		if ti.Equal(tj) {
+			return utils.S(sessions[i]["objectId"]) < utils.S(sessions[j]["objectId"])
+		}
+		return ti.Before(tj)
+	})
+	return sessions, nil
+}
+
+func userSessionsQuery(userID string) types.M {
+	return types.M{
+		"user": types.M{
+			"__type":    "Pointer",
+			"className": "_User",
+			"objectId":  userID,
+		},
+	}
+}
+
+// lastActivity 未记录最后使用时间的 session 使用创建时间
+func lastActivity(device types.M) time.Time {
+	if t, err := utils.DateToTime(device["lastUsedAt"]); err == nil {
+		return t
+	}
+	t, _ := utils.DateToTime(device["createdAt"])
+	return t
+}
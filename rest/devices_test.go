@@ -0,0 +1,140 @@
+package rest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/okobsamoht/talisman/cache"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+func createTestSession(objectID, userID, token, installationID string, expiresAt time.Time) {
+	orm.TalismanDBController.Create("_Session", types.M{
+		"objectId":     objectID,
+		"sessionToken": token,
+		"user": types.M{
+			"__type":    "Pointer",
+			"className": "_User",
+			"objectId":  userID,
+		},
+		"installationId": installationID,
+		"expiresAt":      utils.DateJSON(expiresAt),
+	}, types.M{})
+}
+
+func Test_TouchSession(t *testing.T) {
+	var results types.S
+	/***************************************************************/
+	initEnv()
+	cache.InitCache()
+	config.TConfig.SessionActivityInterval = 300
+	createTestSession("s1", "1001", "r:abc", "", time.Now().Add(time.Hour))
+	TouchSession("r:abc", "10.0.0.1", "iPhone")
+	TouchSession("r:abc", "10.0.0.2", "iPhone")
+	results, _ = orm.TalismanDBController.Find("_Session", types.M{"objectId": "s1"}, types.M{})
+	if len(results) != 1 {
+		t.Fatal("expect:", "len 1", "result:", results)
+	}
+	session := utils.M(results[0])
+	if session["ipAddress"] != "10.0.0.1" || session["deviceName"] != "iPhone" || session["lastUsedAt"] == nil {
+		t.Error("expect:", "ipAddress 10.0.0.1 deviceName iPhone", "result:", session)
+	}
+	orm.TalismanDBController.DeleteEverything()
+}
+
+func Test_ListDevices(t *testing.T) {
+	var results types.S
+	var err error
+	/***************************************************************/
+	initEnv()
+	createTestSession("s1", "1001", "r:abc", "i1", time.Now().Add(time.Hour))
+	createTestSession("s2", "1001", "r:def", "", time.Now().Add(time.Hour))
+	createTestSession("s3", "1001", "r:old", "", time.Now().Add(-time.Hour))
+	createTestSession("s4", "1002", "r:ghi", "", time.Now().Add(time.Hour))
+	orm.TalismanDBController.Create("_Installation", types.M{
+		"objectId":       "i1",
+		"installationId": "i1",
+		"deviceType":     "ios",
+		"deviceToken":    "xxx",
+	}, types.M{})
+	results, err = ListDevices("1001", "r:def")
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	if len(results) != 2 {
+		t.Fatal("expect:", "len 2", "result:", results)
+	}
+	for _, r := range results {
+		device := utils.M(r)
+		if device["sessionToken"] != nil {
+			t.Error("expect:", "no sessionToken", "result:", device)
+		}
+		switch device["objectId"] {
+		case "s1":
+			expect := types.M{"deviceType": "ios"}
+			if device["current"] != false || reflect.DeepEqual(expect, device["installation"]) == false {
+				t.Error("expect:", expect, "result:", device)
+			}
+		case "s2":
+			if device["current"] != true {
+				t.Error("expect:", "current", "result:", device)
+			}
+		default:
+			t.Error("expect:", "s1 or s2", "result:", device)
+		}
+	}
+	orm.TalismanDBController.DeleteEverything()
+}
+
+func Test_RevokeDevice(t *testing.T) {
+	var results types.S
+	var err error
+	var expect error
+	/***************************************************************/
+	initEnv()
+	createTestSession("s1", "1001", "r:abc", "", time.Now().Add(time.Hour))
+	createTestSession("s4", "1002", "r:ghi", "", time.Now().Add(time.Hour))
+	err = RevokeDevice("1001", "s4")
+	expect = errs.E(errs.ObjectNotFound, "Object not found.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	err = RevokeDevice("1001", "s1")
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	results, _ = orm.TalismanDBController.Find("_Session", types.M{}, types.M{})
+	if len(results) != 1 || utils.M(results[0])["objectId"] != "s4" {
+		t.Error("expect:", "only s4", "result:", results)
+	}
+	orm.TalismanDBController.DeleteEverything()
+}
+
+func Test_RevokeAllDevices(t *testing.T) {
+	var results types.S
+	var count int
+	var err error
+	/***************************************************************/
+	initEnv()
+	createTestSession("s1", "1001", "r:abc", "", time.Now().Add(time.Hour))
+	createTestSession("s2", "1001", "r:def", "", time.Now().Add(time.Hour))
+	createTestSession("s4", "1002", "r:ghi", "", time.Now().Add(time.Hour))
+	count, err = RevokeAllDevices("1001", "r:def")
+	if err != nil || count != 1 {
+		t.Error("expect:", 1, "result:", count, err)
+	}
+	results, _ = orm.TalismanDBController.Find("_Session", types.M{"objectId": types.M{"$in": types.S{"s1", "s2"}}}, types.M{})
+	if len(results) != 1 || utils.M(results[0])["objectId"] != "s2" {
+		t.Error("expect:", "only s2", "result:", results)
+	}
+	count, err = RevokeAllDevices("1001", "")
+	if err != nil || count != 1 {
+		t.Error("expect:", 1, "result:", count, err)
+	}
+	orm.TalismanDBController.DeleteEverything()
+}
@@ -0,0 +1,132 @@
+package rest
+
+import (
+	"github.com/okobsamoht/talisman/cloud"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/files"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// SaveFile 保存文件，保存前后运行文件回调，返回文件地址与文件名
+// beforeSaveFile 可以修改文件名与文件内容，或者返回错误拒绝上传
+// beforeSaveFile 设置了文件地址时，认为文件已经保存，不再写入文件存储模块
+// beforeSaveFile 之后校验文件的大小与类型并扫描病毒，不通过时拒绝上传
+func SaveFile(auth *Auth, filename string, data []byte, contentType string) (map[string]string, error) {
+	return SaveFileWithTags(auth, filename, data, contentType, nil)
+}
+
+// SaveFileWithTags 保存文件，开启 FileMetadata 时同时在 _File 表中保存文件的元数据与标签
+func SaveFileWithTags(auth *Auth, filename string, data []byte, contentType string, tags []string) (map[string]string, error) {
+	file := &cloud.File{
+		Name:        filename,
+		Data:        data,
+		ContentType: contentType,
+		Tags:        tags,
+	}
+	err := maybeRunFileTrigger(cloud.TypeBeforeSaveFile, file, auth)
+	if err != nil {
+		return nil, err
+	}
+	// beforeSaveFile 可能修改了文件，校验与扫描使用修改之后的文件
+	err = files.CheckFileAllowed(file.Name, file.ContentType, len(file.Data))
+	if err != nil {
+		return nil, err
+	}
+	err = files.ScanFile(file.Name, file.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]string
+	if file.URL != "" {
+		result = map[string]string{
+			"url":  file.URL,
+			"name": file.Name,
+		}
+	} else {
+		if len(file.Data) == 0 {
+			return nil, errs.E(errs.FileSaveError, "Invalid file upload.")
+		}
+		if len(file.Name) > 128 {
+			return nil, errs.E(errs.InvalidFileName, "Filename too long.")
+		}
+		if utils.IsFileName(file.Name) == false {
+			return nil, errs.E(errs.InvalidFileName, "Filename contains invalid characters.")
+		}
+		result = files.CreateFile(file.Name, file.Data, file.ContentType)
+		if result == nil || result["url"] == "" {
+			return nil, errs.E(errs.FileSaveError, "Could not store file.")
+		}
+	}
+
+	file.Name = result["name"]
+	file.URL = result["url"]
+	if config.TConfig.FileMetadata {
+		err = saveFileMetadata(auth, file)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = maybeRunFileTrigger(cloud.TypeAfterSaveFile, file, auth)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteFile 删除文件，删除前后运行文件回调， beforeDeleteFile 返回错误时不删除文件
+func DeleteFile(auth *Auth, filename string) error {
+	file := &cloud.File{
+		Name: filename,
+		URL:  files.GetFileLocation(filename),
+	}
+	err := maybeRunFileTrigger(cloud.TypeBeforeDeleteFile, file, auth)
+	if err != nil {
+		return err
+	}
+	err = files.DeleteFile(filename)
+	if err != nil {
+		return errs.E(errs.FileDeleteError, "Could not delete file.")
+	}
+	if config.TConfig.FileMetadata {
+		err = orm.TalismanDBController.DeleteFileMetadata(filename)
+		if err != nil {
+			return err
+		}
+	}
+	return maybeRunFileTrigger(cloud.TypeAfterDeleteFile, file, auth)
+}
+
+// saveFileMetadata 保存文件的元数据，未指定文件类型时根据文件名推断
+func saveFileMetadata(auth *Auth, file *cloud.File) error {
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = utils.LookupContentType(file.Name)
+	}
+	uploader := ""
+	if auth != nil && auth.User != nil {
+		uploader = utils.S(auth.User["objectId"])
+	}
+	metadata := orm.NewFileMetadata(file.Name, file.URL, contentType, len(file.Data), uploader, file.Tags)
+	return orm.TalismanDBController.SaveFileMetadata(metadata)
+}
+
+// expandFileMetadata 在查询结果的 File 字段中添加文件的类型、大小与标签
+func expandFileMetadata(className string, objects types.S) error {
+	if config.TConfig.FileMetadata == false || className == orm.FileClassName {
+		return nil
+	}
+	names := orm.FileNamesInObjects(objects)
+	if len(names) == 0 {
+		return nil
+	}
+	metadata, err := orm.TalismanDBController.FindFileMetadata(names)
+	if err != nil {
+		return err
+	}
+	orm.AddFileMetadata(objects, metadata)
+	return nil
+}
@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/cloud"
+	"github.com/okobsamoht/talisman/errs"
+)
+
+func Test_SaveFile(t *testing.T) {
+	defer cloud.RemoveFileTrigger(cloud.TypeBeforeSaveFile)
+	defer cloud.RemoveFileTrigger(cloud.TypeAfterSaveFile)
+	var result map[string]string
+	var expect map[string]string
+	var err, expectErr error
+	/********************************************************/
+	cloud.BeforeSaveFile(func(request cloud.FileTriggerRequest, response cloud.Response) {
+		if request.FileSize > 4 {
+			response.Error(errs.FileSaveError, "File too large.")
+			return
+		}
+		request.File.Name = "renamed.txt"
+		request.File.URL = "http://cdn.example.com/renamed.txt"
+		response.Success(nil)
+	})
+	var saved *cloud.File
+	cloud.AfterSaveFile(func(request cloud.FileTriggerRequest, response cloud.Response) {
+		saved = request.File
+		response.Success(nil)
+	})
+	result, err = SaveFile(Master(), "hello.txt", []byte("hi"), "text/plain")
+	expect = map[string]string{"url": "http://cdn.example.com/renamed.txt", "name": "renamed.txt"}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	if saved == nil || saved.Name != "renamed.txt" {
+		t.Error("expect:", "renamed.txt", "result:", saved)
+	}
+	/********************************************************/
+	_, err = SaveFile(Master(), "hello.txt", []byte("hello"), "text/plain")
+	expectErr = errs.E(errs.FileSaveError, "File too large.")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+}
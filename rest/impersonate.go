@@ -0,0 +1,72 @@
+package rest
+
+import (
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// Impersonate 以用户的身份创建 session ，供客服排查问题，调用方需要校验 Master 权限
+// ttl 为有效期，单位为秒，不超过 ImpersonationSessionLength ， impersonation 为操作人、密钥、来源等信息，
+// 保存在只有 Master 可以读取的 _Impersonation 中，写入审计记录失败时删除 session 并返回错误
+// 无论 SessionTokenType 是否为 JWT ，都生成随机 token ，保证可以随时注销
+func Impersonate(userID string, ttl int, impersonation types.M) (types.M, error) {
+	users, err := orm.TalismanDBController.Find("_User", types.M{"objectId": userID}, types.M{"limit": 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, errs.E(errs.ObjectNotFound, "User not found.")
+	}
+	if ttl <= 0 || ttl > config.TConfig.ImpersonationSessionLength {
+		ttl = config.TConfig.ImpersonationSessionLength
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(time.Duration(ttl) * time.Second)
+	sessionData := types.M{
+		"sessionToken": "r:" + utils.CreateToken(),
+		"user": types.M{
+			"__type":    "Pointer",
+			"className": "_User",
+			"objectId":  userID,
+		},
+		"createdWith": types.M{
+			"action": "impersonate",
+		},
+		"restricted":   false,
+		"impersonated": true,
+		"expiresAt":    utils.DateJSON(expiresAt),
+	}
+	write, err := NewWrite(Master(), "_Session", nil, sessionData, types.M{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	result, err := write.Execute()
+	if err != nil {
+		return nil, err
+	}
+	sessionID := utils.S(utils.M(result["response"])["objectId"])
+
+	record := orm.NewImpersonation(userID, sessionID, impersonation, expiresAt)
+	err = orm.TalismanDBController.SaveImpersonation(record)
+	if err != nil {
+		orm.TalismanDBController.Destroy("_Session", types.M{"objectId": sessionID}, types.M{})
+		return nil, err
+	}
+	sessionData["objectId"] = sessionID
+	return sessionData, nil
+}
+
+// isImpersonationSession 判断是否为模拟登录创建的 session
+// 模拟登录的 session 不占用用户的 MaxSessionsPerUser 名额，也不会因超出名额被注销
+func isImpersonationSession(session types.M) bool {
+	if session["impersonated"] == true {
+		return true
+	}
+	return utils.S(utils.M(session["createdWith"])["action"]) == "impersonate"
+}
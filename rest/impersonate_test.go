@@ -0,0 +1,119 @@
+package rest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/livequery"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+func Test_Impersonate(t *testing.T) {
+	var result types.M
+	var err error
+	var expect error
+	var results types.S
+	/***************************************************************/
+	initEnv()
+	livequery.TLiveQuery = livequery.NewLiveQuery([]string{}, "", "", "")
+	config.TConfig.ImpersonationSessionLength = 3600
+	_, err = Impersonate("1001", 600, types.M{"operator": "alice"})
+	expect = errs.E(errs.ObjectNotFound, "User not found.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	orm.TalismanDBController.DeleteEverything()
+	/***************************************************************/
+	initEnv()
+	orm.TalismanDBController.Create("_User", types.M{"objectId": "1001", "username": "joe"}, types.M{})
+	result, err = Impersonate("1001", 7200, types.M{"operator": "alice", "reason": "ticket 123"})
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	expiresAt, _ := utils.DateToTime(result["expiresAt"])
+	if expiresAt.After(time.Now().Add(time.Hour + time.Minute)) {
+		t.Error("expect:", "ttl capped to 3600", "result:", result["expiresAt"])
+	}
+	results, _ = orm.TalismanDBController.Find("_Session", types.M{"sessionToken": result["sessionToken"]}, types.M{})
+	if len(results) != 1 {
+		t.Fatal("expect:", "len 1", "result:", results)
+	}
+	session := utils.M(results[0])
+	if session["impersonated"] != true || session["impersonation"] != nil {
+		t.Error("expect:", "impersonated without operator", "result:", session)
+	}
+	results, _ = orm.TalismanDBController.Find(orm.ImpersonationClassName, types.M{"session": session["objectId"]}, types.M{})
+	if len(results) != 1 {
+		t.Fatal("expect:", "len 1", "result:", results)
+	}
+	record := utils.M(results[0])
+	if record["operator"] != "alice" || record["reason"] != "ticket 123" || utils.M(record["user"])["objectId"] != "1001" {
+		t.Error("expect:", "audit record by alice", "result:", record)
+	}
+	auth, err := GetAuthForSessionToken(utils.S(result["sessionToken"]), "")
+	if err != nil || utils.S(auth.User["objectId"]) != "1001" {
+		t.Error("expect:", "1001", "result:", auth, err)
+	}
+	orm.TalismanDBController.DeleteEverything()
+	/***************************************************************/
+	// 模拟登录不受 MaxSessionsPerUser 限制
+	initEnv()
+	config.TConfig.MaxSessionsPerUser = 1
+	config.TConfig.SessionLimitPolicy = "reject"
+	orm.TalismanDBController.Create("_User", types.M{"objectId": "1001", "username": "joe"}, types.M{})
+	createTestSession("s1", "1001", "r:abc", "", time.Now().Add(time.Hour))
+	_, err = Impersonate("1001", 600, types.M{"operator": "alice"})
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	results, _ = orm.TalismanDBController.Find("_Session", types.M{"sessionToken": "r:abc"}, types.M{})
+	if len(results) != 1 {
+		t.Error("expect:", "len 1", "result:", results)
+	}
+	orm.TalismanDBController.DeleteEverything()
+	/***************************************************************/
+	// evictOldest 时不计入也不注销 createdWith.action 为 impersonate 的 session
+	initEnv()
+	config.TConfig.MaxSessionsPerUser = 1
+	config.TConfig.SessionLimitPolicy = "evictOldest"
+	orm.TalismanDBController.Create("_Session", types.M{
+		"objectId":     "s0",
+		"sessionToken": "r:support",
+		"user": types.M{
+			"__type":    "Pointer",
+			"className": "_User",
+			"objectId":  "1001",
+		},
+		"createdWith": types.M{"action": "impersonate"},
+		"expiresAt":   utils.DateJSON(time.Now().Add(time.Hour)),
+	}, types.M{})
+	createTestSession("s1", "1001", "r:abc", "", time.Now().Add(time.Hour))
+	write, _ := NewWrite(Master(), "_Session", nil, types.M{
+		"sessionToken": "r:def",
+		"user": types.M{
+			"__type":    "Pointer",
+			"className": "_User",
+			"objectId":  "1001",
+		},
+		"expiresAt": utils.DateJSON(time.Now().Add(time.Hour)),
+	}, types.M{}, nil)
+	_, err = write.Execute()
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	results, _ = orm.TalismanDBController.Find("_Session", types.M{"sessionToken": "r:support"}, types.M{})
+	if len(results) != 1 {
+		t.Error("expect:", "r:support kept", "result:", results)
+	}
+	results, _ = orm.TalismanDBController.Find("_Session", types.M{"sessionToken": "r:abc"}, types.M{})
+	if len(results) != 0 {
+		t.Error("expect:", "r:abc evicted", "result:", results)
+	}
+	orm.TalismanDBController.DeleteEverything()
+	config.TConfig.MaxSessionsPerUser = 0
+}
@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"sort"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// userLookupOptions 开启 lowercase 时按不区分大小写的方式查找，兼容开启之前保存的数据
+func userLookupOptions(steps []string, options types.M) types.M {
+	for _, step := range steps {
+		if step == "lowercase" {
+			options["caseInsensitive"] = true
+		}
+	}
+	return options
+}
+
+// UsernameLookupOptions 按用户名查找用户时使用的查询选项
+func UsernameLookupOptions(options types.M) types.M {
+	return userLookupOptions(config.TConfig.UsernameNormalization, options)
+}
+
+// EmailLookupOptions 按邮箱查找用户时使用的查询选项
+func EmailLookupOptions(options types.M) types.M {
+	return userLookupOptions(config.TConfig.EmailNormalization, options)
+}
+
+// FindNormalizationConflicts 查找 field 字段在全部规范化方式处理之后相同的用户，用于开启规范化之前检查已有数据
+// field 为 username 或 email ，返回的每一组包含规范化之后的值以及对应的用户，按值排序
+func FindNormalizationConflicts(field string) (types.S, error) {
+	users, err := orm.TalismanDBController.Find("_User", types.M{field: types.M{"$exists": true}}, types.M{"keys": []string{field}})
+	if err != nil {
+		return nil, err
+	}
+	return normalizationConflicts(users, field), nil
+}
+
+// normalizationConflicts 按规范化之后的值分组，只返回包含多个用户的分组
+func normalizationConflicts(users types.S, field string) types.S {
+	groups := map[string]types.S{}
+	for _, u := range users {
+		user := utils.M(u)
+		value, ok := user[field].(string)
+		if ok == false || value == "" {
+			continue
+		}
+		key := utils.Normalize(value, utils.NormalizationSteps)
+		groups[key] = append(groups[key], types.M{
+			"objectId": user["objectId"],
+			field:      value,
+		})
+	}
+
+	keys := []string{}
+	for key, group := range groups {
+		if len(group) > 1 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	conflicts := types.S{}
+	for _, key := range keys {
+		group := groups[key]
+		sort.SliceStable(group, func(i, j int) bool {
+			return utils.S(utils.M(group[i])["objectId"]) < utils.S(utils.M(group[j])["objectId"])
+		})
+		conflicts = append(conflicts, types.M{
+			"normalized": key,
+			"users":      group,
+		})
+	}
+	return conflicts
+}
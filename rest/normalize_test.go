@@ -0,0 +1,44 @@
+package rest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_normalizationConflicts(t *testing.T) {
+	users := types.S{
+		types.M{"objectId": "02", "username": "Joe"},
+		types.M{"objectId": "01", "username": "joe "},
+		types.M{"objectId": "03", "username": "jos\u00e9"},
+		types.M{"objectId": "04", "username": "Jose\u0301"},
+		types.M{"objectId": "05", "username": "tom"},
+		types.M{"objectId": "06"},
+	}
+	expect := types.S{
+		types.M{
+			"normalized": "joe",
+			"users": types.S{
+				types.M{"objectId": "01", "username": "joe "},
+				types.M{"objectId": "02", "username": "Joe"},
+			},
+		},
+		types.M{
+			"normalized": "jos\u00e9",
+			"users": types.S{
+				types.M{"objectId": "03", "username": "jos\u00e9"},
+				types.M{"objectId": "04", "username": "Jose\u0301"},
+			},
+		},
+	}
+	result := normalizationConflicts(users, "username")
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+
+	result = normalizationConflicts(types.S{types.M{"objectId": "01", "email": "a@b.com"}}, "email")
+	if reflect.DeepEqual(types.S{}, result) == false {
+		t.Error("expect:", types.S{}, "result:", result)
+	}
+}
@@ -0,0 +1,28 @@
+package rest
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+)
+
+// objectNotFound 开启 UniformObjectNotFound 时返回给客户端的唯一错误
+var objectNotFound = errs.E(errs.ObjectNotFound, "Object not found.")
+
+// UniformNotFound 开启 UniformObjectNotFound 时，把客户端按 objectId 操作对象时的 ObjectNotFound 错误统一为 Object not found.
+// 对象不存在、 ACL 不允许访问以及需要登录时返回相同的错误，并随机等待 0 到 ObjectNotFoundJitter 毫秒，防止通过错误信息或者响应时间探测 objectId
+// 使用 Master Key 或者其他错误原样返回
+func UniformNotFound(auth *Auth, err error) error {
+	if config.TConfig.UniformObjectNotFound == false || auth == nil || auth.IsMaster {
+		return err
+	}
+	if errs.GetErrorCode(err) != errs.ObjectNotFound {
+		return err
+	}
+	if jitter := config.TConfig.ObjectNotFoundJitter; jitter > 0 {
+		time.Sleep(time.Duration(rand.Int63n(int64(jitter)+1)) * time.Millisecond)
+	}
+	return objectNotFound
+}
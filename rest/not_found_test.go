@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+)
+
+func Test_UniformNotFound(t *testing.T) {
+	defer func(c config.Config) { *config.TConfig = c }(*config.TConfig)
+	denied := errs.E(errs.ObjectNotFound, "Permission denied, user needs to be authenticated.")
+	invalid := errs.E(errs.InvalidJSON, "request body is empty")
+	expect := errs.E(errs.ObjectNotFound, "Object not found.")
+	/*************************************************/
+	config.TConfig.UniformObjectNotFound = false
+	if err := UniformNotFound(Nobody(), denied); reflect.DeepEqual(denied, err) == false {
+		t.Error("expect:", denied, "result:", err)
+	}
+	/*************************************************/
+	config.TConfig.UniformObjectNotFound = true
+	config.TConfig.ObjectNotFoundJitter = 0
+	if err := UniformNotFound(Nobody(), denied); reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	if err := UniformNotFound(Nobody(), errs.E(errs.ObjectNotFound, "Object not found for update.")); reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	if err := UniformNotFound(Master(), denied); reflect.DeepEqual(denied, err) == false {
+		t.Error("expect:", denied, "result:", err)
+	}
+	if err := UniformNotFound(Nobody(), invalid); reflect.DeepEqual(invalid, err) == false {
+		t.Error("expect:", invalid, "result:", err)
+	}
+	/*************************************************/
+	config.TConfig.ObjectNotFoundJitter = 30
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		UniformNotFound(Nobody(), denied)
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Error("expect:", "less than 400ms", "result:", elapsed)
+	}
+}
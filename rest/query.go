@@ -3,6 +3,7 @@ package rest
 import (
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/okobsamoht/talisman/cloud"
 	"github.com/okobsamoht/talisman/config"
@@ -26,6 +27,7 @@ type Query struct {
 	keys              []string
 	redirectKey       string
 	redirectClassName string
+	countRelations    []string
 	clientSDK         map[string]string
 }
 
@@ -59,6 +61,10 @@ func NewQuery(
 		redirectClassName: "",
 		clientSDK:         clientSDK,
 	}
+	// 请求 ID 传递给数据库适配器，写入查询注释中
+	if auth.RequestID != "" {
+		query.findOptions["requestId"] = auth.RequestID
+	}
 
 	if auth.IsMaster == false {
 		// 当前权限为 Master 时，findOptions 中不存在 acl 这个 key
@@ -167,6 +173,22 @@ func NewQuery(
 					query.include = append(query.include, strings.Split(set, "."))
 				} // query.include = [["name"],["name","friend"],["user"],["user","seeeion"]]
 			}
+		case "countRelations":
+			if s, ok := v.(string); ok {
+				for _, key := range strings.Split(s, ",") {
+					if key = strings.TrimSpace(key); key != "" {
+						query.countRelations = append(query.countRelations, key)
+					}
+				}
+			}
+		case "locale":
+			if s, ok := v.(string); ok && s != "" {
+				query.findOptions["locale"] = s
+			}
+		case "caseInsensitive":
+			if b, ok := v.(bool); ok && b {
+				query.findOptions["caseInsensitive"] = true
+			}
 		case "redirectClassNameForKey":
 			if s, ok := v.(string); ok {
 				query.redirectKey = s
@@ -195,6 +217,10 @@ func (q *Query) Execute(executeOptions ...types.M) (types.M, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = q.handleCountRelations()
+	if err != nil {
+		return nil, err
+	}
 	err = q.handleInclude()
 	if err != nil {
 		return nil, err
@@ -236,6 +262,10 @@ func (q *Query) BuildRestWhere() error {
 	if err != nil {
 		return err
 	}
+	err = q.replaceDateRange()
+	if err != nil {
+		return err
+	}
 	q.replaceEquality()
 	return nil
 }
@@ -547,6 +577,21 @@ func (q *Query) replaceNotInQuery() error {
 	return q.replaceNotInQuery()
 }
 
+// replaceDateRange 把查询条件中的 $dateRange 替换为 $gte 与 $lt ，由服务器按指定时区计算时间范围
+// 查询条件格式为 {"createdAt":{"$dateRange":{"period":"today","timezone":"Asia/Shanghai"}}}
+func (q *Query) replaceDateRange() error {
+	dateRangeObject := findObjectWithKey(q.Where, "$dateRange")
+	if dateRangeObject == nil {
+		return nil
+	}
+	err := transformDateRange(dateRangeObject, time.Now())
+	if err != nil {
+		return err
+	}
+	// 继续搜索替换
+	return q.replaceDateRange()
+}
+
 func (q *Query) replaceEquality() {
 	for key := range q.Where {
 		q.Where[key] = replaceEqualityConstraint(q.Where[key])
@@ -595,18 +640,22 @@ func (q *Query) runFind(executeOptions ...types.M) error {
 	if err != nil {
 		return err
 	}
-	// 从 _User 表中删除敏感字段
-	if q.className == "_User" {
-		for _, v := range response {
-			if user := utils.M(v); user != nil {
-				cleanResultOfSensitiveUserInfo(user, q.auth)
-				cleanResultAuthData(user)
+	// 删除内部字段，以及 _User 表中的敏感字段
+	for _, v := range response {
+		if object := utils.M(v); object != nil {
+			sanitizeResult(q.className, object, q.auth)
+			if q.className == "_User" {
+				cleanResultAuthData(object)
 			}
 		}
 	}
 
 	// 展开文件类型
 	files.ExpandFilesInObject(response)
+	err = expandFileMetadata(q.className, response)
+	if err != nil {
+		return err
+	}
 
 	if q.redirectClassName != "" {
 		for _, v := range response {
@@ -620,6 +669,35 @@ func (q *Query) runFind(executeOptions ...types.M) error {
 	return nil
 }
 
+// handleCountRelations 在结果中添加 countRelations 指定的 Relation 字段的对象数，格式如下：
+// {"likes":{"__type":"Relation","className":"_User","count":42}}
+// 只统计 _Join 表中的记录，不校验目标对象的 ACL
+func (q *Query) handleCountRelations() error {
+	if len(q.countRelations) == 0 {
+		return nil
+	}
+	results := utils.A(q.response["results"])
+	for _, key := range q.countRelations {
+		targetClass := orm.TalismanDBController.RedirectClassNameForKey(q.className, key)
+		for _, v := range results {
+			object := utils.M(v)
+			if object == nil {
+				continue
+			}
+			count, err := orm.TalismanDBController.CountRelated(q.className, key, utils.S(object["objectId"]))
+			if err != nil {
+				return err
+			}
+			object[key] = types.M{
+				"__type":    "Relation",
+				"className": targetClass,
+				"count":     count,
+			}
+		}
+	}
+	return nil
+}
+
 // runCount 查询符合条件的结果数量
 func (q *Query) runCount() error {
 	if q.doCount == false {
@@ -746,6 +824,10 @@ func includePath(auth *Auth, response types.M, path []string, restOptions types.
 			includeRestOptions["keys"] = strings.Join(keySet, ",")
 		}
 	}
+	// 包含的对象使用相同的语言解析 LocalizedString 字段
+	if locale, ok := restOptions["locale"].(string); ok && locale != "" {
+		includeRestOptions["locale"] = locale
+	}
 
 	replace := types.M{}
 	for clsName, ids := range pointersHash {
@@ -777,10 +859,7 @@ func includePath(auth *Auth, response types.M, path []string, restOptions types.
 			}
 			obj["__type"] = "Object"
 			obj["className"] = clsName
-			if clsName == "_User" && auth.IsMaster == false {
-				delete(obj, "sessionToken")
-				delete(obj, "authData")
-			}
+			sanitizeResult(clsName, obj, auth)
 			replace[utils.S(obj["objectId"])] = obj
 		}
 	}
@@ -929,6 +1008,51 @@ func transformDontSelect(dontSelectObject types.M, key string, objects []types.M
 	dontSelectObject["$nin"] = nin
 }
 
+// transformDateRange 转换对象中的 $dateRange ， period 为 today yesterday thisWeek lastMonth 等时相对于 now 计算，
+// 为 day week month year 时需要指定 date ，格式为 2006-01-02 或者 Date 对象， timezone 为 IANA 时区名称，默认为 UTC
+func transformDateRange(dateRangeObject types.M, now time.Time) error {
+	dateRange := utils.M(dateRangeObject["$dateRange"])
+	if dateRange == nil {
+		return errs.E(errs.InvalidQuery, "bad $dateRange value")
+	}
+	for _, op := range []string{"$gt", "$gte", "$lt", "$lte"} {
+		if _, ok := dateRangeObject[op]; ok {
+			return errs.E(errs.InvalidQuery, "$dateRange can not be used with "+op)
+		}
+	}
+
+	timezone := utils.S(dateRange["timezone"])
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return errs.E(errs.InvalidQuery, "bad $dateRange timezone: "+timezone)
+	}
+
+	period := utils.S(dateRange["period"])
+	date := now
+	if utils.IsRelativePeriod(period) == false {
+		if s, ok := dateRange["date"].(string); ok {
+			date, err = time.ParseInLocation("2006-01-02", s, loc)
+		} else {
+			date, err = utils.DateToTime(dateRange["date"])
+		}
+		if err != nil {
+			return errs.E(errs.InvalidQuery, "bad $dateRange date, it must be 2006-01-02 or a Date")
+		}
+	}
+	start, end, err := utils.DateBucket(period, date, loc)
+	if err != nil {
+		return errs.E(errs.InvalidQuery, "bad $dateRange period: "+period)
+	}
+
+	delete(dateRangeObject, "$dateRange")
+	dateRangeObject["$gte"] = utils.DateJSON(start)
+	dateRangeObject["$lt"] = utils.DateJSON(end)
+	return nil
+}
+
 // transformInQuery 转换对象中的 $inQuery
 func transformInQuery(inQueryObject types.M, className string, results []types.M) {
 	if inQueryObject == nil || inQueryObject["$inQuery"] == nil {
@@ -987,17 +1111,13 @@ func transformNotInQuery(notInQueryObject types.M, className string, results []t
 	notInQueryObject["$nin"] = nin
 }
 
-// cleanResultOfSensitiveUserInfo 清除用户数据中的敏感字段
-func cleanResultOfSensitiveUserInfo(result types.M, auth *Auth) {
-	delete(result, "password")
-
-	if auth.IsMaster || (auth.User != nil && utils.S(auth.User["objectId"]) == utils.S(result["objectId"])) {
-		return
-	}
-
-	for _, field := range config.TConfig.UserSensitiveFields {
-		delete(result, field)
+// sanitizeResult 删除返回结果中的内部字段与用户敏感字段
+func sanitizeResult(className string, result types.M, auth *Auth) {
+	userID := ""
+	if auth.User != nil {
+		userID = utils.S(auth.User["objectId"])
 	}
+	orm.SanitizeObject(className, result, auth.IsMaster, userID)
 }
 
 // cleanResultAuthData 清理 AuthData
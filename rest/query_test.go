@@ -3,6 +3,7 @@ package rest
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/okobsamoht/talisman/cache"
 	"github.com/okobsamoht/talisman/config"
@@ -4058,6 +4059,80 @@ func Test_transformDontSelect(t *testing.T) {
 	}
 }
 
+func Test_transformDateRange(t *testing.T) {
+	var dateRangeObject types.M
+	var err error
+	var expect types.M
+	var expectErr error
+	now := time.Date(2016, 2, 28, 16, 30, 0, 0, time.UTC)
+	/**********************************************************/
+	dateRangeObject = types.M{"$dateRange": types.M{"period": "today"}}
+	err = transformDateRange(dateRangeObject, now)
+	expect = types.M{
+		"$gte": types.M{"__type": "Date", "iso": "2016-02-28T00:00:00.000Z"},
+		"$lt":  types.M{"__type": "Date", "iso": "2016-02-29T00:00:00.000Z"},
+	}
+	if err != nil || reflect.DeepEqual(expect, dateRangeObject) == false {
+		t.Error("expect:", expect, "result:", dateRangeObject, err)
+	}
+	/**********************************************************/
+	dateRangeObject = types.M{"$dateRange": types.M{"period": "today", "timezone": "Asia/Shanghai"}, "$ne": nil}
+	err = transformDateRange(dateRangeObject, now)
+	expect = types.M{
+		"$gte": types.M{"__type": "Date", "iso": "2016-02-28T16:00:00.000Z"},
+		"$lt":  types.M{"__type": "Date", "iso": "2016-02-29T16:00:00.000Z"},
+		"$ne":  nil,
+	}
+	if err != nil || reflect.DeepEqual(expect, dateRangeObject) == false {
+		t.Error("expect:", expect, "result:", dateRangeObject, err)
+	}
+	/**********************************************************/
+	dateRangeObject = types.M{"$dateRange": types.M{"period": "month", "date": "2016-03-15", "timezone": "Asia/Shanghai"}}
+	err = transformDateRange(dateRangeObject, now)
+	expect = types.M{
+		"$gte": types.M{"__type": "Date", "iso": "2016-02-29T16:00:00.000Z"},
+		"$lt":  types.M{"__type": "Date", "iso": "2016-03-31T16:00:00.000Z"},
+	}
+	if err != nil || reflect.DeepEqual(expect, dateRangeObject) == false {
+		t.Error("expect:", expect, "result:", dateRangeObject, err)
+	}
+	/**********************************************************/
+	dateRangeObject = types.M{"$dateRange": "today"}
+	err = transformDateRange(dateRangeObject, now)
+	expectErr = errs.E(errs.InvalidQuery, "bad $dateRange value")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/**********************************************************/
+	dateRangeObject = types.M{"$dateRange": types.M{"period": "today"}, "$lt": "2016-01-01"}
+	err = transformDateRange(dateRangeObject, now)
+	expectErr = errs.E(errs.InvalidQuery, "$dateRange can not be used with $lt")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/**********************************************************/
+	dateRangeObject = types.M{"$dateRange": types.M{"period": "today", "timezone": "Mars/Olympus"}}
+	err = transformDateRange(dateRangeObject, now)
+	expectErr = errs.E(errs.InvalidQuery, "bad $dateRange timezone: Mars/Olympus")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/**********************************************************/
+	dateRangeObject = types.M{"$dateRange": types.M{"period": "day"}}
+	err = transformDateRange(dateRangeObject, now)
+	expectErr = errs.E(errs.InvalidQuery, "bad $dateRange date, it must be 2006-01-02 or a Date")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+	/**********************************************************/
+	dateRangeObject = types.M{"$dateRange": types.M{"period": "someday", "date": "2016-02-28"}}
+	err = transformDateRange(dateRangeObject, now)
+	expectErr = errs.E(errs.InvalidQuery, "bad $dateRange period: someday")
+	if reflect.DeepEqual(expectErr, err) == false {
+		t.Error("expect:", expectErr, "result:", err)
+	}
+}
+
 func Test_transformInQuery(t *testing.T) {
 	var inQueryObject types.M
 	var className string
@@ -0,0 +1,170 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/logger"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// NewRefreshToken 为新签发的 sessionToken 生成 refreshToken ，family 为空时表示新的登录，开始新的令牌族
+func NewRefreshToken(userID, sessionToken, family string) (string, error) {
+	if family == "" {
+		family = utils.CreateObjectID()
+	}
+	token := "rt:" + utils.CreateToken()
+	record := orm.NewRefreshToken(hashRefreshToken(token), family, userID, sessionToken, config.GenerateRefreshTokenExpiresAt())
+	err := orm.TalismanDBController.Create(orm.RefreshTokenClassName, record, types.M{})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RefreshSession 使用 refreshToken 换取新的 sessionToken 与 refreshToken ，旧的 sessionToken 立即失效
+// 每个 refreshToken 只能使用一次，已使用过的 refreshToken 再次出现时说明已经泄露，注销整个令牌族
+func RefreshSession(refreshToken, installationID string) (types.M, error) {
+	invalid := errs.E(errs.InvalidSessionToken, "Invalid refresh token.")
+	if refreshToken == "" {
+		return nil, invalid
+	}
+	results, err := orm.TalismanDBController.Find(orm.RefreshTokenClassName, types.M{"tokenHash": hashRefreshToken(refreshToken)}, types.M{"limit": 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, invalid
+	}
+	record := utils.M(results[0])
+	family := utils.S(record["family"])
+	reused := errs.E(errs.InvalidSessionToken, "Refresh token has already been used.")
+	if used, ok := record["used"].(bool); ok && used {
+		logger.Warn("refresh token reuse detected, revoke token family", family)
+		return nil, revokeRefreshTokenFamily(family, reused)
+	}
+	if expiresAt, err := utils.DateToTime(record["expiresAt"]); err != nil || expiresAt.Before(time.Now()) {
+		return nil, invalid
+	}
+
+	// 只有 used 为 false 时才能修改成功，并发使用同一个 refreshToken 时只有一个请求成功
+	where := types.M{"objectId": record["objectId"], "used": false}
+	_, err = orm.TalismanDBController.Update(orm.RefreshTokenClassName, where, types.M{"used": true}, types.M{}, true)
+	if err != nil {
+		if errs.GetErrorCode(err) == errs.ObjectNotFound {
+			logger.Warn("refresh token reuse detected, revoke token family", family)
+			return nil, revokeRefreshTokenFamily(family, reused)
+		}
+		return nil, err
+	}
+	err = destroySessions([]string{utils.S(record["sessionToken"])})
+	if err != nil {
+		return nil, err
+	}
+
+	userID := utils.S(utils.M(record["user"])["objectId"])
+	token, expiresAt := NewSessionToken(userID)
+	sessionData := types.M{
+		"sessionToken": token,
+		"user": types.M{
+			"__type":    "Pointer",
+			"className": "_User",
+			"objectId":  userID,
+		},
+		"createdWith": types.M{
+			"action": "refresh",
+		},
+		"restricted": false,
+		"expiresAt": types.M{
+			"__type": "Date",
+			"iso":    utils.TimetoString(expiresAt),
+		},
+	}
+	if installationID != "" {
+		sessionData["installationId"] = installationID
+	}
+	write, err := NewWrite(Master(), "_Session", nil, sessionData, types.M{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	_, err = write.Execute()
+	if err != nil {
+		return nil, err
+	}
+	newRefreshToken, err := NewRefreshToken(userID, token, family)
+	if err != nil {
+		return nil, err
+	}
+
+	return types.M{
+		"objectId":     userID,
+		"sessionToken": token,
+		"refreshToken": newRefreshToken,
+		"expiresAt":    utils.DateJSON(expiresAt),
+	}, nil
+}
+
+// RevokeRefreshTokens 删除符合条件的 refreshToken 所在的令牌族以及令牌族签发的 session ，用于退出登录与修改密码
+func RevokeRefreshTokens(where types.M) error {
+	results, err := orm.TalismanDBController.Find(orm.RefreshTokenClassName, where, types.M{})
+	if err != nil {
+		return err
+	}
+	families := map[string]bool{}
+	for _, r := range results {
+		family := utils.S(utils.M(r)["family"])
+		if families[family] {
+			continue
+		}
+		families[family] = true
+		if err := revokeRefreshTokenFamily(family, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeRefreshTokenFamily 删除令牌族中的全部 refreshToken 与对应的 session ，成功时返回 result
+func revokeRefreshTokenFamily(family string, result error) error {
+	results, err := orm.TalismanDBController.Find(orm.RefreshTokenClassName, types.M{"family": family}, types.M{})
+	if err != nil {
+		return err
+	}
+	sessionTokens := []string{}
+	for _, r := range results {
+		sessionTokens = append(sessionTokens, utils.S(utils.M(r)["sessionToken"]))
+	}
+	err = destroySessions(sessionTokens)
+	if err != nil {
+		return err
+	}
+	err = orm.TalismanDBController.Destroy(orm.RefreshTokenClassName, types.M{"family": family}, types.M{})
+	if err != nil && errs.GetErrorCode(err) != errs.ObjectNotFound {
+		return err
+	}
+	return result
+}
+
+// destroySessions 删除 session 并清除用户缓存
+func destroySessions(sessionTokens []string) error {
+	if len(sessionTokens) == 0 {
+		return nil
+	}
+	tokens := types.S{}
+	for _, token := range sessionTokens {
+		tUserCache.delSession(token)
+		tokens = append(tokens, token)
+	}
+	return orm.TalismanDBController.Destroy("_Session", types.M{"sessionToken": types.M{"$in": tokens}}, types.M{})
+}
+
+// hashRefreshToken 数据库中只保存 refreshToken 的哈希值
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
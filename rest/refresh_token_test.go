@@ -0,0 +1,125 @@
+package rest
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/livequery"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+func Test_RefreshSession(t *testing.T) {
+	var result types.M
+	var err error
+	var expect error
+	var results types.S
+	/***************************************************************/
+	initEnv()
+	livequery.TLiveQuery = livequery.NewLiveQuery([]string{}, "", "", "")
+	config.TConfig.RefreshTokens = true
+	config.TConfig.AccessTokenLength = 3600
+	config.TConfig.RefreshTokenLength = 2592000
+	refreshToken, _ := NewRefreshToken("1001", "r:abc", "")
+	result, err = RefreshSession(refreshToken, "")
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	if result["sessionToken"] == nil || result["refreshToken"] == nil || result["refreshToken"] == refreshToken {
+		t.Error("expect:", "new sessionToken and refreshToken", "result:", result)
+	}
+	results, _ = orm.TalismanDBController.Find("_Session", types.M{"sessionToken": result["sessionToken"]}, types.M{})
+	if len(results) != 1 {
+		t.Error("expect:", "len 1", "result:", results)
+	}
+	results, _ = orm.TalismanDBController.Find(orm.RefreshTokenClassName, types.M{}, types.M{})
+	if len(results) != 2 {
+		t.Error("expect:", "len 2", "result:", results)
+	}
+	orm.TalismanDBController.DeleteEverything()
+	/***************************************************************/
+	initEnv()
+	result, err = RefreshSession("rt:unknown", "")
+	expect = errs.E(errs.InvalidSessionToken, "Invalid refresh token.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	orm.TalismanDBController.DeleteEverything()
+	/***************************************************************/
+	// 重复使用时注销整个令牌族
+	initEnv()
+	refreshToken, _ = NewRefreshToken("1001", "r:abc", "")
+	result, _ = RefreshSession(refreshToken, "")
+	_, err = RefreshSession(refreshToken, "")
+	expect = errs.E(errs.InvalidSessionToken, "Refresh token has already been used.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	results, _ = orm.TalismanDBController.Find("_Session", types.M{}, types.M{})
+	if len(results) != 0 {
+		t.Error("expect:", "len 0", "result:", results)
+	}
+	results, _ = orm.TalismanDBController.Find(orm.RefreshTokenClassName, types.M{}, types.M{})
+	if len(results) != 0 {
+		t.Error("expect:", "len 0", "result:", results)
+	}
+	_, err = RefreshSession(utils.S(result["refreshToken"]), "")
+	expect = errs.E(errs.InvalidSessionToken, "Invalid refresh token.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	orm.TalismanDBController.DeleteEverything()
+	/***************************************************************/
+	// 并发使用同一个 refreshToken 时只有一个请求成功
+	initEnv()
+	refreshToken, _ = NewRefreshToken("1001", "r:abc", "")
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	success := 0
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := RefreshSession(refreshToken, ""); err == nil {
+				mu.Lock()
+				success++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if success > 1 {
+		t.Error("expect:", "at most 1 success", "result:", success)
+	}
+	orm.TalismanDBController.DeleteEverything()
+	config.TConfig.RefreshTokens = false
+}
+
+func Test_RevokeRefreshTokens(t *testing.T) {
+	var err error
+	var results types.S
+	/***************************************************************/
+	initEnv()
+	livequery.TLiveQuery = livequery.NewLiveQuery([]string{}, "", "", "")
+	config.TConfig.RefreshTokenLength = 2592000
+	refreshToken, _ := NewRefreshToken("1001", "r:abc", "")
+	NewRefreshToken("1002", "r:def", "")
+	RefreshSession(refreshToken, "")
+	err = RevokeRefreshTokens(types.M{"user": types.M{"__type": "Pointer", "className": "_User", "objectId": "1001"}})
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	results, _ = orm.TalismanDBController.Find(orm.RefreshTokenClassName, types.M{}, types.M{})
+	if len(results) != 1 || utils.S(utils.M(utils.M(results[0])["user"])["objectId"]) != "1002" {
+		t.Error("expect:", "only 1002", "result:", results)
+	}
+	results, _ = orm.TalismanDBController.Find("_Session", types.M{}, types.M{})
+	if len(results) != 0 {
+		t.Error("expect:", "len 0", "result:", results)
+	}
+	orm.TalismanDBController.DeleteEverything()
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/okobsamoht/talisman/cloud"
 	"github.com/okobsamoht/talisman/errs"
 	"github.com/okobsamoht/talisman/livequery"
+	"github.com/okobsamoht/talisman/orm"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
 )
@@ -156,6 +157,34 @@ func enforceRoleSecurity(method string, className string, auth *Auth) error {
 			return errs.E(errs.OperationForbidden, msg)
 		}
 	}
+	// 推送受众只能使用 Master 权限操作
+	if className == "_Audience" && auth.IsMaster == false {
+		return errs.E(errs.OperationForbidden, "Clients aren't allowed to perform the "+method+" operation on the _Audience collection.")
+	}
+	// 修订记录只能使用 Master 权限操作
+	if className == "_Revision" && auth.IsMaster == false {
+		return errs.E(errs.OperationForbidden, "Clients aren't allowed to perform the "+method+" operation on the _Revision collection.")
+	}
+	// 文件元数据由服务器维护，只能使用 Master 权限操作
+	if className == "_File" && auth.IsMaster == false {
+		return errs.E(errs.OperationForbidden, "Clients aren't allowed to perform the "+method+" operation on the _File collection.")
+	}
+	// 邮件投递事件由服务商回调写入，只能使用 Master 权限操作
+	if className == "_EmailEvent" && auth.IsMaster == false {
+		return errs.E(errs.OperationForbidden, "Clients aren't allowed to perform the "+method+" operation on the _EmailEvent collection.")
+	}
+	// refreshToken 只能通过 /refreshSession 使用
+	if className == "_RefreshToken" && auth.IsMaster == false {
+		return errs.E(errs.OperationForbidden, "Clients aren't allowed to perform the "+method+" operation on the _RefreshToken collection.")
+	}
+	// 密钥使用记录由服务器维护，只能使用 Master 权限操作
+	if className == "_KeyUsage" && auth.IsMaster == false {
+		return errs.E(errs.OperationForbidden, "Clients aren't allowed to perform the "+method+" operation on the _KeyUsage collection.")
+	}
+	// 模拟登录的审计记录只能使用 Master 权限查询
+	if className == "_Impersonation" && auth.IsMaster == false {
+		return errs.E(errs.OperationForbidden, "Clients aren't allowed to perform the "+method+" operation on the _Impersonation collection.")
+	}
 	return nil
 }
 
@@ -170,3 +199,12 @@ func checkTriggers(className string, triggerTypes []string) bool {
 func checkLiveQuery(className string) bool {
 	return livequery.TLiveQuery != nil && livequery.TLiveQuery.HasLiveQuery(className)
 }
+
+// sanitizeEvent 复制发送给 LiveQueryServer 的对象，并删除内部字段与敏感字段
+// 原对象仍需传给回调函数，因此不能直接修改
+func sanitizeEvent(className string, object types.M) types.M {
+	if object == nil {
+		return nil
+	}
+	return orm.SanitizeObject(className, utils.CopyMap(object), false, "")
+}
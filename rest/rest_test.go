@@ -43,6 +43,24 @@ func Test_enforceRoleSecurity(t *testing.T) {
 	if reflect.DeepEqual(expect, err) == false {
 		t.Error("expect:", expect, "result:", err)
 	}
+	/********************************************************/
+	method = "create"
+	className = "_Audience"
+	auth = Nobody()
+	err = enforceRoleSecurity(method, className, auth)
+	expect = errs.E(errs.OperationForbidden, "Clients aren't allowed to perform the create operation on the _Audience collection.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/********************************************************/
+	method = "find"
+	className = "_Audience"
+	auth = Master()
+	err = enforceRoleSecurity(method, className, auth)
+	expect = nil
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
 }
 
 func Test_Find(t *testing.T) {
@@ -0,0 +1,59 @@
+package rest
+
+import (
+	"github.com/okobsamoht/talisman/logger"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// pendingRevision 更新前生成的修订记录，更新成功后保存
+type pendingRevision struct {
+	revision types.M
+	policy   orm.RevisionPolicy
+}
+
+// prepareRevision 类开启了修订记录时，在更新前读取对象的当前值，生成修订记录
+func (w *Write) prepareRevision() (*pendingRevision, error) {
+	if w.className == orm.RevisionClassName {
+		return nil, nil
+	}
+	policy, ok := orm.TalismanDBController.LoadSchema(nil).RevisionPolicy(w.className)
+	if ok == false {
+		return nil, nil
+	}
+	results, err := orm.TalismanDBController.Find(w.className, types.M{"objectId": w.objectID()}, types.M{})
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	revision := orm.NewRevision(w.className, utils.M(results[0]), w.data, w.editor())
+	if revision == nil {
+		return nil, nil
+	}
+	return &pendingRevision{revision: revision, policy: policy}, nil
+}
+
+// saveRevision 保存修订记录，出错时只记录日志，不影响本次更新
+func (w *Write) saveRevision(p *pendingRevision) {
+	if p == nil {
+		return
+	}
+	err := orm.TalismanDBController.SaveRevision(p.revision, p.policy)
+	if err != nil {
+		logger.Request(w.auth.RequestID).Error("save revision of", w.className, p.revision["targetId"], "failed:", err)
+	}
+}
+
+// editor 返回修改人：Master 权限为 master ，登录用户为用户的 objectId ，其他情况为空
+func (w *Write) editor() string {
+	if w.auth.IsMaster {
+		return "master"
+	}
+	if w.auth.User != nil {
+		return utils.S(w.auth.User["objectId"])
+	}
+	return ""
+}
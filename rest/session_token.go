@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"strings"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/jwt"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// NewSessionToken 为用户生成 sessionToken ，返回 token 与过期时间
+// SessionTokenType 为 JWT 时生成签名的 token ，其中 sub 为用户 id ，过期时间与 _Session 中的 expiresAt 相同
+func NewSessionToken(userID string) (string, time.Time) {
+	if config.TConfig.SessionTokenType != "JWT" {
+		return "r:" + utils.CreateToken(), config.GenerateSessionExpiresAt()
+	}
+
+	now := time.Now().UTC()
+	// JWT 中的时间精确到秒
+	expiresAt := time.Unix(config.GenerateJWTExpiresAt().Unix(), 0).UTC()
+	claims := types.M{
+		"sub": userID,
+		"jti": utils.CreateToken(),
+		"iat": now.Unix(),
+		"exp": expiresAt.Unix(),
+	}
+	kid, keys := jwtKeys()
+	return jwt.Sign(claims, kid, keys[kid]), expiresAt
+}
+
+// authForJWT 校验 JWT ，未开启 JWTRevocationCheck 时直接返回 token 中的用户
+// 开启时返回 nil ，由调用方继续查询 _Session 确认 token 未被注销
+func authForJWT(sessionToken, installationID string) (*Auth, error) {
+	_, keys := jwtKeys()
+	claims, err := jwt.Parse(sessionToken, keys, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if config.TConfig.JWTRevocationCheck {
+		return nil, nil
+	}
+	return &Auth{
+		IsMaster:       false,
+		InstallationID: installationID,
+		User: types.M{
+			"objectId":     utils.S(claims["sub"]),
+			"className":    "_User",
+			"sessionToken": sessionToken,
+		},
+	}, nil
+}
+
+// jwtKeys 解析配置中的签名密钥，返回用于签名的 kid 与全部密钥
+func jwtKeys() (string, map[string][]byte) {
+	signingKid := ""
+	keys := map[string][]byte{}
+	for i, key := range config.TConfig.JWTSigningKeys {
+		p := strings.Index(key, ":")
+		if p < 1 {
+			continue
+		}
+		if i == 0 {
+			signingKid = key[:p]
+		}
+		keys[key[:p]] = []byte(key[p+1:])
+	}
+	return signingKid, keys
+}
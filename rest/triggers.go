@@ -27,6 +27,7 @@ func getRequest(triggerType string, auth *Auth, parseObject, originalParseObject
 	if auth.InstallationID != "" {
 		request.InstallationID = auth.InstallationID
 	}
+	request.RequestID = auth.RequestID
 
 	return request
 }
@@ -56,6 +57,7 @@ func getRequestQuery(triggerType string, auth *Auth, query types.M, count bool)
 	if auth.InstallationID != "" {
 		request.InstallationID = auth.InstallationID
 	}
+	request.RequestID = auth.RequestID
 
 	return request
 }
@@ -147,6 +149,30 @@ func maybeRunAfterFindTrigger(triggerType, className string, objects types.S, au
 	return response.ResponseObjects, nil
 }
 
+// maybeRunFileTrigger 运行文件回调，回调可以修改 file 中的数据
+func maybeRunFileTrigger(triggerType string, file *cloud.File, auth *Auth) error {
+	trigger := cloud.GetFileTrigger(triggerType)
+	if trigger == nil {
+		return nil
+	}
+	request := cloud.FileTriggerRequest{
+		TriggerName: triggerType,
+		File:        file,
+		FileSize:    len(file.Data),
+	}
+	if auth != nil {
+		request.Master = auth.IsMaster
+		request.User = auth.User
+		request.InstallationID = auth.InstallationID
+		request.RequestID = auth.RequestID
+	}
+	response := &cloud.FileTriggerResponse{
+		Request: request,
+	}
+	trigger(request, response)
+	return response.Err
+}
+
 func inflate(data, restObject types.M) types.M {
 	result := types.M{}
 	if data != nil {
@@ -11,6 +11,9 @@ import (
 	"github.com/okobsamoht/talisman/errs"
 	"github.com/okobsamoht/talisman/mail"
 	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/publichtml"
+	"github.com/okobsamoht/talisman/sso"
+	"github.com/okobsamoht/talisman/throttle"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
 )
@@ -18,12 +21,26 @@ import (
 var adapter mail.Adapter
 
 func init() {
+	Init()
+}
+
+// Init 根据当前配置创建邮件发送模块，以及创建 session 时使用的锁
+func Init() {
 	a := config.TConfig.MailAdapter
 	if a == "smtp" {
 		adapter = mail.NewSMTPAdapter()
+	} else if a == "SendGrid" {
+		adapter = mail.NewSendGridAdapter()
+	} else if a == "Mailgun" {
+		adapter = mail.NewMailgunAdapter()
 	} else {
 		adapter = mail.NewSMTPAdapter()
 	}
+	if config.TConfig.CacheAdapter == "Redis" {
+		sessionLocks = throttle.NewRedisStore(config.TConfig.RedisAddress, config.TConfig.RedisPassword)
+	} else {
+		sessionLocks = throttle.NewMemoryStore()
+	}
 }
 
 // shouldVerifyEmails 根据配置参数确定是否需要验证邮箱
@@ -64,7 +81,9 @@ func SendVerificationEmail(user types.M) {
 		"link":    link,
 		"user":    user,
 	}
-	adapter.SendMail(defaultVerificationEmail(options))
+	email := defaultVerificationEmail(options)
+	applyEmailTemplates(email, publichtml.VerifyEmailSubjectTemplate, publichtml.VerifyEmailTextTemplate, publichtml.VerifyEmailHTMLTemplate)
+	adapter.SendMail(email)
 }
 
 // ResendVerificationEmail 重新发送验证邮件
@@ -135,9 +154,11 @@ func defaultVerificationEmail(options types.M) types.M {
 	to := utils.S(user["email"])
 	subject := "Please verify your e-mail for " + utils.S(options["appName"])
 	return types.M{
-		"text":    text,
-		"to":      to,
-		"subject": subject,
+		"text":         text,
+		"to":           to,
+		"subject":      subject,
+		"templateName": "verifyEmail",
+		"templateData": emailTemplateData(options),
 	}
 }
 
@@ -156,7 +177,9 @@ func SendPasswordResetEmail(email string) error {
 		"link":    link,
 		"user":    user,
 	}
-	adapter.SendMail(defaultResetPasswordEmail(options))
+	message := defaultResetPasswordEmail(options)
+	applyEmailTemplates(message, publichtml.PasswordResetSubjectTemplate, publichtml.PasswordResetTextTemplate, publichtml.PasswordResetHTMLTemplate)
+	adapter.SendMail(message)
 	return nil
 }
 
@@ -167,10 +190,10 @@ func setPasswordResetToken(email string) types.M {
 	where := types.M{
 		"$or": types.S{
 			types.M{
-				"email": email,
+				"email": config.NormalizeEmail(email),
 			},
 			types.M{
-				"username": email,
+				"username": config.NormalizeUsername(email),
 				"email": types.M{
 					"$exists": false,
 				},
@@ -210,9 +233,39 @@ func defaultResetPasswordEmail(options types.M) types.M {
 	}
 	subject := "Password Reset for " + utils.S(options["appName"])
 	return types.M{
-		"text":    text,
-		"to":      to,
-		"subject": subject,
+		"text":         text,
+		"to":           to,
+		"subject":      subject,
+		"templateName": "passwordReset",
+		"templateData": emailTemplateData(options),
+	}
+}
+
+// applyEmailTemplates 存在自定义模板时替换邮件的主题与纯文本内容， HTML 内容保存在 html 中
+func applyEmailTemplates(email types.M, subjectTemplate, textTemplate, htmlTemplate string) {
+	if email == nil {
+		return
+	}
+	data := types.M(utils.M(email["templateData"]))
+	if subject, ok := publichtml.Render(subjectTemplate, data); ok && subject != "" {
+		email["subject"] = subject
+	}
+	if text, ok := publichtml.Render(textTemplate, data); ok {
+		email["text"] = text
+	}
+	if html, ok := publichtml.Render(htmlTemplate, data); ok {
+		email["html"] = html
+	}
+}
+
+// emailTemplateData 邮件模板变量，包括 appName link username email
+func emailTemplateData(options types.M) types.M {
+	user := utils.M(options["user"])
+	return types.M{
+		"appName":  utils.S(options["appName"]),
+		"link":     utils.S(options["link"]),
+		"username": utils.S(user["username"]),
+		"email":    utils.S(user["email"]),
 	}
 }
 
@@ -333,3 +386,39 @@ func buildEmailLink(destination, username, token string) string {
 	}
 	return destination + `?` + usernameAndToken
 }
+
+// SSOLogin 使用单点登录的身份登录，用户不存在时创建用户，返回包含 sessionToken 的用户信息
+// 服务商确认邮箱已验证时保存邮箱，邮箱已被使用并且 linkByEmail 为 true 时关联该用户
+func SSOLogin(id, email string, emailVerified, linkByEmail bool) (types.M, error) {
+	// authData 在校验时会被替换为不包含 token 的数据，每次写入都需要重新生成
+	authData := func() types.M {
+		return types.M{"sso": types.M{"id": id, "token": sso.SignAssertion(id)}}
+	}
+	results, err := orm.TalismanDBController.Find("_User", types.M{"authData.sso.id": id}, types.M{"limit": 1})
+	if err != nil {
+		return nil, err
+	}
+	data := types.M{"authData": authData()}
+	if len(results) == 0 && email != "" && emailVerified {
+		email = config.NormalizeEmail(email)
+		users, err := orm.TalismanDBController.Find("_User", types.M{"email": email}, EmailLookupOptions(types.M{"limit": 1}))
+		if err != nil {
+			return nil, err
+		}
+		if len(users) == 0 {
+			data["email"] = email
+		} else if linkByEmail {
+			objectID := utils.S(utils.M(users[0])["objectId"])
+			_, err = Update(Master(), "_User", objectID, types.M{"authData": authData()}, nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	response, err := Create(Master(), "_User", data, nil)
+	if err != nil {
+		return nil, err
+	}
+	return utils.M(response["response"]), nil
+}
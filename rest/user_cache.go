@@ -0,0 +1,120 @@
+package rest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/okobsamoht/talisman/cache"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// userCache 缓存 sessionToken 对应的用户信息，以及用户对应的角色列表
+// 同时记录用户的所有 sessionToken ，用户信息变化时可以清除该用户的全部缓存
+type userCache struct {
+	mu sync.Mutex
+}
+
+var tUserCache = &userCache{}
+
+const userSessionsPrefix = "sessions:"
+
+// ttl 返回缓存有效期，不超过 session 的剩余有效期
+func (c *userCache) ttl(expiresAt time.Time) int64 {
+	ttl := int64(config.TConfig.UserCacheTTL)
+	if expiresAt.IsZero() {
+		return ttl
+	}
+	remain := int64(expiresAt.Sub(time.Now()) / time.Second)
+	if remain < 1 {
+		remain = 1
+	}
+	if ttl == 0 || ttl > remain {
+		return remain
+	}
+	return ttl
+}
+
+// getUser 获取 sessionToken 对应的用户
+func (c *userCache) getUser(sessionToken string) types.M {
+	return utils.M(cache.User.Get(sessionToken))
+}
+
+// putUser 缓存 sessionToken 对应的用户，并记录到该用户的 sessionToken 列表中
+func (c *userCache) putUser(sessionToken string, user types.M, expiresAt time.Time) {
+	cache.User.Put(sessionToken, user, c.ttl(expiresAt))
+
+	userID := utils.S(user["objectId"])
+	if userID == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tokens := c.sessionTokens(userID)
+	for _, token := range tokens {
+		if token == sessionToken {
+			return
+		}
+	}
+	tokens = append(tokens, sessionToken)
+	cache.User.Put(userSessionsPrefix+userID, tokens, int64(config.TConfig.UserCacheTTL))
+}
+
+// sessionTokens 获取用户已缓存的 sessionToken 列表
+func (c *userCache) sessionTokens(userID string) []string {
+	tokens := []string{}
+	for _, v := range utils.A(cache.User.Get(userSessionsPrefix + userID)) {
+		tokens = append(tokens, utils.S(v))
+	}
+	if v, ok := cache.User.Get(userSessionsPrefix + userID).([]string); ok {
+		tokens = append(tokens, v...)
+	}
+	return tokens
+}
+
+// delSession 清除 sessionToken 对应的缓存，用于 session 被删除或者修改时
+func (c *userCache) delSession(sessionToken string) {
+	if sessionToken == "" {
+		return
+	}
+	cache.User.Del(sessionToken)
+}
+
+// delUser 清除用户的全部 sessionToken 缓存以及角色缓存，用于用户被修改或者删除时
+func (c *userCache) delUser(userID string) {
+	if userID == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, token := range c.sessionTokens(userID) {
+		cache.User.Del(token)
+	}
+	cache.User.Del(userSessionsPrefix + userID)
+	cache.Role.Del(userID)
+}
+
+// getRoles 获取用户的角色列表，使用 Redis 缓存时取出的类型为 []interface{}
+func (c *userCache) getRoles(userID string) ([]string, bool) {
+	v := cache.Role.Get(userID)
+	if v == nil {
+		return nil, false
+	}
+	if roles, ok := v.([]string); ok {
+		return roles, true
+	}
+	if a := utils.A(v); a != nil {
+		roles := []string{}
+		for _, r := range a {
+			roles = append(roles, utils.S(r))
+		}
+		return roles, true
+	}
+	return nil, false
+}
+
+// putRoles 缓存用户的角色列表
+func (c *userCache) putRoles(userID string, roles []string) {
+	cache.Role.Put(userID, roles, int64(config.TConfig.UserCacheTTL))
+}
@@ -0,0 +1,56 @@
+package rest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/okobsamoht/talisman/cache"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_userCache(t *testing.T) {
+	cache.InitCache()
+	var user types.M
+	var result types.M
+	var roles []string
+	var ok bool
+	/*************************************************/
+	user = types.M{"objectId": "1001", "username": "joe"}
+	tUserCache.putUser("aaaaa", user, time.Now().Add(time.Hour))
+	tUserCache.putUser("bbbbb", user, time.Time{})
+	tUserCache.putRoles("1001", []string{"role:admin"})
+	result = tUserCache.getUser("aaaaa")
+	if reflect.DeepEqual(user, result) == false {
+		t.Error("expect:", user, "result:", result)
+	}
+	roles, ok = tUserCache.getRoles("1001")
+	if ok == false || reflect.DeepEqual([]string{"role:admin"}, roles) == false {
+		t.Error("expect:", []string{"role:admin"}, "result:", roles)
+	}
+	tUserCache.delSession("aaaaa")
+	if tUserCache.getUser("aaaaa") != nil {
+		t.Error("expect:", nil, "result:", tUserCache.getUser("aaaaa"))
+	}
+	if tUserCache.getUser("bbbbb") == nil {
+		t.Error("expect:", user, "result:", nil)
+	}
+	/*************************************************/
+	tUserCache.putUser("aaaaa", user, time.Time{})
+	tUserCache.delUser("1001")
+	if tUserCache.getUser("aaaaa") != nil {
+		t.Error("expect:", nil, "result:", tUserCache.getUser("aaaaa"))
+	}
+	if tUserCache.getUser("bbbbb") != nil {
+		t.Error("expect:", nil, "result:", tUserCache.getUser("bbbbb"))
+	}
+	if _, ok = tUserCache.getRoles("1001"); ok {
+		t.Error("expect:", false, "result:", ok)
+	}
+	/*************************************************/
+	cache.Role.Put("1002", []interface{}{"role:user"}, 0)
+	roles, ok = tUserCache.getRoles("1002")
+	if ok == false || reflect.DeepEqual([]string{"role:user"}, roles) == false {
+		t.Error("expect:", []string{"role:user"}, "result:", roles)
+	}
+}
@@ -266,9 +266,16 @@ func Test_defaultVerificationEmail(t *testing.T) {
 	text += " with talisman\n\n"
 	text += "Click here to confirm it:\nhttp://www.g.com"
 	expect = types.M{
-		"text":    text,
-		"to":      "123@g.com",
-		"subject": "Please verify your e-mail for talisman",
+		"text":         text,
+		"to":           "123@g.com",
+		"subject":      "Please verify your e-mail for talisman",
+		"templateName": "verifyEmail",
+		"templateData": types.M{
+			"appName":  "talisman",
+			"link":     "http://www.g.com",
+			"username": "",
+			"email":    "123@g.com",
+		},
 	}
 	if reflect.DeepEqual(expect, result) == false {
 		t.Error("expect:", expect, "result:", result)
@@ -420,9 +427,16 @@ func Test_defaultResetPasswordEmail(t *testing.T) {
 	text += "You requested to reset your password for talisman\n\n"
 	text += "Click here to reset it:\nhttp://www.g.com"
 	expect = types.M{
-		"text":    text,
-		"to":      "123@g.com",
-		"subject": "Password Reset for talisman",
+		"text":         text,
+		"to":           "123@g.com",
+		"subject":      "Password Reset for talisman",
+		"templateName": "passwordReset",
+		"templateData": types.M{
+			"appName":  "talisman",
+			"link":     "http://www.g.com",
+			"username": "",
+			"email":    "123@g.com",
+		},
 	}
 	if reflect.DeepEqual(expect, result) == false {
 		t.Error("expect:", expect, "result:", result)
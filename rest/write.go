@@ -3,11 +3,13 @@ package rest
 import (
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"strconv"
 
+	"github.com/okobsamoht/talisman/audiences"
 	am "github.com/okobsamoht/talisman/auth"
 	"github.com/okobsamoht/talisman/cache"
 	"github.com/okobsamoht/talisman/client"
@@ -15,8 +17,11 @@ import (
 	"github.com/okobsamoht/talisman/config"
 	"github.com/okobsamoht/talisman/errs"
 	"github.com/okobsamoht/talisman/files"
+	"github.com/okobsamoht/talisman/installations"
 	"github.com/okobsamoht/talisman/livequery"
+	"github.com/okobsamoht/talisman/logger"
 	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/signup"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
 )
@@ -28,6 +33,7 @@ type Write struct {
 	query                      types.M
 	data                       types.M
 	originalData               types.M
+	requestData                types.M // 客户端提交的数据，用于计算服务端修改过的字段
 	storage                    types.M
 	RunOptions                 types.M
 	response                   types.M
@@ -72,6 +78,7 @@ func NewWrite(
 		query:                      queryCopy,
 		data:                       utils.CopyMap(data),
 		originalData:               originalData,
+		requestData:                utils.CopyMap(data),
 		storage:                    types.M{},
 		RunOptions:                 types.M{},
 		response:                   nil,
@@ -96,6 +103,10 @@ func (w *Write) Execute() (types.M, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = w.handleAudience()
+	if err != nil {
+		return nil, err
+	}
 	err = w.handleSession()
 	if err != nil {
 		return nil, err
@@ -104,6 +115,10 @@ func (w *Write) Execute() (types.M, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = w.applyCreationDefaults()
+	if err != nil {
+		return nil, err
+	}
 	err = w.runBeforeTrigger()
 	if err != nil {
 		return nil, err
@@ -124,7 +139,12 @@ func (w *Write) Execute() (types.M, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = w.enforceSessionLimit()
+	if err != nil {
+		return nil, err
+	}
 	err = w.runDatabaseOperation()
+	w.unlockSessionLimit()
 	if err != nil {
 		return nil, err
 	}
@@ -183,11 +203,37 @@ func (w *Write) validateClientClassCreation() error {
 	return errs.E(errs.OperationForbidden, "This user is not allowed to access non-existent class: "+w.className)
 }
 
+// applyCreationDefaults 创建对象时写入类中设置的默认 ACL 、创建者与字段默认值
+func (w *Write) applyCreationDefaults() error {
+	if w.response != nil || w.query != nil {
+		return nil
+	}
+	defaults := orm.TalismanDBController.LoadSchema(nil).CreationDefaults(w.className)
+	if defaults == nil {
+		return nil
+	}
+	userID := ""
+	if w.auth.User != nil {
+		userID = utils.S(w.auth.User["objectId"])
+	}
+	// 记录写入了默认值的字段，不使用 Master Key 时也可以写入其中只有 Master 可以写入的字段
+	w.RunOptions["defaultFields"] = orm.ApplyCreationDefaults(defaults, w.data, userID)
+	return nil
+}
+
 // validateSchema 校验数据与权限是否允许进行当前操作
 func (w *Write) validateSchema() error {
 	return orm.TalismanDBController.ValidateObject(w.className, w.data, w.query, w.RunOptions)
 }
 
+// handleAudience 处理 _Audience 表的操作，校验受众名称与查询条件
+func (w *Write) handleAudience() error {
+	if w.response != nil || w.className != "_Audience" {
+		return nil
+	}
+	return audiences.ValidateAudience(w.data, w.query == nil)
+}
+
 // handleInstallation 处理 _Installation 表的操作
 // 新增安装记录时，必须要有设备标识： deviceToken 或者 installationId ，必须要有设备类型 deviceType
 // 更新安装记录时，不能更新 installationId deviceToken deviceType 三个字段
@@ -196,6 +242,14 @@ func (w *Write) handleInstallation() error {
 		return nil
 	}
 
+	// 校验 channels 与 badge 的数据以及操作
+	if err := installations.ValidateChannels(w.data["channels"]); err != nil {
+		return err
+	}
+	if err := installations.ValidateBadge(w.data["badge"]); err != nil {
+		return err
+	}
+
 	if w.query == nil && w.data["deviceToken"] == nil && w.data["installationId"] == nil && w.auth.InstallationID == "" {
 		// create 操作时，设备 id 不能为空
 		return errs.E(errs.MissingRequiredFieldError, "at least one ID field (deviceToken, installationId) must be specified in this operation")
@@ -410,7 +464,6 @@ func (w *Write) handleInstallation() error {
 		delete(w.data, "objectId")
 		delete(w.data, "createdAt")
 	}
-	// TODO Validate ops (add/remove on channels, $inc on badge, etc.)
 
 	return nil
 }
@@ -429,11 +482,24 @@ func (w *Write) handleSession() error {
 		return errs.E(errs.InvalidKeyName, "Cannot set ACL on a Session.")
 	}
 
+	// 最后使用时间、 IP 与模拟登录信息由服务器记录
+	if w.auth.IsMaster == false {
+		for _, key := range []string{"lastUsedAt", "ipAddress", "impersonated", "impersonation"} {
+			if w.data[key] != nil {
+				return errs.E(errs.InvalidKeyName, "Cannot set "+key+" on a Session.")
+			}
+		}
+	}
+
+	// 修改 session 时，清除对应的用户缓存
+	if w.query != nil && w.originalData != nil {
+		tUserCache.delSession(utils.S(w.originalData["sessionToken"]))
+	}
+
 	// 当前为 create 请求，并且不是 Master 权限时
 	if w.query == nil && w.auth.IsMaster == false {
-		// 生成 token ，过期时间为 1 年
-		token := "r:" + utils.CreateToken()
-		expiresAt := config.GenerateSessionExpiresAt()
+		// 生成 token ，过期时间由 SessionLength 决定
+		token, expiresAt := NewSessionToken(utils.S(w.auth.User["objectId"]))
 		user := types.M{
 			"__type":    "Pointer",
 			"className": "_User",
@@ -483,6 +549,59 @@ func (w *Write) handleSession() error {
 	return nil
 }
 
+// enforceSessionLimit 新建 session 之前检查用户有效的 session 数量是否达到 MaxSessionsPerUser
+// 从检查到写入完成期间持有该用户的锁，并发登录时依次处理：reject 返回错误， evictOldest 注销最早的 session
+func (w *Write) enforceSessionLimit() error {
+	limit := config.TConfig.MaxSessionsPerUser
+	if limit <= 0 || w.className != "_Session" || w.query != nil || w.response != nil {
+		return nil
+	}
+	// 模拟登录的 session 不占用用户的名额，也不会注销用户自己的 session
+	if isImpersonationSession(w.data) {
+		return nil
+	}
+	userID := utils.S(utils.M(w.data["user"])["objectId"])
+	if userID == "" {
+		return nil
+	}
+	err := lockUserSessions(userID)
+	if err != nil {
+		return err
+	}
+	w.storage["sessionLimitLock"] = userID
+
+	sessions, err := activeSessions(userID)
+	if err != nil {
+		w.unlockSessionLimit()
+		return err
+	}
+	if len(sessions) < limit {
+		return nil
+	}
+	if config.TConfig.SessionLimitPolicy == "reject" {
+		w.unlockSessionLimit()
+		return errs.E(errs.OperationForbidden, "Too many active sessions for this user.")
+	}
+	tokens := []string{}
+	for _, session := range sessions[:len(sessions)-limit+1] {
+		tokens = append(tokens, utils.S(session["sessionToken"]))
+	}
+	err = revokeSessions(tokens)
+	if err != nil {
+		w.unlockSessionLimit()
+		return err
+	}
+	return nil
+}
+
+// unlockSessionLimit 释放 enforceSessionLimit 中获取的锁
+func (w *Write) unlockSessionLimit() {
+	if userID, ok := w.storage["sessionLimitLock"].(string); ok {
+		delete(w.storage, "sessionLimitLock")
+		unlockUserSessions(userID)
+	}
+}
+
 // validateAuthData 校验用户登录数据，仅处理对 _User 表的操作
 func (w *Write) validateAuthData() error {
 	if w.className != "_User" {
@@ -524,6 +643,11 @@ func (w *Write) validateAuthData() error {
 
 // handleAuthData 处理第三方登录数据
 func (w *Write) handleAuthData(authData types.M) error {
+	// 一次性凭证在查找用户之前校验，已登录过的用户同样需要校验
+	err := w.handleOneTimeAuthData(authData)
+	if err != nil {
+		return err
+	}
 	results, err := w.findUsersWithAuthData(authData)
 	if err != nil {
 		return err
@@ -607,10 +731,29 @@ func (w *Write) handleAuthData(authData types.M) error {
 	return w.handleAuthDataValidation(authData)
 }
 
-// handleAuthDataValidation 校验第三方登录数据
+// handleOneTimeAuthData 校验使用一次性凭证的登录数据，如手机验证码，并替换为不包含凭证的数据保存
+func (w *Write) handleOneTimeAuthData(authData types.M) error {
+	for k, v := range authData {
+		if v == nil || am.IsOneTime(k) == false {
+			continue
+		}
+		err := am.ValidateAuthData(k, utils.M(v))
+		if err != nil {
+			return err
+		}
+		// LDAP 校验之后补充的邮箱与用户组不保存在 authData 中，用于创建用户与同步角色
+		if k == "ldap" {
+			w.storage["ldapUser"] = utils.M(v)
+		}
+		authData[k] = am.StoredAuthData(k, utils.M(v))
+	}
+	return nil
+}
+
+// handleAuthDataValidation 校验第三方登录数据，一次性凭证已在 handleOneTimeAuthData 中校验
 func (w *Write) handleAuthDataValidation(authData types.M) error {
 	for k, v := range authData {
-		if v == nil {
+		if v == nil || am.IsOneTime(k) {
 			continue
 		}
 		err := am.ValidateAuthData(k, utils.M(v))
@@ -736,10 +879,59 @@ func (w *Write) transformUser() error {
 		if _, ok := w.data["emailVerified"]; ok {
 			return errs.E(errs.OperationForbidden, "Clients aren't allowed to manually update email verification.")
 		}
+		if _, ok := w.data["phoneVerified"]; ok {
+			return errs.E(errs.OperationForbidden, "Clients aren't allowed to manually update phone verification.")
+		}
+		if _, ok := w.data["signupFlags"]; ok {
+			return errs.E(errs.OperationForbidden, "Clients aren't allowed to manually update signupFlags.")
+		}
+	}
+
+	// 通过手机验证码登录或者绑定时保存已验证的手机号，直接修改手机号时需要重新验证
+	if phone := utils.M(utils.M(w.data["authData"])["phone"]); phone != nil {
+		w.data["phone"] = phone["id"]
+		w.data["phoneVerified"] = true
+	} else if _, ok := w.data["phone"]; ok && w.auth.IsMaster == false {
+		w.data["phoneVerified"] = false
+	}
+
+	// LDAP 用户首次登录时，使用 LDAP 中的用户名与邮箱创建用户
+	if ldapUser := utils.M(w.storage["ldapUser"]); ldapUser != nil && w.query == nil && w.response == nil {
+		if w.data["username"] == nil {
+			w.data["username"] = ldapUser["id"]
+		}
+		if w.data["email"] == nil && utils.S(ldapUser["email"]) != "" {
+			w.data["email"] = ldapUser["email"]
+		}
+	}
+
+	// 按配置规范化用户名与邮箱，之后的唯一性检测使用规范化之后的值
+	if username, ok := w.data["username"].(string); ok {
+		w.data["username"] = config.NormalizeUsername(username)
+	}
+	if email, ok := w.data["email"].(string); ok {
+		w.data["email"] = config.NormalizeEmail(email)
+	}
+
+	// 客户端注册时校验注册数据，按规则拒绝注册或者记录标记
+	if w.query == nil && w.auth.IsMaster == false {
+		flags, err := signup.Validate(utils.CopyMapM(w.data))
+		if err != nil {
+			return err
+		}
+		if len(flags) > 0 {
+			signupFlags := types.S{}
+			for _, flag := range flags {
+				signupFlags = append(signupFlags, flag)
+			}
+			w.data["signupFlags"] = signupFlags
+		}
 	}
 
 	// 如果是正在更新 _User ，则清除相应用户的 session 缓存
+	// 已记录的 sessionToken 直接清除，未记录的从 _Session 中查询
 	if w.query != nil {
+		tUserCache.delUser(utils.S(w.objectID()))
 		where := types.M{
 			"user": types.M{
 				"__type":    "Pointer",
@@ -760,7 +952,7 @@ func (w *Write) transformUser() error {
 			results := utils.A(response["results"])
 			for _, result := range results {
 				session := utils.M(result)
-				cache.User.Del(utils.S(session["sessionToken"]))
+				tUserCache.delSession(utils.S(session["sessionToken"]))
 			}
 		}
 	}
@@ -817,9 +1009,9 @@ func (w *Write) validateUserName() error {
 		"username": w.data["username"],
 		"objectId": objectID,
 	}
-	option := types.M{
+	option := UsernameLookupOptions(types.M{
 		"limit": 1,
-	}
+	})
 	results, err := orm.TalismanDBController.Find(w.className, where, option)
 	if err != nil {
 		return err
@@ -852,9 +1044,9 @@ func (w *Write) validateEmail() error {
 		"email":    w.data["email"],
 		"objectId": objectID,
 	}
-	option := types.M{
+	option := EmailLookupOptions(types.M{
 		"limit": 1,
-	}
+	})
 	results, err := orm.TalismanDBController.Find(w.className, where, option)
 	if err != nil {
 		return err
@@ -1039,11 +1231,21 @@ func (w *Write) runDatabaseOperation() error {
 			}
 			w.data["_password_history"] = oldPasswords
 		}
-		// 执行更新
-		response, err := orm.TalismanDBController.Update(w.className, w.query, w.data, w.RunOptions, false)
+		// 执行更新，服务端修改过的字段从更新结果中返回，保证客户端与数据库一致
+		options := types.M{}
+		for k, v := range w.RunOptions {
+			options[k] = v
+		}
+		options["computedFields"] = w.computedFields()
+		revision, err := w.prepareRevision()
+		if err != nil {
+			return err
+		}
+		response, err := orm.TalismanDBController.Update(w.className, w.query, w.data, options, false)
 		if err != nil {
 			return err
 		}
+		w.saveRevision(revision)
 		response["updatedAt"] = w.updatedAt
 
 		// 如果回调函数修改过数据，把 w.data 中存在但 response 中不存在的字段复制到返回结果中
@@ -1149,8 +1351,7 @@ func (w *Write) createSessionTokenIfNeeded() error {
 
 // createSessionToken 创建 Token
 func (w *Write) createSessionToken() error {
-	token := "r:" + utils.CreateToken()
-	expiresAt := config.GenerateSessionExpiresAt()
+	token, expiresAt := NewSessionToken(utils.S(w.objectID()))
 	user := types.M{
 		"__type":    "Pointer",
 		"className": "_User",
@@ -1188,8 +1389,23 @@ func (w *Write) createSessionToken() error {
 		return err
 	}
 	_, err = create.Execute()
+	if err != nil {
+		return err
+	}
+
+	if config.TConfig.RefreshTokens {
+		refreshToken, err := NewRefreshToken(utils.S(w.objectID()), token, "")
+		if err != nil {
+			return err
+		}
+		if w.response != nil {
+			if r := utils.M(w.response["response"]); r != nil {
+				r["refreshToken"] = refreshToken
+			}
+		}
+	}
 
-	return err
+	return nil
 }
 
 // handleFollowup 处理后续逻辑
@@ -1209,6 +1425,12 @@ func (w *Write) handleFollowup() error {
 		if err != nil {
 			return err
 		}
+		if config.TConfig.RefreshTokens {
+			err = RevokeRefreshTokens(sessionQuery)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	if w.storage != nil && w.storage["generateNewSession"] != nil {
@@ -1219,6 +1441,18 @@ func (w *Write) handleFollowup() error {
 		}
 	}
 
+	if w.storage != nil && w.storage["ldapUser"] != nil {
+		// LDAP 登录之后按用户组同步角色
+		ldapUser := utils.M(w.storage["ldapUser"])
+		delete(w.storage, "ldapUser")
+		groups, _ := ldapUser["groups"].([]string)
+		roles, managed := am.LDAPRoles(groups)
+		err := SyncRoles(utils.S(w.objectID()), roles, managed)
+		if err != nil {
+			return err
+		}
+	}
+
 	if w.storage != nil && w.storage["sendVerificationEmail"] != nil {
 		// 修改邮箱之后需要发送验证邮件
 		delete(w.storage, "sendVerificationEmail")
@@ -1228,6 +1462,68 @@ func (w *Write) handleFollowup() error {
 	return nil
 }
 
+// SyncRoles 按外部身份源同步用户的角色，把用户加入 roles ，并从 managed 中其余的角色里移除
+// managed 之外的角色不受影响，不存在的角色不会自动创建
+func SyncRoles(userID string, roles, managed []string) error {
+	if userID == "" || len(managed) == 0 {
+		return nil
+	}
+	user := types.M{
+		"__type":    "Pointer",
+		"className": "_User",
+		"objectId":  userID,
+	}
+	all, err := Find(Master(), "_Role", types.M{"name": types.M{"$in": managed}}, types.M{}, nil)
+	if err != nil {
+		return err
+	}
+	joined, err := Find(Master(), "_Role", types.M{"name": types.M{"$in": managed}, "users": user}, types.M{}, nil)
+	if err != nil {
+		return err
+	}
+	isMember := map[string]bool{}
+	for _, r := range utils.A(joined["results"]) {
+		isMember[utils.S(utils.M(r)["objectId"])] = true
+	}
+	found := map[string]bool{}
+	for _, r := range utils.A(all["results"]) {
+		role := utils.M(r)
+		name := utils.S(role["name"])
+		objectID := utils.S(role["objectId"])
+		found[name] = true
+		op := ""
+		if containsString(roles, name) {
+			if isMember[objectID] == false {
+				op = "AddRelation"
+			}
+		} else if isMember[objectID] {
+			op = "RemoveRelation"
+		}
+		if op == "" {
+			continue
+		}
+		_, err := Update(Master(), "_Role", objectID, types.M{"users": types.M{"__op": op, "objects": types.S{user}}}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range roles {
+		if found[name] == false {
+			logger.Warn("role", name, "not found")
+		}
+	}
+	return nil
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
 // runAfterTrigger 运行数据修改后的回调函数
 func (w *Write) runAfterTrigger() error {
 	if w.response == nil || w.response["response"] == nil {
@@ -1260,8 +1556,8 @@ func (w *Write) runAfterTrigger() error {
 	}
 
 	if hasLiveQuery {
-		// 尝试通知 LiveQueryServer
-		livequery.TLiveQuery.OnAfterSave(w.className, updatedObject, originalObject)
+		// 尝试通知 LiveQueryServer ，订阅者各不相同，按无用户的请求删除敏感字段
+		livequery.TLiveQuery.OnAfterSave(w.className, sanitizeEvent(w.className, updatedObject), sanitizeEvent(w.className, originalObject))
 	}
 
 	if hasAfterSaveHook {
@@ -1327,6 +1623,33 @@ func (w *Write) cleanUserAuthData() {
 	}
 }
 
+// computedFieldsExcluded 不返回给客户端的字段
+var computedFieldsExcluded = map[string]bool{
+	"objectId":     true,
+	"createdAt":    true,
+	"updatedAt":    true,
+	"password":     true,
+	"authData":     true,
+	"sessionToken": true,
+}
+
+// computedFields 返回服务端修改过的字段，包括 beforeSave 修改的字段、服务端规范化的字段以及服务端添加的字段
+// 以 _ 开头的内部字段不返回
+func (w *Write) computedFields() []string {
+	fields := []string{}
+	for k, v := range w.data {
+		if strings.HasPrefix(k, "_") || computedFieldsExcluded[k] {
+			continue
+		}
+		if old, ok := w.requestData[k]; ok && reflect.DeepEqual(old, v) {
+			continue
+		}
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
 func (w *Write) updateResponseWithData(response, data types.M) types.M {
 	if w.storage["fieldsChangedByTrigger"] == nil {
 		return response
@@ -2,6 +2,8 @@ package rest
 
 import (
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -243,6 +245,56 @@ func Test_validateClientClassCreation_Write(t *testing.T) {
 	// 测试用例与 query.validateClientClassCreation 相同
 }
 
+func Test_applyCreationDefaults(t *testing.T) {
+	var w *Write
+	var err error
+	var expect error
+	var results types.S
+	auth := &Auth{IsMaster: false, User: types.M{"objectId": "u1"}}
+	/***************************************************************/
+	// 默认值写入的字段在 masterOnlyFields 中时，不使用 Master Key 也可以创建对象
+	initEnv()
+	livequery.TLiveQuery = livequery.NewLiveQuery([]string{}, "", "", "")
+	schema := orm.TalismanDBController.LoadSchema(nil)
+	_, err = schema.AddClassIfNotExists("post", types.M{
+		"title":   types.M{"type": "String"},
+		"credits": types.M{"type": "Number"},
+	}, types.M{
+		"get":      types.M{"*": true},
+		"find":     types.M{"*": true},
+		"create":   types.M{"*": true},
+		"update":   types.M{"*": true},
+		"delete":   types.M{"*": true},
+		"addField": types.M{},
+		"defaults": types.M{"values": types.M{"credits": 0}},
+	})
+	if err != nil {
+		t.Fatal("expect:", nil, "result:", err)
+	}
+	_, err = schema.SetMasterOnlyFields("post", []string{"credits"})
+	if err != nil {
+		t.Fatal("expect:", nil, "result:", err)
+	}
+	w, _ = NewWrite(auth, "post", nil, types.M{"title": "hello"}, nil, nil)
+	_, err = w.Execute()
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	results, _ = orm.TalismanDBController.Find("post", types.M{"title": "hello"}, types.M{})
+	if len(results) != 1 || utils.M(results[0])["credits"] != 0 {
+		t.Error("expect:", "credits 0", "result:", results)
+	}
+	/***************************************************************/
+	// 客户端提交的字段仍然需要 Master 权限
+	w, _ = NewWrite(auth, "post", nil, types.M{"title": "hi", "credits": 100}, nil, nil)
+	_, err = w.Execute()
+	expect = errs.E(errs.OperationForbidden, "credits can only be set with the master key.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	orm.TalismanDBController.DeleteEverything()
+}
+
 func Test_validateSchema(t *testing.T) {
 	// 测试用例与 DBController.ValidateObject 相同
 }
@@ -2639,6 +2691,108 @@ func Test_createSessionToken(t *testing.T) {
 	orm.TalismanDBController.DeleteEverything()
 }
 
+func Test_enforceSessionLimit(t *testing.T) {
+	var err error
+	var expect error
+	var results types.S
+	newSession := func(token string) error {
+		data := types.M{
+			"sessionToken": token,
+			"user": types.M{
+				"__type":    "Pointer",
+				"className": "_User",
+				"objectId":  "1001",
+			},
+			"expiresAt": utils.DateJSON(time.Now().Add(time.Hour)),
+		}
+		w, err := NewWrite(Master(), "_Session", nil, data, types.M{}, nil)
+		if err != nil {
+			return err
+		}
+		_, err = w.Execute()
+		return err
+	}
+	/***************************************************************/
+	initEnv()
+	livequery.TLiveQuery = livequery.NewLiveQuery([]string{}, "", "", "")
+	config.TConfig.MaxSessionsPerUser = 2
+	config.TConfig.SessionLimitPolicy = "evictOldest"
+	newSession("r:1")
+	newSession("r:2")
+	err = newSession("r:3")
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	results, _ = orm.TalismanDBController.Find("_Session", types.M{}, types.M{})
+	if len(results) != 2 {
+		t.Error("expect:", "len 2", "result:", results)
+	}
+	results, _ = orm.TalismanDBController.Find("_Session", types.M{"sessionToken": "r:1"}, types.M{})
+	if len(results) != 0 {
+		t.Error("expect:", "r:1 evicted", "result:", results)
+	}
+	orm.TalismanDBController.DeleteEverything()
+	/***************************************************************/
+	initEnv()
+	config.TConfig.SessionLimitPolicy = "reject"
+	newSession("r:1")
+	newSession("r:2")
+	err = newSession("r:3")
+	expect = errs.E(errs.OperationForbidden, "Too many active sessions for this user.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	results, _ = orm.TalismanDBController.Find("_Session", types.M{"sessionToken": "r:3"}, types.M{})
+	if len(results) != 0 {
+		t.Error("expect:", "r:3 not created", "result:", results)
+	}
+	orm.TalismanDBController.DeleteEverything()
+	/***************************************************************/
+	// 并发登录时 evictOldest 最终只保留最新的 session
+	initEnv()
+	config.TConfig.MaxSessionsPerUser = 3
+	config.TConfig.SessionLimitPolicy = "evictOldest"
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			newSession("r:" + strconv.Itoa(i))
+		}(i)
+	}
+	wg.Wait()
+	results, _ = orm.TalismanDBController.Find("_Session", types.M{}, types.M{})
+	if len(results) != 3 {
+		t.Error("expect:", "len 3", "result:", len(results))
+	}
+	orm.TalismanDBController.DeleteEverything()
+	/***************************************************************/
+	// 并发登录时 reject 只接受到达上限之前的请求
+	initEnv()
+	config.TConfig.SessionLimitPolicy = "reject"
+	newSession("r:old")
+	var mu sync.Mutex
+	success := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if newSession("r:"+strconv.Itoa(i)) == nil {
+				mu.Lock()
+				success++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	results, _ = orm.TalismanDBController.Find("_Session", types.M{}, types.M{})
+	if len(results) != 3 || success != 2 {
+		t.Error("expect:", "3 sessions 2 success", "result:", len(results), success)
+	}
+	orm.TalismanDBController.DeleteEverything()
+	config.TConfig.MaxSessionsPerUser = 0
+}
+
 func Test_location(t *testing.T) {
 	var w *Write
 	var query types.M
@@ -37,11 +37,21 @@ func init() {
 				&controllers.VerificationController{},
 			),
 		),
+		beego.NSNamespace("/phoneVerificationRequest",
+			beego.NSInclude(
+				&controllers.PhoneVerificationController{},
+			),
+		),
 		beego.NSNamespace("/sessions",
 			beego.NSInclude(
 				&controllers.SessionsController{},
 			),
 		),
+		beego.NSNamespace("/devices",
+			beego.NSInclude(
+				&controllers.DevicesController{},
+			),
+		),
 		beego.NSNamespace("/roles",
 			beego.NSInclude(
 				&controllers.RolesController{},
@@ -62,6 +72,11 @@ func init() {
 				&controllers.PushController{},
 			),
 		),
+		beego.NSNamespace("/push_audiences",
+			beego.NSInclude(
+				&controllers.PushAudiencesController{},
+			),
+		),
 		beego.NSNamespace("/installations",
 			beego.NSInclude(
 				&controllers.InstallationsController{},
@@ -82,6 +97,11 @@ func init() {
 				&controllers.SchemasController{},
 			),
 		),
+		beego.NSNamespace("/schema_permissions",
+			beego.NSInclude(
+				&controllers.SchemaPermissionsController{},
+			),
+		),
 		beego.NSNamespace("/apps",
 			beego.NSInclude(
 				&controllers.PublicController{},
@@ -132,11 +152,76 @@ func init() {
 				&controllers.UpgradeSessionController{},
 			),
 		),
+		beego.NSNamespace("/refreshSession",
+			beego.NSInclude(
+				&controllers.RefreshSessionController{},
+			),
+		),
+		beego.NSNamespace("/stats",
+			beego.NSInclude(
+				&controllers.StatsController{},
+			),
+		),
+		beego.NSNamespace("/serverTime",
+			beego.NSInclude(
+				&controllers.ServerTimeController{},
+			),
+		),
 		beego.NSNamespace("/health",
 			beego.NSInclude(
 				&controllers.HealthController{},
 			),
 		),
+		beego.NSNamespace("/export",
+			beego.NSInclude(
+				&controllers.ExportController{},
+			),
+		),
+		beego.NSNamespace("/privacy",
+			beego.NSInclude(
+				&controllers.PrivacyController{},
+			),
+		),
+		beego.NSNamespace("/mergeUsers",
+			beego.NSInclude(
+				&controllers.MergeUsersController{},
+			),
+		),
+		beego.NSNamespace("/impersonate",
+			beego.NSInclude(
+				&controllers.ImpersonateController{},
+			),
+		),
+		beego.NSNamespace("/userConflicts",
+			beego.NSInclude(
+				&controllers.UserConflictsController{},
+			),
+		),
+		beego.NSNamespace("/revisions",
+			beego.NSInclude(
+				&controllers.RevisionsController{},
+			),
+		),
+		beego.NSNamespace("/keys",
+			beego.NSInclude(
+				&controllers.KeysController{},
+			),
+		),
+		beego.NSNamespace("/schemaCache",
+			beego.NSInclude(
+				&controllers.SchemaCacheController{},
+			),
+		),
+		beego.NSNamespace("/mail/events",
+			beego.NSInclude(
+				&controllers.MailEventsController{},
+			),
+		),
+		beego.NSNamespace("/sso",
+			beego.NSInclude(
+				&controllers.SSOController{},
+			),
+		),
 	)
 	beego.AddNamespace(ns)
 }
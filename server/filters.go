@@ -0,0 +1,129 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/context"
+	"github.com/astaxie/beego/plugins/cors"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/logger"
+	"github.com/okobsamoht/talisman/quota"
+	"github.com/okobsamoht/talisman/throttle"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+func allowCrossDomain() {
+	beego.InsertFilter("*", beego.BeforeRouter, cors.Allow(&cors.Options{
+		AllowAllOrigins: true,
+		AllowMethods:    []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders: []string{"Origin", "Authorization", "Access-Control-Allow-Origin",
+			"Access-Control-Allow-Headers", "X-Parse-Master-Key", "X-Parse-REST-API-Key",
+			"X-Parse-Javascript-Key", "X-Parse-Application-Id", "X-Parse-Client-Version", "X-Parse-Session-Token",
+			"X-Parse-Client-Key", "X-Parse-Windows-Key", "X-Parse-Installation-Id", "X-Parse-Device-Name",
+			"X-Parse-Captcha-Token", "X-Requested-With", "X-Parse-Revocable-Session", "Content-Type", "X-Request-Id",
+			"Range", "If-Range", "If-None-Match", "If-Modified-Since"},
+		ExposeHeaders:    []string{"X-Request-Id", "Content-Range", "Accept-Ranges", "Content-Length", "ETag"},
+		AllowCredentials: true,
+	}))
+	beego.InsertFilter("*", beego.BeforeRouter, func(ctx *context.Context) {
+		if ctx.Input.Method() == "OPTIONS" {
+			ctx.Output.SetStatus(200)
+			ctx.ResponseWriter.Started = true
+		}
+	})
+}
+
+// assignRequestID 为每个请求分配请求 ID ，保存在 ctx 的 requestId 中，并通过 X-Request-Id 响应头返回
+// 客户端或者前置代理传入合法的 X-Request-Id 时沿用该 ID
+func assignRequestID() {
+	beego.InsertFilter("*", beego.BeforeRouter, func(ctx *context.Context) {
+		id := ctx.Input.Header("X-Request-Id")
+		if utils.IsRequestID(id) == false {
+			id = utils.CreateRequestID()
+		}
+		ctx.Input.SetData("requestId", id)
+		ctx.Output.Header("X-Request-Id", id)
+	})
+}
+
+// throttleLogin 登录失败次数超过阈值时拒绝登录请求
+func throttleLogin() {
+	if throttle.Login == nil {
+		return
+	}
+	beego.InsertFilter("/v1/login", beego.BeforeRouter, throttle.Login.Filter())
+}
+
+// accountRequests 统计请求次数与传输字节数，超过配额时拒绝请求
+func accountRequests() {
+	if quota.Default == nil {
+		return
+	}
+	before, after := quota.Default.Filters()
+	beego.InsertFilter("/v1/*", beego.BeforeRouter, before)
+	beego.InsertFilter("/v1/*", beego.FinishRouter, after, false)
+}
+
+// reloadOnSignal 收到 SIGHUP 时重新加载配置，失败时继续使用当前配置
+// 会替换整个进程的 SIGHUP 处理，只在设置了 ReloadOnSignal 时安装
+func reloadOnSignal() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	go func() {
+		for range c {
+			if err := config.Reload(); err != nil {
+				logger.Error("Reload config failed:", err)
+				continue
+			}
+			logger.Info("Config reloaded")
+		}
+	}()
+}
+
+// allowMethodOverride 兼容 Parse SDK 的请求方式
+// JS SDK 为避免跨域预检，统一使用 POST 发送请求，实际的请求方法保存在 body 的 _method 中
+// Android SDK 发送 GET 请求时，查询参数以 JSON 格式保存在 body 中， beego 默认不读取 GET 请求的 body
+func allowMethodOverride() {
+	beego.InsertFilter("*", beego.BeforeRouter, func(ctx *context.Context) {
+		switch ctx.Input.Method() {
+		case "GET":
+			if ctx.Input.RequestBody == nil && ctx.Request.ContentLength != 0 && ctx.Request.Body != nil {
+				ctx.Input.CopyBody(beego.BConfig.MaxMemory)
+			}
+		case "POST":
+			contentType := ctx.Input.Header("Content-type")
+			if strings.HasPrefix(contentType, "text/plain") == false &&
+				strings.HasPrefix(contentType, "application/json") == false {
+				return
+			}
+			if method := methodOverride(ctx.Input.RequestBody); method != "" {
+				ctx.Request.Method = method
+			}
+		}
+	})
+}
+
+// methodOverride 返回 body 中 _method 指定的请求方法，仅支持 GET 、 PUT 、 DELETE ，无法解析时返回空
+func methodOverride(body []byte) string {
+	if len(body) == 0 || bytes.Contains(body, []byte(`"_method"`)) == false {
+		return ""
+	}
+	var object struct {
+		Method string `json:"_method"`
+	}
+	if err := json.Unmarshal(body, &object); err != nil {
+		return ""
+	}
+	method := strings.ToUpper(object.Method)
+	switch method {
+	case "GET", "PUT", "DELETE":
+		return method
+	}
+	return ""
+}
@@ -0,0 +1,149 @@
+// Package server 以库的方式运行 talisman ，应用可以把 talisman 嵌入到自己的程序中，而不是单独部署一个进程
+// 云代码通过 cloud 包注册，如 cloud.Define 、 cloud.BeforeSave ，与独立运行时相同
+// 导入包时不连接数据库，也不依赖 conf/app.conf ，各个模块在调用 New 时按传入的配置创建
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/okobsamoht/talisman/analytics"
+	"github.com/okobsamoht/talisman/cache"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/controllers"
+	"github.com/okobsamoht/talisman/files"
+	"github.com/okobsamoht/talisman/hooks"
+	"github.com/okobsamoht/talisman/livequery"
+	"github.com/okobsamoht/talisman/logger"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/push"
+	"github.com/okobsamoht/talisman/quota"
+	"github.com/okobsamoht/talisman/rest"
+	"github.com/okobsamoht/talisman/storage"
+	"github.com/okobsamoht/talisman/throttle"
+
+	// 注册 /v1 下的所有接口
+	_ "github.com/okobsamoht/talisman/routers"
+)
+
+// Server 实现了 http.Handler ，可挂载到应用自己的 http.Server 或者路由上
+type Server struct {
+	handler http.Handler
+}
+
+// New 使用配置 c 初始化 talisman ，只应调用一次
+// c 为 nil 时使用 conf/app.conf 中的配置，配置不合法或者连接数据库失败时返回错误而不是退出进程
+func New(c *config.Config) (*Server, error) {
+	if c != nil {
+		config.TConfig = c
+	}
+	if err := config.Check(); err != nil {
+		return nil, err
+	}
+	if err := initModules(); err != nil {
+		return nil, err
+	}
+	setup()
+	return &Server{handler: beego.BeeApp.Handlers}, nil
+}
+
+// ServeHTTP ...
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// DB 返回数据库操作对象，可在应用中直接读写数据，不经过权限校验
+func (s *Server) DB() *orm.DBController {
+	return orm.TalismanDBController
+}
+
+// Schema 返回当前的 Schema ，用于查询与修改类的定义
+func (s *Server) Schema() *orm.Schema {
+	return orm.TalismanDBController.LoadSchema(nil)
+}
+
+// Shutdown 停止后台任务并关闭数据库连接
+func (s *Server) Shutdown() {
+	Shutdown()
+}
+
+// Shutdown 停止后台任务并关闭数据库连接
+func Shutdown() {
+	livequery.StopWatchDatabase()
+	if orm.TalismanDBController == nil {
+		return
+	}
+	controllers.FlushKeyUsages()
+	orm.TalismanDBController.StopSchemaCacheRefresh()
+	orm.TalismanDBController.StopTTLSweeper()
+	if orm.Adapter != nil {
+		orm.Adapter.HandleShutdown()
+	}
+}
+
+// initModules 按当前配置创建各个模块，依赖数据库的模块在数据库之后创建
+// 数据库驱动连接失败时会 panic ，此处转换为错误返回
+func initModules() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("init modules failed: %v", r)
+		}
+	}()
+	livequery.StopWatchDatabase()
+	logger.SetLevel(config.TConfig.LogLevel)
+	cache.Init()
+	orm.Init()
+	files.Init()
+	rest.Init()
+	push.Init()
+	analytics.Init()
+	livequery.Init()
+	throttle.Init()
+	quota.Init()
+	hooks.Load()
+	return nil
+}
+
+// setup 创建索引、启动后台任务、安装过滤器，独立运行与嵌入运行时共用
+func setup() {
+	// 嵌入运行时没有 conf/app.conf ，接口依赖请求体的拷贝
+	beego.BConfig.CopyRequestBody = true
+
+	// 创建必要的索引
+	orm.TalismanDBController.PerformInitialization()
+
+	// 预加载 Schema ，并在后台定时刷新
+	if config.TConfig.SchemaCacheWarmUp {
+		orm.TalismanDBController.WarmUpSchemaCache()
+	}
+	if config.TConfig.SchemaCacheRefreshInterval > 0 {
+		orm.TalismanDBController.StartSchemaCacheRefresh(time.Duration(config.TConfig.SchemaCacheRefreshInterval) * time.Second)
+	}
+
+	// 后台删除过期对象
+	if config.TConfig.TTLSweepInterval > 0 {
+		orm.TalismanDBController.StartTTLSweeper(time.Duration(config.TConfig.TTLSweepInterval) * time.Second)
+	}
+
+	// 从数据库的对象变更中生成 LiveQuery 事件
+	if config.TConfig.LiveQueryEventSource == "Database" {
+		if watcher, ok := orm.Adapter.(storage.ChangeWatcher); ok {
+			livequery.WatchDatabase(watcher)
+		} else {
+			logger.Warn("Database adapter does not support change watching, LiveQuery events are disabled")
+		}
+	}
+
+	beego.ErrorController(&controllers.ErrorController{})
+
+	assignRequestID()
+	allowMethodOverride()
+	allowCrossDomain()
+	throttleLogin()
+	accountRequests()
+	if config.TConfig.ReloadOnSignal {
+		reloadOnSignal()
+	}
+}
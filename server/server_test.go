@@ -0,0 +1,38 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/okobsamoht/talisman/config"
+)
+
+// 测试环境中没有 conf/app.conf ，导入包时不应连接数据库
+func Test_New(t *testing.T) {
+	var c config.Config
+	var err error
+	var s *Server
+	/********************************************************/
+	c = *config.TConfig
+	c.AppName = "test"
+	c.ServerURL = "http://127.0.0.1:8080/v1"
+	c.AppID = ""
+	c.MasterKey = "test"
+	s, err = New(&c)
+	if s != nil || err == nil || err.Error() != "AppID is required" {
+		t.Error("expect:", "AppID is required", "result:", s, err)
+	}
+	/********************************************************/
+	c = *config.TConfig
+	c.AppName = "test"
+	c.ServerURL = "http://127.0.0.1:8080/v1"
+	c.AppID = "test"
+	c.MasterKey = "test"
+	c.DatabaseType = "MongoDB"
+	c.DatabaseURI = "127.0.0.1:1/test"
+	c.MongoConnectTimeout = 1
+	s, err = New(&c)
+	if s != nil || err == nil {
+		t.Error("expect:", "error", "result:", s, err)
+	}
+	Shutdown()
+}
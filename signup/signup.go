@@ -0,0 +1,123 @@
+// Package signup 在创建用户时校验注册数据
+// 内置的校验检查邮箱域名：允许列表、禁止列表以及一次性邮箱，不符合规则时按 SignupValidationPolicy 拒绝注册或者标记用户
+// 其他校验可以通过 RegisterValidator 注册，在内置校验之后执行
+package signup
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// Validator 校验注册用户的数据， user 为即将写入的用户对象，不能修改
+// 返回 error 时拒绝注册，返回非空的 flag 时允许注册并把 flag 记录在用户的 signupFlags 字段中
+type Validator func(user types.M) (flag string, err error)
+
+type namedValidator struct {
+	name      string
+	validator Validator
+}
+
+var (
+	validatorsMutex sync.RWMutex
+	validators      = []namedValidator{}
+)
+
+// RegisterValidator 注册自定义的校验函数，按注册顺序执行，名称相同时替换之前注册的函数
+func RegisterValidator(name string, v Validator) {
+	validatorsMutex.Lock()
+	defer validatorsMutex.Unlock()
+	for i, n := range validators {
+		if n.name == name {
+			validators[i].validator = v
+			return
+		}
+	}
+	validators = append(validators, namedValidator{name: name, validator: v})
+}
+
+// Validate 依次执行内置校验与注册的校验函数，返回需要记录的标记，任意一个校验返回 error 时停止
+func Validate(user types.M) ([]string, error) {
+	flags := []string{}
+	violation := checkEmailDomain(utils.S(user["email"]))
+	if violation != "" {
+		if config.TConfig.SignupValidationPolicy != "flag" {
+			return nil, errs.E(errs.InvalidEmailAddress, "Email domain is not allowed.")
+		}
+		flags = append(flags, violation)
+	}
+
+	validatorsMutex.RLock()
+	registered := append([]namedValidator{}, validators...)
+	validatorsMutex.RUnlock()
+	for _, n := range registered {
+		flag, err := n.validator(user)
+		if err != nil {
+			return nil, err
+		}
+		if flag != "" {
+			flags = append(flags, flag)
+		}
+	}
+	return flags, nil
+}
+
+// checkEmailDomain 检查邮箱域名，符合规则时返回空，否则返回违反的规则：
+// emailDomainNotAllowed 不在允许列表中、 emailDomainDenied 在禁止列表中、 disposableEmail 一次性邮箱
+func checkEmailDomain(email string) string {
+	p := strings.LastIndex(email, "@")
+	if p < 0 {
+		return ""
+	}
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(email[p+1:])), ".")
+	if domain == "" {
+		return ""
+	}
+	if len(config.TConfig.SignupEmailAllowlist) > 0 {
+		if matchDomain(domain, config.TConfig.SignupEmailAllowlist) {
+			return ""
+		}
+		return "emailDomainNotAllowed"
+	}
+	if matchDomain(domain, config.TConfig.SignupEmailDenylist) {
+		return "emailDomainDenied"
+	}
+	if config.TConfig.BlockDisposableEmails {
+		if isDisposableDomain(domain) || matchDomain(domain, config.TConfig.DisposableEmailDomains) {
+			return "disposableEmail"
+		}
+	}
+	return ""
+}
+
+// matchDomain 判断 domain 是否为 domains 中的域名或者其子域名
+func matchDomain(domain string, domains []string) bool {
+	for _, d := range domains {
+		d = strings.ToLower(strings.Trim(d, ". "))
+		if d == "" {
+			continue
+		}
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDisposableDomain 判断 domain 是否为内置列表中的一次性邮箱域名或者其子域名
+func isDisposableDomain(domain string) bool {
+	for {
+		if disposableDomains[domain] {
+			return true
+		}
+		p := strings.Index(domain, ".")
+		if p < 0 {
+			return false
+		}
+		domain = domain[p+1:]
+	}
+}
@@ -0,0 +1,93 @@
+package signup
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_checkEmailDomain(t *testing.T) {
+	defer func(c config.Config) { *config.TConfig = c }(*config.TConfig)
+	tests := []struct {
+		name       string
+		email      string
+		allowlist  []string
+		denylist   []string
+		disposable bool
+		extra      []string
+		want       string
+	}{
+		{name: "1", email: "joe@mailinator.com", want: ""},
+		{name: "2", email: "joe@mailinator.com", disposable: true, want: "disposableEmail"},
+		{name: "3", email: "joe@eu.Mailinator.COM", disposable: true, want: "disposableEmail"},
+		{name: "4", email: "joe@notmailinator.com", disposable: true, want: ""},
+		{name: "5", email: "joe@temp.example", disposable: true, extra: []string{"temp.example"}, want: "disposableEmail"},
+		{name: "6", email: "joe@spam.example", denylist: []string{"spam.example"}, want: "emailDomainDenied"},
+		{name: "7", email: "joe@mail.spam.example", denylist: []string{"spam.example"}, want: "emailDomainDenied"},
+		{name: "8", email: "joe@example.com", allowlist: []string{"company.com"}, want: "emailDomainNotAllowed"},
+		{name: "9", email: "joe@dev.company.com", allowlist: []string{"company.com"}, denylist: []string{"company.com"}, want: ""},
+		{name: "10", email: "joe@mailinator.com", allowlist: []string{"mailinator.com"}, disposable: true, want: ""},
+		{name: "11", email: "", disposable: true, want: ""},
+		{name: "12", email: "joe", allowlist: []string{"company.com"}, want: ""},
+	}
+	for _, tt := range tests {
+		config.TConfig.SignupEmailAllowlist = tt.allowlist
+		config.TConfig.SignupEmailDenylist = tt.denylist
+		config.TConfig.BlockDisposableEmails = tt.disposable
+		config.TConfig.DisposableEmailDomains = tt.extra
+		if got := checkEmailDomain(tt.email); got != tt.want {
+			t.Errorf("%q. checkEmailDomain() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	defer func(c config.Config) { *config.TConfig = c }(*config.TConfig)
+	defer func() { validators = []namedValidator{} }()
+	config.TConfig.BlockDisposableEmails = true
+	config.TConfig.SignupValidationPolicy = "reject"
+
+	_, err := Validate(types.M{"email": "joe@yopmail.com"})
+	expect := errs.E(errs.InvalidEmailAddress, "Email domain is not allowed.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+
+	config.TConfig.SignupValidationPolicy = "flag"
+	flags, err := Validate(types.M{"email": "joe@yopmail.com"})
+	if err != nil || reflect.DeepEqual([]string{"disposableEmail"}, flags) == false {
+		t.Error("expect:", []string{"disposableEmail"}, "result:", flags, err)
+	}
+
+	RegisterValidator("name", func(user types.M) (string, error) {
+		if user["name"] == "admin" {
+			return "", errors.New("reserved name")
+		}
+		return "", nil
+	})
+	RegisterValidator("phone", func(user types.M) (string, error) {
+		return "noPhone", nil
+	})
+	RegisterValidator("phone", func(user types.M) (string, error) {
+		if user["phone"] == nil {
+			return "missingPhone", nil
+		}
+		return "", nil
+	})
+	flags, err = Validate(types.M{"email": "joe@yopmail.com"})
+	if err != nil || reflect.DeepEqual([]string{"disposableEmail", "missingPhone"}, flags) == false {
+		t.Error("expect:", []string{"disposableEmail", "missingPhone"}, "result:", flags, err)
+	}
+	flags, err = Validate(types.M{"email": "joe@example.com", "phone": "+8613800138000"})
+	if err != nil || reflect.DeepEqual([]string{}, flags) == false {
+		t.Error("expect:", []string{}, "result:", flags, err)
+	}
+	_, err = Validate(types.M{"name": "admin"})
+	if err == nil || err.Error() != "reserved name" {
+		t.Error("expect:", "reserved name", "result:", err)
+	}
+}
@@ -0,0 +1,114 @@
+package sms
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/okobsamoht/talisman/cache"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/logger"
+	"github.com/okobsamoht/talisman/publichtml"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// SendCode 生成验证码并发送到手机号，新的验证码会使之前的验证码失效
+// 距离上次发送不足 PhoneCodeResendInterval 时拒绝发送
+func SendCode(phone string) error {
+	if adapter == nil {
+		return errs.E(errs.UnsupportedService, "This authentication method is unsupported.")
+	}
+	phone = NormalizePhone(phone)
+	if phone == "" {
+		return errs.E(errs.ValidationError, "Invalid phone number.")
+	}
+	now := time.Now().UTC()
+	interval := time.Duration(config.TConfig.PhoneCodeResendInterval) * time.Second
+	if record := utils.M(cache.Phone.Get(phone)); record != nil {
+		if sentAt, err := utils.StringtoTime(utils.S(record["sentAt"])); err == nil && now.Sub(sentAt) < interval {
+			return errs.E(errs.RequestLimitExceeded, "Verification code was sent too frequently.")
+		}
+	}
+
+	code := utils.CreateDigits(config.TConfig.PhoneCodeLength)
+	if err := adapter.SendSMS(phone, codeMessage(code)); err != nil {
+		logger.Error("send verification code to", phone, "failed:", err)
+		return errs.E(errs.InternalServerError, "Could not send verification code.")
+	}
+
+	validity := time.Duration(config.TConfig.PhoneCodeValidityDuration) * time.Second
+	ttl := validity
+	if interval > ttl {
+		ttl = interval
+	}
+	record := types.M{
+		"hash":      hashCode(phone, code),
+		"sentAt":    utils.TimetoString(now),
+		"expiresAt": utils.TimetoString(now.Add(validity)),
+	}
+	cache.Phone.Put(phone, record, int64(ttl/time.Second))
+	attempts.Del(attemptsKey(phone))
+	return nil
+}
+
+// VerifyCode 校验手机号收到的验证码，校验成功后验证码失效，不能重复使用
+// 校验失败的次数达到 PhoneCodeMaxAttempts 时验证码失效，需要重新发送
+func VerifyCode(phone, code string) error {
+	if adapter == nil {
+		return errs.E(errs.UnsupportedService, "This authentication method is unsupported.")
+	}
+	invalid := errs.E(errs.InvalidGeneralAuthData, "Invalid or expired verification code.")
+	phone = NormalizePhone(phone)
+	if phone == "" || code == "" {
+		return invalid
+	}
+	record := utils.M(cache.Phone.Get(phone))
+	if record == nil {
+		return invalid
+	}
+	expiresAt, err := utils.StringtoTime(utils.S(record["expiresAt"]))
+	if err != nil || time.Now().After(expiresAt) {
+		cache.Phone.Del(phone)
+		return invalid
+	}
+
+	hash := hashCode(phone, code)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(utils.S(record["hash"]))) != 1 {
+		validity := time.Duration(config.TConfig.PhoneCodeValidityDuration) * time.Second
+		count, err := attempts.Incr(attemptsKey(phone), validity)
+		if err != nil || count >= config.TConfig.PhoneCodeMaxAttempts {
+			cache.Phone.Del(phone)
+			attempts.Del(attemptsKey(phone))
+		}
+		return invalid
+	}
+	cache.Phone.Del(phone)
+	attempts.Del(attemptsKey(phone))
+	return nil
+}
+
+// codeMessage 生成短信内容，可以通过 TemplatesPath 中的 phone_code.txt 自定义
+func codeMessage(code string) string {
+	data := types.M{
+		"appName": config.TConfig.AppName,
+		"code":    code,
+	}
+	if text, ok := publichtml.Render(publichtml.PhoneCodeTemplate, data); ok {
+		return text
+	}
+	return "Your " + config.TConfig.AppName + " verification code is " + code
+}
+
+// hashCode 缓存中只保存验证码的哈希值
+func hashCode(phone, code string) string {
+	sum := sha256.Sum256([]byte(phone + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+func attemptsKey(phone string) string {
+	return strings.Join([]string{config.TConfig.AppID, "phone", "attempts", phone}, ":")
+}
@@ -0,0 +1,140 @@
+package sms
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/okobsamoht/talisman/cache"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/throttle"
+)
+
+// testAdapter 记录发送的短信
+type testAdapter struct {
+	to   string
+	body string
+	fail bool
+}
+
+func (s *testAdapter) SendSMS(to, body string) error {
+	if s.fail {
+		return errors.New("send failed")
+	}
+	s.to = to
+	s.body = body
+	return nil
+}
+
+func (s *testAdapter) code() string {
+	return s.body[strings.LastIndex(s.body, " ")+1:]
+}
+
+func initTest() *testAdapter {
+	config.TConfig = &config.Config{
+		AppName:                   "talisman",
+		AppID:                     "test",
+		PhoneAuth:                 true,
+		PhoneCodeLength:           6,
+		PhoneCodeValidityDuration: 300,
+		PhoneCodeMaxAttempts:      3,
+		PhoneCodeResendInterval:   60,
+	}
+	cache.InitCache()
+	s := &testAdapter{}
+	adapter = s
+	attempts = throttle.NewMemoryStore()
+	return s
+}
+
+func Test_NormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  string
+	}{
+		{phone: "+8613800138000", want: "+8613800138000"},
+		{phone: "+1 (555) 000-1111", want: "+15550001111"},
+		{phone: "13800138000", want: ""},
+		{phone: "+0123456789", want: ""},
+		{phone: "+86abc", want: ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizePhone(tt.phone); got != tt.want {
+			t.Errorf("%q. NormalizePhone() = %v, want %v", tt.phone, got, tt.want)
+		}
+	}
+}
+
+func Test_SendCode(t *testing.T) {
+	s := initTest()
+	defer func() { adapter = nil }()
+	/*************************************************/
+	err := SendCode("+86 138 0013 8000")
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	if s.to != "+8613800138000" || len(s.code()) != 6 || s.body != "Your talisman verification code is "+s.code() {
+		t.Error("expect:", "+8613800138000", "result:", s.to, s.body)
+	}
+	/*************************************************/
+	expect := errs.E(errs.RequestLimitExceeded, "Verification code was sent too frequently.")
+	if err := SendCode("+8613800138000"); reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*************************************************/
+	expect = errs.E(errs.ValidationError, "Invalid phone number.")
+	if err := SendCode("13800138000"); reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*************************************************/
+	s.fail = true
+	expect = errs.E(errs.InternalServerError, "Could not send verification code.")
+	if err := SendCode("+8613800138001"); reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	if cache.Phone.Get("+8613800138001") != nil {
+		t.Error("expect:", nil, "result:", cache.Phone.Get("+8613800138001"))
+	}
+}
+
+func Test_VerifyCode(t *testing.T) {
+	s := initTest()
+	defer func() { adapter = nil }()
+	invalid := errs.E(errs.InvalidGeneralAuthData, "Invalid or expired verification code.")
+	/*************************************************/
+	if err := VerifyCode("+8613800138000", "123456"); reflect.DeepEqual(invalid, err) == false {
+		t.Error("expect:", invalid, "result:", err)
+	}
+	/*************************************************/
+	SendCode("+8613800138000")
+	code := s.code()
+	if err := VerifyCode("+86 138 0013 8000", code); err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	// 验证码只能使用一次
+	if err := VerifyCode("+8613800138000", code); reflect.DeepEqual(invalid, err) == false {
+		t.Error("expect:", invalid, "result:", err)
+	}
+	/*************************************************/
+	config.TConfig.PhoneCodeResendInterval = 0
+	SendCode("+8613800138000")
+	code = s.code()
+	wrong := "0000000"
+	for i := 0; i < 3; i++ {
+		if err := VerifyCode("+8613800138000", wrong); reflect.DeepEqual(invalid, err) == false {
+			t.Error("expect:", invalid, "result:", err)
+		}
+	}
+	// 失败次数达到上限后，正确的验证码也失效
+	if err := VerifyCode("+8613800138000", code); reflect.DeepEqual(invalid, err) == false {
+		t.Error("expect:", invalid, "result:", err)
+	}
+	/*************************************************/
+	config.TConfig.PhoneCodeValidityDuration = -1
+	SendCode("+8613800138000")
+	if err := VerifyCode("+8613800138000", s.code()); reflect.DeepEqual(invalid, err) == false {
+		t.Error("expect:", invalid, "result:", err)
+	}
+}
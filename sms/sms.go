@@ -0,0 +1,53 @@
+// Package sms 发送短信验证码，用于手机号登录
+// 验证码保存在缓存模块中，只保存哈希值，校验失败的次数保存在与缓存模块一致的计数器中
+package sms
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/throttle"
+)
+
+// Adapter ...
+type Adapter interface {
+	// SendSMS to 为 E.164 格式的手机号，如 +8613800138000 ， body 为短信内容
+	SendSMS(to, body string) error
+}
+
+var adapter Adapter
+var attempts throttle.Store
+
+func init() {
+	Init()
+}
+
+// Init 根据当前配置创建短信发送模块，未开启 PhoneAuth 时不发送短信
+func Init() {
+	adapter = nil
+	attempts = nil
+	if config.TConfig.PhoneAuth == false {
+		return
+	}
+	switch config.TConfig.SMSAdapter {
+	case "", "Twilio":
+		adapter = NewTwilioAdapter()
+	}
+	if config.TConfig.CacheAdapter == "Redis" {
+		attempts = throttle.NewRedisStore(config.TConfig.RedisAddress, config.TConfig.RedisPassword)
+	} else {
+		attempts = throttle.NewMemoryStore()
+	}
+}
+
+var phoneRegexp = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// NormalizePhone 去掉手机号中的空格、横线与括号，不是 E.164 格式时返回空
+func NormalizePhone(phone string) string {
+	phone = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(phone)
+	if phoneRegexp.MatchString(phone) == false {
+		return ""
+	}
+	return phone
+}
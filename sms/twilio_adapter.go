@@ -0,0 +1,68 @@
+package sms
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+)
+
+// TwilioAdapter 通过 Twilio API 发送短信
+type TwilioAdapter struct {
+	url        string
+	accountSID string
+	authToken  string
+	from       string
+	client     *http.Client
+}
+
+// NewTwilioAdapter ...
+func NewTwilioAdapter() *TwilioAdapter {
+	return &TwilioAdapter{
+		url:        "https://api.twilio.com/2010-04-01/Accounts/" + config.TConfig.TwilioAccountSID + "/Messages.json",
+		accountSID: config.TConfig.TwilioAccountSID,
+		authToken:  config.TConfig.TwilioAuthToken,
+		from:       config.TConfig.TwilioFrom,
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SendSMS from 以 MG 开头时作为 Messaging Service SID 使用
+func (t *TwilioAdapter) SendSMS(to, body string) error {
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("Body", body)
+	if strings.HasPrefix(t.from, "MG") {
+		form.Set("MessagingServiceSid", t.from)
+	} else {
+		form.Set("From", t.from)
+	}
+
+	request, err := http.NewRequest("POST", t.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth(t.accountSID, t.authToken)
+	response, err := t.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		var result struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(response.Body).Decode(&result)
+		if result.Message != "" {
+			return errors.New("twilio returned status " + strconv.Itoa(response.StatusCode) + ": " + result.Message)
+		}
+		return errors.New("twilio returned status " + strconv.Itoa(response.StatusCode))
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package sms
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func Test_TwilioAdapter(t *testing.T) {
+	var received url.Values
+	var username, password string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ = r.BasicAuth()
+		r.ParseForm()
+		received = r.PostForm
+		if received.Get("To") == "+10000000000" {
+			w.WriteHeader(400)
+			w.Write([]byte(`{"code":21211,"message":"Invalid 'To' Phone Number"}`))
+			return
+		}
+		w.WriteHeader(201)
+	}))
+	defer server.Close()
+	s := &TwilioAdapter{url: server.URL, accountSID: "AC123", authToken: "token", from: "+15550001111", client: http.DefaultClient}
+	/*************************************************/
+	err := s.SendSMS("+8613800138000", "code 123456")
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	expect := url.Values{
+		"To":   []string{"+8613800138000"},
+		"From": []string{"+15550001111"},
+		"Body": []string{"code 123456"},
+	}
+	if reflect.DeepEqual(expect, received) == false {
+		t.Error("expect:", expect, "result:", received)
+	}
+	if username != "AC123" || password != "token" {
+		t.Error("expect:", "AC123:token", "result:", username+":"+password)
+	}
+	/*************************************************/
+	s.from = "MG123"
+	err = s.SendSMS("+8613800138000", "code 123456")
+	if err != nil || received.Get("MessagingServiceSid") != "MG123" || received.Get("From") != "" {
+		t.Error("expect:", "MG123", "result:", received, err)
+	}
+	/*************************************************/
+	err = s.SendSMS("+10000000000", "code 123456")
+	if err == nil || err.Error() != "twilio returned status 400: Invalid 'To' Phone Number" {
+		t.Error("expect:", "twilio returned status 400", "result:", err)
+	}
+}
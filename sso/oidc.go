@@ -0,0 +1,279 @@
+package sso
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/logger"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// metadataTTL 服务商配置与公钥的缓存时间，遇到未知的 kid 时立即重新获取
+const metadataTTL = time.Hour
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+var (
+	metadataMutex sync.Mutex
+	metadataCache = map[string]*metadata{}
+)
+
+var errUnknownKey = errors.New("unknown signing key")
+
+// metadata 服务商的 openid-configuration 与 jwks_uri 中的公钥
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	keys                  map[string]*rsa.PublicKey
+	loadedAt              time.Time
+}
+
+// loadMetadata 获取服务商配置，refresh 为 true 时忽略缓存
+func loadMetadata(p *config.SSO, refresh bool) (*metadata, error) {
+	metadataMutex.Lock()
+	defer metadataMutex.Unlock()
+	if m, ok := metadataCache[p.Issuer]; ok && refresh == false && time.Since(m.loadedAt) < metadataTTL {
+		return m, nil
+	}
+
+	m := &metadata{}
+	if err := getJSON(p.Issuer+"/.well-known/openid-configuration", m); err != nil {
+		return nil, err
+	}
+	if strings.TrimRight(m.Issuer, "/") != p.Issuer {
+		return nil, errors.New("issuer mismatch: " + m.Issuer)
+	}
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := getJSON(m.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+	m.keys = map[string]*rsa.PublicKey{}
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		m.keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	m.loadedAt = time.Now()
+	metadataCache[p.Issuer] = m
+	return m, nil
+}
+
+func getJSON(u string, v interface{}) error {
+	response, err := client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		return errors.New(u + " returned status " + strconv.Itoa(response.StatusCode))
+	}
+	return json.NewDecoder(response.Body).Decode(v)
+}
+
+// AuthURL 生成跳转到服务商的授权地址
+func AuthURL(p *config.SSO, state, nonce string) (string, error) {
+	m, err := loadMetadata(p, false)
+	if err != nil {
+		logger.Error("load", p.Name, "openid configuration failed:", err)
+		return "", errs.E(errs.ConnectionFailed, "Failed to connect to "+p.Name+".")
+	}
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.ClientID)
+	query.Set("redirect_uri", CallbackURL(p))
+	query.Set("scope", strings.Join(p.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	separator := "?"
+	if strings.Contains(m.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return m.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Authenticate 使用授权码换取 id_token ，校验之后返回用户身份
+func Authenticate(p *config.SSO, code, nonce string) (*Identity, error) {
+	invalid := errs.E(errs.ObjectNotFound, "Failed to validate this user with "+p.Name+".")
+	m, err := loadMetadata(p, false)
+	if err != nil {
+		logger.Error("load", p.Name, "openid configuration failed:", err)
+		return nil, invalid
+	}
+	idToken, err := exchangeCode(p, m.TokenEndpoint, code)
+	if err != nil {
+		logger.Error("exchange", p.Name, "authorization code failed:", err)
+		return nil, invalid
+	}
+	identity, err := verifyIDToken(p, m, idToken, nonce, time.Now())
+	if err == errUnknownKey {
+		// 服务商轮换了签名密钥
+		if m, err = loadMetadata(p, true); err == nil {
+			identity, err = verifyIDToken(p, m, idToken, nonce, time.Now())
+		}
+	}
+	if err != nil {
+		logger.Error("verify", p.Name, "id_token failed:", err)
+		return nil, invalid
+	}
+	return identity, nil
+}
+
+// exchangeCode 向 token_endpoint 提交授权码，返回 id_token
+func exchangeCode(p *config.SSO, tokenEndpoint, code string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", CallbackURL(p))
+	request, err := http.NewRequest("POST", tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	response, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	var result struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if result.Error != "" {
+		return "", errors.New(result.Error + ": " + result.ErrorDescription)
+	}
+	if result.IDToken == "" {
+		return "", errors.New("missing id_token")
+	}
+	return result.IDToken, nil
+}
+
+// verifyIDToken 校验 RS256 签名以及 iss aud exp nonce 声明
+func verifyIDToken(p *config.SSO, m *metadata, token, nonce string, now time.Time) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed id_token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, errors.New("unsupported alg: " + header.Alg)
+	}
+	key, ok := m.keys[header.Kid]
+	if ok == false {
+		return nil, errUnknownKey
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig); err != nil {
+		return nil, err
+	}
+
+	var claims types.M
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if strings.TrimRight(utils.S(claims["iss"]), "/") != p.Issuer {
+		return nil, errors.New("issuer mismatch")
+	}
+	if audienceContains(claims["aud"], p.ClientID) == false {
+		return nil, errors.New("audience mismatch")
+	}
+	if exp, ok := claims["exp"].(float64); ok == false || int64(exp) <= now.Unix() {
+		return nil, errors.New("id_token expired")
+	}
+	if utils.S(claims["nonce"]) != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+	identity := &Identity{
+		Subject: utils.S(claims["sub"]),
+		Email:   utils.S(claims["email"]),
+	}
+	if identity.Subject == "" {
+		return nil, errors.New("missing sub")
+	}
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = v
+	case string:
+		identity.EmailVerified = v == "true"
+	}
+	if p.RoleClaim != "" {
+		switch v := claims[p.RoleClaim].(type) {
+		case []interface{}:
+			for _, g := range v {
+				if s, ok := g.(string); ok {
+					identity.Groups = append(identity.Groups, s)
+				}
+			}
+		case string:
+			identity.Groups = strings.Fields(v)
+		}
+	}
+	return identity, nil
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, a := range v {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
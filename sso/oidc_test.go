@@ -0,0 +1,124 @@
+package sso
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/types"
+)
+
+// signRS256 使用测试密钥签发 id_token
+func signRS256(key *rsa.PrivateKey, kid string, claims types.M) string {
+	header, _ := json.Marshal(types.M{"alg": "RS256", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(input))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func Test_Authenticate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var server *httptest.Server
+	var form url.Values
+	var claims types.M
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(types.M{
+				"issuer":                 server.URL,
+				"authorization_endpoint": server.URL + "/authorize",
+				"token_endpoint":         server.URL + "/token",
+				"jwks_uri":               server.URL + "/jwks",
+			})
+		case "/jwks":
+			json.NewEncoder(w).Encode(types.M{"keys": []types.M{{
+				"kty": "RSA",
+				"kid": "k1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		case "/token":
+			r.ParseForm()
+			form = r.PostForm
+			if form.Get("code") != "good" {
+				json.NewEncoder(w).Encode(types.M{"error": "invalid_grant"})
+				return
+			}
+			json.NewEncoder(w).Encode(types.M{"id_token": signRS256(key, "k1", claims)})
+		}
+	}))
+	defer server.Close()
+	config.TConfig = &config.Config{ServerURL: "http://127.0.0.1/v1"}
+	p := &config.SSO{Name: "test", Issuer: server.URL, ClientID: "client", ClientSecret: "secret", Scopes: []string{"openid", "email"}, RoleClaim: "groups"}
+	/*************************************************/
+	location, err := AuthURL(p, "s1", "n1")
+	u, _ := url.Parse(location)
+	if err != nil || strings.HasPrefix(location, server.URL+"/authorize?") == false ||
+		u.Query().Get("redirect_uri") != "http://127.0.0.1/v1/sso/test/callback" || u.Query().Get("scope") != "openid email" || u.Query().Get("nonce") != "n1" {
+		t.Error("expect:", server.URL+"/authorize?...", "result:", location, err)
+	}
+	/*************************************************/
+	claims = types.M{
+		"iss":            server.URL,
+		"aud":            []string{"client", "other"},
+		"sub":            "1001",
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"nonce":          "n1",
+		"email":          "joe@example.com",
+		"email_verified": true,
+		"groups":         []string{"admins"},
+	}
+	identity, err := Authenticate(p, "good", "n1")
+	expect := &Identity{Subject: "1001", Email: "joe@example.com", EmailVerified: true, Groups: []string{"admins"}}
+	if err != nil || reflect.DeepEqual(expect, identity) == false {
+		t.Error("expect:", expect, "result:", identity, err)
+	}
+	if form.Get("grant_type") != "authorization_code" || form.Get("redirect_uri") != "http://127.0.0.1/v1/sso/test/callback" {
+		t.Error("expect:", "authorization_code", "result:", form)
+	}
+	/*************************************************/
+	if _, err := Authenticate(p, "good", "n2"); err == nil {
+		t.Error("expect:", "nonce mismatch", "result:", nil)
+	}
+	if _, err := Authenticate(p, "bad", "n1"); err == nil {
+		t.Error("expect:", "invalid_grant", "result:", nil)
+	}
+	claims["aud"] = "other"
+	if _, err := Authenticate(p, "good", "n1"); err == nil {
+		t.Error("expect:", "audience mismatch", "result:", nil)
+	}
+	claims["aud"] = "client"
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+	if _, err := Authenticate(p, "good", "n1"); err == nil {
+		t.Error("expect:", "id_token expired", "result:", nil)
+	}
+	/*************************************************/
+	m, _ := loadMetadata(p, false)
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	token := signRS256(other, "k1", types.M{"iss": server.URL, "aud": "client", "sub": "1001", "exp": time.Now().Add(time.Minute).Unix(), "nonce": "n1"})
+	if _, err := verifyIDToken(p, m, token, "n1", time.Now()); err == nil {
+		t.Error("expect:", "verification error", "result:", nil)
+	}
+	token = signRS256(key, "k2", types.M{"iss": server.URL, "aud": "client", "sub": "1001", "exp": time.Now().Add(time.Minute).Unix(), "nonce": "n1"})
+	if _, err := verifyIDToken(p, m, token, "n1", time.Now()); err != errUnknownKey {
+		t.Error("expect:", errUnknownKey, "result:", err)
+	}
+}
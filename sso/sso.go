@@ -0,0 +1,82 @@
+// Package sso OIDC 单点登录，使用授权码流程从服务商获取 id_token ，校验签名与声明之后得到用户身份
+// 登录流程中的 state 与内部使用的登录凭证使用由 MasterKey 派生的密钥签名，不需要保存在服务端
+package sso
+
+import (
+	"strings"
+
+	"github.com/okobsamoht/talisman/config"
+)
+
+// Identity 从 id_token 中读取的用户身份
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Groups        []string
+}
+
+// ID 保存在 authData.sso.id 中的用户标识，格式为 服务商名称:sub
+func (i *Identity) ID(provider string) string {
+	return provider + ":" + i.Subject
+}
+
+// Provider 查找名称对应的服务商，不存在时返回 nil
+func Provider(name string) *config.SSO {
+	for i, p := range config.TConfig.SSOProviders {
+		if p.Name == name {
+			return &config.TConfig.SSOProviders[i]
+		}
+	}
+	return nil
+}
+
+// CallbackURL 服务商授权之后的回调地址，需要在服务商的控制台中添加
+func CallbackURL(p *config.SSO) string {
+	return config.TConfig.ServerURL + "/sso/" + p.Name + "/callback"
+}
+
+// AllowedRedirect 判断登录之后跳转的地址是否以 RedirectURLs 中的某个地址开头
+// 前缀之后只能是 / ? # ，避免 https://app.example.com 匹配到 https://app.example.com.evil.com
+func AllowedRedirect(p *config.SSO, redirect string) bool {
+	for _, prefix := range p.RedirectURLs {
+		if redirect == prefix {
+			return true
+		}
+		if strings.HasPrefix(redirect, prefix) == false {
+			continue
+		}
+		if strings.HasSuffix(prefix, "/") || strings.ContainsAny(redirect[len(prefix):len(prefix)+1], "/?#") {
+			return true
+		}
+	}
+	return false
+}
+
+// Roles 返回用户组声明对应的角色名称，以及 RoleMapping 中配置的全部角色名称
+func Roles(p *config.SSO, groups []string) (roles, managed []string) {
+	roles = []string{}
+	managed = []string{}
+	for _, r := range p.RoleMapping {
+		m := strings.SplitN(r, ":", 2)
+		if len(m) != 2 {
+			continue
+		}
+		managed = appendUnique(managed, m[1])
+		for _, g := range groups {
+			if g == m[0] {
+				roles = appendUnique(roles, m[1])
+			}
+		}
+	}
+	return roles, managed
+}
+
+func appendUnique(s []string, v string) []string {
+	for _, e := range s {
+		if e == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
@@ -0,0 +1,85 @@
+package sso
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+)
+
+func Test_AllowedRedirect(t *testing.T) {
+	p := &config.SSO{RedirectURLs: []string{"https://app.example.com", "myapp://callback", "https://www.example.com/login/"}}
+	tests := []struct {
+		redirect string
+		want     bool
+	}{
+		{redirect: "https://app.example.com", want: true},
+		{redirect: "https://app.example.com/sso", want: true},
+		{redirect: "https://app.example.com?from=sso", want: true},
+		{redirect: "myapp://callback", want: true},
+		{redirect: "https://www.example.com/login/done", want: true},
+		{redirect: "https://app.example.com.evil.com", want: false},
+		{redirect: "https://app.example.comx/sso", want: false},
+		{redirect: "https://www.example.com/", want: false},
+		{redirect: "https://evil.com", want: false},
+	}
+	for _, tt := range tests {
+		if got := AllowedRedirect(p, tt.redirect); got != tt.want {
+			t.Errorf("%q. AllowedRedirect() = %v, want %v", tt.redirect, got, tt.want)
+		}
+	}
+}
+
+func Test_Roles(t *testing.T) {
+	p := &config.SSO{RoleMapping: []string{"admins:Administrator", "staff:Staff", "ops:Staff"}}
+	roles, managed := Roles(p, []string{"admins", "ops", "other"})
+	if reflect.DeepEqual([]string{"Administrator", "Staff"}, roles) == false {
+		t.Error("expect:", []string{"Administrator", "Staff"}, "result:", roles)
+	}
+	if reflect.DeepEqual([]string{"Administrator", "Staff"}, managed) == false {
+		t.Error("expect:", []string{"Administrator", "Staff"}, "result:", managed)
+	}
+}
+
+func Test_State(t *testing.T) {
+	config.TConfig = &config.Config{MasterKey: "master"}
+	state, nonce := NewState("google", "https://app.example.com")
+	redirect, n, err := ParseState(state, "google")
+	if err != nil || redirect != "https://app.example.com" || n != nonce {
+		t.Error("expect:", "https://app.example.com", nonce, "result:", redirect, n, err)
+	}
+	/*************************************************/
+	expect := errs.E(errs.ObjectNotFound, "Invalid or expired SSO state.")
+	if _, _, err := ParseState(state, "okta"); reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	// 断言不能作为 state 使用
+	if _, _, err := ParseState(SignAssertion("google:1"), "google"); reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	// MasterKey 不同时签名无效
+	config.TConfig = &config.Config{MasterKey: "other"}
+	if _, _, err := ParseState(state, "google"); reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+}
+
+func Test_Assertion(t *testing.T) {
+	config.TConfig = &config.Config{MasterKey: "master"}
+	token := SignAssertion("google:1001")
+	if err := VerifyAssertion(token, "google:1001"); err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	expect := errs.E(errs.ObjectNotFound, "SSO auth is invalid for this user.")
+	if err := VerifyAssertion(token, "google:1002"); reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	if err := VerifyAssertion("", "google:1001"); reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	state, _ := NewState("google", "")
+	if err := VerifyAssertion(state, ""); reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+}
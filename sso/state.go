@@ -0,0 +1,72 @@
+package sso
+
+import (
+	"crypto/sha256"
+	"time"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/jwt"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+const (
+	keyID        = "sso"
+	stateTTL     = 10 * time.Minute
+	assertionTTL = time.Minute
+)
+
+// signingKey 由 MasterKey 派生，避免直接使用 MasterKey 签名
+func signingKey() []byte {
+	sum := sha256.Sum256([]byte("sso:" + config.TConfig.MasterKey))
+	return sum[:]
+}
+
+func sign(claims types.M, ttl time.Duration) string {
+	claims["exp"] = time.Now().Add(ttl).Unix()
+	return jwt.Sign(claims, keyID, signingKey())
+}
+
+func parse(token, typ string) (types.M, bool) {
+	claims, err := jwt.Parse(token, map[string][]byte{keyID: signingKey()}, time.Now())
+	if err != nil || utils.S(claims["typ"]) != typ {
+		return nil, false
+	}
+	return claims, true
+}
+
+// NewState 生成授权请求的 state 与 nonce ， state 中包含服务商名称、登录之后跳转的地址与 nonce
+func NewState(provider, redirect string) (state, nonce string) {
+	nonce = utils.CreateToken()
+	state = sign(types.M{
+		"typ":      "state",
+		"provider": provider,
+		"redirect": redirect,
+		"nonce":    nonce,
+	}, stateTTL)
+	return state, nonce
+}
+
+// ParseState 校验回调中的 state ，返回跳转地址与 nonce
+func ParseState(state, provider string) (redirect, nonce string, err error) {
+	claims, ok := parse(state, "state")
+	if ok == false || utils.S(claims["provider"]) != provider {
+		return "", "", errs.E(errs.ObjectNotFound, "Invalid or expired SSO state.")
+	}
+	return utils.S(claims["redirect"]), utils.S(claims["nonce"]), nil
+}
+
+// SignAssertion 生成 authData.sso.token ，由服务端在校验 id_token 之后生成，用于登录或者关联用户
+func SignAssertion(id string) string {
+	return sign(types.M{"typ": "assertion", "id": id}, assertionTTL)
+}
+
+// VerifyAssertion 校验 authData.sso 中的 token 与 id 是否一致，客户端无法伪造
+func VerifyAssertion(token, id string) error {
+	claims, ok := parse(token, "assertion")
+	if ok == false || id == "" || utils.S(claims["id"]) != id {
+		return errs.E(errs.ObjectNotFound, "SSO auth is invalid for this user.")
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// ClassCache 适配器内部的 _SCHEMA 缓存，以类名为 key ，用于 GetClass
+// 每次修改类结构时增加版本号，缓存数据的版本号与当前版本号不一致时失效，
+// 读取数据库期间发生的修改不会被写入缓存的旧数据覆盖。
+// 其他实例的修改无法感知，缓存数据在 ttl 之后过期， ttl 为 0 时不缓存
+type ClassCache struct {
+	mu         sync.RWMutex
+	ttl        time.Duration
+	generation uint64
+	entries    map[string]*classCacheEntry
+}
+
+type classCacheEntry struct {
+	schema     types.M
+	generation uint64
+	expire     time.Time
+}
+
+// NewClassCache ...
+func NewClassCache(ttl time.Duration) *ClassCache {
+	return &ClassCache{
+		ttl:     ttl,
+		entries: map[string]*classCacheEntry{},
+	}
+}
+
+// Generation 返回当前版本号，读取数据库之前获取，写入缓存时传入
+func (c *ClassCache) Generation() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.generation
+}
+
+// Get 获取类的 schema ，不存在、已过期或者版本号不一致时返回 nil
+// 返回的是副本，调用方可以修改
+func (c *ClassCache) Get(className string) types.M {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry := c.entries[className]
+	if entry == nil || entry.generation != c.generation || time.Now().After(entry.expire) {
+		return nil
+	}
+	return utils.DeepCopy(entry.schema).(types.M)
+}
+
+// Put 缓存类的 schema ， generation 为读取数据库之前的版本号，与当前版本号不一致时不缓存
+func (c *ClassCache) Put(className string, schema types.M, generation uint64) {
+	if c.ttl <= 0 || schema == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	c.entries[className] = &classCacheEntry{
+		schema:     utils.DeepCopy(schema).(types.M),
+		generation: generation,
+		expire:     time.Now().Add(c.ttl),
+	}
+}
+
+// Load 从缓存中获取类的 schema ，不存在时调用 fetch 从数据库读取，类存在时写入缓存
+func (c *ClassCache) Load(className string, fetch func(className string) (types.M, error)) (types.M, error) {
+	if schema := c.Get(className); schema != nil {
+		return schema, nil
+	}
+	generation := c.Generation()
+	schema, err := fetch(className)
+	if err != nil {
+		return nil, err
+	}
+	if len(schema) > 0 {
+		c.Put(className, schema, generation)
+	}
+	return schema, nil
+}
+
+// Bump 类结构发生变化时调用，增加版本号，所有缓存数据失效
+func (c *ClassCache) Bump() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.entries = map[string]*classCacheEntry{}
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_ClassCache(t *testing.T) {
+	var c *ClassCache
+	var result, expect types.M
+	schema := types.M{"className": "Post", "fields": types.M{"title": types.M{"type": "String"}}}
+	/*****************************************************************/
+	c = NewClassCache(0)
+	c.Put("Post", schema, c.Generation())
+	result = c.Get("Post")
+	if result != nil {
+		t.Error("expect:", nil, "result:", result)
+	}
+	/*****************************************************************/
+	c = NewClassCache(time.Minute)
+	c.Put("Post", schema, c.Generation())
+	result = c.Get("Post")
+	expect = types.M{"className": "Post", "fields": types.M{"title": types.M{"type": "String"}}}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	fields := result["fields"].(types.M)
+	fields["body"] = types.M{"type": "String"}
+	result = c.Get("Post")
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************************/
+	c = NewClassCache(time.Minute)
+	c.Put("Post", schema, c.Generation())
+	c.Bump()
+	result = c.Get("Post")
+	if result != nil {
+		t.Error("expect:", nil, "result:", result)
+	}
+	/*****************************************************************/
+	c = NewClassCache(time.Minute)
+	generation := c.Generation()
+	c.Bump()
+	c.Put("Post", schema, generation)
+	result = c.Get("Post")
+	if result != nil {
+		t.Error("expect:", nil, "result:", result)
+	}
+	/*****************************************************************/
+	c = NewClassCache(time.Millisecond)
+	c.Put("Post", schema, c.Generation())
+	time.Sleep(5 * time.Millisecond)
+	result = c.Get("Post")
+	if result != nil {
+		t.Error("expect:", nil, "result:", result)
+	}
+}
+
+func Test_ClassCache_Load(t *testing.T) {
+	c := NewClassCache(time.Minute)
+	calls := 0
+	fetch := func(className string) (types.M, error) {
+		calls++
+		if className == "Post" {
+			return types.M{"className": "Post"}, nil
+		}
+		return types.M{}, nil
+	}
+	/*****************************************************************/
+	c.Load("Post", fetch)
+	c.Load("Post", fetch)
+	if calls != 1 {
+		t.Error("expect:", 1, "result:", calls)
+	}
+	/*****************************************************************/
+	calls = 0
+	c.Load("Comment", fetch)
+	c.Load("Comment", fetch)
+	if calls != 2 {
+		t.Error("expect:", 2, "result:", calls)
+	}
+	/*****************************************************************/
+	calls = 0
+	c.Bump()
+	c.Load("Post", fetch)
+	if calls != 1 {
+		t.Error("expect:", 1, "result:", calls)
+	}
+}
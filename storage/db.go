@@ -1,27 +1,119 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/lib/pq" // postgres driver
 	"github.com/okobsamoht/talisman/config"
 	"github.com/okobsamoht/talisman/test"
-	"gopkg.in/mgo.v2"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
-// OpenMongoDB 打开 MongoDB
-func OpenMongoDB() *mgo.Database {
+// OpenMongoDB 使用官方驱动打开 MongoDB ，连接失败时 panic
+func OpenMongoDB() *mongo.Database {
 	// 此处仅用于测试
 	if config.TConfig.DatabaseURI == "" {
 		config.TConfig.DatabaseURI = test.MongoDBTestURL
 	}
 
-	session, err := mgo.Dial(config.TConfig.DatabaseURI)
+	c := config.TConfig
+	uri := mongoURI(c.DatabaseURI)
+	connectTimeout := time.Duration(c.MongoConnectTimeout) * time.Second
+	opts := options.Client().
+		ApplyURI(uri).
+		SetConnectTimeout(connectTimeout).
+		SetServerSelectionTimeout(connectTimeout).
+		SetReadPreference(mongoReadPref(c.MongoReadPreference)).
+		SetRetryWrites(c.MongoRetryWrites).
+		SetRetryReads(c.MongoRetryReads)
+	if c.MongoPoolLimit > 0 {
+		opts.SetMaxPoolSize(uint64(c.MongoPoolLimit))
+	}
+	if c.MongoReadConcern != "" {
+		opts.SetReadConcern(&readconcern.ReadConcern{Level: c.MongoReadConcern})
+	}
+	if wc := mongoWriteConcern(c.MongoWriteConcern, c.MongoWriteConcernTimeout, c.MongoJournal); wc != nil {
+		opts.SetWriteConcern(wc)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
 		panic(err)
 	}
-	session.SetMode(mgo.Monotonic, true)
-	return session.DB("")
+	// Connect 不会等待连接建立，通过 Ping 检查数据库是否可用
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		panic(err)
+	}
+	return client.Database(mongoDatabaseName(uri))
+}
+
+// mongoURI 兼容 mgo 格式的地址，如 127.0.0.1:27017/test ，缺少协议时添加 mongodb://
+func mongoURI(uri string) string {
+	if strings.HasPrefix(uri, "mongodb://") || strings.HasPrefix(uri, "mongodb+srv://") {
+		return uri
+	}
+	return "mongodb://" + uri
+}
+
+// mongoDatabaseName 读取地址中的数据库名，未指定时使用 test ，与 mgo 保持一致
+func mongoDatabaseName(uri string) string {
+	uri = uri[strings.Index(uri, "://")+len("://"):]
+	if i := strings.Index(uri, "?"); i >= 0 {
+		uri = uri[:i]
+	}
+	i := strings.Index(uri, "/")
+	if i < 0 || i == len(uri)-1 {
+		return "test"
+	}
+	return uri[i+1:]
+}
+
+// mongoReadPref 转换读取偏好，默认为 primary
+// 官方驱动不支持 mgo 的 monotonic 模式，为了读到自己的写入，按 primary 处理
+func mongoReadPref(readPreference string) *readpref.ReadPref {
+	switch readPreference {
+	case "primaryPreferred":
+		return readpref.PrimaryPreferred()
+	case "secondary":
+		return readpref.Secondary()
+	case "secondaryPreferred":
+		return readpref.SecondaryPreferred()
+	case "nearest":
+		return readpref.Nearest()
+	default:
+		return readpref.Primary()
+	}
+}
+
+// mongoWriteConcern 转换写关注， w 为整数时表示需要确认的节点数， majority 表示多数节点
+// 未设置任何参数时返回 nil ，使用驱动的默认值
+func mongoWriteConcern(w string, timeout int, journal bool) *writeconcern.WriteConcern {
+	if w == "" && timeout == 0 && journal == false {
+		return nil
+	}
+	wc := &writeconcern.WriteConcern{
+		WTimeout: time.Duration(timeout) * time.Millisecond,
+	}
+	if journal {
+		wc.Journal = &journal
+	}
+	if n, err := strconv.Atoi(w); err == nil {
+		wc.W = n
+	} else if w != "" {
+		wc.W = w
+	}
+	return wc
 }
 
 // OpenPostgreSQL 打开 PostgreSQL
@@ -32,3 +124,19 @@ func OpenPostgreSQL() *sql.DB {
 	}
 	return db
 }
+
+// OpenPostgreSQLReplicas 打开 PostgreSQL 只读副本，未配置时返回空列表
+func OpenPostgreSQLReplicas() []*sql.DB {
+	dbs := []*sql.DB{}
+	for _, uri := range strings.Split(config.TConfig.PostgresReadReplicaURIs, "|") {
+		if uri = strings.TrimSpace(uri); uri == "" {
+			continue
+		}
+		db, err := sql.Open("postgres", uri)
+		if err != nil {
+			panic(err)
+		}
+		dbs = append(dbs, db)
+	}
+	return dbs
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+func Test_mongoWriteConcern(t *testing.T) {
+	type args struct {
+		w       string
+		timeout int
+		journal bool
+	}
+	journal := true
+	tests := []struct {
+		name string
+		args args
+		want *writeconcern.WriteConcern
+	}{
+		{
+			name: "1",
+			args: args{w: "", timeout: 0, journal: false},
+			want: nil,
+		},
+		{
+			name: "2",
+			args: args{w: "2", timeout: 500, journal: false},
+			want: &writeconcern.WriteConcern{W: 2, WTimeout: 500 * time.Millisecond},
+		},
+		{
+			name: "3",
+			args: args{w: "majority", timeout: 0, journal: true},
+			want: &writeconcern.WriteConcern{W: "majority", Journal: &journal},
+		},
+		{
+			name: "4",
+			args: args{w: "", timeout: 0, journal: true},
+			want: &writeconcern.WriteConcern{Journal: &journal},
+		},
+	}
+	for _, tt := range tests {
+		if got := mongoWriteConcern(tt.args.w, tt.args.timeout, tt.args.journal); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. mongoWriteConcern() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_mongoReadPref(t *testing.T) {
+	tests := []struct {
+		name           string
+		readPreference string
+		want           readpref.Mode
+	}{
+		{name: "1", readPreference: "primary", want: readpref.PrimaryMode},
+		{name: "2", readPreference: "secondaryPreferred", want: readpref.SecondaryPreferredMode},
+		{name: "3", readPreference: "nearest", want: readpref.NearestMode},
+		{name: "4", readPreference: "monotonic", want: readpref.PrimaryMode},
+		{name: "5", readPreference: "", want: readpref.PrimaryMode},
+	}
+	for _, tt := range tests {
+		if got := mongoReadPref(tt.readPreference).Mode(); got != tt.want {
+			t.Errorf("%q. mongoReadPref() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_mongoURI(t *testing.T) {
+	tests := []struct {
+		name     string
+		uri      string
+		want     string
+		wantName string
+	}{
+		{name: "1", uri: "127.0.0.1:27017/talisman", want: "mongodb://127.0.0.1:27017/talisman", wantName: "talisman"},
+		{name: "2", uri: "mongodb://user:pass@a:27017,b:27017/talisman?replicaSet=rs0", want: "mongodb://user:pass@a:27017,b:27017/talisman?replicaSet=rs0", wantName: "talisman"},
+		{name: "3", uri: "mongodb+srv://cluster.example.com/?retryWrites=true", want: "mongodb+srv://cluster.example.com/?retryWrites=true", wantName: "test"},
+		{name: "4", uri: "127.0.0.1:27017", want: "mongodb://127.0.0.1:27017", wantName: "test"},
+	}
+	for _, tt := range tests {
+		got := mongoURI(tt.uri)
+		if got != tt.want {
+			t.Errorf("%q. mongoURI() = %v, want %v", tt.name, got, tt.want)
+		}
+		if name := mongoDatabaseName(got); name != tt.wantName {
+			t.Errorf("%q. mongoDatabaseName() = %v, want %v", tt.name, name, tt.wantName)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package storage
 
-import "github.com/okobsamoht/talisman/types"
+import (
+	"time"
+
+	"github.com/okobsamoht/talisman/types"
+)
 
 // Adapter 数据库操作适配器接口
 type Adapter interface {
@@ -24,3 +28,103 @@ type Adapter interface {
 	PerformInitialization(options types.M) error
 	HandleShutdown()
 }
+
+// EstimatedCounter 支持快速估算表中对象总数的适配器
+// 估算结果来自数据库的统计信息，不一定准确，但不需要扫描整张表
+type EstimatedCounter interface {
+	EstimatedCount(className string) (int, error)
+}
+
+// GeoIndexer 支持为 GeoPoint 与 Polygon 字段创建与删除地理位置索引的适配器
+// 索引已存在时 EnsureGeoIndex 不做任何操作，索引不存在时 DropGeoIndex 不返回错误
+type GeoIndexer interface {
+	EnsureGeoIndex(className, fieldName string) error
+	DropGeoIndex(className, fieldName string) error
+}
+
+// JoinIndexer 支持为 Relation 字段的 _Join 表创建索引的适配器
+// 在 owningId 与 relatedId 上分别创建以其开头、包含另一列的联合索引，查询 Join 表时只需要读取索引
+// 索引已存在时不做任何操作
+type JoinIndexer interface {
+	EnsureJoinIndexes(className, fieldName string) error
+}
+
+// Sequencer 支持自增序列的适配器，用于 AutoIncrement 类型的字段
+// NextSequenceValue 原子地返回类中字段的下一个值，从 1 开始，序列不存在时自动创建
+type Sequencer interface {
+	NextSequenceValue(className, fieldName string) (int, error)
+}
+
+// TTLIndexer 支持 TTL 索引的适配器，由数据库自动删除过期时间字段早于当前时间的对象
+// 不支持的适配器由后台任务定时删除过期对象
+type TTLIndexer interface {
+	EnsureTTLIndex(className, fieldName string) error
+	DropTTLIndex(className, fieldName string) error
+}
+
+// FieldTypeUpdater 支持修改已有字段定义的适配器，只修改保存的字段定义，不修改表结构与数据，用于增加 Enum 字段的取值
+type FieldTypeUpdater interface {
+	UpdateFieldType(className, fieldName string, fieldType types.M) error
+}
+
+// CaseInsensitiveIndexer 支持为 String 字段创建索引，加速不区分大小写的相等查询
+// 索引已存在时不做任何操作
+type CaseInsensitiveIndexer interface {
+	EnsureCaseInsensitiveIndex(className, fieldName string) error
+}
+
+// Indexer 支持创建由一个或者多个字段组成的普通索引的适配器，索引已存在时不做任何操作
+// fieldNames 中的字段按顺序组成索引，倒序的字段带有前缀 "-"
+type Indexer interface {
+	EnsureIndex(className string, schema types.M, fieldNames []string) error
+}
+
+// IndexManager 支持按名称查询、创建与删除索引的适配器，格式与 Parse Dashboard 相同
+// 索引为 {"字段名": 1} ，倒序为 -1 ，地理位置索引为 "2dsphere" ，无法用字段表示的索引不会返回
+type IndexManager interface {
+	// GetIndexes 返回类的所有索引，格式为 {"索引名": {"字段名": 1}}
+	GetIndexes(className string, schema types.M) (types.M, error)
+	// CreateIndex 按 fieldNames 的顺序创建索引，倒序的字段带有前缀 "-"
+	CreateIndex(className string, schema types.M, name string, fieldNames []string) error
+	// DropIndex 删除指定名称的索引
+	DropIndex(className, name string) error
+}
+
+// Upserter 支持更新或者插入一个对象并返回结果的适配器
+type Upserter interface {
+	// FindOneAndUpsert 更新符合 query 的一个对象，不存在时插入 insert ，返回更新或者插入后的对象，以及是否插入了新对象
+	// insert 为插入时使用的完整对象，包含 objectId 与 query 中的相等条件
+	FindOneAndUpsert(className string, schema, query, update, insert types.M) (types.M, bool, error)
+}
+
+// FindOneAndDeleter 支持原子地查找并删除一个对象的适配器
+type FindOneAndDeleter interface {
+	// FindOneAndDelete 删除符合 query 的一个对象并返回被删除的对象，没有符合条件的对象时返回空对象
+	// options 中的 sort 用于选择删除哪一个对象
+	FindOneAndDelete(className string, schema, query, options types.M) (types.M, error)
+}
+
+// ClassCacher 支持在适配器内部缓存 GetClass 结果的适配器
+type ClassCacher interface {
+	SetClassCacheTTL(ttl time.Duration)
+	// InvalidateClassCache 使缓存全部失效，用于其他实例修改类结构之后
+	InvalidateClassCache()
+}
+
+// ChangeWatcher 支持从数据库读取对象变更的适配器，外部程序直接写入数据库时也可以得到通知
+// MongoDB 读取 oplog ，需要以副本集方式运行； PostgreSQL 通过触发器记录变更
+type ChangeWatcher interface {
+	// WatchChanges 读取类名以 classPrefix 开头的类的对象变更，直到 stop 被关闭
+	// resumeToken 为上次处理的最后一个变更的 Token ，为空时从当前位置开始读取
+	WatchChanges(classPrefix, resumeToken string, handler func(change *Change), stop <-chan struct{}) error
+}
+
+// Change 对象变更
+// 数据库中无法得到变更前的对象，删除时 Object 仅包含 objectId
+type Change struct {
+	ClassName string
+	Created   bool
+	Deleted   bool
+	Object    types.M
+	Token     string
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+	"golang.org/x/text/unicode/norm"
+)
+
+// keyLimits 对象字段中嵌套键的限制
+type keyLimits struct {
+	maxDepth     int  // 最大嵌套层数， 0 表示不限制
+	maxKeyLength int  // 键的最大长度，按字符计算， 0 表示不限制
+	normalize    bool // 是否把键转换为 Unicode NFC 形式
+}
+
+// CheckNestedKeys 按配置校验创建或者更新数据中字段值内嵌套的键，返回处理后的数据，不修改 object
+// 字段值本身为第 1 层，带点的字段名如 a.b 的值从第 2 层开始计算，更新操作如 Add 中的 objects 与字段值相同
+// 未设置 MaxObjectNestingDepth 、 MaxObjectKeyLength 与 NormalizeObjectKeys 时直接返回 object
+func CheckNestedKeys(object types.M) (types.M, error) {
+	c := config.TConfig
+	return checkNestedKeys(object, keyLimits{
+		maxDepth:     c.MaxObjectNestingDepth,
+		maxKeyLength: c.MaxObjectKeyLength,
+		normalize:    c.NormalizeObjectKeys,
+	})
+}
+
+func checkNestedKeys(object types.M, limits keyLimits) (types.M, error) {
+	if object == nil || (limits.maxDepth <= 0 && limits.maxKeyLength <= 0 && limits.normalize == false) {
+		return object, nil
+	}
+	result := types.M{}
+	for key, value := range object {
+		// ACL 与 authData 中的键为用户 ID 与角色名，以及 _rperm 等内部字段，不做处理
+		if key == "ACL" || key == "authData" || strings.HasPrefix(key, "_") {
+			result[key] = value
+			continue
+		}
+		depth := 0
+		if strings.Contains(key, ".") {
+			components := strings.Split(key, ".")
+			for i := 1; i < len(components); i++ {
+				k, err := limits.checkKey(components[i])
+				if err != nil {
+					return nil, err
+				}
+				components[i] = k
+			}
+			key = strings.Join(components, ".")
+			depth = len(components) - 1
+		}
+		v, err := limits.checkValue(value, depth)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = v
+	}
+	return result, nil
+}
+
+// checkValue 处理位于第 depth 层容器中的值， op 中的 objects 等与 op 所在位置的值处于同一层
+func (l keyLimits) checkValue(value interface{}, depth int) (interface{}, error) {
+	if object := utils.M(value); object != nil {
+		if object["__op"] != nil {
+			return l.checkOp(object, depth)
+		}
+		if l.maxDepth > 0 && depth+1 > l.maxDepth {
+			return nil, errs.E(errs.InvalidNestedKey, "Nested objects should not be deeper than "+strconv.Itoa(l.maxDepth)+" levels")
+		}
+		result := types.M{}
+		for k, v := range object {
+			key, err := l.checkKey(k)
+			if err != nil {
+				return nil, err
+			}
+			r, err := l.checkValue(v, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = r
+		}
+		return result, nil
+	}
+	if array := utils.A(value); array != nil {
+		if l.maxDepth > 0 && depth+1 > l.maxDepth {
+			return nil, errs.E(errs.InvalidNestedKey, "Nested objects should not be deeper than "+strconv.Itoa(l.maxDepth)+" levels")
+		}
+		result := types.S{}
+		for _, v := range array {
+			r, err := l.checkValue(v, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, r)
+		}
+		return result, nil
+	}
+	return value, nil
+}
+
+// checkOp 处理更新操作，操作本身的键不做处理， UpdateMatching 的 match 与 set 为数组元素中的键
+func (l keyLimits) checkOp(op types.M, depth int) (interface{}, error) {
+	result := types.M{}
+	for k, v := range op {
+		var err error
+		switch k {
+		case "__op", "amount":
+			result[k] = v
+			continue
+		case "match", "set":
+			result[k], err = l.checkValue(v, depth+1)
+		case "ops":
+			ops := types.S{}
+			for _, o := range utils.A(v) {
+				r, err := l.checkValue(o, depth)
+				if err != nil {
+					return nil, err
+				}
+				ops = append(ops, r)
+			}
+			result[k] = ops
+		default:
+			result[k], err = l.checkValue(v, depth)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// checkKey 校验键的长度，需要时转换为 NFC 形式
+func (l keyLimits) checkKey(key string) (string, error) {
+	if l.normalize {
+		key = norm.NFC.String(key)
+	}
+	if l.maxKeyLength > 0 && utf8.RuneCountInString(key) > l.maxKeyLength {
+		return "", errs.E(errs.InvalidNestedKey, "Nested keys should not be longer than "+strconv.Itoa(l.maxKeyLength)+" characters")
+	}
+	return key, nil
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_checkNestedKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		object  types.M
+		limits  keyLimits
+		want    types.M
+		wantErr error
+	}{
+		{
+			name:    "1",
+			object:  types.M{"key": types.M{"a": types.M{"b": 1}}},
+			limits:  keyLimits{},
+			want:    types.M{"key": types.M{"a": types.M{"b": 1}}},
+			wantErr: nil,
+		},
+		{
+			name:    "2",
+			object:  types.M{"key": types.M{"a": types.M{"b": 1}}},
+			limits:  keyLimits{maxDepth: 2},
+			want:    types.M{"key": types.M{"a": types.M{"b": 1}}},
+			wantErr: nil,
+		},
+		{
+			name:    "3",
+			object:  types.M{"key": types.M{"a": types.S{types.M{"b": 1}}}},
+			limits:  keyLimits{maxDepth: 2},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidNestedKey, "Nested objects should not be deeper than 2 levels"),
+		},
+		{
+			name:    "4",
+			object:  types.M{"key.a.b": types.M{"c": 1}},
+			limits:  keyLimits{maxDepth: 2},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidNestedKey, "Nested objects should not be deeper than 2 levels"),
+		},
+		{
+			name:    "5",
+			object:  types.M{"key": types.M{"__op": "Add", "objects": types.S{types.M{"a": 1}}}},
+			limits:  keyLimits{maxDepth: 2},
+			want:    types.M{"key": types.M{"__op": "Add", "objects": types.S{types.M{"a": 1}}}},
+			wantErr: nil,
+		},
+		{
+			name:    "6",
+			object:  types.M{"key": types.M{"__op": "UpdateMatching", "match": types.M{"a": 1}, "set": types.M{"b": types.M{"c": 1}}}},
+			limits:  keyLimits{maxDepth: 2},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidNestedKey, "Nested objects should not be deeper than 2 levels"),
+		},
+		{
+			name:    "7",
+			object:  types.M{"key": types.M{"abcd": 1}, "ACL": types.M{"role:abcdefg": types.M{"read": true}}},
+			limits:  keyLimits{maxKeyLength: 4},
+			want:    types.M{"key": types.M{"abcd": 1}, "ACL": types.M{"role:abcdefg": types.M{"read": true}}},
+			wantErr: nil,
+		},
+		{
+			name:    "8",
+			object:  types.M{"key.abcde": 1},
+			limits:  keyLimits{maxKeyLength: 4},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidNestedKey, "Nested keys should not be longer than 4 characters"),
+		},
+		{
+			name:    "9",
+			object:  types.M{"key": types.M{"café": 1}},
+			limits:  keyLimits{maxKeyLength: 4},
+			want:    types.M{"key": types.M{"café": 1}},
+			wantErr: nil,
+		},
+		{
+			name:    "10",
+			object:  types.M{"key": types.M{"café": types.S{types.M{"café": 1}}}, "key.café": 1},
+			limits:  keyLimits{normalize: true},
+			want:    types.M{"key": types.M{"café": types.S{types.M{"café": 1}}}, "key.café": 1},
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		got, err := checkNestedKeys(tt.object, tt.limits)
+		if reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. checkNestedKeys() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("%q. checkNestedKeys() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
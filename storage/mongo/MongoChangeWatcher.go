@@ -0,0 +1,154 @@
+package mongo
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/okobsamoht/talisman/storage"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WatchChanges 读取 oplog 中的对象变更， Token 为 oplog 中的 ts
+// 新增与更新时从数据库中读取当前的对象，游标失效时从最后处理的位置重新读取
+func (m *MongoAdapter) WatchChanges(classPrefix, resumeToken string, handler func(change *storage.Change), stop <-chan struct{}) error {
+	last, err := parseOplogToken(resumeToken)
+	if err != nil {
+		return err
+	}
+	if last.IsZero() {
+		last = primitive.Timestamp{T: uint32(time.Now().Unix())}
+	}
+
+	// 收到 stop 或者适配器关闭时取消正在等待的读取
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	oplog := m.db.Client().Database("local").Collection("oplog.rs", options.Collection().SetRegistry(mongoRegistry))
+	ns := m.db.Name() + "." + m.collectionPrefix
+	opts := options.Find().SetCursorType(options.TailableAwait).SetMaxAwaitTime(time.Second)
+
+	for {
+		cursor, err := oplog.Find(ctx, oplogQuery(ns, classPrefix, last), opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		// TryNext 在等待 MaxAwaitTime 之后没有新的数据时返回 false ，游标失效时 ID 为 0
+		for cursor.ID() != 0 || cursor.RemainingBatchLength() > 0 {
+			if cursor.TryNext(ctx) == false {
+				if cursor.Err() != nil {
+					break
+				}
+				continue
+			}
+			var entry types.M
+			if err := cursor.Decode(&entry); err != nil {
+				cursor.Close(context.Background())
+				return err
+			}
+			if ts, ok := entry["ts"].(primitive.Timestamp); ok {
+				last = ts
+			}
+			if change := m.oplogChange(ns, entry); change != nil {
+				change.Token = oplogToken(last)
+				handler(change)
+			}
+		}
+		err = cursor.Err()
+		cursor.Close(context.Background())
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// oplogQuery 查询 ts 之后指定类的新增、更新、删除操作
+func oplogQuery(ns, classPrefix string, after primitive.Timestamp) types.M {
+	return types.M{
+		"ts": types.M{"$gt": after},
+		"ns": types.M{"$regex": "^" + regexp.QuoteMeta(ns+classPrefix)},
+		"op": types.M{"$in": types.S{"i", "u", "d"}},
+	}
+}
+
+// oplogChange 把 oplog 转换为对象变更，对象已不存在或者不是对象所在的集合时返回 nil
+func (m *MongoAdapter) oplogChange(ns string, entry types.M) *storage.Change {
+	className := strings.TrimPrefix(utils.S(entry["ns"]), ns)
+	if className == "" || className == "_SCHEMA" {
+		return nil
+	}
+
+	var id interface{}
+	op := utils.S(entry["op"])
+	switch op {
+	case "i", "d":
+		id = utils.M(entry["o"])["_id"]
+	case "u":
+		id = utils.M(entry["o2"])["_id"]
+	}
+	objectID, ok := id.(string)
+	if ok == false {
+		return nil
+	}
+	if op == "d" {
+		return &storage.Change{
+			ClassName: className,
+			Deleted:   true,
+			Object:    types.M{"objectId": objectID},
+		}
+	}
+
+	schema, err := m.GetClass(className)
+	if err != nil || len(schema) == 0 {
+		return nil
+	}
+	objects, err := m.Find(className, schema, types.M{"objectId": objectID}, types.M{"limit": 1})
+	if err != nil || len(objects) == 0 {
+		return nil
+	}
+	return &storage.Change{
+		ClassName: className,
+		Created:   op == "i",
+		Object:    objects[0],
+	}
+}
+
+// oplogToken 把 ts 转换为 Token ，高 32 位为秒数，低 32 位为序号，与 mgo 的 MongoTimestamp 格式相同
+func oplogToken(ts primitive.Timestamp) string {
+	return strconv.FormatInt(int64(ts.T)<<32|int64(ts.I), 10)
+}
+
+func parseOplogToken(token string) (primitive.Timestamp, error) {
+	if token == "" {
+		return primitive.Timestamp{}, nil
+	}
+	ts, err := strconv.ParseInt(token, 10, 64)
+	if err != nil {
+		return primitive.Timestamp{}, err
+	}
+	return primitive.Timestamp{T: uint32(ts >> 32), I: uint32(ts)}, nil
+}
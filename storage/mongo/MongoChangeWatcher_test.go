@@ -0,0 +1,59 @@
+package mongo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_oplogQuery(t *testing.T) {
+	var result, expect types.M
+	/*****************************************************************/
+	result = oplogQuery("test.", "", primitive.Timestamp{I: 10})
+	expect = types.M{
+		"ts": types.M{"$gt": primitive.Timestamp{I: 10}},
+		"ns": types.M{"$regex": `^test\.`},
+		"op": types.M{"$in": types.S{"i", "u", "d"}},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************************/
+	result = oplogQuery("test.tomato_", "Game", primitive.Timestamp{I: 10})
+	expect = types.M{
+		"ts": types.M{"$gt": primitive.Timestamp{I: 10}},
+		"ns": types.M{"$regex": `^test\.tomato_Game`},
+		"op": types.M{"$in": types.S{"i", "u", "d"}},
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
+
+func Test_parseOplogToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		want    primitive.Timestamp
+		wantErr bool
+	}{
+		{name: "1", token: "", want: primitive.Timestamp{}},
+		{name: "2", token: "6405784226118647809", want: primitive.Timestamp{T: 1491462864, I: 2040152065}},
+		{name: "3", token: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseOplogToken(tt.token)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. parseOplogToken() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q. parseOplogToken() = %v, want %v", tt.name, got, tt.want)
+		}
+		if tt.token != "" && tt.wantErr == false && oplogToken(got) != tt.token {
+			t.Errorf("%q. oplogToken() = %v, want %v", tt.name, oplogToken(got), tt.token)
+		}
+	}
+}
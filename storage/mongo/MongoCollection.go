@@ -1,26 +1,76 @@
 package mongo
 
 import (
+	"context"
+	"fmt"
+	"reflect"
 	"strings"
-
 	"time"
 
 	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/storage"
 	"github.com/okobsamoht/talisman/types"
-	"gopkg.in/mgo.v2"
+	"github.com/okobsamoht/talisman/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// mongoRegistry 解码时嵌套对象使用 types.M ，数组使用 []interface{} ，日期使用 time.Time ，二进制使用 []byte ， int32 使用 int
+// 与 mgo 解码得到的类型保持一致， Transform 依赖这些类型
+var mongoRegistry = newMongoRegistry()
+
+func newMongoRegistry() *bsoncodec.Registry {
+	registry := bson.NewRegistry()
+	registry.RegisterTypeMapEntry(bson.TypeEmbeddedDocument, reflect.TypeOf(types.M{}))
+	registry.RegisterTypeMapEntry(bson.TypeArray, reflect.TypeOf([]interface{}{}))
+	registry.RegisterTypeMapEntry(bson.TypeDateTime, reflect.TypeOf(time.Time{}))
+	registry.RegisterTypeMapEntry(bson.TypeBinary, reflect.TypeOf([]byte{}))
+	registry.RegisterTypeMapEntry(bson.TypeInt32, reflect.TypeOf(0))
+	return registry
+}
+
+// errNotFound 要更新或者删除的对象不存在
+var errNotFound = mongo.ErrNoDocuments
+
+// mongoIndex 集合中的索引， Key 的格式与排序字段相同，倒序的字段带有前缀 "-" ， 2dsphere 索引的字段带有前缀 "$2dsphere:"
+type mongoIndex struct {
+	Name        string
+	Key         []string
+	Unique      bool
+	Sparse      bool
+	Background  bool
+	ExpireAfter time.Duration
+}
+
 // MongoCollection mongo 表操作对象
 type MongoCollection struct {
-	collection *mgo.Collection
+	collection *mongo.Collection
+	ctx        context.Context
+	timeout    time.Duration // 单次操作的超时时间，为 0 时不超时
 }
 
-func newMongoCollection(collection *mgo.Collection) *MongoCollection {
+func newMongoCollection(ctx context.Context, collection *mongo.Collection) *MongoCollection {
 	return &MongoCollection{
 		collection: collection,
+		ctx:        ctx,
 	}
 }
 
+// operationContext 返回单次操作使用的 context ，适配器关闭时取消正在执行的操作
+func (m *MongoCollection) operationContext() (context.Context, context.CancelFunc) {
+	return operationContext(m.ctx, m.timeout)
+}
+
+// operationContext 从 ctx 派生单次操作的 context ， timeout 为 0 时不超时
+func operationContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
 // find 执行查找操作，自动添加索引
 func (m *MongoCollection) find(query interface{}, options types.M) ([]types.M, error) {
 	result, err := m.rawFind(query, options)
@@ -43,11 +93,7 @@ func (m *MongoCollection) find(query interface{}, options types.M) ([]types.M, e
 		}
 		key := msg[:end]
 		// 添加索引
-		index := mgo.Index{
-			Key:  []string{"$2dsphere:" + key},
-			Bits: 26,
-		}
-		m.collection.EnsureIndex(index)
+		m.ensureGeoIndexInBackground(key)
 		// 再次尝试查询
 		result, err = m.rawFind(query, options)
 		if err != nil {
@@ -70,102 +116,211 @@ func (m *MongoCollection) rawFind(query interface{}, options types.M) ([]types.M
 	if options == nil {
 		options = types.M{}
 	}
-	q := m.collection.Find(query)
-	if options["sort"] != nil {
-		if sort, ok := options["sort"].([]string); ok {
-			q = q.Sort(sort...)
-		}
+	opts := findOptions(options)
+	if options["keys"] != nil {
+		opts.SetProjection(options["keys"])
 	}
-	if options["skip"] != nil {
-		if skip, ok := options["skip"].(float64); ok {
-			q = q.Skip(int(skip))
-		} else if skip, ok := options["skip"].(int); ok {
-			q = q.Skip(skip)
-		}
+	if requestID, ok := options["requestId"].(string); ok && utils.IsRequestID(requestID) {
+		opts.SetComment("requestId:" + requestID)
 	}
-	if options["limit"] != nil {
-		if limit, ok := options["limit"].(float64); ok {
-			q = q.Limit(int(limit))
-		} else if limit, ok := options["limit"].(int); ok {
-			q = q.Limit(limit)
-		}
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	cursor, err := m.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
 	}
-	if options["keys"] != nil {
-		q = q.Select(options["keys"])
+	defer cursor.Close(ctx)
+	guard := storage.NewResultGuard(options)
+	if guard == nil {
+		var result []types.M
+		err := cursor.All(ctx, &result)
+		return result, err
 	}
-	if options["maxTimeMS"] != nil {
-		if limit, ok := options["maxTimeMS"].(float64); ok {
-			q = q.SetMaxTime(time.Duration(limit) * time.Millisecond)
-		} else if limit, ok := options["maxTimeMS"].(int); ok {
-			q = q.SetMaxTime(time.Duration(limit) * time.Millisecond)
+
+	// 限制结果大小时逐个读取，超出限制时立即中止，不再读取剩余的数据
+	result := []types.M{}
+	for cursor.Next(ctx) {
+		err := guard.Add(len(cursor.Current))
+		if err == nil {
+			var object types.M
+			err = cursor.Decode(&object)
+			result = append(result, object)
+		}
+		if err != nil {
+			return nil, err
 		}
 	}
-	var result []types.M
-	err := q.All(&result)
-	return result, err
+	return result, cursor.Err()
 }
 
-// count 执行 count 操作，查找选项包括 sort、skip、limit、maxTimeMS
-func (m *MongoCollection) count(query interface{}, options types.M) int {
-	if options == nil {
-		options = types.M{}
+// findOptions 转换查找选项中的 sort、skip、limit、maxTimeMS
+func findOptions(opts types.M) *options.FindOptions {
+	result := options.Find()
+	if sort, ok := opts["sort"].([]string); ok && len(sort) > 0 {
+		result.SetSort(sortDoc(sort))
 	}
-	q := m.collection.Find(query)
-	if options["sort"] != nil {
-		if sort, ok := options["sort"].([]string); ok {
-			q = q.Sort(sort...)
-		}
+	if skip, ok := intOption(opts["skip"]); ok {
+		result.SetSkip(int64(skip))
 	}
-	if options["skip"] != nil {
-		if skip, ok := options["skip"].(float64); ok {
-			q = q.Skip(int(skip))
-		} else if skip, ok := options["skip"].(int); ok {
-			q = q.Skip(skip)
-		}
+	if limit, ok := intOption(opts["limit"]); ok {
+		result.SetLimit(int64(limit))
 	}
-	if options["limit"] != nil {
-		if limit, ok := options["limit"].(float64); ok {
-			q = q.Limit(int(limit))
-		} else if limit, ok := options["limit"].(int); ok {
-			q = q.Limit(limit)
-		}
+	if maxTimeMS, ok := intOption(opts["maxTimeMS"]); ok {
+		result.SetMaxTime(time.Duration(maxTimeMS) * time.Millisecond)
+	}
+	return result
+}
+
+// intOption 读取 float64 或者 int 类型的选项
+func intOption(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
 	}
-	if options["maxTimeMS"] != nil {
-		if limit, ok := options["maxTimeMS"].(float64); ok {
-			q = q.SetMaxTime(time.Duration(limit) * time.Millisecond)
-		} else if limit, ok := options["maxTimeMS"].(int); ok {
-			q = q.SetMaxTime(time.Duration(limit) * time.Millisecond)
+	return 0, false
+}
+
+// sortDoc 把排序字段转换为有序的文档，倒序的字段带有前缀 "-"
+func sortDoc(keys []string) bson.D {
+	doc := bson.D{}
+	for _, key := range keys {
+		if strings.HasPrefix(key, "-") {
+			doc = append(doc, bson.E{Key: key[1:], Value: -1})
+		} else {
+			doc = append(doc, bson.E{Key: key, Value: 1})
 		}
 	}
-	n, err := q.Count()
+	return doc
+}
+
+// count 执行 count 操作，查找选项包括 skip、limit、maxTimeMS ， sort 不影响计数结果
+func (m *MongoCollection) count(query interface{}, opts types.M) int {
+	countOpts := options.Count()
+	// CountDocuments 使用聚合实现， skip 与 limit 为 0 时不能传入
+	if skip, ok := intOption(opts["skip"]); ok && skip > 0 {
+		countOpts.SetSkip(int64(skip))
+	}
+	if limit, ok := intOption(opts["limit"]); ok && limit > 0 {
+		countOpts.SetLimit(int64(limit))
+	}
+	if maxTimeMS, ok := intOption(opts["maxTimeMS"]); ok {
+		countOpts.SetMaxTime(time.Duration(maxTimeMS) * time.Millisecond)
+	}
+	if query == nil {
+		query = types.M{}
+	}
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	n, err := m.collection.CountDocuments(ctx, query, countOpts)
 	if err != nil {
 		return 0
 	}
-	return n
+	return int(n)
+}
+
+// estimatedCount 不带查询条件的 count ，直接使用集合的统计信息
+func (m *MongoCollection) estimatedCount() (int, error) {
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	n, err := m.collection.EstimatedDocumentCount(ctx)
+	return int(n), err
 }
 
 // findOneAndUpdate 查找并更新一个对象，返回更新后的对象
 func (m *MongoCollection) findOneAndUpdate(selector interface{}, update interface{}) types.M {
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	var result types.M
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := m.collection.FindOneAndUpdate(ctx, selector, update, opts).Decode(&result)
+	if err != nil {
+		return types.M{}
+	}
+	return result
+}
 
+// findOneAndUpsert 更新一个对象，不存在时插入，返回更新或者插入后的对象，以及是否插入了新对象
+// 驱动的 FindOneAndUpdate 不返回是否插入了新对象，所以直接执行 findAndModify 命令，命令使用数据库的写关注
+func (m *MongoCollection) findOneAndUpsert(selector interface{}, update interface{}) (types.M, bool, error) {
+	cmd := bson.D{
+		{Key: "findAndModify", Value: m.collection.Name()},
+		{Key: "query", Value: selector},
+		{Key: "update", Value: update},
+		{Key: "upsert", Value: true},
+		{Key: "new", Value: true},
+	}
+	if wc := m.collection.Database().WriteConcern(); wc != nil {
+		cmd = append(cmd, bson.E{Key: "writeConcern", Value: wc})
+	}
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	raw, err := m.collection.Database().RunCommand(ctx, cmd).Raw()
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, false, errs.E(errs.DuplicateValue, "A duplicate value for a field with unique values was provided")
+		}
+		return nil, false, err
+	}
+	var reply struct {
+		Value           bson.Raw `bson:"value"`
+		LastErrorObject struct {
+			UpdatedExisting bool `bson:"updatedExisting"`
+		} `bson:"lastErrorObject"`
+	}
+	if err := bson.Unmarshal(raw, &reply); err != nil {
+		return nil, false, err
+	}
 	var result types.M
-	change := mgo.Change{
-		Update:    update,
-		ReturnNew: true,
+	if err := bson.UnmarshalWithRegistry(mongoRegistry, reply.Value, &result); err != nil {
+		return nil, false, err
 	}
-	info, err := m.collection.Find(selector).Apply(change, &result)
-	if err != nil || info.Updated == 0 {
-		return types.M{}
+	return result, reply.LastErrorObject.UpdatedExisting == false, nil
+}
+
+// nextSequenceValue 原子地把 id 对应的计数加 1 ，不存在时从 1 开始，返回加 1 之后的值
+func (m *MongoCollection) nextSequenceValue(id string) (int, error) {
+	var result struct {
+		Seq int `bson:"seq"`
 	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	err := m.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"seq": 1}}, opts).Decode(&result)
+	if err != nil {
+		return 0, err
+	}
+	return result.Seq, nil
+}
 
-	return result
+// findOneAndDelete 按 sort 排序删除符合条件的第一个对象，返回被删除的对象，没有符合条件的对象时返回空对象
+func (m *MongoCollection) findOneAndDelete(selector interface{}, sort []string) (types.M, error) {
+	opts := options.FindOneAndDelete()
+	if len(sort) > 0 {
+		opts.SetSort(sortDoc(sort))
+	}
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	var result types.M
+	err := m.collection.FindOneAndDelete(ctx, selector, opts).Decode(&result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return types.M{}, nil
+		}
+		return nil, err
+	}
+	return result, nil
 }
 
 // insertOne 插入一个对象
 func (m *MongoCollection) insertOne(docs interface{}) error {
-	err := m.collection.Insert(docs)
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	_, err := m.collection.InsertOne(ctx, docs)
 	if err != nil {
 		// 键值重复错误单独处理
-		if strings.Index(err.Error(), "duplicate key error") > -1 {
+		if mongo.IsDuplicateKeyError(err) {
 			return errs.E(errs.DuplicateValue, "A duplicate value for a field with unique values was provided")
 		}
 		return err
@@ -175,48 +330,206 @@ func (m *MongoCollection) insertOne(docs interface{}) error {
 
 // upsertOne 更新一个对象，如果要更新的对象不存在，则插入该对象
 func (m *MongoCollection) upsertOne(selector interface{}, update interface{}) error {
-	_, err := m.collection.Upsert(selector, update)
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	_, err := m.collection.UpdateOne(ctx, selector, update, options.Update().SetUpsert(true))
 	return err
 }
 
-// updateOne 更新一个对象
+// updateOne 更新一个对象，没有符合条件的对象时返回 errNotFound
 func (m *MongoCollection) updateOne(selector interface{}, update interface{}) error {
-	return m.collection.Update(selector, update)
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	result, err := m.collection.UpdateOne(ctx, selector, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errNotFound
+	}
+	return nil
 }
 
 // updateMany 更新多个对象
 func (m *MongoCollection) updateMany(selector interface{}, update interface{}) error {
-	_, err := m.collection.UpdateAll(selector, update)
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	_, err := m.collection.UpdateMany(ctx, selector, update)
 	return err
 }
 
-// deleteOne 删除一个对象
+// deleteOne 删除一个对象，没有符合条件的对象时返回 errNotFound
 func (m *MongoCollection) deleteOne(selector interface{}) error {
-	return m.collection.Remove(selector)
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	result, err := m.collection.DeleteOne(ctx, selector)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errNotFound
+	}
+	return nil
 }
 
 // deleteMany 删除多个对象
 func (m *MongoCollection) deleteMany(selector interface{}) (int, error) {
-	info, err := m.collection.RemoveAll(selector)
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	result, err := m.collection.DeleteMany(ctx, selector)
 	if err != nil {
 		return 0, err
 	}
-	n := info.Removed
-	return n, nil
+	return int(result.DeletedCount), nil
 }
 
-// drop 删除当前表
+// drop 删除当前表，表不存在时不返回错误
 func (m *MongoCollection) drop() error {
-	return m.collection.DropCollection()
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	return m.collection.Drop(ctx)
+}
+
+// ensureIndex 创建索引，相同的索引已经存在时不做任何操作
+func (m *MongoCollection) ensureIndex(keys []string, opts *options.IndexOptions) error {
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: indexKeys(keys), Options: opts})
+	return err
+}
+
+// indexKeys 把索引字段转换为有序的文档，倒序的字段带有前缀 "-" ， 2dsphere 索引的字段带有前缀 "$2dsphere:"
+func indexKeys(keys []string) bson.D {
+	doc := bson.D{}
+	for _, key := range keys {
+		switch {
+		case strings.HasPrefix(key, "$2dsphere:"):
+			doc = append(doc, bson.E{Key: strings.TrimPrefix(key, "$2dsphere:"), Value: "2dsphere"})
+		case strings.HasPrefix(key, "-"):
+			doc = append(doc, bson.E{Key: key[1:], Value: -1})
+		default:
+			doc = append(doc, bson.E{Key: key, Value: 1})
+		}
+	}
+	return doc
+}
+
+// indexName 生成与数据库默认规则相同的索引名，如 ["name", "-age"] 生成 name_1_age_-1
+func indexName(keys []string) string {
+	parts := []string{}
+	for _, e := range indexKeys(keys) {
+		parts = append(parts, e.Key, fmt.Sprint(e.Value))
+	}
+	return strings.Join(parts, "_")
 }
 
 // ensureSparseUniqueIndexInBackground 后台创建索引
 func (m *MongoCollection) ensureSparseUniqueIndexInBackground(indexRequest []string) error {
-	index := mgo.Index{
-		Key:        indexRequest,
-		Unique:     true,
-		Background: true,
-		Sparse:     true,
+	return m.ensureIndex(indexRequest, options.Index().SetUnique(true).SetBackground(true).SetSparse(true))
+}
+
+// ensureIndexInBackground 后台创建普通索引
+func (m *MongoCollection) ensureIndexInBackground(key string) error {
+	return m.ensureIndex([]string{key}, options.Index().SetBackground(true))
+}
+
+// ensureCompoundIndexInBackground 后台创建由多个字段组成的索引，倒序的字段带有前缀 "-"
+func (m *MongoCollection) ensureCompoundIndexInBackground(keys []string) error {
+	return m.ensureIndex(keys, options.Index().SetBackground(true))
+}
+
+// ensureNamedIndexInBackground 后台创建指定名称的索引
+func (m *MongoCollection) ensureNamedIndexInBackground(name string, keys []string) error {
+	return m.ensureIndex(keys, options.Index().SetName(name).SetBackground(true))
+}
+
+// indexes 返回集合的所有索引，集合不存在时返回空列表
+func (m *MongoCollection) indexes() ([]mongoIndex, error) {
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	// 集合不存在时驱动返回空的游标
+	cursor, err := m.collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return m.collection.EnsureIndex(index)
+	defer cursor.Close(ctx)
+
+	indexes := []mongoIndex{}
+	for cursor.Next(ctx) {
+		var spec struct {
+			Name               string `bson:"name"`
+			Key                bson.D `bson:"key"`
+			Unique             bool   `bson:"unique"`
+			Sparse             bool   `bson:"sparse"`
+			Background         bool   `bson:"background"`
+			ExpireAfterSeconds *int   `bson:"expireAfterSeconds"`
+		}
+		if err := bson.UnmarshalWithRegistry(mongoRegistry, cursor.Current, &spec); err != nil {
+			return nil, err
+		}
+		index := mongoIndex{
+			Name:       spec.Name,
+			Key:        []string{},
+			Unique:     spec.Unique,
+			Sparse:     spec.Sparse,
+			Background: spec.Background,
+		}
+		for _, e := range spec.Key {
+			key := e.Key
+			switch v := e.Value.(type) {
+			case string:
+				key = "$" + v + ":" + key
+			case int:
+				if v < 0 {
+					key = "-" + key
+				}
+			case int64:
+				if v < 0 {
+					key = "-" + key
+				}
+			case float64:
+				if v < 0 {
+					key = "-" + key
+				}
+			}
+			index.Key = append(index.Key, key)
+		}
+		if spec.ExpireAfterSeconds != nil {
+			index.ExpireAfter = time.Duration(*spec.ExpireAfterSeconds) * time.Second
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, cursor.Err()
+}
+
+// dropIndexName 删除指定名称的索引
+func (m *MongoCollection) dropIndexName(name string) error {
+	ctx, cancel := m.operationContext()
+	defer cancel()
+	_, err := m.collection.Indexes().DropOne(ctx, name)
+	return err
+}
+
+// ensureGeoIndexInBackground 后台创建 2dsphere 索引
+func (m *MongoCollection) ensureGeoIndexInBackground(key string) error {
+	return m.ensureIndex([]string{"$2dsphere:" + key}, options.Index().SetBackground(true).SetBits(26))
+}
+
+// ensureTTLIndexInBackground 后台创建 TTL 索引，对象在字段时间之后约 1 秒过期
+func (m *MongoCollection) ensureTTLIndexInBackground(key string) error {
+	return m.ensureIndex([]string{key}, options.Index().SetBackground(true).SetExpireAfterSeconds(1))
+}
+
+// dropIndex 删除索引，索引不存在时忽略错误
+func (m *MongoCollection) dropIndex(key string) error {
+	err := m.dropIndexName(indexName([]string{key}))
+	if err != nil && strings.Index(err.Error(), "index not found") > -1 {
+		return nil
+	}
+	return err
+}
+
+// dropGeoIndex 删除 2dsphere 索引，索引不存在时忽略错误
+func (m *MongoCollection) dropGeoIndex(key string) error {
+	return m.dropIndex("$2dsphere:" + key)
 }
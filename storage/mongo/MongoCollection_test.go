@@ -1,21 +1,25 @@
 package mongo
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/okobsamoht/talisman/errs"
 	"github.com/okobsamoht/talisman/test"
 	"github.com/okobsamoht/talisman/types"
 
-	"gopkg.in/mgo.v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func Test_find(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
-	mc := &MongoCollection{collection: db.C("obj")}
+	defer db.Client().Disconnect(context.Background())
+	mc := newTestCollection(db, "obj")
 	var docs interface{}
 	var query interface{}
 	var options types.M
@@ -63,8 +67,8 @@ func Test_find(t *testing.T) {
 
 func Test_rawFind(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
-	mc := &MongoCollection{collection: db.C("obj")}
+	defer db.Client().Disconnect(context.Background())
+	mc := newTestCollection(db, "obj")
 	var docs interface{}
 	var query interface{}
 	var options types.M
@@ -438,11 +442,7 @@ func Test_rawFind(t *testing.T) {
 	mc.insertOne(docs)
 	docs = types.M{"_id": "004", "name": "ann", "location": types.S{10, 10}}
 	mc.insertOne(docs)
-	index := mgo.Index{
-		Key:  []string{"$2dsphere:location"},
-		Bits: 26,
-	}
-	mc.collection.EnsureIndex(index)
+	mc.ensureGeoIndexInBackground("location")
 	query = types.M{
 		"location": types.M{
 			"$nearSphere": types.M{
@@ -496,8 +496,8 @@ func Test_rawFind(t *testing.T) {
 
 func Test_count(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
-	mc := &MongoCollection{collection: db.C("obj")}
+	defer db.Client().Disconnect(context.Background())
+	mc := newTestCollection(db, "obj")
 	var docs interface{}
 	var selector interface{}
 	var count int
@@ -534,8 +534,8 @@ func Test_count(t *testing.T) {
 
 func Test_findOneAndUpdate(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
-	mc := &MongoCollection{collection: db.C("obj")}
+	defer db.Client().Disconnect(context.Background())
+	mc := newTestCollection(db, "obj")
 	var docs interface{}
 	var selector interface{}
 	var update interface{}
@@ -594,8 +594,8 @@ func Test_findOneAndUpdate(t *testing.T) {
 
 func Test_insertOne(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
-	mc := &MongoCollection{collection: db.C("obj")}
+	defer db.Client().Disconnect(context.Background())
+	mc := newTestCollection(db, "obj")
 	var docs interface{}
 	var result []types.M
 	var err error
@@ -645,8 +645,8 @@ func Test_insertOne(t *testing.T) {
 
 func Test_upsertOne(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
-	mc := &MongoCollection{collection: db.C("obj")}
+	defer db.Client().Disconnect(context.Background())
+	mc := newTestCollection(db, "obj")
 	var docs interface{}
 	var selector interface{}
 	var update interface{}
@@ -691,8 +691,8 @@ func Test_upsertOne(t *testing.T) {
 
 func Test_updateOne(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
-	mc := &MongoCollection{collection: db.C("obj")}
+	defer db.Client().Disconnect(context.Background())
+	mc := newTestCollection(db, "obj")
 	var docs interface{}
 	var selector interface{}
 	var update interface{}
@@ -856,8 +856,8 @@ func Test_updateOne(t *testing.T) {
 
 func Test_updateMany(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
-	mc := &MongoCollection{collection: db.C("obj")}
+	defer db.Client().Disconnect(context.Background())
+	mc := newTestCollection(db, "obj")
 	var docs interface{}
 	var selector interface{}
 	var update interface{}
@@ -913,8 +913,8 @@ func Test_updateMany(t *testing.T) {
 
 func Test_deleteOne(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
-	mc := &MongoCollection{collection: db.C("obj")}
+	defer db.Client().Disconnect(context.Background())
+	mc := newTestCollection(db, "obj")
 	var docs interface{}
 	var selector interface{}
 	var result []types.M
@@ -951,8 +951,8 @@ func Test_deleteOne(t *testing.T) {
 
 func Test_deleteMany(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
-	mc := &MongoCollection{collection: db.C("obj")}
+	defer db.Client().Disconnect(context.Background())
+	mc := newTestCollection(db, "obj")
 	var docs interface{}
 	var selector interface{}
 	var result []types.M
@@ -1005,8 +1005,8 @@ func Test_deleteMany(t *testing.T) {
 
 func Test_drop(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
-	mc := &MongoCollection{collection: db.C("obj")}
+	defer db.Client().Disconnect(context.Background())
+	mc := newTestCollection(db, "obj")
 	var docs interface{}
 	var result []types.M
 	var err error
@@ -1036,13 +1036,13 @@ func Test_drop(t *testing.T) {
 
 func Test_ensureSparseUniqueIndexInBackground(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
-	mc := &MongoCollection{collection: db.C("obj")}
+	defer db.Client().Disconnect(context.Background())
+	mc := newTestCollection(db, "obj")
 	var docs interface{}
 	var indexRequest []string
 	var err error
-	var indexes []mgo.Index
-	var expect mgo.Index
+	var indexes []mongoIndex
+	var expect mongoIndex
 	var ok bool
 	/********************************************************/
 	docs = types.M{"_id": "001", "name": "joe", "age": 25}
@@ -1052,8 +1052,8 @@ func Test_ensureSparseUniqueIndexInBackground(t *testing.T) {
 	if err != nil {
 		t.Error("expect:", nil, "get result:", err)
 	}
-	indexes, err = mc.collection.Indexes()
-	expect = mgo.Index{
+	indexes, err = mc.indexes()
+	expect = mongoIndex{
 		Key:        []string{"name"},
 		Unique:     true,
 		Background: true,
@@ -1081,8 +1081,8 @@ func Test_ensureSparseUniqueIndexInBackground(t *testing.T) {
 	if err != nil {
 		t.Error("expect:", nil, "get result:", err)
 	}
-	indexes, err = mc.collection.Indexes()
-	expect = mgo.Index{
+	indexes, err = mc.indexes()
+	expect = mongoIndex{
 		Key:        []string{"skill"},
 		Unique:     true,
 		Background: true,
@@ -1108,8 +1108,8 @@ func Test_ensureSparseUniqueIndexInBackground(t *testing.T) {
 	if err != nil {
 		t.Error("expect:", nil, "get result:", err)
 	}
-	indexes, err = mc.collection.Indexes()
-	expect = mgo.Index{
+	indexes, err = mc.indexes()
+	expect = mongoIndex{
 		Key:        []string{"age"},
 		Unique:     true,
 		Background: true,
@@ -1131,6 +1131,56 @@ func Test_ensureSparseUniqueIndexInBackground(t *testing.T) {
 	mc.drop()
 }
 
-func openDB() *mgo.Database {
+func openDB() *mongo.Database {
 	return test.OpenMongoDBForTest()
 }
+
+// newTestCollection 使用与适配器相同的解码规则
+func newTestCollection(db *mongo.Database, name string) *MongoCollection {
+	return newMongoCollection(context.Background(), db.Collection(name, options.Collection().SetRegistry(mongoRegistry)))
+}
+
+func Test_mongoRegistry(t *testing.T) {
+	date := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	data, err := bson.Marshal(types.M{
+		"object": types.M{"key": "hello"},
+		"array":  types.S{int32(1), "a"},
+		"date":   date,
+		"bytes":  []byte("hello"),
+		"int32":  int32(10),
+		"int64":  int64(10),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result types.M
+	err = bson.UnmarshalWithRegistry(mongoRegistry, data, &result)
+	expect := types.M{
+		"object": types.M{"key": "hello"},
+		"array":  []interface{}{1, "a"},
+		"date":   date,
+		"bytes":  []byte("hello"),
+		"int32":  10,
+		"int64":  int64(10),
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+}
+
+func Test_indexName(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		want string
+	}{
+		{name: "1", keys: []string{"name"}, want: "name_1"},
+		{name: "2", keys: []string{"name", "-age"}, want: "name_1_age_-1"},
+		{name: "3", keys: []string{"$2dsphere:location"}, want: "location_2dsphere"},
+	}
+	for _, tt := range tests {
+		if got := indexName(tt.keys); got != tt.want {
+			t.Errorf("%q. indexName() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,12 +1,12 @@
 package mongo
 
 import (
+	"encoding/json"
 	"strings"
 
 	"github.com/okobsamoht/talisman/errs"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
-	"gopkg.in/mgo.v2"
 )
 
 // MongoSchemaCollection _SCHEMA 表操作对象
@@ -51,27 +51,9 @@ func (m *MongoSchemaCollection) findSchema(name string) (types.M, error) {
 	return mongoSchemaToParseSchema(results[0]), nil
 }
 
-// findAndDeleteSchema 查找并删除指定的表定义
+// findAndDeleteSchema 查找并删除指定的表定义，表定义不存在时返回空对象
 func (m *MongoSchemaCollection) findAndDeleteSchema(name string) (types.M, error) {
-
-	var result types.M
-	change := mgo.Change{
-		Remove:    true,
-		ReturnNew: false,
-	}
-	selector := mongoSchemaQueryFromNameQuery(name, nil)
-	info, err := m.collection.collection.Find(selector).Apply(change, &result)
-	if err != nil {
-		if err.Error() == "not found" {
-			return types.M{}, nil
-		}
-		return nil, err
-	}
-	if info.Removed == 0 {
-		return types.M{}, nil
-	}
-
-	return result, nil
+	return m.collection.findOneAndDelete(mongoSchemaQueryFromNameQuery(name, nil), nil)
 }
 
 // addSchema 添加一个表定义
@@ -134,6 +116,19 @@ func (m *MongoSchemaCollection) addFieldIfNotExists(className string, fieldName
 	return m.upsertSchema(className, query, update)
 }
 
+// updateFieldType 修改已有字段的定义
+func (m *MongoSchemaCollection) updateFieldType(className string, fieldName string, fieldType types.M) error {
+	query := types.M{
+		fieldName: types.M{"$exists": true},
+	}
+	update := types.M{
+		"$set": types.M{
+			fieldName: parseFieldTypeToMongoFieldType(fieldType),
+		},
+	}
+	return m.collection.updateOne(mongoSchemaQueryFromNameQuery(className, query), update)
+}
+
 // mongoSchemaQueryFromNameQuery 从表名及查询条件组装 mongo 查询对象
 func mongoSchemaQueryFromNameQuery(name string, query types.M) types.M {
 	object := types.M{
@@ -166,15 +161,39 @@ func mongoFieldToParseSchemaField(t string) types.M {
 			"targetClass": string(t[len("relation<") : len(t)-1]),
 		}
 	}
+	// computed<abc> ==> {"type":"Computed", "function":"abc"}
+	if strings.HasPrefix(t, "computed<") {
+		return types.M{
+			"type":     "Computed",
+			"function": string(t[len("computed<") : len(t)-1]),
+		}
+	}
+	// enum<["a","b"]> ==> {"type":"Enum", "values":["a","b"]}
+	if strings.HasPrefix(t, "enum<") {
+		values := types.S{}
+		json.Unmarshal([]byte(t[len("enum<"):len(t)-1]), &values)
+		return types.M{
+			"type":   "Enum",
+			"values": values,
+		}
+	}
 	switch t {
 	case "number":
 		return types.M{
 			"type": "Number",
 		}
+	case "autoincrement":
+		return types.M{
+			"type": "AutoIncrement",
+		}
 	case "string":
 		return types.M{
 			"type": "String",
 		}
+	case "localizedstring":
+		return types.M{
+			"type": "LocalizedString",
+		}
 	case "boolean":
 		return types.M{
 			"type": "Boolean",
@@ -199,6 +218,10 @@ func mongoFieldToParseSchemaField(t string) types.M {
 		return types.M{
 			"type": "GeoPoint",
 		}
+	case "polygon":
+		return types.M{
+			"type": "Polygon",
+		}
 	case "file":
 		return types.M{
 			"type": "File",
@@ -313,10 +336,19 @@ func parseFieldTypeToMongoFieldType(t types.M) string {
 		return "*" + targetClass
 	case "Relation":
 		return "relation<" + targetClass + ">"
+	case "Computed":
+		return "computed<" + utils.S(t["function"]) + ">"
+	case "Enum":
+		b, _ := json.Marshal(utils.A(t["values"]))
+		return "enum<" + string(b) + ">"
 	case "Number":
 		return "number"
+	case "AutoIncrement":
+		return "autoincrement"
 	case "String":
 		return "string"
+	case "LocalizedString":
+		return "localizedstring"
 	case "Boolean":
 		return "boolean"
 	case "Date":
@@ -327,6 +359,8 @@ func parseFieldTypeToMongoFieldType(t types.M) string {
 		return "array"
 	case "GeoPoint":
 		return "geopoint"
+	case "Polygon":
+		return "polygon"
 	case "File":
 		return "file"
 	default:
@@ -1,6 +1,7 @@
 package mongo
 
 import (
+	"context"
 	"reflect"
 	"strings"
 	"testing"
@@ -8,12 +9,12 @@ import (
 	"github.com/okobsamoht/talisman/errs"
 	"github.com/okobsamoht/talisman/types"
 
-	"gopkg.in/mgo.v2"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func Test_getAllSchemas(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
+	defer db.Client().Disconnect(context.Background())
 	msc := getSchemaCollection(db)
 	var name string
 	var fields types.M
@@ -85,7 +86,7 @@ func Test_getAllSchemas(t *testing.T) {
 
 func Test_findSchema(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
+	defer db.Client().Disconnect(context.Background())
 	msc := getSchemaCollection(db)
 	var name string
 	var fields types.M
@@ -136,7 +137,7 @@ func Test_findSchema(t *testing.T) {
 
 func Test_findAndDeleteSchema(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
+	defer db.Client().Disconnect(context.Background())
 	msc := getSchemaCollection(db)
 	var name string
 	var fields types.M
@@ -185,7 +186,7 @@ func Test_findAndDeleteSchema(t *testing.T) {
 
 func Test_addSchema(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
+	defer db.Client().Disconnect(context.Background())
 	msc := getSchemaCollection(db)
 	var name string
 	var fields types.M
@@ -426,7 +427,7 @@ func Test_addSchema(t *testing.T) {
 
 func Test_updateSchema(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
+	defer db.Client().Disconnect(context.Background())
 	msc := getSchemaCollection(db)
 	var name string
 	var fields types.M
@@ -569,7 +570,7 @@ func Test_updateSchema(t *testing.T) {
 
 func Test_upsertSchema(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
+	defer db.Client().Disconnect(context.Background())
 	msc := getSchemaCollection(db)
 	var name string
 	var fields types.M
@@ -655,7 +656,7 @@ func Test_upsertSchema(t *testing.T) {
 
 func Test_addFieldIfNotExists(t *testing.T) {
 	db := openDB()
-	defer db.Session.Close()
+	defer db.Client().Disconnect(context.Background())
 	msc := getSchemaCollection(db)
 	var className string
 	var fieldName string
@@ -839,6 +840,16 @@ func Test_mongoFieldToParseSchemaField(t *testing.T) {
 		t.Error("expect:", expect, "result:", result)
 	}
 	/*****************************************************/
+	ty = "computed<fullName>"
+	result = mongoFieldToParseSchemaField(ty)
+	expect = types.M{
+		"type":     "Computed",
+		"function": "fullName",
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************/
 	ty = "number"
 	result = mongoFieldToParseSchemaField(ty)
 	expect = types.M{
@@ -935,6 +946,20 @@ func Test_mongoFieldToParseSchemaField(t *testing.T) {
 	if reflect.DeepEqual(expect, result) == false {
 		t.Error("expect:", expect, "result:", result)
 	}
+	/*****************************************************/
+	ty = "autoincrement"
+	result = mongoFieldToParseSchemaField(ty)
+	expect = types.M{"type": "AutoIncrement"}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************/
+	ty = "localizedstring"
+	result = mongoFieldToParseSchemaField(ty)
+	expect = types.M{"type": "LocalizedString"}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
 }
 
 func Test_mongoSchemaFieldsToParseSchemaFields(t *testing.T) {
@@ -1145,6 +1170,13 @@ func Test_parseFieldTypeToMongoFieldType(t *testing.T) {
 		t.Error("expect:", expect, "result:", result)
 	}
 	/*****************************************************/
+	fieldType = types.M{"type": "Computed", "function": "fullName"}
+	result = parseFieldTypeToMongoFieldType(fieldType)
+	expect = "computed<fullName>"
+	if result != expect {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************/
 	fieldType = types.M{
 		"type": "Pointer",
 	}
@@ -1263,10 +1295,24 @@ func Test_parseFieldTypeToMongoFieldType(t *testing.T) {
 	if result != expect {
 		t.Error("expect:", expect, "result:", result)
 	}
+	/*****************************************************/
+	fieldType = types.M{"type": "AutoIncrement"}
+	result = parseFieldTypeToMongoFieldType(fieldType)
+	expect = "autoincrement"
+	if result != expect {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************/
+	fieldType = types.M{"type": "LocalizedString"}
+	result = parseFieldTypeToMongoFieldType(fieldType)
+	expect = "localizedstring"
+	if result != expect {
+		t.Error("expect:", expect, "result:", result)
+	}
 }
 
-func getSchemaCollection(db *mgo.Database) *MongoSchemaCollection {
-	mc := newMongoCollection(db.C("SCHEMA"))
+func getSchemaCollection(db *mongo.Database) *MongoSchemaCollection {
+	mc := newTestCollection(db, "SCHEMA")
 	msc := newMongoSchemaCollection(mc)
 	return msc
 }
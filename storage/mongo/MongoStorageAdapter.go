@@ -1,46 +1,78 @@
 package mongo
 
 import (
+	"context"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/storage"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
-
-	"gopkg.in/mgo.v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const mongoSchemaCollectionName = "_SCHEMA"
 
+// mongoSequenceCollectionName 保存自增序列当前值的表
+const mongoSequenceCollectionName = "_Sequence"
+
 // MongoAdapter mongo 数据库适配器
 type MongoAdapter struct {
 	collectionPrefix string
 	collectionList   []string
-	db               *mgo.Database
+	db               *mongo.Database
+	ctx              context.Context    // 所有数据库操作的 context ，关闭适配器时取消
+	cancel           context.CancelFunc // 取消 ctx
+	timeout          time.Duration      // 单次操作的超时时间，为 0 时不超时
 	transform        *Transform
 	maxTimeMS        int // 单次查询最大时间，以毫秒为单位
+	classCache       *storage.ClassCache
 }
 
 // NewMongoAdapter ...
-func NewMongoAdapter(collectionPrefix string, db *mgo.Database) *MongoAdapter {
+func NewMongoAdapter(collectionPrefix string, db *mongo.Database) *MongoAdapter {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &MongoAdapter{
 		collectionPrefix: collectionPrefix,
 		collectionList:   []string{},
 		db:               db,
+		ctx:              ctx,
+		cancel:           cancel,
 		transform:        NewTransform(),
+		classCache:       storage.NewClassCache(0),
 	}
 }
 
-// collection 获取指定表的操作对象
-func (m *MongoAdapter) collection(name string) *mgo.Collection {
-	return m.db.C(name)
+// SetOperationTimeout 设置单次数据库操作的超时时间，为 0 时不超时
+func (m *MongoAdapter) SetOperationTimeout(timeout time.Duration) {
+	m.timeout = timeout
+}
+
+// SetClassCacheTTL 设置 GetClass 缓存的有效期，为 0 时不缓存
+func (m *MongoAdapter) SetClassCacheTTL(ttl time.Duration) {
+	m.classCache = storage.NewClassCache(ttl)
+}
+
+// InvalidateClassCache 使 GetClass 缓存全部失效
+func (m *MongoAdapter) InvalidateClassCache() {
+	m.classCache.Bump()
+}
+
+// collection 获取指定表的操作对象，使用与 Transform 匹配的解码规则
+func (m *MongoAdapter) collection(name string) *mongo.Collection {
+	return m.db.Collection(name, options.Collection().SetRegistry(mongoRegistry))
 }
 
 // adaptiveCollection 组装 mongo 表操作对象
 func (m *MongoAdapter) adaptiveCollection(name string) *MongoCollection {
 	rawCollection := m.collection(m.collectionPrefix + name)
-	return newMongoCollection(rawCollection)
+	collection := newMongoCollection(m.ctx, rawCollection)
+	collection.timeout = m.timeout
+	return collection
 }
 
 // schemaCollection 组装 _SCHEMA 表操作对象
@@ -73,6 +105,7 @@ func (m *MongoAdapter) ClassExists(name string) bool {
 
 // SetClassLevelPermissions 设置类级别权限
 func (m *MongoAdapter) SetClassLevelPermissions(className string, CLPs types.M) error {
+	defer m.classCache.Bump()
 	schemaCollection := m.schemaCollection()
 	update := types.M{
 		"$set": types.M{
@@ -81,12 +114,17 @@ func (m *MongoAdapter) SetClassLevelPermissions(className string, CLPs types.M)
 			},
 		},
 	}
-	return schemaCollection.updateSchema(className, update)
+	err := schemaCollection.updateSchema(className, update)
+	if err == errNotFound {
+		return errs.Wrap(errs.InvalidClassName, "Class "+className+" does not exist.", err)
+	}
+	return err
 }
 
 // CreateClass 创建类
 // 原始位置 MongoSchemaCollection.go/addSchema
 func (m *MongoAdapter) CreateClass(className string, schema types.M) (types.M, error) {
+	defer m.classCache.Bump()
 	schema = convertParseSchemaToMongoSchema(schema)
 	if schema == nil {
 		schema = types.M{}
@@ -109,19 +147,27 @@ func (m *MongoAdapter) CreateClass(className string, schema types.M) (types.M, e
 
 // AddFieldIfNotExists 添加字段定义
 func (m *MongoAdapter) AddFieldIfNotExists(className, fieldName string, fieldType types.M) error {
+	defer m.classCache.Bump()
 	schemaCollection := m.schemaCollection()
 	return schemaCollection.addFieldIfNotExists(className, fieldName, fieldType)
 }
 
+// UpdateFieldType 修改已有字段的定义，用于增加 Enum 字段的取值
+func (m *MongoAdapter) UpdateFieldType(className, fieldName string, fieldType types.M) error {
+	defer m.classCache.Bump()
+	schemaCollection := m.schemaCollection()
+	return schemaCollection.updateFieldType(className, fieldName, fieldType)
+}
+
 // DeleteClass 删除指定表
 func (m *MongoAdapter) DeleteClass(className string) (types.M, error) {
+	defer m.classCache.Bump()
 	coll := m.adaptiveCollection(className)
+	// 表不存在时 drop 不返回错误
 	err := coll.drop()
 	m.collectionList = m.getCollectionNames()
 	if err != nil {
-		if err.Error() != "ns not found" {
-			return nil, err
-		}
+		return nil, err
 	}
 	schemaCollection := m.schemaCollection()
 	return schemaCollection.findAndDeleteSchema(className)
@@ -129,6 +175,7 @@ func (m *MongoAdapter) DeleteClass(className string) (types.M, error) {
 
 // DeleteAllClasses 删除所有表，仅用于测试
 func (m *MongoAdapter) DeleteAllClasses() error {
+	defer m.classCache.Bump()
 	collections := storageAdapterAllCollections(m)
 	for _, collection := range collections {
 		err := collection.drop()
@@ -142,6 +189,7 @@ func (m *MongoAdapter) DeleteAllClasses() error {
 
 // DeleteFields 删除字段
 func (m *MongoAdapter) DeleteFields(className string, schema types.M, fieldNames []string) error {
+	defer m.classCache.Bump()
 	var fields types.M
 	if schema != nil {
 		fields = utils.M(schema["fields"])
@@ -201,9 +249,9 @@ func (m *MongoAdapter) CreateObject(className string, schema, object types.M) er
 	return coll.insertOne(mongoObject)
 }
 
-// GetClass ...
+// GetClass 获取类的 schema ，优先从适配器内部的缓存中读取
 func (m *MongoAdapter) GetClass(className string) (types.M, error) {
-	return m.schemaCollection().findSchema(className)
+	return m.classCache.Load(className, m.schemaCollection().findSchema)
 }
 
 // GetAllClasses ...
@@ -213,7 +261,9 @@ func (m *MongoAdapter) GetAllClasses() ([]types.M, error) {
 
 // getCollectionNames 获取数据库中当前已经存在的表名
 func (m *MongoAdapter) getCollectionNames() []string {
-	names, err := m.db.CollectionNames()
+	ctx, cancel := operationContext(m.ctx, m.timeout)
+	defer cancel()
+	names, err := m.db.ListCollectionNames(ctx, bson.D{})
 	if err == nil && names != nil {
 		n := []string{}
 		for _, v := range names {
@@ -247,6 +297,32 @@ func (m *MongoAdapter) DeleteObjectsByQuery(className string, schema, query type
 	return nil
 }
 
+// FindOneAndDelete 删除符合条件的一个对象并返回被删除的对象，没有符合条件的对象时返回空对象
+func (m *MongoAdapter) FindOneAndDelete(className string, schema, query, options types.M) (types.M, error) {
+	schema = convertParseSchemaToMongoSchema(schema)
+	mongoWhere, err := m.transform.transformWhere(className, query, schema)
+	if err != nil {
+		return nil, err
+	}
+	var mongoSort []string
+	if keys, ok := options["sort"].([]string); ok {
+		mongoSort = m.transformSort(className, keys, schema)
+	}
+
+	object, err := m.adaptiveCollection(className).findOneAndDelete(mongoWhere, mongoSort)
+	if err != nil {
+		return nil, err
+	}
+	if len(object) == 0 {
+		return types.M{}, nil
+	}
+	result, err := m.transform.mongoObjectToParseObject(className, object, schema)
+	if err != nil {
+		return nil, err
+	}
+	return utils.M(result), nil
+}
+
 // UpdateObjectsByQuery ...
 func (m *MongoAdapter) UpdateObjectsByQuery(className string, schema, query, update types.M) error {
 	schema = convertParseSchemaToMongoSchema(schema)
@@ -297,11 +373,73 @@ func (m *MongoAdapter) UpsertOneObject(className string, schema, query, update t
 	return coll.upsertOne(mongoWhere, mongoUpdate)
 }
 
+// FindOneAndUpsert 更新符合条件的一个对象，不存在时插入 insert
+// insert 中与 update 冲突的字段通过 update 写入，其他字段通过 $setOnInsert 写入
+// 并发插入违反唯一索引时重新执行一次，此时对象已经存在，执行的是更新操作
+func (m *MongoAdapter) FindOneAndUpsert(className string, schema, query, update, insert types.M) (types.M, bool, error) {
+	schema = convertParseSchemaToMongoSchema(schema)
+	mongoUpdate, err := m.transform.transformUpdate(className, update, schema)
+	if err != nil {
+		return nil, false, err
+	}
+	mongoWhere, err := m.transform.transformWhere(className, query, schema)
+	if err != nil {
+		return nil, false, err
+	}
+	mongoInsert, err := m.transform.parseObjectToMongoObjectForCreate(className, insert, schema)
+	if err != nil {
+		return nil, false, err
+	}
+	setOnInsert := types.M{}
+	for key, value := range mongoInsert {
+		// 查询条件中包含 _id 时，由数据库使用查询条件中的 _id
+		if _, ok := mongoWhere["_id"]; ok && key == "_id" {
+			continue
+		}
+		if updatesKey(mongoUpdate, key) == false {
+			setOnInsert[key] = value
+		}
+	}
+	if len(setOnInsert) > 0 {
+		mongoUpdate["$setOnInsert"] = setOnInsert
+	}
+
+	coll := m.adaptiveCollection(className)
+	object, created, err := coll.findOneAndUpsert(mongoWhere, mongoUpdate)
+	if errs.GetErrorCode(err) == errs.DuplicateValue {
+		object, created, err = coll.findOneAndUpsert(mongoWhere, mongoUpdate)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	result, err := m.transform.mongoObjectToParseObject(className, object, schema)
+	if err != nil {
+		return nil, false, err
+	}
+	return utils.M(result), created, nil
+}
+
+// updatesKey 更新操作中是否包含 key 字段，或者 key 的父字段、子字段
+func updatesKey(mongoUpdate types.M, key string) bool {
+	for _, v := range mongoUpdate {
+		fields := utils.M(v)
+		for k := range fields {
+			if k == key || strings.HasPrefix(k, key+".") || strings.HasPrefix(key, k+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Find ...
 func (m *MongoAdapter) Find(className string, schema, query, options types.M) ([]types.M, error) {
 	if options == nil {
 		options = types.M{}
 	}
+	if v, ok := options["caseInsensitive"].(bool); ok && v {
+		query = caseInsensitiveQuery(query, schema)
+	}
 	schema = convertParseSchemaToMongoSchema(schema)
 	mongoWhere, err := m.transform.transformWhere(className, query, schema)
 	if err != nil {
@@ -309,20 +447,7 @@ func (m *MongoAdapter) Find(className string, schema, query, options types.M) ([
 	}
 	if _, ok := options["sort"]; ok {
 		if keys, ok := options["sort"].([]string); ok {
-			mongoSort := []string{}
-			for _, key := range keys {
-				var mongoKey string
-				var prefix string
-
-				if strings.HasPrefix(key, "-") {
-					prefix = "-"
-					key = key[1:]
-				}
-
-				mongoKey = prefix + m.transform.transformKey(className, key, schema)
-				mongoSort = append(mongoSort, mongoKey)
-			}
-			options["sort"] = mongoSort
+			options["sort"] = m.transformSort(className, keys, schema)
 		} else {
 			delete(options, "sort")
 		}
@@ -334,6 +459,10 @@ func (m *MongoAdapter) Find(className string, schema, query, options types.M) ([
 				mongoKey := m.transform.transformKey(className, key, schema)
 				mongoKeys[mongoKey] = 1
 			}
+			// _Join 表中不需要 _id ，不返回时查询可以只读取索引
+			if strings.HasPrefix(className, "_Join:") && mongoKeys["_id"] == nil {
+				mongoKeys["_id"] = 0
+			}
 			options["keys"] = mongoKeys
 		} else {
 			delete(options, "keys")
@@ -359,6 +488,58 @@ func (m *MongoAdapter) Find(className string, schema, query, options types.M) ([
 	return objects, nil
 }
 
+// transformSort 转换排序字段为数据库中的字段名，以 - 开头的字段为降序
+func (m *MongoAdapter) transformSort(className string, keys []string, schema types.M) []string {
+	mongoSort := []string{}
+	for _, key := range keys {
+		var prefix string
+		if strings.HasPrefix(key, "-") {
+			prefix = "-"
+			key = key[1:]
+		}
+		mongoSort = append(mongoSort, prefix+m.transform.transformKey(className, key, schema))
+	}
+	return mongoSort
+}
+
+// caseInsensitiveQuery 把 String 字段上的相等条件转换为不区分大小写的正则查询
+// 正则以 ^ 与 $ 限定整个字段，字段值中的特殊字符会被转义
+func caseInsensitiveQuery(query, schema types.M) types.M {
+	if query == nil {
+		return query
+	}
+	fields := utils.M(schema["fields"])
+	result := types.M{}
+	for key, value := range query {
+		if key == "$or" || key == "$and" {
+			if array := utils.A(value); array != nil {
+				subQueries := types.S{}
+				for _, v := range array {
+					subQueries = append(subQueries, caseInsensitiveQuery(utils.M(v), schema))
+				}
+				result[key] = subQueries
+				continue
+			}
+		}
+		if tp := utils.M(fields[key]); tp == nil || utils.S(tp["type"]) != "String" {
+			result[key] = value
+			continue
+		}
+		if s, ok := value.(string); ok {
+			result[key] = types.M{"$regex": "^" + regexp.QuoteMeta(s) + "$", "$options": "i"}
+			continue
+		}
+		if constraint := utils.M(value); len(constraint) == 1 {
+			if s, ok := constraint["$eq"].(string); ok {
+				result[key] = types.M{"$regex": "^" + regexp.QuoteMeta(s) + "$", "$options": "i"}
+				continue
+			}
+		}
+		result[key] = value
+	}
+	return result
+}
+
 // rawFind 仅用于测试
 func (m *MongoAdapter) rawFind(className string, query types.M) ([]types.M, error) {
 	coll := m.adaptiveCollection(className)
@@ -385,6 +566,11 @@ func (m *MongoAdapter) Count(className string, schema, query types.M) (int, erro
 	return c, nil
 }
 
+// EstimatedCount 估算表中对象总数
+func (m *MongoAdapter) EstimatedCount(className string) (int, error) {
+	return m.adaptiveCollection(className).estimatedCount()
+}
+
 // EnsureUniqueness 创建索引
 func (m *MongoAdapter) EnsureUniqueness(className string, schema types.M, fieldNames []string) error {
 	schema = convertParseSchemaToMongoSchema(schema)
@@ -401,14 +587,113 @@ func (m *MongoAdapter) EnsureUniqueness(className string, schema types.M, fieldN
 	return err
 }
 
+// EnsureCaseInsensitiveIndex 为 String 字段创建索引
+// 不区分大小写的查询使用正则实现，没有使用 collation ，索引可以避免扫描整个集合
+func (m *MongoAdapter) EnsureCaseInsensitiveIndex(className, fieldName string) error {
+	return m.adaptiveCollection(className).ensureIndexInBackground(fieldName)
+}
+
+// EnsureIndex 后台创建由 fieldNames 组成的索引，字段名转换方式与排序字段相同
+func (m *MongoAdapter) EnsureIndex(className string, schema types.M, fieldNames []string) error {
+	schema = convertParseSchemaToMongoSchema(schema)
+	return m.adaptiveCollection(className).ensureCompoundIndexInBackground(m.transformSort(className, fieldNames, schema))
+}
+
+// GetIndexes 返回集合的所有索引，字段名转换为 Parse 格式，如 _p_author 转换为 author
+func (m *MongoAdapter) GetIndexes(className string, schema types.M) (types.M, error) {
+	indexes, err := m.adaptiveCollection(className).indexes()
+	if err != nil {
+		return nil, err
+	}
+	result := types.M{}
+	for _, index := range indexes {
+		result[index.Name] = mongoIndexToParseIndex(index.Key)
+	}
+	return result, nil
+}
+
+// CreateIndex 后台创建指定名称的索引
+func (m *MongoAdapter) CreateIndex(className string, schema types.M, name string, fieldNames []string) error {
+	schema = convertParseSchemaToMongoSchema(schema)
+	return m.adaptiveCollection(className).ensureNamedIndexInBackground(name, m.transformSort(className, fieldNames, schema))
+}
+
+// DropIndex 删除指定名称的索引
+func (m *MongoAdapter) DropIndex(className, name string) error {
+	return m.adaptiveCollection(className).dropIndexName(name)
+}
+
+// mongoIndexToParseIndex 转换索引字段，如 ["-_created_at"] 转换为 {"createdAt": -1}
+func mongoIndexToParseIndex(keys []string) types.M {
+	index := types.M{}
+	for _, key := range keys {
+		var value interface{} = 1
+		if strings.HasPrefix(key, "$2dsphere:") {
+			key = strings.TrimPrefix(key, "$2dsphere:")
+			value = "2dsphere"
+		} else if strings.HasPrefix(key, "-") {
+			key = key[1:]
+			value = -1
+		}
+		switch {
+		case key == "_id":
+			key = "objectId"
+		case key == "_created_at":
+			key = "createdAt"
+		case key == "_updated_at":
+			key = "updatedAt"
+		case strings.HasPrefix(key, "_p_"):
+			key = key[3:]
+		}
+		index[key] = value
+	}
+	return index
+}
+
+// EnsureJoinIndexes 后台创建 _Join 表的 owningId relatedId 与 relatedId owningId 联合索引
+func (m *MongoAdapter) EnsureJoinIndexes(className, fieldName string) error {
+	coll := m.adaptiveCollection("_Join:" + fieldName + ":" + className)
+	err := coll.ensureCompoundIndexInBackground([]string{"owningId", "relatedId"})
+	if err != nil {
+		return err
+	}
+	return coll.ensureCompoundIndexInBackground([]string{"relatedId", "owningId"})
+}
+
+// EnsureGeoIndex 为 GeoPoint 与 Polygon 字段创建 2dsphere 索引
+func (m *MongoAdapter) EnsureGeoIndex(className, fieldName string) error {
+	return m.adaptiveCollection(className).ensureGeoIndexInBackground(fieldName)
+}
+
+// DropGeoIndex 删除 GeoPoint 与 Polygon 字段上的 2dsphere 索引
+func (m *MongoAdapter) DropGeoIndex(className, fieldName string) error {
+	return m.adaptiveCollection(className).dropGeoIndex(fieldName)
+}
+
+// EnsureTTLIndex 为过期时间字段创建 TTL 索引
+func (m *MongoAdapter) EnsureTTLIndex(className, fieldName string) error {
+	return m.adaptiveCollection(className).ensureTTLIndexInBackground(fieldName)
+}
+
+// DropTTLIndex 删除过期时间字段上的 TTL 索引
+func (m *MongoAdapter) DropTTLIndex(className, fieldName string) error {
+	return m.adaptiveCollection(className).dropIndex(fieldName)
+}
+
+// NextSequenceValue 返回类中自增字段的下一个值，序列保存在 _Sequence 表中， _id 为 className:fieldName
+func (m *MongoAdapter) NextSequenceValue(className, fieldName string) (int, error) {
+	return m.adaptiveCollection(mongoSequenceCollectionName).nextSequenceValue(className + ":" + fieldName)
+}
+
 // PerformInitialization 性能优化初始化
 func (m *MongoAdapter) PerformInitialization(options types.M) error {
 	return nil
 }
 
-// HandleShutdown 关闭数据库
+// HandleShutdown 取消正在执行的操作并关闭数据库连接
 func (m *MongoAdapter) HandleShutdown() {
-	m.db.Session.Close()
+	m.cancel()
+	m.db.Client().Disconnect(context.Background())
 }
 
 func storageAdapterAllCollections(m *MongoAdapter) []*MongoCollection {
@@ -5,8 +5,6 @@ import (
 	"testing"
 	"time"
 
-	"gopkg.in/mgo.v2"
-
 	"github.com/okobsamoht/talisman/errs"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
@@ -1076,6 +1074,116 @@ func Test_UpsertOneObject(t *testing.T) {
 	adapter.DeleteAllClasses()
 }
 
+func Test_FindOneAndUpsert(t *testing.T) {
+	adapter := getAdapter()
+	var className string
+	var query types.M
+	var update types.M
+	var insert types.M
+	var result types.M
+	var created bool
+	var err error
+	var expect types.M
+	tmpTimeStr := utils.TimetoString(time.Now().UTC())
+	/*****************************************************/
+	className = "user"
+	query = types.M{"name": "joe"}
+	update = types.M{"score": types.M{"__op": "Increment", "amount": 1}}
+	insert = types.M{
+		"objectId":  "01",
+		"name":      "joe",
+		"score":     1,
+		"createdAt": tmpTimeStr,
+	}
+	result, created, err = adapter.FindOneAndUpsert(className, nil, query, update, insert)
+	expect = types.M{
+		"objectId":  "01",
+		"name":      "joe",
+		"score":     1,
+		"createdAt": tmpTimeStr,
+	}
+	if err != nil || created == false || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, true, "result:", result, created, err)
+	}
+	/*****************************************************/
+	insert = types.M{
+		"objectId":  "02",
+		"name":      "joe",
+		"score":     1,
+		"createdAt": tmpTimeStr,
+	}
+	result, created, err = adapter.FindOneAndUpsert(className, nil, query, update, insert)
+	expect = types.M{
+		"objectId":  "01",
+		"name":      "joe",
+		"score":     2,
+		"createdAt": tmpTimeStr,
+	}
+	if err != nil || created || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, false, "result:", result, created, err)
+	}
+	adapter.DeleteAllClasses()
+}
+
+func Test_FindOneAndDelete(t *testing.T) {
+	adapter := getAdapter()
+	var className string
+	var query types.M
+	var options types.M
+	var result types.M
+	var results []types.M
+	var err error
+	var expect types.M
+	/*****************************************************/
+	className = "job"
+	adapter.CreateObject(className, nil, types.M{"objectId": "01", "state": "ready", "priority": 1})
+	adapter.CreateObject(className, nil, types.M{"objectId": "02", "state": "ready", "priority": 2})
+	adapter.CreateObject(className, nil, types.M{"objectId": "03", "state": "done", "priority": 3})
+	query = types.M{"state": "ready"}
+	options = types.M{"sort": []string{"-priority"}}
+	result, err = adapter.FindOneAndDelete(className, nil, query, options)
+	expect = types.M{"objectId": "02", "state": "ready", "priority": 2}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	results, _ = adapter.Find(className, nil, types.M{}, nil)
+	if len(results) != 2 {
+		t.Error("expect:", 2, "result:", len(results))
+	}
+	/*****************************************************/
+	query = types.M{"state": "failed"}
+	result, err = adapter.FindOneAndDelete(className, nil, query, nil)
+	expect = types.M{}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result, err)
+	}
+	adapter.DeleteAllClasses()
+}
+
+func Test_updatesKey(t *testing.T) {
+	update := types.M{
+		"$set": types.M{"title": "hello", "stats.views": 1},
+		"$inc": types.M{"score": 1},
+	}
+	tests := []struct {
+		name string
+		key  string
+		want bool
+	}{
+		{name: "1", key: "title", want: true},
+		{name: "2", key: "score", want: true},
+		{name: "3", key: "stats", want: true},
+		{name: "4", key: "title.en", want: true},
+		{name: "5", key: "_id", want: false},
+		{name: "6", key: "stat", want: false},
+	}
+	for _, tt := range tests {
+		if got := updatesKey(update, tt.key); got != tt.want {
+			t.Errorf("%q. updatesKey() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
 func Test_Find(t *testing.T) {
 	adapter := getAdapter()
 	var className string
@@ -1384,8 +1492,8 @@ func Test_EnsureUniqueness(t *testing.T) {
 	var schema types.M
 	var fieldNames []string
 	var err error
-	var indexes []mgo.Index
-	var expect mgo.Index
+	var indexes []mongoIndex
+	var expect mongoIndex
 	var ok bool
 	/*****************************************************/
 	className = "user"
@@ -1395,8 +1503,8 @@ func Test_EnsureUniqueness(t *testing.T) {
 	if err != nil {
 		t.Error("expect:", nil, "result:", err)
 	}
-	indexes, err = adapter.adaptiveCollection(className).collection.Indexes()
-	expect = mgo.Index{
+	indexes, err = adapter.adaptiveCollection(className).indexes()
+	expect = mongoIndex{
 		Key:        []string{"username"},
 		Unique:     true,
 		Background: true,
@@ -1419,6 +1527,85 @@ func Test_EnsureUniqueness(t *testing.T) {
 	adapter.DeleteAllClasses()
 }
 
+func Test_GeoIndex(t *testing.T) {
+	adapter := getAdapter()
+	var className string
+	var fieldName string
+	var err error
+	var indexes []mongoIndex
+	var expect []string
+	var ok bool
+	/*****************************************************/
+	className = "post"
+	fieldName = "location"
+	err = adapter.EnsureGeoIndex(className, fieldName)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	indexes, err = adapter.adaptiveCollection(className).indexes()
+	expect = []string{"$2dsphere:location"}
+	ok = false
+	for _, i := range indexes {
+		if reflect.DeepEqual(i.Key, expect) {
+			ok = true
+			break
+		}
+	}
+	if ok == false {
+		t.Error("expect:", expect, "get result:", indexes)
+	}
+	/*****************************************************/
+	err = adapter.DropGeoIndex(className, fieldName)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	indexes, err = adapter.adaptiveCollection(className).indexes()
+	for _, i := range indexes {
+		if reflect.DeepEqual(i.Key, expect) {
+			t.Error("expect:", "index dropped", "get result:", indexes)
+		}
+	}
+	/*****************************************************/
+	err = adapter.DropGeoIndex(className, fieldName)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+
+	adapter.DeleteAllClasses()
+}
+
+func Test_EnsureJoinIndexes(t *testing.T) {
+	adapter := getAdapter()
+	err := adapter.EnsureJoinIndexes("post", "likes")
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	indexes, _ := adapter.adaptiveCollection("_Join:likes:post").indexes()
+	for _, expect := range [][]string{{"owningId", "relatedId"}, {"relatedId", "owningId"}} {
+		ok := false
+		for _, i := range indexes {
+			if reflect.DeepEqual(i.Key, expect) {
+				ok = true
+				break
+			}
+		}
+		if ok == false {
+			t.Error("expect:", expect, "get result:", indexes)
+		}
+	}
+	/*****************************************************/
+	// _Join 表只返回指定的字段，不返回 _id
+	schema := types.M{"fields": types.M{"relatedId": types.M{"type": "String"}, "owningId": types.M{"type": "String"}}}
+	adapter.CreateObject("_Join:likes:post", schema, types.M{"relatedId": "u1", "owningId": "p1"})
+	results, err := adapter.Find("_Join:likes:post", schema, types.M{"owningId": "p1"}, types.M{"keys": []string{"relatedId"}})
+	expectResults := []types.M{types.M{"relatedId": "u1"}}
+	if err != nil || reflect.DeepEqual(results, expectResults) == false {
+		t.Error("expect:", expectResults, "result:", results, err)
+	}
+
+	adapter.DeleteAllClasses()
+}
+
 func Test_storageAdapterAllCollections(t *testing.T) {
 	adapter := getAdapter()
 	var result []*MongoCollection
@@ -1437,7 +1624,7 @@ func Test_storageAdapterAllCollections(t *testing.T) {
 		t.Error("expect:", 2, "result:", len(result))
 	} else {
 		expect := []string{"talismanuser", "talismanuser1"}
-		names := []string{result[0].collection.Name, result[1].collection.Name}
+		names := []string{result[0].collection.Name(), result[1].collection.Name()}
 		if reflect.DeepEqual(expect, names) == false {
 			t.Error("expect:", expect, "result:", names)
 		}
@@ -1453,7 +1640,7 @@ func Test_storageAdapterAllCollections(t *testing.T) {
 		t.Error("expect:", 2, "result:", len(result))
 	} else {
 		expect := []string{"talismanuser", "talismanuser1"}
-		names := []string{result[0].collection.Name, result[1].collection.Name}
+		names := []string{result[0].collection.Name(), result[1].collection.Name()}
 		if reflect.DeepEqual(expect, names) == false {
 			t.Error("expect:", expect, "result:", names)
 		}
@@ -1524,6 +1711,60 @@ func Test_convertParseSchemaToMongoSchema(t *testing.T) {
 	}
 }
 
+func Test_caseInsensitiveQuery(t *testing.T) {
+	var query types.M
+	var schema types.M
+	var result types.M
+	var expect types.M
+	schema = types.M{
+		"fields": types.M{
+			"username": types.M{"type": "String"},
+			"age":      types.M{"type": "Number"},
+		},
+	}
+	/*****************************************************/
+	query = nil
+	result = caseInsensitiveQuery(query, schema)
+	expect = nil
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************/
+	query = types.M{"username": "Joe.Smith", "age": 10}
+	result = caseInsensitiveQuery(query, schema)
+	expect = types.M{
+		"username": types.M{"$regex": `^Joe\.Smith$`, "$options": "i"},
+		"age":      10,
+	}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************/
+	query = types.M{"username": types.M{"$eq": "Joe"}}
+	result = caseInsensitiveQuery(query, schema)
+	expect = types.M{"username": types.M{"$regex": "^Joe$", "$options": "i"}}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************/
+	query = types.M{"username": types.M{"$in": types.S{"Joe"}}}
+	result = caseInsensitiveQuery(query, schema)
+	expect = types.M{"username": types.M{"$in": types.S{"Joe"}}}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************/
+	query = types.M{"$or": types.S{types.M{"username": "Joe"}, types.M{"age": 10}}}
+	result = caseInsensitiveQuery(query, schema)
+	expect = types.M{"$or": types.S{
+		types.M{"username": types.M{"$regex": "^Joe$", "$options": "i"}},
+		types.M{"age": 10},
+	}}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
+
 func Test_mongoSchemaFromFieldsAndClassNameAndCLP(t *testing.T) {
 	var fields types.M
 	var className string
@@ -1624,3 +1865,30 @@ func Test_mongoSchemaFromFieldsAndClassNameAndCLP(t *testing.T) {
 func getAdapter() *MongoAdapter {
 	return NewMongoAdapter("talisman", openDB())
 }
+
+func Test_mongoIndexToParseIndex(t *testing.T) {
+	var keys []string
+	var result types.M
+	var expect types.M
+	/*****************************************************/
+	keys = []string{"_id"}
+	result = mongoIndexToParseIndex(keys)
+	expect = types.M{"objectId": 1}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************/
+	keys = []string{"_p_author", "-_created_at", "title"}
+	result = mongoIndexToParseIndex(keys)
+	expect = types.M{"author": 1, "createdAt": -1, "title": 1}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	/*****************************************************/
+	keys = []string{"$2dsphere:location"}
+	result = mongoIndexToParseIndex(keys)
+	expect = types.M{"location": "2dsphere"}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/storage"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
 )
@@ -182,19 +183,13 @@ func (t *Transform) transformKeyValueForUpdate(className, restKey string, restVa
 	return key, restValue, nil
 }
 
-// valueAsDate 校验并转换时间类型
+// valueAsDate 校验并转换时间类型，支持时间字符串与 Date 对象
 func valueAsDate(value interface{}) (time.Time, bool) {
-	if s, ok := value.(string); ok {
-		t, err := utils.StringtoTime(s)
-		if err != nil {
-			return time.Time{}, false
-		}
-		return t, true
-	}
-	if t, ok := value.(time.Time); ok {
-		return t, true
+	t, err := utils.DateToTime(value)
+	if err != nil {
+		return time.Time{}, false
 	}
-	return time.Time{}, false
+	return t, true
 }
 
 // transformQueryKeyValue 转换查询请求中的键值对
@@ -449,6 +444,54 @@ func (t *Transform) transformConstraint(constraint interface{}, inArray bool) (i
 			}
 			answer[key] = answerArr
 
+		// 转换 数组长度 操作符，参数必须为非负整数
+		case "$size":
+			switch n := object[key].(type) {
+			case int:
+				if n < 0 {
+					return nil, errs.E(errs.InvalidJSON, "bad $size value")
+				}
+				answer[key] = n
+			case float64:
+				if n < 0 || n != float64(int(n)) {
+					return nil, errs.E(errs.InvalidJSON, "bad $size value")
+				}
+				answer[key] = int(n)
+			default:
+				return nil, errs.E(errs.InvalidJSON, "bad $size value")
+			}
+
+		// 转换 数组元素匹配 操作符
+		case "$elemMatch":
+			match := utils.M(object[key])
+			if match == nil || len(match) == 0 {
+				return nil, errs.E(errs.InvalidJSON, "bad $elemMatch value")
+			}
+			result, err := t.transformElemMatch(match)
+			if err != nil {
+				return nil, err
+			}
+			answer[key] = result
+
+		// 转换 被包含 操作符，数组中的所有元素都必须在指定列表中
+		// MongoDB 中转换为 {"$not":{"$elemMatch":{"$nin":[...]}}}
+		case "$containedBy":
+			arr := utils.A(object[key])
+			if arr == nil {
+				return nil, errs.E(errs.InvalidJSON, "bad $containedBy: should be an array")
+			}
+			answerArr := types.S{}
+			for _, v := range arr {
+				result, err := transformer(v)
+				if err != nil {
+					return nil, err
+				}
+				answerArr = append(answerArr, result)
+			}
+			answer["$not"] = types.M{
+				"$elemMatch": types.M{"$nin": answerArr},
+			}
+
 		// 转换 正则 操作符
 		case "$regex":
 			s := utils.S(object[key])
@@ -571,6 +614,16 @@ func (t *Transform) transformConstraint(constraint interface{}, inArray bool) (i
 			if geoWithin == nil {
 				return nil, errs.E(errs.InvalidJSON, "bad $geoWithin value")
 			}
+			if v, ok := geoWithin["$centerSphere"]; ok {
+				centerSphere, err := transformCenterSphere(v)
+				if err != nil {
+					return nil, err
+				}
+				answer["$geoWithin"] = types.M{
+					"$centerSphere": centerSphere,
+				}
+				break
+			}
 			polygon := utils.A(geoWithin["$polygon"])
 			if polygon == nil {
 				return nil, errs.E(errs.InvalidJSON, "bad $geoWithin value")
@@ -592,6 +645,31 @@ func (t *Transform) transformConstraint(constraint interface{}, inArray bool) (i
 				"$polygon": points,
 			}
 
+		case "$geoIntersects":
+			// 查询包含指定坐标的 Polygon
+			// {"$geoIntersects":{"$point":{"__type":"GeoPoint","latitude":0.5,"longitude":0.5}}}
+			// ==>
+			// {"$geoIntersects":{"$geometry":{"type":"Point","coordinates":[0.5,0.5]}}}
+			geoIntersects := utils.M(object[key])
+			if geoIntersects == nil {
+				return nil, errs.E(errs.InvalidJSON, "bad $geoIntersects value")
+			}
+			point := utils.M(geoIntersects["$point"])
+			g := geoPointCoder{}
+			if g.isValidJSON(point) == false {
+				return nil, errs.E(errs.InvalidJSON, "bad $geoIntersects value; $point should be GeoPoint")
+			}
+			p, err := g.jsonToDatabase(point)
+			if err != nil {
+				return nil, err
+			}
+			answer["$geoIntersects"] = types.M{
+				"$geometry": types.M{
+					"type":        "Point",
+					"coordinates": p,
+				},
+			}
+
 		default:
 			b, _ := regexp.MatchString(`^\$+`, key)
 			if b {
@@ -605,6 +683,77 @@ func (t *Transform) transformConstraint(constraint interface{}, inArray bool) (i
 	return answer, nil
 }
 
+// transformCenterSphere 转换 $centerSphere 参数 [中心点, 弧度]
+// 中心点可以是 GeoPoint 对象，也可以是 [经度, 纬度]
+func transformCenterSphere(v interface{}) (types.S, error) {
+	centerSphere := utils.A(v)
+	if centerSphere == nil || len(centerSphere) != 2 {
+		return nil, errs.E(errs.InvalidJSON, "bad $geoWithin value; $centerSphere should be an array of [point, distance]")
+	}
+
+	g := geoPointCoder{}
+	var point interface{}
+	if p := utils.M(centerSphere[0]); p != nil && g.isValidJSON(p) {
+		var err error
+		point, err = g.jsonToDatabase(p)
+		if err != nil {
+			return nil, err
+		}
+	} else if g.isValidDatabaseObject(centerSphere[0]) {
+		point = centerSphere[0]
+	} else {
+		return nil, errs.E(errs.InvalidJSON, "bad $geoWithin value; $centerSphere geo point invalid")
+	}
+
+	var distance float64
+	switch d := centerSphere[1].(type) {
+	case float64:
+		distance = d
+	case int:
+		distance = float64(d)
+	default:
+		return nil, errs.E(errs.InvalidJSON, "bad $geoWithin value; $centerSphere distance invalid")
+	}
+	if distance < 0 {
+		return nil, errs.E(errs.InvalidJSON, "bad $geoWithin value; $centerSphere distance invalid")
+	}
+	return types.S{point, distance}, nil
+}
+
+// transformElemMatch 转换 $elemMatch 中的条件
+// key 均以 $ 开头时为对数组元素本身的限制，如 {"$gte":80,"$lt":85}
+// 否则为对元素中字段的限制，如 {"product":"xyz","score":{"$gte":8}}
+func (t *Transform) transformElemMatch(match types.M) (types.M, error) {
+	for key := range match {
+		if strings.HasPrefix(key, "$") {
+			result, err := t.transformConstraint(match, true)
+			if err != nil {
+				return nil, err
+			}
+			if result == cannotTransform() {
+				return nil, errs.E(errs.InvalidJSON, "bad $elemMatch value")
+			}
+			return utils.M(result), nil
+		}
+	}
+
+	answer := types.M{}
+	for key, value := range match {
+		result, err := t.transformConstraint(value, true)
+		if err != nil {
+			return nil, err
+		}
+		if result == cannotTransform() {
+			result, err = t.transformInteriorAtom(value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		answer[key] = result
+	}
+	return answer, nil
+}
+
 // transformTopLevelAtom 转换顶层的原子数据
 func (t *Transform) transformTopLevelAtom(atom interface{}) (interface{}, error) {
 	if atom == nil {
@@ -685,6 +834,17 @@ func (t *Transform) transformTopLevelAtom(atom interface{}) (interface{}, error)
 			return g.jsonToDatabase(object)
 		}
 
+		// Polygon 类型
+		// {
+		// 	"__type": "Polygon",
+		// 	"coordinates": [[0,0],[0,1],[1,1]]
+		// }
+		// ==> {"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}
+		p := polygonCoder{}
+		if p.isValidJSON(object) {
+			return p.jsonToDatabase(object)
+		}
+
 		// File 类型
 		// {
 		// 	"__type": "File",
@@ -838,6 +998,40 @@ func (t *Transform) transformUpdateOperator(operator interface{}, flatten bool)
 			"arg":  toRemove,
 		}, nil
 
+	// 更新数组中第一个符合 match 条件的元素，match 条件由 orm 添加到查询条件中
+	// {
+	// 	"__op":"UpdateMatching",
+	// 	"match":{"id":"a"},
+	// 	"set":{"done":true}
+	// }
+	// ==>
+	// {
+	// 	"__op":"$set",
+	// 	"arg":{"done":true},
+	// 	"positional":true
+	// }
+	case "UpdateMatching":
+		set := utils.M(operatorMap["set"])
+		if len(set) == 0 {
+			return nil, errs.E(errs.InvalidJSON, "fields to set must be an object")
+		}
+		if flatten {
+			return nil, errs.E(errs.InvalidJSON, "the UpdateMatching operator can not be used when creating an object")
+		}
+		toSet := types.M{}
+		for k, v := range set {
+			o, err := t.transformInteriorValue(v)
+			if err != nil {
+				return nil, err
+			}
+			toSet[k] = o
+		}
+		return types.M{
+			"__op":       "$set",
+			"arg":        toSet,
+			"positional": true,
+		}, nil
+
 	default:
 		// 不支持的类型
 		return nil, errs.E(errs.CommandUnavailable, "the "+op+" operator is not supported yet")
@@ -849,6 +1043,10 @@ func (t *Transform) parseObjectToMongoObjectForCreate(className string, create t
 	if create == nil {
 		return nil, nil
 	}
+	create, err := storage.CheckNestedKeys(create)
+	if err != nil {
+		return nil, err
+	}
 	create = t.addLegacyACL(create)
 	mongoCreate := types.M{}
 
@@ -1092,6 +1290,10 @@ func (t *Transform) transformUpdate(className string, update types.M, parseForma
 	if update == nil {
 		return nil, nil
 	}
+	update, err := storage.CheckNestedKeys(update)
+	if err != nil {
+		return nil, err
+	}
 
 	mongoUpdate := types.M{}
 	// 转换并设置权限信息
@@ -1143,7 +1345,14 @@ func (t *Transform) transformUpdate(className string, update types.M, parseForma
 			if p := utils.M(mongoUpdate[opKey]); p != nil {
 				opValue = p
 			}
-			opValue[key] = op["arg"]
+			if positional, _ := op["positional"].(bool); positional {
+				// 使用位置操作符 $ 更新数组中匹配的元素，如 {"$set":{"items.$.done":true}}
+				for k, v := range utils.M(op["arg"]) {
+					opValue[key+".$."+k] = v
+				}
+			} else {
+				opValue[key] = op["arg"]
+			}
 			mongoUpdate[opKey] = opValue
 		} else {
 			// 转换其他数据
@@ -1439,6 +1648,16 @@ func (t *Transform) mongoObjectToParseObject(className string, mongoObject inter
 						restObject[key] = g.databaseToJSON(value)
 						break
 					}
+					// polygon 类型
+					// {
+					// 	"__type":      "Polygon",
+					// 	"coordinates": [[0,0],[0,1],[1,1],[0,0]]
+					// }
+					p := polygonCoder{}
+					if expectedType != nil && utils.S(expectedType["type"]) == "Polygon" && p.isValidDatabaseObject(value) {
+						restObject[key] = p.databaseToJSON(value)
+						break
+					}
 					// bytesCoder 类型
 					// {
 					// 	"__type": "Bytes",
@@ -1495,12 +1714,7 @@ type dateCoder struct{}
 
 func (d dateCoder) databaseToJSON(object interface{}) types.M {
 	if data, ok := object.(time.Time); ok {
-		json := utils.TimetoString(data)
-
-		return types.M{
-			"__type": "Date",
-			"iso":    json,
-		}
+		return utils.DateJSON(data)
 	}
 	return types.M{
 		"__type": "Date",
@@ -1660,6 +1874,67 @@ func (g geoPointCoder) isValidJSON(value types.M) bool {
 	return value != nil && utils.S(value["__type"]) == "GeoPoint" && value["longitude"] != nil && value["latitude"] != nil
 }
 
+// polygonCoder Polygon 类型处理
+// 数据库中以 GeoJSON 格式保存，坐标为 [经度, 纬度] ，首尾坐标相同
+type polygonCoder struct{}
+
+func (p polygonCoder) databaseToJSON(object interface{}) types.M {
+	coordinates := types.S{}
+	for _, c := range polygonRing(object) {
+		point := utils.A(c)
+		coordinates = append(coordinates, types.S{point[1], point[0]})
+	}
+	return types.M{
+		"__type":      "Polygon",
+		"coordinates": coordinates,
+	}
+}
+
+func (p polygonCoder) isValidDatabaseObject(object interface{}) bool {
+	ring := polygonRing(object)
+	if len(ring) < 4 {
+		return false
+	}
+	for _, c := range ring {
+		if point := utils.A(c); len(point) != 2 {
+			return false
+		}
+	}
+	return true
+}
+
+func (p polygonCoder) jsonToDatabase(json types.M) (interface{}, error) {
+	points, err := storage.ParsePolygon(json)
+	if err != nil {
+		return nil, err
+	}
+	ring := types.S{}
+	for _, point := range points {
+		ring = append(ring, types.S{point[1], point[0]})
+	}
+	return types.M{
+		"type":        "Polygon",
+		"coordinates": types.S{ring},
+	}, nil
+}
+
+func (p polygonCoder) isValidJSON(value types.M) bool {
+	return storage.IsPolygon(value)
+}
+
+// polygonRing 返回 GeoJSON Polygon 的外环坐标
+func polygonRing(object interface{}) types.S {
+	geometry := utils.M(object)
+	if geometry == nil || utils.S(geometry["type"]) != "Polygon" {
+		return nil
+	}
+	rings := utils.A(geometry["coordinates"])
+	if len(rings) == 0 {
+		return nil
+	}
+	return utils.A(rings[0])
+}
+
 // fileCoder File 类型处理
 type fileCoder struct{}
 
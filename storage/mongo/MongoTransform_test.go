@@ -796,6 +796,85 @@ func Test_transformConstraint(t *testing.T) {
 		t.Error("expect:", expect, "get result:", result)
 	}
 	/*************************************************/
+	constraint = types.M{"$size": 2.0}
+	inArray = true
+	result, err = tf.transformConstraint(constraint, inArray)
+	expect = types.M{"$size": 2}
+	if err != nil || reflect.DeepEqual(result, expect) == false {
+		t.Error("expect:", expect, "get result:", result)
+	}
+	/*************************************************/
+	constraint = types.M{"$size": 1.5}
+	inArray = true
+	result, err = tf.transformConstraint(constraint, inArray)
+	expect = errs.E(errs.InvalidJSON, "bad $size value")
+	if reflect.DeepEqual(err, expect) == false || result != nil {
+		t.Error("expect:", expect, "get result:", err)
+	}
+	/*************************************************/
+	constraint = types.M{"$elemMatch": types.M{"$gte": 80, "$lt": 85}}
+	inArray = true
+	result, err = tf.transformConstraint(constraint, inArray)
+	expect = types.M{"$elemMatch": types.M{"$gte": 80, "$lt": 85}}
+	if err != nil || reflect.DeepEqual(result, expect) == false {
+		t.Error("expect:", expect, "get result:", result)
+	}
+	/*************************************************/
+	constraint = types.M{"$elemMatch": types.M{"product": "xyz", "score": types.M{"$gte": 8}}}
+	inArray = true
+	result, err = tf.transformConstraint(constraint, inArray)
+	expect = types.M{"$elemMatch": types.M{"product": "xyz", "score": types.M{"$gte": 8}}}
+	if err != nil || reflect.DeepEqual(result, expect) == false {
+		t.Error("expect:", expect, "get result:", result)
+	}
+	/*************************************************/
+	constraint = types.M{"$elemMatch": "hello"}
+	inArray = true
+	result, err = tf.transformConstraint(constraint, inArray)
+	expect = errs.E(errs.InvalidJSON, "bad $elemMatch value")
+	if reflect.DeepEqual(err, expect) == false || result != nil {
+		t.Error("expect:", expect, "get result:", err)
+	}
+	/*************************************************/
+	constraint = types.M{"$containedBy": types.S{"hello", "world"}}
+	inArray = true
+	result, err = tf.transformConstraint(constraint, inArray)
+	expect = types.M{"$not": types.M{"$elemMatch": types.M{"$nin": types.S{"hello", "world"}}}}
+	if err != nil || reflect.DeepEqual(result, expect) == false {
+		t.Error("expect:", expect, "get result:", result)
+	}
+	/*************************************************/
+	constraint = types.M{"$containedBy": "hello"}
+	inArray = true
+	result, err = tf.transformConstraint(constraint, inArray)
+	expect = errs.E(errs.InvalidJSON, "bad $containedBy: should be an array")
+	if reflect.DeepEqual(err, expect) == false || result != nil {
+		t.Error("expect:", expect, "get result:", err)
+	}
+	/*************************************************/
+	constraint = types.M{"$geoWithin": types.M{
+		"$centerSphere": types.S{
+			types.M{"__type": "GeoPoint", "longitude": 120.0, "latitude": 30.0},
+			0.1,
+		},
+	}}
+	inArray = false
+	result, err = tf.transformConstraint(constraint, inArray)
+	expect = types.M{"$geoWithin": types.M{"$centerSphere": types.S{types.S{120.0, 30.0}, 0.1}}}
+	if err != nil || reflect.DeepEqual(result, expect) == false {
+		t.Error("expect:", expect, "get result:", result)
+	}
+	/*************************************************/
+	constraint = types.M{"$geoWithin": types.M{
+		"$centerSphere": types.S{types.S{120, 30}, -1},
+	}}
+	inArray = false
+	result, err = tf.transformConstraint(constraint, inArray)
+	expect = errs.E(errs.InvalidJSON, "bad $geoWithin value; $centerSphere distance invalid")
+	if reflect.DeepEqual(err, expect) == false || result != nil {
+		t.Error("expect:", expect, "get result:", err)
+	}
+	/*************************************************/
 	constraint = types.M{"$regex": 1024}
 	inArray = true
 	result, err = tf.transformConstraint(constraint, inArray)
@@ -1157,6 +1236,37 @@ func Test_transformConstraint(t *testing.T) {
 	if err != nil || reflect.DeepEqual(result, expect) == false {
 		t.Error("expect:", expect, "get result:", result)
 	}
+	/*************************************************/
+	constraint = types.M{
+		"$geoIntersects": types.M{
+			"$point": types.M{"__type": "GeoPoint", "latitude": 0.5, "longitude": 1.5},
+		},
+	}
+	inArray = false
+	result, err = tf.transformConstraint(constraint, inArray)
+	expect = types.M{
+		"$geoIntersects": types.M{
+			"$geometry": types.M{
+				"type":        "Point",
+				"coordinates": types.S{1.5, 0.5},
+			},
+		},
+	}
+	if err != nil || reflect.DeepEqual(result, expect) == false {
+		t.Error("expect:", expect, "get result:", result)
+	}
+	/*************************************************/
+	constraint = types.M{
+		"$geoIntersects": types.M{
+			"$point": types.S{0.5, 1.5},
+		},
+	}
+	inArray = false
+	result, err = tf.transformConstraint(constraint, inArray)
+	expect = errs.E(errs.InvalidJSON, "bad $geoIntersects value; $point should be GeoPoint")
+	if reflect.DeepEqual(err, expect) == false || result != nil {
+		t.Error("expect:", expect, "get result:", err)
+	}
 }
 
 func Test_transformTopLevelAtom(t *testing.T) {
@@ -1477,6 +1587,34 @@ func Test_transformUpdateOperator(t *testing.T) {
 		t.Error("expect:", expect, "get result:", result)
 	}
 	/*************************************************/
+	operator = types.M{
+		"__op":  "UpdateMatching",
+		"match": types.M{"id": "a"},
+		"set":   types.M{"done": true},
+	}
+	flatten = false
+	result, err = tf.transformUpdateOperator(operator, flatten)
+	expect = types.M{
+		"__op":       "$set",
+		"arg":        types.M{"done": true},
+		"positional": true,
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "get result:", result)
+	}
+	/*************************************************/
+	operator = types.M{
+		"__op":  "UpdateMatching",
+		"match": types.M{"id": "a"},
+		"set":   types.M{"done": true},
+	}
+	flatten = true
+	result, err = tf.transformUpdateOperator(operator, flatten)
+	expect = errs.E(errs.InvalidJSON, "the UpdateMatching operator can not be used when creating an object")
+	if err == nil || reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "get result:", err)
+	}
+	/*************************************************/
 	operator = types.M{
 		"__op": "OtherOp",
 	}
@@ -2281,6 +2419,28 @@ func Test_transformUpdate(t *testing.T) {
 	if err != nil || reflect.DeepEqual(expect, result) == false {
 		t.Error("expect:", expect, "get result:", result)
 	}
+	/*************************************************/
+	className = "post"
+	update = types.M{
+		"title": "hello",
+		"items": types.M{
+			"__op":  "UpdateMatching",
+			"match": types.M{"id": "a"},
+			"set":   types.M{"done": true, "count": 2},
+		},
+	}
+	parseFormatSchema = types.M{}
+	result, err = tf.transformUpdate(className, update, parseFormatSchema)
+	expect = types.M{
+		"$set": types.M{
+			"title":         "hello",
+			"items.$.done":  true,
+			"items.$.count": 2,
+		},
+	}
+	if err != nil || reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "get result:", result)
+	}
 }
 
 func Test_nestedMongoObjectToNestedParseObject(t *testing.T) {
@@ -3362,6 +3522,85 @@ func Test_geoPointCoder(t *testing.T) {
 	}
 }
 
+func Test_polygonCoder(t *testing.T) {
+	pc := polygonCoder{}
+	var databaseObject interface{}
+	var jsonObject types.M
+	var ok bool
+	var result interface{}
+	var expect interface{}
+	var err error
+	/*************************************************/
+	jsonObject = types.M{
+		"__type":      "Polygon",
+		"coordinates": types.S{types.S{0, 0}, types.S{0, 1}, types.S{1, 1}},
+	}
+	ok = pc.isValidJSON(jsonObject)
+	if ok == false {
+		t.Error("expect:", true, "get result:", ok)
+	}
+	result, err = pc.jsonToDatabase(jsonObject)
+	expect = types.M{
+		"type": "Polygon",
+		"coordinates": types.S{
+			types.S{
+				types.S{0.0, 0.0},
+				types.S{1.0, 0.0},
+				types.S{1.0, 1.0},
+				types.S{0.0, 0.0},
+			},
+		},
+	}
+	if err != nil || reflect.DeepEqual(result, expect) == false {
+		t.Error("expect:", expect, "get result:", result, err)
+	}
+	/*************************************************/
+	jsonObject = types.M{
+		"__type":      "Polygon",
+		"coordinates": types.S{types.S{0, 0}, types.S{0, 1}},
+	}
+	_, err = pc.jsonToDatabase(jsonObject)
+	expect = errs.E(errs.InvalidJSON, "Polygon must have at least 3 values")
+	if reflect.DeepEqual(err, expect) == false {
+		t.Error("expect:", expect, "get result:", err)
+	}
+	/*************************************************/
+	databaseObject = types.S{20, 20}
+	ok = pc.isValidDatabaseObject(databaseObject)
+	if ok {
+		t.Error("expect:", false, "get result:", ok)
+	}
+	/*************************************************/
+	databaseObject = types.M{
+		"type": "Polygon",
+		"coordinates": types.S{
+			types.S{
+				types.S{0.0, 0.0},
+				types.S{1.0, 0.0},
+				types.S{1.0, 1.0},
+				types.S{0.0, 0.0},
+			},
+		},
+	}
+	ok = pc.isValidDatabaseObject(databaseObject)
+	if ok == false {
+		t.Error("expect:", true, "get result:", ok)
+	}
+	jsonObject = pc.databaseToJSON(databaseObject)
+	expect = types.M{
+		"__type": "Polygon",
+		"coordinates": types.S{
+			types.S{0.0, 0.0},
+			types.S{0.0, 1.0},
+			types.S{1.0, 1.0},
+			types.S{0.0, 0.0},
+		},
+	}
+	if reflect.DeepEqual(jsonObject, expect) == false {
+		t.Error("expect:", expect, "get jsonObject:", jsonObject)
+	}
+}
+
 func Test_fileCoder(t *testing.T) {
 	fc := fileCoder{}
 	var databaseObject interface{}
@@ -0,0 +1,23 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/okobsamoht/talisman/bench"
+)
+
+func benchmarkTransformWhere(b *testing.B, aclSize int) {
+	tf := NewTransform()
+	schema := bench.Schema()
+	where := bench.WhereWithACL(aclSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := tf.transformWhere(bench.ClassName, where, schema)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTransformWhere_ACL1(b *testing.B)   { benchmarkTransformWhere(b, 1) }
+func BenchmarkTransformWhere_ACL100(b *testing.B) { benchmarkTransformWhere(b, 100) }
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"strconv"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// IsPolygon 是否为 Polygon 类型的值
+func IsPolygon(value types.M) bool {
+	return value != nil && utils.S(value["__type"]) == "Polygon"
+}
+
+// ParsePolygon 校验并解析 Polygon 类型的值，返回闭合的坐标列表，每个坐标为 [纬度, 经度]
+// 格式为 {"__type":"Polygon","coordinates":[[lat,lng],[lat,lng],[lat,lng]]} ，至少包含 3 个坐标，首尾坐标不同时自动闭合
+func ParsePolygon(value types.M) ([][2]float64, error) {
+	if IsPolygon(value) == false {
+		return nil, errs.E(errs.InvalidJSON, "invalid Polygon value")
+	}
+	coordinates := utils.A(value["coordinates"])
+	if len(coordinates) < 3 {
+		return nil, errs.E(errs.InvalidJSON, "Polygon must have at least 3 values")
+	}
+	points := [][2]float64{}
+	for _, c := range coordinates {
+		point := utils.A(c)
+		if len(point) != 2 {
+			return nil, errs.E(errs.InvalidJSON, "Polygon coordinates must be [latitude, longitude]")
+		}
+		latitude, ok := toFloat(point[0])
+		if ok == false || latitude < -90 || latitude > 90 {
+			return nil, errs.E(errs.InvalidJSON, "invalid latitude")
+		}
+		longitude, ok := toFloat(point[1])
+		if ok == false || longitude < -180 || longitude > 180 {
+			return nil, errs.E(errs.InvalidJSON, "invalid longitude")
+		}
+		points = append(points, [2]float64{latitude, longitude})
+	}
+	if points[0] != points[len(points)-1] {
+		points = append(points, points[0])
+	}
+	// 去掉闭合点后至少需要 3 个不同的坐标
+	unique := map[[2]float64]bool{}
+	for _, p := range points {
+		unique[p] = true
+	}
+	if len(unique) < 3 {
+		return nil, errs.E(errs.InvalidJSON, "Polygon must have at least 3 distinct values")
+	}
+	return points, nil
+}
+
+// toFloat 转换数值类型，兼容 int 与数字字符串
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
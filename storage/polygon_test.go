@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_ParsePolygon(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   types.M
+		want    [][2]float64
+		wantErr error
+	}{
+		{
+			name:    "1",
+			value:   types.M{"__type": "GeoPoint", "latitude": 10, "longitude": 10},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidJSON, "invalid Polygon value"),
+		},
+		{
+			name:    "2",
+			value:   types.M{"__type": "Polygon", "coordinates": types.S{types.S{0, 0}, types.S{0, 1}}},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidJSON, "Polygon must have at least 3 values"),
+		},
+		{
+			name:    "3",
+			value:   types.M{"__type": "Polygon", "coordinates": types.S{types.S{0, 0}, types.S{0, 1}, types.S{91, 1}}},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidJSON, "invalid latitude"),
+		},
+		{
+			name:    "4",
+			value:   types.M{"__type": "Polygon", "coordinates": types.S{types.S{0, 0}, types.S{0, 1}, types.S{1, "abc"}}},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidJSON, "invalid longitude"),
+		},
+		{
+			name:    "5",
+			value:   types.M{"__type": "Polygon", "coordinates": types.S{types.S{0, 0}, types.S{0, 1}, types.S{0, 0}}},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidJSON, "Polygon must have at least 3 distinct values"),
+		},
+		{
+			name:    "6",
+			value:   types.M{"__type": "Polygon", "coordinates": types.S{types.S{0, 0}, types.S{0, 1}, types.S{1.5, 1}}},
+			want:    [][2]float64{{0, 0}, {0, 1}, {1.5, 1}, {0, 0}},
+			wantErr: nil,
+		},
+		{
+			name:    "7",
+			value:   types.M{"__type": "Polygon", "coordinates": types.S{types.S{0, 0}, types.S{0, 1}, types.S{1, 1}, types.S{0, 0}}},
+			want:    [][2]float64{{0, 0}, {0, 1}, {1, 1}, {0, 0}},
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParsePolygon(tt.value)
+		if reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. ParsePolygon() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("%q. ParsePolygon() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/lib/pq"
 	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/storage"
 	"github.com/okobsamoht/talisman/types"
 	"github.com/okobsamoht/talisman/utils"
 )
@@ -25,12 +26,24 @@ const postgresDuplicateColumnError = "42701"
 const postgresDuplicateObjectError = "42710"
 const postgresUniqueIndexViolationError = "23505"
 const postgresTransactionAbortedError = "25P02"
+const postgresSerializationFailureError = "40001"
+const postgresDeadlockDetectedError = "40P01"
+
+// schemaUpdateRetries 修改类结构时发生序列化错误或者死锁的最大尝试次数
+const schemaUpdateRetries = 3
 
 // PostgresAdapter postgres 数据库适配器
 type PostgresAdapter struct {
 	collectionPrefix string
 	collectionList   []string
 	db               *sql.DB
+	stmts            *stmtCache
+	stmtCacheSize    int
+	rowLevelSecurity bool
+	classCache       *storage.ClassCache
+	replicas         []*replica
+	replicaNext      uint32
+	replicaStop      chan struct{}
 }
 
 // NewPostgresAdapter ...
@@ -39,6 +52,30 @@ func NewPostgresAdapter(collectionPrefix string, db *sql.DB) *PostgresAdapter {
 		collectionPrefix: collectionPrefix,
 		collectionList:   []string{},
 		db:               db,
+		stmts:            newStmtCache(db, 0),
+		classCache:       storage.NewClassCache(0),
+	}
+}
+
+// SetClassCacheTTL 设置 GetClass 缓存的有效期，为 0 时不缓存
+func (p *PostgresAdapter) SetClassCacheTTL(ttl time.Duration) {
+	p.classCache = storage.NewClassCache(ttl)
+}
+
+// InvalidateClassCache 使 GetClass 缓存全部失效
+func (p *PostgresAdapter) InvalidateClassCache() {
+	p.classCache.Bump()
+}
+
+// SetStmtCacheSize 设置预编译语句缓存的容量，为 0 时不缓存
+// 缓存以 SQL 语句为 key ，用于减少 schema 查询、 _Join 查询、按 objectId 查询等高频语句的重复解析
+func (p *PostgresAdapter) SetStmtCacheSize(size int) {
+	p.stmts.Clear()
+	p.stmts = newStmtCache(p.db, size)
+	p.stmtCacheSize = size
+	for _, r := range p.replicas {
+		r.stmts.Clear()
+		r.stmts = newStmtCache(r.db, size)
 	}
 }
 
@@ -61,7 +98,7 @@ func (p *PostgresAdapter) ensureSchemaCollectionExists() error {
 // ClassExists 检测数据库中是否存在指定类
 func (p *PostgresAdapter) ClassExists(name string) bool {
 	var result bool
-	err := p.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM   information_schema.tables WHERE table_name = $1)`, name).Scan(&result)
+	err := p.stmts.QueryRow(`SELECT EXISTS (SELECT 1 FROM   information_schema.tables WHERE table_name = $1)`, name).Scan(&result)
 	if err != nil {
 		return false
 	}
@@ -87,6 +124,7 @@ func (p *PostgresAdapter) SetClassLevelPermissions(className string, CLPs types.
 	if err != nil {
 		return err
 	}
+	p.classCache.Bump()
 
 	return nil
 }
@@ -126,6 +164,7 @@ func (p *PostgresAdapter) CreateClass(className string, schema types.M) (types.M
 	if err != nil {
 		return nil, err
 	}
+	p.classCache.Bump()
 
 	return toParseSchema(schema), nil
 }
@@ -165,6 +204,10 @@ func (p *PostgresAdapter) createTable(className string, schema types.M, tx *sql.
 			relations = append(relations, fieldName)
 			continue
 		}
+		// 计算字段在读取时生成，不需要创建列
+		if utils.S(parseType["type"]) == "Computed" {
+			continue
+		}
 
 		if fieldName == "_rperm" || fieldName == "_wperm" {
 			parseType["contents"] = types.M{"type": "String"}
@@ -212,6 +255,13 @@ func (p *PostgresAdapter) createTable(className string, schema types.M, tx *sql.
 		}
 	}
 
+	if p.rowLevelSecurity && fields["_rperm"] != nil && fields["_wperm"] != nil {
+		err = p.enableRowLevelSecurity(className, tx)
+		if err != nil {
+			return err
+		}
+	}
+
 	// 创建 relation 表
 	for _, fieldName := range relations {
 		name := fmt.Sprintf(`_Join:%s:%s`, fieldName, className)
@@ -229,74 +279,133 @@ func (p *PostgresAdapter) createTable(className string, schema types.M, tx *sql.
 	return nil
 }
 
-// AddFieldIfNotExists 添加字段定义
+// AddFieldIfNotExists 添加字段定义，发生序列化错误或者死锁时重试
 func (p *PostgresAdapter) AddFieldIfNotExists(className, fieldName string, fieldType types.M) error {
 	if fieldType == nil {
 		fieldType = types.M{}
 	}
 
+	var err error
+	for i := 0; i < schemaUpdateRetries; i++ {
+		err = p.addFieldIfNotExists(className, fieldName, fieldType)
+		if isSerializationError(err) == false {
+			break
+		}
+	}
+	return err
+}
+
+// addFieldIfNotExists 在一个事务中添加列与字段定义
+// 先锁定 _SCHEMA 中类所在的行，再修改表结构，与 DeleteFields 的加锁顺序一致
+// 字段定义通过一条 UPDATE 语句写入，已存在时不修改，并发请求不会互相覆盖
+func (p *PostgresAdapter) addFieldIfNotExists(className, fieldName string, fieldType types.M) error {
 	tx, err := p.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	found := true
+	var exists bool
+	qs := `SELECT ("schema"->'fields'->$2) IS NOT NULL FROM "_SCHEMA" WHERE "className" = $1 FOR UPDATE`
+	err = tx.QueryRow(qs, className, fieldName).Scan(&exists)
+	if err == sql.ErrNoRows {
+		found = false
+	} else if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
 
-	if utils.S(fieldType["type"]) != "Relation" {
+	if utils.S(fieldType["type"]) == "Computed" {
+		// 计算字段只保存定义，不需要创建列
+	} else if utils.S(fieldType["type"]) != "Relation" {
 		tp, err := parseTypeToPostgresType(fieldType)
 		if err != nil {
 			return err
 		}
-		qs := fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN "%s" %s`, className, fieldName, tp)
+		// 列已存在时回滚到保存点，事务可以继续使用
+		_, err = tx.Exec(`SAVEPOINT add_column`)
+		if err != nil {
+			return err
+		}
+		qs = fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN "%s" %s`, className, fieldName, tp)
 		_, err = tx.Exec(qs)
 		if err != nil {
-			if e, ok := err.(*pq.Error); ok {
-				if e.Code == postgresRelationDoesNotExistError {
-					// TODO 添加默认字段
-					_, ce := p.CreateClass(className, types.M{"fields": types.M{fieldName: fieldType}})
-					if ce != nil {
-						return ce
-					}
-				} else if e.Code == postgresDuplicateColumnError {
-					// Column 已经存在，由其他请求创建
-				} else {
+			e, ok := err.(*pq.Error)
+			if ok && e.Code == postgresRelationDoesNotExistError {
+				// 表不存在时创建类，字段定义一同写入
+				tx.Rollback()
+				_, err = p.CreateClass(className, types.M{"fields": types.M{fieldName: fieldType}})
+				if found == false && errs.GetErrorCode(err) == errs.DuplicateValue {
+					// 类已由其他请求创建，重新添加字段
+					return p.addFieldIfNotExists(className, fieldName, fieldType)
+				}
+				return err
+			} else if ok && e.Code == postgresDuplicateColumnError {
+				// Column 已经存在，由其他请求创建
+				_, err = tx.Exec(`ROLLBACK TO SAVEPOINT add_column`)
+				if err != nil {
 					return err
 				}
 			} else {
 				return err
 			}
-			// 发生错误之后 tx 异常中止，需要重新获取
-			tx, err = p.db.Begin()
-			if err != nil {
-				return err
-			}
 		}
 	} else {
 		name := fmt.Sprintf(`_Join:%s:%s`, fieldName, className)
-		qs := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" ("relatedId" varChar(120), "owningId" varChar(120), PRIMARY KEY("relatedId", "owningId") )`, name)
+		qs = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" ("relatedId" varChar(120), "owningId" varChar(120), PRIMARY KEY("relatedId", "owningId") )`, name)
 		_, err := tx.Exec(qs)
 		if err != nil {
 			return err
 		}
 	}
 
-	qs := `SELECT "schema" FROM "_SCHEMA" WHERE "className" = $1 and ("schema"::json->'fields'->$2) is not null`
-	rows, err := p.db.Query(qs, className, fieldName)
+	b, err := json.Marshal(fieldType)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
-	if rows.Next() {
-		return nil
+	path := fmt.Sprintf(`{fields,%s}`, fieldName)
+	qs = `UPDATE "_SCHEMA" SET "schema" = jsonb_set(jsonb_set("schema", '{fields}', COALESCE("schema"->'fields', '{}'::jsonb)), $1::text[], $2::jsonb) WHERE "className" = $3 AND ("schema"->'fields'->$4) IS NULL`
+	_, err = tx.Exec(qs, path, string(b), className, fieldName)
+	if err != nil {
+		return err
 	}
 
-	path := fmt.Sprintf(`{fields,%s}`, fieldName)
-	qs = `UPDATE "_SCHEMA" SET "schema"=jsonb_set("schema", $1, $2)  WHERE "className"=$3`
-	b, _ := json.Marshal(fieldType)
-	_, err = tx.Exec(qs, path, string(b), className)
+	err = tx.Commit()
 	if err != nil {
 		return err
 	}
+	// 表结构发生变化，清空预编译语句缓存
+	p.stmts.Clear()
+	p.classCache.Bump()
+	return nil
+}
+
+// rowSize 估算一行数据的字节数，字符串与 json 按实际长度计算，其他类型按 8 字节计算
+func rowSize(values []*interface{}) int {
+	size := 0
+	for _, v := range values {
+		switch value := (*v).(type) {
+		case []byte:
+			size += len(value)
+		case string:
+			size += len(value)
+		case nil:
+		default:
+			size += 8
+		}
+	}
+	return size
+}
 
-	return tx.Commit()
+// isSerializationError 是否为可以重试的序列化错误或者死锁
+func isSerializationError(err error) bool {
+	if e, ok := err.(*pq.Error); ok {
+		return e.Code == postgresSerializationFailureError || e.Code == postgresDeadlockDetectedError
+	}
+	return false
 }
 
 // DeleteClass 删除指定表
@@ -322,6 +431,8 @@ func (p *PostgresAdapter) DeleteClass(className string) (types.M, error) {
 	if err != nil {
 		return nil, err
 	}
+	p.stmts.Clear()
+	p.classCache.Bump()
 
 	return types.M{}, nil
 }
@@ -380,7 +491,13 @@ func (p *PostgresAdapter) DeleteAllClasses() error {
 		}
 	}
 
-	return tx.Commit()
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	p.stmts.Clear()
+	p.classCache.Bump()
+	return nil
 }
 
 // DeleteFields 删除字段
@@ -396,8 +513,8 @@ func (p *PostgresAdapter) DeleteFields(className string, schema types.M, fieldNa
 	fldNames := types.S{}
 	for _, fieldName := range fieldNames {
 		field := utils.M(fields[fieldName])
-		if field != nil && utils.S(field["type"]) == "Relation" {
-			// 不处理 Relation 类型字段
+		if field != nil && (utils.S(field["type"]) == "Relation" || utils.S(field["type"]) == "Computed") {
+			// 不处理 Relation 与 Computed 类型字段
 		} else {
 			fldNames = append(fldNames, fieldName)
 		}
@@ -412,18 +529,21 @@ func (p *PostgresAdapter) DeleteFields(className string, schema types.M, fieldNa
 	}
 	columns := strings.Join(columnArray, ", DROP COLUMN ")
 
-	b, err := json.Marshal(schema)
-	if err != nil {
-		return err
-	}
-
 	tx, err := p.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	qs := `UPDATE "_SCHEMA" SET "schema"=$1 WHERE "className"=$2`
-	_, err = tx.Exec(qs, b, className)
+	// 只删除指定的字段定义，不覆盖其他请求同时添加的字段
+	removed := ""
+	args := types.S{className}
+	for _, fieldName := range fieldNames {
+		args = append(args, fieldName)
+		removed += fmt.Sprintf(` - $%d::text`, len(args))
+	}
+	qs := fmt.Sprintf(`UPDATE "_SCHEMA" SET "schema" = jsonb_set("schema", '{fields}', COALESCE("schema"->'fields', '{}'::jsonb)%s) WHERE "className"=$1`, removed)
+	_, err = tx.Exec(qs, args...)
 	if err != nil {
 		return err
 	}
@@ -437,7 +557,13 @@ func (p *PostgresAdapter) DeleteFields(className string, schema types.M, fieldNa
 		}
 	}
 
-	return tx.Commit()
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	p.stmts.Clear()
+	p.classCache.Bump()
+	return nil
 }
 
 // CreateObject 创建对象
@@ -452,9 +578,14 @@ func (p *PostgresAdapter) CreateObject(className string, schema, object types.M)
 		return nil
 	}
 	schema = toPostgresSchema(schema)
+	object, err := storage.CheckNestedKeys(object)
+	if err != nil {
+		return err
+	}
+	object = transformACL(object)
 	object = handleDotFields(object)
 
-	err := validateKeys(object)
+	err = validateKeys(object)
 	if err != nil {
 		return err
 	}
@@ -501,11 +632,11 @@ func (p *PostgresAdapter) CreateObject(className string, schema, object types.M)
 				fieldName == "_account_lockout_expires_at" ||
 				fieldName == "_perishable_token_expires_at" ||
 				fieldName == "_password_changed_at" {
-				if v := utils.M(object[fieldName]); v != nil && utils.S(v["iso"]) != "" {
-					valuesArray = append(valuesArray, v["iso"])
-				} else {
-					valuesArray = append(valuesArray, nil)
+				date, err := toPostgresDate(object[fieldName])
+				if err != nil {
+					return err
 				}
+				valuesArray = append(valuesArray, date)
 			}
 
 			continue
@@ -517,11 +648,11 @@ func (p *PostgresAdapter) CreateObject(className string, schema, object types.M)
 		}
 		switch utils.S(tp["type"]) {
 		case "Date":
-			if v := utils.M(object[fieldName]); v != nil && utils.S(v["iso"]) != "" {
-				valuesArray = append(valuesArray, v["iso"])
-			} else {
-				valuesArray = append(valuesArray, nil)
+			date, err := toPostgresDate(object[fieldName])
+			if err != nil {
+				return err
 			}
+			valuesArray = append(valuesArray, date)
 		case "Pointer":
 			if v := utils.M(object[fieldName]); v != nil && utils.S(v["objectId"]) != "" {
 				valuesArray = append(valuesArray, v["objectId"])
@@ -529,28 +660,22 @@ func (p *PostgresAdapter) CreateObject(className string, schema, object types.M)
 				valuesArray = append(valuesArray, "")
 			}
 		case "Array":
+			if fieldName == "_rperm" || fieldName == "_wperm" {
+				valuesArray = append(valuesArray, toPostgresTextArray(utils.A(object[fieldName])))
+				break
+			}
 			b, err := json.Marshal(object[fieldName])
 			if err != nil {
 				return err
 			}
-			if fieldName == "_rperm" || fieldName == "_wperm" {
-				// '[' => '{'
-				if b[0] == 91 {
-					b[0] = 123
-				}
-				// ']' => '}'
-				if len(b) > 0 && b[len(b)-1] == 93 {
-					b[len(b)-1] = 125
-				}
-			}
 			valuesArray = append(valuesArray, b)
-		case "Object":
+		case "Object", "LocalizedString":
 			b, err := json.Marshal(object[fieldName])
 			if err != nil {
 				return err
 			}
 			valuesArray = append(valuesArray, b)
-		case "String", "Number", "Boolean":
+		case "String", "Enum", "Number", "AutoIncrement", "Boolean":
 			valuesArray = append(valuesArray, object[fieldName])
 		case "File":
 			if v := utils.M(object[fieldName]); v != nil && utils.S(v["name"]) != "" {
@@ -561,6 +686,12 @@ func (p *PostgresAdapter) CreateObject(className string, schema, object types.M)
 		case "GeoPoint":
 			geoPoints[fieldName] = object[fieldName]
 			columnsArray = columnsArray[:len(columnsArray)-1]
+		case "Polygon":
+			polygon, err := polygonToPostgres(utils.M(object[fieldName]))
+			if err != nil {
+				return err
+			}
+			valuesArray = append(valuesArray, polygon)
 		default:
 			return errs.E(errs.OtherCause, "Type "+utils.S(tp["type"])+" not supported yet")
 		}
@@ -588,6 +719,8 @@ func (p *PostgresAdapter) CreateObject(className string, schema, object types.M)
 			}
 			if utils.S(tp["type"]) == "Array" {
 				termination = "::jsonb"
+			} else if utils.S(tp["type"]) == "Polygon" {
+				termination = "::polygon"
 			}
 		}
 		initialValues = append(initialValues, fmt.Sprintf(`$%d%s`, index+1, termination))
@@ -614,7 +747,7 @@ func (p *PostgresAdapter) CreateObject(className string, schema, object types.M)
 	valuesPattern := strings.Join(initialValues, ",")
 
 	qs := fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s)`, className, columnsPattern, valuesPattern)
-	_, err = p.db.Exec(qs, valuesArray...)
+	_, err = p.stmts.Exec(qs, valuesArray...)
 	if err != nil {
 		if e, ok := err.(*pq.Error); ok {
 			if e.Code == postgresUniqueIndexViolationError {
@@ -634,7 +767,7 @@ func (p *PostgresAdapter) GetAllClasses() ([]types.M, error) {
 		return nil, err
 	}
 	qs := `SELECT "className","schema" FROM "_SCHEMA"`
-	rows, err := p.db.Query(qs)
+	rows, err := p.stmts.Query(qs)
 	if err != nil {
 		return nil, err
 	}
@@ -661,14 +794,18 @@ func (p *PostgresAdapter) GetAllClasses() ([]types.M, error) {
 	return schemas, nil
 }
 
-// GetClass ...
+// GetClass 获取类的 schema ，优先从适配器内部的缓存中读取
 func (p *PostgresAdapter) GetClass(className string) (types.M, error) {
+	return p.classCache.Load(className, p.getClass)
+}
+
+func (p *PostgresAdapter) getClass(className string) (types.M, error) {
 	err := p.ensureSchemaCollectionExists()
 	if err != nil {
 		return nil, err
 	}
 	qs := `SELECT "schema" FROM "_SCHEMA" WHERE "className"=$1`
-	rows, err := p.db.Query(qs, className)
+	rows, err := p.stmts.Query(qs, className)
 	if err != nil {
 		return nil, err
 	}
@@ -694,6 +831,14 @@ func (p *PostgresAdapter) GetClass(className string) (types.M, error) {
 
 // DeleteObjectsByQuery 删除符合条件的所有对象
 func (p *PostgresAdapter) DeleteObjectsByQuery(className string, schema, query types.M) error {
+	query, q, tx, err := p.aclSession(query)
+	if err != nil {
+		return err
+	}
+	if tx != nil {
+		defer tx.Rollback()
+	}
+
 	where, err := buildWhereClause(schema, query, 1)
 	if err != nil {
 		return err
@@ -704,7 +849,7 @@ func (p *PostgresAdapter) DeleteObjectsByQuery(className string, schema, query t
 	}
 
 	qs := fmt.Sprintf(`WITH deleted AS (DELETE FROM "%s" WHERE %s RETURNING *) SELECT count(*) FROM deleted`, className, where.pattern)
-	row := p.db.QueryRow(qs, where.values...)
+	row := q.QueryRow(qs, where.values...)
 	var count int
 	err = row.Scan(&count)
 	if err != nil {
@@ -721,11 +866,117 @@ func (p *PostgresAdapter) DeleteObjectsByQuery(className string, schema, query t
 		return errs.E(errs.ObjectNotFound, "Object not found.")
 	}
 
+	if tx != nil {
+		return tx.Commit()
+	}
 	return nil
 }
 
+// FindOneAndDelete 删除符合条件的一个对象并返回被删除的对象，没有符合条件的对象时返回空对象
+// 使用 FOR UPDATE SKIP LOCKED 选择对象，并发删除时跳过已被其他事务锁定的对象，保证每个对象只被一个请求取得
+func (p *PostgresAdapter) FindOneAndDelete(className string, schema, query, options types.M) (types.M, error) {
+	if schema == nil {
+		schema = types.M{}
+	}
+	if options == nil {
+		options = types.M{}
+	}
+	schema = toPostgresSchema(schema)
+	fields := utils.M(schema["fields"])
+	if fields == nil {
+		fields = types.M{}
+	}
+
+	query, q, tx, err := p.aclSession(query)
+	if err != nil {
+		return nil, err
+	}
+	if tx != nil {
+		defer tx.Rollback()
+	}
+
+	where, err := buildWhereClause(schema, query, 1)
+	if err != nil {
+		return nil, err
+	}
+	if where.pattern == "" {
+		where.pattern = "TRUE"
+	}
+
+	var sortPattern string
+	if keys, ok := options["sort"].([]string); ok {
+		postgresSort := []string{}
+		for _, key := range keys {
+			if strings.HasPrefix(key, "-") {
+				postgresSort = append(postgresSort, fmt.Sprintf(`%s DESC`, transformSortKey(key[1:])))
+			} else {
+				postgresSort = append(postgresSort, fmt.Sprintf(`%s ASC`, transformSortKey(key)))
+			}
+		}
+		if len(postgresSort) > 0 {
+			sortPattern = fmt.Sprintf(`ORDER BY %s`, strings.Join(postgresSort, ","))
+		}
+	}
+
+	qs := fmt.Sprintf(`DELETE FROM "%s" WHERE "objectId" = (SELECT "objectId" FROM "%s" WHERE %s %s LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING *`, className, className, where.pattern, sortPattern)
+	rows, err := q.Query(qs, where.values...)
+	if err != nil {
+		if e, ok := err.(*pq.Error); ok {
+			// 表不存在返回空
+			if e.Code == postgresRelationDoesNotExistError {
+				return types.M{}, nil
+			}
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	object := types.M{}
+	if rows.Next() {
+		resultColumns, err := rows.Columns()
+		if err != nil {
+			return nil, err
+		}
+
+		resultValues := []*interface{}{}
+		values := types.S{}
+		for i := 0; i < len(resultColumns); i++ {
+			var v interface{}
+			resultValues = append(resultValues, &v)
+			values = append(values, &v)
+		}
+		err = rows.Scan(values...)
+		if err != nil {
+			return nil, err
+		}
+		for i, field := range resultColumns {
+			object[field] = *resultValues[i]
+		}
+
+		object, err = postgresObjectToParseObject(object, fields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if tx != nil {
+		// 提交之前需要先关闭 rows
+		rows.Close()
+		err = tx.Commit()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return object, nil
+}
+
 // Find ...
 func (p *PostgresAdapter) Find(className string, schema, query, options types.M) ([]types.M, error) {
+	return p.find(className, schema, query, options, p.readSession)
+}
+
+// find 查询对象， session 用于选择执行查询的数据库，需要读取最新数据时使用 aclSession
+func (p *PostgresAdapter) find(className string, schema, query, options types.M, session func(types.M) (types.M, queryer, *sql.Tx, error)) ([]types.M, error) {
 	if schema == nil {
 		schema = types.M{}
 	}
@@ -742,8 +993,22 @@ func (p *PostgresAdapter) Find(className string, schema, query, options types.M)
 		hasSkip = true
 	}
 
+	// 只读查询无需提交，结束时回滚即可
+	query, q, tx, err := session(query)
+	if err != nil {
+		return nil, err
+	}
+	if tx != nil {
+		defer tx.Rollback()
+	}
+
+	caseInsensitive := false
+	if v, ok := options["caseInsensitive"].(bool); ok {
+		caseInsensitive = v
+	}
+
 	values := types.S{}
-	where, err := buildWhereClause(schema, query, 1)
+	where, err := buildWhereClauseWithOptions(schema, query, 1, caseInsensitive)
 	if err != nil {
 		return nil, err
 	}
@@ -772,9 +1037,9 @@ func (p *PostgresAdapter) Find(className string, schema, query, options types.M)
 				var postgresKey string
 				if strings.HasPrefix(key, "-") {
 					key = key[1:]
-					postgresKey = fmt.Sprintf(`"%s" DESC`, key)
+					postgresKey = fmt.Sprintf(`%s DESC`, transformSortKey(key))
 				} else {
-					postgresKey = fmt.Sprintf(`"%s" ASC`, key)
+					postgresKey = fmt.Sprintf(`%s ASC`, transformSortKey(key))
 				}
 				postgresSort = append(postgresSort, postgresKey)
 			}
@@ -804,7 +1069,10 @@ func (p *PostgresAdapter) Find(className string, schema, query, options types.M)
 	}
 
 	qs := fmt.Sprintf(`SELECT %s FROM "%s" %s %s %s %s`, columns, className, wherePattern, sortPattern, limitPattern, skipPattern)
-	rows, err := p.db.Query(qs, values...)
+	if requestID, ok := options["requestId"].(string); ok && utils.IsRequestID(requestID) {
+		qs = "/* requestId:" + requestID + " */ " + qs
+	}
+	rows, err := q.Query(qs, values...)
 	if err != nil {
 		if e, ok := err.(*pq.Error); ok {
 			// 表不存在返回空
@@ -821,6 +1089,7 @@ func (p *PostgresAdapter) Find(className string, schema, query, options types.M)
 		fields = types.M{}
 	}
 
+	guard := storage.NewResultGuard(options)
 	results := []types.M{}
 	var resultColumns []string
 	for rows.Next() {
@@ -841,6 +1110,11 @@ func (p *PostgresAdapter) Find(className string, schema, query, options types.M)
 		if err != nil {
 			return nil, err
 		}
+		// 超出结果大小限制时中止，不再读取剩余的行
+		err = guard.Add(rowSize(resultValues))
+		if err != nil {
+			return nil, err
+		}
 		object := types.M{}
 		for i, field := range resultColumns {
 			object[field] = *resultValues[i]
@@ -859,6 +1133,14 @@ func (p *PostgresAdapter) Find(className string, schema, query, options types.M)
 
 // Count ...
 func (p *PostgresAdapter) Count(className string, schema, query types.M) (int, error) {
+	query, q, tx, err := p.readSession(query)
+	if err != nil {
+		return 0, err
+	}
+	if tx != nil {
+		defer tx.Rollback()
+	}
+
 	where, err := buildWhereClause(schema, query, 1)
 	if err != nil {
 		return 0, err
@@ -870,7 +1152,7 @@ func (p *PostgresAdapter) Count(className string, schema, query types.M) (int, e
 	}
 
 	qs := fmt.Sprintf(`SELECT count(*) FROM "%s" %s`, className, wherePattern)
-	rows, err := p.db.Query(qs, where.values...)
+	rows, err := q.Query(qs, where.values...)
 	if err != nil {
 		if e, ok := err.(*pq.Error); ok {
 			if e.Code == postgresRelationDoesNotExistError {
@@ -891,6 +1173,40 @@ func (p *PostgresAdapter) Count(className string, schema, query types.M) (int, e
 	return count, nil
 }
 
+// EstimatedCount 从 pg_class 中读取表的估算行数，表未被统计过时使用 Count
+func (p *PostgresAdapter) EstimatedCount(className string) (int, error) {
+	_, q, tx, err := p.readSession(nil)
+	if err != nil {
+		return 0, err
+	}
+	if tx != nil {
+		defer tx.Rollback()
+	}
+	qs := `SELECT reltuples FROM pg_class WHERE relname = $1`
+	rows, err := q.Query(qs, className)
+	if err != nil {
+		return 0, err
+	}
+	var count float64
+	found := false
+	if rows.Next() {
+		found = true
+		err = rows.Scan(&count)
+	}
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+	if found == false {
+		return 0, nil
+	}
+	// 从未执行过 ANALYZE 的表 reltuples 为 -1 或者 0
+	if count <= 0 {
+		return p.Count(className, types.M{}, types.M{})
+	}
+	return int(count), nil
+}
+
 // UpdateObjectsByQuery ...
 func (p *PostgresAdapter) UpdateObjectsByQuery(className string, schema, query, update types.M) error {
 	_, err := p.FindOneAndUpdate(className, schema, query, update)
@@ -913,7 +1229,13 @@ func (p *PostgresAdapter) FindOneAndUpdate(className string, schema, query, upda
 		fields = types.M{}
 	}
 
-	originalUpdate := utils.CopyMapM(update)
+	update, err := storage.CheckNestedKeys(update)
+	if err != nil {
+		return nil, err
+	}
+	update = transformACL(update)
+	// Object 字段中子字段的更新使用 jsonb 路径，不再合并到字段的值中
+	update, pathUpdates := extractObjectPathUpdates(update, fields)
 	update = handleDotFields(update)
 
 	for fieldName, v := range update {
@@ -967,12 +1289,32 @@ func (p *PostgresAdapter) FindOneAndUpdate(className string, schema, query, upda
 		}
 
 		if fieldName == "updatedAt" {
+			date, err := toPostgresDate(fieldValue)
+			if err != nil {
+				return nil, err
+			}
 			updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = $%d`, fieldName, index))
-			values = append(values, fieldValue)
+			values = append(values, date)
+			index = index + 1
+			continue
+		}
+
+		if fieldName == "_rperm" || fieldName == "_wperm" {
+			updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = $%d::text[]`, fieldName, index))
+			values = append(values, toPostgresTextArray(utils.A(fieldValue)))
 			index = index + 1
 			continue
 		}
 
+		// Date 字段的值为时间字符串时，与 Date 对象一样转换为 UTC 时间
+		if s, ok := fieldValue.(string); ok && utils.S(utils.M(fields[fieldName])["type"]) == "Date" {
+			date, err := toPostgresDate(s)
+			if err != nil {
+				return nil, err
+			}
+			fieldValue = date
+		}
+
 		switch fieldValue.(type) {
 		case string, bool, float64, int:
 			updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = $%d`, fieldName, index))
@@ -1025,6 +1367,35 @@ func (p *PostgresAdapter) FindOneAndUpdate(className string, schema, query, upda
 				values = append(values, string(b))
 				index = index + 1
 				continue
+			case "UpdateMatching":
+				// 把 set 合并到数组中第一个符合 match 条件的元素上，没有符合条件的元素时保持原值
+				tp := utils.M(fields[fieldName])
+				if tp == nil || utils.S(tp["type"]) != "Array" {
+					return nil, errs.E(errs.IncorrectType, "UpdateMatching can only be applied to Array fields")
+				}
+				if expectedType, _ := parseTypeToPostgresType(tp); expectedType != "jsonb" {
+					return nil, errs.E(errs.OperationForbidden, "Postgres doesn't support UpdateMatching on "+expectedType+" yet")
+				}
+				match := utils.M(object["match"])
+				set := utils.M(object["set"])
+				if len(match) == 0 || len(set) == 0 {
+					return nil, errs.E(errs.InvalidJSON, "bad UpdateMatching value")
+				}
+				elemPatterns, elemValues, err := buildElemMatchClause("elem", match, index)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, elemValues...)
+				index = index + len(elemValues)
+				firstMatch := fmt.Sprintf(`SELECT min(elemPos) FROM jsonb_array_elements("%s") WITH ORDINALITY AS m(elem, elemPos) WHERE %s`, fieldName, strings.Join(elemPatterns, " AND "))
+				updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = COALESCE((SELECT jsonb_agg(CASE WHEN pos = (%s) THEN value || $%d::jsonb ELSE value END ORDER BY pos) FROM jsonb_array_elements("%s") WITH ORDINALITY AS t(value, pos)), "%s")`, fieldName, firstMatch, index, fieldName, fieldName))
+				b, err := json.Marshal(set)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, string(b))
+				index = index + 1
+				continue
 			}
 
 			switch utils.S(object["__type"]) {
@@ -1043,65 +1414,51 @@ func (p *PostgresAdapter) FindOneAndUpdate(className string, schema, query, upda
 				values = append(values, object["longitude"], object["latitude"])
 				index = index + 2
 				continue
+			case "Polygon":
+				polygon, err := polygonToPostgres(object)
+				if err != nil {
+					return nil, err
+				}
+				updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = $%d::polygon`, fieldName, index))
+				values = append(values, polygon)
+				index = index + 1
+				continue
 			case "Relation":
 				continue
 			}
 
-			if tp := utils.M(fields[fieldName]); tp != nil && utils.S(tp["type"]) == "Object" {
-				keysToIncrement := []string{}
-				for k, v := range originalUpdate {
-					if o := utils.M(v); o != nil && utils.S(o["__op"]) == "Increment" {
-						if keys := strings.Split(k, "."); len(keys) == 2 && keys[0] == fieldName {
-							keysToIncrement = append(keysToIncrement, keys[1])
-						}
-					}
-				}
-
-				incrementPatterns := ""
-				if len(keysToIncrement) > 0 {
-					for _, key := range keysToIncrement {
-						increment := utils.M(object[key])
-						if increment == nil {
-							continue
-						}
-
-						var amount interface{}
-						switch increment["amount"].(type) {
-						case float64, int:
-							amount = increment["amount"]
-						}
-						if amount == nil {
-							continue
-						}
-						incrementPatterns += " || " + fmt.Sprintf(`CONCAT('{"%s":', COALESCE("%s"->>'%s', '0')::float + %v, '}')::jsonb`, key, fieldName, key, amount)
-					}
-
-					for _, key := range keysToIncrement {
-						delete(object, key)
-					}
-				}
-
-				keysToDelete := []string{}
-				for k, v := range originalUpdate {
-					if o := utils.M(v); o != nil && utils.S(o["__op"]) == "Delete" {
-						if keys := strings.Split(k, "."); len(keys) == 2 && keys[0] == fieldName {
-							keysToDelete = append(keysToDelete, keys[1])
-						}
-					}
-				}
-
-				deletePatterns := ""
-				for _, k := range keysToDelete {
-					deletePatterns = deletePatterns + fmt.Sprintf(` - '%s'`, k)
+			// LocalizedString 字段整体替换，修改单个语言时使用 title.en 形式的字段名
+			if tp := utils.M(fields[fieldName]); tp != nil && utils.S(tp["type"]) == "LocalizedString" {
+				b, err := json.Marshal(object)
+				if err != nil {
+					return nil, err
 				}
+				updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = $%d::jsonb`, fieldName, index))
+				values = append(values, string(b))
+				index = index + 1
+				continue
+			}
 
-				updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = ( COALESCE("%s", '{}'::jsonb) %s %s || $%d::jsonb )`, fieldName, fieldName, deletePatterns, incrementPatterns, index))
+			if tp := utils.M(fields[fieldName]); tp != nil && utils.S(tp["type"]) == "Object" {
+				expr := fmt.Sprintf(`( COALESCE("%s", '{}'::jsonb) || $%d::jsonb )`, fieldName, index)
 				b, err := json.Marshal(object)
 				if err != nil {
 					return nil, err
 				}
 				values = append(values, string(b))
 				index = index + 1
+				// 同时更新了子字段时，在合并之后的值上继续更新
+				if updates, ok := pathUpdates[fieldName]; ok {
+					delete(pathUpdates, fieldName)
+					var pathValues types.S
+					expr, pathValues, err = buildObjectPathUpdate(expr, updates, index)
+					if err != nil {
+						return nil, err
+					}
+					values = append(values, pathValues...)
+					index = index + len(pathValues)
+				}
+				updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = %s`, fieldName, expr))
 				continue
 			}
 		}
@@ -1142,15 +1499,41 @@ func (p *PostgresAdapter) FindOneAndUpdate(className string, schema, query, upda
 		return nil, errs.E(errs.OperationForbidden, "Postgres doesn't support update "+string(b)+" yet")
 	}
 
+	pathFields := []string{}
+	for fieldName := range pathUpdates {
+		pathFields = append(pathFields, fieldName)
+	}
+	sort.Strings(pathFields)
+	for _, fieldName := range pathFields {
+		expr, pathValues, err := buildObjectPathUpdate(fmt.Sprintf(`COALESCE("%s", '{}'::jsonb)`, fieldName), pathUpdates[fieldName], index)
+		if err != nil {
+			return nil, err
+		}
+		updatePatterns = append(updatePatterns, fmt.Sprintf(`"%s" = %s`, fieldName, expr))
+		values = append(values, pathValues...)
+		index = index + len(pathValues)
+	}
+
+	query, q, tx, err := p.aclSession(query)
+	if err != nil {
+		return nil, err
+	}
+	if tx != nil {
+		defer tx.Rollback()
+	}
+
 	where, err := buildWhereClause(schema, query, index)
 	if err != nil {
 		return nil, err
 	}
 	values = append(values, where.values...)
+	if where.pattern == "" {
+		where.pattern = "TRUE"
+	}
 
 	// TODO 需要添加限制，只更新一条，UpdateObjectsByQuery 时更新多条
 	qs := fmt.Sprintf(`UPDATE "%s" SET %s WHERE %s RETURNING *`, className, strings.Join(updatePatterns, ","), where.pattern)
-	rows, err := p.db.Query(qs, values...)
+	rows, err := q.Query(qs, values...)
 	if err != nil {
 		if e, ok := err.(*pq.Error); ok {
 			// 表不存在返回空
@@ -1190,6 +1573,14 @@ func (p *PostgresAdapter) FindOneAndUpdate(className string, schema, query, upda
 		}
 	}
 
+	if tx != nil {
+		// 提交之前需要先关闭 rows
+		rows.Close()
+		err = tx.Commit()
+		if err != nil {
+			return nil, err
+		}
+	}
 	return object, nil
 }
 
@@ -1216,6 +1607,36 @@ func (p *PostgresAdapter) UpsertOneObject(className string, schema, query, updat
 	return nil
 }
 
+// FindOneAndUpsert 更新符合条件的一个对象，不存在时插入 insert ，返回更新或者插入后的对象，以及是否插入了新对象
+// 更新与插入不在同一个语句中，并发插入时依赖唯一索引保证只插入一个对象，插入违反唯一索引时重新尝试更新
+func (p *PostgresAdapter) FindOneAndUpsert(className string, schema, query, update, insert types.M) (types.M, bool, error) {
+	for retried := false; ; retried = true {
+		object, err := p.FindOneAndUpdate(className, schema, query, update)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(object) > 0 {
+			return object, false, nil
+		}
+
+		err = p.CreateObject(className, schema, insert)
+		if err == nil {
+			// 从主库读取插入的对象，只读副本可能还没有同步
+			objects, err := p.find(className, schema, types.M{"objectId": insert["objectId"]}, types.M{"limit": 1}, p.aclSession)
+			if err != nil {
+				return nil, false, err
+			}
+			if len(objects) == 0 {
+				return nil, false, errs.E(errs.ObjectNotFound, "Object not found.")
+			}
+			return objects[0], true, nil
+		}
+		if retried || errs.GetErrorCode(err) != errs.DuplicateValue {
+			return nil, false, err
+		}
+	}
+}
+
 // EnsureUniqueness 创建索引
 func (p *PostgresAdapter) EnsureUniqueness(className string, schema types.M, fieldNames []string) error {
 	sort.Sort(sort.StringSlice(fieldNames))
@@ -1241,6 +1662,131 @@ func (p *PostgresAdapter) EnsureUniqueness(className string, schema types.M, fie
 	return nil
 }
 
+// EnsureJoinIndexes 创建 _Join 表的 owningId relatedId 联合索引，主键 relatedId owningId 用于按 relatedId 查询
+func (p *PostgresAdapter) EnsureJoinIndexes(className, fieldName string) error {
+	name := fmt.Sprintf(`_Join:%s:%s`, fieldName, className)
+	fieldNames := []string{"owningId", "relatedId"}
+	qs := fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%s" ON "%s" (%s)`, compoundIndexName(name, fieldNames), name, strings.Join(postgresIndexColumns(fieldNames), ", "))
+	_, err := p.db.Exec(qs)
+	return err
+}
+
+// NextSequenceValue 返回类中自增字段的下一个值，序列不存在时创建
+func (p *PostgresAdapter) NextSequenceValue(className, fieldName string) (int, error) {
+	name := `"` + sequenceName(className, fieldName) + `"`
+	var value int
+	err := p.db.QueryRow(`SELECT nextval($1::regclass)`, name).Scan(&value)
+	if err == nil {
+		return value, nil
+	}
+	if e, ok := err.(*pq.Error); ok == false || e.Code != postgresRelationDoesNotExistError {
+		return 0, err
+	}
+	_, err = p.db.Exec(fmt.Sprintf(`CREATE SEQUENCE IF NOT EXISTS %s`, name))
+	if err != nil {
+		return 0, err
+	}
+	err = p.db.QueryRow(`SELECT nextval($1::regclass)`, name).Scan(&value)
+	if err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// EnsureGeoIndex 为 GeoPoint 与 Polygon 字段创建 GiST 索引
+func (p *PostgresAdapter) EnsureGeoIndex(className, fieldName string) error {
+	qs := fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%s" ON "%s" USING GIST ("%s")`, geoIndexName(className, fieldName), className, fieldName)
+	_, err := p.db.Exec(qs)
+	return err
+}
+
+// UpdateFieldType 修改 _SCHEMA 中已有字段的定义，列的类型不变，用于增加 Enum 字段的取值
+func (p *PostgresAdapter) UpdateFieldType(className, fieldName string, fieldType types.M) error {
+	defer p.classCache.Bump()
+	b, err := json.Marshal(fieldType)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf(`{fields,%s}`, fieldName)
+	qs := `UPDATE "_SCHEMA" SET "schema" = jsonb_set("schema", $1::text[], $2::jsonb) WHERE "className" = $3 AND ("schema"->'fields'->$4) IS NOT NULL`
+	_, err = p.db.Exec(qs, path, string(b), className, fieldName)
+	return err
+}
+
+// DropGeoIndex 删除 GeoPoint 与 Polygon 字段上的 GiST 索引
+func (p *PostgresAdapter) DropGeoIndex(className, fieldName string) error {
+	qs := fmt.Sprintf(`DROP INDEX IF EXISTS "%s"`, geoIndexName(className, fieldName))
+	_, err := p.db.Exec(qs)
+	return err
+}
+
+// EnsureCaseInsensitiveIndex 为 String 字段创建 lower() 表达式索引，用于不区分大小写的相等查询
+func (p *PostgresAdapter) EnsureCaseInsensitiveIndex(className, fieldName string) error {
+	qs := fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%s" ON "%s" (lower("%s"))`, caseInsensitiveIndexName(className, fieldName), className, fieldName)
+	_, err := p.db.Exec(qs)
+	return err
+}
+
+// EnsureIndex 创建由 fieldNames 组成的 B-Tree 索引，使用 CONCURRENTLY 避免创建过程中阻塞写入
+func (p *PostgresAdapter) EnsureIndex(className string, schema types.M, fieldNames []string) error {
+	if len(fieldNames) == 0 {
+		return nil
+	}
+	qs := fmt.Sprintf(`CREATE INDEX CONCURRENTLY IF NOT EXISTS "%s" ON "%s" (%s)`, compoundIndexName(className, fieldNames), className, strings.Join(postgresIndexColumns(fieldNames), ", "))
+	_, err := p.db.Exec(qs)
+	return err
+}
+
+// GetIndexes 返回表的所有索引，表达式索引（如不区分大小写的索引）不会返回
+func (p *PostgresAdapter) GetIndexes(className string, schema types.M) (types.M, error) {
+	qs := `SELECT i.relname, a.attname, (ix.indoption[u.k - 1] & 1) = 1
+		FROM pg_index ix
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		CROSS JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS u(attnum, k)
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = u.attnum
+		WHERE t.relname = $1 AND NOT EXISTS (SELECT 1 FROM unnest(ix.indkey) AS e(attnum) WHERE e.attnum = 0)
+		ORDER BY i.relname, u.k`
+	rows, err := p.db.Query(qs, className)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := types.M{}
+	for rows.Next() {
+		var name, column string
+		var desc bool
+		err := rows.Scan(&name, &column, &desc)
+		if err != nil {
+			return nil, err
+		}
+		index := utils.M(result[name])
+		if index == nil {
+			index = types.M{}
+			result[name] = index
+		}
+		if desc {
+			index[column] = -1
+		} else {
+			index[column] = 1
+		}
+	}
+	return result, rows.Err()
+}
+
+// CreateIndex 创建指定名称的 B-Tree 索引
+func (p *PostgresAdapter) CreateIndex(className string, schema types.M, name string, fieldNames []string) error {
+	qs := fmt.Sprintf(`CREATE INDEX "%s" ON "%s" (%s)`, name, className, strings.Join(postgresIndexColumns(fieldNames), ", "))
+	_, err := p.db.Exec(qs)
+	return err
+}
+
+// DropIndex 删除指定名称的索引
+func (p *PostgresAdapter) DropIndex(className, name string) error {
+	_, err := p.db.Exec(fmt.Sprintf(`DROP INDEX IF EXISTS "%s"`, name))
+	return err
+}
+
 // PerformInitialization ...
 func (p *PostgresAdapter) PerformInitialization(options types.M) error {
 	if options == nil {
@@ -1301,11 +1847,26 @@ func (p *PostgresAdapter) PerformInitialization(options types.M) error {
 		return err
 	}
 
-	return tx.Commit()
+	_, err = tx.Exec(arrayContainedBy)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	if p.rowLevelSecurity {
+		return p.enableRowLevelSecurityForAll()
+	}
+	return nil
 }
 
 // HandleShutdown 关闭数据库
 func (p *PostgresAdapter) HandleShutdown() {
+	p.closeReplicas()
+	p.stmts.Clear()
 	p.db.Close()
 }
 
@@ -1371,6 +1932,19 @@ func postgresObjectToParseObject(object, fields types.M) (types.M, error) {
 				"longitude": longitude,
 				"latitude":  latitude,
 			}
+		} else if objectType == "Polygon" && object[fieldName] != nil {
+			// object[fieldName] = ((10,20),(30,40),(10,20)) (longitude, latitude)
+			resString := ""
+			if v, ok := object[fieldName].([]byte); ok {
+				resString = string(v)
+			} else if v, ok := object[fieldName].(string); ok {
+				resString = v
+			}
+			polygon, err := postgresPolygonToParsePolygon(resString)
+			if err != nil {
+				return nil, err
+			}
+			object[fieldName] = polygon
 		} else if objectType == "File" && object[fieldName] != nil {
 			if v, ok := object[fieldName].([]byte); ok {
 				object[fieldName] = types.M{
@@ -1385,7 +1959,7 @@ func postgresObjectToParseObject(object, fields types.M) (types.M, error) {
 			} else {
 				object[fieldName] = nil
 			}
-		} else if objectType == "String" && object[fieldName] != nil {
+		} else if (objectType == "String" || objectType == "Enum") && object[fieldName] != nil {
 			if v, ok := object[fieldName].([]byte); ok {
 				object[fieldName] = string(v)
 			} else if v, ok := object[fieldName].(string); ok {
@@ -1393,7 +1967,7 @@ func postgresObjectToParseObject(object, fields types.M) (types.M, error) {
 			} else {
 				object[fieldName] = nil
 			}
-		} else if objectType == "Object" && object[fieldName] != nil {
+		} else if (objectType == "Object" || objectType == "LocalizedString") && object[fieldName] != nil {
 			if v, ok := object[fieldName].([]byte); ok {
 				var r types.M
 				err := json.Unmarshal(v, &r)
@@ -1435,46 +2009,18 @@ func postgresObjectToParseObject(object, fields types.M) (types.M, error) {
 		}
 	}
 
-	if object["_rperm"] != nil {
-		// object["_rperm"] = {hello,world}
-		// 在添加 _rperm 时已保证值里不含 ','
-		resString := ""
-		if v, ok := object["_rperm"].([]byte); ok {
-			resString = string(v)
-		} else if v, ok := object["_rperm"].(string); ok {
-			resString = v
-		}
-		if len(resString) < 2 {
-			object["_rperm"] = nil
-		} else {
-			keys := strings.Split(resString[1:len(resString)-1], ",")
-			rperm := make(types.S, len(keys))
-			for i, k := range keys {
-				rperm[i] = k
-			}
-			object["_rperm"] = rperm
+	// object["_rperm"] = {hello,"role:a b"}
+	for _, fieldName := range []string{"_rperm", "_wperm"} {
+		if object[fieldName] == nil {
+			continue
 		}
-	}
-
-	if object["_wperm"] != nil {
-		// object["_wperm"] = {hello,world}
-		// 在添加 _wperm 时已保证值里不含 ','
 		resString := ""
-		if v, ok := object["_wperm"].([]byte); ok {
+		if v, ok := object[fieldName].([]byte); ok {
 			resString = string(v)
-		} else if v, ok := object["_wperm"].(string); ok {
+		} else if v, ok := object[fieldName].(string); ok {
 			resString = v
 		}
-		if len(resString) < 2 {
-			object["_wperm"] = nil
-		} else {
-			keys := strings.Split(resString[1:len(resString)-1], ",")
-			wperm := make(types.S, len(keys))
-			for i, k := range keys {
-				wperm[i] = k
-			}
-			object["_wperm"] = wperm
-		}
+		object[fieldName] = parsePostgresTextArray(resString)
 	}
 
 	if object["createdAt"] != nil {
@@ -1518,10 +2064,7 @@ func postgresObjectToParseObject(object, fields types.M) (types.M, error) {
 			delete(object, fieldName)
 		}
 		if v, ok := object[fieldName].(time.Time); ok {
-			object[fieldName] = types.M{
-				"__type": "Date",
-				"iso":    utils.TimetoString(v),
-			}
+			object[fieldName] = utils.DateJSON(v)
 		}
 	}
 	return object, nil
@@ -1540,11 +2083,11 @@ func parseTypeToPostgresType(t types.M) (string, error) {
 	}
 	tp := utils.S(t["type"])
 	switch tp {
-	case "String":
+	case "String", "Enum":
 		return "text", nil
 	case "Date":
 		return "timestamp with time zone", nil
-	case "Object":
+	case "Object", "LocalizedString":
 		return "jsonb", nil
 	case "File":
 		return "text", nil
@@ -1552,10 +2095,12 @@ func parseTypeToPostgresType(t types.M) (string, error) {
 		return "boolean", nil
 	case "Pointer":
 		return "char(24)", nil
-	case "Number":
+	case "Number", "AutoIncrement":
 		return "double precision", nil
 	case "GeoPoint":
 		return "point", nil
+	case "Polygon":
+		return "polygon", nil
 	case "Array":
 		if contents := utils.M(t["contents"]); contents != nil {
 			if utils.S(contents["type"]) == "String" {
@@ -1568,9 +2113,160 @@ func parseTypeToPostgresType(t types.M) (string, error) {
 	}
 }
 
+// transformACL 把对象中的 ACL 转换为 _rperm 与 _wperm ，与 MongoDB 中保存的权限格式一致
+// {"ACL":{"*":{"read":true},"userid":{"read":true,"write":true}}}
+// ==>
+// {"_rperm":["*","userid"],"_wperm":["userid"]}
+// ACL 不是对象时删除 ACL 字段，不修改权限，不包含 ACL 时返回原对象
+func transformACL(object types.M) types.M {
+	if _, ok := object["ACL"]; ok == false {
+		return object
+	}
+	result := utils.CopyMap(object)
+	acl := utils.M(result["ACL"])
+	delete(result, "ACL")
+	if acl == nil {
+		return result
+	}
+	entries := []string{}
+	for entry := range acl {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	rperm := types.S{}
+	wperm := types.S{}
+	for _, entry := range entries {
+		perm := utils.M(acl[entry])
+		if perm == nil {
+			continue
+		}
+		if perm["read"] != nil {
+			rperm = append(rperm, entry)
+		}
+		if perm["write"] != nil {
+			wperm = append(wperm, entry)
+		}
+	}
+	result["_rperm"] = rperm
+	result["_wperm"] = wperm
+	return result
+}
+
+// toPostgresTextArray 把字符串数组转换为 postgres 中 text[] 的格式，每个元素都使用双引号包围
+// ["*","role:a b"] ==> {"*","role:a b"}
+func toPostgresTextArray(values types.S) string {
+	elements := []string{}
+	for _, v := range values {
+		e := strings.Replace(utils.S(v), `\`, `\\`, -1)
+		e = strings.Replace(e, `"`, `\"`, -1)
+		elements = append(elements, `"`+e+`"`)
+	}
+	return "{" + strings.Join(elements, ",") + "}"
+}
+
+// parsePostgresTextArray 解析 postgres 返回的 text[] ，包含特殊字符的元素由双引号包围并转义
+// {hello,"role:a b"} ==> ["hello","role:a b"]
+func parsePostgresTextArray(s string) types.S {
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil
+	}
+	s = s[1 : len(s)-1]
+	result := types.S{}
+	if s == "" {
+		return result
+	}
+	var element []byte
+	quoted := false
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inQuotes && c == '\\' && i+1 < len(s):
+			i++
+			element = append(element, s[i])
+		case c == '"':
+			inQuotes = !inQuotes
+			quoted = true
+		case inQuotes == false && c == ',':
+			result = append(result, postgresTextArrayElement(element, quoted))
+			element = nil
+			quoted = false
+		default:
+			element = append(element, c)
+		}
+	}
+	result = append(result, postgresTextArrayElement(element, quoted))
+	return result
+}
+
+// postgresTextArrayElement 没有引号的 NULL 表示空元素
+func postgresTextArrayElement(element []byte, quoted bool) interface{} {
+	if quoted == false && string(element) == "NULL" {
+		return nil
+	}
+	return string(element)
+}
+
+// polygonToPostgres 把 Polygon 类型的值转换为 postgres 中 polygon 的格式 ((经度, 纬度), ...)
+func polygonToPostgres(value types.M) (string, error) {
+	points, err := storage.ParsePolygon(value)
+	if err != nil {
+		return "", err
+	}
+	pointStrings := []string{}
+	for _, point := range points {
+		pointStrings = append(pointStrings, fmt.Sprintf("(%v, %v)", point[1], point[0]))
+	}
+	return "(" + strings.Join(pointStrings, ", ") + ")", nil
+}
+
+// postgresPolygonToParsePolygon 把 postgres 中的 polygon 转换为 Polygon 类型，坐标为 [纬度, 经度]
+func postgresPolygonToParsePolygon(s string) (types.M, error) {
+	// ((10,20),(30,40)) ==> ["10,20", "30,40"]
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "(("), "))")
+	coordinates := types.S{}
+	for _, pointString := range strings.Split(s, "),(") {
+		point := strings.Split(pointString, ",")
+		if len(point) != 2 {
+			return nil, errs.E(errs.InternalServerError, "invalid polygon value: "+s)
+		}
+		longitude, err := strconv.ParseFloat(strings.TrimSpace(point[0]), 64)
+		if err != nil {
+			return nil, err
+		}
+		latitude, err := strconv.ParseFloat(strings.TrimSpace(point[1]), 64)
+		if err != nil {
+			return nil, err
+		}
+		coordinates = append(coordinates, types.S{latitude, longitude})
+	}
+	return types.M{
+		"__type":      "Polygon",
+		"coordinates": coordinates,
+	}, nil
+}
+
+// toPostgresDate 把 Date 对象或者时间字符串转换为带毫秒的 UTC 时间字符串，空值转换为 NULL
+func toPostgresDate(value interface{}) (interface{}, error) {
+	if v := utils.M(value); v != nil {
+		value = v["iso"]
+	}
+	if value == nil || value == "" {
+		return nil, nil
+	}
+	t, err := utils.DateToTime(value)
+	if err != nil {
+		return nil, errs.E(errs.InvalidJSON, "Invalid Date value.")
+	}
+	return utils.TimetoString(t), nil
+}
+
 func toPostgresValue(value interface{}) interface{} {
 	if v := utils.M(value); v != nil {
 		if utils.S(v["__type"]) == "Date" {
+			if date, err := toPostgresDate(v); err == nil {
+				return date
+			}
 			return v["iso"]
 		}
 		if utils.S(v["__type"]) == "File" {
@@ -1676,6 +2372,108 @@ func toPostgresSchema(schema types.M) types.M {
 	return schema
 }
 
+// objectPathUpdate Object 字段中一个子字段的更新， path 为子字段的路径，不包含字段名
+type objectPathUpdate struct {
+	path  []string
+	value interface{}
+}
+
+// extractObjectPathUpdates 从 update 中取出 Object 与 LocalizedString 字段的子字段更新，如 {"profile.address.city":"x"}
+// 返回去掉子字段更新的 update ，以及按字段名分组、按路径排序的子字段更新
+func extractObjectPathUpdates(update, fields types.M) (types.M, map[string][]objectPathUpdate) {
+	pathUpdates := map[string][]objectPathUpdate{}
+	keys := []string{}
+	for key := range update {
+		components := strings.Split(key, ".")
+		if len(components) < 2 {
+			continue
+		}
+		if tp := utils.M(fields[components[0]]); tp == nil || (utils.S(tp["type"]) != "Object" && utils.S(tp["type"]) != "LocalizedString") {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return update, pathUpdates
+	}
+	sort.Strings(keys)
+
+	result := utils.CopyMap(update)
+	for _, key := range keys {
+		components := strings.Split(key, ".")
+		pathUpdates[components[0]] = append(pathUpdates[components[0]], objectPathUpdate{
+			path:  components[1:],
+			value: update[key],
+		})
+		delete(result, key)
+	}
+	return result, pathUpdates
+}
+
+// buildObjectPathUpdate 在 expr 上依次执行子字段的设置、删除与增加，返回更新后的 jsonb 表达式以及参数
+// 路径的中间层级不存在或者不是对象时，替换为空对象
+func buildObjectPathUpdate(expr string, updates []objectPathUpdate, index int) (string, types.S, error) {
+	values := types.S{}
+	pathParam := func(path []string) string {
+		values = append(values, toPostgresTextArray(stringsToS(path)))
+		return fmt.Sprintf(`$%d::text[]`, index+len(values)-1)
+	}
+	ensureParents := func(expr string, path []string) string {
+		for i := 1; i < len(path); i++ {
+			parent := pathParam(path[:i])
+			expr = fmt.Sprintf(`jsonb_set(%s, %s, CASE WHEN jsonb_typeof(%s #> %s) = 'object' THEN %s #> %s ELSE '{}'::jsonb END, true)`, expr, parent, expr, parent, expr, parent)
+		}
+		return expr
+	}
+
+	for _, u := range updates {
+		if u.value == nil {
+			expr = fmt.Sprintf(`(%s #- %s)`, expr, pathParam(u.path))
+			continue
+		}
+		op := utils.M(u.value)
+		if op != nil && op["__op"] != nil {
+			switch utils.S(op["__op"]) {
+			case "Delete":
+				expr = fmt.Sprintf(`(%s #- %s)`, expr, pathParam(u.path))
+				continue
+			case "Increment":
+				switch op["amount"].(type) {
+				case float64, int:
+				default:
+					return "", nil, errs.E(errs.InvalidJSON, "incrementing must provide a number")
+				}
+				expr = ensureParents(expr, u.path)
+				path := pathParam(u.path)
+				values = append(values, op["amount"])
+				expr = fmt.Sprintf(`jsonb_set(%s, %s, to_jsonb(COALESCE((%s #>> %s)::float, 0) + $%d), true)`, expr, path, expr, path, index+len(values)-1)
+				continue
+			default:
+				b, _ := json.Marshal(u.value)
+				return "", nil, errs.E(errs.OperationForbidden, "Postgres doesn't support update "+string(b)+" yet")
+			}
+		}
+		b, err := json.Marshal(u.value)
+		if err != nil {
+			return "", nil, err
+		}
+		expr = ensureParents(expr, u.path)
+		path := pathParam(u.path)
+		values = append(values, string(b))
+		expr = fmt.Sprintf(`jsonb_set(%s, %s, $%d::jsonb, true)`, expr, path, index+len(values)-1)
+	}
+	return expr, values, nil
+}
+
+// stringsToS 把字符串数组转换为 types.S
+func stringsToS(strs []string) types.S {
+	result := types.S{}
+	for _, s := range strs {
+		result = append(result, s)
+	}
+	return result
+}
+
 func handleDotFields(object types.M) types.M {
 	for fieldName := range object {
 		if strings.Index(fieldName, ".") == -1 {
@@ -1751,6 +2549,12 @@ type whereClause struct {
 }
 
 func buildWhereClause(schema, query types.M, index int) (*whereClause, error) {
+	return buildWhereClauseWithOptions(schema, query, index, false)
+}
+
+// buildWhereClauseWithOptions caseInsensitive 为 true 时， String 字段的相等条件不区分大小写
+// 条件转换为 lower("field") = lower($n) ，可以使用 EnsureCaseInsensitiveIndex 创建的索引
+func buildWhereClauseWithOptions(schema, query types.M, index int, caseInsensitive bool) (*whereClause, error) {
 	patterns := []string{}
 	values := types.S{}
 	sorts := []string{}
@@ -1765,13 +2569,18 @@ func buildWhereClause(schema, query types.M, index int) (*whereClause, error) {
 	}
 	for fieldName, fieldValue := range query {
 		isArrayField := false
+		isStringField := false
 		if fields != nil {
 			if tp := utils.M(fields[fieldName]); tp != nil {
 				if utils.S(tp["type"]) == "Array" {
 					isArrayField = true
 				}
+				if utils.S(tp["type"]) == "String" {
+					isStringField = true
+				}
 			}
 		}
+		ignoreCase := caseInsensitive && isStringField
 		initialPatternsLength := len(patterns)
 
 		if fields[fieldName] == nil {
@@ -1782,6 +2591,18 @@ func buildWhereClause(schema, query types.M, index int) (*whereClause, error) {
 			}
 		}
 
+		// LocalizedString 字段中单个语言的查询条件，如 title.en
+		if i := strings.Index(fieldName, "."); i > 0 && utils.S(utils.M(fields[fieldName[:i]])["type"]) == "LocalizedString" {
+			localePatterns, localeValues, err := buildLocalizedStringClause(fieldName[:i], fieldName[i+1:], fieldValue, index)
+			if err != nil {
+				return nil, err
+			}
+			patterns = append(patterns, localePatterns...)
+			values = append(values, localeValues...)
+			index = index + len(localeValues)
+			continue
+		}
+
 		if strings.Contains(fieldName, ".") {
 			components := strings.Split(fieldName, ".")
 			for index, cmpt := range components {
@@ -1798,7 +2619,11 @@ func buildWhereClause(schema, query types.M, index int) (*whereClause, error) {
 			}
 			patterns = append(patterns, fmt.Sprintf(`%s = '%v'`, name, string(b)))
 		} else if _, ok := fieldValue.(string); ok {
-			patterns = append(patterns, fmt.Sprintf(`"%s" = $%d`, fieldName, index))
+			if ignoreCase {
+				patterns = append(patterns, fmt.Sprintf(`lower("%s") = lower($%d)`, fieldName, index))
+			} else {
+				patterns = append(patterns, fmt.Sprintf(`"%s" = $%d`, fieldName, index))
+			}
 			values = append(values, fieldValue)
 			index = index + 1
 		} else if _, ok := fieldValue.(bool); ok {
@@ -1819,7 +2644,7 @@ func buildWhereClause(schema, query types.M, index int) (*whereClause, error) {
 			if array := utils.A(fieldValue); array != nil {
 				for _, v := range array {
 					if subQuery := utils.M(v); subQuery != nil {
-						clause, err := buildWhereClause(schema, subQuery, index)
+						clause, err := buildWhereClauseWithOptions(schema, subQuery, index, caseInsensitive)
 						if err != nil {
 							return nil, err
 						}
@@ -1864,6 +2689,10 @@ func buildWhereClause(schema, query types.M, index int) (*whereClause, error) {
 			if v, ok := value["$eq"]; ok {
 				if v == nil {
 					patterns = append(patterns, fmt.Sprintf(`"%s" IS NULL`, fieldName))
+				} else if _, ok := v.(string); ok && ignoreCase {
+					patterns = append(patterns, fmt.Sprintf(`lower("%s") = lower($%d)`, fieldName, index))
+					values = append(values, v)
+					index = index + 1
 				} else {
 					patterns = append(patterns, fmt.Sprintf(`"%s" = $%d`, fieldName, index))
 					values = append(values, v)
@@ -1948,6 +2777,52 @@ func buildWhereClause(schema, query types.M, index int) (*whereClause, error) {
 				index = index + 1
 			}
 
+			if v, ok := value["$containedBy"]; ok {
+				list := utils.A(v)
+				if list == nil {
+					return nil, errs.E(errs.InvalidJSON, "bad $containedBy: should be an array")
+				}
+				if isArrayField == false {
+					return nil, errs.E(errs.InvalidJSON, "bad $containedBy: can only be used on Array fields")
+				}
+				j, _ := json.Marshal(list)
+				if isTypeString {
+					patterns = append(patterns, fmt.Sprintf(`("%s" IS NULL OR "%s" <@ ARRAY(SELECT jsonb_array_elements_text($%d::jsonb)))`, fieldName, fieldName, index))
+				} else {
+					patterns = append(patterns, fmt.Sprintf(`("%s" IS NULL OR array_contained_by("%s", $%d::jsonb))`, fieldName, fieldName, index))
+				}
+				values = append(values, string(j))
+				index = index + 1
+			}
+
+			if v, ok := value["$size"]; ok && isArrayField {
+				size, ok := arraySize(v)
+				if ok == false {
+					return nil, errs.E(errs.InvalidJSON, "bad $size value")
+				}
+				if isTypeString {
+					patterns = append(patterns, fmt.Sprintf(`COALESCE(array_length("%s", 1), 0) = $%d`, fieldName, index))
+				} else {
+					patterns = append(patterns, fmt.Sprintf(`COALESCE(jsonb_array_length("%s"), 0) = $%d`, fieldName, index))
+				}
+				values = append(values, size)
+				index = index + 1
+			}
+
+			if v, ok := value["$elemMatch"]; ok && isArrayField && isTypeString == false {
+				match := utils.M(v)
+				if match == nil {
+					return nil, errs.E(errs.InvalidJSON, "bad $elemMatch value")
+				}
+				elemPatterns, elemValues, err := buildElemMatchClause("elem", match, index)
+				if err != nil {
+					return nil, err
+				}
+				patterns = append(patterns, fmt.Sprintf(`EXISTS (SELECT 1 FROM jsonb_array_elements("%s") AS elem WHERE %s)`, fieldName, strings.Join(elemPatterns, " AND ")))
+				values = append(values, elemValues...)
+				index = index + len(elemValues)
+			}
+
 			if b, ok := value["$exists"].(bool); ok {
 				if b {
 					patterns = append(patterns, fmt.Sprintf(`"%s" IS NOT NULL`, fieldName))
@@ -1986,7 +2861,20 @@ func buildWhereClause(schema, query types.M, index int) (*whereClause, error) {
 			}
 
 			if geoWithin := utils.M(value["$geoWithin"]); geoWithin != nil {
-				if polygon := utils.A(geoWithin["$polygon"]); polygon != nil {
+				if v, ok := geoWithin["$centerSphere"]; ok {
+					if utils.S(utils.M(fields[fieldName])["type"]) != "GeoPoint" {
+						return nil, errs.E(errs.InvalidJSON, "bad $geoWithin value; $centerSphere can only be used on GeoPoint fields")
+					}
+					longitude, latitude, distance, err := parseCenterSphere(v)
+					if err != nil {
+						return nil, err
+					}
+					// 弧度转换为米
+					distanceInMeters := distance * 6371 * 1000
+					patterns = append(patterns, fmt.Sprintf(`ST_distance_sphere("%s"::geometry, POINT($%d, $%d)::geometry) <= $%d`, fieldName, index, index+1, index+2))
+					values = append(values, longitude, latitude, distanceInMeters)
+					index = index + 3
+				} else if polygon := utils.A(geoWithin["$polygon"]); polygon != nil {
 					points := []string{}
 					for _, p := range polygon {
 						if point := utils.M(p); point != nil && utils.S(point["__type"]) == "GeoPoint" {
@@ -2006,6 +2894,19 @@ func buildWhereClause(schema, query types.M, index int) (*whereClause, error) {
 				}
 			}
 
+			if geoIntersects := utils.M(value["$geoIntersects"]); geoIntersects != nil {
+				if utils.S(utils.M(fields[fieldName])["type"]) != "Polygon" {
+					return nil, errs.E(errs.InvalidJSON, "bad $geoIntersects value; $geoIntersects can only be used on Polygon fields")
+				}
+				point := utils.M(geoIntersects["$point"])
+				if point == nil || utils.S(point["__type"]) != "GeoPoint" {
+					return nil, errs.E(errs.InvalidJSON, "bad $geoIntersects value; $point should be GeoPoint")
+				}
+				patterns = append(patterns, fmt.Sprintf(`"%s"::polygon @> $%d::point`, fieldName, index))
+				values = append(values, fmt.Sprintf("(%v, %v)", point["longitude"], point["latitude"]))
+				index = index + 1
+			}
+
 			if regex := utils.S(value["$regex"]); regex != "" {
 				operator := "~"
 				opts := utils.S(value["$options"])
@@ -2038,7 +2939,7 @@ func buildWhereClause(schema, query types.M, index int) (*whereClause, error) {
 
 			if utils.S(value["__type"]) == "Date" {
 				patterns = append(patterns, fmt.Sprintf(`"%s" = $%d`, fieldName, index))
-				values = append(values, value["iso"])
+				values = append(values, toPostgresValue(value))
 				index = index + 1
 			}
 
@@ -2066,6 +2967,281 @@ func buildWhereClause(schema, query types.M, index int) (*whereClause, error) {
 	return &whereClause{strings.Join(patterns, " AND "), values, sorts}, nil
 }
 
+// geoIndexName 地理位置索引的名称
+func geoIndexName(className, fieldName string) string {
+	return className + "_" + fieldName + "_gist"
+}
+
+// transformSortKey 转换排序字段， a.b.c 转换为 "a"->'b'->'c' ，按 jsonb 的规则比较子字段
+func transformSortKey(key string) string {
+	components := strings.Split(key, ".")
+	for i, cmpt := range components {
+		if i == 0 {
+			components[i] = `"` + cmpt + `"`
+		} else {
+			components[i] = `'` + cmpt + `'`
+		}
+	}
+	return strings.Join(components, "->")
+}
+
+// postgresIndexColumns 转换索引的字段，倒序的字段带有前缀 "-"
+func postgresIndexColumns(fieldNames []string) []string {
+	columns := []string{}
+	for _, fieldName := range fieldNames {
+		if strings.HasPrefix(fieldName, "-") {
+			columns = append(columns, fmt.Sprintf(`"%s" DESC`, fieldName[1:]))
+		} else {
+			columns = append(columns, fmt.Sprintf(`"%s"`, fieldName))
+		}
+	}
+	return columns
+}
+
+// compoundIndexName 普通索引的名称，倒序的字段以 _desc 结尾
+// 超过 63 个字符时 PostgreSQL 会截断名称，此时使用字段名的 md5 代替
+func compoundIndexName(className string, fieldNames []string) string {
+	parts := []string{}
+	for _, fieldName := range fieldNames {
+		if strings.HasPrefix(fieldName, "-") {
+			parts = append(parts, fieldName[1:]+"_desc")
+		} else {
+			parts = append(parts, fieldName)
+		}
+	}
+	name := className + "_" + strings.Join(parts, "_") + "_idx"
+	if len(name) > 63 {
+		name = className + "_" + utils.MD5Hash(strings.Join(fieldNames, ","))[:16] + "_idx"
+	}
+	return name
+}
+
+// caseInsensitiveIndexName 不区分大小写索引的名称
+func caseInsensitiveIndexName(className, fieldName string) string {
+	return className + "_" + fieldName + "_lower"
+}
+
+// sequenceName AutoIncrement 字段对应的序列名称，超过 63 个字符时使用字段名的 hash
+func sequenceName(className, fieldName string) string {
+	name := className + "_" + fieldName + "_seq"
+	if len(name) > 63 {
+		name = className + "_" + utils.MD5Hash(fieldName)[:16] + "_seq"
+	}
+	return name
+}
+
+// parseCenterSphere 解析 $centerSphere 参数 [中心点, 弧度]，中心点可以是 GeoPoint 对象或者 [经度, 纬度]
+func parseCenterSphere(v interface{}) (longitude, latitude, distance float64, err error) {
+	invalid := func(msg string) (float64, float64, float64, error) {
+		return 0, 0, 0, errs.E(errs.InvalidJSON, "bad $geoWithin value; $centerSphere "+msg)
+	}
+	centerSphere := utils.A(v)
+	if centerSphere == nil || len(centerSphere) != 2 {
+		return invalid("should be an array of [point, distance]")
+	}
+
+	var lng, lat interface{}
+	if point := utils.M(centerSphere[0]); point != nil && utils.S(point["__type"]) == "GeoPoint" {
+		lng, lat = point["longitude"], point["latitude"]
+	} else if point := utils.A(centerSphere[0]); len(point) == 2 {
+		lng, lat = point[0], point[1]
+	} else {
+		return invalid("geo point invalid")
+	}
+	var ok1, ok2, ok3 bool
+	longitude, ok1 = toFloat(lng)
+	latitude, ok2 = toFloat(lat)
+	if ok1 == false || ok2 == false || longitude < -180 || longitude > 180 || latitude < -90 || latitude > 90 {
+		return invalid("geo point invalid")
+	}
+	distance, ok3 = toFloat(centerSphere[1])
+	if ok3 == false || distance < 0 {
+		return invalid("distance invalid")
+	}
+	return longitude, latitude, distance, nil
+}
+
+// toFloat 转换数字类型
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// arraySize 解析 $size 的参数，必须为非负整数
+func arraySize(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, n >= 0
+	case float64:
+		if n >= 0 && n == float64(int(n)) {
+			return int(n), true
+		}
+	}
+	return 0, false
+}
+
+// buildElemMatchClause 组装 $elemMatch 中对数组元素的查询条件， expr 为 jsonb 类型的数组元素
+// 条件中的 key 均以 $ 开头时，表示对元素本身的限制，如 {"$gte":80,"$lt":85}
+// 否则表示对元素中字段的限制，如 {"product":"xyz","score":{"$gte":8}}
+func buildElemMatchClause(expr string, match types.M, index int) ([]string, types.S, error) {
+	patterns := []string{}
+	values := types.S{}
+	if len(match) == 0 {
+		return nil, nil, errs.E(errs.InvalidJSON, "bad $elemMatch value")
+	}
+
+	isConstraint := false
+	for key := range match {
+		if strings.HasPrefix(key, "$") {
+			isConstraint = true
+			break
+		}
+	}
+	if isConstraint {
+		return buildElemConstraint(expr, match, index)
+	}
+
+	keys := []string{}
+	for key := range match {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		keyExpr := fmt.Sprintf(`%s->'%s'`, expr, strings.Replace(key, "'", "''", -1))
+		var keyPatterns []string
+		var keyValues types.S
+		var err error
+		if constraint := utils.M(match[key]); constraint != nil && isElemConstraint(constraint) {
+			keyPatterns, keyValues, err = buildElemConstraint(keyExpr, constraint, index)
+		} else {
+			keyPatterns, keyValues, err = buildElemConstraint(keyExpr, types.M{"$eq": match[key]}, index)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		patterns = append(patterns, keyPatterns...)
+		values = append(values, keyValues...)
+		index = index + len(keyValues)
+	}
+	return patterns, values, nil
+}
+
+// isElemConstraint 是否为以 $ 开头的限制条件
+func isElemConstraint(constraint types.M) bool {
+	for key := range constraint {
+		if strings.HasPrefix(key, "$") == false {
+			return false
+		}
+	}
+	return len(constraint) > 0
+}
+
+// buildElemConstraint 组装对 jsonb 值的限制条件，参数以 jsonb 格式传入
+// 比较大小时要求类型相同，避免 jsonb 在不同类型之间进行比较
+func buildElemConstraint(expr string, constraint types.M, index int) ([]string, types.S, error) {
+	patterns := []string{}
+	values := types.S{}
+	keys := []string{}
+	for key := range constraint {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		v := constraint[key]
+		switch key {
+		case "$eq", "$ne":
+			not := ""
+			if key == "$ne" {
+				not = "NOT "
+			}
+			if v == nil {
+				patterns = append(patterns, fmt.Sprintf(`%s(%s IS NULL OR %s = 'null'::jsonb)`, not, expr, expr))
+				continue
+			}
+			j, _ := json.Marshal(v)
+			patterns = append(patterns, fmt.Sprintf(`%s(%s IS NOT NULL AND %s = $%d::jsonb)`, not, expr, expr, index))
+			values = append(values, string(j))
+			index = index + 1
+		case "$gt", "$lt", "$gte", "$lte":
+			j, _ := json.Marshal(v)
+			patterns = append(patterns, fmt.Sprintf(`(jsonb_typeof(%s) = jsonb_typeof($%d::jsonb) AND %s %s $%d::jsonb)`, expr, index, expr, parseToPosgresComparator[key], index))
+			values = append(values, string(j))
+			index = index + 1
+		case "$in", "$nin":
+			list := utils.A(v)
+			if list == nil {
+				return nil, nil, errs.E(errs.InvalidJSON, "bad "+key+" value")
+			}
+			not := ""
+			if key == "$nin" {
+				not = "NOT "
+			}
+			j, _ := json.Marshal(list)
+			patterns = append(patterns, fmt.Sprintf(`%sEXISTS (SELECT 1 FROM jsonb_array_elements($%d::jsonb) AS candidate WHERE candidate = %s)`, not, index, expr))
+			values = append(values, string(j))
+			index = index + 1
+		case "$exists":
+			b, ok := v.(bool)
+			if ok == false {
+				return nil, nil, errs.E(errs.InvalidJSON, "bad $exists value")
+			}
+			if b {
+				patterns = append(patterns, fmt.Sprintf(`%s IS NOT NULL`, expr))
+			} else {
+				patterns = append(patterns, fmt.Sprintf(`%s IS NULL`, expr))
+			}
+		default:
+			s, _ := json.Marshal(constraint)
+			return nil, nil, errs.E(errs.OperationForbidden, "Postgres doesn't support this query type yet "+string(s))
+		}
+	}
+	return patterns, values, nil
+}
+
+// buildLocalizedStringClause 组装 LocalizedString 字段中单个语言的查询条件，如 {"title.en":{"$in":["a","b"]}}
+// 除 buildElemConstraint 支持的条件之外，还支持 $regex
+func buildLocalizedStringClause(fieldName, locale string, fieldValue interface{}, index int) ([]string, types.S, error) {
+	locale = strings.Replace(locale, "'", "''", -1)
+	expr := fmt.Sprintf(`"%s"->'%s'`, fieldName, locale)
+	constraint := utils.M(fieldValue)
+	if constraint == nil || isElemConstraint(constraint) == false {
+		return buildElemConstraint(expr, types.M{"$eq": fieldValue}, index)
+	}
+
+	patterns := []string{}
+	values := types.S{}
+	constraint = utils.CopyMap(constraint)
+	if regex, ok := constraint["$regex"].(string); ok {
+		operator := "~"
+		opts := utils.S(constraint["$options"])
+		if strings.Contains(opts, "i") {
+			operator = "~*"
+		}
+		if strings.Contains(opts, "x") {
+			regex = removeWhiteSpace(regex)
+		}
+		patterns = append(patterns, fmt.Sprintf(`"%s"->>'%s' %s $%d`, fieldName, locale, operator, index))
+		values = append(values, processRegexPattern(regex))
+		index = index + 1
+		delete(constraint, "$regex")
+		delete(constraint, "$options")
+	}
+	if len(constraint) > 0 {
+		elemPatterns, elemValues, err := buildElemConstraint(expr, constraint, index)
+		if err != nil {
+			return nil, nil, err
+		}
+		patterns = append(patterns, elemPatterns...)
+		values = append(values, elemValues...)
+	}
+	return patterns, values, nil
+}
+
 func removeWhiteSpace(s string) string {
 	if strings.HasSuffix(s, "\n") == false {
 		s = s + "\n"
@@ -2148,10 +3324,7 @@ func literalizeRegexPart(s string) string {
 
 func valueToDate(v interface{}) types.M {
 	if v, ok := v.(time.Time); ok {
-		return types.M{
-			"__type": "Date",
-			"iso":    utils.TimetoString(v),
-		}
+		return utils.DateJSON(v)
 	}
 	return nil
 }
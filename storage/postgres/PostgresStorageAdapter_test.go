@@ -7,8 +7,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/okobsamoht/talisman/errs"
 	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
 )
 
 func Test_parseTypeToPostgresType(t *testing.T) {
@@ -117,6 +119,60 @@ func Test_parseTypeToPostgresType(t *testing.T) {
 	}
 }
 
+func Test_isSerializationError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "1", err: nil, want: false},
+		{name: "2", err: &pq.Error{Code: postgresSerializationFailureError}, want: true},
+		{name: "3", err: &pq.Error{Code: postgresDeadlockDetectedError}, want: true},
+		{name: "4", err: &pq.Error{Code: postgresDuplicateColumnError}, want: false},
+		{name: "5", err: errs.E(errs.DuplicateValue, "Class Post already exists."), want: false},
+	}
+	for _, tt := range tests {
+		if got := isSerializationError(tt.err); got != tt.want {
+			t.Errorf("%q. isSerializationError() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_rowSize(t *testing.T) {
+	var a, b, c, d interface{}
+	a = []byte(`{"lang":"zh"}`)
+	b = "hello"
+	c = int64(10)
+	d = nil
+	result := rowSize([]*interface{}{&a, &b, &c, &d})
+	expect := 13 + 5 + 8
+	if result != expect {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
+
+func Test_toPostgresDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    interface{}
+		wantErr error
+	}{
+		{name: "1", value: nil, want: nil},
+		{name: "2", value: types.M{"__type": "Date", "iso": ""}, want: nil},
+		{name: "3", value: types.M{"__type": "Date", "iso": "2006-01-02T15:04:05.000Z"}, want: "2006-01-02T15:04:05.000Z"},
+		{name: "4", value: types.M{"__type": "Date", "iso": "2006-01-02T23:04:05+08:00"}, want: "2006-01-02T15:04:05.000Z"},
+		{name: "5", value: "2006-01-02 15:04:05", want: "2006-01-02T15:04:05.000Z"},
+		{name: "6", value: "hello", want: nil, wantErr: errs.E(errs.InvalidJSON, "Invalid Date value.")},
+	}
+	for _, tt := range tests {
+		got, err := toPostgresDate(tt.value)
+		if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%q. toPostgresDate() = %v, %v, want %v, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
 func Test_toPostgresValue(t *testing.T) {
 	type args struct {
 		value interface{}
@@ -178,6 +234,421 @@ func Test_toPostgresValue(t *testing.T) {
 	}
 }
 
+func Test_polygonToPostgres(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   types.M
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "1",
+			value:   types.M{"__type": "Polygon", "coordinates": types.S{types.S{0, 0}, types.S{0, 1}}},
+			want:    "",
+			wantErr: errs.E(errs.InvalidJSON, "Polygon must have at least 3 values"),
+		},
+		{
+			name:    "2",
+			value:   types.M{"__type": "Polygon", "coordinates": types.S{types.S{0, 0}, types.S{0, 1.5}, types.S{1, 1}}},
+			want:    "((0, 0), (1.5, 0), (1, 1), (0, 0))",
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		got, err := polygonToPostgres(tt.value)
+		if reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. polygonToPostgres() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q. polygonToPostgres() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_postgresPolygonToParsePolygon(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    types.M
+		wantErr bool
+	}{
+		{
+			name: "1",
+			s:    "((0,0),(1.5,0),(1,1),(0,0))",
+			want: types.M{
+				"__type": "Polygon",
+				"coordinates": types.S{
+					types.S{0.0, 0.0},
+					types.S{0.0, 1.5},
+					types.S{1.0, 1.0},
+					types.S{0.0, 0.0},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "2",
+			s:       "((0,0),(1.5))",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		got, err := postgresPolygonToParsePolygon(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. postgresPolygonToParsePolygon() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("%q. postgresPolygonToParsePolygon() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_transformACL(t *testing.T) {
+	tests := []struct {
+		name   string
+		object types.M
+		want   types.M
+	}{
+		{
+			name:   "1",
+			object: types.M{"key": "hello"},
+			want:   types.M{"key": "hello"},
+		},
+		{
+			name:   "2",
+			object: types.M{"key": "hello", "ACL": nil},
+			want:   types.M{"key": "hello"},
+		},
+		{
+			name: "3",
+			object: types.M{
+				"key": "hello",
+				"ACL": types.M{
+					"*":           types.M{"read": true},
+					"userid":      types.M{"read": true, "write": true},
+					"role:a b":    types.M{"write": true},
+					"role:no-key": types.M{},
+				},
+			},
+			want: types.M{
+				"key":    "hello",
+				"_rperm": types.S{"*", "userid"},
+				"_wperm": types.S{"role:a b", "userid"},
+			},
+		},
+		{
+			name:   "4",
+			object: types.M{"ACL": types.M{}},
+			want:   types.M{"_rperm": types.S{}, "_wperm": types.S{}},
+		},
+	}
+	for _, tt := range tests {
+		if got := transformACL(tt.object); reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("%q. transformACL() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_toPostgresTextArray(t *testing.T) {
+	tests := []struct {
+		name   string
+		values types.S
+		want   string
+	}{
+		{name: "1", values: nil, want: "{}"},
+		{name: "2", values: types.S{"*", "userid"}, want: `{"*","userid"}`},
+		{name: "3", values: types.S{"role:a b", `a,"b"\c`}, want: `{"role:a b","a,\"b\"\\c"}`},
+	}
+	for _, tt := range tests {
+		if got := toPostgresTextArray(tt.values); got != tt.want {
+			t.Errorf("%q. toPostgresTextArray() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_parsePostgresTextArray(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want types.S
+	}{
+		{name: "1", s: "", want: nil},
+		{name: "2", s: "{}", want: types.S{}},
+		{name: "3", s: "{*,userid}", want: types.S{"*", "userid"}},
+		{name: "4", s: `{"role:a b","a,\"b\"\\c",NULL,"NULL"}`, want: types.S{"role:a b", `a,"b"\c`, nil, "NULL"}},
+		{name: "5", s: `{""}`, want: types.S{""}},
+	}
+	for _, tt := range tests {
+		if got := parsePostgresTextArray(tt.s); reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("%q. parsePostgresTextArray() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_compoundIndexName(t *testing.T) {
+	tests := []struct {
+		name       string
+		className  string
+		fieldNames []string
+		want       string
+	}{
+		{name: "1", className: "post", fieldNames: []string{"title"}, want: "post_title_idx"},
+		{name: "2", className: "post", fieldNames: []string{"author", "-createdAt"}, want: "post_author_createdAt_desc_idx"},
+		{
+			name:       "3",
+			className:  "post",
+			fieldNames: []string{"aVeryLongFieldNameNumberOne", "aVeryLongFieldNameNumberTwo", "-createdAt"},
+			want:       "post_" + utils.MD5Hash("aVeryLongFieldNameNumberOne,aVeryLongFieldNameNumberTwo,-createdAt")[:16] + "_idx",
+		},
+	}
+	for _, tt := range tests {
+		if got := compoundIndexName(tt.className, tt.fieldNames); got != tt.want {
+			t.Errorf("%q. compoundIndexName() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_sequenceName(t *testing.T) {
+	tests := []struct {
+		name      string
+		className string
+		fieldName string
+		want      string
+	}{
+		{name: "1", className: "order", fieldName: "orderNo", want: "order_orderNo_seq"},
+		{
+			name:      "2",
+			className: "order",
+			fieldName: "aVeryLongFieldNameForTheOrderNumberThatExceedsTheLimitOfPostgres",
+			want:      "order_" + utils.MD5Hash("aVeryLongFieldNameForTheOrderNumberThatExceedsTheLimitOfPostgres")[:16] + "_seq",
+		},
+	}
+	for _, tt := range tests {
+		if got := sequenceName(tt.className, tt.fieldName); got != tt.want {
+			t.Errorf("%q. sequenceName() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_buildLocalizedStringClause(t *testing.T) {
+	tests := []struct {
+		name         string
+		fieldName    string
+		locale       string
+		fieldValue   interface{}
+		index        int
+		wantPatterns []string
+		wantValues   types.S
+		wantErr      error
+	}{
+		{
+			name:         "1",
+			fieldName:    "title",
+			locale:       "en",
+			fieldValue:   "Hello",
+			index:        1,
+			wantPatterns: []string{`("title"->'en' IS NOT NULL AND "title"->'en' = $1::jsonb)`},
+			wantValues:   types.S{`"Hello"`},
+		},
+		{
+			name:         "2",
+			fieldName:    "title",
+			locale:       "zh-CN",
+			fieldValue:   types.M{"$in": types.S{"你好", "您好"}, "$exists": true},
+			index:        3,
+			wantPatterns: []string{`"title"->'zh-CN' IS NOT NULL`, `EXISTS (SELECT 1 FROM jsonb_array_elements($3::jsonb) AS candidate WHERE candidate = "title"->'zh-CN')`},
+			wantValues:   types.S{`["你好","您好"]`},
+		},
+		{
+			name:         "3",
+			fieldName:    "title",
+			locale:       "en",
+			fieldValue:   types.M{"$regex": "^Hel", "$options": "i", "$ne": "Help"},
+			index:        2,
+			wantPatterns: []string{`"title"->>'en' ~* $2`, `NOT ("title"->'en' IS NOT NULL AND "title"->'en' = $3::jsonb)`},
+			wantValues:   types.S{"^Hel", `"Help"`},
+		},
+		{
+			name:       "4",
+			fieldName:  "title",
+			locale:     "en",
+			fieldValue: types.M{"$near": "Hello"},
+			index:      1,
+			wantErr:    errs.E(errs.OperationForbidden, `Postgres doesn't support this query type yet {"$near":"Hello"}`),
+		},
+	}
+	for _, tt := range tests {
+		patterns, values, err := buildLocalizedStringClause(tt.fieldName, tt.locale, tt.fieldValue, tt.index)
+		if !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%q. buildLocalizedStringClause() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(patterns, tt.wantPatterns) {
+			t.Errorf("%q. buildLocalizedStringClause() patterns = %v, want %v", tt.name, patterns, tt.wantPatterns)
+		}
+		if !reflect.DeepEqual(values, tt.wantValues) {
+			t.Errorf("%q. buildLocalizedStringClause() values = %v, want %v", tt.name, values, tt.wantValues)
+		}
+	}
+}
+
+func Test_extractObjectPathUpdates(t *testing.T) {
+	fields := types.M{
+		"key":  types.M{"type": "String"},
+		"key2": types.M{"type": "Object"},
+	}
+	update := types.M{
+		"key":            "hi",
+		"key2.a.b":       "c",
+		"key2.a":         types.M{"__op": "Delete"},
+		"other.sub":      1,
+		"key2.count.num": types.M{"__op": "Increment", "amount": 1},
+	}
+	result, pathUpdates := extractObjectPathUpdates(update, fields)
+	expect := types.M{"key": "hi", "other.sub": 1}
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+	expectUpdates := map[string][]objectPathUpdate{
+		"key2": []objectPathUpdate{
+			{path: []string{"a"}, value: types.M{"__op": "Delete"}},
+			{path: []string{"a", "b"}, value: "c"},
+			{path: []string{"count", "num"}, value: types.M{"__op": "Increment", "amount": 1}},
+		},
+	}
+	if reflect.DeepEqual(expectUpdates, pathUpdates) == false {
+		t.Error("expect:", expectUpdates, "result:", pathUpdates)
+	}
+}
+
+func Test_buildObjectPathUpdate(t *testing.T) {
+	tests := []struct {
+		name       string
+		updates    []objectPathUpdate
+		wantExpr   string
+		wantValues types.S
+		wantErr    error
+	}{
+		{
+			name:       "1",
+			updates:    []objectPathUpdate{{path: []string{"a"}, value: "b"}},
+			wantExpr:   `jsonb_set(e, $3::text[], $4::jsonb, true)`,
+			wantValues: types.S{`{"a"}`, `"b"`},
+		},
+		{
+			name:       "2",
+			updates:    []objectPathUpdate{{path: []string{"a", "b"}, value: nil}},
+			wantExpr:   `(e #- $3::text[])`,
+			wantValues: types.S{`{"a","b"}`},
+		},
+		{
+			name:       "3",
+			updates:    []objectPathUpdate{{path: []string{"a", "b"}, value: 1}},
+			wantExpr:   `jsonb_set(jsonb_set(e, $3::text[], CASE WHEN jsonb_typeof(e #> $3::text[]) = 'object' THEN e #> $3::text[] ELSE '{}'::jsonb END, true), $4::text[], $5::jsonb, true)`,
+			wantValues: types.S{`{"a"}`, `{"a","b"}`, `1`},
+		},
+		{
+			name:       "4",
+			updates:    []objectPathUpdate{{path: []string{"a"}, value: types.M{"__op": "Increment", "amount": 2}}},
+			wantExpr:   `jsonb_set(e, $3::text[], to_jsonb(COALESCE((e #>> $3::text[])::float, 0) + $4), true)`,
+			wantValues: types.S{`{"a"}`, 2},
+		},
+		{
+			name:    "5",
+			updates: []objectPathUpdate{{path: []string{"a"}, value: types.M{"__op": "Increment", "amount": "2"}}},
+			wantErr: errs.E(errs.InvalidJSON, "incrementing must provide a number"),
+		},
+		{
+			name:    "6",
+			updates: []objectPathUpdate{{path: []string{"a"}, value: types.M{"__op": "Add", "objects": types.S{1}}}},
+			wantErr: errs.E(errs.OperationForbidden, `Postgres doesn't support update {"__op":"Add","objects":[1]} yet`),
+		},
+	}
+	for _, tt := range tests {
+		expr, values, err := buildObjectPathUpdate("e", tt.updates, 3)
+		if reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. buildObjectPathUpdate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr != nil {
+			continue
+		}
+		if expr != tt.wantExpr {
+			t.Errorf("%q. buildObjectPathUpdate() = %v, want %v", tt.name, expr, tt.wantExpr)
+		}
+		if reflect.DeepEqual(values, tt.wantValues) == false {
+			t.Errorf("%q. buildObjectPathUpdate() values = %v, want %v", tt.name, values, tt.wantValues)
+		}
+	}
+}
+
+func TestPostgresAdapter_ACL(t *testing.T) {
+	db := openDB()
+	p := NewPostgresAdapter("", db)
+	schema := types.M{
+		"className": "post",
+		"fields": types.M{
+			"objectId": types.M{"type": "String"},
+			"key":      types.M{"type": "String"},
+		},
+	}
+	p.CreateClass("post", schema)
+	defer func() {
+		db.Exec(`DROP TABLE "post"`)
+		db.Exec(`DROP TABLE "_SCHEMA"`)
+	}()
+
+	object := types.M{
+		"objectId": "01",
+		"key":      "hello",
+		"ACL": types.M{
+			"*":        types.M{"read": true},
+			"role:a b": types.M{"read": true, "write": true},
+		},
+	}
+	err := p.CreateObject("post", schema, object)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	results, err := p.Find("post", schema, types.M{"_rperm": types.M{"$in": types.S{nil, "*", "userid"}}}, types.M{})
+	expect := []types.M{
+		types.M{
+			"objectId": "01",
+			"key":      "hello",
+			"_rperm":   types.S{"*", "role:a b"},
+			"_wperm":   types.S{"role:a b"},
+		},
+	}
+	if err != nil || reflect.DeepEqual(results, expect) == false {
+		t.Error("expect:", expect, "result:", results, err)
+	}
+	/*****************************************************/
+	query := types.M{"objectId": "01", "_wperm": types.M{"$in": types.S{nil, "userid"}}}
+	result, err := p.FindOneAndUpdate("post", schema, query, types.M{"key": "hi"})
+	if err != nil || len(result) != 0 {
+		t.Error("expect:", types.M{}, "result:", result, err)
+	}
+	/*****************************************************/
+	query = types.M{"objectId": "01", "_wperm": types.M{"$in": types.S{nil, "role:a b"}}}
+	update := types.M{"ACL": types.M{}}
+	result, err = p.FindOneAndUpdate("post", schema, query, update)
+	expectObject := types.M{
+		"objectId": "01",
+		"key":      "hello",
+		"_rperm":   types.S{},
+		"_wperm":   types.S{},
+	}
+	if err != nil || reflect.DeepEqual(result, expectObject) == false {
+		t.Error("expect:", expectObject, "result:", result, err)
+	}
+	if _, ok := update["ACL"]; ok == false {
+		t.Error("expect:", "update not modified", "result:", update)
+	}
+}
+
 func Test_transformValue(t *testing.T) {
 	type args struct {
 		value interface{}
@@ -1521,93 +1992,427 @@ func Test_buildWhereClause(t *testing.T) {
 				index: 1,
 			},
 			want: &whereClause{
-				pattern: `"key" < $1`,
-				values:  types.S{1024},
+				pattern: `"key" < $1`,
+				values:  types.S{1024},
+				sorts:   []string{},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "36",
+			args: args{
+				schema: types.M{
+					"fields": types.M{},
+				},
+				query: types.M{
+					"key": types.M{
+						"$gte": 1024,
+					},
+				},
+				index: 1,
+			},
+			want: &whereClause{
+				pattern: `"key" >= $1`,
+				values:  types.S{1024},
+				sorts:   []string{},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "37",
+			args: args{
+				schema: types.M{
+					"fields": types.M{},
+				},
+				query: types.M{
+					"key": types.M{
+						"$lte": 1024,
+					},
+				},
+				index: 1,
+			},
+			want: &whereClause{
+				pattern: `"key" <= $1`,
+				values:  types.S{1024},
+				sorts:   []string{},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "38",
+			args: args{
+				schema: types.M{
+					"fields": types.M{},
+				},
+				query: types.M{
+					"key": types.S{"hello"},
+				},
+				index: 1,
+			},
+			want:    nil,
+			wantErr: errs.E(errs.OperationForbidden, `Postgres doesn't support this query type yet ["hello"]`),
+		},
+		{
+			name: "39",
+			args: args{
+				schema: types.M{
+					"fields": types.M{},
+				},
+				query: types.M{
+					"key": nil,
+				},
+				index: 1,
+			},
+			want: &whereClause{
+				pattern: `"key" IS NULL`,
+				values:  types.S{},
+				sorts:   []string{},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "40",
+			args: args{
+				schema: types.M{
+					"fields": types.M{
+						"key": types.M{"type": "Array"},
+					},
+				},
+				query: types.M{
+					"key": types.M{"$size": 2.0},
+				},
+				index: 1,
+			},
+			want: &whereClause{
+				pattern: `COALESCE(jsonb_array_length("key"), 0) = $1`,
+				values:  types.S{2},
+				sorts:   []string{},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "41",
+			args: args{
+				schema: types.M{
+					"fields": types.M{
+						"key": types.M{"type": "Array"},
+					},
+				},
+				query: types.M{
+					"key": types.M{"$size": -1},
+				},
+				index: 1,
+			},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidJSON, "bad $size value"),
+		},
+		{
+			name: "42",
+			args: args{
+				schema: types.M{
+					"fields": types.M{
+						"key": types.M{"type": "Array"},
+					},
+				},
+				query: types.M{
+					"key": types.M{"$elemMatch": types.M{"$gte": 80, "$lt": 85}},
+				},
+				index: 1,
+			},
+			want: &whereClause{
+				pattern: `EXISTS (SELECT 1 FROM jsonb_array_elements("key") AS elem WHERE (jsonb_typeof(elem) = jsonb_typeof($1::jsonb) AND elem >= $1::jsonb) AND (jsonb_typeof(elem) = jsonb_typeof($2::jsonb) AND elem < $2::jsonb))`,
+				values:  types.S{"80", "85"},
+				sorts:   []string{},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "43",
+			args: args{
+				schema: types.M{
+					"fields": types.M{
+						"key": types.M{"type": "Array"},
+					},
+				},
+				query: types.M{
+					"key": types.M{"$elemMatch": types.M{
+						"product": "xyz",
+						"score":   types.M{"$in": types.S{8, 9}},
+					}},
+				},
+				index: 1,
+			},
+			want: &whereClause{
+				pattern: `EXISTS (SELECT 1 FROM jsonb_array_elements("key") AS elem WHERE (elem->'product' IS NOT NULL AND elem->'product' = $1::jsonb) AND EXISTS (SELECT 1 FROM jsonb_array_elements($2::jsonb) AS candidate WHERE candidate = elem->'score'))`,
+				values:  types.S{`"xyz"`, `[8,9]`},
+				sorts:   []string{},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "44",
+			args: args{
+				schema: types.M{
+					"fields": types.M{
+						"key": types.M{"type": "Array"},
+					},
+				},
+				query: types.M{
+					"key": types.M{"$elemMatch": types.M{"$regex": "^a"}},
+				},
+				index: 1,
+			},
+			want:    nil,
+			wantErr: errs.E(errs.OperationForbidden, `Postgres doesn't support this query type yet {"$regex":"^a"}`),
+		},
+		{
+			name: "45",
+			args: args{
+				schema: types.M{
+					"fields": types.M{
+						"key": types.M{"type": "Array"},
+					},
+				},
+				query: types.M{
+					"key": types.M{"$containedBy": types.S{1, 2, 3}},
+				},
+				index: 1,
+			},
+			want: &whereClause{
+				pattern: `("key" IS NULL OR array_contained_by("key", $1::jsonb))`,
+				values:  types.S{`[1,2,3]`},
+				sorts:   []string{},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "46",
+			args: args{
+				schema: types.M{
+					"fields": types.M{
+						"key": types.M{"type": "String"},
+					},
+				},
+				query: types.M{
+					"key": types.M{"$containedBy": types.S{"hello"}},
+				},
+				index: 1,
+			},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidJSON, "bad $containedBy: can only be used on Array fields"),
+		},
+		{
+			name: "47",
+			args: args{
+				schema: types.M{
+					"fields": types.M{
+						"location": types.M{"type": "GeoPoint"},
+					},
+				},
+				query: types.M{
+					"location": types.M{"$geoWithin": types.M{
+						"$centerSphere": types.S{
+							types.M{"__type": "GeoPoint", "longitude": 120.0, "latitude": 30.0},
+							0.1,
+						},
+					}},
+				},
+				index: 1,
+			},
+			want: &whereClause{
+				pattern: `ST_distance_sphere("location"::geometry, POINT($1, $2)::geometry) <= $3`,
+				values:  types.S{120.0, 30.0, 0.1 * 6371 * 1000},
+				sorts:   []string{},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "48",
+			args: args{
+				schema: types.M{
+					"fields": types.M{
+						"location": types.M{"type": "GeoPoint"},
+					},
+				},
+				query: types.M{
+					"location": types.M{"$geoWithin": types.M{
+						"$centerSphere": types.S{types.S{200, 30}, 0.1},
+					}},
+				},
+				index: 1,
+			},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidJSON, "bad $geoWithin value; $centerSphere geo point invalid"),
+		},
+		{
+			name: "49",
+			args: args{
+				schema: types.M{
+					"fields": types.M{
+						"location": types.M{"type": "String"},
+					},
+				},
+				query: types.M{
+					"location": types.M{"$geoWithin": types.M{
+						"$centerSphere": types.S{types.S{120, 30}, 0.1},
+					}},
+				},
+				index: 1,
+			},
+			want:    nil,
+			wantErr: errs.E(errs.InvalidJSON, "bad $geoWithin value; $centerSphere can only be used on GeoPoint fields"),
+		},
+	}
+	for _, tt := range tests {
+		got, err := buildWhereClause(tt.args.schema, tt.args.query, tt.args.index)
+		if !reflect.DeepEqual(err, tt.wantErr) {
+			t.Errorf("%q. buildWhereClause() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. buildWhereClause() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_buildWhereClauseWithOptions(t *testing.T) {
+	type args struct {
+		schema          types.M
+		query           types.M
+		index           int
+		caseInsensitive bool
+	}
+	schema := types.M{
+		"fields": types.M{
+			"username": types.M{"type": "String"},
+			"email":    types.M{"type": "String"},
+			"age":      types.M{"type": "Number"},
+		},
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *whereClause
+		wantErr error
+	}{
+		{
+			name: "1",
+			args: args{
+				schema:          schema,
+				query:           types.M{"username": "Joe"},
+				index:           1,
+				caseInsensitive: false,
+			},
+			want: &whereClause{
+				pattern: `"username" = $1`,
+				values:  types.S{"Joe"},
 				sorts:   []string{},
 			},
 			wantErr: nil,
 		},
 		{
-			name: "36",
+			name: "2",
 			args: args{
-				schema: types.M{
-					"fields": types.M{},
-				},
-				query: types.M{
-					"key": types.M{
-						"$gte": 1024,
-					},
-				},
-				index: 1,
+				schema:          schema,
+				query:           types.M{"username": "Joe"},
+				index:           1,
+				caseInsensitive: true,
 			},
 			want: &whereClause{
-				pattern: `"key" >= $1`,
-				values:  types.S{1024},
+				pattern: `lower("username") = lower($1)`,
+				values:  types.S{"Joe"},
 				sorts:   []string{},
 			},
 			wantErr: nil,
 		},
 		{
-			name: "37",
+			name: "3",
 			args: args{
-				schema: types.M{
-					"fields": types.M{},
-				},
-				query: types.M{
-					"key": types.M{
-						"$lte": 1024,
-					},
-				},
-				index: 1,
+				schema:          schema,
+				query:           types.M{"email": types.M{"$eq": "Joe@Example.com"}},
+				index:           1,
+				caseInsensitive: true,
 			},
 			want: &whereClause{
-				pattern: `"key" <= $1`,
-				values:  types.S{1024},
+				pattern: `lower("email") = lower($1)`,
+				values:  types.S{"Joe@Example.com"},
 				sorts:   []string{},
 			},
 			wantErr: nil,
 		},
 		{
-			name: "38",
+			name: "4",
 			args: args{
-				schema: types.M{
-					"fields": types.M{},
-				},
-				query: types.M{
-					"key": types.S{"hello"},
-				},
-				index: 1,
+				schema: schema,
+				query: types.M{"$or": types.S{
+					types.M{"username": "Joe"},
+				}},
+				index:           1,
+				caseInsensitive: true,
 			},
-			want:    nil,
-			wantErr: errs.E(errs.OperationForbidden, `Postgres doesn't support this query type yet ["hello"]`),
+			want: &whereClause{
+				pattern: `(lower("username") = lower($1))`,
+				values:  types.S{"Joe"},
+				sorts:   []string{},
+			},
+			wantErr: nil,
 		},
 		{
-			name: "39",
+			name: "5",
 			args: args{
-				schema: types.M{
-					"fields": types.M{},
-				},
-				query: types.M{
-					"key": nil,
-				},
-				index: 1,
+				schema:          schema,
+				query:           types.M{"age": 10.0},
+				index:           1,
+				caseInsensitive: true,
 			},
 			want: &whereClause{
-				pattern: `"key" IS NULL`,
-				values:  types.S{},
+				pattern: `"age" = $1`,
+				values:  types.S{10.0},
 				sorts:   []string{},
 			},
 			wantErr: nil,
 		},
 	}
 	for _, tt := range tests {
-		got, err := buildWhereClause(tt.args.schema, tt.args.query, tt.args.index)
+		got, err := buildWhereClauseWithOptions(tt.args.schema, tt.args.query, tt.args.index, tt.args.caseInsensitive)
 		if !reflect.DeepEqual(err, tt.wantErr) {
-			t.Errorf("%q. buildWhereClause() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			t.Errorf("%q. buildWhereClauseWithOptions() error = %v, wantErr %v", tt.name, err, tt.wantErr)
 			continue
 		}
 		if !reflect.DeepEqual(got, tt.want) {
-			t.Errorf("%q. buildWhereClause() = %v, want %v", tt.name, got, tt.want)
+			t.Errorf("%q. buildWhereClauseWithOptions() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_transformSortKey(t *testing.T) {
+	type args struct {
+		key string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "1",
+			args: args{key: "key"},
+			want: `"key"`,
+		},
+		{
+			name: "2",
+			args: args{key: "info.age"},
+			want: `"info"->'age'`,
+		},
+		{
+			name: "3",
+			args: args{key: "info.name.first"},
+			want: `"info"->'name'->'first'`,
+		},
+	}
+	for _, tt := range tests {
+		if got := transformSortKey(tt.args.key); got != tt.want {
+			t.Errorf("%q. transformSortKey() = %v, want %v", tt.name, got, tt.want)
 		}
 	}
 }
@@ -5779,6 +6584,52 @@ func TestPostgresAdapter_FindOneAndUpdate(t *testing.T) {
 			initialize: initialize,
 			clean:      clean,
 		},
+		{
+			name: "21.3-Object-Nested-Set",
+			args: args{
+				className: "post",
+				schema: types.M{
+					"className": "post",
+					"fields": types.M{
+						"key":  types.M{"type": "String"},
+						"key2": types.M{"type": "Object"},
+					},
+				},
+				query:  types.M{"key": "hi"},
+				update: types.M{"key2.a.b.c": "world"},
+				dataObjects: []types.M{
+					types.M{"key": "hello", "key2": types.M{"a": types.M{"b": types.M{"d": "hello"}}}},
+					types.M{"key": "hi", "key2": types.M{"a": types.M{"b": types.M{"d": "hi"}, "e": "hi"}}},
+				},
+			},
+			want:       types.M{"key": "hi", "key2": types.M{"a": types.M{"b": types.M{"c": "world", "d": "hi"}, "e": "hi"}}},
+			wantErr:    nil,
+			initialize: initialize,
+			clean:      clean,
+		},
+		{
+			name: "21.4-Object-Nested-Delete",
+			args: args{
+				className: "post",
+				schema: types.M{
+					"className": "post",
+					"fields": types.M{
+						"key":  types.M{"type": "String"},
+						"key2": types.M{"type": "Object"},
+					},
+				},
+				query:  types.M{"key": "hi"},
+				update: types.M{"key2.a.b.d": types.M{"__op": "Delete"}},
+				dataObjects: []types.M{
+					types.M{"key": "hello", "key2": types.M{"a": types.M{"b": types.M{"d": "hello"}}}},
+					types.M{"key": "hi", "key2": types.M{"a": types.M{"b": types.M{"d": "hi", "f": "hi"}}}},
+				},
+			},
+			want:       types.M{"key": "hi", "key2": types.M{"a": types.M{"b": types.M{"f": "hi"}}}},
+			wantErr:    nil,
+			initialize: initialize,
+			clean:      clean,
+		},
 		{
 			name: "22-text[]",
 			args: args{
@@ -5871,6 +6722,40 @@ func TestPostgresAdapter_FindOneAndUpdate(t *testing.T) {
 			initialize: initialize,
 			clean:      clean,
 		},
+		{
+			name: "26-UpdateMatching",
+			args: args{
+				className: "post",
+				schema: types.M{
+					"className": "post",
+					"fields": types.M{
+						"key":  types.M{"type": "String"},
+						"key2": types.M{"type": "Array"},
+					},
+				},
+				query: types.M{"key": "hi"},
+				update: types.M{"key2": types.M{
+					"__op":  "UpdateMatching",
+					"match": types.M{"id": "b"},
+					"set":   types.M{"done": true},
+				}},
+				dataObjects: []types.M{
+					types.M{"key": "hi", "key2": types.S{
+						types.M{"id": "a", "done": false},
+						types.M{"id": "b", "done": false},
+						types.M{"id": "b", "done": false},
+					}},
+				},
+			},
+			want: types.M{"key": "hi", "key2": types.S{
+				map[string]interface{}{"id": "a", "done": false},
+				map[string]interface{}{"id": "b", "done": true},
+				map[string]interface{}{"id": "b", "done": false},
+			}},
+			wantErr:    nil,
+			initialize: initialize,
+			clean:      clean,
+		},
 	}
 	for _, tt := range tests {
 		tt.initialize(tt.args.className, tt.args.schema, tt.args.dataObjects)
@@ -5977,6 +6862,183 @@ func TestPostgresAdapter_UpsertOneObject(t *testing.T) {
 	}
 }
 
+func TestPostgresAdapter_FindOneAndUpsert(t *testing.T) {
+	db := openDB()
+	p := NewPostgresAdapter("", db)
+	initialize := func(className string, schema types.M, objects []types.M) {
+		p.CreateClass(className, schema)
+		for _, object := range objects {
+			p.CreateObject(className, schema, object)
+		}
+	}
+	clean := func(className string) {
+		db.Exec(`DROP TABLE "` + className + `"`)
+		db.Exec(`DROP TABLE "_SCHEMA"`)
+	}
+	schema := types.M{
+		"className": "post",
+		"fields": types.M{
+			"objectId": types.M{"type": "String"},
+			"key":      types.M{"type": "String"},
+			"count":    types.M{"type": "Number"},
+		},
+	}
+	type args struct {
+		className   string
+		schema      types.M
+		query       types.M
+		update      types.M
+		insert      types.M
+		dataObjects []types.M
+	}
+	tests := []struct {
+		name        string
+		args        args
+		want        types.M
+		wantCreated bool
+		wantErr     error
+		initialize  func(className string, schema types.M, objects []types.M)
+		clean       func(className string)
+	}{
+		{
+			name: "1",
+			args: args{
+				className: "post",
+				schema:    schema,
+				query:     types.M{"key": "hi"},
+				update:    types.M{"count": types.M{"__op": "Increment", "amount": 1}},
+				insert:    types.M{"objectId": "02", "key": "hi", "count": 1},
+				dataObjects: []types.M{
+					types.M{"objectId": "01", "key": "hi", "count": 1},
+				},
+			},
+			want:        types.M{"objectId": "01", "key": "hi", "count": 2.0},
+			wantCreated: false,
+			wantErr:     nil,
+			initialize:  initialize,
+			clean:       clean,
+		},
+		{
+			name: "2",
+			args: args{
+				className: "post",
+				schema:    schema,
+				query:     types.M{"key": "haha"},
+				update:    types.M{"count": types.M{"__op": "Increment", "amount": 1}},
+				insert:    types.M{"objectId": "02", "key": "haha", "count": 1},
+				dataObjects: []types.M{
+					types.M{"objectId": "01", "key": "hi", "count": 1},
+				},
+			},
+			want:        types.M{"objectId": "02", "key": "haha", "count": 1.0},
+			wantCreated: true,
+			wantErr:     nil,
+			initialize:  initialize,
+			clean:       clean,
+		},
+	}
+	for _, tt := range tests {
+		tt.initialize(tt.args.className, tt.args.schema, tt.args.dataObjects)
+		got, created, err := p.FindOneAndUpsert(tt.args.className, tt.args.schema, tt.args.query, tt.args.update, tt.args.insert)
+		tt.clean(tt.args.className)
+
+		if reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. PostgresAdapter.FindOneAndUpsert() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if created != tt.wantCreated || reflect.DeepEqual(got, tt.want) == false {
+			t.Errorf("%q. PostgresAdapter.FindOneAndUpsert() = %v, %v, want %v, %v", tt.name, got, created, tt.want, tt.wantCreated)
+		}
+	}
+}
+
+func TestPostgresAdapter_FindOneAndDelete(t *testing.T) {
+	db := openDB()
+	p := NewPostgresAdapter("", db)
+	initialize := func(className string, schema types.M, objects []types.M) {
+		p.CreateClass(className, schema)
+		for _, object := range objects {
+			p.CreateObject(className, schema, object)
+		}
+	}
+	clean := func(className string) {
+		db.Exec(`DROP TABLE "` + className + `"`)
+		db.Exec(`DROP TABLE "_SCHEMA"`)
+	}
+	schema := types.M{
+		"className": "job",
+		"fields": types.M{
+			"objectId": types.M{"type": "String"},
+			"state":    types.M{"type": "String"},
+			"priority": types.M{"type": "Number"},
+		},
+	}
+	dataObjects := []types.M{
+		types.M{"objectId": "01", "state": "ready", "priority": 1},
+		types.M{"objectId": "02", "state": "ready", "priority": 2},
+		types.M{"objectId": "03", "state": "done", "priority": 3},
+	}
+	type args struct {
+		className   string
+		schema      types.M
+		query       types.M
+		options     types.M
+		dataObjects []types.M
+	}
+	tests := []struct {
+		name       string
+		args       args
+		want       types.M
+		wantCount  int
+		wantErr    error
+		initialize func(className string, schema types.M, objects []types.M)
+		clean      func(className string)
+	}{
+		{
+			name: "1",
+			args: args{
+				className:   "job",
+				schema:      schema,
+				query:       types.M{"state": "ready"},
+				options:     types.M{"sort": []string{"-priority"}},
+				dataObjects: dataObjects,
+			},
+			want:       types.M{"objectId": "02", "state": "ready", "priority": 2.0},
+			wantCount:  2,
+			wantErr:    nil,
+			initialize: initialize,
+			clean:      clean,
+		},
+		{
+			name: "2",
+			args: args{
+				className:   "job",
+				schema:      schema,
+				query:       types.M{"state": "failed"},
+				options:     nil,
+				dataObjects: dataObjects,
+			},
+			want:       types.M{},
+			wantCount:  3,
+			wantErr:    nil,
+			initialize: initialize,
+			clean:      clean,
+		},
+	}
+	for _, tt := range tests {
+		tt.initialize(tt.args.className, tt.args.schema, tt.args.dataObjects)
+		got, err := p.FindOneAndDelete(tt.args.className, tt.args.schema, tt.args.query, tt.args.options)
+		count, _ := p.Count(tt.args.className, tt.args.schema, types.M{})
+		tt.clean(tt.args.className)
+
+		if reflect.DeepEqual(err, tt.wantErr) == false {
+			t.Errorf("%q. PostgresAdapter.FindOneAndDelete() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if reflect.DeepEqual(got, tt.want) == false || count != tt.wantCount {
+			t.Errorf("%q. PostgresAdapter.FindOneAndDelete() = %v, %v, want %v, %v", tt.name, got, count, tt.want, tt.wantCount)
+		}
+	}
+}
+
 func TestPostgresAdapter_EnsureUniqueness(t *testing.T) {
 	db := openDB()
 	p := NewPostgresAdapter("", db)
@@ -6063,3 +7125,37 @@ func TestPostgresAdapter_EnsureUniqueness(t *testing.T) {
 		}
 	}
 }
+
+func TestPostgresAdapter_stmtCache(t *testing.T) {
+	db := openDB()
+	p := NewPostgresAdapter("", db)
+	p.SetStmtCacheSize(2)
+	schema := types.M{
+		"className": "post",
+		"fields": types.M{
+			"key": types.M{"type": "String"},
+		},
+	}
+	p.CreateClass("post", schema)
+	p.CreateObject("post", schema, types.M{"objectId": "01", "key": "hello"})
+	p.Find("post", schema, types.M{"objectId": "01"}, types.M{})
+	if p.stmts.Len() != 2 {
+		t.Errorf("stmtCache.Len() = %v, want %v", p.stmts.Len(), 2)
+	}
+	p.Count("post", schema, types.M{})
+	if p.stmts.Len() != 2 {
+		t.Errorf("stmtCache.Len() = %v, want %v", p.stmts.Len(), 2)
+	}
+	p.AddFieldIfNotExists("post", "title", types.M{"type": "String"})
+	if p.stmts.Len() != 0 {
+		t.Errorf("stmtCache.Len() = %v, want %v", p.stmts.Len(), 0)
+	}
+	schema["fields"].(types.M)["title"] = types.M{"type": "String"}
+	result, err := p.Find("post", schema, types.M{"objectId": "01"}, types.M{})
+	if err != nil || len(result) != 1 || result[0]["key"] != "hello" {
+		t.Errorf("Find() = %v, %v", result, err)
+	}
+	db.Exec(`DROP TABLE "post"`)
+	db.Exec(`DROP TABLE "_SCHEMA"`)
+	p.HandleShutdown()
+}
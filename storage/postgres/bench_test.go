@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/okobsamoht/talisman/bench"
+)
+
+func benchmarkBuildWhereClause(b *testing.B, aclSize int) {
+	schema := bench.Schema()
+	where := bench.WhereWithACL(aclSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := buildWhereClause(schema, where, 1)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBuildWhereClause_ACL1(b *testing.B)   { benchmarkBuildWhereClause(b, 1) }
+func BenchmarkBuildWhereClause_ACL100(b *testing.B) { benchmarkBuildWhereClause(b, 100) }
+
+func benchmarkExtractACL(b *testing.B, aclSize int) {
+	where := bench.WhereWithACL(aclSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		extractACL(where)
+	}
+}
+
+func BenchmarkExtractACL_ACL1(b *testing.B)   { benchmarkExtractACL(b, 1) }
+func BenchmarkExtractACL_ACL100(b *testing.B) { benchmarkExtractACL(b, 100) }
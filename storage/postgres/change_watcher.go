@@ -0,0 +1,202 @@
+package postgres
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/okobsamoht/talisman/storage"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+/*
+对象变更
+
+PostgreSQL 没有可直接读取的变更日志，在类对应的表上添加触发器，新增、更新、删除对象时
+向 _LiveQueryEvent 表写入一条记录，再按自增 id 轮询读取，记录的 id 即为 Token 。
+之后新建的类在下一次同步触发器时添加，超过 changeRetention 的记录会被删除。
+*/
+
+const (
+	changePollInterval        = 500 * time.Millisecond
+	changeTriggerSyncInterval = 10 * time.Second
+	changeRetention           = time.Hour
+	changeBatchSize           = 100
+)
+
+// changeTriggerFunction 触发器函数，表名即为类名
+const changeTriggerFunction = `CREATE OR REPLACE FUNCTION talisman_record_change() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		INSERT INTO "_LiveQueryEvent" ("className", "objectId", "op") VALUES (TG_TABLE_NAME, OLD."objectId", 'd');
+		RETURN OLD;
+	END IF;
+	INSERT INTO "_LiveQueryEvent" ("className", "objectId", "op") VALUES (TG_TABLE_NAME, NEW."objectId", lower(left(TG_OP, 1)));
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql`
+
+// changeEvent _LiveQueryEvent 表中的一条记录， op 为 i u d ，分别表示新增、更新、删除
+type changeEvent struct {
+	id        int64
+	className string
+	objectID  string
+	op        string
+}
+
+// WatchChanges 轮询 _LiveQueryEvent 表中的对象变更， Token 为记录的 id
+// 新增与更新时从数据库中读取当前的对象
+func (p *PostgresAdapter) WatchChanges(classPrefix, resumeToken string, handler func(change *storage.Change), stop <-chan struct{}) error {
+	err := p.ensureChangeTable()
+	if err != nil {
+		return err
+	}
+	last, err := p.changeStart(resumeToken)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(changePollInterval)
+	defer ticker.Stop()
+	var synced time.Time
+	for {
+		if time.Since(synced) > changeTriggerSyncInterval {
+			err = p.ensureChangeTriggers(classPrefix)
+			if err != nil {
+				return err
+			}
+			p.db.Exec(`DELETE FROM "_LiveQueryEvent" WHERE "createdAt" < $1`, time.Now().Add(-changeRetention))
+			synced = time.Now()
+		}
+
+		for {
+			events, err := p.readChanges(last)
+			if err != nil {
+				return err
+			}
+			for _, event := range events {
+				last = event.id
+				if strings.HasPrefix(event.className, classPrefix) == false {
+					continue
+				}
+				if change := p.eventChange(event); change != nil {
+					change.Token = strconv.FormatInt(event.id, 10)
+					handler(change)
+				}
+			}
+			if len(events) < changeBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// ensureChangeTable 创建 _LiveQueryEvent 表与触发器函数
+func (p *PostgresAdapter) ensureChangeTable() error {
+	_, err := p.db.Exec(`CREATE TABLE IF NOT EXISTS "_LiveQueryEvent" ( "id" bigserial, "className" varChar(120) NOT NULL, "objectId" varChar(120) NOT NULL, "op" char(1) NOT NULL, "createdAt" timestamp with time zone NOT NULL DEFAULT now(), PRIMARY KEY ("id") )`)
+	if err != nil {
+		if e, ok := err.(*pq.Error); ok == false || (e.Code != postgresDuplicateRelationError && e.Code != postgresUniqueIndexViolationError) {
+			return err
+		}
+	}
+	_, err = p.db.Exec(changeTriggerFunction)
+	return err
+}
+
+// ensureChangeTriggers 为类名以 classPrefix 开头的表添加触发器，已存在的跳过
+func (p *PostgresAdapter) ensureChangeTriggers(classPrefix string) error {
+	schemas, err := p.GetAllClasses()
+	if err != nil {
+		return err
+	}
+	for _, schema := range schemas {
+		className := utils.S(schema["className"])
+		if className == "" || strings.HasPrefix(className, classPrefix) == false {
+			continue
+		}
+		trigger := className + "_livequery"
+		var exists bool
+		err = p.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = $1)`, trigger).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		_, err = p.db.Exec(fmt.Sprintf(`CREATE TRIGGER "%s" AFTER INSERT OR UPDATE OR DELETE ON "%s" FOR EACH ROW EXECUTE PROCEDURE talisman_record_change()`, trigger, className))
+		if err != nil {
+			if e, ok := err.(*pq.Error); ok {
+				// 类对应的表还未创建，或者触发器已由其他进程添加
+				if e.Code == postgresRelationDoesNotExistError || e.Code == postgresDuplicateObjectError {
+					continue
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// changeStart 返回开始读取的位置， resumeToken 为空时从当前最后一条记录之后开始
+func (p *PostgresAdapter) changeStart(resumeToken string) (int64, error) {
+	if resumeToken != "" {
+		return strconv.ParseInt(resumeToken, 10, 64)
+	}
+	var last int64
+	err := p.db.QueryRow(`SELECT COALESCE(MAX("id"), 0) FROM "_LiveQueryEvent"`).Scan(&last)
+	return last, err
+}
+
+// readChanges 按 id 顺序读取 after 之后的记录
+func (p *PostgresAdapter) readChanges(after int64) ([]*changeEvent, error) {
+	rows, err := p.db.Query(`SELECT "id", "className", "objectId", "op" FROM "_LiveQueryEvent" WHERE "id" > $1 ORDER BY "id" LIMIT $2`, after, changeBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []*changeEvent{}
+	for rows.Next() {
+		event := &changeEvent{}
+		err = rows.Scan(&event.id, &event.className, &event.objectID, &event.op)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// eventChange 把记录转换为对象变更，对象已不存在时返回 nil
+func (p *PostgresAdapter) eventChange(event *changeEvent) *storage.Change {
+	if event.op == "d" {
+		return &storage.Change{
+			ClassName: event.className,
+			Deleted:   true,
+			Object:    types.M{"objectId": event.objectID},
+		}
+	}
+
+	schema, err := p.GetClass(event.className)
+	if err != nil || len(schema) == 0 {
+		return nil
+	}
+	// 副本可能还未同步到该对象，从主库读取
+	objects, err := p.find(event.className, schema, types.M{"objectId": event.objectID}, types.M{"limit": 1}, p.aclSession)
+	if err != nil || len(objects) == 0 {
+		return nil
+	}
+	return &storage.Change{
+		ClassName: event.className,
+		Created:   event.op == "i",
+		Object:    objects[0],
+	}
+}
@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/okobsamoht/talisman/types"
+)
+
+/*
+只读副本
+
+Find 、 Count 、 EstimatedCount 按顺序轮流使用可用的只读副本，其他操作，包括写入对象、
+修改表结构、读取 schema ，均使用主库。
+后台每隔 replicaCheckInterval 检查一次副本，连接失败或者延迟超过 maxLag 的副本暂停使用，
+恢复之后重新加入；查询时副本连接失败，同样暂停使用该副本，并在主库上重新执行。
+没有可用的副本时使用主库。
+*/
+
+const replicaCheckInterval = 5 * time.Second
+
+// replicaLagQuery 查询副本的延迟，单位为秒，已应用所有收到的日志时为 0
+const replicaLagQuery = `SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0 ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END`
+
+// replica 只读副本
+type replica struct {
+	db      *sql.DB
+	stmts   *stmtCache
+	mu      sync.RWMutex
+	healthy bool
+}
+
+func (r *replica) isHealthy() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.healthy
+}
+
+func (r *replica) setHealthy(healthy bool) {
+	r.mu.Lock()
+	r.healthy = healthy
+	r.mu.Unlock()
+}
+
+// check 检查副本是否可以连接，以及延迟是否超过 maxLag ， maxLag 为 0 时不检查延迟
+func (r *replica) check(maxLag time.Duration) {
+	var lag float64
+	err := r.db.QueryRow(replicaLagQuery).Scan(&lag)
+	if err != nil {
+		r.setHealthy(false)
+		return
+	}
+	r.setHealthy(maxLag <= 0 || time.Duration(lag*float64(time.Second)) <= maxLag)
+}
+
+// SetReadReplicas 设置只读副本，已有的副本会被关闭
+// maxLag 为副本允许的最大延迟，为 0 时不检查延迟
+func (p *PostgresAdapter) SetReadReplicas(dbs []*sql.DB, maxLag time.Duration) {
+	p.closeReplicas()
+	if len(dbs) == 0 {
+		return
+	}
+	replicas := []*replica{}
+	for _, db := range dbs {
+		r := &replica{
+			db:    db,
+			stmts: newStmtCache(db, p.stmtCacheSize),
+		}
+		r.check(maxLag)
+		replicas = append(replicas, r)
+	}
+	p.replicas = replicas
+
+	stop := make(chan struct{})
+	p.replicaStop = stop
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(replicaCheckInterval):
+				for _, r := range replicas {
+					r.check(maxLag)
+				}
+			}
+		}
+	}()
+}
+
+// closeReplicas 停止检查并关闭所有副本
+func (p *PostgresAdapter) closeReplicas() {
+	if p.replicaStop != nil {
+		close(p.replicaStop)
+		p.replicaStop = nil
+	}
+	for _, r := range p.replicas {
+		r.stmts.Clear()
+		r.db.Close()
+	}
+	p.replicas = nil
+}
+
+// pickReplica 轮流选择可用的副本，没有可用的副本时返回 nil
+func (p *PostgresAdapter) pickReplica() *replica {
+	n := len(p.replicas)
+	if n == 0 {
+		return nil
+	}
+	start := atomic.AddUint32(&p.replicaNext, 1)
+	for i := 0; i < n; i++ {
+		r := p.replicas[(int(start)+i)%n]
+		if r.isHealthy() {
+			return r
+		}
+	}
+	return nil
+}
+
+// readSession 与 aclSession 相同，但优先在只读副本上执行
+func (p *PostgresAdapter) readSession(query types.M) (types.M, queryer, *sql.Tx, error) {
+	r := p.pickReplica()
+	if r == nil {
+		return p.aclSession(query)
+	}
+	result, q, tx, err := p.aclSessionOn(r.db, &readQueryer{replica: r, primary: p.stmts}, query)
+	if err != nil && isConnectionError(err) {
+		r.setHealthy(false)
+		return p.aclSession(query)
+	}
+	return result, q, tx, err
+}
+
+// readQueryer 在副本上执行查询，连接失败时暂停使用该副本，并在主库上重新执行
+// QueryRow 的错误在 Scan 时才能得到，因此不会切换到主库， Exec 总是在主库上执行
+type readQueryer struct {
+	replica *replica
+	primary queryer
+}
+
+func (q *readQueryer) Query(qs string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := q.replica.stmts.Query(qs, args...)
+	if err != nil && isConnectionError(err) {
+		q.replica.setHealthy(false)
+		return q.primary.Query(qs, args...)
+	}
+	return rows, err
+}
+
+func (q *readQueryer) QueryRow(qs string, args ...interface{}) *sql.Row {
+	return q.replica.stmts.QueryRow(qs, args...)
+}
+
+func (q *readQueryer) Exec(qs string, args ...interface{}) (sql.Result, error) {
+	return q.primary.Exec(qs, args...)
+}
+
+// isConnectionError 是否为连接错误，包括网络错误，以及 08 连接异常、 57P 服务关闭或者不可用
+func isConnectionError(err error) bool {
+	if e, ok := err.(*pq.Error); ok {
+		return strings.HasPrefix(string(e.Code), "08") || strings.HasPrefix(string(e.Code), "57P")
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	return err == driver.ErrBadConn || err == io.EOF || err == io.ErrUnexpectedEOF
+}
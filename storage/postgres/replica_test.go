@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func Test_isConnectionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "1", err: driver.ErrBadConn, want: true},
+		{name: "2", err: io.EOF, want: true},
+		{name: "3", err: &pq.Error{Code: "08006"}, want: true},
+		{name: "4", err: &pq.Error{Code: "57P01"}, want: true},
+		{name: "5", err: &pq.Error{Code: "57014"}, want: false},
+		{name: "6", err: &pq.Error{Code: postgresRelationDoesNotExistError}, want: false},
+		{name: "7", err: sql.ErrNoRows, want: false},
+		{name: "8", err: errors.New("sql: converting argument"), want: false},
+	}
+	for _, tt := range tests {
+		if got := isConnectionError(tt.err); got != tt.want {
+			t.Errorf("%q. isConnectionError() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_pickReplica(t *testing.T) {
+	var p *PostgresAdapter
+	var r1, r2 *replica
+	/*****************************************************************/
+	p = &PostgresAdapter{}
+	if r := p.pickReplica(); r != nil {
+		t.Error("expect:", nil, "result:", r)
+	}
+	/*****************************************************************/
+	r1 = &replica{healthy: true}
+	r2 = &replica{healthy: true}
+	p = &PostgresAdapter{replicas: []*replica{r1, r2}}
+	if a, b := p.pickReplica(), p.pickReplica(); a == b {
+		t.Error("expect:", "different replicas", "result:", a, b)
+	}
+	/*****************************************************************/
+	r1 = &replica{healthy: false}
+	r2 = &replica{healthy: true}
+	p = &PostgresAdapter{replicas: []*replica{r1, r2}}
+	for i := 0; i < 3; i++ {
+		if r := p.pickReplica(); r != r2 {
+			t.Error("expect:", r2, "result:", r)
+		}
+	}
+	/*****************************************************************/
+	r1 = &replica{healthy: false}
+	r2 = &replica{healthy: false}
+	p = &PostgresAdapter{replicas: []*replica{r1, r2}}
+	if r := p.pickReplica(); r != nil {
+		t.Error("expect:", nil, "result:", r)
+	}
+}
@@ -0,0 +1,206 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+/*
+行级安全模式
+
+开启后，查询中的 _rperm 与 _wperm 条件不再转换为 SQL 条件，而是在事务中写入会话变量，
+由表上的行级安全策略完成过滤：
+talisman.rperm 读权限，用于 SELECT
+talisman.wperm 写权限，用于 UPDATE 与 DELETE
+变量的值为以 ',' 分隔的用户 id 、 role:角色名 与 * 。
+查询中没有对应的权限条件时，如使用 Master Key 的请求，把 talisman.rperm_all 或者 talisman.wperm_all 设置为 on 表示不做限制，
+变量未设置或者为空时策略拒绝访问，未经过 aclSession 的操作无法读取或者修改对象。
+
+表使用 FORCE ROW LEVEL SECURITY ，表的所有者同样受策略限制。
+超级用户与具有 BYPASSRLS 属性的角色不受策略限制，使用这样的角色连接时不开启行级安全模式，仍然在查询条件中校验。
+*/
+
+// rlsReadCheck 读权限策略，与 {"_rperm":{"$in":[nil,...]}} 等价
+const rlsReadCheck = `current_setting('talisman.rperm_all', true) = 'on' OR (COALESCE(current_setting('talisman.rperm', true), '') <> '' AND ("_rperm" IS NULL OR "_rperm" && string_to_array(current_setting('talisman.rperm', true), ',')))`
+
+// rlsWriteCheck 写权限策略，与 {"_wperm":{"$in":[nil,...]}} 等价
+const rlsWriteCheck = `current_setting('talisman.wperm_all', true) = 'on' OR (COALESCE(current_setting('talisman.wperm', true), '') <> '' AND ("_wperm" IS NULL OR "_wperm" && string_to_array(current_setting('talisman.wperm', true), ',')))`
+
+// rlsPolicies 表上的行级安全策略，按顺序执行
+var rlsPolicies = []string{
+	`ALTER TABLE "%[1]s" ENABLE ROW LEVEL SECURITY`,
+	`ALTER TABLE "%[1]s" FORCE ROW LEVEL SECURITY`,
+	`DROP POLICY IF EXISTS "talisman_read" ON "%[1]s"`,
+	`CREATE POLICY "talisman_read" ON "%[1]s" FOR SELECT USING (` + rlsReadCheck + `)`,
+	`DROP POLICY IF EXISTS "talisman_insert" ON "%[1]s"`,
+	`CREATE POLICY "talisman_insert" ON "%[1]s" FOR INSERT WITH CHECK (true)`,
+	`DROP POLICY IF EXISTS "talisman_update" ON "%[1]s"`,
+	`CREATE POLICY "talisman_update" ON "%[1]s" FOR UPDATE USING (` + rlsWriteCheck + `) WITH CHECK (true)`,
+	`DROP POLICY IF EXISTS "talisman_delete" ON "%[1]s"`,
+	`CREATE POLICY "talisman_delete" ON "%[1]s" FOR DELETE USING (` + rlsWriteCheck + `)`,
+}
+
+// queryer 执行 SQL 语句， stmtCache 与 sql.Tx 均实现了该接口
+type queryer interface {
+	Query(qs string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(qs string, args ...interface{}) *sql.Row
+	Exec(qs string, args ...interface{}) (sql.Result, error)
+}
+
+// SetRowLevelSecurity 设置是否使用行级安全策略校验 _rperm 与 _wperm
+// 开启后新建的表会自动添加策略，已存在的表在 PerformInitialization 时添加
+// 连接使用的角色为超级用户或者具有 BYPASSRLS 属性时策略不起作用，此时不开启并返回错误，仍然在查询条件中校验
+func (p *PostgresAdapter) SetRowLevelSecurity(enabled bool) error {
+	p.rowLevelSecurity = false
+	if enabled == false {
+		return nil
+	}
+	var super, bypass bool
+	err := p.db.QueryRow(`SELECT rolsuper, rolbypassrls FROM pg_roles WHERE rolname = current_user`).Scan(&super, &bypass)
+	if err != nil {
+		return err
+	}
+	if super || bypass {
+		return errors.New("row level security is bypassed by the database role, use a role without SUPERUSER and BYPASSRLS, fall back to ACL conditions in queries")
+	}
+	p.rowLevelSecurity = true
+	return nil
+}
+
+// enableRowLevelSecurity 为表添加行级安全策略，表中必须包含 _rperm 与 _wperm 字段
+func (p *PostgresAdapter) enableRowLevelSecurity(className string, tx *sql.Tx) error {
+	for _, policy := range rlsPolicies {
+		qs := fmt.Sprintf(policy, className)
+		var err error
+		if tx != nil {
+			_, err = tx.Exec(qs)
+		} else {
+			_, err = p.db.Exec(qs)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enableRowLevelSecurityForAll 为所有包含 _rperm 与 _wperm 字段的表添加行级安全策略
+func (p *PostgresAdapter) enableRowLevelSecurityForAll() error {
+	qs := `SELECT table_name FROM information_schema.columns WHERE table_schema = current_schema() AND column_name IN ('_rperm', '_wperm') GROUP BY table_name HAVING count(*) = 2`
+	rows, err := p.db.Query(qs)
+	if err != nil {
+		return err
+	}
+	classNames := []string{}
+	for rows.Next() {
+		var className string
+		err = rows.Scan(&className)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		classNames = append(classNames, className)
+	}
+	rows.Close()
+
+	for _, className := range classNames {
+		err = p.enableRowLevelSecurity(className, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aclSession 行级安全模式下，从查询中取出权限条件，开启事务并写入会话变量
+// 返回去掉权限条件的查询，以及执行语句使用的 queryer ，调用方需要在结束时提交或者回滚 tx
+// 未开启行级安全时 tx 为 nil ，直接使用预编译语句缓存
+func (p *PostgresAdapter) aclSession(query types.M) (types.M, queryer, *sql.Tx, error) {
+	return p.aclSessionOn(p.db, p.stmts, query)
+}
+
+// aclSessionOn 与 aclSession 相同，在指定的数据库上开启事务，不需要事务时使用 stmts 执行语句
+// 策略拒绝未设置变量的会话，因此查询中没有权限条件时同样需要开启事务，声明不限制对应的权限
+func (p *PostgresAdapter) aclSessionOn(db *sql.DB, stmts queryer, query types.M) (types.M, queryer, *sql.Tx, error) {
+	if p.rowLevelSecurity == false {
+		return query, stmts, nil, nil
+	}
+	query, rperm, wperm := extractACL(query)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	_, err = tx.Exec(`SELECT set_config('talisman.rperm', $1, true), set_config('talisman.wperm', $2, true), set_config('talisman.rperm_all', $3, true), set_config('talisman.wperm_all', $4, true)`, aclVariables(rperm, wperm)...)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, nil, err
+	}
+	return query, tx, tx, nil
+}
+
+// aclVariables 返回写入会话变量的值，依次为 rperm 、 wperm 、 rperm_all 、 wperm_all
+// 权限为空表示查询中没有对应的权限条件，设置 *_all 为 on 不做限制
+func aclVariables(rperm, wperm string) []interface{} {
+	all := func(perm string) string {
+		if perm == "" {
+			return "on"
+		}
+		return "off"
+	}
+	return []interface{}{rperm, wperm, all(rperm), all(wperm)}
+}
+
+// extractACL 从查询中取出 _rperm 与 _wperm 条件，返回去掉权限条件的查询与会话变量的值
+// 条件格式为 {"$in":[nil,"*","userid","role:xxx"]} ，
+// 不符合该格式、没有任何权限，或者包含 ',' 时保留在查询中，由 SQL 条件处理
+func extractACL(query types.M) (types.M, string, string) {
+	if query == nil {
+		return query, "", ""
+	}
+	rperm := aclSetting(query["_rperm"])
+	wperm := aclSetting(query["_wperm"])
+	if rperm == "" && wperm == "" {
+		return query, "", ""
+	}
+
+	result := utils.CopyMap(query)
+	if rperm != "" {
+		delete(result, "_rperm")
+	}
+	if wperm != "" {
+		delete(result, "_wperm")
+	}
+	return result, rperm, wperm
+}
+
+// aclSetting 把 {"$in":[nil,"*","userid"]} 转换为会话变量的值 *,userid ，无法转换时返回空
+func aclSetting(condition interface{}) string {
+	in := utils.A(utils.M(condition)["$in"])
+	if len(utils.M(condition)) != 1 || in == nil {
+		return ""
+	}
+	allowNull := false
+	ids := []string{}
+	for _, v := range in {
+		if v == nil {
+			allowNull = true
+			continue
+		}
+		id, ok := v.(string)
+		if ok == false || id == "" || strings.Contains(id, ",") {
+			return ""
+		}
+		ids = append(ids, id)
+	}
+	// 策略中总是允许 _rperm 与 _wperm 为空的对象
+	if allowNull == false {
+		return ""
+	}
+	return strings.Join(ids, ",")
+}
@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_extractACL(t *testing.T) {
+	type args struct {
+		query types.M
+	}
+	tests := []struct {
+		name      string
+		args      args
+		wantQuery types.M
+		wantRperm string
+		wantWperm string
+	}{
+		{
+			name:      "1",
+			args:      args{query: nil},
+			wantQuery: nil,
+			wantRperm: "",
+			wantWperm: "",
+		},
+		{
+			name:      "2",
+			args:      args{query: types.M{"key": "hello"}},
+			wantQuery: types.M{"key": "hello"},
+			wantRperm: "",
+			wantWperm: "",
+		},
+		{
+			name: "3",
+			args: args{query: types.M{
+				"key":    "hello",
+				"_rperm": types.M{"$in": types.S{nil, "*", "123456", "role:admin"}},
+			}},
+			wantQuery: types.M{"key": "hello"},
+			wantRperm: "*,123456,role:admin",
+			wantWperm: "",
+		},
+		{
+			name: "4",
+			args: args{query: types.M{
+				"objectId": "1024",
+				"_wperm":   types.M{"$in": types.S{nil, "*", "123456"}},
+			}},
+			wantQuery: types.M{"objectId": "1024"},
+			wantRperm: "",
+			wantWperm: "*,123456",
+		},
+		{
+			name: "5",
+			args: args{query: types.M{
+				"_rperm": types.M{"$in": types.S{nil}},
+			}},
+			wantQuery: types.M{"_rperm": types.M{"$in": types.S{nil}}},
+			wantRperm: "",
+			wantWperm: "",
+		},
+		{
+			name: "6",
+			args: args{query: types.M{
+				"_rperm": types.M{"$in": types.S{"*", "123456"}},
+			}},
+			wantQuery: types.M{"_rperm": types.M{"$in": types.S{"*", "123456"}}},
+			wantRperm: "",
+			wantWperm: "",
+		},
+		{
+			name: "7",
+			args: args{query: types.M{
+				"_rperm": types.M{"$in": types.S{nil, "*", "a,b"}},
+			}},
+			wantQuery: types.M{"_rperm": types.M{"$in": types.S{nil, "*", "a,b"}}},
+			wantRperm: "",
+			wantWperm: "",
+		},
+		{
+			name: "8",
+			args: args{query: types.M{
+				"_rperm": types.M{"$in": types.S{nil, "*"}, "$ne": "123456"},
+			}},
+			wantQuery: types.M{"_rperm": types.M{"$in": types.S{nil, "*"}, "$ne": "123456"}},
+			wantRperm: "",
+			wantWperm: "",
+		},
+	}
+	for _, tt := range tests {
+		gotQuery, gotRperm, gotWperm := extractACL(tt.args.query)
+		if !reflect.DeepEqual(gotQuery, tt.wantQuery) {
+			t.Errorf("%q. extractACL() gotQuery = %v, want %v", tt.name, gotQuery, tt.wantQuery)
+		}
+		if gotRperm != tt.wantRperm {
+			t.Errorf("%q. extractACL() gotRperm = %v, want %v", tt.name, gotRperm, tt.wantRperm)
+		}
+		if gotWperm != tt.wantWperm {
+			t.Errorf("%q. extractACL() gotWperm = %v, want %v", tt.name, gotWperm, tt.wantWperm)
+		}
+	}
+}
+
+func Test_aclVariables(t *testing.T) {
+	tests := []struct {
+		name  string
+		rperm string
+		wperm string
+		want  []interface{}
+	}{
+		{
+			name:  "1",
+			rperm: "",
+			wperm: "",
+			want:  []interface{}{"", "", "on", "on"},
+		},
+		{
+			name:  "2",
+			rperm: "*,123456",
+			wperm: "",
+			want:  []interface{}{"*,123456", "", "off", "on"},
+		},
+		{
+			name:  "3",
+			rperm: "",
+			wperm: "*,123456",
+			want:  []interface{}{"", "*,123456", "on", "off"},
+		},
+		{
+			name:  "4",
+			rperm: "*,role:admin",
+			wperm: "*,123456",
+			want:  []interface{}{"*,role:admin", "*,123456", "off", "off"},
+		},
+	}
+	for _, tt := range tests {
+		if got := aclVariables(tt.rperm, tt.wperm); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. aclVariables() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
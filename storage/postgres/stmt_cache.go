@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/lib/pq"
+	"github.com/okobsamoht/talisman/dependencies/lru"
+)
+
+const postgresFeatureNotSupportedError = "0A000"
+
+// stmtCache 预编译语句缓存，以 SQL 语句为 key ，超出容量时淘汰最久未使用的语句
+// 语句被淘汰时，如果仍有请求在使用，则等待使用完毕之后再关闭
+type stmtCache struct {
+	mu    sync.Mutex
+	db    *sql.DB
+	stmts *lru.Cache
+}
+
+// cachedStmt 缓存中的预编译语句
+// refs 为当前正在使用该语句的请求数， evicted 表示已从缓存中淘汰
+type cachedStmt struct {
+	stmt    *sql.Stmt
+	refs    int
+	evicted bool
+}
+
+// newStmtCache 创建预编译语句缓存， size 小于等于 0 时不进行缓存
+func newStmtCache(db *sql.DB, size int) *stmtCache {
+	c := &stmtCache{db: db}
+	if size <= 0 {
+		return c
+	}
+	c.stmts = lru.New(size)
+	c.stmts.OnEvicted = func(key, value interface{}) {
+		if s, ok := value.(*cachedStmt); ok {
+			s.evicted = true
+			if s.refs == 0 {
+				s.stmt.Close()
+			}
+		}
+	}
+	return c
+}
+
+// acquire 获取 qs 对应的预编译语句，不存在时进行预编译并加入缓存
+func (c *stmtCache) acquire(qs string) (*cachedStmt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.stmts.Get(qs); ok {
+		s := v.(*cachedStmt)
+		s.refs++
+		return s, nil
+	}
+	stmt, err := c.db.Prepare(qs)
+	if err != nil {
+		return nil, err
+	}
+	s := &cachedStmt{stmt: stmt, refs: 1}
+	c.stmts.Add(qs, s)
+	return s, nil
+}
+
+// release 释放预编译语句，已被淘汰的语句在最后一个请求释放时关闭
+func (c *stmtCache) release(s *cachedStmt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s.refs--
+	if s.evicted && s.refs == 0 {
+		s.stmt.Close()
+	}
+}
+
+// Query 执行查询，返回的 rows 会保持对语句的引用，关闭 rows 之前语句不会被真正关闭
+func (c *stmtCache) Query(qs string, args ...interface{}) (*sql.Rows, error) {
+	if c.stmts == nil {
+		return c.db.Query(qs, args...)
+	}
+	s, err := c.acquire(qs)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.stmt.Query(args...)
+	c.release(s)
+	if isStalePlanError(err) {
+		c.Clear()
+		return c.db.Query(qs, args...)
+	}
+	return rows, err
+}
+
+// QueryRow 执行查询，并返回一行结果
+func (c *stmtCache) QueryRow(qs string, args ...interface{}) *sql.Row {
+	if c.stmts == nil {
+		return c.db.QueryRow(qs, args...)
+	}
+	s, err := c.acquire(qs)
+	if err != nil {
+		// 预编译失败时直接执行，由 Scan 返回错误
+		return c.db.QueryRow(qs, args...)
+	}
+	defer c.release(s)
+	return s.stmt.QueryRow(args...)
+}
+
+// Exec 执行语句
+func (c *stmtCache) Exec(qs string, args ...interface{}) (sql.Result, error) {
+	if c.stmts == nil {
+		return c.db.Exec(qs, args...)
+	}
+	s, err := c.acquire(qs)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.stmt.Exec(args...)
+	c.release(s)
+	if isStalePlanError(err) {
+		c.Clear()
+		return c.db.Exec(qs, args...)
+	}
+	return result, err
+}
+
+// Len 返回缓存中的语句数量
+func (c *stmtCache) Len() int {
+	if c.stmts == nil {
+		return 0
+	}
+	return c.stmts.Len()
+}
+
+// Clear 清空缓存，表结构发生变化时需要调用，避免使用过期的执行计划
+func (c *stmtCache) Clear() {
+	if c.stmts == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stmts.Clear()
+}
+
+// isStalePlanError 表结构被其他实例修改之后，已预编译的语句会返回 cached plan must not change result type
+func isStalePlanError(err error) bool {
+	if e, ok := err.(*pq.Error); ok {
+		return e.Code == postgresFeatureNotSupportedError
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"strconv"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+// ResultGuard 限制 Find 返回的对象数量与数据大小，适配器每读取一个对象调用一次 Add ，超出时中止查询
+// 限制来自 Find 的 options ： maxRows 最大对象数， maxBytes 最大字节数，取值为 0 或者不存在时不限制
+// 字节数为数据库返回的原始数据大小，与最终返回给客户端的 JSON 大小接近但不完全相同
+type ResultGuard struct {
+	maxRows  int
+	maxBytes int
+	rows     int
+	bytes    int
+}
+
+// NewResultGuard 从 options 中读取限制，没有任何限制时返回 nil
+func NewResultGuard(options types.M) *ResultGuard {
+	maxRows, _ := options["maxRows"].(int)
+	maxBytes, _ := options["maxBytes"].(int)
+	if maxRows <= 0 && maxBytes <= 0 {
+		return nil
+	}
+	return &ResultGuard{maxRows: maxRows, maxBytes: maxBytes}
+}
+
+// Add 记录读取到的一个对象， size 为对象的字节数，超出限制时返回错误
+func (g *ResultGuard) Add(size int) error {
+	if g == nil {
+		return nil
+	}
+	g.rows++
+	g.bytes += size
+	if g.maxRows > 0 && g.rows > g.maxRows {
+		return errs.E(errs.InvalidLimitError, "Query result exceeds the maximum of "+strconv.Itoa(g.maxRows)+" objects, use a smaller limit or more specific constraints.")
+	}
+	if g.maxBytes > 0 && g.bytes > g.maxBytes {
+		return errs.E(errs.ObjectTooLarge, "Query result exceeds the maximum size of "+strconv.Itoa(g.maxBytes)+" bytes, use keys to select fewer fields or a smaller limit.")
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_ResultGuard(t *testing.T) {
+	var g *ResultGuard
+	var err, expect error
+	/*****************************************************************/
+	g = NewResultGuard(types.M{})
+	if g != nil {
+		t.Error("expect:", nil, "result:", g)
+	}
+	err = g.Add(1024)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	/*****************************************************************/
+	g = NewResultGuard(types.M{"maxRows": 2})
+	g.Add(10)
+	err = g.Add(10)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	err = g.Add(10)
+	expect = errs.E(errs.InvalidLimitError, "Query result exceeds the maximum of 2 objects, use a smaller limit or more specific constraints.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*****************************************************************/
+	g = NewResultGuard(types.M{"maxBytes": 100})
+	err = g.Add(60)
+	if err != nil {
+		t.Error("expect:", nil, "result:", err)
+	}
+	err = g.Add(60)
+	expect = errs.E(errs.ObjectTooLarge, "Query result exceeds the maximum size of 100 bytes, use keys to select fewer fields or a smaller limit.")
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+}
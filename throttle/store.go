@@ -0,0 +1,148 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Store 保存失败次数，计数在第一次增加后的 window 时间内有效
+type Store interface {
+	Incr(key string, window time.Duration) (int, error)
+	Get(key string) (int, error)
+	Del(key string) error
+}
+
+// MemoryStore 保存在内存中的计数，只适用于单实例部署
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]*counter
+	lastPrune time.Time
+}
+
+type counter struct {
+	count  int
+	expire time.Time
+}
+
+// NewMemoryStore ...
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters:  map[string]*counter{},
+		lastPrune: time.Now(),
+	}
+}
+
+// Incr ...
+func (m *MemoryStore) Incr(key string, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	// 定时清理过期的计数，避免大量不同的 IP 占用内存
+	if now.Sub(m.lastPrune) > window {
+		for k, c := range m.counters {
+			if c.expire.Before(now) {
+				delete(m.counters, k)
+			}
+		}
+		m.lastPrune = now
+	}
+
+	c, ok := m.counters[key]
+	if ok == false || c.expire.Before(now) {
+		c = &counter{expire: now.Add(window)}
+		m.counters[key] = c
+	}
+	c.count++
+	return c.count, nil
+}
+
+// Get ...
+func (m *MemoryStore) Get(key string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.counters[key]
+	if ok == false {
+		return 0, nil
+	}
+	if c.expire.Before(time.Now()) {
+		delete(m.counters, key)
+		return 0, nil
+	}
+	return c.count, nil
+}
+
+// Del ...
+func (m *MemoryStore) Del(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.counters, key)
+	return nil
+}
+
+// RedisStore 保存在 Redis 中的计数，多实例部署时共享
+type RedisStore struct {
+	p *redis.Pool
+}
+
+// NewRedisStore ...
+func NewRedisStore(address, password string) *RedisStore {
+	dialFunc := func() (c redis.Conn, err error) {
+		c, err = redis.Dial("tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		if password != "" {
+			if _, err := c.Do("AUTH", password); err != nil {
+				c.Close()
+				return nil, err
+			}
+		}
+		return
+	}
+	return &RedisStore{
+		p: &redis.Pool{
+			MaxIdle:     3,
+			IdleTimeout: 180 * time.Second,
+			Dial:        dialFunc,
+		},
+	}
+}
+
+// Incr 第一次增加时设置过期时间
+func (r *RedisStore) Incr(key string, window time.Duration) (int, error) {
+	c := r.p.Get()
+	defer c.Close()
+	count, err := redis.Int(c.Do("INCR", key))
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		seconds := int64(window / time.Second)
+		if seconds < 1 {
+			seconds = 1
+		}
+		_, err = c.Do("EXPIRE", key, seconds)
+	}
+	return count, err
+}
+
+// Get ...
+func (r *RedisStore) Get(key string) (int, error) {
+	c := r.p.Get()
+	defer c.Close()
+	count, err := redis.Int(c.Do("GET", key))
+	if err == redis.ErrNil {
+		return 0, nil
+	}
+	return count, err
+}
+
+// Del ...
+func (r *RedisStore) Del(key string) error {
+	c := r.p.Get()
+	defer c.Close()
+	_, err := c.Do("DEL", key)
+	return err
+}
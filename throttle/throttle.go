@@ -0,0 +1,163 @@
+// Package throttle 限制登录尝试次数，按 IP 与用户名分别计数，防止暴力破解密码
+// 计数在第一次失败后的时间窗口内有效，窗口结束后自动清零
+// 与账户锁定规则不同，超过阈值时直接拒绝请求，不会查询与修改用户数据
+package throttle
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/context"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// Login 登录接口使用的限制器，未开启 LoginThrottle 时为 nil
+var Login *Limiter
+
+func init() {
+	Init()
+	config.OnReload(func(c *config.Config) {
+		if Login != nil {
+			Login.SetLimits(c.LoginThrottleIPThreshold, c.LoginThrottleUsernameThreshold, time.Duration(c.LoginThrottleWindow)*time.Second)
+		}
+	})
+}
+
+// Init 根据当前配置创建 Login
+func Init() {
+	Login = nil
+	if config.TConfig.LoginThrottle == false {
+		return
+	}
+	var store Store
+	if config.TConfig.LoginThrottleStore == "Redis" {
+		store = NewRedisStore(config.TConfig.RedisAddress, config.TConfig.RedisPassword)
+	} else {
+		store = NewMemoryStore()
+	}
+	Login = NewLimiter(
+		store,
+		config.TConfig.LoginThrottleIPThreshold,
+		config.TConfig.LoginThrottleUsernameThreshold,
+		time.Duration(config.TConfig.LoginThrottleWindow)*time.Second,
+	)
+}
+
+// Limiter 按 IP 与用户名限制失败次数，阈值为 0 时不限制
+type Limiter struct {
+	mu                sync.RWMutex
+	store             Store
+	ipThreshold       int
+	usernameThreshold int
+	window            time.Duration
+}
+
+// NewLimiter 创建限制器， window 为计数的时间窗口
+func NewLimiter(store Store, ipThreshold, usernameThreshold int, window time.Duration) *Limiter {
+	return &Limiter{
+		store:             store,
+		ipThreshold:       ipThreshold,
+		usernameThreshold: usernameThreshold,
+		window:            window,
+	}
+}
+
+// SetLimits 修改阈值与时间窗口，已有的计数保持不变，用于重新加载配置
+func (l *Limiter) SetLimits(ipThreshold, usernameThreshold int, window time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ipThreshold = ipThreshold
+	l.usernameThreshold = usernameThreshold
+	l.window = window
+}
+
+func (l *Limiter) limits() (int, int, time.Duration) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.ipThreshold, l.usernameThreshold, l.window
+}
+
+// Check 检查 IP 或者用户名的失败次数是否已达到阈值，达到时返回 RequestLimitExceeded 错误
+// 存储不可用时不做限制，避免影响正常登录
+func (l *Limiter) Check(ip, username string) error {
+	ipThreshold, usernameThreshold, _ := l.limits()
+	if (ip != "" && l.exceeded(ipKey(ip), ipThreshold)) ||
+		(username != "" && l.exceeded(usernameKey(username), usernameThreshold)) {
+		return errs.E(errs.RequestLimitExceeded, "Too many failed login attempts, please try again later.")
+	}
+	return nil
+}
+
+// Fail 记录一次失败的登录
+func (l *Limiter) Fail(ip, username string) {
+	ipThreshold, usernameThreshold, window := l.limits()
+	if ip != "" && ipThreshold > 0 {
+		l.store.Incr(ipKey(ip), window)
+	}
+	if username != "" && usernameThreshold > 0 {
+		l.store.Incr(usernameKey(username), window)
+	}
+}
+
+// Succeed 登录成功后清除用户名的失败次数， IP 的计数继续保留，防止同一 IP 轮流尝试多个用户
+func (l *Limiter) Succeed(ip, username string) {
+	if username != "" {
+		l.store.Del(usernameKey(username))
+	}
+}
+
+// Filter 返回检查登录请求的过滤器，超过阈值时返回 429 ，如：
+// beego.InsertFilter("/v1/login", beego.BeforeRouter, throttle.Login.Filter())
+// 用户名从 url 参数或者 JSON 请求体中获取，失败次数需要由处理登录的接口调用 Fail 记录
+// IP 使用 config.ClientIP ，只有经过可信代理时才读取 X-Forwarded-For ，客户端无法通过修改请求头绕过限制
+func (l *Limiter) Filter() func(ctx *context.Context) {
+	return func(ctx *context.Context) {
+		err := l.Check(config.ClientIP(ctx.Request), requestUsername(ctx))
+		if err == nil {
+			return
+		}
+		ctx.Output.SetStatus(429)
+		ctx.Output.JSON(errs.ErrorToMap(err), false, false)
+	}
+}
+
+func (l *Limiter) exceeded(key string, threshold int) bool {
+	if threshold <= 0 {
+		return false
+	}
+	count, err := l.store.Get(key)
+	if err != nil {
+		return false
+	}
+	return count >= threshold
+}
+
+// requestUsername 获取请求中的用户名，与登录接口记录失败次数时一样进行规范化
+func requestUsername(ctx *context.Context) string {
+	if username := ctx.Input.Query("username"); username != "" {
+		return config.NormalizeUsername(username)
+	}
+	if len(ctx.Input.RequestBody) == 0 {
+		return ""
+	}
+	var body types.M
+	if json.Unmarshal(ctx.Input.RequestBody, &body) != nil {
+		return ""
+	}
+	return config.NormalizeUsername(utils.S(body["username"]))
+}
+
+const keySeparator = ":"
+
+func ipKey(ip string) string {
+	return strings.Join([]string{config.TConfig.AppID, "throttle", "ip", ip}, keySeparator)
+}
+
+func usernameKey(username string) string {
+	return strings.Join([]string{config.TConfig.AppID, "throttle", "username", username}, keySeparator)
+}
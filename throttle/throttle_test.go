@@ -0,0 +1,206 @@
+package throttle
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/context"
+	"github.com/okobsamoht/talisman/config"
+	"github.com/okobsamoht/talisman/errs"
+)
+
+func Test_MemoryStore(t *testing.T) {
+	var store *MemoryStore
+	var count int
+	var err error
+	var expect int
+	/*****************************************************/
+	store = NewMemoryStore()
+	count, err = store.Get("key")
+	expect = 0
+	if err != nil || count != expect {
+		t.Error("expect:", expect, "result:", count, err)
+	}
+	/*****************************************************/
+	store = NewMemoryStore()
+	store.Incr("key", time.Minute)
+	count, err = store.Incr("key", time.Minute)
+	expect = 2
+	if err != nil || count != expect {
+		t.Error("expect:", expect, "result:", count, err)
+	}
+	count, err = store.Get("key")
+	if err != nil || count != expect {
+		t.Error("expect:", expect, "result:", count, err)
+	}
+	/*****************************************************/
+	store = NewMemoryStore()
+	store.Incr("key", time.Minute)
+	store.Del("key")
+	count, err = store.Get("key")
+	expect = 0
+	if err != nil || count != expect {
+		t.Error("expect:", expect, "result:", count, err)
+	}
+	/*****************************************************/
+	store = NewMemoryStore()
+	store.Incr("key", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	count, err = store.Get("key")
+	expect = 0
+	if err != nil || count != expect {
+		t.Error("expect:", expect, "result:", count, err)
+	}
+	count, err = store.Incr("key", time.Minute)
+	expect = 1
+	if err != nil || count != expect {
+		t.Error("expect:", expect, "result:", count, err)
+	}
+}
+
+func Test_Limiter(t *testing.T) {
+	var l *Limiter
+	var err error
+	var expect error
+	limitErr := errs.E(errs.RequestLimitExceeded, "Too many failed login attempts, please try again later.")
+	/*****************************************************/
+	l = NewLimiter(NewMemoryStore(), 3, 2, time.Minute)
+	err = l.Check("127.0.0.1", "joe")
+	expect = nil
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*****************************************************/
+	l = NewLimiter(NewMemoryStore(), 3, 2, time.Minute)
+	l.Fail("127.0.0.1", "joe")
+	l.Fail("127.0.0.2", "joe")
+	err = l.Check("127.0.0.3", "joe")
+	expect = limitErr
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	err = l.Check("127.0.0.3", "tom")
+	expect = nil
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*****************************************************/
+	l = NewLimiter(NewMemoryStore(), 3, 2, time.Minute)
+	l.Fail("127.0.0.1", "joe")
+	l.Fail("127.0.0.1", "tom")
+	l.Fail("127.0.0.1", "amy")
+	err = l.Check("127.0.0.1", "bob")
+	expect = limitErr
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*****************************************************/
+	l = NewLimiter(NewMemoryStore(), 3, 2, time.Minute)
+	l.Fail("127.0.0.1", "joe")
+	l.Fail("127.0.0.2", "joe")
+	l.Succeed("127.0.0.3", "joe")
+	err = l.Check("127.0.0.3", "joe")
+	expect = nil
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*****************************************************/
+	l = NewLimiter(NewMemoryStore(), 0, 0, time.Minute)
+	l.Fail("127.0.0.1", "joe")
+	l.Fail("127.0.0.1", "joe")
+	err = l.Check("127.0.0.1", "joe")
+	expect = nil
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+	/*****************************************************/
+	l = NewLimiter(NewMemoryStore(), 3, 3, time.Minute)
+	l.Fail("127.0.0.1", "joe")
+	l.Fail("127.0.0.2", "joe")
+	l.SetLimits(3, 2, time.Minute)
+	err = l.Check("127.0.0.3", "joe")
+	expect = limitErr
+	if reflect.DeepEqual(expect, err) == false {
+		t.Error("expect:", expect, "result:", err)
+	}
+}
+
+func Test_Limiter_Filter(t *testing.T) {
+	normalization := config.TConfig.UsernameNormalization
+	defer func() { config.TConfig.UsernameNormalization = normalization }()
+	config.TConfig.UsernameNormalization = []string{"trim", "lowercase"}
+
+	var l *Limiter
+	var code int
+	request := func(l *Limiter, remoteAddr, body string) int {
+		r := httptest.NewRequest("POST", "/v1/login", strings.NewReader(body))
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		ctx := context.NewContext()
+		ctx.Reset(w, r)
+		ctx.Input.RequestBody = []byte(body)
+		l.Filter()(ctx)
+		return w.Code
+	}
+	/*****************************************************/
+	l = NewLimiter(NewMemoryStore(), 0, 2, time.Minute)
+	l.Fail("127.0.0.1", config.NormalizeUsername("Joe"))
+	l.Fail("127.0.0.1", config.NormalizeUsername("joe "))
+	code = request(l, "127.0.0.1:52100", `{"username":" JOE ","password":"123"}`)
+	if code != 429 {
+		t.Error("expect:", 429, "result:", code)
+	}
+	code = request(l, "127.0.0.1:52100", `{"username":"ann","password":"123"}`)
+	if code != 200 {
+		t.Error("expect:", 200, "result:", code)
+	}
+}
+
+func Test_Limiter_Filter_forwardedFor(t *testing.T) {
+	trustedProxies := config.TConfig.TrustedProxies
+	defer func() { config.TConfig.TrustedProxies = trustedProxies }()
+
+	var l *Limiter
+	var code int
+	request := func(l *Limiter, remoteAddr, forwardedFor string) int {
+		r := httptest.NewRequest("POST", "/v1/login", nil)
+		r.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		ctx := context.NewContext()
+		ctx.Reset(w, r)
+		l.Filter()(ctx)
+		return w.Code
+	}
+	/*****************************************************/
+	config.TConfig.TrustedProxies = nil
+	l = NewLimiter(NewMemoryStore(), 2, 0, time.Minute)
+	l.Fail("203.0.113.5", "")
+	l.Fail("203.0.113.5", "")
+	code = request(l, "203.0.113.5:52100", "198.51.100.1")
+	if code != 429 {
+		t.Error("expect:", 429, "result:", code)
+	}
+	code = request(l, "203.0.113.5:52100", "198.51.100.2")
+	if code != 429 {
+		t.Error("expect:", 429, "result:", code)
+	}
+	/*****************************************************/
+	config.TConfig.TrustedProxies = []string{"10.0.0.0/8"}
+	l = NewLimiter(NewMemoryStore(), 2, 0, time.Minute)
+	l.Fail("203.0.113.5", "")
+	l.Fail("203.0.113.5", "")
+	code = request(l, "10.0.0.2:52100", "198.51.100.1, 203.0.113.5")
+	if code != 429 {
+		t.Error("expect:", 429, "result:", code)
+	}
+	code = request(l, "10.0.0.2:52100", "198.51.100.1")
+	if code != 200 {
+		t.Error("expect:", 200, "result:", code)
+	}
+}
@@ -0,0 +1,373 @@
+// Package usermerge 把重复的用户合并到主用户中，如同一个人先用邮箱注册、又用 Facebook 登录产生的两个账号
+// 处理范围包括：所有类中指向重复用户的 Pointer 字段与 Relation 字段、重复用户拥有的 Relation 字段、
+// 所有对象 ACL 中重复用户的权限、第三方登录信息以及重复用户的 _Session 与 refreshToken
+// 重复用户对象最后处理：清除第三方登录信息与 ACL ，设置 mergedInto 指向主用户，之后不能再登录
+package usermerge
+
+import (
+	"sort"
+
+	"github.com/okobsamoht/talisman/cache"
+	"github.com/okobsamoht/talisman/errs"
+	"github.com/okobsamoht/talisman/orm"
+	"github.com/okobsamoht/talisman/types"
+	"github.com/okobsamoht/talisman/utils"
+)
+
+// Merge 把用户 duplicateID 合并到用户 primaryID 中，返回处理报告， dryRun 为 true 时只统计不修改
+// 报告格式：
+//
+//	{
+//		"primaryId": "xxx",
+//		"duplicateId": "yyy",
+//		"dryRun": false,
+//		"duplicate": "deactivated", // 之前的步骤出错时为 kept
+//		"sessions": 2,
+//		"pointers": [{"className": "Post", "fieldName": "author", "count": 3}],
+//		"relations": [{"className": "_Role", "fieldName": "users", "count": 1}],
+//		"ownedRelations": [{"className": "_User", "fieldName": "friends", "count": 5}],
+//		"acl": [{"className": "Post", "count": 3}],
+//		"authData": {"moved": ["facebook"], "conflicts": []},
+//		"email": "moved", // 主用户没有邮箱时移动重复用户的邮箱，否则为 kept
+//		"errors": []
+//	}
+//
+// 用户不存在、两个 id 相同或者重复用户已经被合并时返回错误，其他步骤出错时记录到 errors 中并继续处理
+func Merge(primaryID, duplicateID string, dryRun bool) (types.M, error) {
+	if primaryID == "" || duplicateID == "" || primaryID == duplicateID {
+		return nil, errs.E(errs.InvalidJSON, "primaryId and duplicateId should be two different users")
+	}
+	d := orm.TalismanDBController
+	users, err := d.Find("_User", types.M{"objectId": types.M{"$in": types.S{primaryID, duplicateID}}}, types.M{})
+	if err != nil {
+		return nil, err
+	}
+	var primary, duplicate types.M
+	for _, u := range users {
+		user := utils.M(u)
+		switch utils.S(user["objectId"]) {
+		case primaryID:
+			primary = user
+		case duplicateID:
+			duplicate = user
+		}
+	}
+	if primary == nil || duplicate == nil {
+		return nil, errs.E(errs.ObjectNotFound, "User not found.")
+	}
+	if duplicate["mergedInto"] != nil || primary["mergedInto"] != nil {
+		return nil, errs.E(errs.OperationForbidden, "User has already been merged.")
+	}
+	classes, err := d.LoadSchema(nil).GetAllClasses(types.M{"clearCache": true})
+	if err != nil {
+		return nil, err
+	}
+
+	m := &merger{
+		primaryID:   primaryID,
+		duplicateID: duplicateID,
+		primary:     userPointer(primaryID),
+		duplicate:   userPointer(duplicateID),
+		dryRun:      dryRun,
+		report: types.M{
+			"primaryId":      primaryID,
+			"duplicateId":    duplicateID,
+			"dryRun":         dryRun,
+			"sessions":       0,
+			"pointers":       types.S{},
+			"relations":      types.S{},
+			"ownedRelations": types.S{},
+			"acl":            types.S{},
+			"errors":         types.S{},
+		},
+	}
+	m.mergeReferences(classes)
+	m.mergeACL(classes)
+	m.removeSessions()
+	m.mergeUser(primary, duplicate)
+	return m.report, nil
+}
+
+type merger struct {
+	primaryID   string
+	duplicateID string
+	primary     types.M
+	duplicate   types.M
+	dryRun      bool
+	report      types.M
+}
+
+func (m *merger) addError(err error) {
+	m.report["errors"] = append(utils.A(m.report["errors"]), err.Error())
+}
+
+func (m *merger) addItem(key string, item types.M) {
+	m.report[key] = append(utils.A(m.report[key]), item)
+}
+
+// mergeReferences 把指向重复用户的 Pointer 与 Relation 改为指向主用户，并把重复用户拥有的 Relation 移动到主用户
+// _Session 与 _RefreshToken 在 removeSessions 中删除
+func (m *merger) mergeReferences(classes []types.M) {
+	d := orm.TalismanDBController
+	for _, class := range classes {
+		className := utils.S(class["className"])
+		if className == "_Session" || className == orm.RefreshTokenClassName {
+			continue
+		}
+		fields := utils.M(class["fields"])
+		for _, fieldName := range sortedFieldNames(fields) {
+			field := utils.M(fields[fieldName])
+			fieldType := utils.S(field["type"])
+			if className == "_User" && fieldType == "Relation" {
+				count, err := d.MoveRelatedObjects(className, fieldName, m.duplicateID, m.primaryID, m.dryRun)
+				if err != nil {
+					m.addError(err)
+				} else if count > 0 {
+					m.addItem("ownedRelations", types.M{"className": className, "fieldName": fieldName, "count": count})
+				}
+			}
+			if utils.S(field["targetClass"]) != "_User" {
+				continue
+			}
+			switch fieldType {
+			case "Pointer":
+				m.mergePointer(className, fieldName)
+			case "Relation":
+				count, err := d.ReplaceRelatedObject(className, fieldName, m.duplicateID, m.primaryID, m.dryRun)
+				if err != nil {
+					m.addError(err)
+					continue
+				}
+				if count > 0 {
+					m.addItem("relations", types.M{"className": className, "fieldName": fieldName, "count": count})
+				}
+			}
+		}
+	}
+	if m.dryRun == false {
+		// 角色可能发生变化
+		cache.Role.Del(m.primaryID)
+		cache.Role.Del(m.duplicateID)
+	}
+}
+
+// mergePointer 把 className 中 fieldName 指向重复用户的对象改为指向主用户
+func (m *merger) mergePointer(className, fieldName string) {
+	d := orm.TalismanDBController
+	query := types.M{fieldName: m.duplicate}
+	objects, err := d.Find(className, query, types.M{})
+	if err != nil {
+		m.addError(err)
+		return
+	}
+	if len(objects) == 0 {
+		return
+	}
+	if m.dryRun == false {
+		_, err = d.Update(className, query, types.M{fieldName: m.primary}, types.M{"many": true}, false)
+		if err != nil {
+			m.addError(err)
+			return
+		}
+	}
+	m.addItem("pointers", types.M{"className": className, "fieldName": fieldName, "count": len(objects)})
+}
+
+// mergeACL 把所有对象 ACL 中重复用户的权限合并到主用户
+func (m *merger) mergeACL(classes []types.M) {
+	d := orm.TalismanDBController
+	query := types.M{
+		"$or": types.S{
+			types.M{"_rperm": types.M{"$in": types.S{m.duplicateID}}},
+			types.M{"_wperm": types.M{"$in": types.S{m.duplicateID}}},
+		},
+	}
+	for _, class := range classes {
+		className := utils.S(class["className"])
+		if className == "_Session" || className == orm.RefreshTokenClassName {
+			continue
+		}
+		objects, err := d.Find(className, query, types.M{})
+		if err != nil {
+			m.addError(err)
+			continue
+		}
+		count := 0
+		for _, o := range objects {
+			object := utils.M(o)
+			// 重复用户的 ACL 在 mergeUser 中清除
+			if className == "_User" && utils.S(object["objectId"]) == m.duplicateID {
+				continue
+			}
+			acl := mergeACL(utils.M(object["ACL"]), m.duplicateID, m.primaryID)
+			if m.dryRun == false {
+				_, err := d.Update(className, types.M{"objectId": object["objectId"]}, types.M{"ACL": acl}, types.M{}, false)
+				if err != nil {
+					m.addError(err)
+					continue
+				}
+			}
+			count++
+		}
+		if count > 0 {
+			m.addItem("acl", types.M{"className": className, "count": count})
+		}
+	}
+}
+
+// removeSessions 删除重复用户的 _Session 与 refreshToken
+func (m *merger) removeSessions() {
+	d := orm.TalismanDBController
+	query := types.M{"user": m.duplicate}
+	sessions, err := d.Find("_Session", query, types.M{})
+	if err != nil {
+		m.addError(err)
+		return
+	}
+	m.report["sessions"] = len(sessions)
+	if m.dryRun {
+		return
+	}
+	if len(sessions) > 0 {
+		if err := d.Destroy("_Session", query, types.M{}); err != nil {
+			m.addError(err)
+			return
+		}
+		for _, s := range sessions {
+			cache.User.Del(utils.S(utils.M(s)["sessionToken"]))
+		}
+	}
+	if d.CollectionExists(orm.RefreshTokenClassName) {
+		err = d.Destroy(orm.RefreshTokenClassName, query, types.M{})
+		if err != nil && errs.GetErrorCode(err) != errs.ObjectNotFound {
+			m.addError(err)
+		}
+	}
+}
+
+// mergeUser 把重复用户的第三方登录信息与邮箱移动到主用户，然后停用重复用户，之前的步骤出错时不处理
+// 主用户与重复用户都有同一个第三方登录时保留主用户的，记录到 conflicts 中
+func (m *merger) mergeUser(primary, duplicate types.M) {
+	moved, conflicts := mergeAuthData(utils.M(primary["authData"]), utils.M(duplicate["authData"]))
+	m.report["authData"] = types.M{"moved": sortedFieldNames(moved), "conflicts": conflicts}
+	moveEmail := utils.S(primary["email"]) == "" && utils.S(duplicate["email"]) != ""
+	if moveEmail {
+		m.report["email"] = "moved"
+	} else {
+		m.report["email"] = "kept"
+	}
+	if len(utils.A(m.report["errors"])) > 0 {
+		m.report["duplicate"] = "kept"
+		return
+	}
+	m.report["duplicate"] = "deactivated"
+	if m.dryRun {
+		return
+	}
+
+	d := orm.TalismanDBController
+	// 先从重复用户中删除，避免违反 authData 与 email 的唯一索引
+	deactivate := types.M{
+		"mergedInto": m.primary,
+		"ACL":        types.M{},
+	}
+	authData := types.M{}
+	for provider := range utils.M(duplicate["authData"]) {
+		authData[provider] = nil
+	}
+	if len(authData) > 0 {
+		deactivate["authData"] = authData
+	}
+	if moveEmail {
+		deactivate["email"] = types.M{"__op": "Delete"}
+		deactivate["emailVerified"] = types.M{"__op": "Delete"}
+	}
+	_, err := d.Update("_User", types.M{"objectId": m.duplicateID}, deactivate, types.M{}, false)
+	if err != nil {
+		m.addError(err)
+		m.report["duplicate"] = "kept"
+		return
+	}
+
+	update := types.M{}
+	if len(moved) > 0 {
+		update["authData"] = moved
+	}
+	if moveEmail {
+		update["email"] = duplicate["email"]
+		if duplicate["emailVerified"] != nil {
+			update["emailVerified"] = duplicate["emailVerified"]
+		}
+	}
+	if len(update) > 0 {
+		_, err = d.Update("_User", types.M{"objectId": m.primaryID}, update, types.M{}, false)
+		if err != nil {
+			m.addError(err)
+		}
+	}
+	// 主用户的信息发生变化，清除已缓存的用户信息
+	sessions, err := d.Find("_Session", types.M{"user": m.primary}, types.M{})
+	if err == nil {
+		for _, s := range sessions {
+			cache.User.Del(utils.S(utils.M(s)["sessionToken"]))
+		}
+	}
+}
+
+// mergeACL 把 ACL 中 fromID 的权限合并到 toID ，任意一方有读或者写权限时合并后也有
+func mergeACL(acl types.M, fromID, toID string) types.M {
+	result := types.M{}
+	for k, v := range acl {
+		if k != fromID {
+			result[k] = v
+		}
+	}
+	from := utils.M(acl[fromID])
+	if from == nil {
+		return result
+	}
+	to := types.M{}
+	for k, v := range utils.M(acl[toID]) {
+		to[k] = v
+	}
+	for _, p := range []string{"read", "write"} {
+		if from[p] == true {
+			to[p] = true
+		}
+	}
+	if len(to) > 0 {
+		result[toID] = to
+	}
+	return result
+}
+
+// mergeAuthData 返回需要移动到主用户的第三方登录信息，以及两个用户都有的第三方登录名称
+func mergeAuthData(primary, duplicate types.M) (types.M, []string) {
+	moved := types.M{}
+	conflicts := []string{}
+	for provider, data := range duplicate {
+		if data == nil {
+			continue
+		}
+		if primary[provider] != nil {
+			conflicts = append(conflicts, provider)
+			continue
+		}
+		moved[provider] = data
+	}
+	sort.Strings(conflicts)
+	return moved, conflicts
+}
+
+func userPointer(userID string) types.M {
+	return types.M{"__type": "Pointer", "className": "_User", "objectId": userID}
+}
+
+func sortedFieldNames(fields types.M) []string {
+	names := []string{}
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,66 @@
+package usermerge
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/okobsamoht/talisman/types"
+)
+
+func Test_mergeACL(t *testing.T) {
+	tests := []struct {
+		name string
+		acl  types.M
+		want types.M
+	}{
+		{
+			name: "1",
+			acl:  types.M{"*": types.M{"read": true}, "u2": types.M{"read": true, "write": true}},
+			want: types.M{"*": types.M{"read": true}, "u1": types.M{"read": true, "write": true}},
+		},
+		{
+			name: "2",
+			acl:  types.M{"u1": types.M{"read": true}, "u2": types.M{"write": true}},
+			want: types.M{"u1": types.M{"read": true, "write": true}},
+		},
+		{
+			name: "3",
+			acl:  types.M{"u1": types.M{"read": true, "write": true}, "u2": types.M{"read": true}},
+			want: types.M{"u1": types.M{"read": true, "write": true}},
+		},
+		{
+			name: "4",
+			acl:  types.M{"role:admin": types.M{"read": true}},
+			want: types.M{"role:admin": types.M{"read": true}},
+		},
+	}
+	for _, tt := range tests {
+		if got := mergeACL(tt.acl, "u2", "u1"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. mergeACL() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_mergeAuthData(t *testing.T) {
+	primary := types.M{
+		"facebook": types.M{"id": "1001"},
+	}
+	duplicate := types.M{
+		"facebook": types.M{"id": "1002"},
+		"twitter":  types.M{"id": "2002"},
+		"github":   nil,
+	}
+	moved, conflicts := mergeAuthData(primary, duplicate)
+	expect := types.M{"twitter": types.M{"id": "2002"}}
+	if reflect.DeepEqual(expect, moved) == false {
+		t.Error("expect:", expect, "result:", moved)
+	}
+	if reflect.DeepEqual([]string{"facebook"}, conflicts) == false {
+		t.Error("expect:", []string{"facebook"}, "result:", conflicts)
+	}
+
+	moved, conflicts = mergeAuthData(nil, nil)
+	if len(moved) != 0 || len(conflicts) != 0 {
+		t.Error("expect:", "empty", "result:", moved, conflicts)
+	}
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizationSteps 支持的规范化方式，按此顺序处理
+var NormalizationSteps = []string{"trim", "nfc", "lowercase"}
+
+// Normalize 按 steps 中包含的方式规范化字符串， steps 的顺序不影响结果
+// trim 去除首尾空白， nfc 转换为 Unicode NFC 形式， lowercase 转换为小写
+func Normalize(s string, steps []string) string {
+	enabled := map[string]bool{}
+	for _, step := range steps {
+		enabled[step] = true
+	}
+	if enabled["trim"] {
+		s = strings.TrimSpace(s)
+	}
+	if enabled["nfc"] {
+		s = norm.NFC.String(s)
+	}
+	if enabled["lowercase"] {
+		s = strings.ToLower(s)
+	}
+	return s
+}
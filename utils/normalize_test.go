@@ -0,0 +1,24 @@
+package utils
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		s     string
+		steps []string
+		want  string
+	}{
+		{s: " Joe ", steps: nil, want: " Joe "},
+		{s: " Joe ", steps: []string{"trim"}, want: "Joe"},
+		{s: " Joe ", steps: []string{"lowercase"}, want: " joe "},
+		{s: " Joe@Example.COM ", steps: []string{"lowercase", "trim"}, want: "joe@example.com"},
+		{s: "Jose\u0301", steps: []string{"nfc"}, want: "Jos\u00e9"},
+		{s: "Jose\u0301", steps: []string{"lowercase"}, want: "jose\u0301"},
+		{s: " JOSE\u0301", steps: []string{"trim", "nfc", "lowercase"}, want: "jos\u00e9"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.s, tt.steps); got != tt.want {
+			t.Errorf("Normalize(%q, %v) = %q, want %q", tt.s, tt.steps, got, tt.want)
+		}
+	}
+}
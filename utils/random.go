@@ -2,12 +2,12 @@ package utils
 
 import (
 	"github.com/astaxie/beego/utils"
-	"gopkg.in/mgo.v2/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // CreateObjectID ...
 func CreateObjectID() string {
-	return bson.NewObjectId().Hex()
+	return primitive.NewObjectID().Hex()
 }
 
 // CreateToken ...
@@ -26,3 +26,8 @@ func CreateFileName() string {
 func CreateString(n int) string {
 	return string(utils.RandomCreateBytes(n))
 }
+
+// CreateDigits 生成 n 位数字，用于短信验证码
+func CreateDigits(n int) string {
+	return string(utils.RandomCreateBytes(n, []byte("0123456789")...))
+}
@@ -0,0 +1,24 @@
+package utils
+
+// maxRequestIDLength 客户端传入的请求 ID 最大长度
+const maxRequestIDLength = 64
+
+// CreateRequestID 生成请求 ID ，格式与 UUID 相同
+func CreateRequestID() string {
+	return CreateFileName()
+}
+
+// IsRequestID 校验客户端传入的请求 ID ，只允许字母、数字、 - 与 _
+// 请求 ID 会写入日志、响应头与数据库查询注释中，不符合要求的 ID 由服务端重新生成
+func IsRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '-' || c == '_' {
+			continue
+		}
+		return false
+	}
+	return true
+}
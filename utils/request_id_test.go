@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCreateRequestID(t *testing.T) {
+	id := CreateRequestID()
+	if len(id) != 36 || IsRequestID(id) == false {
+		t.Error("CreateRequestID is invalid!", id)
+	}
+}
+
+func TestIsRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{name: "1", id: "", want: false},
+		{name: "2", id: "0A1B2C3D-4E5F-6A7B-8C9D-0E1F2A3B4C5D", want: true},
+		{name: "3", id: "req_123", want: true},
+		{name: "4", id: "abc */ DROP TABLE", want: false},
+		{name: "5", id: "abc\nxyz", want: false},
+		{name: "6", id: strings.Repeat("a", 65), want: false},
+	}
+	for _, tt := range tests {
+		if got := IsRequestID(tt.id); got != tt.want {
+			t.Errorf("%q. IsRequestID() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
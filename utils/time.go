@@ -1,15 +1,34 @@
 package utils
 
 import (
+	"errors"
 	"time"
+
+	"github.com/okobsamoht/talisman/types"
 )
 
 // ISO8601 ...
 const ISO8601 = "2006-01-02T15:04:05.000Z"
 
-// StringtoTime ...
+// legacyLayouts 旧版 SDK 使用的时间格式：不带毫秒、带时区偏移，或者不带时区的本地格式，均按 UTC 处理
+var legacyLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// StringtoTime 解析 ISO8601 格式的时间，兼容旧版 SDK 的时间格式
 func StringtoTime(s string) (time.Time, error) {
-	return time.ParseInLocation(ISO8601, s, time.UTC)
+	t, err := time.ParseInLocation(ISO8601, s, time.UTC)
+	if err == nil {
+		return t, nil
+	}
+	for _, layout := range legacyLayouts {
+		if lt, e := time.ParseInLocation(layout, s, time.UTC); e == nil {
+			return lt.UTC(), nil
+		}
+	}
+	return t, err
 }
 
 // TimetoString ...
@@ -42,3 +61,96 @@ func StringtoUnixmilli(s string) (int64, error) {
 func UnixmillitoString(m int64) string {
 	return TimetoString(UnixmillitoTime(m))
 }
+
+// errInvalidDate 无法转换为时间的值
+var errInvalidDate = errors.New("invalid date")
+
+// DateToTime 把 Date 对象 {"__type":"Date","iso":"..."} 、时间字符串或者 time.Time 转换为 UTC 时间
+// 两个数据库适配器都使用该函数处理 Date 类型，保证同样的值得到同样的时间
+func DateToTime(v interface{}) (time.Time, error) {
+	switch value := v.(type) {
+	case time.Time:
+		return value.UTC(), nil
+	case string:
+		return StringtoTime(value)
+	}
+	if date := M(v); date != nil && S(date["__type"]) == "Date" {
+		if t, ok := date["iso"].(time.Time); ok {
+			return t.UTC(), nil
+		}
+		return StringtoTime(S(date["iso"]))
+	}
+	return time.Time{}, errInvalidDate
+}
+
+// NormalizeISO 把时间字符串转换为带毫秒的 UTC 时间，如 2016-02-28T21:25:05+08:00 转换为 2016-02-28T13:25:05.000Z
+func NormalizeISO(s string) (string, error) {
+	t, err := StringtoTime(s)
+	if err != nil {
+		return "", err
+	}
+	return TimetoString(t), nil
+}
+
+// DateJSON 把时间转换为 API 格式的 Date 对象
+func DateJSON(t time.Time) types.M {
+	return types.M{
+		"__type": "Date",
+		"iso":    TimetoString(t),
+	}
+}
+
+// relativePeriods 相对于当前时间的时间范围，对应的单位与偏移量
+var relativePeriods = map[string]struct {
+	unit   string
+	offset int
+}{
+	"today":     {"day", 0},
+	"yesterday": {"day", -1},
+	"tomorrow":  {"day", 1},
+	"thisWeek":  {"week", 0},
+	"lastWeek":  {"week", -1},
+	"nextWeek":  {"week", 1},
+	"thisMonth": {"month", 0},
+	"lastMonth": {"month", -1},
+	"nextMonth": {"month", 1},
+	"thisYear":  {"year", 0},
+	"lastYear":  {"year", -1},
+	"nextYear":  {"year", 1},
+}
+
+// DateBucket 返回时区 loc 中包含 date 的时间范围 [start, end) ，结果为 UTC 时间
+// period 为 day week month year 时直接使用 date ，为 today yesterday thisWeek lastMonth 等时 date 为当前时间
+// 星期从周一开始，按当地时间的零点计算，夏令时切换的日期不一定是 24 小时
+func DateBucket(period string, date time.Time, loc *time.Location) (time.Time, time.Time, error) {
+	unit, offset := period, 0
+	if p, ok := relativePeriods[period]; ok {
+		unit, offset = p.unit, p.offset
+	}
+	d := date.In(loc)
+	var start, end time.Time
+	switch unit {
+	case "day":
+		start = time.Date(d.Year(), d.Month(), d.Day()+offset, 0, 0, 0, 0, loc)
+		end = time.Date(d.Year(), d.Month(), d.Day()+offset+1, 0, 0, 0, 0, loc)
+	case "week":
+		day := d.Day() - (int(d.Weekday())+6)%7 + offset*7
+		start = time.Date(d.Year(), d.Month(), day, 0, 0, 0, 0, loc)
+		end = time.Date(d.Year(), d.Month(), day+7, 0, 0, 0, 0, loc)
+	case "month":
+		start = time.Date(d.Year(), d.Month()+time.Month(offset), 1, 0, 0, 0, 0, loc)
+		end = time.Date(d.Year(), d.Month()+time.Month(offset+1), 1, 0, 0, 0, 0, loc)
+	case "year":
+		start = time.Date(d.Year()+offset, time.January, 1, 0, 0, 0, 0, loc)
+		end = time.Date(d.Year()+offset+1, time.January, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Time{}, time.Time{}, errors.New("invalid period: " + period)
+	}
+	return start.UTC(), end.UTC(), nil
+}
+
+// IsRelativePeriod period 是否为相对于当前时间的时间范围
+func IsRelativePeriod(period string) bool {
+	_, ok := relativePeriods[period]
+	return ok
+}
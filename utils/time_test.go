@@ -1,6 +1,12 @@
 package utils
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/okobsamoht/talisman/types"
+)
 
 func TestStringtoTime(t *testing.T) {
 	s := "2016-02-28T13:25:05.123Z"
@@ -39,3 +45,101 @@ func TestUnixmillitoString(t *testing.T) {
 		t.Error("UnixmillitoString error")
 	}
 }
+
+func TestStringtoTimeLegacy(t *testing.T) {
+	expect := "2016-02-28T13:25:05.000Z"
+	for _, s := range []string{
+		"2016-02-28T13:25:05Z",
+		"2016-02-28T21:25:05+08:00",
+		"2016-02-28T13:25:05",
+		"2016-02-28 13:25:05",
+	} {
+		time, err := StringtoTime(s)
+		if err != nil || TimetoString(time) != expect {
+			t.Error("expect:", expect, "result:", TimetoString(time), err)
+		}
+	}
+	_, err := StringtoTime("2016-02-28")
+	if err == nil {
+		t.Error("expect:", "error", "result:", nil)
+	}
+}
+
+func TestDateToTime(t *testing.T) {
+	expect := "2016-02-28T13:25:05.123Z"
+	for _, v := range []interface{}{
+		"2016-02-28T13:25:05.123Z",
+		"2016-02-28T21:25:05.123+08:00",
+		types.M{"__type": "Date", "iso": "2016-02-28T13:25:05.123Z"},
+		map[string]interface{}{"__type": "Date", "iso": "2016-02-28T21:25:05.123+08:00"},
+		time.Date(2016, 2, 28, 21, 25, 5, 123000000, time.FixedZone("CST", 8*3600)),
+	} {
+		d, err := DateToTime(v)
+		if err != nil || TimetoString(d) != expect || d.Location() != time.UTC {
+			t.Error("expect:", expect, "result:", TimetoString(d), err)
+		}
+	}
+	for _, v := range []interface{}{
+		nil,
+		1456665905123,
+		"2016-02-28",
+		types.M{"__type": "Pointer", "iso": "2016-02-28T13:25:05.123Z"},
+	} {
+		if _, err := DateToTime(v); err == nil {
+			t.Error("expect:", "error", "result:", nil)
+		}
+	}
+}
+
+func TestNormalizeISO(t *testing.T) {
+	expect := "2016-02-28T13:25:05.000Z"
+	s, err := NormalizeISO("2016-02-28 13:25:05")
+	if err != nil || s != expect {
+		t.Error("expect:", expect, "result:", s, err)
+	}
+	_, err = NormalizeISO("hello")
+	if err == nil {
+		t.Error("expect:", "error", "result:", nil)
+	}
+}
+
+func TestDateJSON(t *testing.T) {
+	expect := types.M{"__type": "Date", "iso": "2016-02-28T13:25:05.123Z"}
+	result := DateJSON(UnixmillitoTime(1456665905123))
+	if reflect.DeepEqual(expect, result) == false {
+		t.Error("expect:", expect, "result:", result)
+	}
+}
+
+func TestDateBucket(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	// 2016-02-28 为周日，上海时间为 2016-02-29 周一 00:30
+	now := time.Date(2016, 2, 28, 16, 30, 0, 0, time.UTC)
+	tests := []struct {
+		period string
+		loc    *time.Location
+		start  string
+		end    string
+	}{
+		{"today", time.UTC, "2016-02-28T00:00:00.000Z", "2016-02-29T00:00:00.000Z"},
+		{"today", shanghai, "2016-02-28T16:00:00.000Z", "2016-02-29T16:00:00.000Z"},
+		{"yesterday", shanghai, "2016-02-27T16:00:00.000Z", "2016-02-28T16:00:00.000Z"},
+		{"day", shanghai, "2016-02-28T16:00:00.000Z", "2016-02-29T16:00:00.000Z"},
+		{"thisWeek", time.UTC, "2016-02-22T00:00:00.000Z", "2016-02-29T00:00:00.000Z"},
+		{"thisWeek", shanghai, "2016-02-28T16:00:00.000Z", "2016-03-06T16:00:00.000Z"},
+		{"lastWeek", shanghai, "2016-02-21T16:00:00.000Z", "2016-02-28T16:00:00.000Z"},
+		{"thisMonth", shanghai, "2016-01-31T16:00:00.000Z", "2016-02-29T16:00:00.000Z"},
+		{"nextMonth", time.UTC, "2016-03-01T00:00:00.000Z", "2016-04-01T00:00:00.000Z"},
+		{"lastYear", time.UTC, "2015-01-01T00:00:00.000Z", "2016-01-01T00:00:00.000Z"},
+	}
+	for _, tt := range tests {
+		start, end, err := DateBucket(tt.period, now, tt.loc)
+		if err != nil || TimetoString(start) != tt.start || TimetoString(end) != tt.end {
+			t.Error("expect:", tt.period, tt.start, tt.end, "result:", TimetoString(start), TimetoString(end), err)
+		}
+	}
+	_, _, err := DateBucket("someday", now, time.UTC)
+	if err == nil {
+		t.Error("expect:", "error", "result:", nil)
+	}
+}